@@ -7,7 +7,7 @@ import '../styles/UserAnswers.css';
 const UserAnswers = ({ user }) => {
   const [answers, setAnswers] = useState([]);
   const [hasMore, setHasMore] = useState(true);
-  const [start, setStart] = useState(0);
+  const [cursor, setCursor] = useState('');
   const count = 10; // Number of answers to load per request
 
   const cookieElement = document.cookie.split('; ').find(row => row.startsWith('token='));
@@ -19,7 +19,7 @@ const UserAnswers = ({ user }) => {
 
   const fetchAnswers = async () => {
     try {
-      const response = await fetch(`${API_URL}/get_user_profile/${user}?start=${start}&count=${count}`, {
+      const response = await fetch(`${API_URL}/get_user_profile/${user}?cursor=${encodeURIComponent(cursor)}&count=${count}`, {
         headers: token !== null ? {
           'Content-Type': 'application/json',
           'Authorization': `Bearer ${token}`
@@ -35,9 +35,9 @@ const UserAnswers = ({ user }) => {
         // Combine new answers with existing ones, avoiding duplicates
         const combinedAnswers = [...answers, ...newAnswers.filter(newAnswer => !answers.some(answer => answer.id === newAnswer.id))];
         setAnswers(combinedAnswers);
-        setStart(prevStart => prevStart + count);
+        setCursor(data.next_cursor);
 
-        if (newAnswers.length < count) {
+        if (!data.next_cursor) {
           setHasMore(false);
         }
       } else {
@@ -15,7 +15,7 @@ const QuestionList = ({ user }) => {
     }
   const [questions, setQuestions] = useState([]);
   const [hasMore, setHasMore] = useState(true);
-  const [start, setStart] = useState(0);
+  const [cursor, setCursor] = useState('');
   const count = 10; // Number of questions to load per request
 
   useEffect(() => {
@@ -24,7 +24,7 @@ const QuestionList = ({ user }) => {
 
   const fetchQuestions = async () => {
     try {
-    const response = await fetch(`${API_URL}/get_questions?start=${start}&count=${count}`, {
+    const response = await fetch(`${API_URL}/get_questions?cursor=${encodeURIComponent(cursor)}&count=${count}`, {
       headers: {
         'Content-Type': 'application/json',
         'Authorization': `Bearer ${token}`
@@ -33,15 +33,16 @@ const QuestionList = ({ user }) => {
       if (!response.ok) {
         throw new Error('Network response was not ok');
       }
-      const newQuestions = await response.json();
+      const page = await response.json();
+      const newQuestions = page.questions;
 
       if (newQuestions) {
         // Combine new questions with existing ones, avoiding duplicates
         const combinedQuestions = [...questions, ...newQuestions.filter(newQuestion => !questions.some(question => question.id === newQuestion.id))];
         setQuestions(combinedQuestions);
-        setStart(prevStart => prevStart + count);
+        setCursor(page.next_cursor);
 
-        if (newQuestions.length < count) {
+        if (!page.next_cursor) {
           setHasMore(false);
         }
       } else {
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+	"strings"
+)
+
+// smallest prefixes of the IP bans, a broader range would ban whole providers
+const (
+	minIpv4BanPrefix = 16
+	minIpv6BanPrefix = 32
+)
+
+var errNetworkBanned = errors.New("your network is banned")
+var errEmailDomainBanned = errors.New("email domain is banned")
+
+// addressBanRecord is the address ban recorded in the audit log
+type addressBanRecord struct {
+	BanType  string `json:"ban_type"`
+	Value    string `json:"value"`
+	Duration int    `json:"duration,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// normalizeAddressBanValue returns the value stored for the ban: the masked CIDR range of an IP address or range,
+// or the lower case domain of an email domain
+func normalizeAddressBanValue(banType string, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch banType {
+	case models.AddressBanIp:
+		var prefix netip.Prefix
+		if strings.Contains(value, "/") {
+			var err error
+			prefix, err = netip.ParsePrefix(value)
+			if err != nil {
+				return "", errors.New("value must be an IP address or a CIDR range")
+			}
+		} else {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return "", errors.New("value must be an IP address or a CIDR range")
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		// IPv4 addresses mapped in IPv6 are banned as IPv4 addresses, which is how requests come
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+		}
+		prefix = prefix.Masked()
+		if (prefix.Addr().Is4() && prefix.Bits() < minIpv4BanPrefix) || (prefix.Addr().Is6() && prefix.Bits() < minIpv6BanPrefix) {
+			return "", errors.New("CIDR range is too broad")
+		}
+		return prefix.String(), nil
+	case models.AddressBanEmailDomain:
+		domain := strings.ToLower(strings.TrimPrefix(value, "@"))
+		if len(domain) > 255 || !strings.Contains(domain, ".") || strings.ContainsAny(domain, "@ /") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+			return "", errors.New("value must be an email domain")
+		}
+		return domain, nil
+	}
+	return "", errors.New("ban_type must be ip or email_domain")
+}
+
+// isIpAddressBanned tells if the IP address is in the range of one of the bans, invalid addresses are never banned
+func isIpAddressBanned(ipAddress string, bans []models.AddressBan) bool {
+	addr, err := netip.ParseAddr(ipAddress)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, ban := range bans {
+		prefix, err := netip.ParsePrefix(ban.Value)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmailDomainBanned tells if the email belongs to one of the banned domains or to one of their subdomains
+func isEmailDomainBanned(email string, bans []models.AddressBan) bool {
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return false
+	}
+	domain := strings.ToLower(address.Address[strings.LastIndex(address.Address, "@")+1:])
+	for _, ban := range bans {
+		if domain == ban.Value || strings.HasSuffix(domain, "."+ban.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAddressNotBanned checks that the IP address and the domain of the email are not banned, either can be left empty
+func CheckAddressNotBanned(ipAddress string, email string) (int, error) {
+	if ipAddress != "" {
+		bans, err := database.GetStore().GetAddressBans(models.AddressBanIp, nil, 0)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if isIpAddressBanned(ipAddress, bans) {
+			return http.StatusForbidden, errNetworkBanned
+		}
+	}
+	if email != "" {
+		bans, err := database.GetStore().GetAddressBans(models.AddressBanEmailDomain, nil, 0)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if isEmailDomainBanned(email, bans) {
+			return http.StatusForbidden, errEmailDomainBanned
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// AddAddressBan bans an IP address, a CIDR range or an email domain
+func AddAddressBan(audit models.AuditContext, infos models.AddAddressBanInfos) (int64, int, error) {
+	value, err := normalizeAddressBanValue(infos.BanType, infos.Value)
+	if err != nil {
+		return 0, http.StatusBadRequest, err
+	}
+	if infos.Duration < 0 {
+		return 0, http.StatusBadRequest, errors.New("duration cannot be negative")
+	}
+	if len(infos.Reason) > 1000 {
+		return 0, http.StatusBadRequest, errors.New("reason is too long")
+	}
+
+	var banId int64
+	code, err := inTransaction(func(store database.Store) error {
+		banId, err = store.AddAddressBan(infos.BanType, value, audit.ModeratorId, infos.Duration, infos.Reason)
+		if err != nil {
+			return err
+		}
+		return addAuditEntry(store, audit, models.AuditAddressBanAdd, models.AuditTargetAddressBan, int(banId), nil,
+			addressBanRecord{BanType: infos.BanType, Value: value, Duration: infos.Duration, Reason: infos.Reason})
+	})
+	if err != nil {
+		return 0, code, err
+	}
+	return banId, http.StatusOK, nil
+}
+
+// GetAddressBans returns a page of the active address bans, most recent first.
+// Only the bans of the type are returned when banType is not empty
+func GetAddressBans(banType string, cursor string, count int) (models.AddressBanPage, int, error) {
+	if banType != "" && banType != models.AddressBanIp && banType != models.AddressBanEmailDomain {
+		return models.AddressBanPage{}, http.StatusBadRequest, errors.New("ban_type must be ip or email_domain")
+	}
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.AddressBanPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	bans, err := database.GetStore().GetAddressBans(banType, after, count+1)
+	if err != nil {
+		return models.AddressBanPage{}, http.StatusInternalServerError, err
+	}
+	page := models.AddressBanPage{Bans: bans}
+	if len(bans) > count {
+		page.Bans = bans[:count]
+		last := page.Bans[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
+
+// RemoveAddressBan lifts an address ban, the audit log keeps what it banned
+func RemoveAddressBan(audit models.AuditContext, banId int) (int, error) {
+	ban, err := database.GetStore().GetAddressBan(banId)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, errors.New("address ban not found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return inTransaction(func(store database.Store) error {
+		err := store.RemoveAddressBan(banId)
+		if err != nil {
+			return err
+		}
+		return addAuditEntry(store, audit, models.AuditAddressBanRemove, models.AuditTargetAddressBan, banId,
+			addressBanRecord{BanType: ban.BanType, Value: ban.Value, Reason: ban.Reason}, nil)
+	})
+}
+
+// GetLinkedAccounts returns the IP addresses the user asked or answered from and the other accounts using them,
+// for the moderators to see who else to ban
+func GetLinkedAccounts(audit models.AuditContext, userId int) (models.LinkedAccounts, int, error) {
+	exists, err := database.GetStore().CheckUserIdExists(userId)
+	if err != nil {
+		return models.LinkedAccounts{}, http.StatusInternalServerError, err
+	}
+	if !exists {
+		return models.LinkedAccounts{}, http.StatusNotFound, errors.New("user not found")
+	}
+
+	ipAddresses, err := database.GetStore().GetUserIpAddresses(userId)
+	if err != nil {
+		return models.LinkedAccounts{}, http.StatusInternalServerError, err
+	}
+	accounts, err := database.GetStore().GetAccountsByIpAddresses(ipAddresses, userId)
+	if err != nil {
+		return models.LinkedAccounts{}, http.StatusInternalServerError, err
+	}
+	err = addAuditEntry(database.GetStore(), audit, models.AuditLinkedAccountsView, models.AuditTargetUser, userId, nil, nil)
+	if err != nil {
+		return models.LinkedAccounts{}, http.StatusInternalServerError, err
+	}
+	return models.LinkedAccounts{IpAddresses: ipAddresses, Accounts: accounts}, http.StatusOK, nil
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var addressBanColumns = []string{"id", "ban_type", "value", "author_id", "reason", "created_at", "expires_at"}
+
+// expectAddressBans expects the active address bans of the type to be loaded, with the given values
+func expectAddressBans(mock sqlmock.Sqlmock, banType string, values ...string) {
+	rows := sqlmock.NewRows(addressBanColumns)
+	for i, value := range values {
+		rows.AddRow(i+1, banType, value, 1, "", time.Now(), nil)
+	}
+	mock.ExpectQuery("SELECT (.+) FROM address_ban WHERE (.+) AND address_ban.ban_type = ").WithArgs(banType).WillReturnRows(rows)
+}
+
+func TestNormalizeAddressBanValue(t *testing.T) {
+	valid := map[[2]string]string{
+		{"ip", "10.0.0.1"}:            "10.0.0.1/32",
+		{"ip", " 10.0.12.1/16 "}:      "10.0.0.0/16",
+		{"ip", "::ffff:10.0.0.1"}:     "10.0.0.1/32",
+		{"ip", "2001:db8::1/48"}:      "2001:db8::/48",
+		{"email_domain", "@Spam.com"}: "spam.com",
+		{"email_domain", "mail.io"}:   "mail.io",
+	}
+	for input, expected := range valid {
+		value, err := normalizeAddressBanValue(input[0], input[1])
+		if err != nil || value != expected {
+			t.Errorf("Expected %s %s to give %s, got %s, %v", input[0], input[1], expected, value, err)
+		}
+	}
+	invalid := [][2]string{{"ip", "10.0.0"}, {"ip", "10.0.0.0/8"}, {"ip", "2001::/16"}, {"email_domain", "spam"}, {"email_domain", "a@spam.com"}, {"user", "toto"}}
+	for _, input := range invalid {
+		_, err := normalizeAddressBanValue(input[0], input[1])
+		if err == nil {
+			t.Errorf("Expected %s %s to be refused", input[0], input[1])
+		}
+	}
+}
+
+func TestCheckAddressNotBanned(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// an address in a banned range
+	expectAddressBans(mock, models.AddressBanIp, "10.1.0.0/16")
+	code, err := CheckAddressNotBanned("10.1.2.3", "toto@spam.com")
+	if code != http.StatusForbidden || err != errNetworkBanned {
+		t.Errorf("Expected the network to be banned, got %d, %v", code, err)
+	}
+
+	// a subdomain of a banned domain
+	expectAddressBans(mock, models.AddressBanIp, "10.1.0.0/16")
+	expectAddressBans(mock, models.AddressBanEmailDomain, "spam.com")
+	code, err = CheckAddressNotBanned("10.2.0.1", "toto@mail.Spam.com")
+	if code != http.StatusForbidden || err != errEmailDomainBanned {
+		t.Errorf("Expected the email domain to be banned, got %d, %v", code, err)
+	}
+
+	// a domain ending like a banned one
+	expectAddressBans(mock, models.AddressBanEmailDomain, "spam.com")
+	code, err = CheckAddressNotBanned("", "toto@notspam.com")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected the email domain not to be banned, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("FROM address_ban").WillReturnError(errors.New("error"))
+	code, _ = CheckAddressNotBanned("10.2.0.1", "")
+	if code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestAddAddressBan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	audit := models.AuditContext{ModeratorId: 3}
+
+	_, code, err := AddAddressBan(audit, models.AddAddressBanInfos{BanType: "ip", Value: "10.0.0.1", Duration: -1})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected 400, got %d, %v", code, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO address_ban").WithArgs("ip", "10.0.0.0/24", 3, "spam", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").
+		WithArgs(3, "address_ban.add", "address_ban", 4, `{"after":{"ban_type":"ip","value":"10.0.0.0/24","duration":24,"reason":"spam"}}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	banId, code, err := AddAddressBan(audit, models.AddAddressBanInfos{BanType: "ip", Value: "10.0.0.7/24", Duration: 24, Reason: "spam"})
+	if code != http.StatusOK || err != nil || banId != 4 {
+		t.Errorf("Expected ban 4, got %d, %d, %v", banId, code, err)
+	}
+
+	// the ban is rolled back when it can't be logged
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO address_ban").WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	_, code, _ = AddAddressBan(audit, models.AddAddressBanInfos{BanType: "email_domain", Value: "spam.com"})
+	if code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRemoveAddressBan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	audit := models.AuditContext{ModeratorId: 3}
+
+	mock.ExpectQuery("FROM address_ban WHERE address_ban.id = ").WithArgs(4).WillReturnError(sql.ErrNoRows)
+	code, err := RemoveAddressBan(audit, 4)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected 404, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("FROM address_ban WHERE address_ban.id = ").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(addressBanColumns).AddRow(4, "email_domain", "spam.com", 1, "spam", time.Now(), nil))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM address_ban").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").
+		WithArgs(3, "address_ban.remove", "address_ban", 4, `{"before":{"ban_type":"email_domain","value":"spam.com","reason":"spam"}}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	code, err = RemoveAddressBan(audit, 4)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected 200, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetLinkedAccounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	audit := models.AuditContext{ModeratorId: 3}
+
+	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(0))
+	_, code, err := GetLinkedAccounts(audit, 2)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected 404, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
+	mock.ExpectQuery("SELECT author_ip_address FROM question WHERE author_id = (.+) UNION SELECT answerer_ip_address FROM answer").WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"ip_address"}).AddRow("10.0.0.1").AddRow("10.0.0.2"))
+	mock.ExpectQuery("SELECT user.id, user.username, shared.ip_address FROM").WithArgs("10.0.0.1", "10.0.0.2", "10.0.0.1", "10.0.0.2", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "ip_address"}).AddRow(4, "toto", "10.0.0.1").AddRow(4, "toto", "10.0.0.2").AddRow(5, "titi", "10.0.0.2"))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(3, "linked_accounts.view", "user", 2, nil, "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	linked, code, err := GetLinkedAccounts(audit, 2)
+	if code != http.StatusOK || err != nil {
+		t.Fatalf("Expected 200, got %d, %v", code, err)
+	}
+	if len(linked.IpAddresses) != 2 || len(linked.Accounts) != 2 || len(linked.Accounts[0].IpAddresses) != 2 || linked.Accounts[1].Username != "titi" {
+		t.Errorf("Unexpected linked accounts %+v", linked)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/models"
+	"project_truthful/moderation/filter"
+	"project_truthful/notifications"
 )
 
 func checkAnswerInfos(answer string) error {
@@ -17,21 +20,27 @@ func checkAnswerInfos(answer string) error {
 	return nil
 }
 
+// AnswerQuestion answers the question received by the user.
+// An answer held by the content filters waits for a review before being published, with http.StatusAccepted and no id
 func AnswerQuestion(userId int, questionId int, answerText string, authorIpAddress string) (int64, int, error) {
 	err := checkAnswerInfos(answerText)
 	if err != nil {
 		return 0, http.StatusBadRequest, err
 	}
 
-	userExists, err := database.CheckUserIdExists(userId, database.DB)
+	userExists, err := database.GetStore().CheckUserIdExists(userId)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
 	if !userExists {
 		return 0, http.StatusNotFound, errors.New("user not found")
 	}
+	code, err := CheckEmailVerified(userId, VerifiedEmailToAnswer)
+	if err != nil {
+		return 0, code, err
+	}
 
-	questionReceiverId, err := database.GetQuestionReceiverId(questionId, database.DB)
+	questionReceiverId, err := database.GetStore().GetQuestionReceiverId(questionId)
 	if err != nil && err == sql.ErrNoRows {
 		// if the question doesn't exist, we return a 404
 		return 0, http.StatusNotFound, errors.New("question not found")
@@ -42,26 +51,53 @@ func AnswerQuestion(userId int, questionId int, answerText string, authorIpAddre
 	if questionReceiverId != userId {
 		return 0, http.StatusForbidden, errors.New("user is not the receiver of the question")
 	}
+	removed, err := database.GetStore().CheckQuestionRemoved(questionId)
+	if err == sql.ErrNoRows {
+		return 0, http.StatusNotFound, errors.New("question not found")
+	} else if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	if removed {
+		return 0, http.StatusForbidden, errors.New("question has been removed by a moderator")
+	}
 
 	// we check if the user has already answered the question
-	alreadyAnswered, err := database.HasQuestionBeenAnswered(questionId, database.DB)
+	alreadyAnswered, err := database.GetStore().HasQuestionBeenAnswered(questionId)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
 	if alreadyAnswered {
 		return 0, http.StatusForbidden, errors.New("user has already answered the question")
 	}
+	awaitingReview, err := database.GetStore().CheckPendingHeldAnswer(questionId)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	if awaitingReview {
+		return 0, http.StatusForbidden, errors.New("answer of the user is awaiting review")
+	}
+
+	result, code, err := filterContent(filter.Answer, answerText, userId, authorIpAddress, userId)
+	if err != nil {
+		return 0, code, err
+	}
+	if result.Decision == filter.Hold {
+		code, err := holdContent(models.HeldContent{ContentType: filter.Answer, Text: answerText, AuthorId: userId, AuthorIpAddress: authorIpAddress,
+			ReceiverId: userId, QuestionId: questionId}, result)
+		return 0, code, err
+	}
 
-	id, err := database.AddAnswer(userId, questionId, answerText, authorIpAddress, database.DB)
+	id, err := database.GetStore().AddAnswer(userId, questionId, answerText, authorIpAddress)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
+	notifications.NotifyQuestionAnswered(questionId, userId, int(id))
 	return id, http.StatusCreated, nil
 }
 
 func MarkAnswerAsDeleted(userId int, answerId int) (int, error) {
 	// we check if the user is the author of the answer
-	authorId, err := database.GetAnswerAuthorId(answerId, database.DB)
+	authorId, err := database.GetStore().GetAnswerAuthorId(answerId)
 	if err != nil && err == sql.ErrNoRows {
 		// if the answer doesn't exist, we return a 404
 		return http.StatusNotFound, errors.New("answer not found")
@@ -73,7 +109,7 @@ func MarkAnswerAsDeleted(userId int, answerId int) (int, error) {
 		return http.StatusForbidden, errors.New("user is not the author of the answer")
 	}
 
-	err = database.MarkAnswerAsDeleted(answerId, database.DB)
+	err = database.GetStore().MarkAnswerAsDeleted(answerId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -3,9 +3,11 @@ package client
 import (
 	"database/sql"
 	"errors"
+	"net/http"
 	"project_truthful/client/database"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -62,8 +64,20 @@ func TestAnswerQuestionError(t *testing.T) {
 		t.Errorf("Database error: expected error, got nil")
 	}
 
+	// accounts without a verified email can't answer
+	mock.ExpectQuery("SELECT COUNT").WithArgs(12).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(12).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", false))
+	_, code, err := AnswerQuestion(12, 1, "toto", "ip_address")
+	if mock.ExpectationsWereMet() != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+	if code != http.StatusForbidden || err != errEmailNotVerified {
+		t.Errorf("Unverified email: expected 403, got %d, %v", code, err)
+	}
+
 	// question does not exists
 	mock.ExpectQuery("SELECT COUNT").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id").WithArgs(1).WillReturnError(sql.ErrNoRows)
 	_, _, err = AnswerQuestion(3, 1, "toto", "ip_address")
 	if mock.ExpectationsWereMet() != nil {
@@ -75,6 +89,7 @@ func TestAnswerQuestionError(t *testing.T) {
 
 	// check question database error
 	mock.ExpectQuery("SELECT COUNT").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id").WithArgs(2).WillReturnError(errors.New("test error"))
 	_, _, err = AnswerQuestion(4, 2, "toto", "ip_address")
 	if mock.ExpectationsWereMet() != nil {
@@ -86,6 +101,7 @@ func TestAnswerQuestionError(t *testing.T) {
 
 	// question id and receiver id do not match
 	mock.ExpectQuery("SELECT COUNT").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"receiver_id"}).AddRow(2))
 	_, _, err = AnswerQuestion(5, 3, "toto", "ip_address")
 	if mock.ExpectationsWereMet() != nil {
@@ -97,7 +113,9 @@ func TestAnswerQuestionError(t *testing.T) {
 
 	// user already answered the question
 	mock.ExpectQuery("SELECT COUNT").WithArgs(6).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(6).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"receiver_id"}).AddRow(6))
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	_, _, err = AnswerQuestion(6, 7, "toto", "ip_address")
 	if mock.ExpectationsWereMet() != nil {
@@ -109,7 +127,9 @@ func TestAnswerQuestionError(t *testing.T) {
 
 	// check if question already answered database error
 	mock.ExpectQuery("SELECT COUNT").WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"receiver_id"}).AddRow(8))
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(9).WillReturnError(errors.New("test error"))
 	_, _, err = AnswerQuestion(8, 9, "toto", "ip_address")
 	if mock.ExpectationsWereMet() != nil {
@@ -120,9 +140,14 @@ func TestAnswerQuestionError(t *testing.T) {
 	}
 
 	mock.ExpectQuery("SELECT COUNT").WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id").WithArgs(11).WillReturnRows(sqlmock.NewRows([]string{"receiver_id"}).AddRow(10))
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(11).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(11).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM held_content").WithArgs(11, "pending").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO answer").WithArgs(10, 11, "toto", "ip_address").WillReturnError(errors.New("test error"))
+	mock.ExpectRollback()
 	_, _, err = AnswerQuestion(10, 11, "toto", "ip_address")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -141,9 +166,22 @@ func TestAnswerQuestionSuccess(t *testing.T) {
 	}
 
 	mock.ExpectQuery("SELECT COUNT").WithArgs(6).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(6).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"receiver_id"}).AddRow(6))
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM held_content").WithArgs(7, "pending").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO answer").WithArgs(6, 7, "toto", "ip_address").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT IGNORE INTO timeline_entry").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	// the author of the question is notified
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(7, "question", 3, false, 6, time.Now(), false))
+	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("titi", "Titi"))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_mute").WithArgs(3, 6).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO notification").WithArgs(3, "question_answered", 6, 7, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(6).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto", "Toto"))
 	answerId, code, err := AnswerQuestion(6, 7, "toto", "ip_address")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/models"
+	"project_truthful/moderation/filter"
+	"project_truthful/notifications"
 )
 
 func checkQuestionInfos(question string) error {
@@ -16,24 +19,67 @@ func checkQuestionInfos(question string) error {
 	return nil
 }
 
-func AskQuestion(question string, authorId int, authorIpAddress string, isAuthorAnonymous bool, receiverId int) (int64, int, error) {
-	receiverExists, err := database.CheckUserIdExists(receiverId, database.DB)
+// AskQuestion asks the receiver a question, the captcha token is only needed when the receiver asks logged out visitors for one.
+// When the settings of the receiver refuse the question, the error is an InboxError telling which setting did.
+// A question held by the content filters waits for a review instead of going to the inbox, with http.StatusAccepted and no id
+func AskQuestion(question string, authorId int, authorIpAddress string, isAuthorAnonymous bool, receiverId int, captchaToken string) (int64, int, error) {
+	receiverExists, err := database.GetStore().CheckUserIdExists(receiverId)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
 	if !receiverExists {
 		return 0, http.StatusNotFound, errors.New("receiver not found")
 	}
+	// logged in authors are matched even when asking anonymously
+	if authorId != 0 && authorId != receiverId {
+		code, err := checkNotBlocked(authorId, receiverId)
+		if err != nil {
+			return 0, code, err
+		}
+	}
 
 	err = checkQuestionInfos(question)
 	if err != nil {
 		return 0, http.StatusBadRequest, err
 	}
+	// anonymous questions don't show the account, which doesn't need to be verified
+	if authorId != 0 && !isAuthorAnonymous {
+		code, err := CheckEmailVerified(authorId, VerifiedEmailToAsk)
+		if err != nil {
+			return 0, code, err
+		}
+	}
+
+	// the account of logged in authors is checked for bans, anonymous ones could be banned users
+	if authorId == 0 || isAuthorAnonymous {
+		code, err := CheckAddressNotBanned(authorIpAddress, "")
+		if err != nil {
+			return 0, code, err
+		}
+	}
+	code, err := checkAskerNotBlocked(receiverId, authorIpAddress)
+	if err != nil {
+		return 0, code, err
+	}
+	code, err = checkInboxSettings(receiverId, authorId, isAuthorAnonymous, captchaToken, authorIpAddress)
+	if err != nil {
+		return 0, code, err
+	}
+	result, code, err := filterContent(filter.Question, question, authorId, authorIpAddress, receiverId)
+	if err != nil {
+		return 0, code, err
+	}
+	if result.Decision == filter.Hold {
+		code, err := holdContent(models.HeldContent{ContentType: filter.Question, Text: question, AuthorId: authorId, AuthorIpAddress: authorIpAddress,
+			IsAuthorAnonymous: isAuthorAnonymous || authorId == 0, ReceiverId: receiverId}, result)
+		return 0, code, err
+	}
 
-	id, err := database.AddQuestion(question, authorId, authorIpAddress, isAuthorAnonymous, receiverId, database.DB)
+	id, err := database.GetStore().AddQuestion(question, authorId, authorIpAddress, isAuthorAnonymous, receiverId)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
+	notifications.NotifyQuestionReceived(receiverId, authorId, isAuthorAnonymous, int(id))
 
 	return id, http.StatusCreated, nil
 }
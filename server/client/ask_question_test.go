@@ -4,8 +4,11 @@ import (
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/client/iphash"
+	"project_truthful/models"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -36,7 +39,7 @@ func TestAskQuestion(t *testing.T) {
 	}
 	// test for checkUserIdExists error
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnError(errors.New("error"))
-	_, code, err := AskQuestion("question", 1, "ip_address", true, 1)
+	_, code, err := AskQuestion("question", 1, "ip_address", true, 1, "")
 	if code != http.StatusInternalServerError {
 		t.Errorf("Expected http.StatusInternalServerError, got %d", code)
 	}
@@ -45,7 +48,7 @@ func TestAskQuestion(t *testing.T) {
 	}
 	// test for checkUserIdExists not found
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	_, code, err = AskQuestion("question", 1, "ip_address", true, 1)
+	_, code, err = AskQuestion("question", 1, "ip_address", true, 1, "")
 	if code != http.StatusNotFound {
 		t.Errorf("Expected http.StatusNotFound, got %d", code)
 	}
@@ -54,17 +57,38 @@ func TestAskQuestion(t *testing.T) {
 	}
 	// test for checkQuestionInfos error
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	_, code, err = AskQuestion("", 1, "ip_address", true, 1)
+	_, code, err = AskQuestion("", 1, "ip_address", true, 1, "")
 	if code != http.StatusBadRequest {
 		t.Errorf("Expected http.StatusBadRequest, got %d", code)
 	}
 	if err == nil {
 		t.Errorf("Expected error, got nil")
 	}
+	// test for signed question from an account without a verified email
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", false))
+	_, code, err = AskQuestion("question", 1, "ip_address", false, 1, "")
+	if code != http.StatusForbidden || err != errEmailNotVerified {
+		t.Errorf("Expected http.StatusForbidden, got %d, %v", code, err)
+	}
+	// test for asker blocked by the receiver
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expectAddressBans(mock, models.AddressBanIp)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM asker_block").WithArgs(1, iphash.Hash("ip_address")).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	_, code, err = AskQuestion("question", 1, "ip_address", true, 1, "")
+	if code != http.StatusForbidden {
+		t.Errorf("Expected http.StatusForbidden, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
 	// test for AddQuestion error
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expectAddressBans(mock, models.AddressBanIp)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM asker_block").WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"word"}))
 	mock.ExpectExec("INSERT INTO question").WithArgs("question", 1, "ip_address", true, 1).WillReturnError(errors.New("error"))
-	_, code, err = AskQuestion("question", 1, "ip_address", true, 1)
+	_, code, err = AskQuestion("question", 1, "ip_address", true, 1, "")
 	if code != http.StatusInternalServerError {
 		t.Errorf("Expected http.StatusInternalServerError, got %d", code)
 	}
@@ -73,8 +97,19 @@ func TestAskQuestion(t *testing.T) {
 	}
 	// test for success
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expectAddressBans(mock, models.AddressBanIp)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM asker_block").WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"word"}))
 	mock.ExpectExec("INSERT INTO question").WithArgs("question", 1, "ip_address", true, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-	id, code, err := AskQuestion("question", 1, "ip_address", true, 1)
+	// the author of the anonymous question is left out of the notification
+	mock.ExpectExec("INSERT INTO notification").WithArgs(1, "question_received", nil, 1, nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	// the question is pushed to the receiver
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(1, "question", 1, true, 1, time.Now(), false))
+	id, code, err := AskQuestion("question", 1, "ip_address", true, 1, "")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
 	if code != http.StatusCreated {
 		t.Errorf("Expected http.StatusCreated, got %d", code)
 	}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/iphash"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+)
+
+// number of characters of the question shown in the list of the blocked askers
+const questionSnippetLength = 50
+
+// BlockAsker stops the asker of the anonymous question from asking the receiver again,
+// for duration hours or for good when 0. Only a salted hash of the IP address of the asker is kept
+func BlockAsker(receiverId int, questionId int, duration int) (int64, int, error) {
+	if duration < 0 {
+		return 0, http.StatusBadRequest, errors.New("duration must be positive")
+	}
+	question, err := database.GetStore().GetQuestionById(questionId)
+	if err == sql.ErrNoRows {
+		return 0, http.StatusNotFound, errors.New("question not found")
+	} else if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	if question.ReceiverId != receiverId {
+		return 0, http.StatusForbidden, errors.New("user is not the receiver of the question")
+	}
+	if !question.IsAuthorAnonymous {
+		return 0, http.StatusBadRequest, errors.New("question is not anonymous, block its author instead")
+	}
+
+	ipAddress, err := database.GetStore().GetQuestionAuthorIpAddress(questionId)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	id, err := database.GetStore().AddAskerBlock(receiverId, iphash.Hash(ipAddress), questionId, duration)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	return id, http.StatusCreated, nil
+}
+
+func UnblockAsker(receiverId int, blockId int) (int, error) {
+	removed, err := database.GetStore().RemoveAskerBlock(receiverId, blockId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !removed {
+		return http.StatusNotFound, errors.New("block not found")
+	}
+	return http.StatusOK, nil
+}
+
+// GetAskerBlocks returns a page of the active asker blocks of the receiver, most recent first
+func GetAskerBlocks(receiverId int, cursor string, count int) (models.AskerBlockPage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.AskerBlockPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more block is fetched to know if there is a next page
+	blocks, err := database.GetStore().GetAskerBlocks(receiverId, after, count+1)
+	if err != nil {
+		return models.AskerBlockPage{}, http.StatusInternalServerError, err
+	}
+	page := models.AskerBlockPage{Blocks: blocks}
+	if len(blocks) > count {
+		page.Blocks = blocks[:count]
+		last := page.Blocks[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	for i := range page.Blocks {
+		page.Blocks[i].QuestionSnippet = snippet(page.Blocks[i].QuestionSnippet)
+	}
+	return page, http.StatusOK, nil
+}
+
+// snippet shortens the text to its first characters
+func snippet(text string) string {
+	runes := []rune(text)
+	if len(runes) <= questionSnippetLength {
+		return text
+	}
+	return string(runes[:questionSnippetLength]) + "…"
+}
+
+// checkAskerNotBlocked returns a forbidden error if the receiver blocked the askers with this IP address
+func checkAskerNotBlocked(receiverId int, ipAddress string) (int, error) {
+	blocked, err := database.GetStore().CheckAskerBlockExists(receiverId, iphash.Hash(ipAddress))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if blocked {
+		return http.StatusForbidden, errors.New("user doesn't accept questions from this asker")
+	}
+	return http.StatusOK, nil
+}
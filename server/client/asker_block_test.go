@@ -0,0 +1,117 @@
+package client
+
+import (
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/iphash"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectQuestion(mock sqlmock.Sqlmock, questionId int, receiverId int, isAuthorAnonymous bool) {
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(questionId).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+			AddRow(questionId, "question", nil, isAuthorAnonymous, receiverId, time.Now(), false))
+}
+
+func TestBlockAsker(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	_, code, err := BlockAsker(1, 1, -1)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected negative durations to be refused, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT id, text, author_id").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, code, err = BlockAsker(1, 1, 0)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	expectQuestion(mock, 1, 2, true)
+	_, code, err = BlockAsker(1, 1, 0)
+	if code != http.StatusForbidden || err == nil {
+		t.Errorf("Expected only the receiver to block the asker, got %d", code)
+	}
+
+	expectQuestion(mock, 1, 1, false)
+	_, code, err = BlockAsker(1, 1, 0)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected signed questions to be refused, got %d", code)
+	}
+
+	expectQuestion(mock, 1, 1, true)
+	mock.ExpectQuery("SELECT author_ip_address FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"author_ip_address"}).AddRow("127.0.0.1"))
+	mock.ExpectExec("INSERT INTO asker_block").WithArgs(1, iphash.Hash("127.0.0.1"), 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	id, code, err := BlockAsker(1, 1, 24)
+	if code != http.StatusCreated || err != nil || id != 4 {
+		t.Errorf("Expected the asker to be blocked, got %d, %d and %v", id, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestUnblockAsker(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	mock.ExpectExec("DELETE FROM asker_block").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	code, err := UnblockAsker(1, 2)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+	mock.ExpectExec("DELETE FROM asker_block").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	code, err = UnblockAsker(1, 2)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetAskerBlocks(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	_, code, err := GetAskerBlocks(1, "not a cursor", 10)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected invalid cursors to be refused, got %d", code)
+	}
+
+	long := strings.Repeat("é", 60)
+	mock.ExpectQuery("SELECT asker_block.id").WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "expires_at"}).
+			AddRow(3, 5, long, time.Now(), nil).
+			AddRow(2, 4, "short", time.Now(), nil))
+	page, code, err := GetAskerBlocks(1, "", 1)
+	if code != http.StatusOK || err != nil {
+		t.Fatalf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if len(page.Blocks) != 1 || page.NextCursor == "" {
+		t.Errorf("Expected one block and a next page, got %+v", page)
+	}
+	if page.Blocks[0].QuestionSnippet != strings.Repeat("é", questionSnippetLength)+"…" {
+		t.Errorf("Expected the question to be shortened, got %s", page.Blocks[0].QuestionSnippet)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
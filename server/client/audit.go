@@ -0,0 +1,127 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+)
+
+// maximum number of entries of an export, a narrower filter is needed above it
+const maxAuditExportCount = 10000
+
+var auditActions = map[string]bool{
+	models.AuditRoleGrant:          true,
+	models.AuditRoleRevoke:         true,
+	models.AuditUserBan:            true,
+	models.AuditUserPardon:         true,
+	models.AuditBanEdit:            true,
+	models.AuditAddressBanAdd:      true,
+	models.AuditAddressBanRemove:   true,
+	models.AuditLinkedAccountsView: true,
+	models.AuditQuestionsView:      true,
+	models.AuditHeldContentReview:  true,
+	models.AuditReportsResolve:     true,
+	models.AuditQuestionRemove:     true,
+	models.AuditQuestionRestore:    true,
+	models.AuditAnswerRemove:       true,
+	models.AuditAnswerRestore:      true,
+	models.AuditFilterDecision:     true,
+	models.AuditRoleEdit:           true,
+	models.AuditLoginHistoryView:   true,
+}
+
+var auditTargets = map[string]bool{
+	models.AuditTargetUser:       true,
+	models.AuditTargetQuestion:   true,
+	models.AuditTargetAnswer:     true,
+	models.AuditTargetBan:        true,
+	models.AuditTargetAddressBan: true,
+	models.AuditTargetRole:       true,
+}
+
+// auditPayload holds the target before and after the action, either is left out when it doesn't apply
+type auditPayload struct {
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+}
+
+// addAuditEntry records the action in the audit log of the store, a targetId of 0 records an action without target
+func addAuditEntry(store database.Store, audit models.AuditContext, action string, targetType string, targetId int, before any, after any) error {
+	entry := models.AuditEntry{ModeratorId: audit.ModeratorId, Action: action, TargetType: targetType, TargetId: targetId,
+		IpAddress: audit.IpAddress, UserAgent: audit.UserAgent}
+	if before != nil || after != nil {
+		payload, err := json.Marshal(auditPayload{Before: before, After: after})
+		if err != nil {
+			return err
+		}
+		entry.Payload = payload
+	}
+	return store.AddAuditEntry(entry)
+}
+
+// inTransaction runs the writes of an audited action and its audit entries in a transaction,
+// nothing is written when one of them fails
+func inTransaction(fn func(store database.Store) error) (int, error) {
+	err := database.GetStore().InTransaction(fn)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func checkAuditFilter(filter models.AuditFilter) error {
+	if filter.Action != "" && !auditActions[filter.Action] {
+		return errors.New("unknown action " + filter.Action)
+	}
+	if filter.TargetType != "" && !auditTargets[filter.TargetType] {
+		return errors.New("target_type must be user, question, answer, ban, address_ban or role")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
+
+// GetAuditLog returns a page of the entries of the audit log matching the filter, most recent first
+func GetAuditLog(filter models.AuditFilter, cursor string, count int) (models.AuditPage, int, error) {
+	err := checkAuditFilter(filter)
+	if err != nil {
+		return models.AuditPage{}, http.StatusBadRequest, err
+	}
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.AuditPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	entries, err := database.GetStore().GetAuditEntries(filter, after, count+1)
+	if err != nil {
+		return models.AuditPage{}, http.StatusInternalServerError, err
+	}
+	page := models.AuditPage{Entries: entries}
+	if len(entries) > count {
+		page.Entries = entries[:count]
+		last := page.Entries[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
+
+// ExportAuditLog returns every entry of the audit log matching the filter, most recent first
+func ExportAuditLog(filter models.AuditFilter) ([]models.AuditEntry, int, error) {
+	err := checkAuditFilter(filter)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	entries, err := database.GetStore().GetAuditEntries(filter, nil, maxAuditExportCount+1)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if len(entries) > maxAuditExportCount {
+		return nil, http.StatusBadRequest, errors.New("too many entries to export, narrow the date range")
+	}
+	return entries, http.StatusOK, nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var auditColumns = []string{"id", "user_id", "action", "target_type", "target_id", "payload", "ip_address", "user_agent", "created_at"}
+
+func TestGetAuditLog(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	now := time.Now()
+	for _, filter := range []models.AuditFilter{{Action: "banUser"}, {TargetType: "comment"}, {From: now, To: now.Add(-time.Hour)}} {
+		_, code, err := GetAuditLog(filter, "", 10)
+		if code != http.StatusBadRequest || err == nil {
+			t.Errorf("Expected filter %+v to be refused, got %d", filter, code)
+		}
+	}
+
+	// one more entry is fetched to know if there is a next page
+	mock.ExpectQuery("SELECT (.+) FROM moderation_logging WHERE moderation_logging.action = \\?").WithArgs("user.ban", 2).WillReturnRows(sqlmock.NewRows(auditColumns).
+		AddRow(2, 1, "user.ban", "user", 3, nil, "", "", now).
+		AddRow(1, 1, "user.ban", "user", 4, nil, "", "", now))
+	page, code, err := GetAuditLog(models.AuditFilter{Action: "user.ban"}, "", 1)
+	if code != http.StatusOK || err != nil || len(page.Entries) != 1 || page.NextCursor == "" {
+		t.Errorf("Expected a page of 1 entry with a next cursor, got %+v, %d and %v", page, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestExportAuditLog(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	_, code, err := ExportAuditLog(models.AuditFilter{TargetType: "comment"})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected unknown targets to be refused, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM moderation_logging WHERE moderation_logging.user_id = \\?").WithArgs(1, maxAuditExportCount+1).
+		WillReturnRows(sqlmock.NewRows(auditColumns).AddRow(1, 1, "user.pardon", "ban", 3, nil, "", "", time.Now()))
+	entries, code, err := ExportAuditLog(models.AuditFilter{ModeratorId: 1})
+	if code != http.StatusOK || err != nil || len(entries) != 1 {
+		t.Errorf("Expected 1 entry, got %+v, %d and %v", entries, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
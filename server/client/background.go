@@ -0,0 +1,21 @@
+package client
+
+import "sync"
+
+var backgroundJobs sync.WaitGroup
+
+// inBackground runs work which must not delay the response of a request, such as sending an email:
+// waiting on the mail server would make the response slower, and tell whether an email was sent.
+// Tests replace it to choose when the work runs
+var inBackground = func(job func()) {
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		job()
+	}()
+}
+
+// WaitBackgroundJobs waits for the work started in the background by the requests, the server calls it before exiting
+func WaitBackgroundJobs() {
+	backgroundJobs.Wait()
+}
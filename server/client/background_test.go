@@ -0,0 +1,34 @@
+package client
+
+import "testing"
+
+// holdBackground keeps the background work of the test until the returned function runs it
+func holdBackground(t *testing.T) func() {
+	var jobs []func()
+	previous := inBackground
+	inBackground = func(job func()) {
+		jobs = append(jobs, job)
+	}
+	t.Cleanup(func() { inBackground = previous })
+	return func() {
+		held := jobs
+		jobs = nil
+		for _, job := range held {
+			job()
+		}
+	}
+}
+
+func TestWaitBackgroundJobs(t *testing.T) {
+	done := make(chan struct{})
+	ran := false
+	inBackground(func() {
+		<-done
+		ran = true
+	})
+	close(done)
+	WaitBackgroundJobs()
+	if !ran {
+		t.Errorf("Expected the background job to be waited for")
+	}
+}
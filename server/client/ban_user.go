@@ -1,73 +1,125 @@
 package client
 
 import (
+	"database/sql"
 	"errors"
+	"math"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+	"project_truthful/permissions"
+	"time"
 )
 
-func BanUser(userId int, requesterId int, duration int, reason string) (int64, int, error) {
-	// Checks if the author is an admin
-	isModerator, err := database.CheckModeratorStatus(requesterId, database.DB)
+var errBanAlreadyPardoned = errors.New("ban is already pardoned")
+
+// UserBannedError is returned when a banned user tries to use the site, it carries the details of the ban
+type UserBannedError struct {
+	Ban models.Ban
+}
+
+func (e *UserBannedError) Error() string {
+	return "user is banned"
+}
+
+func CheckUserNotBanned(userId int) (int, error) {
+	ban, err := database.GetStore().GetActiveBan(userId)
+	if err == sql.ErrNoRows {
+		return http.StatusOK, nil
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusForbidden, &UserBannedError{Ban: ban}
+}
+
+// banRecord is the ban recorded in the audit log
+type banRecord struct {
+	BanId    int64  `json:"ban_id"`
+	Duration int    `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+// pardonState is the state of a ban recorded in the audit log before and after a pardon
+type pardonState struct {
+	Pardoned bool  `json:"pardoned"`
+	PardonId int64 `json:"pardon_id,omitempty"`
+}
+
+// banDuration is the length of a ban recorded in the audit log before and after it is edited
+type banDuration struct {
+	Duration  int        `json:"duration"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+var banStatuses = map[string]bool{
+	models.BanStatusActive:   true,
+	models.BanStatusExpired:  true,
+	models.BanStatusPardoned: true,
+}
+
+// BanUser bans the user, the requester must be allowed to ban users
+func BanUser(audit models.AuditContext, userId int, duration int, reason string) (int64, int, error) {
+	code, err := checkBan(userId, audit.ModeratorId)
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
+		return 0, code, err
 	}
-	isAdmin, err := database.CheckAdminStatus(requesterId, database.DB)
+
+	var banId int64
+	code, err = inTransaction(func(store database.Store) error {
+		banId, err = banUser(store, audit, userId, duration, reason)
+		return err
+	})
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
-	}
-	if !isModerator && !isAdmin {
-		return 0, http.StatusForbidden, errors.New("user is not a moderator or admin")
+		return 0, code, err
 	}
+	return banId, http.StatusOK, nil
+}
 
+// checkBan checks that the requester can ban the user
+func checkBan(userId int, requesterId int) (int, error) {
 	// checks if the user exists
-	exists, err := database.CheckUserIdExists(userId, database.DB)
+	exists, err := database.GetStore().CheckUserIdExists(userId)
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
+		return http.StatusInternalServerError, err
 	}
 	if !exists {
-		return 0, http.StatusNotFound, errors.New("user not found")
+		return http.StatusNotFound, errors.New("user not found")
 	}
 
 	// checks if user is self
 	if userId == requesterId {
-		return 0, http.StatusForbidden, errors.New("cannot ban self")
+		return http.StatusForbidden, errors.New("cannot ban self")
 	}
 
 	//checks if user is admin
-	isAdmin, err = database.CheckAdminStatus(userId, database.DB)
+	isAdmin, err := database.GetStore().CheckUserHasRoleName(userId, permissions.RoleAdmin)
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
+		return http.StatusInternalServerError, err
 	}
 	if isAdmin {
-		return 0, http.StatusForbidden, errors.New("cannot ban an admin")
+		return http.StatusForbidden, errors.New("cannot ban an admin")
 	}
-
-	// Bans the user
-	banId, err := database.BanUser(userId, requesterId, duration, reason, database.DB)
-	if err != nil {
-		return 0, http.StatusInternalServerError, err
-	}
-
-	return banId, http.StatusOK, nil
+	return http.StatusOK, nil
 }
 
-func PardonUser(banId int, requesterId int) (int64, int, error) {
-	// Checks if the author is an admin
-	isModerator, err := database.CheckModeratorStatus(requesterId, database.DB)
+// banUser writes the ban and its audit entry in the store
+func banUser(store database.Store, audit models.AuditContext, userId int, duration int, reason string) (int64, error) {
+	banId, err := store.BanUser(userId, audit.ModeratorId, duration, reason)
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
+		return 0, err
 	}
-	isAdmin, err := database.CheckAdminStatus(requesterId, database.DB)
+	err = addAuditEntry(store, audit, models.AuditUserBan, models.AuditTargetUser, userId, nil, banRecord{BanId: banId, Duration: duration, Reason: reason})
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
-	}
-	if !isModerator && !isAdmin {
-		return 0, http.StatusForbidden, errors.New("user is not a moderator or admin")
+		return 0, err
 	}
+	return banId, nil
+}
 
+// PardonUser lifts the ban, the requester must be allowed to pardon users
+func PardonUser(audit models.AuditContext, banId int) (int64, int, error) {
 	// Checks if the ban exists
-	exists, err := database.CheckBanExistsByBanId(banId, database.DB)
+	exists, err := database.GetStore().CheckBanExistsByBanId(banId)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
@@ -76,19 +128,111 @@ func PardonUser(banId int, requesterId int) (int64, int, error) {
 	}
 
 	// Checks if the ban is already pardoned
-	pardoned, err := database.CheckPardonExists(banId, database.DB)
+	pardoned, err := database.GetStore().CheckPardonExists(banId)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
 	if pardoned {
-		return 0, http.StatusForbidden, errors.New("ban is already pardoned")
+		return 0, http.StatusForbidden, errBanAlreadyPardoned
 	}
 
-	// Pardons the user
-	pardonId, err := database.PardonUser(banId, requesterId, database.DB)
-	if err != nil {
-		return 0, http.StatusInternalServerError, err
+	// Pardons the user, unless a concurrent request pardoned them since the check
+	var pardonId int64
+	code, err := inTransaction(func(store database.Store) error {
+		pardonId, err = store.PardonUser(banId, audit.ModeratorId)
+		if err != nil {
+			return err
+		}
+		if pardonId == 0 {
+			return errBanAlreadyPardoned
+		}
+		return addAuditEntry(store, audit, models.AuditUserPardon, models.AuditTargetBan, banId, pardonState{Pardoned: false}, pardonState{Pardoned: true, PardonId: pardonId})
+	})
+	if err == errBanAlreadyPardoned {
+		return 0, http.StatusBadRequest, err
+	} else if err != nil {
+		return 0, code, err
 	}
 
 	return pardonId, http.StatusOK, nil
 }
+
+// GetBans returns a page of the bans matching the filter with their pardon, most recent first
+func GetBans(filter models.BanFilter, cursor string, count int) (models.BanPage, int, error) {
+	if filter.Status != "" && !banStatuses[filter.Status] {
+		return models.BanPage{}, http.StatusBadRequest, errors.New("status must be active, expired or pardoned")
+	}
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.BanPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	bans, err := database.GetStore().GetBans(filter, after, count+1)
+	if err != nil {
+		return models.BanPage{}, http.StatusInternalServerError, err
+	}
+	page := models.BanPage{Bans: bans}
+	if len(bans) > count {
+		page.Bans = bans[:count]
+		last := page.Bans[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
+
+// GetUserBans returns a page of every ban of the user with their pardon, most recent first
+func GetUserBans(userId int, cursor string, count int) (models.BanPage, int, error) {
+	exists, err := database.GetStore().CheckUserIdExists(userId)
+	if err != nil {
+		return models.BanPage{}, http.StatusInternalServerError, err
+	}
+	if !exists {
+		return models.BanPage{}, http.StatusNotFound, errors.New("user not found")
+	}
+	return GetBans(models.BanFilter{UserId: userId}, cursor, count)
+}
+
+// EditBan changes the duration of an active ban, the new duration is counted from the creation of the ban
+func EditBan(audit models.AuditContext, infos models.EditBanInfos) (models.BanDetails, int, error) {
+	if infos.Duration < 0 {
+		return models.BanDetails{}, http.StatusBadRequest, errors.New("duration cannot be negative")
+	}
+	ban, err := database.GetStore().GetBan(infos.BanId)
+	if err == sql.ErrNoRows {
+		return models.BanDetails{}, http.StatusNotFound, errors.New("ban not found")
+	} else if err != nil {
+		return models.BanDetails{}, http.StatusInternalServerError, err
+	}
+	if ban.Status != models.BanStatusActive {
+		return models.BanDetails{}, http.StatusBadRequest, errors.New("only active bans can be edited")
+	}
+
+	var expiresAt *time.Time
+	if infos.Duration != 0 {
+		expiration := ban.CreatedAt.Add(time.Duration(infos.Duration) * time.Hour)
+		if !expiration.After(time.Now()) {
+			return models.BanDetails{}, http.StatusBadRequest, errors.New("the ban would already be expired, pardon it instead")
+		}
+		expiresAt = &expiration
+	}
+
+	before := banDuration{ExpiresAt: ban.ExpiresAt}
+	if ban.ExpiresAt != nil {
+		before.Duration = int(math.Round(ban.ExpiresAt.Sub(ban.CreatedAt).Hours()))
+	}
+	code, err := inTransaction(func(store database.Store) error {
+		err := store.UpdateBanExpiration(ban.Id, expiresAt)
+		if err != nil {
+			return err
+		}
+		return addAuditEntry(store, audit, models.AuditBanEdit, models.AuditTargetBan, ban.Id, before, banDuration{Duration: infos.Duration, ExpiresAt: expiresAt})
+	})
+	if err != nil {
+		return models.BanDetails{}, code, err
+	}
+
+	ban.ExpiresAt = expiresAt
+	ban.IsPermanent = expiresAt == nil
+	return ban, http.StatusOK, nil
+}
@@ -1,10 +1,13 @@
 package client
 
 import (
+	"database/sql"
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/models"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -17,43 +20,9 @@ func TestBanUserError(t *testing.T) {
 	defer db.Close()
 	database.DB = db
 
-	// test with error while checking moderator status
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking moderator status"))
-	_, code, err := BanUser(1, 1, 1, "reason")
-	if code != http.StatusInternalServerError {
-		t.Errorf("expected 500, got %d", code)
-	}
-	if err == nil {
-		t.Errorf("expected error, got nil")
-	}
-
-	// test with error while checking admin status
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(0))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking admin status"))
-	_, code, err = BanUser(1, 1, 1, "reason")
-	if code != http.StatusInternalServerError {
-		t.Errorf("expected 500, got %d", code)
-	}
-	if err == nil {
-		t.Errorf("expected error, got nil")
-	}
-
-	// test with user not being a moderator or admin
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(0))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
-	_, code, err = BanUser(1, 1, 1, "reason")
-	if code != http.StatusForbidden {
-		t.Errorf("expected 403, got %d", code)
-	}
-	if err == nil {
-		t.Errorf("expected error, got nil")
-	}
-
 	// test with error while checking user id exists
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking user id exists"))
-	_, code, err = BanUser(1, 1, 1, "reason")
+	_, code, err := BanUser(models.AuditContext{ModeratorId: 1}, 1, 1, "reason")
 	if code != http.StatusInternalServerError {
 		t.Errorf("expected 500, got %d", code)
 	}
@@ -62,10 +31,8 @@ func TestBanUserError(t *testing.T) {
 	}
 
 	// test with user not found
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(0))
-	_, code, err = BanUser(1, 1, 1, "reason")
+	_, code, err = BanUser(models.AuditContext{ModeratorId: 1}, 1, 1, "reason")
 	if code != http.StatusNotFound {
 		t.Errorf("expected 404, got %d", code)
 	}
@@ -74,10 +41,8 @@ func TestBanUserError(t *testing.T) {
 	}
 
 	// test with user being self
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-	_, code, err = BanUser(1, 1, 1, "reason")
+	_, code, err = BanUser(models.AuditContext{ModeratorId: 1}, 1, 1, "reason")
 	if code != http.StatusForbidden {
 		t.Errorf("expected 403, got %d", code)
 	}
@@ -86,11 +51,9 @@ func TestBanUserError(t *testing.T) {
 	}
 
 	// test with error while checking admin status of user
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
 	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnError(errors.New("error while checking admin status of user"))
-	_, code, err = BanUser(2, 1, 1, "reason")
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(2, "admin").WillReturnError(errors.New("error while checking admin status of user"))
+	_, code, err = BanUser(models.AuditContext{ModeratorId: 1}, 2, 1, "reason")
 	if code != http.StatusInternalServerError {
 		t.Errorf("expected 500, got %d", code)
 	}
@@ -99,11 +62,9 @@ func TestBanUserError(t *testing.T) {
 	}
 
 	// // test with user being an admin
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
 	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-	_, code, err = BanUser(2, 1, 1, "reason")
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(2, "admin").WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
+	_, code, err = BanUser(models.AuditContext{ModeratorId: 1}, 2, 1, "reason")
 	if code != http.StatusForbidden {
 		t.Errorf("expected 403, got %d", code)
 	}
@@ -112,12 +73,12 @@ func TestBanUserError(t *testing.T) {
 	}
 
 	// test with error while banning user
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
 	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(2, "admin").WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO ban").WithArgs(2, 1, "reason").WillReturnError(errors.New("error while banning user"))
-	_, code, err = BanUser(2, 1, 1, "reason")
+	mock.ExpectRollback()
+	_, code, err = BanUser(models.AuditContext{ModeratorId: 1}, 2, 1, "reason")
 	if code != http.StatusInternalServerError {
 		t.Errorf("expected 500, got %d", code)
 	}
@@ -135,136 +96,250 @@ func TestBanUser(t *testing.T) {
 	database.DB = db
 
 	// test with success
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
 	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(2, "admin").WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO ban").WithArgs(2, 1, "ban reason").WillReturnResult(sqlmock.NewResult(1, 1))
-	_, code, err := BanUser(2, 1, 0, "ban reason")
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "user.ban", "user", 2, `{"after":{"ban_id":1,"duration":0,"reason":"ban reason"}}`, "1.2.3.4", "agent").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	_, code, err := BanUser(models.AuditContext{ModeratorId: 1, IpAddress: "1.2.3.4", UserAgent: "agent"}, 2, 0, "ban reason")
 	if code != http.StatusOK {
 		t.Errorf("expected 200, got %d", code)
 	}
 	if err != nil {
 		t.Errorf("expected nil, got error")
 	}
+
+	// the ban is rolled back when it can't be logged
+	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(2, "admin").WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO ban").WithArgs(2, 1, "ban reason").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnError(errors.New("error while logging ban"))
+	mock.ExpectRollback()
+	_, code, err = BanUser(models.AuditContext{ModeratorId: 1}, 2, 0, "ban reason")
+	if code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
 }
 
-// func TestPardonUserError(t *testing.T) {
-// 	db, mock, err := sqlmock.New()
-// 	if err != nil {
-// 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-// 	}
-// 	defer db.Close()
-// 	database.DB = db
-
-// 	// test with error while checking moderator status
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking moderator status"))
-// 	_, code, err := PardonUser(1, 1)
-// 	if code != http.StatusInternalServerError {
-// 		t.Errorf("expected 500, got %d", code)
-// 	}
-// 	if err == nil {
-// 		t.Errorf("expected error, got nil")
-// 	}
-
-// 	// test with error while checking admin status
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(0))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking admin status"))
-// 	_, code, err = PardonUser(1, 1)
-// 	if code != http.StatusInternalServerError {
-// 		t.Errorf("expected 500, got %d", code)
-// 	}
-// 	if err == nil {
-// 		t.Errorf("expected error, got nil")
-// 	}
-
-// 	// test with user not being a moderator or admin
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(0))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
-// 	_, code, err = PardonUser(1, 1)
-// 	if code != http.StatusForbidden {
-// 		t.Errorf("expected 403, got %d", code)
-// 	}
-// 	if err == nil {
-// 		t.Errorf("expected error, got nil")
-// 	}
-
-// 	// test with error while checking ban exists by ban id
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking ban exists by ban id"))
-// 	_, code, err = PardonUser(1, 1)
-// 	if code != http.StatusInternalServerError {
-// 		t.Errorf("expected 500, got %d", code)
-// 	}
-// 	if err == nil {
-// 		t.Errorf("expected error, got nil")
-// 	}
-
-// 	// test with ban not found
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-// 	_, code, err = PardonUser(1, 1)
-// 	if code != http.StatusNotFound {
-// 		t.Errorf("expected 404, got %d", code)
-// 	}
-// 	if err == nil {
-// 		t.Errorf("expected error, got nil")
-// 	}
-
-// 	// test with error while checking pardon exists
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking pardon exists"))
-// 	_, code, err = PardonUser(1, 1)
-// 	if code != http.StatusInternalServerError {
-// 		t.Errorf("expected 500, got %d", code)
-// 	}
-// 	if err == nil {
-// 		t.Errorf("expected error, got nil")
-// 	}
-
-// 	// test with error while pardoning user
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-// 	mock.ExpectExec("INSERT INTO pardon").WithArgs(1, 1).WillReturnError(errors.New("error while pardoning user"))
-
-// 	_, code, err = PardonUser(1, 1)
-// 	if code != http.StatusInternalServerError {
-// 		t.Errorf("expected 500, got %d", code)
-// 	}
-// 	if err == nil {
-// 		t.Errorf("expected error, got nil")
-// 	}
-// }
-
-// func TestPardonUser(t *testing.T) {
-// 	db, mock, err := sqlmock.New()
-// 	if err != nil {
-// 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-// 	}
-// 	defer db.Close()
-// 	database.DB = db
-
-// 	// test with success
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
-// 	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-// 	mock.ExpectExec("INSERT INTO pardon").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-
-// 	_, code, err := PardonUser(1, 1)
-// 	if code != http.StatusOK {
-// 		t.Errorf("expected 200, got %d", code)
-// 	}
-// 	if err != nil {
-// 		t.Errorf("expected nil, got error")
-// 	}
-// }
+func TestPardonUserError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// test with error while checking ban exists by ban id
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban").WithArgs(1).WillReturnError(errors.New("error while checking ban exists by ban id"))
+	_, code, err := PardonUser(models.AuditContext{ModeratorId: 1}, 1)
+	if code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// test with ban not found
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	_, code, err = PardonUser(models.AuditContext{ModeratorId: 1}, 1)
+	if code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// test with error while checking pardon exists
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM pardon").WithArgs(1).WillReturnError(errors.New("error while checking pardon exists"))
+	_, code, err = PardonUser(models.AuditContext{ModeratorId: 1}, 1)
+	if code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// test with ban already pardoned
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM pardon").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	_, code, err = PardonUser(models.AuditContext{ModeratorId: 1}, 1)
+	if code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// test with error while pardoning user
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM pardon").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pardon").WithArgs(1, 1).WillReturnError(errors.New("error while pardoning user"))
+	mock.ExpectRollback()
+	_, code, err = PardonUser(models.AuditContext{ModeratorId: 1}, 1)
+	if code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestPardonUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// test with success
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM pardon").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pardon").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "user.pardon", "ban", 1, `{"before":{"pardoned":false},"after":{"pardoned":true,"pardon_id":1}}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	pardonId, code, err := PardonUser(models.AuditContext{ModeratorId: 1}, 1)
+	if code != http.StatusOK {
+		t.Errorf("expected 200, got %d", code)
+	}
+	if err != nil || pardonId != 1 {
+		t.Errorf("expected pardon 1, got %d, %v", pardonId, err)
+	}
+
+	// a concurrent request pardoned the ban after the check, no second pardon nor audit entry is written
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM pardon").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pardon (.+) AND NOT EXISTS").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	_, code, err = PardonUser(models.AuditContext{ModeratorId: 1}, 1)
+	if code != http.StatusBadRequest || err != errBanAlreadyPardoned {
+		t.Errorf("expected 400, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+var banDetailsColumns = []string{"id", "user_id", "author_id", "reason", "created_at", "expires_at", "status", "pardon_id", "pardoner_id", "pardoned_at"}
+
+func TestGetBans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// invalid status
+	_, code, err := GetBans(models.BanFilter{Status: "lifted"}, "", 10)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("expected 400, got %d, %v", code, err)
+	}
+
+	// one more ban than asked gives a next page
+	now := time.Now()
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon (.+) WHERE (.+) ban.expires_at > CURRENT_TIMESTAMP").WithArgs(2).WillReturnRows(sqlmock.NewRows(banDetailsColumns).
+		AddRow(2, 1, 3, "spam", now, nil, "active", nil, nil, nil).
+		AddRow(1, 1, 3, "spam", now, nil, "active", nil, nil, nil))
+	page, code, err := GetBans(models.BanFilter{Status: models.BanStatusActive}, "", 1)
+	if code != http.StatusOK || err != nil || len(page.Bans) != 1 || page.NextCursor == "" {
+		t.Errorf("expected 1 ban with a next page, got %d, %+v, %v", code, page, err)
+	}
+
+	// the bans of a missing user
+	mock.ExpectQuery("SELECT").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(0))
+	_, code, err = GetUserBans(4, "", 10)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("expected 404, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestEditBan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	audit := models.AuditContext{ModeratorId: 3}
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * time.Hour)
+
+	// negative duration
+	_, code, err := EditBan(audit, models.EditBanInfos{BanId: 1, Duration: -1})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("expected 400, got %d, %v", code, err)
+	}
+
+	// ban not found
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	_, code, err = EditBan(audit, models.EditBanInfos{BanId: 1, Duration: 48})
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("expected 404, got %d, %v", code, err)
+	}
+
+	// pardoned ban
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(banDetailsColumns).AddRow(1, 2, 3, "spam", createdAt, expiresAt, "pardoned", 1, 3, createdAt))
+	_, code, err = EditBan(audit, models.EditBanInfos{BanId: 1, Duration: 48})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("expected 400, got %d, %v", code, err)
+	}
+
+	// shortened to an expiration in the past
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(banDetailsColumns).AddRow(1, 2, 3, "spam", createdAt, nil, "active", nil, nil, nil))
+	_, code, err = EditBan(audit, models.EditBanInfos{BanId: 1, Duration: 1})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("expected 400, got %d, %v", code, err)
+	}
+
+	// a day long ban made permanent
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(banDetailsColumns).AddRow(1, 2, 3, "spam", createdAt, expiresAt, "active", nil, nil, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE ban SET expires_at").WithArgs(sql.NullTime{}, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").
+		WithArgs(3, "ban.edit", "ban", 1, `{"before":{"duration":24,"expires_at":"2024-01-02T00:00:00Z"},"after":{"duration":0,"expires_at":null}}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	ban, code, err := EditBan(audit, models.EditBanInfos{BanId: 1})
+	if code != http.StatusOK || err != nil || !ban.IsPermanent || ban.ExpiresAt != nil {
+		t.Errorf("expected a permanent ban, got %d, %+v, %v", code, ban, err)
+	}
+
+	// the edit is rolled back when it can't be logged
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(banDetailsColumns).AddRow(1, 2, 3, "spam", time.Now(), nil, "active", nil, nil, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE ban SET expires_at").WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnError(errors.New("error while logging"))
+	mock.ExpectRollback()
+	_, code, err = EditBan(audit, models.EditBanInfos{BanId: 1, Duration: 48})
+	if code != http.StatusInternalServerError || err == nil {
+		t.Errorf("expected 500, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
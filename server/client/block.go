@@ -0,0 +1,84 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+)
+
+// checkNotBlocked returns a forbidden error if the user has been blocked by the other user
+func checkNotBlocked(userId int, otherUserId int) (int, error) {
+	blocked, err := database.GetStore().CheckBlockExists(otherUserId, userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if blocked {
+		return http.StatusForbidden, errors.New("user has been blocked by this user")
+	}
+	return http.StatusOK, nil
+}
+
+// BlockUser stops the blocked user from asking questions to the blocker, following them and liking their answers.
+// The follows between both users are removed
+func BlockUser(blockerId int, blockedId int) (int, error) {
+	if blockerId == blockedId {
+		return http.StatusBadRequest, errors.New("user can't block himself")
+	}
+	blockedExists, err := database.GetStore().CheckUserIdExists(blockedId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !blockedExists {
+		return http.StatusNotFound, errors.New("user not found")
+	}
+
+	created, err := database.GetStore().AddBlock(blockerId, blockedId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !created {
+		return http.StatusBadRequest, errors.New("user already blocks this user")
+	}
+	return http.StatusOK, nil
+}
+
+func UnblockUser(blockerId int, blockedId int) (int, error) {
+	removed, err := database.GetStore().RemoveBlock(blockerId, blockedId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !removed {
+		return http.StatusBadRequest, errors.New("user doesn't block this user")
+	}
+	return http.StatusOK, nil
+}
+
+// GetBlockedUsers returns a page of the users blocked by the user, most recent block first
+func GetBlockedUsers(userId int, cursor string, count int) (models.RelatedUserPage, int, error) {
+	return getRelatedUserPage(cursor, count, func(after *models.Cursor, count int) ([]models.RelatedUser, error) {
+		return database.GetStore().GetBlockedUsers(userId, after, count)
+	})
+}
+
+func getRelatedUserPage(cursor string, count int, getUsers func(after *models.Cursor, count int) ([]models.RelatedUser, error)) (models.RelatedUserPage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.RelatedUserPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more user is fetched to know if there is a next page
+	users, err := getUsers(after, count+1)
+	if err != nil {
+		return models.RelatedUserPage{}, http.StatusInternalServerError, err
+	}
+	page := models.RelatedUserPage{Users: users}
+	if len(users) > count {
+		page.Users = users[:count]
+		last := page.Users[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.Since, Id: last.RelationId})
+	}
+	return page, http.StatusOK, nil
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestBlockUser(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	code, err := BlockUser(1, 1)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected users not to block themselves, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	code, err = BlockUser(1, 2)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2, 2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	code, err = BlockUser(1, 2)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected the block to exist already, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2, 2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	code, err = BlockUser(1, 2)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestUnblockUser(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	mock.ExpectExec("DELETE FROM user_block").WithArgs(1, 2).WillReturnError(errors.New("error"))
+	code, err := UnblockUser(1, 2)
+	if code != http.StatusInternalServerError || err == nil {
+		t.Errorf("Expected http.StatusInternalServerError, got %d", code)
+	}
+	mock.ExpectExec("DELETE FROM user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	code, err = UnblockUser(1, 2)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected http.StatusBadRequest, got %d", code)
+	}
+	mock.ExpectExec("DELETE FROM user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	code, err = UnblockUser(1, 2)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetBlockedUsers(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	_, code, _ := GetBlockedUsers(1, "forged", 1)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected http.StatusBadRequest for a forged cursor, got %d", code)
+	}
+
+	// one more user than asked is fetched to know if there is a next page
+	now := time.Now()
+	mock.ExpectQuery("SELECT user_block.id").WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "id", "username", "display_name"}).AddRow(2, now, 3, "tata", "Tata").AddRow(1, now, 2, "titi", "Titi"))
+	page, code, err := GetBlockedUsers(1, "", 1)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if len(page.Users) != 1 || page.Users[0].Username != "tata" || page.NextCursor == "" {
+		t.Errorf("Expected a page with tata and a next cursor, got %+v", page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,76 @@
+// Package captcha checks the captchas solved by logged out visitors.
+// The tokens are verified against a siteverify endpoint, the protocol shared by hCaptcha, reCAPTCHA and Turnstile
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// DefaultVerifyUrl is used when CAPTCHA_VERIFY_URL is not set
+const DefaultVerifyUrl = "https://api.hcaptcha.com/siteverify"
+
+// TestToken is the only valid token when IS_TEST is set
+const TestToken = "test"
+
+var ErrNotConfigured = errors.New("captcha is not configured on this server")
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func isTest() bool {
+	return os.Getenv("IS_TEST") == "true"
+}
+
+// Enabled tells if captchas can be verified, it needs the CAPTCHA_SECRET env variable
+func Enabled() bool {
+	return isTest() || os.Getenv("CAPTCHA_SECRET") != ""
+}
+
+type verifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify tells if the token proves that the visitor at the IP address solved a captcha
+func Verify(token string, ipAddress string) (bool, error) {
+	if isTest() {
+		return token == TestToken, nil
+	}
+	secret := os.Getenv("CAPTCHA_SECRET")
+	if secret == "" {
+		return false, ErrNotConfigured
+	}
+	if token == "" {
+		return false, nil
+	}
+	verifyUrl := os.Getenv("CAPTCHA_VERIFY_URL")
+	if verifyUrl == "" {
+		verifyUrl = DefaultVerifyUrl
+	}
+
+	resp, err := client.PostForm(verifyUrl, url.Values{"secret": {secret}, "response": {token}, "remoteip": {ipAddress}})
+	if err != nil {
+		log.Printf("Error verifying captcha, %v\n", err)
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Error verifying captcha, got status %d\n", resp.StatusCode)
+		return false, errors.New("captcha verification failed")
+	}
+	var result verifyResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		log.Printf("Error decoding captcha verification, %v\n", err)
+		return false, err
+	}
+	if !result.Success {
+		log.Printf("Captcha refused, %v\n", result.ErrorCodes)
+	}
+	return result.Success, nil
+}
@@ -0,0 +1,63 @@
+package captcha
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyInTestMode(t *testing.T) {
+	t.Setenv("IS_TEST", "true")
+	if !Enabled() {
+		t.Errorf("Expected captchas to be enabled in test mode")
+	}
+	if valid, err := Verify(TestToken, "127.0.0.1"); err != nil || !valid {
+		t.Errorf("Expected the test token to be valid, got %t and %v", valid, err)
+	}
+	if valid, _ := Verify("other", "127.0.0.1"); valid {
+		t.Errorf("Expected other tokens to be refused")
+	}
+}
+
+func TestVerifyNotConfigured(t *testing.T) {
+	t.Setenv("IS_TEST", "false")
+	t.Setenv("CAPTCHA_SECRET", "")
+	if Enabled() {
+		t.Errorf("Expected captchas to be disabled without secret")
+	}
+	if _, err := Verify("token", "127.0.0.1"); err != ErrNotConfigured {
+		t.Errorf("Expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("secret") != "secret" || r.PostForm.Get("remoteip") != "127.0.0.1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("response") == "valid" {
+			w.Write([]byte(`{"success": true}`))
+		} else {
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer server.Close()
+	t.Setenv("IS_TEST", "false")
+	t.Setenv("CAPTCHA_SECRET", "secret")
+	t.Setenv("CAPTCHA_VERIFY_URL", server.URL)
+
+	if valid, err := Verify("valid", "127.0.0.1"); err != nil || !valid {
+		t.Errorf("Expected the token to be valid, got %t and %v", valid, err)
+	}
+	if valid, err := Verify("invalid", "127.0.0.1"); err != nil || valid {
+		t.Errorf("Expected the token to be refused, got %t and %v", valid, err)
+	}
+	if valid, err := Verify("", "127.0.0.1"); err != nil || valid {
+		t.Errorf("Expected an empty token to be refused without verification, got %t and %v", valid, err)
+	}
+	if _, err := Verify("valid", "10.0.0.1"); err == nil {
+		t.Errorf("Expected an error when the verification fails")
+	}
+}
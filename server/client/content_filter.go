@@ -0,0 +1,184 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+	"project_truthful/moderation/filter"
+	"project_truthful/notifications"
+)
+
+// ContentRejected is the code of the questions and answers rejected by the content filters
+const ContentRejected = "content_rejected"
+
+// ContentRejectedError is returned when the content filters reject a question or an answer.
+// The matches are kept out of the error so that spammers can't learn how to get through
+type ContentRejectedError struct {
+	Kind string
+}
+
+func (e *ContentRejectedError) Error() string {
+	return e.Kind + " was rejected by the content filters"
+}
+
+// filterContent runs the content filters on a question for the receiver, or on an answer of the receiver.
+// Every decision the filters didn't make on a clean content is recorded in the audit log with the IP address of the author,
+// the error is a ContentRejectedError on rejection
+func filterContent(kind string, text string, authorId int, authorIpAddress string, receiverId int) (filter.Result, int, error) {
+	content := filter.Content{Kind: kind, Text: text, AuthorId: authorId, ReceiverId: receiverId}
+	if kind == filter.Question {
+		words, err := database.GetStore().GetMutedWords(receiverId)
+		if err != nil {
+			return filter.Result{}, http.StatusInternalServerError, err
+		}
+		content.MutedWords = words
+	}
+	result, err := filter.GetPipeline().Run(content)
+	if err != nil {
+		return filter.Result{}, http.StatusInternalServerError, err
+	}
+
+	if result.Score > 0 {
+		decision := filterDecision{Kind: kind, Decision: result.Decision.String(), Score: result.Score, Reasons: filterReasons(result)}
+		err = addAuditEntry(database.GetStore(), models.AuditContext{IpAddress: authorIpAddress}, models.AuditFilterDecision, models.AuditTargetUser, authorId, nil, decision)
+		if err != nil {
+			return filter.Result{}, http.StatusInternalServerError, err
+		}
+	}
+	if result.Decision == filter.Reject {
+		return result, http.StatusBadRequest, &ContentRejectedError{Kind: kind}
+	}
+	return result, http.StatusOK, nil
+}
+
+// filterDecision is the decision of the filters recorded in the audit log
+type filterDecision struct {
+	Kind     string   `json:"kind"`
+	Decision string   `json:"decision"`
+	Score    int      `json:"score"`
+	Reasons  []string `json:"reasons"`
+}
+
+// filterReasons lists the matches of the filters
+func filterReasons(result filter.Result) []string {
+	reasons := []string{}
+	for _, match := range result.Matches {
+		reasons = append(reasons, match.Filter+": "+match.Reason)
+	}
+	return reasons
+}
+
+// holdContent queues the content held by the filters for a review, the reasons are the matches of the filters
+func holdContent(content models.HeldContent, result filter.Result) (int, error) {
+	content.Score = result.Score
+	content.Reasons = filterReasons(result)
+	_, err := database.GetStore().AddHeldContent(content)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusAccepted, nil
+}
+
+// GetHeldContents returns a page of the contents waiting for a review, most recent first
+func GetHeldContents(cursor string, count int) (models.HeldContentPage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.HeldContentPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more content is fetched to know if there is a next page
+	contents, err := database.GetStore().GetPendingHeldContents(after, count+1)
+	if err != nil {
+		return models.HeldContentPage{}, http.StatusInternalServerError, err
+	}
+	page := models.HeldContentPage{Contents: contents}
+	if len(contents) > count {
+		page.Contents = contents[:count]
+		last := page.Contents[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
+
+// heldState is the state of a held content recorded in the audit log before and after its review
+type heldState struct {
+	HeldId int    `json:"held_id"`
+	Status string `json:"status"`
+	// question or answer published on approval
+	ContentId int64 `json:"content_id,omitempty"`
+}
+
+// errAlreadyReviewed rolls back the review of a content another moderator reviewed first
+var errAlreadyReviewed = errors.New("content has already been reviewed")
+
+// ReviewHeldContent publishes the held content when approved, the review is recorded in the audit log with the author as target
+func ReviewHeldContent(audit models.AuditContext, heldId int, approve bool) (models.HeldContent, int, error) {
+	held, err := database.GetStore().GetHeldContent(heldId)
+	if err == sql.ErrNoRows {
+		return models.HeldContent{}, http.StatusNotFound, errors.New("held content not found")
+	} else if err != nil {
+		return models.HeldContent{}, http.StatusInternalServerError, err
+	}
+	if held.Status != models.HeldPending {
+		return models.HeldContent{}, http.StatusBadRequest, errAlreadyReviewed
+	}
+	if approve && held.ContentType == filter.Answer {
+		answered, err := database.GetStore().HasQuestionBeenAnswered(held.QuestionId)
+		if err != nil {
+			return models.HeldContent{}, http.StatusInternalServerError, err
+		}
+		if answered {
+			return models.HeldContent{}, http.StatusBadRequest, errors.New("question has already been answered")
+		}
+	}
+
+	status := models.HeldRejected
+	if approve {
+		status = models.HeldApproved
+	}
+	var id int64
+	code, err := inTransaction(func(store database.Store) error {
+		// the review is recorded first so that two moderators can't publish the content twice
+		reviewed, err := store.ReviewHeldContent(heldId, status, audit.ModeratorId)
+		if err != nil {
+			return err
+		}
+		if !reviewed {
+			return errAlreadyReviewed
+		}
+		if approve {
+			if held.ContentType == filter.Question {
+				id, err = store.AddQuestion(held.Text, held.AuthorId, held.AuthorIpAddress, held.IsAuthorAnonymous, held.ReceiverId)
+			} else {
+				id, err = store.AddAnswer(held.AuthorId, held.QuestionId, held.Text, held.AuthorIpAddress)
+			}
+			if err != nil {
+				return err
+			}
+			err = store.SetHeldContentId(heldId, int(id))
+			if err != nil {
+				return err
+			}
+		}
+		return addAuditEntry(store, audit, models.AuditHeldContentReview, models.AuditTargetUser, held.AuthorId,
+			heldState{HeldId: heldId, Status: models.HeldPending}, heldState{HeldId: heldId, Status: status, ContentId: id})
+	})
+	if err == errAlreadyReviewed {
+		return models.HeldContent{}, http.StatusBadRequest, err
+	} else if err != nil {
+		return models.HeldContent{}, code, err
+	}
+	held.Status = status
+
+	// the notifications are sent once the content is published
+	if approve && held.ContentType == filter.Question {
+		notifications.NotifyQuestionReceived(held.ReceiverId, held.AuthorId, held.IsAuthorAnonymous, int(id))
+	} else if approve {
+		notifications.NotifyQuestionAnswered(held.QuestionId, held.AuthorId, int(id))
+	}
+	return held, http.StatusOK, nil
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"project_truthful/moderation/filter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func setTestPipeline(t *testing.T) {
+	previous := filter.GetPipeline()
+	blocklist, err := filter.ParseBlocklist(strings.NewReader("casino\nhold free money"))
+	if err != nil {
+		t.Fatalf("Error while parsing blocklist: %s", err.Error())
+	}
+	filter.SetPipeline(filter.NewPipeline(blocklist, filter.MutedWords{}, filter.Links{Score: filter.HoldScore}))
+	t.Cleanup(func() { filter.SetPipeline(previous) })
+}
+
+func TestFilterContent(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	setTestPipeline(t)
+
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"word"}))
+	result, code, err := filterContent(filter.Question, "What is the meaning of life?", 1, "ip_address", 2)
+	if code != http.StatusOK || err != nil || result.Decision != filter.Allow {
+		t.Errorf("Expected the question to be allowed, got %s, %d and %v", result.Decision, code, err)
+	}
+
+	// the decisions on the contents that matched are logged
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"word"}))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(nil, "filter.decision", "user", 1,
+		`{"after":{"kind":"question","decision":"hold","score":50,"reasons":["blocklist: matches free money"]}}`, "ip_address", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	result, code, err = filterContent(filter.Question, "Get free money", 1, "ip_address", 2)
+	if code != http.StatusOK || err != nil || result.Decision != filter.Hold {
+		t.Errorf("Expected the question to be held, got %s, %d and %v", result.Decision, code, err)
+	}
+
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"word"}).AddRow("pizza"))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(nil, "filter.decision", "user", 1, sqlmock.AnyArg(), "ip_address", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	_, code, err = filterContent(filter.Question, "Do you like pizza?", 1, "ip_address", 2)
+	var rejected *ContentRejectedError
+	if code != http.StatusBadRequest || !errors.As(err, &rejected) {
+		t.Errorf("Expected the muted word to reject the question, got %d and %v", code, err)
+	}
+
+	// the muted words are not checked on answers
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(nil, "filter.decision", nil, nil, sqlmock.AnyArg(), "ip_address", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	_, code, err = filterContent(filter.Answer, "casino", 0, "ip_address", 2)
+	if code != http.StatusBadRequest || !errors.As(err, &rejected) || strings.Contains(err.Error(), "casino") {
+		t.Errorf("Expected the answer to be rejected without telling why, got %d and %v", code, err)
+	}
+
+	// a decision which can't be logged is not applied
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnError(errors.New("error"))
+	_, code, err = filterContent(filter.Answer, "casino", 1, "ip_address", 1)
+	if code != http.StatusInternalServerError || err == nil {
+		t.Errorf("Expected http.StatusInternalServerError, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+var heldContentColumns = []string{"id", "content_type", "text", "author_id", "author_ip_address", "is_author_anonymous",
+	"receiver_id", "question_id", "score", "reasons", "status", "created_at"}
+
+func TestReviewHeldContent(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM held_content").WithArgs(1).WillReturnRows(sqlmock.NewRows(heldContentColumns))
+	_, code, err := ReviewHeldContent(models.AuditContext{ModeratorId: 3}, 1, true)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM held_content").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(heldContentColumns).AddRow(1, "question", "text", 2, "ip_address", false, 1, nil, 50, `[]`, "rejected", time.Now()))
+	_, code, err = ReviewHeldContent(models.AuditContext{ModeratorId: 3}, 1, true)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected reviewed contents to be refused, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM held_content").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(heldContentColumns).AddRow(2, "answer", "text", 1, "ip_address", false, 1, 4, 50, `[]`, "pending", time.Now()))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	_, code, err = ReviewHeldContent(models.AuditContext{ModeratorId: 3}, 2, true)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected answers of answered questions to be refused, got %d", code)
+	}
+
+	// another moderator reviewed the content in the meantime
+	mock.ExpectQuery("SELECT (.+) FROM held_content").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(heldContentColumns).AddRow(1, "question", "text", 2, "ip_address", false, 1, nil, 50, `[]`, "pending", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE held_content SET status").WithArgs("rejected", 3, 1, "pending").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	_, code, err = ReviewHeldContent(models.AuditContext{ModeratorId: 3}, 1, false)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected the second review to be refused, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM held_content").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(heldContentColumns).AddRow(1, "question", "text", 2, "ip_address", false, 1, nil, 50, `[]`, "pending", time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE held_content SET status").WithArgs("rejected", 3, 1, "pending").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(3, "held_content.review", "user", 2,
+		`{"before":{"held_id":1,"status":"pending"},"after":{"held_id":1,"status":"rejected"}}`, "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	held, code, err := ReviewHeldContent(models.AuditContext{ModeratorId: 3}, 1, false)
+	if code != http.StatusOK || err != nil || held.Status != "rejected" || held.AuthorId != 2 {
+		t.Errorf("Expected the content to be rejected, got %+v, %d and %v", held, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+	"strings"
+	"time"
+)
+
+func AddAddressBan(banType string, value string, authorId int, duration int, reason string, db Querier) (int64, error) {
+	var expiresAt sql.NullTime
+	if duration != 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().Add(time.Duration(duration) * time.Hour), Valid: true}
+	}
+	result, err := db.Exec("INSERT INTO address_ban (ban_type, value, author_id, reason, expires_at) VALUES (?, ?, ?, ?, ?)", banType, value, authorId, reason, expiresAt)
+	if err != nil {
+		log.Printf("Error banning %s %s, %v\n", banType, value, err)
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting address ban ID, %v\n", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+const addressBanColumns = "address_ban.id, address_ban.ban_type, address_ban.value, address_ban.author_id, address_ban.reason, address_ban.created_at, address_ban.expires_at"
+
+// an address ban is active until it expires, permanent ones have no expiration date
+const activeAddressBanCondition = "(address_ban.expires_at IS NULL OR address_ban.expires_at > CURRENT_TIMESTAMP)"
+
+func scanAddressBan(scanner interface{ Scan(...any) error }) (models.AddressBan, error) {
+	var ban models.AddressBan
+	var expiresAt sql.NullTime
+	err := scanner.Scan(&ban.Id, &ban.BanType, &ban.Value, &ban.AuthorId, &ban.Reason, &ban.CreatedAt, &expiresAt)
+	if err != nil {
+		return models.AddressBan{}, err
+	}
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	} else {
+		ban.IsPermanent = true
+	}
+	return ban, nil
+}
+
+// GetAddressBan returns the ban, sql.ErrNoRows when it doesn't exist
+func GetAddressBan(banId int, db Querier) (models.AddressBan, error) {
+	ban, err := scanAddressBan(db.QueryRow("SELECT "+addressBanColumns+" FROM address_ban WHERE address_ban.id = ?", banId))
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting address ban %d, %v\n", banId, err)
+	}
+	return ban, err
+}
+
+// GetAddressBans returns the active bans after the cursor, most recent first.
+// Only the bans of the type are returned when banType is not empty, every active ban is returned when count is 0
+func GetAddressBans(banType string, cursor *models.Cursor, count int, db Querier) ([]models.AddressBan, error) {
+	conditions := []string{activeAddressBanCondition}
+	args := []any{}
+	if banType != "" {
+		conditions = append(conditions, "address_ban.ban_type = ?")
+		args = append(args, banType)
+	}
+	condition, pageArgs := pageCondition("address_ban", cursor)
+	if condition != "" {
+		conditions = append(conditions, strings.TrimPrefix(condition, " AND "))
+		args = append(args, pageArgs...)
+	}
+	query := "SELECT " + addressBanColumns + " FROM address_ban WHERE " + strings.Join(conditions, " AND ") + " ORDER BY address_ban.created_at DESC, address_ban.id DESC"
+	if count != 0 {
+		query += " LIMIT ?"
+		args = append(args, count)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error getting address bans, %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []models.AddressBan{}
+	for rows.Next() {
+		ban, err := scanAddressBan(rows)
+		if err != nil {
+			log.Printf("Error scanning address bans, %v\n", err)
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+func RemoveAddressBan(banId int, db Querier) error {
+	_, err := db.Exec("DELETE FROM address_ban WHERE id = ?", banId)
+	if err != nil {
+		log.Printf("Error removing address ban %d, %v\n", banId, err)
+		return err
+	}
+	return nil
+}
+
+// GetUserIpAddresses returns the IP addresses the user asked or answered from
+func GetUserIpAddresses(userId int, db Querier) ([]string, error) {
+	rows, err := db.Query("SELECT author_ip_address FROM question WHERE author_id = ? UNION SELECT answerer_ip_address FROM answer WHERE user_id = ? ORDER BY 1", userId, userId)
+	if err != nil {
+		log.Printf("Error getting IP addresses of user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ipAddresses := []string{}
+	for rows.Next() {
+		var ipAddress string
+		err := rows.Scan(&ipAddress)
+		if err != nil {
+			log.Printf("Error scanning IP addresses of user %d, %v\n", userId, err)
+			return nil, err
+		}
+		ipAddresses = append(ipAddresses, ipAddress)
+	}
+	return ipAddresses, nil
+}
+
+// GetAccountsByIpAddresses returns the accounts other than the user which asked or answered from the IP addresses,
+// with the addresses each of them used
+func GetAccountsByIpAddresses(ipAddresses []string, userId int, db Querier) ([]models.LinkedAccount, error) {
+	if len(ipAddresses) == 0 {
+		return []models.LinkedAccount{}, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ipAddresses)), ", ")
+	args := []any{}
+	for _, ipAddress := range ipAddresses {
+		args = append(args, ipAddress)
+	}
+	args = append(args, args...)
+	rows, err := db.Query("SELECT user.id, user.username, shared.ip_address FROM "+
+		"(SELECT author_id AS user_id, author_ip_address AS ip_address FROM question WHERE author_id IS NOT NULL AND author_ip_address IN ("+placeholders+") "+
+		"UNION SELECT user_id, answerer_ip_address FROM answer WHERE answerer_ip_address IN ("+placeholders+")) shared "+
+		"JOIN user ON user.id = shared.user_id WHERE shared.user_id != ? ORDER BY user.id, shared.ip_address", append(args, userId)...)
+	if err != nil {
+		log.Printf("Error getting accounts sharing IP addresses with user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.LinkedAccount{}
+	for rows.Next() {
+		var account models.LinkedAccount
+		var ipAddress string
+		err := rows.Scan(&account.UserId, &account.Username, &ipAddress)
+		if err != nil {
+			log.Printf("Error scanning accounts sharing IP addresses with user %d, %v\n", userId, err)
+			return nil, err
+		}
+		if len(accounts) > 0 && accounts[len(accounts)-1].UserId == account.UserId {
+			last := &accounts[len(accounts)-1]
+			last.IpAddresses = append(last.IpAddresses, ipAddress)
+			continue
+		}
+		account.IpAddresses = []string{ipAddress}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAddAddressBan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO address_ban").WithArgs("ip", "10.0.0.0/24", 1, "spam", nil).WillReturnResult(sqlmock.NewResult(3, 1))
+	id, err := AddAddressBan("ip", "10.0.0.0/24", 1, 0, "spam", db)
+	if err != nil || id != 3 {
+		t.Errorf("Expected ban 3, got %d, %v", id, err)
+	}
+
+	mock.ExpectExec("INSERT INTO address_ban").WithArgs("email_domain", "spam.com", 1, "", sqlmock.AnyArg()).WillReturnError(errors.New("error"))
+	_, err = AddAddressBan("email_domain", "spam.com", 1, 24, "", db)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestGetAddressBans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	columns := []string{"id", "ban_type", "value", "author_id", "reason", "created_at", "expires_at"}
+	expiresAt := time.Now().Add(time.Hour)
+
+	// every active ban of the type
+	mock.ExpectQuery("SELECT (.+) FROM address_ban WHERE (.+) AND address_ban.ban_type = \\? ORDER BY address_ban.created_at DESC, address_ban.id DESC$").WithArgs("ip").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "ip", "10.0.0.0/24", 1, "", time.Now(), nil).AddRow(1, "ip", "10.0.0.1/32", 1, "", time.Now(), expiresAt))
+	bans, err := GetAddressBans("ip", nil, 0, db)
+	if err != nil || len(bans) != 2 || !bans[0].IsPermanent || bans[1].ExpiresAt == nil {
+		t.Errorf("Expected 2 bans, got %+v, %v", bans, err)
+	}
+
+	// a page of every type
+	cursor := &models.Cursor{CreatedAt: time.Now().UTC(), Id: 5}
+	mock.ExpectQuery("SELECT (.+) FROM address_ban WHERE (.+) ORDER BY (.+) LIMIT").WithArgs(cursor.CreatedAt, cursor.CreatedAt, cursor.Id, 11).
+		WillReturnRows(sqlmock.NewRows(columns))
+	bans, err = GetAddressBans("", cursor, 11, db)
+	if err != nil || len(bans) != 0 {
+		t.Errorf("Expected no ban, got %+v, %v", bans, err)
+	}
+
+	mock.ExpectQuery("FROM address_ban").WillReturnError(errors.New("error"))
+	_, err = GetAddressBans("ip", nil, 0, db)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestGetAccountsByIpAddresses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// nothing to look for
+	accounts, err := GetAccountsByIpAddresses([]string{}, 1, db)
+	if err != nil || len(accounts) != 0 {
+		t.Errorf("Expected no account, got %+v, %v", accounts, err)
+	}
+
+	mock.ExpectQuery("SELECT user.id, user.username, shared.ip_address FROM").WithArgs("10.0.0.1", "10.0.0.2", "10.0.0.1", "10.0.0.2", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "ip_address"}).AddRow(2, "titi", "10.0.0.1").AddRow(2, "titi", "10.0.0.2").AddRow(3, "tata", "10.0.0.1"))
+	accounts, err = GetAccountsByIpAddresses([]string{"10.0.0.1", "10.0.0.2"}, 1, db)
+	if err != nil || len(accounts) != 2 || len(accounts[0].IpAddresses) != 2 || accounts[1].Username != "tata" {
+		t.Errorf("Expected 2 accounts, got %+v, %v", accounts, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
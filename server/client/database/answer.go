@@ -6,7 +6,7 @@ import (
 	"project_truthful/models"
 )
 
-func CheckAnswerIdExists(answerId int, db *sql.DB) (bool, error) {
+func CheckAnswerIdExists(answerId int, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM answer WHERE id = ? AND has_been_deleted = 0", answerId).Scan(&count)
 	if err != nil {
@@ -16,7 +16,7 @@ func CheckAnswerIdExists(answerId int, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func GetAnswerAuthorId(answerId int, db *sql.DB) (int, error) {
+func GetAnswerAuthorId(answerId int, db Querier) (int, error) {
 	var authorId int
 	err := db.QueryRow("SELECT user_id FROM answer WHERE id = ? AND has_been_deleted = 0", answerId).Scan(&authorId)
 	if err != nil {
@@ -26,7 +26,7 @@ func GetAnswerAuthorId(answerId int, db *sql.DB) (int, error) {
 	return authorId, nil
 }
 
-func HasQuestionBeenAnswered(questionId int, db *sql.DB) (bool, error) {
+func HasQuestionBeenAnswered(questionId int, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM answer WHERE question_id = ? AND has_been_deleted = 0", questionId).Scan(&count)
 	if err != nil {
@@ -36,7 +36,7 @@ func HasQuestionBeenAnswered(questionId int, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func GetAnswerIdByQuestionId(questionId int, db *sql.DB) (int, error) {
+func GetAnswerIdByQuestionId(questionId int, db Querier) (int, error) {
 	var answerId int
 	err := db.QueryRow("SELECT id FROM answer WHERE question_id = ? AND has_been_deleted = 0", questionId).Scan(&answerId)
 	if err != nil {
@@ -46,23 +46,31 @@ func GetAnswerIdByQuestionId(questionId int, db *sql.DB) (int, error) {
 	return answerId, nil
 }
 
-func AddAnswer(userId int, questionId int, answerText string, answererIpAddress string, db *sql.DB) (int64, error) {
-	result, err := db.Exec("INSERT INTO answer (user_id, question_id, text, answerer_ip_address) VALUES (?, ?, ?, ?)", userId, questionId, answerText, answererIpAddress)
-	if err != nil {
-		log.Printf("Error inserting answer for user %d and question %d, %v\n", userId, questionId, err)
-		return 0, err
-	}
+// AddAnswer also adds the answer to the timelines of the followers of the user
+func AddAnswer(userId int, questionId int, answerText string, answererIpAddress string, db Querier) (int64, error) {
+	var id int64
+	err := inTransaction(db, func(tx Querier) error {
+		result, err := tx.Exec("INSERT INTO answer (user_id, question_id, text, answerer_ip_address) VALUES (?, ?, ?, ?)", userId, questionId, answerText, answererIpAddress)
+		if err != nil {
+			log.Printf("Error inserting answer for user %d and question %d, %v\n", userId, questionId, err)
+			return err
+		}
 
-	id, err := result.LastInsertId()
+		id, err = result.LastInsertId()
+		if err != nil {
+			log.Printf("Error getting last inserted id for question, %v\n", err)
+			return err
+		}
+		return AddAnswerToTimelines(int(id), tx)
+	})
 	if err != nil {
-		log.Printf("Error getting last inserted id for question, %v\n", err)
 		return 0, err
 	}
 	return id, nil
 }
 
-func MarkAnswerAsDeleted(answerId int, db *sql.DB) error {
-	_, err := db.Exec("UPDATE answer SET has_been_deleted = 1, deleted_at = NOW() WHERE id = ?", answerId)
+func MarkAnswerAsDeleted(answerId int, db Querier) error {
+	_, err := db.Exec("UPDATE answer SET has_been_deleted = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = ?", answerId)
 	if err != nil {
 		log.Printf("Error marking answer %d as deleted, %v\n", answerId, err)
 		return err
@@ -70,54 +78,118 @@ func MarkAnswerAsDeleted(answerId int, db *sql.DB) error {
 	return nil
 }
 
-func getAnswers(id int, requestingUser int, count int, start int, db *sql.DB) ([]models.Answer, error) {
-	rows, err := db.Query("SELECT id, question_id, text, created_at FROM answer WHERE user_id = ? AND has_been_deleted = 0 ORDER BY created_at DESC LIMIT ?, ?", id, start, count+start)
+// CheckAnswerRemoved tells if a moderator removed the answer, it returns sql.ErrNoRows when the answer doesn't exist or was deleted
+func CheckAnswerRemoved(answerId int, db Querier) (bool, error) {
+	var removed bool
+	err := db.QueryRow("SELECT removed_at IS NOT NULL FROM answer WHERE id = ? AND has_been_deleted = 0", answerId).Scan(&removed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error checking if answer %d was removed, %v\n", answerId, err)
+	}
+	return removed, err
+}
+
+func RemoveAnswer(answerId int, moderatorId int, reason string, db Querier) error {
+	_, err := db.Exec("UPDATE answer SET removed_at = CURRENT_TIMESTAMP, removed_by = ?, removal_reason = ? WHERE id = ?", moderatorId, reason, answerId)
+	if err != nil {
+		log.Printf("Error removing answer %d by moderator %d, %v\n", answerId, moderatorId, err)
+		return err
+	}
+	return nil
+}
+
+func RestoreAnswer(answerId int, db Querier) error {
+	_, err := db.Exec("UPDATE answer SET removed_at = NULL, removed_by = NULL, removal_reason = NULL WHERE id = ?", answerId)
+	if err != nil {
+		log.Printf("Error restoring answer %d, %v\n", answerId, err)
+		return err
+	}
+	return nil
+}
+
+func getAnswers(id int, requestingUser int, count int, start int, db Querier) ([]models.Answer, error) {
+	rows, err := db.Query("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer WHERE user_id = ? AND has_been_deleted = 0 ORDER BY created_at DESC LIMIT ? OFFSET ?", id, count, start)
+	if err != nil {
+		log.Printf("Error getting answers for id %d, %v\n", id, err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAnswers(rows, id, requestingUser, db)
+}
+
+// getAnswersPage returns the answers of the user after the cursor, newest first
+func getAnswersPage(id int, requestingUser int, cursor *models.Cursor, count int, db Querier) ([]models.Answer, error) {
+	condition, args := pageCondition("answer", cursor)
+	args = append([]any{id}, append(args, count)...)
+	rows, err := db.Query("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer WHERE user_id = ? AND has_been_deleted = 0"+condition+" ORDER BY created_at DESC, id DESC LIMIT ?", args...)
 	if err != nil {
 		log.Printf("Error getting answers for id %d, %v\n", id, err)
 		return nil, err
 	}
 	defer rows.Close()
+	return scanAnswers(rows, id, requestingUser, db)
+}
+
+func scanAnswers(rows *sql.Rows, id int, requestingUser int, db Querier) ([]models.Answer, error) {
 	var answers []models.Answer
 	for rows.Next() {
-		//TODO: Put this in a function
 		var answer models.Answer
 		var questionId int
-		err := rows.Scan(&answer.Id, &questionId, &answer.AnswerText, &answer.CreatedAt)
+		err := rows.Scan(&answer.Id, &questionId, &answer.AnswerText, &answer.CreatedAt, &answer.RemovedByModeration)
 		if err != nil {
 			log.Printf("Error scanning answer for id %d, %v\n", id, err)
 			return nil, err
 		}
-		question, err := GetQuestionById(questionId, db)
-		if err != nil {
-			log.Printf("Error getting question for answer %d, %v\n", answer.Id, err)
-			question = models.Question{}
-		}
-		answer.QuestionText = question.Text
-		if question.IsAuthorAnonymous {
-			answer.Author = models.UserPreview{}
-			answer.IsAuthorAnonymous = true
-		} else {
-			answer.Author.Id = question.Author.Id
-			answer.Author.Username = question.Author.Username
-			answer.Author.DisplayName = question.Author.DisplayName
-		}
-		answer.LikeCount, err = GetLikeCountForAnswer(answer.Id, db)
+		completeAnswer(&answer, questionId, requestingUser, db)
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
+
+// completeAnswer fills the question, the like count and whether the requesting user liked the answer
+func completeAnswer(answer *models.Answer, questionId int, requestingUser int, db Querier) {
+	question, err := GetQuestionById(questionId, db)
+	if err != nil {
+		log.Printf("Error getting question for answer %d, %v\n", answer.Id, err)
+		question = models.Question{}
+	}
+	answer.QuestionText = question.Text
+	if question.RemovedByModeration {
+		answer.RemovedByModeration = true
+	}
+	if question.IsAuthorAnonymous {
+		answer.Author = models.UserPreview{}
+		answer.IsAuthorAnonymous = true
+	} else {
+		answer.Author.Id = question.Author.Id
+		answer.Author.Username = question.Author.Username
+		answer.Author.DisplayName = question.Author.DisplayName
+	}
+	answer.LikeCount, err = GetLikeCountForAnswer(answer.Id, db)
+	if err != nil {
+		log.Printf("Error getting like count for answer %d, %v\n", answer.Id, err)
+		answer.LikeCount = 0
+	}
+	if requestingUser != 0 {
+		liked, err := CheckLikeExists(requestingUser, answer.Id, db)
 		if err != nil {
-			log.Printf("Error getting like count for answer %d, %v\n", answer.Id, err)
-			answer.LikeCount = 0
-		}
-		if requestingUser != 0 {
-			liked, err := CheckLikeExists(requestingUser, answer.Id, db)
-			if err != nil {
-				log.Printf("Error checking if answer %d is liked by user %d, %v\n", answer.Id, requestingUser, err)
-				answer.LikedByRequester = false
-			} else {
-				answer.LikedByRequester = liked
-			}
-		} else {
+			log.Printf("Error checking if answer %d is liked by user %d, %v\n", answer.Id, requestingUser, err)
 			answer.LikedByRequester = false
+		} else {
+			answer.LikedByRequester = liked
 		}
-		answers = append(answers, answer)
+	} else {
+		answer.LikedByRequester = false
 	}
-	return answers, nil
+	HideRemovedAnswer(answer)
+}
+
+// HideRemovedAnswer empties the answer when a moderator removed it or its question, only the fact that it was removed is shown
+func HideRemovedAnswer(answer *models.Answer) {
+	if !answer.RemovedByModeration {
+		return
+	}
+	answer.QuestionText = ""
+	answer.AnswerText = ""
+	answer.Author = models.UserPreview{}
+	answer.IsAuthorAnonymous = false
 }
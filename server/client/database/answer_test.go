@@ -61,7 +61,9 @@ func TestAddAnswer(t *testing.T) {
 	defer db.Close()
 
 	// Test with query fail
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO answer").WithArgs(1, 1, "content", "ip_address").WillReturnError(errors.New("error for db test"))
+	mock.ExpectRollback()
 	_, err = AddAnswer(1, 1, "content", "ip_address", db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -70,15 +72,34 @@ func TestAddAnswer(t *testing.T) {
 		t.Errorf("Database error: expected error, got nil")
 	}
 
-	// Test with no error
+	// Test with no error, the answer is added to the timelines of the followers
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO answer").WithArgs(2, 2, "content", "ip_address").WillReturnResult(sqlmock.NewResult(1, 1))
-	_, err = AddAnswer(2, 2, "content", "ip_address", db)
+	mock.ExpectExec("INSERT IGNORE INTO timeline_entry (.+) FROM answer JOIN follow ON follow.followed = answer.user_id WHERE answer.id = \\?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	id, err := AddAnswer(2, 2, "content", "ip_address", db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
 	if err != nil {
 		t.Errorf("Database error: expected nil, got %s", err.Error())
 	}
+	if id != 1 {
+		t.Errorf("Expected answer id 1, got %d", id)
+	}
+
+	// the answer is rolled back when the timelines can't be written
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO answer").WithArgs(3, 3, "content", "ip_address").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT IGNORE INTO timeline_entry").WithArgs(2).WillReturnError(errors.New("error for db test"))
+	mock.ExpectRollback()
+	_, err = AddAnswer(3, 3, "content", "ip_address", db)
+	if err == nil {
+		t.Errorf("Database error: expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
 }
 
 func TestHasQuestionBeenAnswered(t *testing.T) {
@@ -185,7 +206,7 @@ func TestGetAnswersErrors(t *testing.T) {
 	defer db.Close()
 
 	// test with error on first query
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnError(errors.New("error for test"))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnError(errors.New("error for test"))
 	_, err = getAnswers(1, 0, 30, 0, db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -195,7 +216,7 @@ func TestGetAnswersErrors(t *testing.T) {
 	}
 
 	// test with wrong type
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, "error", "text", time.Now()))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, "error", "text", time.Now(), false))
 	_, err = getAnswers(1, 0, 30, 0, db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -205,8 +226,8 @@ func TestGetAnswersErrors(t *testing.T) {
 	}
 
 	// test with error on GetQuestionById query and GetLikeCountForAnswer query
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, 1, "text", time.Now()))
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question").WithArgs(1).WillReturnError(errors.New("error for test"))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "text", time.Now(), false))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnError(errors.New("error for test"))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnError(errors.New("error for test"))
 	answers, err := getAnswers(1, 0, 30, 0, db)
 	if mock.ExpectationsWereMet() != nil {
@@ -233,8 +254,8 @@ func TestGetAnswers(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, 1, "text", time.Now()))
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).AddRow(1, "text", 0, true, 1, time.Now()))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "text", time.Now(), false))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(1, "text", 0, true, 1, time.Now(), false))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	answers, err := getAnswers(1, 0, 30, 0, db)
 	if err != nil {
@@ -265,8 +286,8 @@ func TestGetAnswersNotLikedByUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, 1, "text", time.Now()))
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).AddRow(1, "text", 0, true, 1, time.Now()))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "text", time.Now(), false))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(1, "text", 0, true, 1, time.Now(), false))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	answers, err := getAnswers(1, 1, 30, 0, db)
@@ -301,8 +322,8 @@ func TestGetAnswersLikedByUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, 1, "text", time.Now()))
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).AddRow(1, "text", 0, true, 1, time.Now()))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "text", time.Now(), false))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(1, "text", 0, true, 1, time.Now(), false))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	answers, err := getAnswers(1, 1, 30, 0, db)
@@ -337,8 +358,8 @@ func TestGetAnswersErrorCheckLike(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, 1, "text", time.Now()))
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).AddRow(1, "text", 0, true, 1, time.Now()))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "text", time.Now(), false))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(1, "text", 0, true, 1, time.Now(), false))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1, 1).WillReturnError(errors.New("error"))
 	answers, err := getAnswers(1, 1, 30, 0, db)
@@ -400,3 +421,43 @@ func TestGetAnswerIdByQuestionId(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestAnswerRemoval(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM answer WHERE id = \\? AND has_been_deleted = 0").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
+	removed, err := CheckAnswerRemoved(1, db)
+	if err != nil || removed {
+		t.Errorf("Expected the answer not to be removed, got %t and %v", removed, err)
+	}
+
+	mock.ExpectExec("UPDATE answer SET removed_at = CURRENT_TIMESTAMP, removed_by = \\?, removal_reason = \\? WHERE id = \\?").WithArgs(3, "spam", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = RemoveAnswer(1, 3, "spam", db)
+	if err != nil {
+		t.Errorf("Error while removing answer: %s", err.Error())
+	}
+
+	// the removed answer is listed without its content
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "text", time.Now(), true))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(1, "question", nil, true, 1, time.Now(), false))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	answers, err := getAnswers(1, 0, 30, 0, db)
+	if err != nil || len(answers) != 1 || !answers[0].RemovedByModeration || answers[0].AnswerText != "" || answers[0].QuestionText != "" || answers[0].IsAuthorAnonymous {
+		t.Errorf("Expected the answer to be hidden, got %+v and %v", answers, err)
+	}
+
+	mock.ExpectExec("UPDATE answer SET removed_at = NULL, removed_by = NULL, removal_reason = NULL WHERE id = \\?").WithArgs(1).WillReturnError(errors.New("error"))
+	err = RestoreAnswer(1, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
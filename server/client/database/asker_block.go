@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+	"time"
+)
+
+// AddAskerBlock blocks the askers with the IP hash from asking the receiver for duration hours, or for good when 0
+func AddAskerBlock(receiverId int, ipHash string, questionId int, duration int, db Querier) (int64, error) {
+	var expiresAt *time.Time
+	if duration != 0 {
+		expiration := time.Now().UTC().Add(time.Duration(duration) * time.Hour)
+		expiresAt = &expiration
+	}
+	result, err := db.Exec("INSERT INTO asker_block (receiver_id, ip_hash, question_id, expires_at) VALUES (?, ?, ?, ?)", receiverId, ipHash, questionId, expiresAt)
+	if err != nil {
+		log.Printf("Error blocking asker of question %d for receiver %d, %v\n", questionId, receiverId, err)
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting asker block ID, %v\n", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// CheckAskerBlockExists tells if the receiver has an active block of the IP hash, a block is active until it expires
+func CheckAskerBlockExists(receiverId int, ipHash string, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM asker_block WHERE receiver_id = ? AND ip_hash = ? AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)", receiverId, ipHash).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking asker block for receiver %d, %v\n", receiverId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetAskerBlocks returns the active asker blocks of the receiver after the cursor, most recent first.
+// The snippet holds the whole text of the question the asker was blocked from
+func GetAskerBlocks(receiverId int, cursor *models.Cursor, count int, db Querier) ([]models.AskerBlock, error) {
+	condition, args := pageCondition("asker_block", cursor)
+	args = append([]any{receiverId}, append(args, count)...)
+	rows, err := db.Query("SELECT asker_block.id, asker_block.question_id, question.text, asker_block.created_at, asker_block.expires_at "+
+		"FROM asker_block JOIN question ON question.id = asker_block.question_id "+
+		"WHERE asker_block.receiver_id = ? AND (asker_block.expires_at IS NULL OR asker_block.expires_at > CURRENT_TIMESTAMP)"+
+		condition+" ORDER BY asker_block.created_at DESC, asker_block.id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("Error getting asker blocks of receiver %d, %v\n", receiverId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []models.AskerBlock{}
+	for rows.Next() {
+		var block models.AskerBlock
+		var expiresAt sql.NullTime
+		err := rows.Scan(&block.Id, &block.QuestionId, &block.QuestionSnippet, &block.CreatedAt, &expiresAt)
+		if err != nil {
+			log.Printf("Error scanning asker blocks of receiver %d, %v\n", receiverId, err)
+			return nil, err
+		}
+		if expiresAt.Valid {
+			block.ExpiresAt = &expiresAt.Time
+		}
+		block.IsPermanent = !expiresAt.Valid
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// RemoveAskerBlock returns false if the receiver has no such block
+func RemoveAskerBlock(receiverId int, blockId int, db Querier) (bool, error) {
+	result, err := db.Exec("DELETE FROM asker_block WHERE id = ? AND receiver_id = ?", blockId, receiverId)
+	if err != nil {
+		log.Printf("Error removing asker block %d of receiver %d, %v\n", blockId, receiverId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for asker block %d of receiver %d, %v\n", blockId, receiverId, err)
+		return false, err
+	}
+	return rows > 0, nil
+}
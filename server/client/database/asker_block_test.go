@@ -0,0 +1,111 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAddAskerBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("INSERT INTO asker_block").WithArgs(1, "hash", 2, nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	id, err := AddAskerBlock(1, "hash", 2, 0, db)
+	if err != nil || id != 1 {
+		t.Errorf("Expected a permanent block with id 1, got %d and %v", id, err)
+	}
+	mock.ExpectExec("INSERT INTO asker_block").WithArgs(1, "hash", 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+	id, err = AddAskerBlock(1, "hash", 2, 24, db)
+	if err != nil || id != 2 {
+		t.Errorf("Expected a temporary block with id 2, got %d and %v", id, err)
+	}
+	mock.ExpectExec("INSERT INTO asker_block").WithArgs(1, "hash", 3, nil).WillReturnError(errors.New("error"))
+	_, err = AddAskerBlock(1, "hash", 3, 0, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestCheckAskerBlockExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM asker_block WHERE receiver_id = \\? AND ip_hash = \\? AND \\(expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP\\)").
+		WithArgs(1, "hash").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	exists, err := CheckAskerBlockExists(1, "hash", db)
+	if err != nil || !exists {
+		t.Errorf("Expected the block to exist, got %t and %v", exists, err)
+	}
+	mock.ExpectQuery("SELECT COUNT(.+) FROM asker_block").WithArgs(1, "other").WillReturnError(errors.New("error"))
+	_, err = CheckAskerBlockExists(1, "other", db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetAskerBlocks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	expiresAt := time.Now().Add(time.Hour)
+	mock.ExpectQuery("SELECT asker_block.id, asker_block.question_id, question.text, asker_block.created_at, asker_block.expires_at FROM asker_block JOIN question").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "expires_at"}).
+			AddRow(2, 5, "question", time.Now(), expiresAt).
+			AddRow(1, 4, "other question", time.Now(), nil))
+	blocks, err := GetAskerBlocks(1, nil, 3, db)
+	if err != nil {
+		t.Errorf("Error while getting asker blocks: %s", err.Error())
+	}
+	if len(blocks) != 2 || blocks[0].QuestionSnippet != "question" || blocks[0].IsPermanent || blocks[0].ExpiresAt == nil {
+		t.Errorf("Expected a temporary block first, got %+v", blocks)
+	}
+	if len(blocks) == 2 && (!blocks[1].IsPermanent || blocks[1].ExpiresAt != nil) {
+		t.Errorf("Expected a permanent block second, got %+v", blocks[1])
+	}
+
+	mock.ExpectQuery("SELECT asker_block.id").WithArgs(1, 3).WillReturnError(errors.New("error"))
+	_, err = GetAskerBlocks(1, nil, 3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRemoveAskerBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM asker_block WHERE id = \\? AND receiver_id = \\?").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	removed, err := RemoveAskerBlock(1, 2, db)
+	if err != nil || !removed {
+		t.Errorf("Expected the block to be removed, got %t and %v", removed, err)
+	}
+	mock.ExpectExec("DELETE FROM asker_block").WithArgs(3, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	removed, err = RemoveAskerBlock(1, 3, db)
+	if err != nil || removed {
+		t.Errorf("Expected no block to be removed, got %t and %v", removed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
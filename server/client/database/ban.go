@@ -3,10 +3,12 @@ package database
 import (
 	"database/sql"
 	"log"
+	"project_truthful/models"
+	"strings"
 	"time"
 )
 
-func BanUser(userId int, requesterId int, duration int, reason string, db *sql.DB) (int64, error) {
+func BanUser(userId int, requesterId int, duration int, reason string, db Querier) (int64, error) {
 	var banId int64
 	if duration == 0 {
 		result, err := db.Exec("INSERT INTO ban (user_id, author_id, reason) VALUES (?, ?, ?)", userId, requesterId, reason)
@@ -20,7 +22,7 @@ func BanUser(userId int, requesterId int, duration int, reason string, db *sql.D
 			return 0, err
 		}
 	} else {
-		expiration := time.Now().Add(time.Duration(duration) * time.Hour)
+		expiration := time.Now().UTC().Add(time.Duration(duration) * time.Hour)
 		result, err := db.Exec("INSERT INTO ban (user_id, author_id, reason, expires_at) VALUES (?, ?, ?, ?)", userId, requesterId, reason, expiration)
 		if err != nil {
 			log.Printf("Error banning user %d, %v\n", userId, err)
@@ -35,9 +37,10 @@ func BanUser(userId int, requesterId int, duration int, reason string, db *sql.D
 	return banId, nil
 }
 
-func CheckUserBanStatus(userId int, db *sql.DB) (bool, error) {
+// a ban is active if it has not been pardoned and is either permanent (no expiration date) or not expired yet
+func CheckUserBanStatus(userId int, db Querier) (bool, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM ban WHERE user_id = ? AND expires_at > NOW()", userId).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM ban LEFT JOIN pardon ON pardon.ban_id = ban.id WHERE ban.user_id = ? AND pardon.id IS NULL AND (ban.expires_at IS NULL OR ban.expires_at > CURRENT_TIMESTAMP)", userId).Scan(&count)
 	if err != nil {
 		log.Printf("Error checking if user %d is banned, %v\n", userId, err)
 		return false, err
@@ -45,12 +48,132 @@ func CheckUserBanStatus(userId int, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func CheckBanExistsByBanId(banId int, db *sql.DB) (bool, error) {
+// GetActiveBan returns the active ban lasting the longest, permanent bans first
+func GetActiveBan(userId int, db Querier) (models.Ban, error) {
+	var ban models.Ban
+	var reason sql.NullString
+	var expiresAt sql.NullTime
+	err := db.QueryRow("SELECT ban.id, ban.reason, ban.created_at, ban.expires_at FROM ban LEFT JOIN pardon ON pardon.ban_id = ban.id WHERE ban.user_id = ? AND pardon.id IS NULL AND (ban.expires_at IS NULL OR ban.expires_at > CURRENT_TIMESTAMP) ORDER BY ban.expires_at IS NULL DESC, ban.expires_at DESC LIMIT 1", userId).Scan(&ban.Id, &reason, &ban.CreatedAt, &expiresAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting active ban for user %d, %v\n", userId, err)
+		return models.Ban{}, err
+	} else if err == sql.ErrNoRows {
+		return models.Ban{}, err
+	}
+	ban.Reason = reason.String
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	} else {
+		ban.IsPermanent = true
+	}
+	return ban, nil
+}
+
+// CheckBanExistsByBanId checks that the ban exists and is permanent or not expired yet, pardoned bans are found
+func CheckBanExistsByBanId(banId int, db Querier) (bool, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM ban WHERE id = ? AND expires_at > NOW()", banId).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM ban WHERE id = ? AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)", banId).Scan(&count)
 	if err != nil {
 		log.Printf("Error checking if ban %d exists, %v\n", banId, err)
 		return false, err
 	}
 	return count > 0, nil
 }
+
+// banStatusConditions select the bans joined with their pardon having each status
+var banStatusConditions = map[string]string{
+	models.BanStatusActive:   "pardon.id IS NULL AND (ban.expires_at IS NULL OR ban.expires_at > CURRENT_TIMESTAMP)",
+	models.BanStatusExpired:  "pardon.id IS NULL AND ban.expires_at <= CURRENT_TIMESTAMP",
+	models.BanStatusPardoned: "pardon.id IS NOT NULL",
+}
+
+const banDetailsQuery = "SELECT ban.id, ban.user_id, ban.author_id, ban.reason, ban.created_at, ban.expires_at, " +
+	"CASE WHEN pardon.id IS NOT NULL THEN 'pardoned' WHEN ban.expires_at IS NULL OR ban.expires_at > CURRENT_TIMESTAMP THEN 'active' ELSE 'expired' END, " +
+	"pardon.id, pardon.pardoner_id, pardon.created_at FROM ban LEFT JOIN pardon ON pardon.ban_id = ban.id"
+
+func scanBanDetails(scanner interface{ Scan(...any) error }) (models.BanDetails, error) {
+	var ban models.BanDetails
+	var reason sql.NullString
+	var expiresAt, pardonedAt sql.NullTime
+	var pardonId, pardonerId sql.NullInt64
+	err := scanner.Scan(&ban.Id, &ban.UserId, &ban.AuthorId, &reason, &ban.CreatedAt, &expiresAt, &ban.Status, &pardonId, &pardonerId, &pardonedAt)
+	if err != nil {
+		return models.BanDetails{}, err
+	}
+	ban.Reason = reason.String
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	} else {
+		ban.IsPermanent = true
+	}
+	if pardonId.Valid {
+		ban.Pardon = &models.Pardon{Id: int(pardonId.Int64), PardonerId: int(pardonerId.Int64), CreatedAt: pardonedAt.Time}
+	}
+	return ban, nil
+}
+
+// GetBan returns the ban with its pardon, sql.ErrNoRows when it doesn't exist
+func GetBan(banId int, db Querier) (models.BanDetails, error) {
+	ban, err := scanBanDetails(db.QueryRow(banDetailsQuery+" WHERE ban.id = ?", banId))
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting ban %d, %v\n", banId, err)
+	}
+	return ban, err
+}
+
+// GetBans returns the bans matching the filter after the cursor with their pardon, most recent first
+func GetBans(filter models.BanFilter, cursor *models.Cursor, count int, db Querier) ([]models.BanDetails, error) {
+	conditions := []string{}
+	args := []any{}
+	if filter.UserId != 0 {
+		conditions = append(conditions, "ban.user_id = ?")
+		args = append(args, filter.UserId)
+	}
+	if filter.AuthorId != 0 {
+		conditions = append(conditions, "ban.author_id = ?")
+		args = append(args, filter.AuthorId)
+	}
+	if condition, ok := banStatusConditions[filter.Status]; ok {
+		conditions = append(conditions, condition)
+	}
+	condition, pageArgs := pageCondition("ban", cursor)
+	if condition != "" {
+		conditions = append(conditions, strings.TrimPrefix(condition, " AND "))
+		args = append(args, pageArgs...)
+	}
+	query := banDetailsQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := db.Query(query+" ORDER BY ban.created_at DESC, ban.id DESC LIMIT ?", append(args, count)...)
+	if err != nil {
+		log.Printf("Error getting bans, %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []models.BanDetails{}
+	for rows.Next() {
+		ban, err := scanBanDetails(rows)
+		if err != nil {
+			log.Printf("Error scanning bans, %v\n", err)
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+// UpdateBanExpiration changes when the ban expires, a nil expiresAt makes it permanent
+func UpdateBanExpiration(banId int, expiresAt *time.Time, db Querier) error {
+	var expiration sql.NullTime
+	if expiresAt != nil {
+		expiration = sql.NullTime{Time: expiresAt.UTC(), Valid: true}
+	}
+	_, err := db.Exec("UPDATE ban SET expires_at = ? WHERE id = ?", expiration, banId)
+	if err != nil {
+		log.Printf("Error updating expiration of ban %d, %v\n", banId, err)
+		return err
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"database/sql"
 	"errors"
+	"project_truthful/models"
 	"testing"
 	"time"
 
@@ -26,7 +28,7 @@ func TestBanUserDbError(t *testing.T) {
 	}
 
 	// with duration
-	mock.ExpectExec("INSERT INTO ban").WithArgs(1, 1, "ban reason", time.Now().Add(time.Duration(1)*time.Hour)).WillReturnError(errors.New("error for db test"))
+	mock.ExpectExec("INSERT INTO ban").WithArgs(1, 1, "ban reason", sqlmock.AnyArg()).WillReturnError(errors.New("error for db test"))
 	_, err = BanUser(1, 1, 1, "ban reason", db)
 	if err == nil {
 		t.Errorf("expected error, got nil")
@@ -54,7 +56,7 @@ func TestBanUserLastInsertIdError(t *testing.T) {
 	}
 
 	// with duration
-	mock.ExpectExec("INSERT INTO ban").WithArgs(1, 1, "ban reason", time.Now().Add(time.Duration(1)*time.Hour)).WillReturnResult(sqlmock.NewErrorResult(errors.New("error for last insert id")))
+	mock.ExpectExec("INSERT INTO ban").WithArgs(1, 1, "ban reason", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewErrorResult(errors.New("error for last insert id")))
 	_, err = BanUser(1, 1, 1, "ban reason", db)
 	if err == nil {
 		t.Errorf("expected error, got nil")
@@ -82,7 +84,7 @@ func TestBanUserSuccess(t *testing.T) {
 	}
 
 	// with duration
-	mock.ExpectExec("INSERT INTO ban").WithArgs(1, 1, "ban reason", time.Now().Add(time.Duration(1)*time.Hour)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ban").WithArgs(1, 1, "ban reason", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 	_, err = BanUser(1, 1, 1, "ban reason", db)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
@@ -130,6 +132,55 @@ func TestCheckUserBanStatus(t *testing.T) {
 	}
 }
 
+func TestGetActiveBan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	columns := []string{"id", "reason", "created_at", "expires_at"}
+	createdAt := time.Now()
+
+	// permanent ban
+	mock.ExpectQuery("SELECT ban.id, ban.reason, ban.created_at, ban.expires_at FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).AddRow(4, "spam", createdAt, nil))
+	ban, err := GetActiveBan(1, db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if ban.Id != 4 || ban.Reason != "spam" || !ban.IsPermanent || ban.ExpiresAt != nil {
+		t.Errorf("unexpected ban %+v", ban)
+	}
+
+	// temporary ban without reason
+	expiresAt := createdAt.Add(time.Hour)
+	mock.ExpectQuery("SELECT ban.id, ban.reason, ban.created_at, ban.expires_at FROM ban").WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).AddRow(5, nil, createdAt, expiresAt))
+	ban, err = GetActiveBan(2, db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if ban.Id != 5 || ban.Reason != "" || ban.IsPermanent || ban.ExpiresAt == nil || !ban.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("unexpected ban %+v", ban)
+	}
+
+	// no active ban
+	mock.ExpectQuery("SELECT ban.id, ban.reason, ban.created_at, ban.expires_at FROM ban").WithArgs(3).WillReturnError(sql.ErrNoRows)
+	_, err = GetActiveBan(3, db)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	// db error
+	mock.ExpectQuery("SELECT ban.id, ban.reason, ban.created_at, ban.expires_at FROM ban").WithArgs(4).WillReturnError(errors.New("error"))
+	_, err = GetActiveBan(4, db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
 func TestCheckBanExistsByBanId(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -137,8 +188,8 @@ func TestCheckBanExistsByBanId(t *testing.T) {
 	}
 	defer db.Close()
 
-	// ban exists
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	// ban exists, permanent bans have no expiration date
+	mock.ExpectQuery("SELECT COUNT(.+) FROM ban WHERE id = (.+) AND \\(expires_at IS NULL OR").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	_, err = CheckBanExistsByBanId(1, db)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
@@ -167,3 +218,90 @@ func TestCheckBanExistsByBanId(t *testing.T) {
 		t.Errorf("expectations were not met: %s", err)
 	}
 }
+
+var banDetailsColumns = []string{"id", "user_id", "author_id", "reason", "created_at", "expires_at", "status", "pardon_id", "pardoner_id", "pardoned_at"}
+
+func TestGetBans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	now := time.Now()
+
+	// every filter, with a pardoned ban and a permanent one
+	rows := sqlmock.NewRows(banDetailsColumns).
+		AddRow(2, 1, 3, "spam", now, now.Add(time.Hour), "pardoned", 1, 3, now).
+		AddRow(1, 1, 3, nil, now, nil, "active", nil, nil, nil)
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon (.+) WHERE ban.user_id = (.+) AND ban.author_id = (.+) AND pardon.id IS NOT NULL AND \\(ban.created_at < (.+) ORDER BY ban.created_at DESC, ban.id DESC LIMIT").
+		WithArgs(1, 3, sqlmock.AnyArg(), sqlmock.AnyArg(), 5, 11).WillReturnRows(rows)
+	bans, err := GetBans(models.BanFilter{UserId: 1, AuthorId: 3, Status: "pardoned"}, &models.Cursor{CreatedAt: now, Id: 5}, 11, db)
+	if err != nil || len(bans) != 2 {
+		t.Fatalf("Expected 2 bans, got %+v, %v", bans, err)
+	}
+	if bans[0].Pardon == nil || bans[0].Pardon.PardonerId != 3 || bans[0].IsPermanent || bans[0].Status != "pardoned" {
+		t.Errorf("Expected a pardoned ban, got %+v", bans[0])
+	}
+	if bans[1].Pardon != nil || !bans[1].IsPermanent || bans[1].ExpiresAt != nil {
+		t.Errorf("Expected a permanent ban, got %+v", bans[1])
+	}
+
+	// no filter
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon ON pardon.ban_id = ban.id ORDER BY").WithArgs(10).WillReturnError(errors.New("error"))
+	_, err = GetBans(models.BanFilter{}, nil, 10, db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestGetBan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon (.+) WHERE ban.id = ").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(banDetailsColumns).AddRow(1, 2, 3, "spam", time.Now(), nil, "active", nil, nil, nil))
+	ban, err := GetBan(1, db)
+	if err != nil || ban.Id != 1 || ban.UserId != 2 || ban.AuthorId != 3 || ban.Status != "active" || !ban.IsPermanent {
+		t.Errorf("Unexpected ban %+v, %v", ban, err)
+	}
+
+	mock.ExpectQuery("FROM ban LEFT JOIN pardon").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	_, err = GetBan(2, db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestUpdateBanExpiration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	expiresAt := time.Now()
+
+	mock.ExpectExec("UPDATE ban SET expires_at = (.+) WHERE id = ").WithArgs(sql.NullTime{Time: expiresAt.UTC(), Valid: true}, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = UpdateBanExpiration(1, &expiresAt, db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// permanent
+	mock.ExpectExec("UPDATE ban SET expires_at").WithArgs(sql.NullTime{}, 1).WillReturnError(errors.New("error"))
+	err = UpdateBanExpiration(1, nil, db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"log"
+	"project_truthful/models"
+)
+
+func CheckBlockExists(blockerId int, blockedId int, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user_block WHERE blocker_id = ? AND blocked_id = ?", blockerId, blockedId).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking if block exists for blocker %d and blocked %d, %v\n", blockerId, blockedId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AddBlock blocks the user and removes the follows between both users, it returns false if the block already exists
+func AddBlock(blockerId int, blockedId int, db Querier) (bool, error) {
+	var rows int64
+	err := inTransaction(db, func(tx Querier) error {
+		result, err := tx.Exec(insertIgnore()+" INTO user_block (blocker_id, blocked_id) VALUES (?, ?)", blockerId, blockedId)
+		if err != nil {
+			log.Printf("Error inserting block for blocker %d and blocked %d, %v\n", blockerId, blockedId, err)
+			return err
+		}
+		rows, err = result.RowsAffected()
+		if err != nil {
+			log.Printf("Error getting affected rows for block of blocker %d and blocked %d, %v\n", blockerId, blockedId, err)
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM follow WHERE (follower = ? AND followed = ?) OR (follower = ? AND followed = ?)", blockerId, blockedId, blockedId, blockerId)
+		if err != nil {
+			log.Printf("Error deleting follows between users %d and %d, %v\n", blockerId, blockedId, err)
+			return err
+		}
+		err = removeFollowFromTimeline(blockerId, blockedId, tx)
+		if err != nil {
+			return err
+		}
+		return removeFollowFromTimeline(blockedId, blockerId, tx)
+	})
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// RemoveBlock returns false if there was no block to remove
+func RemoveBlock(blockerId int, blockedId int, db Querier) (bool, error) {
+	result, err := db.Exec("DELETE FROM user_block WHERE blocker_id = ? AND blocked_id = ?", blockerId, blockedId)
+	if err != nil {
+		log.Printf("Error deleting block for blocker %d and blocked %d, %v\n", blockerId, blockedId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for unblock of blocker %d and blocked %d, %v\n", blockerId, blockedId, err)
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// GetBlockedUsers returns the users blocked by the user after the cursor, most recent block first
+func GetBlockedUsers(userId int, cursor *models.Cursor, count int, db Querier) ([]models.RelatedUser, error) {
+	return getRelatedUsers("user_block", "blocker_id", "blocked_id", userId, cursor, count, db)
+}
+
+// getRelatedUsers lists the users in the related column of the rows of the table whose user column is the user
+func getRelatedUsers(table string, userColumn string, relatedColumn string, userId int, cursor *models.Cursor, count int, db Querier) ([]models.RelatedUser, error) {
+	condition, args := pageCondition(table, cursor)
+	args = append([]any{userId}, append(args, count)...)
+	rows, err := db.Query("SELECT "+table+".id, "+table+".created_at, user.id, user.username, user.display_name "+
+		"FROM "+table+" JOIN user ON user.id = "+table+"."+relatedColumn+" WHERE "+table+"."+userColumn+" = ?"+
+		condition+" ORDER BY "+table+".created_at DESC, "+table+".id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("Error getting %s list of user %d, %v\n", table, userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.RelatedUser{}
+	for rows.Next() {
+		var user models.RelatedUser
+		err := rows.Scan(&user.RelationId, &user.Since, &user.Id, &user.Username, &user.DisplayName)
+		if err != nil {
+			log.Printf("Error scanning %s list of user %d, %v\n", table, userId, err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckBlockExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	exists, err := CheckBlockExists(1, 2, db)
+	if err != nil {
+		t.Errorf("Error while checking if block exists: %s", err.Error())
+	}
+	if !exists {
+		t.Errorf("Block should exist")
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 3).WillReturnError(errors.New("error"))
+	_, err = CheckBlockExists(1, 3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestAddBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	// the follows between both users are removed with the block
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2, 2, 1).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM timeline_entry WHERE user_id = \\? AND answerer_id = \\?").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM timeline_entry WHERE user_id = \\? AND answerer_id = \\?").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	created, err := AddBlock(1, 2, db)
+	if err != nil {
+		t.Errorf("Error while adding block: %s", err.Error())
+	}
+	if !created {
+		t.Errorf("Block should be created")
+	}
+
+	// the block already exists
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2, 2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	created, err = AddBlock(1, 2, db)
+	if err != nil {
+		t.Errorf("Error while adding block: %s", err.Error())
+	}
+	if created {
+		t.Errorf("Block should not be created twice")
+	}
+
+	// the block is rolled back when the follows can't be removed
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_block").WithArgs(1, 3).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 3, 3, 1).WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	_, err = AddBlock(1, 3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRemoveBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	removed, err := RemoveBlock(1, 2, db)
+	if err != nil || !removed {
+		t.Errorf("Expected the block to be removed, got %t and %v", removed, err)
+	}
+	mock.ExpectExec("DELETE FROM user_block").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	removed, err = RemoveBlock(1, 2, db)
+	if err != nil || removed {
+		t.Errorf("Expected no block to remove, got %t and %v", removed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetBlockedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	now := time.Now()
+	cursor := &models.Cursor{CreatedAt: now, Id: 5}
+	mock.ExpectQuery("SELECT user_block.id, user_block.created_at, user.id, user.username, user.display_name FROM user_block JOIN user ON user.id = user_block.blocked_id WHERE user_block.blocker_id = \\?").
+		WithArgs(1, now.UTC(), now.UTC(), 5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "id", "username", "display_name"}).AddRow(4, now, 2, "titi", "Titi"))
+	users, err := GetBlockedUsers(1, cursor, 2, db)
+	if err != nil {
+		t.Errorf("Error while getting blocked users: %s", err.Error())
+	}
+	if len(users) != 1 || users[0].Username != "titi" || users[0].RelationId != 4 {
+		t.Errorf("Expected titi, got %+v", users)
+	}
+
+	mock.ExpectQuery("SELECT user_block.id").WithArgs(1, 2).WillReturnError(errors.New("error"))
+	_, err = GetBlockedUsers(1, nil, 2, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -4,24 +4,48 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"project_truthful/dialect"
+	"project_truthful/migrations"
 
 	"github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
 var DB *sql.DB
 
-func Init() (*sql.DB, error) {
-	log.Println("Connecting to db...")
-	cfg := mysql.Config{
-		User:                 os.Getenv("DB_USER"),
-		Passwd:               os.Getenv("DB_PASSWORD"),
-		Net:                  "tcp",
-		Addr:                 os.Getenv("DB_CONTAINER_NAME") + ":" + os.Getenv("DB_PORT"),
-		DBName:               os.Getenv("DB_NAME"),
-		AllowNativePasswords: true,
-		ParseTime:            true,
+// Dialect is the SQL dialect spoken by the database opened by Init
+var Dialect = dialect.MySQL
+
+// default file used by the SQLite database when DB_PATH is not set
+const defaultSQLitePath = "truthful.db"
+
+type initOptions struct {
+	requireUpToDateSchema bool
+}
+
+type InitOption func(*initOptions)
+
+// RequireUpToDateSchema makes Init fail when some migrations are not applied yet
+func RequireUpToDateSchema() InitOption {
+	return func(options *initOptions) {
+		options.requireUpToDateSchema = true
+	}
+}
+
+func Init(opts ...InitOption) (*sql.DB, error) {
+	var options initOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	d, err := dialect.FromEnv()
+	if err != nil {
+		log.Printf("SQL database driver error, %v\n", err)
+		return nil, err
 	}
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+
+	log.Printf("Connecting to %s db...\n", d)
+	db, err := open(d)
 	if err != nil {
 		log.Printf("SQL database open error, %v\n", err)
 		return nil, err
@@ -32,5 +56,37 @@ func Init() (*sql.DB, error) {
 		return nil, err
 	}
 	log.Println("Connected!")
+	Dialect = d
+
+	if options.requireUpToDateSchema {
+		err = migrations.CheckUpToDate(db, d)
+		if err != nil {
+			log.Printf("SQL database schema check error, %v\n", err)
+			db.Close()
+			return nil, err
+		}
+	}
 	return db, nil
 }
+
+func open(d dialect.Dialect) (*sql.DB, error) {
+	if d == dialect.SQLite {
+		path := os.Getenv("DB_PATH")
+		if path == "" {
+			path = defaultSQLitePath
+		}
+		// foreign keys are off by default in SQLite, times are stored as text in UTC so that they compare with CURRENT_TIMESTAMP
+		return sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite")
+	}
+
+	cfg := mysql.Config{
+		User:                 os.Getenv("DB_USER"),
+		Passwd:               os.Getenv("DB_PASSWORD"),
+		Net:                  "tcp",
+		Addr:                 os.Getenv("DB_CONTAINER_NAME") + ":" + os.Getenv("DB_PORT"),
+		DBName:               os.Getenv("DB_NAME"),
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	}
+	return sql.Open("mysql", cfg.FormatDSN())
+}
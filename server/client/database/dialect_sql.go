@@ -0,0 +1,22 @@
+package database
+
+import (
+	"project_truthful/dialect"
+)
+
+// insertIgnore starts an INSERT skipping the rows breaking a unique constraint, RowsAffected tells if the row was inserted
+func insertIgnore() string {
+	if Dialect == dialect.SQLite {
+		return "INSERT OR IGNORE"
+	}
+	return "INSERT IGNORE"
+}
+
+// timeParameter is the placeholder of a time compared with a timestamp column.
+// SQLite stores timestamps as text, datetime() brings the parameter to the format of CURRENT_TIMESTAMP
+func timeParameter() string {
+	if Dialect == dialect.SQLite {
+		return "datetime(?)"
+	}
+	return "?"
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+	"time"
+)
+
+func AddEmailVerificationToken(userId int, email string, tokenHash string, expiresAt time.Time, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO email_verification_token (user_id, email, token_hash, expires_at) VALUES (?, ?, ?, ?)", userId, email, tokenHash, expiresAt.UTC())
+	if err != nil {
+		log.Printf("Error inserting email verification token for user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// CountEmailVerificationTokens counts the verification emails sent to the user since the given time
+func CountEmailVerificationTokens(userId int, since time.Time, db Querier) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM email_verification_token WHERE user_id = ? AND created_at > "+timeParameter(), userId, since.UTC()).Scan(&count)
+	if err != nil {
+		log.Printf("Error counting email verification tokens of user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetEmailVerificationToken returns sql.ErrNoRows when no token has the hash
+func GetEmailVerificationToken(tokenHash string, db Querier) (models.EmailVerificationToken, error) {
+	var verificationToken models.EmailVerificationToken
+	err := db.QueryRow("SELECT id, user_id, email, expires_at, used_at IS NOT NULL FROM email_verification_token WHERE token_hash = ?", tokenHash).Scan(&verificationToken.Id, &verificationToken.UserId, &verificationToken.Email, &verificationToken.ExpiresAt, &verificationToken.IsUsed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting email verification token, %v\n", err)
+		return models.EmailVerificationToken{}, err
+	}
+	return verificationToken, err
+}
+
+// GetPendingEmail returns the address of the last token of the user which can still be used, sql.ErrNoRows when there is none
+func GetPendingEmail(userId int, db Querier) (string, error) {
+	var email string
+	err := db.QueryRow("SELECT email FROM email_verification_token WHERE user_id = ? AND used_at IS NULL AND expires_at > "+timeParameter()+" ORDER BY created_at DESC, id DESC LIMIT 1", userId, time.Now().UTC()).Scan(&email)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting pending email of user %d, %v\n", userId, err)
+		return "", err
+	}
+	return email, err
+}
+
+// UseEmailVerificationToken returns false if the token had already been used by a concurrent request
+func UseEmailVerificationToken(id int, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE email_verification_token SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL", id)
+	if err != nil {
+		log.Printf("Error using email verification token %d, %v\n", id, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for email verification token %d, %v\n", id, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RevokeEmailVerificationTokens marks every unused token of the user as used
+func RevokeEmailVerificationTokens(userId int, db Querier) error {
+	_, err := db.Exec("UPDATE email_verification_token SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND used_at IS NULL", userId)
+	if err != nil {
+		log.Printf("Error revoking email verification tokens of user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUserEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT email, email_verified FROM user WHERE id = ").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", false))
+	email, verified, err := GetUserEmail(2, db)
+	if err != nil || email != "toto@toto.fr" || verified {
+		t.Errorf("Expected the unverified email, got %s, %t, %v", email, verified, err)
+	}
+	mock.ExpectQuery("SELECT email, email_verified FROM user WHERE id = ").WithArgs(3).WillReturnError(sql.ErrNoRows)
+	_, _, err = GetUserEmail(3, db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	mock.ExpectExec("UPDATE user SET email = (.+), email_verified = 1 WHERE id = ").WithArgs("titi@titi.fr", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = SetEmailVerified(2, "titi@titi.fr", db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestEmailVerificationTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	since := time.Now().UTC()
+
+	mock.ExpectExec("INSERT INTO email_verification_token").WithArgs(2, "titi@titi.fr", "hash", expiresAt).WillReturnResult(sqlmock.NewResult(1, 1))
+	id, err := AddEmailVerificationToken(2, "titi@titi.fr", "hash", expiresAt, db)
+	if err != nil || id != 1 {
+		t.Errorf("Expected token 1, got %d, %v", id, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM email_verification_token WHERE user_id = (.+) AND created_at > ").WithArgs(2, since).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	count, err := CountEmailVerificationTokens(2, since, db)
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 token, got %d, %v", count, err)
+	}
+
+	mock.ExpectQuery("SELECT id, user_id, email, expires_at, used_at IS NOT NULL FROM email_verification_token WHERE token_hash = ").WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "email", "expires_at", "used"}).AddRow(1, 2, "titi@titi.fr", expiresAt, false))
+	verificationToken, err := GetEmailVerificationToken("hash", db)
+	if err != nil || verificationToken.UserId != 2 || verificationToken.Email != "titi@titi.fr" || verificationToken.IsUsed || !verificationToken.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Unexpected token %+v, %v", verificationToken, err)
+	}
+	mock.ExpectQuery("FROM email_verification_token WHERE token_hash = ").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	_, err = GetEmailVerificationToken("unknown", db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT email FROM email_verification_token WHERE user_id = (.+) AND used_at IS NULL AND expires_at > (.+) ORDER BY created_at DESC").WithArgs(2, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("titi@titi.fr"))
+	pendingEmail, err := GetPendingEmail(2, db)
+	if err != nil || pendingEmail != "titi@titi.fr" {
+		t.Errorf("Expected the pending email, got %s, %v", pendingEmail, err)
+	}
+	mock.ExpectQuery("SELECT email FROM email_verification_token").WithArgs(3, sqlmock.AnyArg()).WillReturnError(errors.New("error"))
+	_, err = GetPendingEmail(3, db)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+
+	// a token can only be used once
+	mock.ExpectExec("UPDATE email_verification_token SET used_at = CURRENT_TIMESTAMP WHERE id = (.+) AND used_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE email_verification_token SET used_at = CURRENT_TIMESTAMP WHERE id = (.+) AND used_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	used, err := UseEmailVerificationToken(1, db)
+	if err != nil || !used {
+		t.Errorf("Expected the token to be used, got %t, %v", used, err)
+	}
+	used, err = UseEmailVerificationToken(1, db)
+	if err != nil || used {
+		t.Errorf("Expected the token to be used already, got %t, %v", used, err)
+	}
+
+	mock.ExpectExec("UPDATE email_verification_token SET used_at = CURRENT_TIMESTAMP WHERE user_id = (.+) AND used_at IS NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	err = RevokeEmailVerificationTokens(2, db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
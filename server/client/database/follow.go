@@ -1,11 +1,11 @@
 package database
 
 import (
-	"database/sql"
 	"log"
+	"project_truthful/models"
 )
 
-func CheckFollowExists(follower int, followed int, db *sql.DB) (bool, error) {
+func CheckFollowExists(follower int, followed int, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM follow WHERE follower = ? AND followed = ?", follower, followed).Scan(&count)
 	if err != nil {
@@ -15,20 +15,79 @@ func CheckFollowExists(follower int, followed int, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func AddFollow(followerId int, followedId int, db *sql.DB) error {
-	_, err := db.Exec("INSERT INTO follow (follower, followed) VALUES (?, ?)", followerId, followedId)
+// AddFollow returns false if the follow already exists, e.g. when inserted by a concurrent request.
+// The answers of the followed user are added to the timeline of the follower
+func AddFollow(followerId int, followedId int, db Querier) (bool, error) {
+	var rows int64
+	err := inTransaction(db, func(tx Querier) error {
+		result, err := tx.Exec(insertIgnore()+" INTO follow (follower, followed) VALUES (?, ?)", followerId, followedId)
+		if err != nil {
+			log.Printf("Error inserting follow for follower %d and followed %d, %v\n", followerId, followedId, err)
+			return err
+		}
+		rows, err = result.RowsAffected()
+		if err != nil {
+			log.Printf("Error getting affected rows for follow of follower %d and followed %d, %v\n", followerId, followedId, err)
+			return err
+		}
+		if rows == 0 {
+			return nil
+		}
+		return addFollowToTimeline(followerId, followedId, tx)
+	})
 	if err != nil {
-		log.Printf("Error inserting follow for follower %d and followed %d, %v\n", followerId, followedId, err)
-		return err
+		return false, err
 	}
-	return nil
+	return rows > 0, nil
+}
+
+// RemoveFollow also removes the answers of the followed user from the timeline of the follower
+func RemoveFollow(followerId int, followedId int, db Querier) error {
+	return inTransaction(db, func(tx Querier) error {
+		_, err := tx.Exec("DELETE FROM follow WHERE follower = ? AND followed = ?", followerId, followedId)
+		if err != nil {
+			log.Printf("Error deleting follow for follower %d and followed %d, %v\n", followerId, followedId, err)
+			return err
+		}
+		return removeFollowFromTimeline(followerId, followedId, tx)
+	})
+}
+
+// GetFollowers returns the users following the user after the cursor, most recent follow first
+func GetFollowers(userId int, requestingUser int, cursor *models.Cursor, count int, db Querier) ([]models.FollowPreview, error) {
+	return getFollowList("followed", "follower", userId, requestingUser, cursor, count, db)
 }
 
-func RemoveFollow(followerId int, followedId int, db *sql.DB) error {
-	_, err := db.Exec("DELETE FROM follow WHERE follower = ? AND followed = ?", followerId, followedId)
+// GetFollowing returns the users followed by the user after the cursor, most recent follow first
+func GetFollowing(userId int, requestingUser int, cursor *models.Cursor, count int, db Querier) ([]models.FollowPreview, error) {
+	return getFollowList("follower", "followed", userId, requestingUser, cursor, count, db)
+}
+
+// getFollowList lists the users in the listed column of the follows whose user column is the user
+func getFollowList(userColumn string, listedColumn string, userId int, requestingUser int, cursor *models.Cursor, count int, db Querier) ([]models.FollowPreview, error) {
+	condition, args := pageCondition("follow", cursor)
+	args = append([]any{requestingUser, requestingUser, userId}, append(args, count)...)
+	rows, err := db.Query("SELECT follow.id, follow.created_at, user.id, user.username, user.display_name, "+
+		"EXISTS (SELECT 1 FROM follow requester WHERE requester.follower = ? AND requester.followed = user.id), "+
+		"EXISTS (SELECT 1 FROM follow requester WHERE requester.follower = user.id AND requester.followed = ?) "+
+		"FROM follow JOIN user ON user.id = follow."+listedColumn+" WHERE follow."+userColumn+" = ?"+
+		condition+" ORDER BY follow.created_at DESC, follow.id DESC LIMIT ?", args...)
 	if err != nil {
-		log.Printf("Error deleting follow for follower %d and followed %d, %v\n", followerId, followedId, err)
-		return err
+		log.Printf("Error getting %s list of user %d, %v\n", listedColumn, userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.FollowPreview{}
+	for rows.Next() {
+		var user models.FollowPreview
+		err := rows.Scan(&user.FollowId, &user.FollowedAt, &user.Id, &user.Username, &user.DisplayName, &user.FollowedByRequester, &user.FollowsRequester)
+		if err != nil {
+			log.Printf("Error scanning %s list of user %d, %v\n", listedColumn, userId, err)
+			return nil, err
+		}
+		user.IsMutual = user.FollowedByRequester && user.FollowsRequester
+		users = append(users, user)
 	}
-	return nil
+	return users, nil
 }
@@ -2,7 +2,9 @@ package database
 
 import (
 	"errors"
+	"project_truthful/models"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -53,17 +55,35 @@ func TestAddFollow(t *testing.T) {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
-	mock.ExpectExec("INSERT INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
-	err = AddFollow(1, 2, db)
+	// the answers of the followed user are added to the timeline of the follower
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT IGNORE INTO timeline_entry (.+) FROM answer WHERE answer.user_id = \\? AND answer.has_been_deleted = 0").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	created, err := AddFollow(1, 2, db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
 	if err != nil {
 		t.Errorf("Error while adding follow: %s", err.Error())
 	}
+	if !created {
+		t.Errorf("Follow should be created")
+	}
+
+	// the follow already exists
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	created, err = AddFollow(1, 2, db)
+	if err != nil || created {
+		t.Errorf("Follow should not be created, got %t, %v", created, err)
+	}
 
-	mock.ExpectExec("INSERT INTO follow").WithArgs(1, 3).WillReturnError(errors.New("error"))
-	err = AddFollow(1, 3, db)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WithArgs(1, 3).WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	_, err = AddFollow(1, 3, db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
@@ -78,7 +98,11 @@ func TestRemoveFollow(t *testing.T) {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
+	// the answers of the followed user are removed from the timeline of the follower
+	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM timeline_entry WHERE user_id = \\? AND answerer_id = \\?").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
 	err = RemoveFollow(1, 2, db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -87,7 +111,9 @@ func TestRemoveFollow(t *testing.T) {
 		t.Errorf("Error while removing follow: %s", err.Error())
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 3).WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
 	err = RemoveFollow(1, 3, db)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -96,3 +122,58 @@ func TestRemoveFollow(t *testing.T) {
 		t.Errorf("Error should not be nil")
 	}
 }
+
+func TestGetFollowers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	followedAt := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	columns := []string{"id", "created_at", "id", "username", "display_name", "followed_by_requester", "follows_requester"}
+	rows := sqlmock.NewRows(columns).
+		AddRow(5, followedAt, 2, "titi", "Titi", 1, 1).
+		AddRow(4, followedAt, 3, "tata", "Tata", 0, 1)
+	mock.ExpectQuery("SELECT follow.id, follow.created_at, user.id, user.username, user.display_name, (.+) FROM follow JOIN user ON user.id = follow.follower WHERE follow.followed = \\? ORDER BY follow.created_at DESC, follow.id DESC LIMIT \\?").
+		WithArgs(3, 3, 1, 11).WillReturnRows(rows)
+	users, err := GetFollowers(1, 3, nil, 11, db)
+	if err != nil {
+		t.Fatalf("Error while getting followers: %s", err.Error())
+	}
+	if len(users) != 2 || users[0].Username != "titi" || !users[0].IsMutual || users[0].FollowId != 5 {
+		t.Errorf("Unexpected followers %+v", users)
+	}
+	if users[1].FollowedByRequester || !users[1].FollowsRequester || users[1].IsMutual {
+		t.Errorf("Unexpected follower %+v", users[1])
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM follow JOIN user ON user.id = follow.follower WHERE follow.followed = \\? AND \\(follow.created_at < \\? OR").
+		WithArgs(3, 3, 1, followedAt, followedAt, 4, 11).WillReturnError(errors.New("error"))
+	_, err = GetFollowers(1, 3, &models.Cursor{CreatedAt: followedAt, Id: 4}, 11, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetFollowing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "created_at", "id", "username", "display_name", "followed_by_requester", "follows_requester"}).
+		AddRow(5, time.Now(), 2, "titi", "Titi", 0, 0)
+	mock.ExpectQuery("SELECT (.+) FROM follow JOIN user ON user.id = follow.followed WHERE follow.follower = \\?").WithArgs(0, 0, 1, 11).WillReturnRows(rows)
+	users, err := GetFollowing(1, 0, nil, 11, db)
+	if err != nil || len(users) != 1 || users[0].Id != 2 || users[0].FollowedByRequester {
+		t.Errorf("Unexpected following %+v, %v", users, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
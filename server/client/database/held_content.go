@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"project_truthful/models"
+)
+
+// AddHeldContent queues the content for review, its reasons are stored as a JSON array
+func AddHeldContent(content models.HeldContent, db Querier) (int64, error) {
+	reasons, err := json.Marshal(content.Reasons)
+	if err != nil {
+		log.Printf("Error encoding reasons of held %s, %v\n", content.ContentType, err)
+		return 0, err
+	}
+	result, err := db.Exec("INSERT INTO held_content (content_type, text, author_id, author_ip_address, is_author_anonymous, receiver_id, question_id, score, reasons) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		content.ContentType, content.Text, nullableId(content.AuthorId), content.AuthorIpAddress, content.IsAuthorAnonymous, content.ReceiverId, nullableId(content.QuestionId), content.Score, string(reasons))
+	if err != nil {
+		log.Printf("Error inserting held %s for receiver %d, %v\n", content.ContentType, content.ReceiverId, err)
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting held content ID, %v\n", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+const heldContentColumns = "held_content.id, held_content.content_type, held_content.text, held_content.author_id, held_content.author_ip_address, held_content.is_author_anonymous, " +
+	"held_content.receiver_id, held_content.question_id, held_content.score, held_content.reasons, held_content.status, held_content.created_at"
+
+func scanHeldContent(scanner interface{ Scan(...any) error }) (models.HeldContent, error) {
+	var content models.HeldContent
+	var authorId, questionId sql.NullInt64
+	var reasons string
+	err := scanner.Scan(&content.Id, &content.ContentType, &content.Text, &authorId, &content.AuthorIpAddress, &content.IsAuthorAnonymous,
+		&content.ReceiverId, &questionId, &content.Score, &reasons, &content.Status, &content.CreatedAt)
+	if err != nil {
+		return models.HeldContent{}, err
+	}
+	content.AuthorId = int(authorId.Int64)
+	content.QuestionId = int(questionId.Int64)
+	err = json.Unmarshal([]byte(reasons), &content.Reasons)
+	if err != nil {
+		return models.HeldContent{}, err
+	}
+	return content, nil
+}
+
+func GetHeldContent(heldId int, db Querier) (models.HeldContent, error) {
+	content, err := scanHeldContent(db.QueryRow("SELECT "+heldContentColumns+" FROM held_content WHERE id = ?", heldId))
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting held content %d, %v\n", heldId, err)
+	}
+	return content, err
+}
+
+// GetPendingHeldContents returns the contents waiting for a review after the cursor, most recent first
+func GetPendingHeldContents(cursor *models.Cursor, count int, db Querier) ([]models.HeldContent, error) {
+	condition, args := pageCondition("held_content", cursor)
+	args = append(append([]any{models.HeldPending}, args...), count)
+	rows, err := db.Query("SELECT "+heldContentColumns+" FROM held_content WHERE held_content.status = ?"+
+		condition+" ORDER BY held_content.created_at DESC, held_content.id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("Error getting pending held contents, %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	contents := []models.HeldContent{}
+	for rows.Next() {
+		content, err := scanHeldContent(rows)
+		if err != nil {
+			log.Printf("Error scanning pending held contents, %v\n", err)
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return contents, nil
+}
+
+// CheckPendingHeldAnswer tells if an answer to the question is waiting for a review
+func CheckPendingHeldAnswer(questionId int, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM held_content WHERE question_id = ? AND status = ?", questionId, models.HeldPending).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking held answers of question %d, %v\n", questionId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ReviewHeldContent records the decision of the moderator, it returns false if the content was already reviewed
+func ReviewHeldContent(heldId int, status string, reviewerId int, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE held_content SET status = ?, reviewed_by = ?, reviewed_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?", status, reviewerId, heldId, models.HeldPending)
+	if err != nil {
+		log.Printf("Error reviewing held content %d, %v\n", heldId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for review of held content %d, %v\n", heldId, err)
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// SetHeldContentId links the approved content to the question or the answer it was published as
+func SetHeldContentId(heldId int, contentId int, db Querier) error {
+	_, err := db.Exec("UPDATE held_content SET content_id = ? WHERE id = ?", contentId, heldId)
+	if err != nil {
+		log.Printf("Error setting content id of held content %d, %v\n", heldId, err)
+		return err
+	}
+	return nil
+}
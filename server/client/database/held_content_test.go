@@ -0,0 +1,152 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var heldContentRows = []string{"id", "content_type", "text", "author_id", "author_ip_address", "is_author_anonymous",
+	"receiver_id", "question_id", "score", "reasons", "status", "created_at"}
+
+func TestAddHeldContent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	content := models.HeldContent{ContentType: "question", Text: "see https://example.com", IsAuthorAnonymous: true, AuthorIpAddress: "ip_address",
+		ReceiverId: 2, Score: 50, Reasons: []string{"links: https://example.com"}}
+	// guests and questions are stored as NULL
+	mock.ExpectExec("INSERT INTO held_content").
+		WithArgs("question", "see https://example.com", nil, "ip_address", true, 2, nil, 50, `["links: https://example.com"]`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	id, err := AddHeldContent(content, db)
+	if err != nil || id != 1 {
+		t.Errorf("Expected id 1, got %d and %v", id, err)
+	}
+
+	mock.ExpectExec("INSERT INTO held_content").WillReturnError(errors.New("error"))
+	_, err = AddHeldContent(content, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetHeldContent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	now := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM held_content WHERE id = \\?").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(heldContentRows).AddRow(1, "answer", "text", 2, "ip_address", false, 2, 3, 50, `["links: a.co"]`, "pending", now))
+	content, err := GetHeldContent(1, db)
+	if err != nil {
+		t.Errorf("Error while getting held content: %s", err.Error())
+	}
+	if content.AuthorId != 2 || content.QuestionId != 3 || len(content.Reasons) != 1 || content.Status != models.HeldPending {
+		t.Errorf("Unexpected held content %+v", content)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM held_content").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(heldContentRows).AddRow(2, "question", "text", nil, "ip_address", true, 2, nil, 50, `[]`, "pending", now))
+	content, err = GetHeldContent(2, db)
+	if err != nil || content.AuthorId != 0 || content.QuestionId != 0 {
+		t.Errorf("Expected no author and no question, got %+v and %v", content, err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM held_content").WithArgs(3).WillReturnError(errors.New("error"))
+	_, err = GetHeldContent(3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetPendingHeldContents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	now := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM held_content WHERE held_content.status = \\? ORDER BY (.+) LIMIT \\?").WithArgs("pending", 11).
+		WillReturnRows(sqlmock.NewRows(heldContentRows).AddRow(2, "question", "text", nil, "ip_address", true, 2, nil, 50, `[]`, "pending", now))
+	contents, err := GetPendingHeldContents(nil, 11, db)
+	if err != nil || len(contents) != 1 {
+		t.Errorf("Expected 1 held content, got %v and %v", contents, err)
+	}
+
+	cursor := &models.Cursor{CreatedAt: now, Id: 2}
+	mock.ExpectQuery("SELECT (.+) FROM held_content WHERE held_content.status = \\? AND (.+) LIMIT \\?").
+		WithArgs("pending", sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 11).WillReturnError(errors.New("error"))
+	_, err = GetPendingHeldContents(cursor, 11, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestCheckPendingHeldAnswer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM held_content WHERE question_id = \\? AND status = \\?").WithArgs(1, "pending").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	pending, err := CheckPendingHeldAnswer(1, db)
+	if err != nil || !pending {
+		t.Errorf("Expected a pending answer, got %v and %v", pending, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM held_content").WithArgs(2, "pending").WillReturnError(errors.New("error"))
+	_, err = CheckPendingHeldAnswer(2, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestReviewHeldContent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("UPDATE held_content SET status = \\?, reviewed_by = \\?, reviewed_at = CURRENT_TIMESTAMP WHERE id = \\? AND status = \\?").
+		WithArgs("approved", 3, 1, "pending").WillReturnResult(sqlmock.NewResult(0, 1))
+	reviewed, err := ReviewHeldContent(1, "approved", 3, db)
+	if err != nil || !reviewed {
+		t.Errorf("Expected the content to be reviewed, got %v and %v", reviewed, err)
+	}
+
+	mock.ExpectExec("UPDATE held_content SET status").WithArgs("rejected", 3, 1, "pending").WillReturnResult(sqlmock.NewResult(0, 0))
+	reviewed, err = ReviewHeldContent(1, "rejected", 3, db)
+	if err != nil || reviewed {
+		t.Errorf("Expected the content to be already reviewed, got %v and %v", reviewed, err)
+	}
+
+	mock.ExpectExec("UPDATE held_content SET content_id = \\? WHERE id = \\?").WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = SetHeldContentId(1, 5, db)
+	if err != nil {
+		t.Errorf("Error while setting content id: %s", err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
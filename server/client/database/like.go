@@ -1,11 +1,10 @@
 package database
 
 import (
-	"database/sql"
 	"log"
 )
 
-func CheckLikeExists(userId int, postId int, db *sql.DB) (bool, error) {
+func CheckLikeExists(userId int, postId int, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM answer_like WHERE user_id = ? AND answer_id = ?", userId, postId).Scan(&count)
 	if err != nil {
@@ -15,7 +14,7 @@ func CheckLikeExists(userId int, postId int, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func RemoveLike(userId int, postId int, db *sql.DB) error {
+func RemoveLike(userId int, postId int, db Querier) error {
 	_, err := db.Exec("DELETE FROM answer_like WHERE user_id = ? AND answer_id = ?", userId, postId)
 	if err != nil {
 		log.Printf("Error deleting like for user %d and post %d, %v\n", userId, postId, err)
@@ -24,7 +23,7 @@ func RemoveLike(userId int, postId int, db *sql.DB) error {
 	return nil
 }
 
-func AddLike(userId int, postId int, db *sql.DB) error {
+func AddLike(userId int, postId int, db Querier) error {
 	_, err := db.Exec("INSERT INTO answer_like (user_id, answer_id) VALUES (?, ?)", userId, postId)
 	if err != nil {
 		log.Printf("Error inserting like for user %d and post %d, %v\n", userId, postId, err)
@@ -33,7 +32,7 @@ func AddLike(userId int, postId int, db *sql.DB) error {
 	return nil
 }
 
-func GetLikeCountForAnswer(answerId int, db *sql.DB) (int, error) {
+func GetLikeCountForAnswer(answerId int, db Querier) (int, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM answer_like WHERE answer_id = ?", answerId).Scan(&count)
 	if err != nil {
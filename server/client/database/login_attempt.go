@@ -0,0 +1,115 @@
+package database
+
+import (
+	"log"
+	"project_truthful/models"
+	"time"
+)
+
+// AddLoginAttempt records a login, UserId is 0 when the username matched no account
+func AddLoginAttempt(attempt models.LoginAttempt, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO login_attempt (username, user_id, ip_address, user_agent, method, success) VALUES (?, ?, ?, ?, ?, ?)",
+		attempt.Username, nullableId(attempt.UserId), attempt.IpAddress, attempt.UserAgent, attempt.Method, attempt.Success)
+	if err != nil {
+		log.Printf("Error recording login attempt for username %s, %v\n", attempt.Username, err)
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// FinishLoginAttempt sets the account and the result of a login recorded before its credentials were checked
+func FinishLoginAttempt(id int, userId int, success bool, db Querier) error {
+	_, err := db.Exec("UPDATE login_attempt SET user_id = ?, success = ? WHERE id = ?", nullableId(userId), success, id)
+	if err != nil {
+		log.Printf("Error finishing login attempt %d, %v\n", id, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteLoginAttempt forgets a login recorded before its credentials were checked, when it ended up being neither a failure nor a login
+func DeleteLoginAttempt(id int, db Querier) error {
+	_, err := db.Exec("DELETE FROM login_attempt WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting login attempt %d, %v\n", id, err)
+		return err
+	}
+	return nil
+}
+
+// getLoginFailures counts the failures matching the condition since the given time, and returns when the last one happened
+func getLoginFailures(condition string, args []any, db Querier) (models.LoginFailures, error) {
+	var failures models.LoginFailures
+	err := db.QueryRow("SELECT COUNT(*) FROM login_attempt WHERE success = 0 AND "+condition, args...).Scan(&failures.Count)
+	if err != nil {
+		return models.LoginFailures{}, err
+	}
+	if failures.Count == 0 {
+		return failures, nil
+	}
+	err = db.QueryRow("SELECT created_at FROM login_attempt WHERE success = 0 AND "+condition+" ORDER BY id DESC LIMIT 1", args...).Scan(&failures.LastFailureAt)
+	if err != nil {
+		return models.LoginFailures{}, err
+	}
+	return failures, nil
+}
+
+// GetLoginFailuresByUsername counts the failed logins of the username since the given time and before the given attempt,
+// a successful login forgets the previous failures
+func GetLoginFailuresByUsername(username string, since time.Time, beforeId int, db Querier) (models.LoginFailures, error) {
+	condition := "username = ? AND created_at > " + timeParameter() + " AND id < ? AND id > (SELECT COALESCE(MAX(id), 0) FROM login_attempt WHERE username = ? AND success = 1 AND id < ?)"
+	failures, err := getLoginFailures(condition, []any{username, since.UTC(), beforeId, username, beforeId}, db)
+	if err != nil {
+		log.Printf("Error counting login failures of username %s, %v\n", username, err)
+		return models.LoginFailures{}, err
+	}
+	return failures, nil
+}
+
+// GetLoginFailuresByIp counts the failed logins from the IP address since the given time and before the given attempt,
+// whichever username they were for
+func GetLoginFailuresByIp(ipAddress string, since time.Time, beforeId int, db Querier) (models.LoginFailures, error) {
+	failures, err := getLoginFailures("ip_address = ? AND created_at > "+timeParameter()+" AND id < ?", []any{ipAddress, since.UTC(), beforeId}, db)
+	if err != nil {
+		log.Printf("Error counting login failures from ip %s, %v\n", ipAddress, err)
+		return models.LoginFailures{}, err
+	}
+	return failures, nil
+}
+
+// CheckNewLoginDevice tells if the user logged in before, but never from the IP address with the user agent
+func CheckNewLoginDevice(userId int, ipAddress string, userAgent string, db Querier) (bool, error) {
+	var logins, fromDevice int
+	err := db.QueryRow("SELECT COUNT(*), COALESCE(SUM(ip_address = ? AND user_agent = ?), 0) FROM login_attempt WHERE user_id = ? AND success = 1",
+		ipAddress, userAgent, userId).Scan(&logins, &fromDevice)
+	if err != nil {
+		log.Printf("Error checking login devices of user %d, %v\n", userId, err)
+		return false, err
+	}
+	return logins > 0 && fromDevice == 0, nil
+}
+
+// GetLoginHistory returns the logins of the user after the cursor, newest first
+func GetLoginHistory(userId int, cursor *models.Cursor, count int, db Querier) ([]models.LoginHistoryEntry, error) {
+	condition, args := pageCondition("login_attempt", cursor)
+	args = append([]any{userId}, append(args, count)...)
+	rows, err := db.Query("SELECT login_attempt.id, login_attempt.ip_address, login_attempt.user_agent, login_attempt.method, login_attempt.success, login_attempt.created_at "+
+		"FROM login_attempt WHERE login_attempt.user_id = ?"+condition+" ORDER BY login_attempt.created_at DESC, login_attempt.id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("Error getting login history of user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	logins := []models.LoginHistoryEntry{}
+	for rows.Next() {
+		var login models.LoginHistoryEntry
+		err := rows.Scan(&login.Id, &login.IpAddress, &login.UserAgent, &login.Method, &login.Success, &login.CreatedAt)
+		if err != nil {
+			log.Printf("Error scanning login history of user %d, %v\n", userId, err)
+			return nil, err
+		}
+		logins = append(logins, login)
+	}
+	return logins, nil
+}
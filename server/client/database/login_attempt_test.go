@@ -0,0 +1,145 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAddLoginAttempt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO login_attempt").WithArgs("toto", 2, "127.0.0.1", "agent", "google", true).WillReturnResult(sqlmock.NewResult(1, 1))
+	id, err := AddLoginAttempt(models.LoginAttempt{Username: "toto", UserId: 2, IpAddress: "127.0.0.1", UserAgent: "agent", Method: models.LoginMethodGoogle, Success: true}, db)
+	if err != nil || id != 1 {
+		t.Errorf("Unexpected result: %d, %v", id, err)
+	}
+	// an unknown username has no user
+	mock.ExpectExec("INSERT INTO login_attempt").WithArgs("nobody", nil, "127.0.0.1", "", "password", false).WillReturnError(errors.New("error"))
+	_, err = AddLoginAttempt(models.LoginAttempt{Username: "nobody", IpAddress: "127.0.0.1", Method: models.LoginMethodPassword}, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestFinishAndDeleteLoginAttempt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE login_attempt SET user_id = (.+), success = (.+) WHERE id = ").WithArgs(2, false, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = FinishLoginAttempt(5, 2, false, db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	mock.ExpectExec("UPDATE login_attempt SET user_id = (.+), success = (.+) WHERE id = ").WithArgs(nil, false, 6).WillReturnError(errors.New("error"))
+	err = FinishLoginAttempt(6, 0, false, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	mock.ExpectExec("DELETE FROM login_attempt WHERE id = ").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = DeleteLoginAttempt(5, db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestGetLoginFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	since := time.Now().Add(-time.Hour)
+	lastFailureAt := time.Now().Add(-time.Minute)
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM login_attempt WHERE success = 0 AND username = (.+) AND id < (.+) AND id > (.+) WHERE username = (.+) AND success = 1").
+		WithArgs("toto", since.UTC(), 10, "toto", 10).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT created_at FROM login_attempt WHERE success = 0 AND username = (.+) ORDER BY id DESC LIMIT 1").
+		WithArgs("toto", since.UTC(), 10, "toto", 10).WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(lastFailureAt))
+	failures, err := GetLoginFailuresByUsername("toto", since, 10, db)
+	if err != nil || failures.Count != 3 || !failures.LastFailureAt.Equal(lastFailureAt) {
+		t.Errorf("Unexpected failures %+v, %v", failures, err)
+	}
+
+	// without failures, there is no last one to look for
+	mock.ExpectQuery("SELECT COUNT(.+) FROM login_attempt WHERE success = 0 AND ip_address = ").
+		WithArgs("127.0.0.1", since.UTC(), 10).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	failures, err = GetLoginFailuresByIp("127.0.0.1", since, 10, db)
+	if err != nil || failures.Count != 0 {
+		t.Errorf("Unexpected failures %+v, %v", failures, err)
+	}
+	mock.ExpectQuery("SELECT COUNT(.+) FROM login_attempt WHERE success = 0 AND ip_address = ").
+		WithArgs("127.0.0.1", since.UTC(), 10).WillReturnError(errors.New("error"))
+	_, err = GetLoginFailuresByIp("127.0.0.1", since, 10, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestCheckNewLoginDevice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	for _, device := range []struct {
+		logins     int
+		fromDevice int
+		isNew      bool
+	}{{0, 0, false}, {3, 1, false}, {3, 0, true}} {
+		mock.ExpectQuery("SELECT COUNT(.+), COALESCE(.+) FROM login_attempt WHERE user_id = (.+) AND success = 1").WithArgs("10.0.0.1", "agent", 2).
+			WillReturnRows(sqlmock.NewRows([]string{"logins", "from_device"}).AddRow(device.logins, device.fromDevice))
+		isNew, err := CheckNewLoginDevice(2, "10.0.0.1", "agent", db)
+		if err != nil || isNew != device.isNew {
+			t.Errorf("Expected %t for %+v, got %t, %v", device.isNew, device, isNew, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestGetLoginHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT login_attempt.id, login_attempt.ip_address, login_attempt.user_agent, login_attempt.method, login_attempt.success, login_attempt.created_at FROM login_attempt WHERE login_attempt.user_id = ").
+		WithArgs(2, 10).WillReturnRows(sqlmock.NewRows([]string{"id", "ip_address", "user_agent", "method", "success", "created_at"}).
+		AddRow(2, "10.0.0.1", "agent", "password", false, now).AddRow(1, "10.0.0.1", "agent", "password", true, now))
+	logins, err := GetLoginHistory(2, nil, 10, db)
+	if err != nil || len(logins) != 2 || logins[0].Success || !logins[1].Success || logins[0].Method != models.LoginMethodPassword {
+		t.Errorf("Unexpected logins %+v, %v", logins, err)
+	}
+	mock.ExpectQuery("FROM login_attempt WHERE login_attempt.user_id = ").WithArgs(2, sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 10).WillReturnError(errors.New("error"))
+	_, err = GetLoginHistory(2, &models.Cursor{CreatedAt: now, Id: 2}, 10, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
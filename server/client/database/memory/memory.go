@@ -0,0 +1,2625 @@
+// Package memory implements database.Store in memory, it mirrors the behaviour of the SQL store
+// (not found errors are sql.ErrNoRows, usernames and emails are compared case insensitively)
+// and is meant for tests running the whole server without a database
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"project_truthful/client/database"
+	"project_truthful/models"
+)
+
+type user struct {
+	id            int
+	username      string
+	displayName   string
+	email         string
+	emailVerified bool
+	password      string
+	birthdate     string
+	createdAt     time.Time
+}
+
+type question struct {
+	id                int
+	receiverId        int
+	authorId          int
+	authorIpAddress   string
+	isAuthorAnonymous bool
+	text              string
+	createdAt         time.Time
+	hasBeenDeleted    bool
+	removal           *removal
+}
+
+// removal is the removal of a question or an answer by a moderator
+type removal struct {
+	moderatorId int
+	reason      string
+	removedAt   time.Time
+}
+
+type answer struct {
+	id                int
+	userId            int
+	questionId        int
+	text              string
+	answererIpAddress string
+	createdAt         time.Time
+	hasBeenDeleted    bool
+	removal           *removal
+}
+
+type like struct {
+	userId   int
+	answerId int
+}
+
+type follow struct {
+	id        int
+	follower  int
+	followed  int
+	createdAt time.Time
+}
+
+// relation is a block or a mute of the related user by the user
+type relation struct {
+	id        int
+	userId    int
+	relatedId int
+	createdAt time.Time
+}
+
+type ban struct {
+	id        int
+	userId    int
+	authorId  int
+	reason    string
+	createdAt time.Time
+	expiresAt *time.Time
+}
+
+type askerBlock struct {
+	id         int
+	receiverId int
+	ipHash     string
+	questionId int
+	createdAt  time.Time
+	expiresAt  *time.Time
+}
+
+type heldContent struct {
+	models.HeldContent
+	reviewedBy int
+	reviewedAt *time.Time
+	contentId  int
+}
+
+type mutedWord struct {
+	id     int
+	userId int
+	word   string
+}
+
+type report struct {
+	models.Report
+	claimedBy    int
+	resolutionId int
+}
+
+type pardon struct {
+	id         int
+	banId      int
+	pardonerId int
+	createdAt  time.Time
+}
+
+type oauthLogin struct {
+	providerId int
+	subject    string
+	userId     int64
+}
+
+type session struct {
+	id        int
+	sessionId string
+	userId    int
+	tokenHash string
+	ipAddress string
+	userAgent string
+	createdAt time.Time
+	expiresAt time.Time
+	rotatedAt *time.Time
+	revokedAt *time.Time
+}
+
+type passwordResetRequest struct {
+	ipAddress string
+	userId    int
+	createdAt time.Time
+}
+
+type passwordResetToken struct {
+	id        int
+	userId    int
+	tokenHash string
+	expiresAt time.Time
+	used      bool
+}
+
+type emailVerificationToken struct {
+	id        int
+	userId    int
+	email     string
+	tokenHash string
+	createdAt time.Time
+	expiresAt time.Time
+	used      bool
+}
+
+type role struct {
+	id                int
+	name              string
+	permissions       []string
+	requiresTwoFactor bool
+}
+
+type totpSecret struct {
+	secret       string
+	lastUsedStep int64
+	enabled      bool
+}
+
+type recoveryCode struct {
+	userId   int
+	codeHash string
+	used     bool
+}
+
+type loginChallenge struct {
+	id        int
+	userId    int
+	tokenHash string
+	attempts  int
+	expiresAt time.Time
+	used      bool
+}
+
+type streamTicket struct {
+	id              int
+	userId          int
+	tokenHash       string
+	expiresAt       time.Time
+	streamExpiresAt time.Time
+	used            bool
+}
+
+type loginAttempt struct {
+	models.LoginAttempt
+	id        int
+	createdAt time.Time
+}
+
+type userRole struct {
+	userId    int
+	roleId    int
+	grantedBy int
+}
+
+type notification struct {
+	id               int
+	userId           int
+	notificationType string
+	actorId          int
+	questionId       int
+	answerId         int
+	createdAt        time.Time
+	readAt           *time.Time
+}
+
+// Store is a database.Store keeping everything in memory, it is safe for concurrent use
+type Store struct {
+	mu sync.Mutex
+	// held by the transactions, which run one at a time
+	txMu sync.Mutex
+	tables
+}
+
+// tables holds the rows of the store, a copy of them is kept during a transaction to roll it back
+type tables struct {
+	// last id given for each table
+	lastIds map[string]int
+
+	users          []*user
+	questions      []*question
+	answers        []*answer
+	likes          []like
+	follows        []follow
+	blocks         []relation
+	mutes          []relation
+	askerBlocks    []*askerBlock
+	settings       map[int]models.UserSettings
+	mutedWords     []mutedWord
+	heldContents   []*heldContent
+	reports        []*report
+	resolutions    []*models.ReportResolution
+	bans           []*ban
+	pardons        []*pardon
+	addressBans    []models.AddressBan
+	rateLimits     map[string]*models.RateLimit
+	oauthProviders []string
+	oauthLogins    []oauthLogin
+	sessions       []*session
+	resetRequests  []passwordResetRequest
+	resetTokens    []*passwordResetToken
+	emailTokens    []*emailVerificationToken
+	totpSecrets    map[int]*totpSecret
+	recoveryCodes  []*recoveryCode
+	challenges     []*loginChallenge
+	streamTickets  []*streamTicket
+	loginAttempts  []loginAttempt
+	roles          []*role
+	userRoles      []userRole
+	auditEntries   []models.AuditEntry
+	notifications  []*notification
+}
+
+// New returns an empty store, with the roles and oauth providers created by the migrations
+func New() *Store {
+	return &Store{tables: tables{
+		lastIds:        map[string]int{},
+		rateLimits:     map[string]*models.RateLimit{},
+		settings:       map[int]models.UserSettings{},
+		totpSecrets:    map[int]*totpSecret{},
+		oauthProviders: []string{"Google"},
+		roles: []*role{
+			{id: 1, name: "admin", permissions: []string{"audit.view", "content.remove", "content.review", "questions.view_any", "reports.review", "roles.manage", "users.ban", "users.pardon"}},
+			{id: 2, name: "moderator", permissions: []string{"content.remove", "content.review", "questions.view_any", "reports.review", "users.ban", "users.pardon"}},
+		},
+	}}
+}
+
+// clone copies the rows, the rows behind pointers are copied too since the store changes them in place
+func (t *tables) clone() tables {
+	return tables{
+		lastIds:        maps.Clone(t.lastIds),
+		users:          clonePointers(t.users),
+		questions:      clonePointers(t.questions),
+		answers:        clonePointers(t.answers),
+		likes:          slices.Clone(t.likes),
+		follows:        slices.Clone(t.follows),
+		blocks:         slices.Clone(t.blocks),
+		mutes:          slices.Clone(t.mutes),
+		askerBlocks:    clonePointers(t.askerBlocks),
+		settings:       maps.Clone(t.settings),
+		mutedWords:     slices.Clone(t.mutedWords),
+		heldContents:   clonePointers(t.heldContents),
+		reports:        clonePointers(t.reports),
+		resolutions:    clonePointers(t.resolutions),
+		bans:           clonePointers(t.bans),
+		pardons:        clonePointers(t.pardons),
+		addressBans:    slices.Clone(t.addressBans),
+		rateLimits:     cloneMapPointers(t.rateLimits),
+		oauthProviders: slices.Clone(t.oauthProviders),
+		oauthLogins:    slices.Clone(t.oauthLogins),
+		sessions:       clonePointers(t.sessions),
+		resetRequests:  slices.Clone(t.resetRequests),
+		resetTokens:    clonePointers(t.resetTokens),
+		emailTokens:    clonePointers(t.emailTokens),
+		totpSecrets:    cloneMapPointers(t.totpSecrets),
+		recoveryCodes:  clonePointers(t.recoveryCodes),
+		challenges:     clonePointers(t.challenges),
+		streamTickets:  clonePointers(t.streamTickets),
+		loginAttempts:  slices.Clone(t.loginAttempts),
+		roles:          clonePointers(t.roles),
+		userRoles:      slices.Clone(t.userRoles),
+		auditEntries:   slices.Clone(t.auditEntries),
+		notifications:  clonePointers(t.notifications),
+	}
+}
+
+func clonePointers[T any](rows []*T) []*T {
+	if rows == nil {
+		return nil
+	}
+	clones := make([]*T, len(rows))
+	for i, row := range rows {
+		clone := *row
+		clones[i] = &clone
+	}
+	return clones
+}
+
+func cloneMapPointers[K comparable, V any](rows map[K]*V) map[K]*V {
+	clones := make(map[K]*V, len(rows))
+	for key, row := range rows {
+		clone := *row
+		clones[key] = &clone
+	}
+	return clones
+}
+
+func (s *Store) nextId(table string) int {
+	s.lastIds[table]++
+	return s.lastIds[table]
+}
+
+// errDuplicate mirrors the unique constraints of the SQL schema
+var errDuplicate = errors.New("duplicate entry")
+
+// users
+
+func (s *Store) findUser(id int) *user {
+	for _, u := range s.users {
+		if u.id == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Store) InsertUser(username string, password string, email string, birthdate string) (int64, error) {
+	return s.InsertUserWithDisplayName(username, username, password, email, birthdate)
+}
+
+func (s *Store) InsertUserWithDisplayName(username string, displayName string, password string, email string, birthdate string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := &user{id: s.nextId("user"), username: username, displayName: displayName, password: password, email: email, birthdate: birthdate, createdAt: time.Now()}
+	s.users = append(s.users, u)
+	return int64(u.id), nil
+}
+
+func (s *Store) CheckUsernameExists(username string) (bool, error) {
+	_, err := s.GetUserId(username)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) CheckUserIdExists(id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.findUser(id) != nil, nil
+}
+
+func (s *Store) CheckEmailExists(email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.email, email) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) GetUserId(username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.username, username) {
+			return u.id, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *Store) GetHashedPassword(id int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.findUser(id)
+	if u == nil {
+		return "", sql.ErrNoRows
+	}
+	return u.password, nil
+}
+
+func (s *Store) GetUserIdByEmail(email string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.email, email) {
+			return u.id, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *Store) UpdatePassword(id int, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.findUser(id); u != nil {
+		u.password = password
+	}
+	return nil
+}
+
+func (s *Store) GetUserEmail(id int) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.findUser(id)
+	if u == nil {
+		return "", false, sql.ErrNoRows
+	}
+	return u.email, u.emailVerified, nil
+}
+
+func (s *Store) SetEmailVerified(id int, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.findUser(id); u != nil {
+		u.email = email
+		u.emailVerified = true
+	}
+	return nil
+}
+
+func (s *Store) GetUsernameAndDisplayName(id int) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.findUser(id)
+	if u == nil {
+		return "", "", sql.ErrNoRows
+	}
+	return u.username, u.displayName, nil
+}
+
+func (s *Store) UpdateUserInformations(id int, displayName string, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.findUser(id)
+	if u != nil {
+		u.displayName = displayName
+		u.email = email
+	}
+	return nil
+}
+
+func (s *Store) GetUserProfileInfos(id int, requestingUser int, count int, start int) (models.UserProfileInfos, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.findUser(id)
+	if u == nil {
+		return models.UserProfileInfos{}, sql.ErrNoRows
+	}
+
+	infos := models.UserProfileInfos{Id: id, Username: u.username, DisplayName: u.displayName}
+	for _, f := range s.follows {
+		if f.followed == id {
+			infos.FollowerCount++
+		}
+		if f.follower == id {
+			infos.FollowingCount++
+		}
+	}
+	for _, a := range s.answers {
+		if a.userId == id {
+			infos.AnswerCount++
+		}
+	}
+	infos.Answers = s.getAnswers(id, requestingUser, count, start)
+	return infos, nil
+}
+
+func (s *Store) GetUserProfileInfosPage(id int, requestingUser int, cursor *models.Cursor, count int) (models.UserProfileInfos, error) {
+	infos, err := s.GetUserProfileInfos(id, requestingUser, 0, 0)
+	if err != nil {
+		return models.UserProfileInfos{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userAnswers := []*answer{}
+	for _, a := range s.sortedAnswers(id) {
+		if isAfter(a.createdAt, a.id, cursor) {
+			userAnswers = append(userAnswers, a)
+		}
+	}
+	infos.Answers = s.answersToModels(paginate(userAnswers, 0, count), requestingUser)
+	return infos, nil
+}
+
+func (s *Store) getAnswers(id int, requestingUser int, count int, start int) []models.Answer {
+	return s.answersToModels(paginate(s.sortedAnswers(id), start, count), requestingUser)
+}
+
+// sortedAnswers returns the answers of the user which are not deleted, newest first
+func (s *Store) sortedAnswers(id int) []*answer {
+	userAnswers := []*answer{}
+	for _, a := range s.answers {
+		if a.userId == id && !a.hasBeenDeleted {
+			userAnswers = append(userAnswers, a)
+		}
+	}
+	sort.SliceStable(userAnswers, func(i, j int) bool {
+		return newerThan(userAnswers[i].createdAt, userAnswers[i].id, userAnswers[j].createdAt, userAnswers[j].id)
+	})
+	return userAnswers
+}
+
+func (s *Store) answersToModels(userAnswers []*answer, requestingUser int) []models.Answer {
+	var answers []models.Answer
+	for _, a := range userAnswers {
+		result := models.Answer{Id: a.id, AnswerText: a.text, CreatedAt: a.createdAt, RemovedByModeration: a.removal != nil}
+		q := s.findQuestion(a.questionId)
+		if q != nil {
+			question := s.questionToModel(q)
+			result.QuestionText = question.Text
+			if question.RemovedByModeration {
+				result.RemovedByModeration = true
+			}
+			if question.IsAuthorAnonymous {
+				result.IsAuthorAnonymous = true
+			} else {
+				result.Author = question.Author
+			}
+		}
+		result.LikeCount = s.likeCount(a.id)
+		if requestingUser != 0 {
+			result.LikedByRequester = s.likeExists(requestingUser, a.id)
+		}
+		database.HideRemovedAnswer(&result)
+		answers = append(answers, result)
+	}
+	return answers
+}
+
+// newerThan orders items as the SQL queries do, by creation date then id, newest first
+func newerThan(createdAt time.Time, id int, otherCreatedAt time.Time, otherId int) bool {
+	if !createdAt.Equal(otherCreatedAt) {
+		return createdAt.After(otherCreatedAt)
+	}
+	return id > otherId
+}
+
+// isAfter tells if an item comes after the cursor in a list sorted by newerThan
+func isAfter(createdAt time.Time, id int, cursor *models.Cursor) bool {
+	return cursor == nil || newerThan(cursor.CreatedAt, cursor.Id, createdAt, id)
+}
+
+func paginate[T any](items []T, offset int, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// oauth
+
+func (s *Store) GetOAuthProvider(provider string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, name := range s.oauthProviders {
+		if strings.EqualFold(name, provider) {
+			return i + 1, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *Store) GetUserIdBySubject(providerId int, subject string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, login := range s.oauthLogins {
+		if login.providerId == providerId && login.subject == subject {
+			return login.userId, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *Store) InsertOauthLogin(providerId int, subject string, userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.oauthLogins = append(s.oauthLogins, oauthLogin{providerId: providerId, subject: subject, userId: userId})
+	return nil
+}
+
+// questions
+
+func (s *Store) findQuestion(id int) *question {
+	for _, q := range s.questions {
+		if q.id == id {
+			return q
+		}
+	}
+	return nil
+}
+
+func (s *Store) questionToModel(q *question) models.Question {
+	result := models.Question{Id: q.id, Text: q.text, IsAuthorAnonymous: q.isAuthorAnonymous, ReceiverId: q.receiverId, CreatedAt: q.createdAt,
+		RemovedByModeration: q.removal != nil}
+	if !q.isAuthorAnonymous && q.authorId != 0 {
+		result.Author.Id = int64(q.authorId)
+		if author := s.findUser(q.authorId); author != nil {
+			result.Author.Username = author.username
+			result.Author.DisplayName = author.displayName
+		}
+	}
+	return result
+}
+
+func (s *Store) hasBeenAnswered(questionId int) bool {
+	for _, a := range s.answers {
+		if a.questionId == questionId && !a.hasBeenDeleted {
+			return true
+		}
+	}
+	return false
+}
+
+// GetQuestions mirrors the SQL query: answered questions are skipped after the page is fetched
+func (s *Store) GetQuestions(userId int, start int, count int) ([]models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	received := []*question{}
+	for _, q := range s.questions {
+		if q.receiverId == userId {
+			received = append(received, q)
+		}
+	}
+	sort.SliceStable(received, func(i, j int) bool {
+		return received[i].createdAt.After(received[j].createdAt)
+	})
+
+	var questions []models.Question
+	for _, q := range paginate(received, start, count) {
+		if s.hasBeenAnswered(q.id) {
+			continue
+		}
+		questions = append(questions, s.questionToModel(q))
+	}
+	return questions, nil
+}
+
+func (s *Store) GetQuestionsPage(userId int, cursor *models.Cursor, count int) ([]models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	received := []*question{}
+	for _, q := range s.questions {
+		if q.receiverId == userId && !q.hasBeenDeleted && !s.hasBeenAnswered(q.id) && isAfter(q.createdAt, q.id, cursor) {
+			received = append(received, q)
+		}
+	}
+	sort.SliceStable(received, func(i, j int) bool {
+		return newerThan(received[i].createdAt, received[i].id, received[j].createdAt, received[j].id)
+	})
+
+	questions := []models.Question{}
+	for _, q := range paginate(received, 0, count) {
+		questions = append(questions, s.questionToModel(q))
+	}
+	return questions, nil
+}
+
+func (s *Store) GetQuestionReceiverId(questionId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.findQuestion(questionId)
+	if q == nil {
+		return 0, sql.ErrNoRows
+	}
+	return q.receiverId, nil
+}
+
+func (s *Store) AddQuestion(text string, authorId int, authorIpAddress string, isAuthorAnonymous bool, receiverId int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := &question{id: s.nextId("question"), receiverId: receiverId, authorId: authorId, authorIpAddress: authorIpAddress, isAuthorAnonymous: isAuthorAnonymous, text: text, createdAt: time.Now()}
+	// questions without author are always anonymous
+	if authorId == 0 {
+		q.isAuthorAnonymous = true
+	}
+	s.questions = append(s.questions, q)
+	return int64(q.id), nil
+}
+
+func (s *Store) GetQuestionById(questionId int) (models.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.findQuestion(questionId)
+	if q == nil {
+		return models.Question{}, sql.ErrNoRows
+	}
+	return s.questionToModel(q), nil
+}
+
+func (s *Store) GetQuestionAuthorIpAddress(questionId int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.questions {
+		if q.id == questionId {
+			return q.authorIpAddress, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+func (s *Store) MarkQuestionAsDeleted(questionId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q := s.findQuestion(questionId); q != nil {
+		q.hasBeenDeleted = true
+	}
+	return nil
+}
+
+func (s *Store) CheckQuestionRemoved(questionId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.findQuestion(questionId)
+	if q == nil || q.hasBeenDeleted {
+		return false, sql.ErrNoRows
+	}
+	return q.removal != nil, nil
+}
+
+func (s *Store) RemoveQuestion(questionId int, moderatorId int, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q := s.findQuestion(questionId); q != nil {
+		q.removal = &removal{moderatorId: moderatorId, reason: reason, removedAt: time.Now()}
+	}
+	return nil
+}
+
+func (s *Store) RestoreQuestion(questionId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q := s.findQuestion(questionId); q != nil {
+		q.removal = nil
+	}
+	return nil
+}
+
+// answers
+
+func (s *Store) findAnswer(id int) *answer {
+	for _, a := range s.answers {
+		if a.id == id && !a.hasBeenDeleted {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Store) CheckAnswerIdExists(answerId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.findAnswer(answerId) != nil, nil
+}
+
+func (s *Store) GetAnswerAuthorId(answerId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.findAnswer(answerId)
+	if a == nil {
+		return 0, sql.ErrNoRows
+	}
+	return a.userId, nil
+}
+
+func (s *Store) HasQuestionBeenAnswered(questionId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hasBeenAnswered(questionId), nil
+}
+
+func (s *Store) GetAnswerIdByQuestionId(questionId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.answers {
+		if a.questionId == questionId && !a.hasBeenDeleted {
+			return a.id, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *Store) AddAnswer(userId int, questionId int, answerText string, answererIpAddress string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := &answer{id: s.nextId("answer"), userId: userId, questionId: questionId, text: answerText, answererIpAddress: answererIpAddress, createdAt: time.Now()}
+	s.answers = append(s.answers, a)
+	return int64(a.id), nil
+}
+
+func (s *Store) MarkAnswerAsDeleted(answerId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.findAnswer(answerId); a != nil {
+		a.hasBeenDeleted = true
+	}
+	return nil
+}
+
+func (s *Store) CheckAnswerRemoved(answerId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.findAnswer(answerId)
+	if a == nil {
+		return false, sql.ErrNoRows
+	}
+	return a.removal != nil, nil
+}
+
+func (s *Store) RemoveAnswer(answerId int, moderatorId int, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.findAnswer(answerId); a != nil {
+		a.removal = &removal{moderatorId: moderatorId, reason: reason, removedAt: time.Now()}
+	}
+	return nil
+}
+
+func (s *Store) RestoreAnswer(answerId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.findAnswer(answerId); a != nil {
+		a.removal = nil
+	}
+	return nil
+}
+
+// likes
+
+func (s *Store) likeExists(userId int, answerId int) bool {
+	for _, l := range s.likes {
+		if l.userId == userId && l.answerId == answerId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) likeCount(answerId int) int {
+	count := 0
+	for _, l := range s.likes {
+		if l.answerId == answerId {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Store) CheckLikeExists(userId int, postId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.likeExists(userId, postId), nil
+}
+
+func (s *Store) RemoveLike(userId int, postId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	likes := s.likes[:0]
+	for _, l := range s.likes {
+		if l.userId != userId || l.answerId != postId {
+			likes = append(likes, l)
+		}
+	}
+	s.likes = likes
+	return nil
+}
+
+func (s *Store) AddLike(userId int, postId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.likes = append(s.likes, like{userId: userId, answerId: postId})
+	return nil
+}
+
+func (s *Store) GetLikeCountForAnswer(answerId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.likeCount(answerId), nil
+}
+
+// follows
+
+func (s *Store) CheckFollowExists(follower int, followed int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.followExists(follower, followed), nil
+}
+
+func (s *Store) followExists(follower int, followed int) bool {
+	for _, f := range s.follows {
+		if f.follower == follower && f.followed == followed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) AddFollow(followerId int, followedId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.followExists(followerId, followedId) {
+		return false, nil
+	}
+	s.follows = append(s.follows, follow{id: s.nextId("follow"), follower: followerId, followed: followedId, createdAt: time.Now()})
+	return true, nil
+}
+
+func (s *Store) RemoveFollow(followerId int, followedId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	follows := s.follows[:0]
+	for _, f := range s.follows {
+		if f.follower != followerId || f.followed != followedId {
+			follows = append(follows, f)
+		}
+	}
+	s.follows = follows
+	return nil
+}
+
+func (s *Store) GetFollowers(userId int, requestingUser int, cursor *models.Cursor, count int) ([]models.FollowPreview, error) {
+	return s.followList(func(f follow) (int, bool) { return f.follower, f.followed == userId }, requestingUser, cursor, count), nil
+}
+
+func (s *Store) GetFollowing(userId int, requestingUser int, cursor *models.Cursor, count int) ([]models.FollowPreview, error) {
+	return s.followList(func(f follow) (int, bool) { return f.followed, f.follower == userId }, requestingUser, cursor, count), nil
+}
+
+// followList lists the users returned by listed for the follows it keeps, most recent follow first
+func (s *Store) followList(listed func(f follow) (int, bool), requestingUser int, cursor *models.Cursor, count int) []models.FollowPreview {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	follows := []follow{}
+	for _, f := range s.follows {
+		if _, ok := listed(f); ok && isAfter(f.createdAt, f.id, cursor) {
+			follows = append(follows, f)
+		}
+	}
+	sort.SliceStable(follows, func(i, j int) bool {
+		return newerThan(follows[i].createdAt, follows[i].id, follows[j].createdAt, follows[j].id)
+	})
+
+	users := []models.FollowPreview{}
+	for _, f := range paginate(follows, 0, count) {
+		userId, _ := listed(f)
+		u := s.findUser(userId)
+		if u == nil {
+			continue
+		}
+		user := models.FollowPreview{
+			UserPreview:         models.UserPreview{Id: int64(u.id), Username: u.username, DisplayName: u.displayName},
+			FollowedAt:          f.createdAt,
+			FollowedByRequester: s.followExists(requestingUser, u.id),
+			FollowsRequester:    s.followExists(u.id, requestingUser),
+			FollowId:            f.id,
+		}
+		user.IsMutual = user.FollowedByRequester && user.FollowsRequester
+		users = append(users, user)
+	}
+	return users
+}
+
+// blocks and mutes
+
+func relationExists(relations []relation, userId int, relatedId int) bool {
+	for _, r := range relations {
+		if r.userId == userId && r.relatedId == relatedId {
+			return true
+		}
+	}
+	return false
+}
+
+// addRelation returns the relations with the new one, and false if it already existed
+func (s *Store) addRelation(relations []relation, table string, userId int, relatedId int) ([]relation, bool) {
+	if relationExists(relations, userId, relatedId) {
+		return relations, false
+	}
+	return append(relations, relation{id: s.nextId(table), userId: userId, relatedId: relatedId, createdAt: time.Now()}), true
+}
+
+// removeRelation returns the relations without the removed one, and false if it didn't exist
+func removeRelation(relations []relation, userId int, relatedId int) ([]relation, bool) {
+	kept := relations[:0]
+	removed := false
+	for _, r := range relations {
+		if r.userId == userId && r.relatedId == relatedId {
+			removed = true
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, removed
+}
+
+// relatedUsers lists the users related to the user after the cursor, most recent relation first
+func (s *Store) relatedUsers(relations []relation, userId int, cursor *models.Cursor, count int) []models.RelatedUser {
+	kept := []relation{}
+	for _, r := range relations {
+		if r.userId == userId && isAfter(r.createdAt, r.id, cursor) {
+			kept = append(kept, r)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return newerThan(kept[i].createdAt, kept[i].id, kept[j].createdAt, kept[j].id)
+	})
+
+	users := []models.RelatedUser{}
+	for _, r := range paginate(kept, 0, count) {
+		u := s.findUser(r.relatedId)
+		if u == nil {
+			continue
+		}
+		users = append(users, models.RelatedUser{
+			UserPreview: models.UserPreview{Id: int64(u.id), Username: u.username, DisplayName: u.displayName},
+			Since:       r.createdAt,
+			RelationId:  r.id,
+		})
+	}
+	return users
+}
+
+func (s *Store) CheckBlockExists(blockerId int, blockedId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return relationExists(s.blocks, blockerId, blockedId), nil
+}
+
+func (s *Store) AddBlock(blockerId int, blockedId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var created bool
+	s.blocks, created = s.addRelation(s.blocks, "user_block", blockerId, blockedId)
+	follows := s.follows[:0]
+	for _, f := range s.follows {
+		if (f.follower != blockerId || f.followed != blockedId) && (f.follower != blockedId || f.followed != blockerId) {
+			follows = append(follows, f)
+		}
+	}
+	s.follows = follows
+	return created, nil
+}
+
+func (s *Store) RemoveBlock(blockerId int, blockedId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed bool
+	s.blocks, removed = removeRelation(s.blocks, blockerId, blockedId)
+	return removed, nil
+}
+
+func (s *Store) GetBlockedUsers(userId int, cursor *models.Cursor, count int) ([]models.RelatedUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.relatedUsers(s.blocks, userId, cursor, count), nil
+}
+
+func (s *Store) CheckMuteExists(muterId int, mutedId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return relationExists(s.mutes, muterId, mutedId), nil
+}
+
+func (s *Store) AddMute(muterId int, mutedId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var created bool
+	s.mutes, created = s.addRelation(s.mutes, "user_mute", muterId, mutedId)
+	return created, nil
+}
+
+func (s *Store) RemoveMute(muterId int, mutedId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed bool
+	s.mutes, removed = removeRelation(s.mutes, muterId, mutedId)
+	return removed, nil
+}
+
+func (s *Store) GetMutedUsers(userId int, cursor *models.Cursor, count int) ([]models.RelatedUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.relatedUsers(s.mutes, userId, cursor, count), nil
+}
+
+// asker blocks
+
+func (s *Store) AddAskerBlock(receiverId int, ipHash string, questionId int, duration int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &askerBlock{id: s.nextId("asker_block"), receiverId: receiverId, ipHash: ipHash, questionId: questionId, createdAt: time.Now()}
+	if duration != 0 {
+		expiration := time.Now().Add(time.Duration(duration) * time.Hour)
+		b.expiresAt = &expiration
+	}
+	s.askerBlocks = append(s.askerBlocks, b)
+	return int64(b.id), nil
+}
+
+func (b *askerBlock) isActive() bool {
+	return b.expiresAt == nil || b.expiresAt.After(time.Now())
+}
+
+func (s *Store) CheckAskerBlockExists(receiverId int, ipHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.askerBlocks {
+		if b.receiverId == receiverId && b.ipHash == ipHash && b.isActive() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) GetAskerBlocks(receiverId int, cursor *models.Cursor, count int) ([]models.AskerBlock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocks := []*askerBlock{}
+	for _, b := range s.askerBlocks {
+		if b.receiverId == receiverId && b.isActive() && isAfter(b.createdAt, b.id, cursor) {
+			blocks = append(blocks, b)
+		}
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return newerThan(blocks[i].createdAt, blocks[i].id, blocks[j].createdAt, blocks[j].id)
+	})
+
+	result := []models.AskerBlock{}
+	for _, b := range paginate(blocks, 0, count) {
+		block := models.AskerBlock{Id: b.id, QuestionId: b.questionId, CreatedAt: b.createdAt, ExpiresAt: b.expiresAt, IsPermanent: b.expiresAt == nil}
+		for _, q := range s.questions {
+			if q.id == b.questionId {
+				block.QuestionSnippet = q.text
+			}
+		}
+		result = append(result, block)
+	}
+	return result, nil
+}
+
+func (s *Store) RemoveAskerBlock(receiverId int, blockId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.askerBlocks {
+		if b.id == blockId && b.receiverId == receiverId {
+			s.askerBlocks = append(s.askerBlocks[:i], s.askerBlocks[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// settings
+
+func (s *Store) GetUserSettings(userId int) (models.UserSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	settings, ok := s.settings[userId]
+	if !ok {
+		return models.DefaultUserSettings, nil
+	}
+	return settings, nil
+}
+
+func (s *Store) UpdateUserSettings(userId int, settings models.UserSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[userId] = settings
+	return nil
+}
+
+// muted words
+
+func (s *Store) GetMutedWords(userId int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	words := []string{}
+	for _, w := range s.mutedWords {
+		if w.userId == userId {
+			words = append(words, w.word)
+		}
+	}
+	return words, nil
+}
+
+func (s *Store) AddMutedWord(userId int, word string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range s.mutedWords {
+		if w.userId == userId && strings.EqualFold(w.word, word) {
+			return false, nil
+		}
+	}
+	s.mutedWords = append(s.mutedWords, mutedWord{id: s.nextId("muted_word"), userId: userId, word: word})
+	return true, nil
+}
+
+func (s *Store) RemoveMutedWord(userId int, word string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, w := range s.mutedWords {
+		if w.userId == userId && strings.EqualFold(w.word, word) {
+			s.mutedWords = append(s.mutedWords[:i], s.mutedWords[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// held contents
+
+func (s *Store) AddHeldContent(content models.HeldContent) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content.Id = s.nextId("held_content")
+	content.Status = models.HeldPending
+	content.CreatedAt = time.Now()
+	s.heldContents = append(s.heldContents, &heldContent{HeldContent: content})
+	return int64(content.Id), nil
+}
+
+func (s *Store) findHeldContent(heldId int) *heldContent {
+	for _, h := range s.heldContents {
+		if h.Id == heldId {
+			return h
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetHeldContent(heldId int) (models.HeldContent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.findHeldContent(heldId)
+	if h == nil {
+		return models.HeldContent{}, sql.ErrNoRows
+	}
+	return h.HeldContent, nil
+}
+
+func (s *Store) GetPendingHeldContents(cursor *models.Cursor, count int) ([]models.HeldContent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := []*heldContent{}
+	for _, h := range s.heldContents {
+		if h.Status == models.HeldPending && isAfter(h.CreatedAt, h.Id, cursor) {
+			pending = append(pending, h)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return newerThan(pending[i].CreatedAt, pending[i].Id, pending[j].CreatedAt, pending[j].Id)
+	})
+	contents := []models.HeldContent{}
+	for _, h := range paginate(pending, 0, count) {
+		contents = append(contents, h.HeldContent)
+	}
+	return contents, nil
+}
+
+func (s *Store) CheckPendingHeldAnswer(questionId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range s.heldContents {
+		if h.QuestionId == questionId && h.Status == models.HeldPending {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) ReviewHeldContent(heldId int, status string, reviewerId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.findHeldContent(heldId)
+	if h == nil || h.Status != models.HeldPending {
+		return false, nil
+	}
+	now := time.Now()
+	h.Status = status
+	h.reviewedBy = reviewerId
+	h.reviewedAt = &now
+	return true, nil
+}
+
+func (s *Store) SetHeldContentId(heldId int, contentId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h := s.findHeldContent(heldId); h != nil {
+		h.contentId = contentId
+	}
+	return nil
+}
+
+// reports
+
+func (s *Store) GetReportTargetOwnerId(targetType string, targetId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch targetType {
+	case models.ReportTargetQuestion:
+		if q := s.findQuestion(targetId); q != nil && !q.hasBeenDeleted && q.removal == nil {
+			return q.authorId, nil
+		}
+	case models.ReportTargetAnswer:
+		if a := s.findAnswer(targetId); a != nil && a.removal == nil {
+			return a.userId, nil
+		}
+	case models.ReportTargetUser:
+		if u := s.findUser(targetId); u != nil {
+			return u.id, nil
+		}
+	default:
+		return 0, errors.New("unknown report target " + targetType)
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *Store) AddReport(reporterId int, targetType string, targetId int, reason string, details string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &report{Report: models.Report{Id: s.nextId("report"), ReporterId: reporterId, TargetType: targetType, TargetId: targetId,
+		Reason: reason, Details: details, CreatedAt: time.Now()}}
+	s.reports = append(s.reports, r)
+	return int64(r.Id), nil
+}
+
+// openReports returns the open reports of the target in the order they were made, every open report when targetType is empty
+func (s *Store) openReports(targetType string, targetId int) []*report {
+	reports := []*report{}
+	for _, r := range s.reports {
+		if r.resolutionId == 0 && (targetType == "" || (r.TargetType == targetType && r.TargetId == targetId)) {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+func (s *Store) CheckOpenReportExists(reporterId int, targetType string, targetId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.openReports(targetType, targetId) {
+		if r.ReporterId == reporterId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// reportGroups groups the reports by target, in the order of their first report
+func reportGroups(reports []*report) []models.ReportGroup {
+	groups := []models.ReportGroup{}
+	indexes := map[string]int{}
+	for _, r := range reports {
+		key := r.TargetType + ":" + strconv.Itoa(r.TargetId)
+		i, ok := indexes[key]
+		if !ok {
+			i = len(groups)
+			indexes[key] = i
+			groups = append(groups, models.ReportGroup{TargetType: r.TargetType, TargetId: r.TargetId, Reasons: []string{}, FirstReportedAt: r.CreatedAt})
+		}
+		group := &groups[i]
+		group.ReportCount++
+		group.LastReportedAt = r.CreatedAt
+		group.LastReportId = r.Id
+		if r.claimedBy > group.ClaimedBy {
+			group.ClaimedBy = r.claimedBy
+		}
+		found := false
+		for _, reason := range group.Reasons {
+			found = found || reason == r.Reason
+		}
+		if !found {
+			group.Reasons = append(group.Reasons, r.Reason)
+		}
+	}
+	return groups
+}
+
+func (s *Store) GetOpenReportGroups(cursor *models.Cursor, count int) ([]models.ReportGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	groups := []models.ReportGroup{}
+	for _, group := range reportGroups(s.openReports("", 0)) {
+		if isAfter(group.LastReportedAt, group.LastReportId, cursor) {
+			groups = append(groups, group)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return newerThan(groups[i].LastReportedAt, groups[i].LastReportId, groups[j].LastReportedAt, groups[j].LastReportId)
+	})
+	return append([]models.ReportGroup{}, paginate(groups, 0, count)...), nil
+}
+
+func (s *Store) GetOpenReportGroup(targetType string, targetId int) (models.ReportGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	groups := reportGroups(s.openReports(targetType, targetId))
+	if len(groups) == 0 {
+		return models.ReportGroup{}, sql.ErrNoRows
+	}
+	return groups[0], nil
+}
+
+func (s *Store) GetOpenReports(targetType string, targetId int) ([]models.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports := []models.Report{}
+	for _, r := range s.openReports(targetType, targetId) {
+		reports = append(reports, r.Report)
+	}
+	return reports, nil
+}
+
+func (s *Store) ClaimReports(targetType string, targetId int, moderatorId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.openReports(targetType, targetId) {
+		r.claimedBy = moderatorId
+	}
+	return nil
+}
+
+func (s *Store) ResolveReports(resolution models.ReportResolution) (int64, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports := s.openReports(resolution.TargetType, resolution.TargetId)
+	if len(reports) == 0 {
+		return 0, 0, nil
+	}
+	resolution.Id = s.nextId("report_resolution")
+	resolution.ReportCount = len(reports)
+	resolution.CreatedAt = time.Now()
+	for _, r := range reports {
+		r.resolutionId = resolution.Id
+	}
+	s.resolutions = append(s.resolutions, &resolution)
+	return int64(resolution.Id), resolution.ReportCount, nil
+}
+
+func (s *Store) GetReportResolutions(targetType string, targetId int, cursor *models.Cursor, count int) ([]models.ReportResolution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resolutions := []models.ReportResolution{}
+	for _, r := range s.resolutions {
+		if (targetType == "" || (r.TargetType == targetType && r.TargetId == targetId)) && isAfter(r.CreatedAt, r.Id, cursor) {
+			resolutions = append(resolutions, *r)
+		}
+	}
+	sort.SliceStable(resolutions, func(i, j int) bool {
+		return newerThan(resolutions[i].CreatedAt, resolutions[i].Id, resolutions[j].CreatedAt, resolutions[j].Id)
+	})
+	return append([]models.ReportResolution{}, paginate(resolutions, 0, count)...), nil
+}
+
+// timeline
+
+func (s *Store) GetTimeline(userId int, cursor *models.Cursor, count int) ([]models.TimelineAnswer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	followed := map[int]bool{}
+	for _, f := range s.follows {
+		if f.follower == userId && len(s.activeBans(f.followed)) == 0 && !relationExists(s.mutes, userId, f.followed) {
+			followed[f.followed] = true
+		}
+	}
+	timeline := []*answer{}
+	for _, a := range s.answers {
+		if followed[a.userId] && !a.hasBeenDeleted && isAfter(a.createdAt, a.id, cursor) {
+			timeline = append(timeline, a)
+		}
+	}
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return newerThan(timeline[i].createdAt, timeline[i].id, timeline[j].createdAt, timeline[j].id)
+	})
+	timeline = paginate(timeline, 0, count)
+
+	answers := []models.TimelineAnswer{}
+	for i, answer := range s.answersToModels(timeline, userId) {
+		result := models.TimelineAnswer{Answer: answer}
+		if u := s.findUser(timeline[i].userId); u != nil {
+			result.Answerer = models.UserPreview{Id: int64(u.id), Username: u.username, DisplayName: u.displayName}
+		}
+		answers = append(answers, result)
+	}
+	return answers, nil
+}
+
+// notifications
+
+func (s *Store) AddNotification(userId int, notificationType string, actorId int, questionId int, answerId int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := &notification{id: s.nextId("notification"), userId: userId, notificationType: notificationType, actorId: actorId, questionId: questionId, answerId: answerId, createdAt: time.Now()}
+	s.notifications = append(s.notifications, n)
+	return int64(n.id), nil
+}
+
+func (s *Store) GetNotifications(userId int, cursor *models.Cursor, count int) ([]models.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userNotifications := []*notification{}
+	for _, n := range s.notifications {
+		if n.userId == userId && isAfter(n.createdAt, n.id, cursor) {
+			userNotifications = append(userNotifications, n)
+		}
+	}
+	sort.SliceStable(userNotifications, func(i, j int) bool {
+		return newerThan(userNotifications[i].createdAt, userNotifications[i].id, userNotifications[j].createdAt, userNotifications[j].id)
+	})
+
+	notifications := []models.Notification{}
+	for _, n := range paginate(userNotifications, 0, count) {
+		result := models.Notification{Id: n.id, Type: n.notificationType, QuestionId: n.questionId, AnswerId: n.answerId, CreatedAt: n.createdAt, IsRead: n.readAt != nil}
+		if u := s.findUser(n.actorId); u != nil {
+			result.Actor = &models.UserPreview{Id: int64(u.id), Username: u.username, DisplayName: u.displayName}
+		}
+		notifications = append(notifications, result)
+	}
+	return notifications, nil
+}
+
+func (s *Store) CountUnreadNotifications(userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, n := range s.notifications {
+		if n.userId == userId && n.readAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) markNotificationsAsRead(filter func(*notification) bool) {
+	now := time.Now()
+	for _, n := range s.notifications {
+		if n.readAt == nil && filter(n) {
+			n.readAt = &now
+		}
+	}
+}
+
+func (s *Store) MarkNotificationAsRead(userId int, notificationId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exists := false
+	s.markNotificationsAsRead(func(n *notification) bool { return n.id == notificationId && n.userId == userId })
+	for _, n := range s.notifications {
+		if n.id == notificationId && n.userId == userId {
+			exists = true
+		}
+	}
+	return exists, nil
+}
+
+func (s *Store) MarkAllNotificationsAsRead(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markNotificationsAsRead(func(n *notification) bool { return n.userId == userId })
+	return nil
+}
+
+// bans and pardons
+
+func (s *Store) isPardoned(banId int) bool {
+	for _, p := range s.pardons {
+		if p.banId == banId {
+			return true
+		}
+	}
+	return false
+}
+
+// activeBans returns the active bans of the user, the one lasting the longest first
+func (s *Store) activeBans(userId int) []*ban {
+	now := time.Now()
+	active := []*ban{}
+	for _, b := range s.bans {
+		if b.userId != userId || s.isPardoned(b.id) {
+			continue
+		}
+		if b.expiresAt == nil || b.expiresAt.After(now) {
+			active = append(active, b)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		if active[i].expiresAt == nil || active[j].expiresAt == nil {
+			return active[i].expiresAt == nil && active[j].expiresAt != nil
+		}
+		return active[i].expiresAt.After(*active[j].expiresAt)
+	})
+	return active
+}
+
+func (s *Store) BanUser(userId int, requesterId int, duration int, reason string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &ban{id: s.nextId("ban"), userId: userId, authorId: requesterId, reason: reason, createdAt: time.Now()}
+	if duration != 0 {
+		expiration := time.Now().Add(time.Duration(duration) * time.Hour)
+		b.expiresAt = &expiration
+	}
+	s.bans = append(s.bans, b)
+	return int64(b.id), nil
+}
+
+func (s *Store) CheckUserBanStatus(userId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.activeBans(userId)) > 0, nil
+}
+
+func (s *Store) GetActiveBan(userId int) (models.Ban, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active := s.activeBans(userId)
+	if len(active) == 0 {
+		return models.Ban{}, sql.ErrNoRows
+	}
+	b := active[0]
+	result := models.Ban{Id: b.id, Reason: b.reason, CreatedAt: b.createdAt, IsPermanent: b.expiresAt == nil}
+	if b.expiresAt != nil {
+		expiresAt := *b.expiresAt
+		result.ExpiresAt = &expiresAt
+	}
+	return result, nil
+}
+
+func (s *Store) CheckBanExistsByBanId(banId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.bans {
+		if b.id == banId && (b.expiresAt == nil || b.expiresAt.After(time.Now())) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) banDetails(b *ban) models.BanDetails {
+	details := models.BanDetails{Id: b.id, UserId: b.userId, AuthorId: b.authorId, Reason: b.reason, CreatedAt: b.createdAt,
+		IsPermanent: b.expiresAt == nil, Status: models.BanStatusActive}
+	if b.expiresAt != nil {
+		expiresAt := *b.expiresAt
+		details.ExpiresAt = &expiresAt
+		if !expiresAt.After(time.Now()) {
+			details.Status = models.BanStatusExpired
+		}
+	}
+	for _, p := range s.pardons {
+		if p.banId == b.id {
+			details.Status = models.BanStatusPardoned
+			details.Pardon = &models.Pardon{Id: p.id, PardonerId: p.pardonerId, CreatedAt: p.createdAt}
+			break
+		}
+	}
+	return details
+}
+
+func (s *Store) GetBan(banId int) (models.BanDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.bans {
+		if b.id == banId {
+			return s.banDetails(b), nil
+		}
+	}
+	return models.BanDetails{}, sql.ErrNoRows
+}
+
+func (s *Store) GetBans(filter models.BanFilter, cursor *models.Cursor, count int) ([]models.BanDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bans := []models.BanDetails{}
+	for _, b := range s.bans {
+		if (filter.UserId != 0 && b.userId != filter.UserId) || (filter.AuthorId != 0 && b.authorId != filter.AuthorId) || !isAfter(b.createdAt, b.id, cursor) {
+			continue
+		}
+		details := s.banDetails(b)
+		if filter.Status == "" || details.Status == filter.Status {
+			bans = append(bans, details)
+		}
+	}
+	sort.SliceStable(bans, func(i, j int) bool {
+		return newerThan(bans[i].CreatedAt, bans[i].Id, bans[j].CreatedAt, bans[j].Id)
+	})
+	return append([]models.BanDetails{}, paginate(bans, 0, count)...), nil
+}
+
+func (s *Store) UpdateBanExpiration(banId int, expiresAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.bans {
+		if b.id == banId {
+			b.expiresAt = nil
+			if expiresAt != nil {
+				expiration := *expiresAt
+				b.expiresAt = &expiration
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) PardonUser(banId int, requesterId int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isPardoned(banId) {
+		return 0, nil
+	}
+	p := &pardon{id: s.nextId("pardon"), banId: banId, pardonerId: requesterId, createdAt: time.Now()}
+	s.pardons = append(s.pardons, p)
+	return int64(p.id), nil
+}
+
+func (s *Store) CheckPardonExists(banId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isPardoned(banId), nil
+}
+
+// address bans
+
+func (s *Store) AddAddressBan(banType string, value string, authorId int, duration int, reason string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ban := models.AddressBan{Id: s.nextId("address_ban"), BanType: banType, Value: value, AuthorId: authorId, Reason: reason, CreatedAt: time.Now(), IsPermanent: duration == 0}
+	if duration != 0 {
+		expiration := time.Now().Add(time.Duration(duration) * time.Hour)
+		ban.ExpiresAt = &expiration
+	}
+	s.addressBans = append(s.addressBans, ban)
+	return int64(ban.Id), nil
+}
+
+func (s *Store) GetAddressBan(banId int) (models.AddressBan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ban := range s.addressBans {
+		if ban.Id == banId {
+			return ban, nil
+		}
+	}
+	return models.AddressBan{}, sql.ErrNoRows
+}
+
+func (s *Store) GetAddressBans(banType string, cursor *models.Cursor, count int) ([]models.AddressBan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bans := []models.AddressBan{}
+	for _, ban := range s.addressBans {
+		active := ban.ExpiresAt == nil || ban.ExpiresAt.After(time.Now())
+		if active && (banType == "" || ban.BanType == banType) && isAfter(ban.CreatedAt, ban.Id, cursor) {
+			bans = append(bans, ban)
+		}
+	}
+	sort.SliceStable(bans, func(i, j int) bool {
+		return newerThan(bans[i].CreatedAt, bans[i].Id, bans[j].CreatedAt, bans[j].Id)
+	})
+	if count == 0 {
+		return bans, nil
+	}
+	return append([]models.AddressBan{}, paginate(bans, 0, count)...), nil
+}
+
+func (s *Store) RemoveAddressBan(banId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addressBans = slices.DeleteFunc(s.addressBans, func(ban models.AddressBan) bool { return ban.Id == banId })
+	return nil
+}
+
+// ipAddressesByUser returns the IP addresses each user asked or answered from
+func (s *Store) ipAddressesByUser() map[int][]string {
+	used := map[int][]string{}
+	add := func(userId int, ipAddress string) {
+		if userId != 0 && !slices.Contains(used[userId], ipAddress) {
+			used[userId] = append(used[userId], ipAddress)
+		}
+	}
+	for _, q := range s.questions {
+		add(q.authorId, q.authorIpAddress)
+	}
+	for _, a := range s.answers {
+		add(a.userId, a.answererIpAddress)
+	}
+	for _, ipAddresses := range used {
+		slices.Sort(ipAddresses)
+	}
+	return used
+}
+
+func (s *Store) GetUserIpAddresses(userId int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.ipAddressesByUser()[userId]...), nil
+}
+
+func (s *Store) GetAccountsByIpAddresses(ipAddresses []string, userId int) ([]models.LinkedAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	accounts := []models.LinkedAccount{}
+	for accountId, used := range s.ipAddressesByUser() {
+		shared := []string{}
+		for _, ipAddress := range used {
+			if slices.Contains(ipAddresses, ipAddress) {
+				shared = append(shared, ipAddress)
+			}
+		}
+		u := s.findUser(accountId)
+		if accountId != userId && len(shared) > 0 && u != nil {
+			accounts = append(accounts, models.LinkedAccount{UserId: accountId, Username: u.username, IpAddresses: shared})
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].UserId < accounts[j].UserId })
+	return accounts, nil
+}
+
+// rate limits
+
+func (s *Store) GetRateLimit(ip string) (models.RateLimit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rateLimit, ok := s.rateLimits[ip]
+	if !ok {
+		rateLimit = &models.RateLimit{IpAddress: ip, RequestCount: 0, LastRequestTime: time.Now()}
+		s.rateLimits[ip] = rateLimit
+	}
+	return *rateLimit, nil
+}
+
+func (s *Store) ResetRateLimit(ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rateLimit, ok := s.rateLimits[ip]; ok {
+		rateLimit.RequestCount = 1
+		rateLimit.LastRequestTime = time.Now()
+	}
+	return nil
+}
+
+func (s *Store) IncrementRateLimit(ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rateLimit, ok := s.rateLimits[ip]; ok {
+		rateLimit.RequestCount++
+		rateLimit.LastRequestTime = time.Now()
+	}
+	return nil
+}
+
+// sessions
+
+func (s *Store) InsertRefreshToken(sessionId string, userId int, tokenHash string, ipAddress string, userAgent string, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.sessions {
+		if existing.tokenHash == tokenHash {
+			return 0, errDuplicate
+		}
+	}
+	token := &session{id: s.nextId("session"), sessionId: sessionId, userId: userId, tokenHash: tokenHash, ipAddress: ipAddress, userAgent: userAgent, createdAt: time.Now(), expiresAt: expiresAt}
+	s.sessions = append(s.sessions, token)
+	return int64(token.id), nil
+}
+
+func (s *Store) GetRefreshTokenByHash(tokenHash string) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.sessions {
+		if token.tokenHash == tokenHash {
+			return models.RefreshToken{Id: token.id, SessionId: token.sessionId, UserId: token.userId, ExpiresAt: token.expiresAt, IsRotated: token.rotatedAt != nil, IsRevoked: token.revokedAt != nil}, nil
+		}
+	}
+	return models.RefreshToken{}, sql.ErrNoRows
+}
+
+func (s *Store) MarkRefreshTokenAsRotated(id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.sessions {
+		if token.id == id && token.rotatedAt == nil {
+			now := time.Now()
+			token.rotatedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// revokeSessions revokes the tokens matching the filter and returns how many were revoked
+func (s *Store) revokeSessions(filter func(*session) bool) int {
+	revoked := 0
+	now := time.Now()
+	for _, token := range s.sessions {
+		if token.revokedAt == nil && filter(token) {
+			token.revokedAt = &now
+			revoked++
+		}
+	}
+	return revoked
+}
+
+func (s *Store) RevokeSession(sessionId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeSessions(func(token *session) bool { return token.sessionId == sessionId })
+	return nil
+}
+
+func (s *Store) RevokeUserSession(userId int, sessionId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revoked := s.revokeSessions(func(token *session) bool { return token.sessionId == sessionId && token.userId == userId })
+	return revoked > 0, nil
+}
+
+func (s *Store) RevokeAllUserSessions(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeSessions(func(token *session) bool { return token.userId == userId })
+	return nil
+}
+
+func (s *Store) GetActiveSessions(userId int) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active := []*session{}
+	for _, token := range s.sessions {
+		if token.userId == userId && token.rotatedAt == nil && token.revokedAt == nil && token.expiresAt.After(time.Now()) {
+			active = append(active, token)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return active[i].createdAt.After(active[j].createdAt)
+	})
+	sessions := []models.Session{}
+	for _, token := range active {
+		sessions = append(sessions, models.Session{Id: token.sessionId, IpAddress: token.ipAddress, UserAgent: token.userAgent, LastUsedAt: token.createdAt, ExpiresAt: token.expiresAt})
+	}
+	return sessions, nil
+}
+
+// password resets
+
+func (s *Store) AddPasswordResetRequest(ipAddress string, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resetRequests = append(s.resetRequests, passwordResetRequest{ipAddress: ipAddress, userId: userId, createdAt: time.Now()})
+	return nil
+}
+
+func (s *Store) countPasswordResetRequests(since time.Time, matches func(request passwordResetRequest) bool) int {
+	count := 0
+	for _, request := range s.resetRequests {
+		if request.createdAt.After(since) && matches(request) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Store) CountPasswordResetRequestsByIp(ipAddress string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.countPasswordResetRequests(since, func(request passwordResetRequest) bool { return request.ipAddress == ipAddress }), nil
+}
+
+func (s *Store) CountPasswordResetRequestsByUser(userId int, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.countPasswordResetRequests(since, func(request passwordResetRequest) bool { return request.userId == userId }), nil
+}
+
+func (s *Store) AddPasswordResetToken(userId int, tokenHash string, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.resetTokens {
+		if token.tokenHash == tokenHash {
+			return 0, errDuplicate
+		}
+	}
+	token := &passwordResetToken{id: s.nextId("password_reset_token"), userId: userId, tokenHash: tokenHash, expiresAt: expiresAt}
+	s.resetTokens = append(s.resetTokens, token)
+	return int64(token.id), nil
+}
+
+func (s *Store) GetPasswordResetToken(tokenHash string) (models.PasswordResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.resetTokens {
+		if token.tokenHash == tokenHash {
+			return models.PasswordResetToken{Id: token.id, UserId: token.userId, ExpiresAt: token.expiresAt, IsUsed: token.used}, nil
+		}
+	}
+	return models.PasswordResetToken{}, sql.ErrNoRows
+}
+
+func (s *Store) UsePasswordResetToken(id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.resetTokens {
+		if token.id == id && !token.used {
+			token.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) RevokePasswordResetTokens(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.resetTokens {
+		if token.userId == userId {
+			token.used = true
+		}
+	}
+	return nil
+}
+
+// email verification
+
+func (s *Store) AddEmailVerificationToken(userId int, email string, tokenHash string, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.emailTokens {
+		if token.tokenHash == tokenHash {
+			return 0, errDuplicate
+		}
+	}
+	token := &emailVerificationToken{id: s.nextId("email_verification_token"), userId: userId, email: email, tokenHash: tokenHash, createdAt: time.Now(), expiresAt: expiresAt}
+	s.emailTokens = append(s.emailTokens, token)
+	return int64(token.id), nil
+}
+
+func (s *Store) CountEmailVerificationTokens(userId int, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, token := range s.emailTokens {
+		if token.userId == userId && token.createdAt.After(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) GetEmailVerificationToken(tokenHash string) (models.EmailVerificationToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.emailTokens {
+		if token.tokenHash == tokenHash {
+			return models.EmailVerificationToken{Id: token.id, UserId: token.userId, Email: token.email, ExpiresAt: token.expiresAt, IsUsed: token.used}, nil
+		}
+	}
+	return models.EmailVerificationToken{}, sql.ErrNoRows
+}
+
+func (s *Store) GetPendingEmail(userId int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.emailTokens) - 1; i >= 0; i-- {
+		token := s.emailTokens[i]
+		if token.userId == userId && !token.used && token.expiresAt.After(time.Now()) {
+			return token.email, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+func (s *Store) UseEmailVerificationToken(id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.emailTokens {
+		if token.id == id && !token.used {
+			token.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) RevokeEmailVerificationTokens(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.emailTokens {
+		if token.userId == userId {
+			token.used = true
+		}
+	}
+	return nil
+}
+
+// roles
+
+func (s *Store) findRole(name string) *role {
+	for _, r := range s.roles {
+		if strings.EqualFold(r.name, name) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *Store) hasRole(userId int, roleId int) bool {
+	for _, ur := range s.userRoles {
+		if ur.userId == userId && ur.roleId == roleId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CheckUserPermission(userId int, permission string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.roles {
+		if !s.hasRole(userId, r.id) {
+			continue
+		}
+		// the roles requiring a second factor only grant their permissions to the users who enabled one
+		if r.requiresTwoFactor && (s.totpSecrets[userId] == nil || !s.totpSecrets[userId].enabled) {
+			continue
+		}
+		for _, p := range r.permissions {
+			if p == permission {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) CheckUserHasRole(userId int, roleId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hasRole(userId, roleId), nil
+}
+
+func (s *Store) CheckUserHasRoleName(userId int, roleName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.findRole(roleName)
+	return r != nil && s.hasRole(userId, r.id), nil
+}
+
+func (s *Store) GetRoleId(roleName string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.findRole(roleName)
+	if r == nil {
+		return 0, sql.ErrNoRows
+	}
+	return r.id, nil
+}
+
+func (s *Store) GrantRole(userId int, roleId int, grantedBy int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hasRole(userId, roleId) {
+		return errDuplicate
+	}
+	s.userRoles = append(s.userRoles, userRole{userId: userId, roleId: roleId, grantedBy: grantedBy})
+	return nil
+}
+
+func (s *Store) RevokeRole(userId int, roleId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, ur := range s.userRoles {
+		if ur.userId == userId && ur.roleId == roleId {
+			s.userRoles = append(s.userRoles[:i], s.userRoles[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) GetRoles() ([]models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	roles := []models.Role{}
+	for _, r := range s.roles {
+		permissions := append([]string{}, r.permissions...)
+		sort.Strings(permissions)
+		roles = append(roles, models.Role{Id: r.id, Name: r.name, Permissions: permissions, RequiresTwoFactor: r.requiresTwoFactor})
+	}
+	return roles, nil
+}
+
+func (s *Store) GetUserRoles(userId int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	roles := []string{}
+	for _, r := range s.roles {
+		if s.hasRole(userId, r.id) {
+			roles = append(roles, r.name)
+		}
+	}
+	return roles, nil
+}
+
+func (s *Store) CheckTwoFactorRequired(userId int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.roles {
+		if r.requiresTwoFactor && s.hasRole(userId, r.id) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) SetRoleRequiresTwoFactor(roleId int, required bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.roles {
+		if r.id == roleId {
+			r.requiresTwoFactor = required
+		}
+	}
+	return nil
+}
+
+// two factor
+
+func (s *Store) GetTotpSecret(userId int) (models.TotpSecret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret := s.totpSecrets[userId]
+	if secret == nil {
+		return models.TotpSecret{}, sql.ErrNoRows
+	}
+	return models.TotpSecret{UserId: userId, Secret: secret.secret, LastUsedStep: secret.lastUsedStep, IsEnabled: secret.enabled}, nil
+}
+
+func (s *Store) AddTotpSecret(userId int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.totpSecrets[userId] != nil {
+		return errDuplicate
+	}
+	s.totpSecrets[userId] = &totpSecret{secret: secret}
+	return nil
+}
+
+func (s *Store) EnableTotp(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if secret := s.totpSecrets[userId]; secret != nil {
+		secret.enabled = true
+	}
+	return nil
+}
+
+func (s *Store) UseTotpStep(userId int, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret := s.totpSecrets[userId]
+	if secret == nil || secret.lastUsedStep >= step {
+		return false, nil
+	}
+	secret.lastUsedStep = step
+	return true, nil
+}
+
+func (s *Store) DeleteTotpSecret(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.totpSecrets, userId)
+	return nil
+}
+
+func (s *Store) AddRecoveryCodes(userId int, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, codeHash := range codeHashes {
+		for _, code := range s.recoveryCodes {
+			if code.userId == userId && code.codeHash == codeHash {
+				return errDuplicate
+			}
+		}
+		s.recoveryCodes = append(s.recoveryCodes, &recoveryCode{userId: userId, codeHash: codeHash})
+	}
+	return nil
+}
+
+func (s *Store) DeleteRecoveryCodes(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := []*recoveryCode{}
+	for _, code := range s.recoveryCodes {
+		if code.userId != userId {
+			kept = append(kept, code)
+		}
+	}
+	s.recoveryCodes = kept
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, code := range s.recoveryCodes {
+		if code.userId == userId && code.codeHash == codeHash && !code.used {
+			code.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) CountRecoveryCodes(userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, code := range s.recoveryCodes {
+		if code.userId == userId && !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) AddLoginChallenge(userId int, tokenHash string, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, challenge := range s.challenges {
+		if challenge.tokenHash == tokenHash {
+			return 0, errDuplicate
+		}
+	}
+	challenge := &loginChallenge{id: s.nextId("login_challenge"), userId: userId, tokenHash: tokenHash, expiresAt: expiresAt}
+	s.challenges = append(s.challenges, challenge)
+	return int64(challenge.id), nil
+}
+
+func (s *Store) GetLoginChallenge(tokenHash string) (models.LoginChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, challenge := range s.challenges {
+		if challenge.tokenHash == tokenHash {
+			return models.LoginChallenge{Id: challenge.id, UserId: challenge.userId, Attempts: challenge.attempts, ExpiresAt: challenge.expiresAt, IsUsed: challenge.used}, nil
+		}
+	}
+	return models.LoginChallenge{}, sql.ErrNoRows
+}
+
+func (s *Store) AddLoginChallengeAttempt(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, challenge := range s.challenges {
+		if challenge.id == id {
+			challenge.attempts++
+		}
+	}
+	return nil
+}
+
+func (s *Store) UseLoginChallenge(id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, challenge := range s.challenges {
+		if challenge.id == id && !challenge.used {
+			challenge.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// stream tickets
+
+func (s *Store) AddStreamTicket(userId int, tokenHash string, expiresAt time.Time, streamExpiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ticket := range s.streamTickets {
+		if ticket.tokenHash == tokenHash {
+			return errDuplicate
+		}
+	}
+	s.streamTickets = append(s.streamTickets, &streamTicket{id: s.nextId("stream_ticket"), userId: userId, tokenHash: tokenHash,
+		expiresAt: expiresAt, streamExpiresAt: streamExpiresAt})
+	return nil
+}
+
+func (s *Store) GetStreamTicket(tokenHash string) (models.StreamTicket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ticket := range s.streamTickets {
+		if ticket.tokenHash == tokenHash {
+			return models.StreamTicket{Id: ticket.id, UserId: ticket.userId, ExpiresAt: ticket.expiresAt, StreamExpiresAt: ticket.streamExpiresAt, IsUsed: ticket.used}, nil
+		}
+	}
+	return models.StreamTicket{}, sql.ErrNoRows
+}
+
+func (s *Store) UseStreamTicket(id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ticket := range s.streamTickets {
+		if ticket.id == id && !ticket.used {
+			ticket.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// login attempts
+
+func (s *Store) AddLoginAttempt(attempt models.LoginAttempt) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextId("login_attempt")
+	s.loginAttempts = append(s.loginAttempts, loginAttempt{LoginAttempt: attempt, id: id, createdAt: time.Now()})
+	return int64(id), nil
+}
+
+func (s *Store) FinishLoginAttempt(id int, userId int, success bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.loginAttempts {
+		if s.loginAttempts[i].id == id {
+			s.loginAttempts[i].UserId = userId
+			s.loginAttempts[i].Success = success
+		}
+	}
+	return nil
+}
+
+func (s *Store) DeleteLoginAttempt(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loginAttempts = slices.DeleteFunc(s.loginAttempts, func(attempt loginAttempt) bool { return attempt.id == id })
+	return nil
+}
+
+// getLoginFailures counts the failures since the given time, before the given attempt and after the last attempt for which forgets returns true
+func (s *Store) getLoginFailures(since time.Time, beforeId int, matches func(attempt loginAttempt) bool, forgets func(attempt loginAttempt) bool) models.LoginFailures {
+	failures := models.LoginFailures{}
+	for _, attempt := range s.loginAttempts {
+		if attempt.id >= beforeId {
+			break
+		}
+		if forgets(attempt) {
+			failures = models.LoginFailures{}
+		} else if !attempt.Success && attempt.createdAt.After(since) && matches(attempt) {
+			failures.Count++
+			failures.LastFailureAt = attempt.createdAt
+		}
+	}
+	return failures
+}
+
+func (s *Store) GetLoginFailuresByUsername(username string, since time.Time, beforeId int) (models.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLoginFailures(since, beforeId,
+		func(attempt loginAttempt) bool { return attempt.Username == username },
+		func(attempt loginAttempt) bool { return attempt.Success && attempt.Username == username }), nil
+}
+
+func (s *Store) GetLoginFailuresByIp(ipAddress string, since time.Time, beforeId int) (models.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLoginFailures(since, beforeId,
+		func(attempt loginAttempt) bool { return attempt.IpAddress == ipAddress },
+		func(attempt loginAttempt) bool { return false }), nil
+}
+
+func (s *Store) CheckNewLoginDevice(userId int, ipAddress string, userAgent string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loggedIn := false
+	for _, attempt := range s.loginAttempts {
+		if attempt.UserId != userId || !attempt.Success {
+			continue
+		}
+		if attempt.IpAddress == ipAddress && attempt.UserAgent == userAgent {
+			return false, nil
+		}
+		loggedIn = true
+	}
+	return loggedIn, nil
+}
+
+func (s *Store) GetLoginHistory(userId int, cursor *models.Cursor, count int) ([]models.LoginHistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userAttempts := []loginAttempt{}
+	for _, attempt := range s.loginAttempts {
+		if userId != 0 && attempt.UserId == userId && isAfter(attempt.createdAt, attempt.id, cursor) {
+			userAttempts = append(userAttempts, attempt)
+		}
+	}
+	sort.SliceStable(userAttempts, func(i, j int) bool {
+		return newerThan(userAttempts[i].createdAt, userAttempts[i].id, userAttempts[j].createdAt, userAttempts[j].id)
+	})
+
+	logins := []models.LoginHistoryEntry{}
+	for _, attempt := range paginate(userAttempts, 0, count) {
+		logins = append(logins, models.LoginHistoryEntry{Id: attempt.id, IpAddress: attempt.IpAddress, UserAgent: attempt.UserAgent,
+			Method: attempt.Method, Success: attempt.Success, CreatedAt: attempt.createdAt})
+	}
+	return logins, nil
+}
+
+// moderation
+
+func (s *Store) AddAuditEntry(entry models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.Id = s.nextId("moderation_logging")
+	entry.CreatedAt = time.Now()
+	if entry.TargetId == 0 {
+		entry.TargetType = ""
+	}
+	if len(entry.Payload) == 0 {
+		entry.Payload = json.RawMessage("{}")
+	}
+	s.auditEntries = append(s.auditEntries, entry)
+	return nil
+}
+
+func (s *Store) GetAuditEntries(filter models.AuditFilter, cursor *models.Cursor, count int) ([]models.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []models.AuditEntry{}
+	for _, e := range s.auditEntries {
+		if (filter.ModeratorId != 0 && e.ModeratorId != filter.ModeratorId) || (filter.Action != "" && e.Action != filter.Action) ||
+			(filter.TargetType != "" && e.TargetType != filter.TargetType) || (filter.TargetId != 0 && e.TargetId != filter.TargetId) ||
+			(!filter.From.IsZero() && e.CreatedAt.Before(filter.From)) || (!filter.To.IsZero() && !e.CreatedAt.Before(filter.To)) {
+			continue
+		}
+		if isAfter(e.CreatedAt, e.Id, cursor) {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return newerThan(entries[i].CreatedAt, entries[i].Id, entries[j].CreatedAt, entries[j].Id)
+	})
+	return append([]models.AuditEntry{}, paginate(entries, 0, count)...), nil
+}
+
+// InTransaction runs fn on the store itself and puts the rows back as they were when fn fails.
+// The transactions run one at a time, the writes made outside of them while fn runs are rolled back as well
+func (s *Store) InTransaction(fn func(store database.Store) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	snapshot := s.tables.clone()
+	s.mu.Unlock()
+
+	err := fn(transaction{s})
+	if err != nil {
+		s.mu.Lock()
+		s.tables = snapshot
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// transaction is the store given to the function of a transaction, the transactions it starts join it
+type transaction struct {
+	*Store
+}
+
+func (t transaction) InTransaction(fn func(store database.Store) error) error {
+	return fn(t)
+}
+
+var _ database.Store = (*Store)(nil)
@@ -0,0 +1,853 @@
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsers(t *testing.T) {
+	s := New()
+	id, err := s.InsertUser("Toto", "password", "Toto@toto.fr", "1990-01-01")
+	if err != nil || id != 1 {
+		t.Fatalf("Expected user 1 to be inserted, got %d, %v", id, err)
+	}
+
+	// usernames and emails are case insensitive, as with the MySQL collation
+	userId, err := s.GetUserId("toto")
+	if err != nil || userId != 1 {
+		t.Errorf("Expected user 1, got %d, %v", userId, err)
+	}
+	exists, _ := s.CheckEmailExists("toto@TOTO.fr")
+	if !exists {
+		t.Errorf("Expected email to exist")
+	}
+
+	_, err = s.GetUserId("titi")
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+	_, _, err = s.GetUsernameAndDisplayName(2)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestQuestionsAndAnswers(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	s.AddQuestion("signed", 2, "127.0.0.1", false, 1)
+	s.AddQuestion("anonymous", 2, "127.0.0.1", true, 1)
+
+	questions, _ := s.GetQuestions(1, 0, 10)
+	if len(questions) != 2 {
+		t.Fatalf("Expected 2 questions, got %d", len(questions))
+	}
+	for _, question := range questions {
+		if question.IsAuthorAnonymous && question.Author.Id != 0 {
+			t.Errorf("Expected anonymous question to hide its author, got %+v", question.Author)
+		}
+		if !question.IsAuthorAnonymous && question.Author.Username != "titi" {
+			t.Errorf("Expected author titi, got %+v", question.Author)
+		}
+	}
+
+	answerId, _ := s.AddAnswer(1, 2, "answer", "127.0.0.1")
+	s.AddLike(2, int(answerId))
+	questions, _ = s.GetQuestions(1, 0, 10)
+	if len(questions) != 1 || questions[0].Text != "signed" {
+		t.Errorf("Expected the answered question to be skipped, got %+v", questions)
+	}
+
+	profile, err := s.GetUserProfileInfos(1, 2, 10, 0)
+	if err != nil || profile.AnswerCount != 1 || len(profile.Answers) != 1 {
+		t.Fatalf("Unexpected profile %+v, %v", profile, err)
+	}
+	if !profile.Answers[0].IsAuthorAnonymous || profile.Answers[0].LikeCount != 1 || !profile.Answers[0].LikedByRequester {
+		t.Errorf("Unexpected answer %+v", profile.Answers[0])
+	}
+
+	s.MarkAnswerAsDeleted(int(answerId))
+	exists, _ := s.CheckAnswerIdExists(int(answerId))
+	if exists {
+		t.Errorf("Expected deleted answer to be hidden")
+	}
+}
+
+func TestPages(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	for i := 0; i < 5; i++ {
+		s.AddQuestion("question", 0, "127.0.0.1", true, 1)
+	}
+
+	questions, _ := s.GetQuestionsPage(1, nil, 3)
+	if len(questions) != 3 || questions[0].Id != 5 {
+		t.Fatalf("Expected the 3 newest questions, got %+v", questions)
+	}
+	last := questions[2]
+	questions, _ = s.GetQuestionsPage(1, &models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id}, 3)
+	if len(questions) != 2 || questions[0].Id != 2 || questions[1].Id != 1 {
+		t.Errorf("Expected questions 2 and 1, got %+v", questions)
+	}
+
+	for i := 1; i <= 3; i++ {
+		s.AddAnswer(1, i, "answer", "127.0.0.1")
+	}
+	profile, err := s.GetUserProfileInfosPage(1, 0, nil, 2)
+	if err != nil || profile.AnswerCount != 3 || len(profile.Answers) != 2 {
+		t.Fatalf("Unexpected profile %+v, %v", profile, err)
+	}
+	answer := profile.Answers[1]
+	profile, _ = s.GetUserProfileInfosPage(1, 0, &models.Cursor{CreatedAt: answer.CreatedAt, Id: answer.Id}, 2)
+	if len(profile.Answers) != 1 || profile.Answers[0].Id != 1 {
+		t.Errorf("Expected the oldest answer, got %+v", profile.Answers)
+	}
+}
+
+func TestFollows(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	s.InsertUser("tata", "password", "tata@tata.fr", "1990-01-01")
+	created, _ := s.AddFollow(2, 1)
+	if !created {
+		t.Errorf("Expected follow to be created")
+	}
+	created, _ = s.AddFollow(2, 1)
+	if created {
+		t.Errorf("Expected follow to be created only once")
+	}
+	s.AddFollow(3, 1)
+	s.AddFollow(1, 2)
+
+	// toto and titi follow each other
+	followers, _ := s.GetFollowers(1, 1, nil, 10)
+	if len(followers) != 2 || followers[0].Username != "tata" || followers[1].Username != "titi" {
+		t.Fatalf("Expected followers tata and titi, got %+v", followers)
+	}
+	if followers[0].IsMutual || !followers[0].FollowsRequester || !followers[1].IsMutual {
+		t.Errorf("Unexpected follow flags %+v", followers)
+	}
+	followers, _ = s.GetFollowers(1, 1, &models.Cursor{CreatedAt: followers[0].FollowedAt, Id: followers[0].FollowId}, 10)
+	if len(followers) != 1 || followers[0].Username != "titi" {
+		t.Errorf("Expected follower titi after the cursor, got %+v", followers)
+	}
+	following, _ := s.GetFollowing(1, 0, nil, 10)
+	if len(following) != 1 || following[0].Username != "titi" || following[0].FollowedByRequester {
+		t.Errorf("Expected following titi, got %+v", following)
+	}
+}
+
+func TestBlocksAndMutes(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	s.InsertUser("tata", "password", "tata@tata.fr", "1990-01-01")
+	s.AddFollow(1, 2)
+	s.AddFollow(2, 1)
+	s.AddFollow(3, 1)
+
+	// blocking removes the follows between both users only
+	created, _ := s.AddBlock(1, 2)
+	if !created {
+		t.Errorf("Expected block to be created")
+	}
+	created, _ = s.AddBlock(1, 2)
+	if created {
+		t.Errorf("Expected block to be created only once")
+	}
+	if exists, _ := s.CheckFollowExists(1, 2); exists {
+		t.Errorf("Expected the follow of titi to be removed")
+	}
+	if exists, _ := s.CheckFollowExists(2, 1); exists {
+		t.Errorf("Expected the follow of toto to be removed")
+	}
+	if exists, _ := s.CheckFollowExists(3, 1); !exists {
+		t.Errorf("Expected the follow of tata to be kept")
+	}
+	if exists, _ := s.CheckBlockExists(2, 1); exists {
+		t.Errorf("Expected the block to go one way")
+	}
+
+	s.AddBlock(1, 3)
+	blocked, _ := s.GetBlockedUsers(1, nil, 10)
+	if len(blocked) != 2 || blocked[0].Username != "tata" || blocked[1].Username != "titi" {
+		t.Fatalf("Expected blocked users tata and titi, got %+v", blocked)
+	}
+	blocked, _ = s.GetBlockedUsers(1, &models.Cursor{CreatedAt: blocked[0].Since, Id: blocked[0].RelationId}, 10)
+	if len(blocked) != 1 || blocked[0].Username != "titi" {
+		t.Errorf("Expected blocked user titi after the cursor, got %+v", blocked)
+	}
+	removed, _ := s.RemoveBlock(1, 2)
+	if !removed {
+		t.Errorf("Expected block to be removed")
+	}
+	removed, _ = s.RemoveBlock(1, 2)
+	if removed {
+		t.Errorf("Expected block to be removed only once")
+	}
+
+	created, _ = s.AddMute(1, 2)
+	if !created {
+		t.Errorf("Expected mute to be created")
+	}
+	if exists, _ := s.CheckMuteExists(1, 2); !exists {
+		t.Errorf("Expected mute to exist")
+	}
+	muted, _ := s.GetMutedUsers(1, nil, 10)
+	if len(muted) != 1 || muted[0].Username != "titi" {
+		t.Errorf("Expected muted user titi, got %+v", muted)
+	}
+	removed, _ = s.RemoveMute(1, 2)
+	if !removed {
+		t.Errorf("Expected mute to be removed")
+	}
+}
+
+func TestAskerBlocks(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.AddQuestion("first question", 0, "127.0.0.1", true, 1)
+	s.AddQuestion("second question", 0, "127.0.0.2", true, 1)
+	if ip, _ := s.GetQuestionAuthorIpAddress(1); ip != "127.0.0.1" {
+		t.Errorf("Expected the address of the asker, got %s", ip)
+	}
+
+	permanentId, _ := s.AddAskerBlock(1, "hash", 1, 0)
+	temporaryId, _ := s.AddAskerBlock(1, "other", 2, 1)
+	if exists, _ := s.CheckAskerBlockExists(1, "hash"); !exists {
+		t.Errorf("Expected the asker to be blocked")
+	}
+	if exists, _ := s.CheckAskerBlockExists(2, "hash"); exists {
+		t.Errorf("Expected the block to be kept to its receiver")
+	}
+	blocks, _ := s.GetAskerBlocks(1, nil, 10)
+	if len(blocks) != 2 || blocks[0].Id != int(temporaryId) || blocks[0].IsPermanent || blocks[1].QuestionSnippet != "first question" || !blocks[1].IsPermanent {
+		t.Fatalf("Expected the temporary then the permanent block, got %+v", blocks)
+	}
+	blocks, _ = s.GetAskerBlocks(1, &models.Cursor{CreatedAt: blocks[0].CreatedAt, Id: blocks[0].Id}, 10)
+	if len(blocks) != 1 || blocks[0].Id != int(permanentId) {
+		t.Errorf("Expected the permanent block after the cursor, got %+v", blocks)
+	}
+
+	removed, _ := s.RemoveAskerBlock(2, int(permanentId))
+	if removed {
+		t.Errorf("Expected only the receiver to remove the block")
+	}
+	removed, _ = s.RemoveAskerBlock(1, int(permanentId))
+	if !removed {
+		t.Errorf("Expected the block to be removed")
+	}
+	if exists, _ := s.CheckAskerBlockExists(1, "hash"); exists {
+		t.Errorf("Expected the asker to be unblocked")
+	}
+}
+
+func TestUserSettings(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	if settings, _ := s.GetUserSettings(1); settings != models.DefaultUserSettings {
+		t.Errorf("Expected the default settings, got %+v", settings)
+	}
+	expected := models.UserSettings{GuestQuestions: models.GuestQuestionsCaptcha, InboxPaused: true}
+	s.UpdateUserSettings(1, expected)
+	if settings, _ := s.GetUserSettings(1); settings != expected {
+		t.Errorf("Expected %+v, got %+v", expected, settings)
+	}
+}
+
+func TestMutedWordsAndHeldContents(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.AddMutedWord(1, "pizza")
+	if created, _ := s.AddMutedWord(1, "pizza"); created {
+		t.Errorf("Expected the word to be muted only once")
+	}
+	s.AddMutedWord(1, "pineapple")
+	if words, _ := s.GetMutedWords(1); len(words) != 2 || words[0] != "pizza" {
+		t.Errorf("Expected pizza then pineapple, got %v", words)
+	}
+	if removed, _ := s.RemoveMutedWord(1, "pizza"); !removed {
+		t.Errorf("Expected the word to be unmuted")
+	}
+	if removed, _ := s.RemoveMutedWord(1, "pizza"); removed {
+		t.Errorf("Expected the word not to be muted anymore")
+	}
+
+	questionId, _ := s.AddHeldContent(models.HeldContent{ContentType: "question", Text: "see a.co", ReceiverId: 1, Reasons: []string{"links: a.co"}})
+	answerId, _ := s.AddHeldContent(models.HeldContent{ContentType: "answer", Text: "see a.co", AuthorId: 1, ReceiverId: 1, QuestionId: 3})
+	if pending, _ := s.CheckPendingHeldAnswer(3); !pending {
+		t.Errorf("Expected the answer to be pending")
+	}
+	contents, _ := s.GetPendingHeldContents(nil, 10)
+	if len(contents) != 2 || contents[0].Id != int(answerId) || contents[1].Status != models.HeldPending {
+		t.Fatalf("Expected the answer then the question, got %+v", contents)
+	}
+	contents, _ = s.GetPendingHeldContents(&models.Cursor{CreatedAt: contents[0].CreatedAt, Id: contents[0].Id}, 10)
+	if len(contents) != 1 || contents[0].Id != int(questionId) {
+		t.Errorf("Expected the question after the cursor, got %+v", contents)
+	}
+
+	if reviewed, _ := s.ReviewHeldContent(int(answerId), models.HeldRejected, 1); !reviewed {
+		t.Errorf("Expected the answer to be reviewed")
+	}
+	if reviewed, _ := s.ReviewHeldContent(int(answerId), models.HeldApproved, 1); reviewed {
+		t.Errorf("Expected the answer to be reviewed only once")
+	}
+	if pending, _ := s.CheckPendingHeldAnswer(3); pending {
+		t.Errorf("Expected the answer not to be pending anymore")
+	}
+	if held, _ := s.GetHeldContent(int(answerId)); held.Status != models.HeldRejected {
+		t.Errorf("Expected the answer to be rejected, got %+v", held)
+	}
+	if _, err := s.GetHeldContent(10); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestReports(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	s.AddQuestion("question", 0, "127.0.0.1", true, 1)
+	if ownerId, err := s.GetReportTargetOwnerId("question", 1); err != nil || ownerId != 0 {
+		t.Errorf("Expected no owner for a question of a guest, got %d and %v", ownerId, err)
+	}
+	if _, err := s.GetReportTargetOwnerId("answer", 1); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	s.AddReport(1, "user", 2, "spam", "")
+	s.AddReport(1, "question", 1, "spam", "")
+	s.AddReport(2, "question", 1, "hate", "details")
+	if reported, _ := s.CheckOpenReportExists(2, "question", 1); !reported {
+		t.Errorf("Expected the question to be reported")
+	}
+	groups, _ := s.GetOpenReportGroups(nil, 10)
+	if len(groups) != 2 || groups[0].TargetType != "question" || groups[0].ReportCount != 2 || len(groups[0].Reasons) != 2 {
+		t.Fatalf("Expected the question then the user, got %+v", groups)
+	}
+	groups, _ = s.GetOpenReportGroups(&models.Cursor{CreatedAt: groups[0].LastReportedAt, Id: groups[0].LastReportId}, 10)
+	if len(groups) != 1 || groups[0].TargetType != "user" {
+		t.Errorf("Expected the user after the cursor, got %+v", groups)
+	}
+
+	s.ClaimReports("question", 1, 2)
+	if group, _ := s.GetOpenReportGroup("question", 1); group.ClaimedBy != 2 {
+		t.Errorf("Expected the reports to be claimed, got %+v", group)
+	}
+	id, count, _ := s.ResolveReports(models.ReportResolution{TargetType: "question", TargetId: 1, ModeratorId: 2, Action: "delete"})
+	if id != 1 || count != 2 {
+		t.Errorf("Expected 2 reports to be resolved, got %d and %d", id, count)
+	}
+	if _, count, _ := s.ResolveReports(models.ReportResolution{TargetType: "question", TargetId: 1, ModeratorId: 2, Action: "dismiss"}); count != 0 {
+		t.Errorf("Expected the reports to be resolved once, got %d", count)
+	}
+	if _, err := s.GetOpenReportGroup("question", 1); err != sql.ErrNoRows {
+		t.Errorf("Expected no open report, got %v", err)
+	}
+	resolutions, _ := s.GetReportResolutions("user", 2, nil, 10)
+	if len(resolutions) != 0 {
+		t.Errorf("Expected no resolution for the user, got %+v", resolutions)
+	}
+	resolutions, _ = s.GetReportResolutions("", 0, nil, 10)
+	if len(resolutions) != 1 || resolutions[0].ReportCount != 2 {
+		t.Errorf("Expected the resolution of the question, got %+v", resolutions)
+	}
+}
+
+func TestContentRemoval(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	questionId, _ := s.AddQuestion("question", 2, "127.0.0.1", false, 1)
+	answerId, _ := s.AddAnswer(1, int(questionId), "answer", "127.0.0.1")
+	if _, err := s.CheckQuestionRemoved(1000); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	s.RemoveAnswer(int(answerId), 2, "spam")
+	if removed, _ := s.CheckAnswerRemoved(int(answerId)); !removed {
+		t.Errorf("Expected the answer to be removed")
+	}
+	if _, err := s.GetReportTargetOwnerId("answer", int(answerId)); err != sql.ErrNoRows {
+		t.Errorf("Expected removed answers not to be reported, got %v", err)
+	}
+	answers := s.getAnswers(1, 0, 10, 0)
+	if len(answers) != 1 || !answers[0].RemovedByModeration || answers[0].AnswerText != "" || answers[0].Author.Id != 0 {
+		t.Errorf("Expected the answer to be hidden, got %+v", answers)
+	}
+	s.RestoreAnswer(int(answerId))
+	answers = s.getAnswers(1, 0, 10, 0)
+	if len(answers) != 1 || answers[0].RemovedByModeration || answers[0].AnswerText != "answer" {
+		t.Errorf("Expected the answer to be restored, got %+v", answers)
+	}
+
+	// the answer of a removed question is hidden with it
+	s.RemoveQuestion(int(questionId), 2, "spam")
+	if question, _ := s.GetQuestionById(int(questionId)); !question.RemovedByModeration || question.Text != "question" {
+		t.Errorf("Expected the question to be removed with its text, got %+v", question)
+	}
+	answers = s.getAnswers(1, 0, 10, 0)
+	if len(answers) != 1 || !answers[0].RemovedByModeration || answers[0].QuestionText != "" {
+		t.Errorf("Expected the answer to be hidden, got %+v", answers)
+	}
+	s.RestoreQuestion(int(questionId))
+	if removed, _ := s.CheckQuestionRemoved(int(questionId)); removed {
+		t.Errorf("Expected the question to be restored")
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	s.InsertUser("tata", "password", "tata@tata.fr", "1990-01-01")
+	s.InsertUser("tutu", "password", "tutu@tutu.fr", "1990-01-01")
+	for receiver := 2; receiver <= 4; receiver++ {
+		questionId, _ := s.AddQuestion("question", 0, "127.0.0.1", true, receiver)
+		s.AddAnswer(receiver, int(questionId), "answer", "127.0.0.1")
+	}
+	deletedQuestionId, _ := s.AddQuestion("question", 0, "127.0.0.1", true, 2)
+	deletedAnswerId, _ := s.AddAnswer(2, int(deletedQuestionId), "deleted", "127.0.0.1")
+	s.MarkAnswerAsDeleted(int(deletedAnswerId))
+
+	// tutu is muted, tata is banned
+	s.AddFollow(1, 2)
+	s.AddFollow(1, 3)
+	s.AddFollow(1, 4)
+	s.AddMute(1, 4)
+	s.BanUser(3, 4, 0, "spam")
+	answers, _ := s.GetTimeline(1, nil, 10)
+	if len(answers) != 1 || answers[0].Answerer.Username != "titi" || answers[0].QuestionText != "question" {
+		t.Errorf("Expected only the answer of titi, got %+v", answers)
+	}
+}
+
+func TestBans(t *testing.T) {
+	s := New()
+	temporaryBanId, _ := s.BanUser(1, 2, 24, "spam")
+	permanentBanId, _ := s.BanUser(1, 2, 0, "spam")
+
+	// the permanent ban outlasts the temporary one
+	ban, err := s.GetActiveBan(1)
+	if err != nil || int64(ban.Id) != permanentBanId || !ban.IsPermanent {
+		t.Errorf("Expected the permanent ban, got %+v, %v", ban, err)
+	}
+
+	s.PardonUser(int(permanentBanId), 2)
+	ban, err = s.GetActiveBan(1)
+	if err != nil || int64(ban.Id) != temporaryBanId || ban.ExpiresAt == nil || ban.ExpiresAt.Before(time.Now()) {
+		t.Errorf("Expected the temporary ban, got %+v, %v", ban, err)
+	}
+
+	s.PardonUser(int(temporaryBanId), 2)
+	pardonId, _ := s.PardonUser(int(temporaryBanId), 2)
+	if pardonId != 0 {
+		t.Errorf("Expected the ban not to be pardoned twice, got pardon %d", pardonId)
+	}
+	_, err = s.GetActiveBan(1)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	// permanent bans can be pardoned, expired ones can't
+	exists, _ := s.CheckBanExistsByBanId(int(permanentBanId))
+	if !exists {
+		t.Errorf("Expected the permanent ban to exist")
+	}
+	expiredBanId, _ := s.BanUser(1, 3, 1, "spam")
+	s.UpdateBanExpiration(int(expiredBanId), &time.Time{})
+	exists, _ = s.CheckBanExistsByBanId(int(expiredBanId))
+	if exists {
+		t.Errorf("Expected the expired ban not to be found")
+	}
+
+	bans, _ := s.GetBans(models.BanFilter{UserId: 1}, nil, 10)
+	if len(bans) != 3 || bans[0].Status != models.BanStatusExpired || bans[2].Pardon == nil || bans[2].Pardon.PardonerId != 2 {
+		t.Errorf("Unexpected bans %+v", bans)
+	}
+	bans, _ = s.GetBans(models.BanFilter{AuthorId: 3, Status: models.BanStatusPardoned}, nil, 10)
+	if len(bans) != 0 {
+		t.Errorf("Expected no pardoned ban by user 3, got %+v", bans)
+	}
+	details, err := s.GetBan(int(permanentBanId))
+	if err != nil || details.Status != models.BanStatusPardoned || !details.IsPermanent {
+		t.Errorf("Expected the pardoned permanent ban, got %+v, %v", details, err)
+	}
+}
+
+func TestAddressBans(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	ipId, _ := s.AddAddressBan(models.AddressBanIp, "10.0.0.0/24", 1, 0, "spam")
+	s.AddAddressBan(models.AddressBanEmailDomain, "spam.com", 1, 24, "")
+
+	bans, _ := s.GetAddressBans(models.AddressBanIp, nil, 0)
+	if len(bans) != 1 || int64(bans[0].Id) != ipId || !bans[0].IsPermanent {
+		t.Errorf("Expected the permanent IP ban only, got %+v", bans)
+	}
+	bans, _ = s.GetAddressBans("", nil, 1)
+	if len(bans) != 1 || bans[0].BanType != models.AddressBanEmailDomain {
+		t.Errorf("Expected the most recent ban first, got %+v", bans)
+	}
+	s.RemoveAddressBan(int(ipId))
+	_, err := s.GetAddressBan(int(ipId))
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	s.AddQuestion("question", 1, "10.0.0.2", false, 2)
+	s.AddQuestion("question", 0, "10.0.0.1", true, 2)
+	s.AddAnswer(2, 1, "answer", "10.0.0.2")
+	ipAddresses, _ := s.GetUserIpAddresses(1)
+	accounts, _ := s.GetAccountsByIpAddresses(ipAddresses, 1)
+	if len(ipAddresses) != 1 || len(accounts) != 1 || accounts[0].Username != "titi" {
+		t.Errorf("Expected titi to share an address with toto, got %v, %+v", ipAddresses, accounts)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := New()
+	id, _ := s.InsertRefreshToken("session", 1, "hash", "127.0.0.1", "agent", time.Now().Add(time.Hour))
+	_, err := s.InsertRefreshToken("session", 1, "hash", "127.0.0.1", "agent", time.Now().Add(time.Hour))
+	if err == nil {
+		t.Errorf("Expected duplicate token hash to be rejected")
+	}
+
+	rotated, _ := s.MarkRefreshTokenAsRotated(int(id))
+	if !rotated {
+		t.Errorf("Expected token to be rotated")
+	}
+	rotated, _ = s.MarkRefreshTokenAsRotated(int(id))
+	if rotated {
+		t.Errorf("Expected token to be rotated only once")
+	}
+
+	s.InsertRefreshToken("session", 1, "hash2", "127.0.0.1", "agent", time.Now().Add(time.Hour))
+	sessions, _ := s.GetActiveSessions(1)
+	if len(sessions) != 1 {
+		t.Errorf("Expected 1 active session, got %d", len(sessions))
+	}
+	revoked, _ := s.RevokeUserSession(2, "session")
+	if revoked {
+		t.Errorf("Expected session of another user not to be revoked")
+	}
+	s.RevokeAllUserSessions(1)
+	sessions, _ = s.GetActiveSessions(1)
+	if len(sessions) != 0 {
+		t.Errorf("Expected no active session, got %d", len(sessions))
+	}
+}
+
+func TestPasswordResets(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	userId, err := s.GetUserIdByEmail("TOTO@toto.fr")
+	if err != nil || userId != 1 {
+		t.Errorf("Expected user 1, got %d, %v", userId, err)
+	}
+	_, err = s.GetUserIdByEmail("titi@titi.fr")
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	s.AddPasswordResetRequest("10.0.0.1", 0)
+	s.AddPasswordResetRequest("10.0.0.1", 1)
+	byIp, _ := s.CountPasswordResetRequestsByIp("10.0.0.1", time.Now().Add(-time.Hour))
+	byUser, _ := s.CountPasswordResetRequestsByUser(1, time.Now().Add(-time.Hour))
+	if byIp != 2 || byUser != 1 {
+		t.Errorf("Expected 2 requests from the IP address and 1 for the user, got %d and %d", byIp, byUser)
+	}
+
+	id, _ := s.AddPasswordResetToken(1, "hash1", time.Now().Add(time.Hour))
+	s.AddPasswordResetToken(1, "hash2", time.Now().Add(time.Hour))
+	used, _ := s.UsePasswordResetToken(int(id))
+	usedAgain, _ := s.UsePasswordResetToken(int(id))
+	if !used || usedAgain {
+		t.Errorf("Expected the token to be used once, got %t and %t", used, usedAgain)
+	}
+	s.RevokePasswordResetTokens(1)
+	resetToken, err := s.GetPasswordResetToken("hash2")
+	if err != nil || !resetToken.IsUsed {
+		t.Errorf("Expected the other token to be revoked, got %+v, %v", resetToken, err)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	email, verified, err := s.GetUserEmail(1)
+	if err != nil || email != "toto@toto.fr" || verified {
+		t.Errorf("Expected the new account to be unverified, got %s, %t, %v", email, verified, err)
+	}
+
+	s.AddEmailVerificationToken(1, "toto@toto.fr", "hash1", time.Now().Add(time.Hour))
+	id, _ := s.AddEmailVerificationToken(1, "titi@titi.fr", "hash2", time.Now().Add(time.Hour))
+	if _, err := s.AddEmailVerificationToken(1, "titi@titi.fr", "hash2", time.Now().Add(time.Hour)); err == nil {
+		t.Errorf("Expected the token hashes to be unique")
+	}
+	count, _ := s.CountEmailVerificationTokens(1, time.Now().Add(-time.Hour))
+	pendingEmail, _ := s.GetPendingEmail(1)
+	if count != 2 || pendingEmail != "titi@titi.fr" {
+		t.Errorf("Expected 2 tokens and titi@titi.fr pending, got %d and %s", count, pendingEmail)
+	}
+
+	used, _ := s.UseEmailVerificationToken(int(id))
+	usedAgain, _ := s.UseEmailVerificationToken(int(id))
+	if !used || usedAgain {
+		t.Errorf("Expected the token to be used once, got %t and %t", used, usedAgain)
+	}
+	s.SetEmailVerified(1, "titi@titi.fr")
+	s.RevokeEmailVerificationTokens(1)
+	email, verified, _ = s.GetUserEmail(1)
+	if email != "titi@titi.fr" || !verified {
+		t.Errorf("Expected the new email to be verified, got %s, %t", email, verified)
+	}
+	if _, err := s.GetPendingEmail(1); err != sql.ErrNoRows {
+		t.Errorf("Expected no pending email once the tokens are revoked, got %v", err)
+	}
+	verificationToken, err := s.GetEmailVerificationToken("hash1")
+	if err != nil || !verificationToken.IsUsed {
+		t.Errorf("Expected the other token to be revoked, got %+v, %v", verificationToken, err)
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	if _, err := s.GetTotpSecret(1); err != sql.ErrNoRows {
+		t.Errorf("Expected no secret, got %v", err)
+	}
+	s.AddTotpSecret(1, "SECRET")
+	if err := s.AddTotpSecret(1, "OTHER"); err == nil {
+		t.Errorf("Expected a single secret per user")
+	}
+	s.EnableTotp(1)
+	secret, err := s.GetTotpSecret(1)
+	if err != nil || secret.Secret != "SECRET" || !secret.IsEnabled {
+		t.Errorf("Expected the enabled secret, got %+v, %v", secret, err)
+	}
+	used, _ := s.UseTotpStep(1, 10)
+	usedAgain, _ := s.UseTotpStep(1, 10)
+	usedBefore, _ := s.UseTotpStep(1, 9)
+	if !used || usedAgain || usedBefore {
+		t.Errorf("Expected the steps to only go forward, got %t, %t and %t", used, usedAgain, usedBefore)
+	}
+
+	s.AddRecoveryCodes(1, []string{"hash1", "hash2"})
+	used, _ = s.UseRecoveryCode(1, "hash1")
+	usedAgain, _ = s.UseRecoveryCode(1, "hash1")
+	count, _ := s.CountRecoveryCodes(1)
+	if !used || usedAgain || count != 1 {
+		t.Errorf("Expected the code to be used once, got %t, %t and %d left", used, usedAgain, count)
+	}
+
+	id, _ := s.AddLoginChallenge(1, "challenge", time.Now().Add(time.Minute))
+	s.AddLoginChallengeAttempt(int(id))
+	challenge, err := s.GetLoginChallenge("challenge")
+	if err != nil || challenge.UserId != 1 || challenge.Attempts != 1 || challenge.IsUsed {
+		t.Errorf("Unexpected challenge %+v, %v", challenge, err)
+	}
+	used, _ = s.UseLoginChallenge(int(id))
+	usedAgain, _ = s.UseLoginChallenge(int(id))
+	if !used || usedAgain {
+		t.Errorf("Expected the challenge to be used once, got %t and %t", used, usedAgain)
+	}
+
+	// the permissions of a role requiring a second factor are withheld from the holders without one
+	moderatorId, _ := s.GetRoleId("moderator")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	s.GrantRole(1, moderatorId, 1)
+	s.GrantRole(2, moderatorId, 1)
+	s.SetRoleRequiresTwoFactor(moderatorId, true)
+	required, _ := s.CheckTwoFactorRequired(2)
+	allowed, _ := s.CheckUserPermission(2, "users.ban")
+	if !required || allowed {
+		t.Errorf("Expected the permission to be withheld, got %t and %t", required, allowed)
+	}
+	allowed, _ = s.CheckUserPermission(1, "users.ban")
+	if !allowed {
+		t.Errorf("Expected the user with a second factor to keep the permission")
+	}
+
+	s.DeleteTotpSecret(1)
+	s.DeleteRecoveryCodes(1)
+	count, _ = s.CountRecoveryCodes(1)
+	if _, err := s.GetTotpSecret(1); err != sql.ErrNoRows || count != 0 {
+		t.Errorf("Expected the second factor to be removed, got %v and %d codes", err, count)
+	}
+}
+
+func TestLoginAttempts(t *testing.T) {
+	s := New()
+	since := time.Now().Add(-time.Hour)
+	s.AddLoginAttempt(models.LoginAttempt{Username: "toto", UserId: 1, IpAddress: "10.0.0.1", Success: false})
+	s.AddLoginAttempt(models.LoginAttempt{Username: "nobody", IpAddress: "10.0.0.1", Success: false})
+	id, _ := s.AddLoginAttempt(models.LoginAttempt{Username: "toto", IpAddress: "10.0.0.1", Success: false})
+	failures, _ := s.GetLoginFailuresByUsername("toto", since, int(id))
+	if failures.Count != 1 || time.Since(failures.LastFailureAt) > time.Second {
+		t.Errorf("Expected 1 failure of toto before the attempt, got %+v", failures)
+	}
+	failures, _ = s.GetLoginFailuresByIp("10.0.0.1", since, int(id))
+	if failures.Count != 2 {
+		t.Errorf("Expected 2 failures from the address before the attempt, got %+v", failures)
+	}
+
+	// a successful login forgets the failures of the username, not of the address
+	s.FinishLoginAttempt(int(id), 1, true)
+	next, _ := s.AddLoginAttempt(models.LoginAttempt{Username: "toto", IpAddress: "10.0.0.1", Success: false})
+	failures, _ = s.GetLoginFailuresByUsername("toto", since, int(next))
+	if failures.Count != 0 {
+		t.Errorf("Expected the failures of toto to be forgotten, got %+v", failures)
+	}
+	failures, _ = s.GetLoginFailuresByIp("10.0.0.1", since, int(next))
+	if failures.Count != 2 {
+		t.Errorf("Expected the failures from the address to stay, got %+v", failures)
+	}
+	failures, _ = s.GetLoginFailuresByIp("10.0.0.1", time.Now().Add(time.Minute), int(next))
+	if failures.Count != 0 {
+		t.Errorf("Expected the older failures to be ignored, got %+v", failures)
+	}
+
+	s.DeleteLoginAttempt(int(next))
+	history, _ := s.GetLoginHistory(1, nil, 10)
+	if len(history) != 2 || !history[0].Success {
+		t.Errorf("Expected the deleted attempt to leave, and the finished one to be a login of toto, got %+v", history)
+	}
+}
+
+func TestLoginHistory(t *testing.T) {
+	s := New()
+	isNew, _ := s.CheckNewLoginDevice(1, "10.0.0.1", "agent")
+	if isNew {
+		t.Errorf("Expected the first login not to come from a new device")
+	}
+	s.AddLoginAttempt(models.LoginAttempt{Username: "toto", UserId: 1, IpAddress: "10.0.0.1", UserAgent: "agent", Method: models.LoginMethodPassword, Success: true})
+	s.AddLoginAttempt(models.LoginAttempt{Username: "toto", UserId: 1, IpAddress: "10.0.0.2", UserAgent: "agent", Method: models.LoginMethodPassword, Success: false})
+	s.AddLoginAttempt(models.LoginAttempt{Username: "titi", UserId: 2, IpAddress: "10.0.0.2", UserAgent: "agent", Method: models.LoginMethodGoogle, Success: true})
+	for _, device := range []struct {
+		ipAddress string
+		userAgent string
+		isNew     bool
+	}{{"10.0.0.1", "agent", false}, {"10.0.0.1", "other agent", true}, {"10.0.0.2", "agent", true}} {
+		isNew, _ := s.CheckNewLoginDevice(1, device.ipAddress, device.userAgent)
+		if isNew != device.isNew {
+			t.Errorf("Expected %t for %+v, got %t", device.isNew, device, isNew)
+		}
+	}
+
+	logins, _ := s.GetLoginHistory(1, nil, 1)
+	if len(logins) != 1 || logins[0].Success || logins[0].IpAddress != "10.0.0.2" {
+		t.Fatalf("Expected the failed login first, got %+v", logins)
+	}
+	logins, _ = s.GetLoginHistory(1, &models.Cursor{CreatedAt: logins[0].CreatedAt, Id: logins[0].Id}, 10)
+	if len(logins) != 1 || !logins[0].Success || logins[0].Method != models.LoginMethodPassword {
+		t.Errorf("Expected the successful login next, got %+v", logins)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	s := New()
+	moderatorId, err := s.GetRoleId("moderator")
+	if err != nil {
+		t.Fatalf("Error while getting moderator role: %s", err.Error())
+	}
+	s.GrantRole(1, moderatorId, 2)
+	if err := s.GrantRole(1, moderatorId, 2); err == nil {
+		t.Errorf("Expected role to be granted only once")
+	}
+
+	allowed, _ := s.CheckUserPermission(1, "users.ban")
+	if !allowed {
+		t.Errorf("Expected moderator to be allowed to ban")
+	}
+	allowed, _ = s.CheckUserPermission(1, "roles.manage")
+	if allowed {
+		t.Errorf("Expected moderator not to manage roles")
+	}
+
+	revoked, _ := s.RevokeRole(1, moderatorId)
+	if !revoked {
+		t.Errorf("Expected role to be revoked")
+	}
+	roles, _ := s.GetUserRoles(1)
+	if len(roles) != 0 {
+		t.Errorf("Expected no role, got %v", roles)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	s := New()
+	s.AddAuditEntry(models.AuditEntry{ModeratorId: 1, Action: "user.ban", TargetType: "user", TargetId: 2, IpAddress: "1.2.3.4"})
+	s.AddAuditEntry(models.AuditEntry{Action: "filter.decision", TargetType: "user", Payload: json.RawMessage(`{"after":{}}`)})
+	s.InTransaction(func(store database.Store) error {
+		return store.AddAuditEntry(models.AuditEntry{ModeratorId: 1, Action: "user.pardon", TargetType: "ban", TargetId: 3})
+	})
+
+	entries, _ := s.GetAuditEntries(models.AuditFilter{}, nil, 10)
+	if len(entries) != 3 || entries[0].Action != "user.pardon" || entries[1].TargetType != "" || string(entries[2].Payload) != "{}" {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+	entries, _ = s.GetAuditEntries(models.AuditFilter{ModeratorId: 1, TargetType: "user", TargetId: 2}, nil, 10)
+	if len(entries) != 1 || entries[0].Action != "user.ban" || entries[0].IpAddress != "1.2.3.4" {
+		t.Errorf("Expected the ban only, got %+v", entries)
+	}
+	entries, _ = s.GetAuditEntries(models.AuditFilter{Action: "filter.decision", From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}, nil, 10)
+	if len(entries) != 1 || entries[0].ModeratorId != 0 {
+		t.Errorf("Expected the decision of the filters only, got %+v", entries)
+	}
+	entries, _ = s.GetAuditEntries(models.AuditFilter{From: time.Now().Add(time.Hour)}, nil, 10)
+	if len(entries) != 0 {
+		t.Errorf("Expected no entry in the future, got %+v", entries)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	s := New()
+	s.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	s.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	s.AddFollow(1, 2)
+	s.IncrementRateLimit("1.2.3.4")
+	before := s.tables.clone()
+
+	// the rows changed in place, removed and added are put back when the transaction fails
+	err := s.InTransaction(func(store database.Store) error {
+		store.UpdateUserInformations(1, "Toto", "new@toto.fr")
+		store.RemoveFollow(1, 2)
+		store.IncrementRateLimit("1.2.3.4")
+		store.BanUser(2, 1, 0, "spam")
+		// the transactions started by the function join it
+		return store.InTransaction(func(store database.Store) error {
+			store.AddAuditEntry(models.AuditEntry{ModeratorId: 1, Action: "user.ban", TargetType: "user", TargetId: 2})
+			return errors.New("error")
+		})
+	})
+	if err == nil {
+		t.Fatalf("Expected the error of the transaction")
+	}
+	if !reflect.DeepEqual(s.tables, before) {
+		t.Errorf("Expected the store to be rolled back")
+	}
+	email, _, _ := s.GetUserEmail(1)
+	following, _ := s.CheckFollowExists(1, 2)
+	if email != "toto@toto.fr" || !following {
+		t.Errorf("Expected the email and the follow to be kept, got %s, %t", email, following)
+	}
+
+	err = s.InTransaction(func(store database.Store) error {
+		return store.RemoveFollow(1, 2)
+	})
+	following, _ = s.CheckFollowExists(1, 2)
+	if err != nil || following {
+		t.Errorf("Expected the follow to be removed, got %t, %v", following, err)
+	}
+}
@@ -2,61 +2,94 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
+	"project_truthful/models"
+	"strings"
 )
 
-func CheckAdminStatus(userId int, db *sql.DB) (bool, error) {
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE id = ? AND is_admin = 1", userId).Scan(&count)
-	if err != nil {
-		log.Printf("Error checking if user %d is an admin, %v\n", userId, err)
-		return false, err
+// AddAuditEntry records an action of a moderator, a ModeratorId of 0 records a decision of the content filters
+// and a TargetId of 0 an action without target
+func AddAuditEntry(entry models.AuditEntry, db Querier) error {
+	var targetType sql.NullString
+	if entry.TargetId != 0 {
+		targetType = sql.NullString{String: entry.TargetType, Valid: true}
 	}
-	return count > 0, nil
-}
-
-func CheckModeratorStatus(userId int, db *sql.DB) (bool, error) {
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE id = ? AND is_moderator = 1", userId).Scan(&count)
-	if err != nil {
-		log.Printf("Error checking if user %d is a moderator, %v\n", userId, err)
-		return false, err
+	var payload sql.NullString
+	if len(entry.Payload) > 0 {
+		payload = sql.NullString{String: string(entry.Payload), Valid: true}
 	}
-	return count > 0, nil
-}
-
-func PromoteUserToAdmin(userId int, db *sql.DB) error {
-	_, err := db.Exec("UPDATE user SET is_admin = 1 WHERE id = ?", userId)
+	_, err := db.Exec("INSERT INTO moderation_logging (user_id, action, target_type, target_id, payload, ip_address, user_agent) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		nullableId(entry.ModeratorId), entry.Action, targetType, nullableId(entry.TargetId), payload, entry.IpAddress, entry.UserAgent)
 	if err != nil {
-		log.Printf("Error promoting user %d to admin, %v\n", userId, err)
+		log.Printf("Error logging moderation action %s by moderator %d on %s %d, %v\n", entry.Action, entry.ModeratorId, entry.TargetType, entry.TargetId, err)
 		return err
 	}
 	return nil
 }
 
-func PromoteUserToModerator(userId int, db *sql.DB) error {
-	_, err := db.Exec("UPDATE user SET is_moderator = 1 WHERE id = ?", userId)
+// GetAuditEntries returns the entries of the audit log matching the filter after the cursor, most recent first
+func GetAuditEntries(filter models.AuditFilter, cursor *models.Cursor, count int, db Querier) ([]models.AuditEntry, error) {
+	conditions := []string{}
+	args := []any{}
+	if filter.ModeratorId != 0 {
+		conditions = append(conditions, "moderation_logging.user_id = ?")
+		args = append(args, filter.ModeratorId)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "moderation_logging.action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "moderation_logging.target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetId != 0 {
+		conditions = append(conditions, "moderation_logging.target_id = ?")
+		args = append(args, filter.TargetId)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "moderation_logging.created_at >= "+timeParameter())
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "moderation_logging.created_at < "+timeParameter())
+		args = append(args, filter.To.UTC())
+	}
+	condition, pageArgs := pageCondition("moderation_logging", cursor)
+	if condition != "" {
+		conditions = append(conditions, strings.TrimPrefix(condition, " AND "))
+		args = append(args, pageArgs...)
+	}
+	query := "SELECT id, user_id, action, target_type, target_id, payload, ip_address, user_agent, created_at FROM moderation_logging"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := db.Query(query+" ORDER BY moderation_logging.created_at DESC, moderation_logging.id DESC LIMIT ?", append(args, count)...)
 	if err != nil {
-		log.Printf("Error promoting user %d to moderator, %v\n", userId, err)
-		return err
+		log.Printf("Error getting audit entries, %v\n", err)
+		return nil, err
 	}
-	return nil
-}
+	defer rows.Close()
 
-func LogModerationAction(moderatorId int, action string, targetId int, db *sql.DB) error {
-	if targetId == 0 {
-		_, err := db.Exec("INSERT INTO moderation_logging (user_id, action) VALUES (?, ?)", moderatorId, action)
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var moderatorId, targetId sql.NullInt64
+		var targetType, payload sql.NullString
+		err := rows.Scan(&entry.Id, &moderatorId, &entry.Action, &targetType, &targetId, &payload, &entry.IpAddress, &entry.UserAgent, &entry.CreatedAt)
 		if err != nil {
-			log.Printf("Error logging moderation action %s by moderator %d, %v\n", action, moderatorId, err)
-			return err
+			log.Printf("Error scanning audit entries, %v\n", err)
+			return nil, err
 		}
-		return nil
-	} else {
-		_, err := db.Exec("INSERT INTO moderation_logging (user_id, action, target_id) VALUES (?, ?, ?)", moderatorId, action, targetId)
-		if err != nil {
-			log.Printf("Error logging moderation action %s by moderator %d on target %d, %v\n", action, moderatorId, targetId, err)
-			return err
+		entry.ModeratorId = int(moderatorId.Int64)
+		entry.TargetType = targetType.String
+		entry.TargetId = int(targetId.Int64)
+		entry.Payload = json.RawMessage("{}")
+		if payload.Valid {
+			entry.Payload = json.RawMessage(payload.String)
 		}
-		return nil
+		entries = append(entries, entry)
 	}
+	return entries, nil
 }
@@ -1,135 +1,127 @@
 package database
 
 import (
+	"encoding/json"
+	"errors"
+	"project_truthful/models"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
-func TestCheckAdminStatus(t *testing.T) {
+func TestAddAuditEntry(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	_, err = CheckAdminStatus(1, db)
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
+
+	// decision of the filters, without target
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(nil, "filter.decision", nil, nil, nil, "1.2.3.4", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	err = AddAuditEntry(models.AuditEntry{Action: "filter.decision", TargetType: "user", IpAddress: "1.2.3.4"}, db)
 	if err != nil {
-		t.Errorf("Error while checking admin status: %s", err.Error())
+		t.Errorf("Error while adding audit entry: %s", err.Error())
 	}
-}
 
-func TestCheckAdminStatusError(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	// with target and payload
+	entry := models.AuditEntry{ModeratorId: 1, Action: "question.remove", TargetType: "question", TargetId: 2, Payload: json.RawMessage(`{"after":{}}`), IpAddress: "1.2.3.4", UserAgent: "agent"}
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "question.remove", "question", 2, `{"after":{}}`, "1.2.3.4", "agent").WillReturnResult(sqlmock.NewResult(2, 1))
+	err = AddAuditEntry(entry, db)
 	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
-	}
-	defer db.Close()
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnError(err)
-	_, err = CheckAdminStatus(1, db)
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+		t.Errorf("Error while adding audit entry: %s", err.Error())
 	}
+
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnError(errors.New("error"))
+	err = AddAuditEntry(entry, db)
 	if err == nil {
 		t.Errorf("Error should not be nil")
 	}
-}
-
-func TestCheckModeratorStatus(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
-	}
-	defer db.Close()
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	_, err = CheckModeratorStatus(1, db)
-	if mock.ExpectationsWereMet() != nil {
+	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
-	if err != nil {
-		t.Errorf("Error while checking moderator status: %s", err.Error())
-	}
 }
 
-func TestCheckModeratorStatusError(t *testing.T) {
+func TestGetAuditEntries(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnError(err)
-	_, err = CheckModeratorStatus(1, db)
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
-	}
-}
+	columns := []string{"id", "user_id", "action", "target_type", "target_id", "payload", "ip_address", "user_agent", "created_at"}
+	now := time.Now()
 
-func TestPromoteUserToAdmin(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	mock.ExpectQuery("SELECT (.+) FROM moderation_logging ORDER BY (.+) LIMIT \\?").WithArgs(10).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(2, 1, "user.ban", "user", 3, `{"after":{"duration":0}}`, "1.2.3.4", "agent", now).
+		AddRow(1, nil, "filter.decision", nil, nil, nil, "", "", now))
+	entries, err := GetAuditEntries(models.AuditFilter{}, nil, 10, db)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v and %v", entries, err)
 	}
-	defer db.Close()
-	mock.ExpectExec("UPDATE user").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
-	err = PromoteUserToAdmin(1, db)
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+	if entries[0].ModeratorId != 1 || entries[0].TargetType != "user" || entries[0].TargetId != 3 || string(entries[0].Payload) != `{"after":{"duration":0}}` {
+		t.Errorf("Unexpected entry %+v", entries[0])
 	}
-	if err != nil {
-		t.Errorf("Error while promoting user to admin: %s", err.Error())
+	if entries[1].ModeratorId != 0 || entries[1].TargetId != 0 || string(entries[1].Payload) != "{}" {
+		t.Errorf("Expected an entry of the filters without target, got %+v", entries[1])
 	}
-}
 
-func TestPromoteUserToAdminError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
-	}
-	defer db.Close()
-	mock.ExpectExec("UPDATE user").WithArgs(1).WillReturnError(err)
-	err = PromoteUserToAdmin(1, db)
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+	// every filter and the cursor
+	from := now.Add(-time.Hour)
+	filter := models.AuditFilter{ModeratorId: 1, Action: "user.ban", TargetType: "user", TargetId: 3, From: from, To: now}
+	mock.ExpectQuery("WHERE moderation_logging.user_id = \\? AND moderation_logging.action = \\? AND moderation_logging.target_type = \\? AND moderation_logging.target_id = \\? "+
+		"AND moderation_logging.created_at >= \\? AND moderation_logging.created_at < \\? AND \\(moderation_logging.created_at < \\?").
+		WithArgs(1, "user.ban", "user", 3, from.UTC(), now.UTC(), now.UTC(), now.UTC(), 5, 10).WillReturnRows(sqlmock.NewRows(columns))
+	entries, err = GetAuditEntries(filter, &models.Cursor{CreatedAt: now, Id: 5}, 10, db)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entry, got %+v and %v", entries, err)
 	}
+
+	mock.ExpectQuery("SELECT (.+) FROM moderation_logging").WillReturnError(errors.New("error"))
+	_, err = GetAuditEntries(models.AuditFilter{}, nil, 10, db)
 	if err == nil {
 		t.Errorf("Error should not be nil")
 	}
-}
-
-func TestPromoteUserToModerator(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
-	}
-	defer db.Close()
-	mock.ExpectExec("UPDATE user").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
-	err = PromoteUserToModerator(1, db)
-	if mock.ExpectationsWereMet() != nil {
+	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
-	if err != nil {
-		t.Errorf("Error while promoting user to moderator: %s", err.Error())
-	}
 }
 
-func TestPromoteUserToModeratorError(t *testing.T) {
+func TestInTransaction(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
-	mock.ExpectExec("UPDATE user").WithArgs(1).WillReturnError(err)
-	err = PromoteUserToModerator(1, db)
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+	store := NewSQLStore(db)
+	entry := models.AuditEntry{ModeratorId: 1, Action: "user.pardon"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err = store.InTransaction(func(tx Store) error {
+		return tx.AddAuditEntry(entry)
+	})
+	if err != nil {
+		t.Errorf("Error while running transaction: %s", err.Error())
 	}
+
+	// the writes are rolled back when the function fails, nested transactions join the outer one
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectRollback()
+	err = store.InTransaction(func(tx Store) error {
+		return tx.InTransaction(func(nested Store) error {
+			err := nested.AddAuditEntry(entry)
+			if err != nil {
+				return err
+			}
+			return errors.New("error")
+		})
+	})
 	if err == nil {
 		t.Errorf("Error should not be nil")
 	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
 }
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"log"
+	"project_truthful/models"
+)
+
+func CheckMuteExists(muterId int, mutedId int, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user_mute WHERE muter_id = ? AND muted_id = ?", muterId, mutedId).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking if mute exists for muter %d and muted %d, %v\n", muterId, mutedId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AddMute returns false if the mute already exists
+func AddMute(muterId int, mutedId int, db Querier) (bool, error) {
+	result, err := db.Exec(insertIgnore()+" INTO user_mute (muter_id, muted_id) VALUES (?, ?)", muterId, mutedId)
+	if err != nil {
+		log.Printf("Error inserting mute for muter %d and muted %d, %v\n", muterId, mutedId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for mute of muter %d and muted %d, %v\n", muterId, mutedId, err)
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// RemoveMute returns false if there was no mute to remove
+func RemoveMute(muterId int, mutedId int, db Querier) (bool, error) {
+	result, err := db.Exec("DELETE FROM user_mute WHERE muter_id = ? AND muted_id = ?", muterId, mutedId)
+	if err != nil {
+		log.Printf("Error deleting mute for muter %d and muted %d, %v\n", muterId, mutedId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for unmute of muter %d and muted %d, %v\n", muterId, mutedId, err)
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// GetMutedUsers returns the users muted by the user after the cursor, most recent mute first
+func GetMutedUsers(userId int, cursor *models.Cursor, count int, db Querier) ([]models.RelatedUser, error) {
+	return getRelatedUsers("user_mute", "muter_id", "muted_id", userId, cursor, count, db)
+}
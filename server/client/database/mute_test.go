@@ -0,0 +1,87 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckMuteExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_mute").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	exists, err := CheckMuteExists(1, 2, db)
+	if err != nil {
+		t.Errorf("Error while checking if mute exists: %s", err.Error())
+	}
+	if exists {
+		t.Errorf("Mute should not exist")
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_mute").WithArgs(1, 3).WillReturnError(errors.New("error"))
+	_, err = CheckMuteExists(1, 3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestAddAndRemoveMute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("INSERT IGNORE INTO user_mute").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	created, err := AddMute(1, 2, db)
+	if err != nil || !created {
+		t.Errorf("Expected the mute to be created, got %t and %v", created, err)
+	}
+	mock.ExpectExec("INSERT IGNORE INTO user_mute").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	created, err = AddMute(1, 2, db)
+	if err != nil || created {
+		t.Errorf("Expected the mute to exist already, got %t and %v", created, err)
+	}
+	mock.ExpectExec("INSERT IGNORE INTO user_mute").WithArgs(1, 3).WillReturnError(errors.New("error"))
+	_, err = AddMute(1, 3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+
+	mock.ExpectExec("DELETE FROM user_mute").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	removed, err := RemoveMute(1, 2, db)
+	if err != nil || !removed {
+		t.Errorf("Expected the mute to be removed, got %t and %v", removed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetMutedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT user_mute.id, user_mute.created_at, user.id, user.username, user.display_name FROM user_mute JOIN user ON user.id = user_mute.muted_id WHERE user_mute.muter_id = \\?").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "id", "username", "display_name"}).AddRow(1, time.Now(), 2, "titi", "Titi"))
+	users, err := GetMutedUsers(1, nil, 3, db)
+	if err != nil {
+		t.Errorf("Error while getting muted users: %s", err.Error())
+	}
+	if len(users) != 1 || users[0].Username != "titi" {
+		t.Errorf("Expected titi, got %+v", users)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"log"
+)
+
+// GetMutedWords returns the words muted by the user, in the order they were muted
+func GetMutedWords(userId int, db Querier) ([]string, error) {
+	rows, err := db.Query("SELECT word FROM muted_word WHERE user_id = ? ORDER BY id", userId)
+	if err != nil {
+		log.Printf("Error getting muted words of user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []string{}
+	for rows.Next() {
+		var word string
+		err := rows.Scan(&word)
+		if err != nil {
+			log.Printf("Error scanning muted words of user %d, %v\n", userId, err)
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
+
+// AddMutedWord returns false if the user already muted the word
+func AddMutedWord(userId int, word string, db Querier) (bool, error) {
+	result, err := db.Exec(insertIgnore()+" INTO muted_word (user_id, word) VALUES (?, ?)", userId, word)
+	if err != nil {
+		log.Printf("Error muting word for user %d, %v\n", userId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for muted word of user %d, %v\n", userId, err)
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// RemoveMutedWord returns false if the user didn't mute the word
+func RemoveMutedWord(userId int, word string, db Querier) (bool, error) {
+	result, err := db.Exec("DELETE FROM muted_word WHERE user_id = ? AND word = ?", userId, word)
+	if err != nil {
+		log.Printf("Error unmuting word for user %d, %v\n", userId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for muted word of user %d, %v\n", userId, err)
+		return false, err
+	}
+	return rows > 0, nil
+}
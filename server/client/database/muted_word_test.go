@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetMutedWords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT word FROM muted_word WHERE user_id = \\? ORDER BY id").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"word"}).AddRow("pineapple").AddRow("pizza"))
+	words, err := GetMutedWords(1, db)
+	if err != nil || len(words) != 2 || words[0] != "pineapple" {
+		t.Errorf("Expected pineapple and pizza, got %v and %v", words, err)
+	}
+
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(2).WillReturnError(errors.New("error"))
+	_, err = GetMutedWords(2, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestAddMutedWord(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("INSERT IGNORE INTO muted_word").WithArgs(1, "pizza").WillReturnResult(sqlmock.NewResult(1, 1))
+	created, err := AddMutedWord(1, "pizza", db)
+	if err != nil || !created {
+		t.Errorf("Expected the word to be muted, got %v and %v", created, err)
+	}
+
+	mock.ExpectExec("INSERT IGNORE INTO muted_word").WithArgs(1, "pizza").WillReturnResult(sqlmock.NewResult(0, 0))
+	created, err = AddMutedWord(1, "pizza", db)
+	if err != nil || created {
+		t.Errorf("Expected the word to be already muted, got %v and %v", created, err)
+	}
+
+	mock.ExpectExec("INSERT IGNORE INTO muted_word").WithArgs(1, "pizza").WillReturnError(errors.New("error"))
+	_, err = AddMutedWord(1, "pizza", db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRemoveMutedWord(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM muted_word WHERE user_id = \\? AND word = \\?").WithArgs(1, "pizza").WillReturnResult(sqlmock.NewResult(0, 1))
+	removed, err := RemoveMutedWord(1, "pizza", db)
+	if err != nil || !removed {
+		t.Errorf("Expected the word to be unmuted, got %v and %v", removed, err)
+	}
+
+	mock.ExpectExec("DELETE FROM muted_word").WithArgs(1, "pizza").WillReturnResult(sqlmock.NewResult(0, 0))
+	removed, err = RemoveMutedWord(1, "pizza", db)
+	if err != nil || removed {
+		t.Errorf("Expected the word not to be muted, got %v and %v", removed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
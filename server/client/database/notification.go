@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+)
+
+// nullableId stores the id 0 as NULL
+func nullableId(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// AddNotification records a notification for the user, the actor, question and answer are left NULL when 0
+func AddNotification(userId int, notificationType string, actorId int, questionId int, answerId int, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO notification (user_id, type, actor_id, question_id, answer_id) VALUES (?, ?, ?, ?, ?)", userId, notificationType, nullableId(actorId), nullableId(questionId), nullableId(answerId))
+	if err != nil {
+		log.Printf("Error inserting %s notification for user %d, %v\n", notificationType, userId, err)
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting last inserted id for notification, %v\n", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetNotifications returns the notifications of the user after the cursor, newest first
+func GetNotifications(userId int, cursor *models.Cursor, count int, db Querier) ([]models.Notification, error) {
+	condition, args := pageCondition("notification", cursor)
+	args = append([]any{userId}, append(args, count)...)
+	rows, err := db.Query("SELECT notification.id, notification.type, notification.question_id, notification.answer_id, notification.created_at, notification.read_at, user.id, user.username, user.display_name "+
+		"FROM notification LEFT JOIN user ON user.id = notification.actor_id WHERE notification.user_id = ?"+
+		condition+" ORDER BY notification.created_at DESC, notification.id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("Error getting notifications for user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var notification models.Notification
+		var questionId, answerId, actorId sql.NullInt64
+		var readAt sql.NullTime
+		var actorUsername, actorDisplayName sql.NullString
+		err := rows.Scan(&notification.Id, &notification.Type, &questionId, &answerId, &notification.CreatedAt, &readAt, &actorId, &actorUsername, &actorDisplayName)
+		if err != nil {
+			log.Printf("Error scanning notification for user %d, %v\n", userId, err)
+			return nil, err
+		}
+		notification.QuestionId = int(questionId.Int64)
+		notification.AnswerId = int(answerId.Int64)
+		notification.IsRead = readAt.Valid
+		if actorId.Valid {
+			notification.Actor = &models.UserPreview{Id: actorId.Int64, Username: actorUsername.String, DisplayName: actorDisplayName.String}
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
+
+func CountUnreadNotifications(userId int, db Querier) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM notification WHERE user_id = ? AND read_at IS NULL", userId).Scan(&count)
+	if err != nil {
+		log.Printf("Error counting unread notifications for user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkNotificationAsRead returns false if the user has no such notification, marking a read notification again keeps its read date
+func MarkNotificationAsRead(userId int, notificationId int, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE notification SET read_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND read_at IS NULL", notificationId, userId)
+	if err != nil {
+		log.Printf("Error marking notification %d of user %d as read, %v\n", notificationId, userId, err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for notification %d, %v\n", notificationId, err)
+		return false, err
+	}
+	if rows > 0 {
+		return true, nil
+	}
+
+	// nothing was updated, the notification is either read already or not one of the user
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM notification WHERE id = ? AND user_id = ?", notificationId, userId).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking if notification %d of user %d exists, %v\n", notificationId, userId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func MarkAllNotificationsAsRead(userId int, db Querier) error {
+	_, err := db.Exec("UPDATE notification SET read_at = CURRENT_TIMESTAMP WHERE user_id = ? AND read_at IS NULL", userId)
+	if err != nil {
+		log.Printf("Error marking notifications of user %d as read, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
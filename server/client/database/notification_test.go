@@ -0,0 +1,154 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAddNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	// the missing actor and answer are stored as NULL
+	mock.ExpectExec("INSERT INTO notification").WithArgs(1, "question_received", nil, 3, nil).WillReturnResult(sqlmock.NewResult(4, 1))
+	id, err := AddNotification(1, "question_received", 0, 3, 0, db)
+	if err != nil || id != 4 {
+		t.Errorf("Expected notification 4, got %d, %v", id, err)
+	}
+
+	mock.ExpectExec("INSERT INTO notification").WithArgs(1, "new_follower", 2, nil, nil).WillReturnError(errors.New("error"))
+	_, err = AddNotification(1, "new_follower", 2, 0, 0, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetNotifications(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	createdAt := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	columns := []string{"id", "type", "question_id", "answer_id", "created_at", "read_at", "id", "username", "display_name"}
+	rows := sqlmock.NewRows(columns).
+		AddRow(5, "answer_liked", nil, 3, createdAt, createdAt, 2, "titi", "Titi").
+		AddRow(4, "question_received", 7, nil, createdAt, nil, nil, nil, nil)
+	mock.ExpectQuery("SELECT (.+) FROM notification LEFT JOIN user ON user.id = notification.actor_id WHERE notification.user_id = \\? ORDER BY notification.created_at DESC, notification.id DESC LIMIT \\?").
+		WithArgs(1, 11).WillReturnRows(rows)
+	notifications, err := GetNotifications(1, nil, 11, db)
+	if err != nil || len(notifications) != 2 {
+		t.Fatalf("Expected 2 notifications, got %+v, %v", notifications, err)
+	}
+	if !notifications[0].IsRead || notifications[0].Actor == nil || notifications[0].Actor.Username != "titi" || notifications[0].AnswerId != 3 {
+		t.Errorf("Unexpected notification %+v", notifications[0])
+	}
+	if notifications[1].IsRead || notifications[1].Actor != nil || notifications[1].QuestionId != 7 {
+		t.Errorf("Unexpected anonymous notification %+v", notifications[1])
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM notification (.+) AND \\(notification.created_at < \\? OR").WithArgs(1, createdAt, createdAt, 4, 11).WillReturnError(errors.New("error"))
+	_, err = GetNotifications(1, &models.Cursor{CreatedAt: createdAt, Id: 4}, 11, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestCountUnreadNotifications(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM notification WHERE user_id = \\? AND read_at IS NULL").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+	count, err := CountUnreadNotifications(1, db)
+	if err != nil || count != 3 {
+		t.Errorf("Expected 3 unread notifications, got %d, %v", count, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM notification").WithArgs(1).WillReturnError(errors.New("error"))
+	_, err = CountUnreadNotifications(1, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestMarkNotificationAsRead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(4, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	exists, err := MarkNotificationAsRead(1, 4, db)
+	if err != nil || !exists {
+		t.Errorf("Expected notification to be read, got %t, %v", exists, err)
+	}
+
+	// already read
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(4, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM notification WHERE id = \\? AND user_id = \\?").WithArgs(4, 1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	exists, err = MarkNotificationAsRead(1, 4, db)
+	if err != nil || !exists {
+		t.Errorf("Expected read notification to exist, got %t, %v", exists, err)
+	}
+
+	// notification of another user
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM notification").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	exists, err = MarkNotificationAsRead(1, 5, db)
+	if err != nil || exists {
+		t.Errorf("Expected notification not to exist, got %t, %v", exists, err)
+	}
+
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(4, 1).WillReturnError(errors.New("error"))
+	_, err = MarkNotificationAsRead(1, 4, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestMarkAllNotificationsAsRead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE notification SET read_at = CURRENT_TIMESTAMP WHERE user_id = \\? AND read_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	err = MarkAllNotificationsAsRead(1, db)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(1).WillReturnError(errors.New("error"))
+	err = MarkAllNotificationsAsRead(1, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"project_truthful/models"
+)
+
+// pageCondition restricts a query ordered by created_at DESC, id DESC to the rows after the cursor,
+// the columns are those of the given table
+func pageCondition(table string, cursor *models.Cursor) (string, []any) {
+	return pageConditionOn(table+".created_at", table+".id", cursor)
+}
+
+// pageConditionOn is pageCondition for a query ordered by other columns than created_at and id
+func pageConditionOn(createdAtColumn string, idColumn string, cursor *models.Cursor) (string, []any) {
+	if cursor == nil {
+		return "", nil
+	}
+	createdAt := timeParameter()
+	condition := " AND (" + createdAtColumn + " < " + createdAt + " OR (" + createdAtColumn + " = " + createdAt + " AND " + idColumn + " < ?))"
+	return condition, []any{cursor.CreatedAt.UTC(), cursor.CreatedAt.UTC(), cursor.Id}
+}
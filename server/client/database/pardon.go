@@ -1,20 +1,27 @@
 package database
 
 import (
-	"database/sql"
 	"log"
 )
 
-func PardonUser(banId int, requesterId int, db *sql.DB) (int64, error) {
-	var pardonId int64
-
-	result, err := db.Exec("INSERT INTO pardon (ban_id, pardoner_id) VALUES (?, ?)", banId, requesterId)
+// PardonUser returns 0 if the ban is already pardoned, e.g. by a concurrent request
+func PardonUser(banId int, requesterId int, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO pardon (ban_id, pardoner_id) SELECT ban.id, ? FROM ban WHERE ban.id = ? "+
+		"AND NOT EXISTS (SELECT 1 FROM pardon WHERE pardon.ban_id = ban.id)", requesterId, banId)
 	if err != nil {
 		log.Printf("Error pardoning user %d, %v\n", banId, err)
 		return 0, err
 	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for pardon of ban %d, %v\n", banId, err)
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, nil
+	}
 
-	pardonId, err = result.LastInsertId()
+	pardonId, err := result.LastInsertId()
 	if err != nil {
 		log.Printf("Error getting pardon ID, %v\n", err)
 		return 0, err
@@ -23,7 +30,7 @@ func PardonUser(banId int, requesterId int, db *sql.DB) (int64, error) {
 	return pardonId, nil
 }
 
-func CheckPardonExists(banId int, db *sql.DB) (bool, error) {
+func CheckPardonExists(banId int, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM pardon WHERE ban_id = ?", banId).Scan(&count)
 	if err != nil {
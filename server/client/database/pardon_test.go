@@ -49,9 +49,16 @@ func TestPardonUser(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectExec("INSERT INTO pardon").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-	_, err = PardonUser(1, 1, db)
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
+	pardonId, err := PardonUser(1, 1, db)
+	if err != nil || pardonId != 1 {
+		t.Errorf("expected pardon 1, got %d, %v", pardonId, err)
+	}
+
+	// the ban is already pardoned
+	mock.ExpectExec("INSERT INTO pardon (.+) FROM ban WHERE ban.id = \\? AND NOT EXISTS").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	pardonId, err = PardonUser(2, 1, db)
+	if err != nil || pardonId != 0 {
+		t.Errorf("expected no pardon, got %d, %v", pardonId, err)
 	}
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("expectations were not met: %s", err)
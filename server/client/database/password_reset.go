@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+	"time"
+)
+
+// AddPasswordResetRequest records a request of the password reset flow, userId is 0 when it matches no account
+func AddPasswordResetRequest(ipAddress string, userId int, db Querier) error {
+	var user sql.NullInt64
+	if userId != 0 {
+		user = sql.NullInt64{Int64: int64(userId), Valid: true}
+	}
+	_, err := db.Exec("INSERT INTO password_reset_request (ip_address, user_id) VALUES (?, ?)", ipAddress, user)
+	if err != nil {
+		log.Printf("Error recording password reset request from ip %s, %v\n", ipAddress, err)
+		return err
+	}
+	return nil
+}
+
+func CountPasswordResetRequestsByIp(ipAddress string, since time.Time, db Querier) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM password_reset_request WHERE ip_address = ? AND created_at > "+timeParameter(), ipAddress, since.UTC()).Scan(&count)
+	if err != nil {
+		log.Printf("Error counting password reset requests from ip %s, %v\n", ipAddress, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func CountPasswordResetRequestsByUser(userId int, since time.Time, db Querier) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM password_reset_request WHERE user_id = ? AND created_at > "+timeParameter(), userId, since.UTC()).Scan(&count)
+	if err != nil {
+		log.Printf("Error counting password reset requests of user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func AddPasswordResetToken(userId int, tokenHash string, expiresAt time.Time, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO password_reset_token (user_id, token_hash, expires_at) VALUES (?, ?, ?)", userId, tokenHash, expiresAt.UTC())
+	if err != nil {
+		log.Printf("Error inserting password reset token for user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetPasswordResetToken returns sql.ErrNoRows when no token has the hash
+func GetPasswordResetToken(tokenHash string, db Querier) (models.PasswordResetToken, error) {
+	var resetToken models.PasswordResetToken
+	err := db.QueryRow("SELECT id, user_id, expires_at, used_at IS NOT NULL FROM password_reset_token WHERE token_hash = ?", tokenHash).Scan(&resetToken.Id, &resetToken.UserId, &resetToken.ExpiresAt, &resetToken.IsUsed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting password reset token, %v\n", err)
+		return models.PasswordResetToken{}, err
+	}
+	return resetToken, err
+}
+
+// UsePasswordResetToken returns false if the token had already been used by a concurrent request
+func UsePasswordResetToken(id int, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE password_reset_token SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL", id)
+	if err != nil {
+		log.Printf("Error using password reset token %d, %v\n", id, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for password reset token %d, %v\n", id, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RevokePasswordResetTokens marks every unused token of the user as used
+func RevokePasswordResetTokens(userId int, db Querier) error {
+	_, err := db.Exec("UPDATE password_reset_token SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND used_at IS NULL", userId)
+	if err != nil {
+		log.Printf("Error revoking password reset tokens of user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPasswordResetRequests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	since := time.Now().UTC()
+
+	// requests matching no account have no user
+	mock.ExpectExec("INSERT INTO password_reset_request").WithArgs("10.0.0.1", nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO password_reset_request").WithArgs("10.0.0.1", 2).WillReturnResult(sqlmock.NewResult(2, 1))
+	err = AddPasswordResetRequest("10.0.0.1", 0, db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	err = AddPasswordResetRequest("10.0.0.1", 2, db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE ip_address = (.+) AND created_at > ").WithArgs("10.0.0.1", since).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	count, err := CountPasswordResetRequestsByIp("10.0.0.1", since, db)
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2 requests, got %d, %v", count, err)
+	}
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE user_id = (.+) AND created_at > ").WithArgs(2, since).WillReturnError(errors.New("error"))
+	_, err = CountPasswordResetRequestsByUser(2, since, db)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestPasswordResetTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	expiresAt := time.Now().Add(time.Hour).UTC()
+
+	mock.ExpectExec("INSERT INTO password_reset_token").WithArgs(2, "hash", expiresAt).WillReturnResult(sqlmock.NewResult(1, 1))
+	id, err := AddPasswordResetToken(2, "hash", expiresAt, db)
+	if err != nil || id != 1 {
+		t.Errorf("Expected token 1, got %d, %v", id, err)
+	}
+
+	mock.ExpectQuery("SELECT id, user_id, expires_at, used_at IS NOT NULL FROM password_reset_token WHERE token_hash = ").WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at", "used"}).AddRow(1, 2, expiresAt, false))
+	resetToken, err := GetPasswordResetToken("hash", db)
+	if err != nil || resetToken.UserId != 2 || resetToken.IsUsed || !resetToken.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Unexpected token %+v, %v", resetToken, err)
+	}
+	mock.ExpectQuery("FROM password_reset_token WHERE token_hash = ").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	_, err = GetPasswordResetToken("unknown", db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	// a token can only be used once
+	mock.ExpectExec("UPDATE password_reset_token SET used_at = CURRENT_TIMESTAMP WHERE id = (.+) AND used_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE password_reset_token SET used_at = CURRENT_TIMESTAMP WHERE id = (.+) AND used_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	used, err := UsePasswordResetToken(1, db)
+	if err != nil || !used {
+		t.Errorf("Expected the token to be used, got %t, %v", used, err)
+	}
+	used, err = UsePasswordResetToken(1, db)
+	if err != nil || used {
+		t.Errorf("Expected the token to be used already, got %t, %v", used, err)
+	}
+
+	mock.ExpectExec("UPDATE password_reset_token SET used_at = CURRENT_TIMESTAMP WHERE user_id = (.+) AND used_at IS NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	err = RevokePasswordResetTokens(2, db)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
@@ -1,12 +1,40 @@
 package database
 
 import (
-	"database/sql"
 	"log"
 	"project_truthful/models"
 )
 
-func GetUserProfileInfos(id int, requestingUser int, count int, start int, db *sql.DB) (models.UserProfileInfos, error) {
+func GetUserProfileInfos(id int, requestingUser int, count int, start int, db Querier) (models.UserProfileInfos, error) {
+	infos, err := getProfileCounts(id, db)
+	if err != nil {
+		return models.UserProfileInfos{}, err
+	}
+
+	infos.Answers, err = getAnswers(id, requestingUser, count, start, db)
+	if err != nil {
+		log.Printf("Error getting answers for id %d, %v\n", id, err)
+		return models.UserProfileInfos{}, err
+	}
+	return infos, nil
+}
+
+// GetUserProfileInfosPage returns the profile of the user with their answers after the cursor
+func GetUserProfileInfosPage(id int, requestingUser int, cursor *models.Cursor, count int, db Querier) (models.UserProfileInfos, error) {
+	infos, err := getProfileCounts(id, db)
+	if err != nil {
+		return models.UserProfileInfos{}, err
+	}
+
+	infos.Answers, err = getAnswersPage(id, requestingUser, cursor, count, db)
+	if err != nil {
+		log.Printf("Error getting answers for id %d, %v\n", id, err)
+		return models.UserProfileInfos{}, err
+	}
+	return infos, nil
+}
+
+func getProfileCounts(id int, db Querier) (models.UserProfileInfos, error) {
 	username, displayName, err := GetUsernameAndDisplayName(id, db)
 	if err != nil {
 		log.Printf("Error getting user profile infos for id %d, %v\n", id, err)
@@ -34,11 +62,5 @@ func GetUserProfileInfos(id int, requestingUser int, count int, start int, db *s
 		return models.UserProfileInfos{}, err
 	}
 
-	answers, err := getAnswers(id, requestingUser, count, start, db)
-	if err != nil {
-		log.Printf("Error getting answers for id %d, %v\n", id, err)
-		return models.UserProfileInfos{}, err
-	}
-
-	return models.UserProfileInfos{Id: id, Username: username, DisplayName: displayName, FollowerCount: followerCount, FollowingCount: followingCount, AnswerCount: answerCount, Answers: answers}, nil
+	return models.UserProfileInfos{Id: id, Username: username, DisplayName: displayName, FollowerCount: followerCount, FollowingCount: followingCount, AnswerCount: answerCount}, nil
 }
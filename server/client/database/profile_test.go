@@ -67,7 +67,7 @@ func TestGetUserProfileInfosError(t *testing.T) {
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(5, 0, 30).WillReturnError(errors.New("error for db test"))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(5, 30, 0).WillReturnError(errors.New("error for db test"))
 	_, err = GetUserProfileInfos(5, 0, 30, 0, db)
 	if err == nil {
 		t.Errorf("Database error: expected error, got nil")
@@ -110,11 +110,11 @@ func TestGetProfileInfos(t *testing.T) {
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, 1, "answer_text", creationTime))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "answer_text", creationTime, false))
 
-	questionRows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).
-		AddRow(1, "question_text", 2, false, 1, creationTime)
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question").WithArgs(1).WillReturnRows(questionRows)
+	questionRows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+		AddRow(1, "question_text", 2, false, 1, creationTime, false)
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(questionRows)
 	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("username_author", "display_name_author"))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	profile, err := GetUserProfileInfos(1, 0, 30, 0, db)
@@ -6,9 +6,9 @@ import (
 	"project_truthful/models"
 )
 
-func GetQuestions(userId int, start int, end int, db *sql.DB) ([]models.Question, error) {
+func GetQuestions(userId int, start int, count int, db Querier) ([]models.Question, error) {
 	//selects all questions in database where receiver_id = userId
-	rows, err := db.Query("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question WHERE receiver_id = ? ORDER BY created_at DESC LIMIT ?, ?", userId, start, end)
+	rows, err := db.Query("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question WHERE receiver_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?", userId, count, start)
 	if err != nil {
 		log.Printf("Error getting questions for user %d, %v\n", userId, err)
 		return nil, err
@@ -20,7 +20,7 @@ func GetQuestions(userId int, start int, end int, db *sql.DB) ([]models.Question
 		// TODO : put this in a function
 		var curQuestion models.Question
 		var authorId sql.NullInt64
-		err := rows.Scan(&curQuestion.Id, &curQuestion.Text, &authorId, &curQuestion.IsAuthorAnonymous, &curQuestion.ReceiverId, &curQuestion.CreatedAt)
+		err := rows.Scan(&curQuestion.Id, &curQuestion.Text, &authorId, &curQuestion.IsAuthorAnonymous, &curQuestion.ReceiverId, &curQuestion.CreatedAt, &curQuestion.RemovedByModeration)
 		if err != nil {
 			log.Printf("Error scanning question for user %d, %v\n", userId, err)
 			return nil, err
@@ -50,7 +50,39 @@ func GetQuestions(userId int, start int, end int, db *sql.DB) ([]models.Question
 	return questions, nil
 }
 
-func GetQuestionReceiverId(questionId int, db *sql.DB) (int, error) {
+// GetQuestionsPage returns the unanswered questions received by the user after the cursor, newest first
+func GetQuestionsPage(userId int, cursor *models.Cursor, count int, db Querier) ([]models.Question, error) {
+	condition, args := pageCondition("question", cursor)
+	args = append([]any{userId}, append(args, count)...)
+	rows, err := db.Query("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question WHERE receiver_id = ? AND has_been_deleted = 0 AND NOT EXISTS (SELECT 1 FROM answer WHERE answer.question_id = question.id AND answer.has_been_deleted = 0)"+condition+" ORDER BY created_at DESC, id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("Error getting questions for user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+	questions := []models.Question{}
+	for rows.Next() {
+		var question models.Question
+		var authorId sql.NullInt64
+		err := rows.Scan(&question.Id, &question.Text, &authorId, &question.IsAuthorAnonymous, &question.ReceiverId, &question.CreatedAt, &question.RemovedByModeration)
+		if err != nil {
+			log.Printf("Error scanning question for user %d, %v\n", userId, err)
+			return nil, err
+		}
+		if !question.IsAuthorAnonymous && authorId.Valid {
+			question.Author.Id = authorId.Int64
+			question.Author.Username, question.Author.DisplayName, err = GetUsernameAndDisplayName(int(authorId.Int64), db)
+			if err != nil {
+				log.Printf("Error getting author username and display name for question %d, %v\n", question.Id, err)
+				return nil, err
+			}
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+func GetQuestionReceiverId(questionId int, db Querier) (int, error) {
 	var userId int
 	err := db.QueryRow("SELECT receiver_id FROM question WHERE id = ?", questionId).Scan(&userId)
 	if err != nil && err != sql.ErrNoRows {
@@ -62,7 +94,18 @@ func GetQuestionReceiverId(questionId int, db *sql.DB) (int, error) {
 	return userId, nil
 }
 
-func AddQuestion(question string, authorId int, authorIpAddress string, isAuthorAnonymous bool, receiverId int, db *sql.DB) (int64, error) {
+// GetQuestionAuthorIpAddress returns the IP address the question was asked from, it must never be sent to clients
+func GetQuestionAuthorIpAddress(questionId int, db Querier) (string, error) {
+	var ipAddress string
+	err := db.QueryRow("SELECT author_ip_address FROM question WHERE id = ?", questionId).Scan(&ipAddress)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting author ip address for question %d, %v\n", questionId, err)
+		return "", err
+	}
+	return ipAddress, err
+}
+
+func AddQuestion(question string, authorId int, authorIpAddress string, isAuthorAnonymous bool, receiverId int, db Querier) (int64, error) {
 	var result sql.Result
 	var err error
 
@@ -84,10 +127,10 @@ func AddQuestion(question string, authorId int, authorIpAddress string, isAuthor
 	return id, nil
 }
 
-func GetQuestionById(questionId int, db *sql.DB) (models.Question, error) {
+func GetQuestionById(questionId int, db Querier) (models.Question, error) {
 	var question models.Question
 	var authorId sql.NullInt64
-	err := db.QueryRow("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question WHERE id = ?", questionId).Scan(&question.Id, &question.Text, &authorId, &question.IsAuthorAnonymous, &question.ReceiverId, &question.CreatedAt)
+	err := db.QueryRow("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question WHERE id = ?", questionId).Scan(&question.Id, &question.Text, &authorId, &question.IsAuthorAnonymous, &question.ReceiverId, &question.CreatedAt, &question.RemovedByModeration)
 	if err != nil && err != sql.ErrNoRows {
 		log.Printf("Error getting question %d, %v\n", questionId, err)
 		return models.Question{}, err
@@ -109,11 +152,39 @@ func GetQuestionById(questionId int, db *sql.DB) (models.Question, error) {
 	return question, nil
 }
 
-func MarkQuestionAsDeleted(questionId int, db *sql.DB) error {
-	_, err := db.Exec("UPDATE question SET has_been_deleted = 1, deleted_at = NOW() WHERE id = ?", questionId)
+func MarkQuestionAsDeleted(questionId int, db Querier) error {
+	_, err := db.Exec("UPDATE question SET has_been_deleted = 1, deleted_at = CURRENT_TIMESTAMP WHERE id = ?", questionId)
 	if err != nil {
 		log.Printf("Error marking question %d as deleted, %v\n", questionId, err)
 		return err
 	}
 	return nil
 }
+
+// CheckQuestionRemoved tells if a moderator removed the question, it returns sql.ErrNoRows when the question doesn't exist or was deleted
+func CheckQuestionRemoved(questionId int, db Querier) (bool, error) {
+	var removed bool
+	err := db.QueryRow("SELECT removed_at IS NOT NULL FROM question WHERE id = ? AND has_been_deleted = 0", questionId).Scan(&removed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error checking if question %d was removed, %v\n", questionId, err)
+	}
+	return removed, err
+}
+
+func RemoveQuestion(questionId int, moderatorId int, reason string, db Querier) error {
+	_, err := db.Exec("UPDATE question SET removed_at = CURRENT_TIMESTAMP, removed_by = ?, removal_reason = ? WHERE id = ?", moderatorId, reason, questionId)
+	if err != nil {
+		log.Printf("Error removing question %d by moderator %d, %v\n", questionId, moderatorId, err)
+		return err
+	}
+	return nil
+}
+
+func RestoreQuestion(questionId int, db Querier) error {
+	_, err := db.Exec("UPDATE question SET removed_at = NULL, removed_by = NULL, removal_reason = NULL WHERE id = ?", questionId)
+	if err != nil {
+		log.Printf("Error restoring question %d, %v\n", questionId, err)
+		return err
+	}
+	return nil
+}
@@ -91,7 +91,7 @@ func TestGetQuestions(t *testing.T) {
 	}
 
 	// test for an SQL error
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnError(errors.New("error for db test"))
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnError(errors.New("error for db test"))
 	_, err = GetQuestions(1, 0, 30, db)
 	if err == nil {
 		t.Error("Expected error, got nil")
@@ -102,8 +102,8 @@ func TestGetQuestions(t *testing.T) {
 	}
 
 	// test for error when scanning rows
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).AddRow(55, 55, 55, 55, 55, 55)
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(rows)
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(55, 55, 55, 55, 55, 55, false)
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(rows)
 	_, err = GetQuestions(1, 0, 30, db)
 	if err == nil {
 		t.Error("Expected error, got nil")
@@ -114,8 +114,8 @@ func TestGetQuestions(t *testing.T) {
 	}
 
 	// test for no rows returned
-	rows = sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"})
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(rows)
+	rows = sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"})
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(rows)
 	questions, err := GetQuestions(1, 0, 30, db)
 	if err != nil {
 		t.Errorf("Error while getting questions: %s", err.Error())
@@ -136,11 +136,11 @@ func TestGetQuestionsError(t *testing.T) {
 	}
 	creationDate := time.Now()
 	questions := helpunittesting.GenerateTestQuestions(3, 1, creationDate)
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"})
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"})
 	for _, question := range questions {
-		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, question.RemovedByModeration)
 	}
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(rows)
 	// expects all the queries for getting answers
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(questions[0].Id).WillReturnError(errors.New("error for db test"))
 	_, err = GetQuestions(1, 0, 30, db)
@@ -163,11 +163,11 @@ func TestGetQuestionsMultipleRows(t *testing.T) {
 	questions := helpunittesting.GenerateTestQuestions(30, 1, curTime)
 
 	// generate rows
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"})
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"})
 	for _, question := range questions {
-		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, question.RemovedByModeration)
 	}
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(rows)
 	// expects all the queries for getting answers
 	for i, question := range questions {
 		mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(question.Id).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
@@ -219,11 +219,11 @@ func TestGetQuestionsMultipleRowsAndAnswers(t *testing.T) {
 	questions := helpunittesting.GenerateTestQuestions(30, 1, curTime)
 
 	// generate rows
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"})
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"})
 	for _, question := range questions {
-		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, question.RemovedByModeration)
 	}
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(rows)
 	// expects all the queries for getting answers. Every query will return no answers except the 25th one
 	for i, question := range questions {
 		if i == 25 {
@@ -288,10 +288,10 @@ func TestGetQuestionById(t *testing.T) {
 		CreatedAt:         time.Now(),
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).
-		AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+		AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, false)
 
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question WHERE id = \\?").
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question WHERE id = \\?").
 		WithArgs(1).
 		WillReturnRows(rows)
 	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(question.Author.Id).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow(question.Author.Username, question.Author.DisplayName))
@@ -333,10 +333,10 @@ func TestGetQuestionByIdNullAuthor(t *testing.T) {
 		CreatedAt:         time.Now(),
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).
-		AddRow(question.Id, question.Text, nil, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+		AddRow(question.Id, question.Text, nil, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, false)
 
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question WHERE id = \\?").
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question WHERE id = \\?").
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -368,7 +368,7 @@ func TestGetQuestionByIdNotFound(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question WHERE id = \\?").
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question WHERE id = \\?").
 		WithArgs(1).
 		WillReturnError(sql.ErrNoRows)
 
@@ -382,3 +382,83 @@ func TestGetQuestionByIdNotFound(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestGetQuestionsPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	// first page, without cursor
+	creationTime := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+		AddRow(3, "signed", 2, false, 1, creationTime, false).
+		AddRow(2, "anonymous", nil, true, 1, creationTime, false)
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question WHERE receiver_id = \\? AND has_been_deleted = 0 AND NOT EXISTS (.+) ORDER BY created_at DESC, id DESC LIMIT \\?").WithArgs(1, 11).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("titi", "Titi"))
+	questions, err := GetQuestionsPage(1, nil, 11, db)
+	if err != nil {
+		t.Fatalf("Error while getting questions: %s", err.Error())
+	}
+	if len(questions) != 2 || questions[0].Author.Username != "titi" || questions[1].Author.Id != 0 {
+		t.Errorf("Unexpected questions %+v", questions)
+	}
+
+	// next page, the questions created at the same time as the cursor are ordered by id
+	cursor := &models.Cursor{CreatedAt: creationTime, Id: 2}
+	mock.ExpectQuery("SELECT (.+) FROM question WHERE (.+) AND \\(question.created_at < \\? OR \\(question.created_at = \\? AND question.id < \\?\\)\\) ORDER BY").WithArgs(1, creationTime, creationTime, 2, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}))
+	questions, err = GetQuestionsPage(1, cursor, 11, db)
+	if err != nil || len(questions) != 0 {
+		t.Errorf("Expected no question, got %+v, %v", questions, err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM question").WithArgs(1, 11).WillReturnError(errors.New("error"))
+	_, err = GetQuestionsPage(1, nil, 11, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestQuestionRemoval(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question WHERE id = \\? AND has_been_deleted = 0").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(true))
+	removed, err := CheckQuestionRemoved(1, db)
+	if err != nil || !removed {
+		t.Errorf("Expected the question to be removed, got %t and %v", removed, err)
+	}
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	_, err = CheckQuestionRemoved(2, db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	mock.ExpectExec("UPDATE question SET removed_at = CURRENT_TIMESTAMP, removed_by = \\?, removal_reason = \\? WHERE id = \\?").WithArgs(3, "spam", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = RemoveQuestion(1, 3, "spam", db)
+	if err != nil {
+		t.Errorf("Error while removing question: %s", err.Error())
+	}
+	mock.ExpectExec("UPDATE question SET removed_at = CURRENT_TIMESTAMP").WithArgs(3, "spam", 1).WillReturnError(errors.New("error"))
+	err = RemoveQuestion(1, 3, "spam", db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+
+	mock.ExpectExec("UPDATE question SET removed_at = NULL, removed_by = NULL, removal_reason = NULL WHERE id = \\?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	err = RestoreQuestion(1, db)
+	if err != nil {
+		t.Errorf("Error while restoring question: %s", err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"time"
 )
 
-func GetRateLimit(ip string, db *sql.DB) (models.RateLimit, error) {
+func GetRateLimit(ip string, db Querier) (models.RateLimit, error) {
 	var rateLimit models.RateLimit
 	err := db.QueryRow("SELECT * FROM rate_limit WHERE ip_address = ?", ip).Scan(&rateLimit.IpAddress, &rateLimit.RequestCount, &rateLimit.LastRequestTime)
 	if err == sql.ErrNoRows {
-		_, err := db.Exec("INSERT INTO rate_limit (ip_address, request_count, last_updated) VALUES (?, 0, NOW())", ip)
+		_, err := db.Exec("INSERT INTO rate_limit (ip_address, request_count, last_updated) VALUES (?, 0, CURRENT_TIMESTAMP)", ip)
 		if err != nil {
 			log.Printf("Error inserting rate limit for ip %s, %v\n", ip, err)
 			return models.RateLimit{}, err
@@ -24,8 +24,8 @@ func GetRateLimit(ip string, db *sql.DB) (models.RateLimit, error) {
 	return rateLimit, nil
 }
 
-func ResetRateLimit(ip string, db *sql.DB) error {
-	_, err := db.Exec("UPDATE rate_limit SET request_count = 1, last_updated = NOW() WHERE ip_address = ?", ip)
+func ResetRateLimit(ip string, db Querier) error {
+	_, err := db.Exec("UPDATE rate_limit SET request_count = 1, last_updated = CURRENT_TIMESTAMP WHERE ip_address = ?", ip)
 	if err != nil {
 		log.Printf("Error resetting rate limit for ip %s, %v\n", ip, err)
 		return err
@@ -33,8 +33,8 @@ func ResetRateLimit(ip string, db *sql.DB) error {
 	return nil
 }
 
-func IncrementRateLimit(ip string, db *sql.DB) error {
-	_, err := db.Exec("UPDATE rate_limit SET request_count = request_count + 1, last_updated = NOW() WHERE ip_address = ?", ip)
+func IncrementRateLimit(ip string, db Querier) error {
+	_, err := db.Exec("UPDATE rate_limit SET request_count = request_count + 1, last_updated = CURRENT_TIMESTAMP WHERE ip_address = ?", ip)
 	if err != nil {
 		log.Printf("Error incrementing rate limit for ip %s, %v\n", ip, err)
 		return err
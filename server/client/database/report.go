@@ -0,0 +1,242 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"project_truthful/models"
+	"strings"
+)
+
+// GetReportTargetOwnerId returns the user behind the target: the author of a question, 0 when they were logged out,
+// the author of an answer or the user itself. It returns sql.ErrNoRows when the target doesn't exist, was deleted or removed by a moderator
+func GetReportTargetOwnerId(targetType string, targetId int, db Querier) (int, error) {
+	var query string
+	switch targetType {
+	case models.ReportTargetQuestion:
+		query = "SELECT author_id FROM question WHERE id = ? AND has_been_deleted = 0 AND removed_at IS NULL"
+	case models.ReportTargetAnswer:
+		query = "SELECT user_id FROM answer WHERE id = ? AND has_been_deleted = 0 AND removed_at IS NULL"
+	case models.ReportTargetUser:
+		query = "SELECT id FROM user WHERE id = ?"
+	default:
+		return 0, errors.New("unknown report target " + targetType)
+	}
+	var ownerId sql.NullInt64
+	err := db.QueryRow(query, targetId).Scan(&ownerId)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting owner of %s %d, %v\n", targetType, targetId, err)
+	}
+	return int(ownerId.Int64), err
+}
+
+func AddReport(reporterId int, targetType string, targetId int, reason string, details string, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO report (reporter_id, target_type, target_id, reason, details) VALUES (?, ?, ?, ?, ?)", reporterId, targetType, targetId, reason, details)
+	if err != nil {
+		log.Printf("Error inserting report of %s %d by user %d, %v\n", targetType, targetId, reporterId, err)
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting report ID, %v\n", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// CheckOpenReportExists tells if the user reported the target and no moderator resolved it yet
+func CheckOpenReportExists(reporterId int, targetType string, targetId int, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM report WHERE reporter_id = ? AND target_type = ? AND target_id = ? AND resolution_id IS NULL", reporterId, targetType, targetId).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking report of %s %d by user %d, %v\n", targetType, targetId, reporterId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// reportGroupsQuery groups the open reports matching the inner condition by target.
+// The first and the last reports are joined back so that their times keep the type of the column in SQLite
+const reportGroupsQuery = "SELECT report_group.target_type, report_group.target_id, report_group.report_count, report_group.reasons, report_group.claimed_by, " +
+	"first_report.created_at, last_report.created_at, last_report.id FROM (" +
+	"SELECT target_type, target_id, COUNT(*) AS report_count, GROUP_CONCAT(DISTINCT reason) AS reasons, MAX(claimed_by) AS claimed_by, MIN(id) AS first_id, MAX(id) AS last_id " +
+	"FROM report WHERE resolution_id IS NULL%s GROUP BY target_type, target_id) report_group " +
+	"JOIN report first_report ON first_report.id = report_group.first_id JOIN report last_report ON last_report.id = report_group.last_id"
+
+func scanReportGroup(scanner interface{ Scan(...any) error }) (models.ReportGroup, error) {
+	var group models.ReportGroup
+	var reasons string
+	var claimedBy sql.NullInt64
+	err := scanner.Scan(&group.TargetType, &group.TargetId, &group.ReportCount, &reasons, &claimedBy, &group.FirstReportedAt, &group.LastReportedAt, &group.LastReportId)
+	if err != nil {
+		return models.ReportGroup{}, err
+	}
+	group.Reasons = strings.Split(reasons, ",")
+	group.ClaimedBy = int(claimedBy.Int64)
+	return group, nil
+}
+
+// GetOpenReportGroups returns the targets with open reports after the cursor, the most recently reported first
+func GetOpenReportGroups(cursor *models.Cursor, count int, db Querier) ([]models.ReportGroup, error) {
+	query := fmt.Sprintf(reportGroupsQuery, "")
+	condition, args := pageCondition("last_report", cursor)
+	if condition != "" {
+		query += " WHERE " + strings.TrimPrefix(condition, " AND ")
+	}
+	rows, err := db.Query(query+" ORDER BY last_report.created_at DESC, last_report.id DESC LIMIT ?", append(args, count)...)
+	if err != nil {
+		log.Printf("Error getting open report groups, %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.ReportGroup{}
+	for rows.Next() {
+		group, err := scanReportGroup(rows)
+		if err != nil {
+			log.Printf("Error scanning open report groups, %v\n", err)
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// GetOpenReportGroup returns the open reports of the target grouped together, sql.ErrNoRows when there is none
+func GetOpenReportGroup(targetType string, targetId int, db Querier) (models.ReportGroup, error) {
+	query := fmt.Sprintf(reportGroupsQuery, " AND target_type = ? AND target_id = ?")
+	group, err := scanReportGroup(db.QueryRow(query, targetType, targetId))
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting open reports of %s %d, %v\n", targetType, targetId, err)
+	}
+	return group, err
+}
+
+// GetOpenReports returns the open reports of the target, in the order they were made
+func GetOpenReports(targetType string, targetId int, db Querier) ([]models.Report, error) {
+	rows, err := db.Query("SELECT id, reporter_id, target_type, target_id, reason, details, created_at FROM report WHERE target_type = ? AND target_id = ? AND resolution_id IS NULL ORDER BY id",
+		targetType, targetId)
+	if err != nil {
+		log.Printf("Error getting open reports of %s %d, %v\n", targetType, targetId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		var report models.Report
+		err := rows.Scan(&report.Id, &report.ReporterId, &report.TargetType, &report.TargetId, &report.Reason, &report.Details, &report.CreatedAt)
+		if err != nil {
+			log.Printf("Error scanning open reports of %s %d, %v\n", targetType, targetId, err)
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// ClaimReports gives the open reports of the target to the moderator, a moderatorId of 0 releases them
+func ClaimReports(targetType string, targetId int, moderatorId int, db Querier) error {
+	var err error
+	if moderatorId == 0 {
+		_, err = db.Exec("UPDATE report SET claimed_by = NULL, claimed_at = NULL WHERE target_type = ? AND target_id = ? AND resolution_id IS NULL", targetType, targetId)
+	} else {
+		_, err = db.Exec("UPDATE report SET claimed_by = ?, claimed_at = CURRENT_TIMESTAMP WHERE target_type = ? AND target_id = ? AND resolution_id IS NULL", moderatorId, targetType, targetId)
+	}
+	if err != nil {
+		log.Printf("Error claiming reports of %s %d for moderator %d, %v\n", targetType, targetId, moderatorId, err)
+		return err
+	}
+	return nil
+}
+
+// errNothingResolved rolls back a resolution which closed no report
+var errNothingResolved = errors.New("no open report to resolve")
+
+// ResolveReports records the resolution and closes the open reports of its target with it.
+// It returns the id of the resolution and the number of reports it closed, nothing is recorded when no report was open
+func ResolveReports(resolution models.ReportResolution, db Querier) (int64, int, error) {
+	var id, count int64
+	err := inTransaction(db, func(tx Querier) error {
+		result, err := tx.Exec("INSERT INTO report_resolution (target_type, target_id, moderator_id, action, note, ban_id) VALUES (?, ?, ?, ?, ?, ?)",
+			resolution.TargetType, resolution.TargetId, resolution.ModeratorId, resolution.Action, resolution.Note, nullableId(resolution.BanId))
+		if err != nil {
+			log.Printf("Error inserting resolution of %s %d, %v\n", resolution.TargetType, resolution.TargetId, err)
+			return err
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			log.Printf("Error getting resolution ID, %v\n", err)
+			return err
+		}
+
+		result, err = tx.Exec("UPDATE report SET resolution_id = ? WHERE target_type = ? AND target_id = ? AND resolution_id IS NULL", id, resolution.TargetType, resolution.TargetId)
+		if err != nil {
+			log.Printf("Error resolving reports of %s %d, %v\n", resolution.TargetType, resolution.TargetId, err)
+			return err
+		}
+		count, err = result.RowsAffected()
+		if err != nil {
+			log.Printf("Error getting affected rows for resolution of %s %d, %v\n", resolution.TargetType, resolution.TargetId, err)
+			return err
+		}
+		if count == 0 {
+			// rolls the resolution back
+			return errNothingResolved
+		}
+		_, err = tx.Exec("UPDATE report_resolution SET report_count = ? WHERE id = ?", count, id)
+		if err != nil {
+			log.Printf("Error counting reports of resolution %d, %v\n", id, err)
+			return err
+		}
+		return nil
+	})
+	if err == errNothingResolved {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	return id, int(count), nil
+}
+
+// GetReportResolutions returns the resolutions after the cursor, most recent first.
+// Only the resolutions of the target are returned when targetType is not empty
+func GetReportResolutions(targetType string, targetId int, cursor *models.Cursor, count int, db Querier) ([]models.ReportResolution, error) {
+	conditions := []string{}
+	args := []any{}
+	if targetType != "" {
+		conditions = append(conditions, "report_resolution.target_type = ? AND report_resolution.target_id = ?")
+		args = append(args, targetType, targetId)
+	}
+	condition, pageArgs := pageCondition("report_resolution", cursor)
+	if condition != "" {
+		conditions = append(conditions, strings.TrimPrefix(condition, " AND "))
+		args = append(args, pageArgs...)
+	}
+	query := "SELECT id, target_type, target_id, moderator_id, action, note, ban_id, report_count, created_at FROM report_resolution"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := db.Query(query+" ORDER BY report_resolution.created_at DESC, report_resolution.id DESC LIMIT ?", append(args, count)...)
+	if err != nil {
+		log.Printf("Error getting report resolutions, %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	resolutions := []models.ReportResolution{}
+	for rows.Next() {
+		var resolution models.ReportResolution
+		var banId sql.NullInt64
+		err := rows.Scan(&resolution.Id, &resolution.TargetType, &resolution.TargetId, &resolution.ModeratorId, &resolution.Action, &resolution.Note,
+			&banId, &resolution.ReportCount, &resolution.CreatedAt)
+		if err != nil {
+			log.Printf("Error scanning report resolutions, %v\n", err)
+			return nil, err
+		}
+		resolution.BanId = int(banId.Int64)
+		resolutions = append(resolutions, resolution)
+	}
+	return resolutions, nil
+}
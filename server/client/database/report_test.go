@@ -0,0 +1,208 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetReportTargetOwnerId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT author_id FROM question WHERE id = \\? AND has_been_deleted = 0").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"author_id"}).AddRow(nil))
+	ownerId, err := GetReportTargetOwnerId("question", 1, db)
+	if err != nil || ownerId != 0 {
+		t.Errorf("Expected no owner for a question of a guest, got %d and %v", ownerId, err)
+	}
+
+	mock.ExpectQuery("SELECT user_id FROM answer WHERE id = \\? AND has_been_deleted = 0").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(3))
+	ownerId, err = GetReportTargetOwnerId("answer", 2, db)
+	if err != nil || ownerId != 3 {
+		t.Errorf("Expected the author of the answer, got %d and %v", ownerId, err)
+	}
+
+	mock.ExpectQuery("SELECT id FROM user").WithArgs(4).WillReturnError(errors.New("error"))
+	_, err = GetReportTargetOwnerId("user", 4, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	_, err = GetReportTargetOwnerId("post", 4, db)
+	if err == nil {
+		t.Errorf("Expected unknown targets to be refused")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestAddReport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM report WHERE reporter_id = \\? AND target_type = \\? AND target_id = \\? AND resolution_id IS NULL").
+		WithArgs(1, "user", 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	reported, err := CheckOpenReportExists(1, "user", 2, db)
+	if err != nil || reported {
+		t.Errorf("Expected no open report, got %v and %v", reported, err)
+	}
+
+	mock.ExpectExec("INSERT INTO report").WithArgs(1, "user", 2, "spam", "details").WillReturnResult(sqlmock.NewResult(5, 1))
+	id, err := AddReport(1, "user", 2, "spam", "details", db)
+	if err != nil || id != 5 {
+		t.Errorf("Expected id 5, got %d and %v", id, err)
+	}
+
+	mock.ExpectExec("INSERT INTO report").WillReturnError(errors.New("error"))
+	_, err = AddReport(1, "user", 2, "spam", "", db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+var reportGroupColumns = []string{"target_type", "target_id", "report_count", "reasons", "claimed_by", "first_reported_at", "last_reported_at", "last_report_id"}
+
+func TestGetOpenReportGroups(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	now := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM \\(SELECT (.+) FROM report WHERE resolution_id IS NULL GROUP BY target_type, target_id\\) report_group (.+) ORDER BY last_report.created_at DESC, last_report.id DESC LIMIT \\?").
+		WithArgs(11).WillReturnRows(sqlmock.NewRows(reportGroupColumns).AddRow("user", 2, 3, "spam,hate", nil, now, now, 7).AddRow("answer", 1, 1, "other", 4, now, now, 5))
+	groups, err := GetOpenReportGroups(nil, 11, db)
+	if err != nil || len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %v and %v", groups, err)
+	}
+	if groups[0].ReportCount != 3 || len(groups[0].Reasons) != 2 || groups[0].ClaimedBy != 0 || groups[1].ClaimedBy != 4 || groups[1].LastReportId != 5 {
+		t.Errorf("Unexpected groups %+v", groups)
+	}
+
+	mock.ExpectQuery("SELECT (.+) WHERE \\(last_report.created_at < \\? (.+) LIMIT \\?").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5, 11).WillReturnError(errors.New("error"))
+	_, err = GetOpenReportGroups(&models.Cursor{CreatedAt: now, Id: 5}, 11, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM report WHERE resolution_id IS NULL AND target_type = \\? AND target_id = \\? GROUP BY").WithArgs("user", 2).
+		WillReturnRows(sqlmock.NewRows(reportGroupColumns).AddRow("user", 2, 3, "spam", 4, now, now, 7))
+	group, err := GetOpenReportGroup("user", 2, db)
+	if err != nil || group.ClaimedBy != 4 {
+		t.Errorf("Expected the group claimed by 4, got %+v and %v", group, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetOpenReports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM report WHERE target_type = \\? AND target_id = \\? AND resolution_id IS NULL ORDER BY id").WithArgs("answer", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reporter_id", "target_type", "target_id", "reason", "details", "created_at"}).AddRow(1, 2, "answer", 1, "spam", "", time.Now()))
+	reports, err := GetOpenReports("answer", 1, db)
+	if err != nil || len(reports) != 1 || reports[0].ReporterId != 2 {
+		t.Errorf("Expected the report of user 2, got %+v and %v", reports, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestClaimReports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	mock.ExpectExec("UPDATE report SET claimed_by = \\?, claimed_at = CURRENT_TIMESTAMP WHERE target_type = \\? AND target_id = \\? AND resolution_id IS NULL").
+		WithArgs(3, "user", 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	err = ClaimReports("user", 2, 3, db)
+	if err != nil {
+		t.Errorf("Error while claiming reports: %s", err.Error())
+	}
+
+	mock.ExpectExec("UPDATE report SET claimed_by = NULL, claimed_at = NULL").WithArgs("user", 2).WillReturnError(errors.New("error"))
+	err = ClaimReports("user", 2, 0, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestResolveReports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	resolution := models.ReportResolution{TargetType: "user", TargetId: 2, ModeratorId: 3, Action: "ban", BanId: 4}
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO report_resolution").WithArgs("user", 2, 3, "ban", "", 4).WillReturnResult(sqlmock.NewResult(6, 1))
+	mock.ExpectExec("UPDATE report SET resolution_id = \\? WHERE target_type = \\? AND target_id = \\? AND resolution_id IS NULL").
+		WithArgs(6, "user", 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE report_resolution SET report_count = \\? WHERE id = \\?").WithArgs(2, 6).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	id, count, err := ResolveReports(resolution, db)
+	if err != nil || id != 6 || count != 2 {
+		t.Errorf("Expected resolution 6 of 2 reports, got %d, %d and %v", id, count, err)
+	}
+
+	// the resolution is rolled back when another moderator resolved the reports first
+	resolution.Action = "dismiss"
+	resolution.BanId = 0
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO report_resolution").WithArgs("user", 2, 3, "dismiss", "", nil).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("UPDATE report SET resolution_id").WithArgs(7, "user", 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	id, count, err = ResolveReports(resolution, db)
+	if err != nil || id != 0 || count != 0 {
+		t.Errorf("Expected nothing to be resolved, got %d, %d and %v", id, count, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetReportResolutions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	columns := []string{"id", "target_type", "target_id", "moderator_id", "action", "note", "ban_id", "report_count", "created_at"}
+	mock.ExpectQuery("SELECT (.+) FROM report_resolution ORDER BY").WithArgs(11).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "user", 2, 3, "ban", "", 4, 2, time.Now()).AddRow(1, "answer", 1, 3, "dismiss", "fine", nil, 1, time.Now()))
+	resolutions, err := GetReportResolutions("", 0, nil, 11, db)
+	if err != nil || len(resolutions) != 2 || resolutions[0].BanId != 4 || resolutions[1].BanId != 0 {
+		t.Errorf("Expected 2 resolutions, got %+v and %v", resolutions, err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM report_resolution WHERE report_resolution.target_type = \\? AND report_resolution.target_id = \\? AND \\((.+) LIMIT \\?").
+		WithArgs("user", 2, sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 11).WillReturnError(errors.New("error"))
+	_, err = GetReportResolutions("user", 2, &models.Cursor{CreatedAt: time.Now(), Id: 2}, 11, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+)
+
+func CheckUserPermission(userId int, permission string, db Querier) (bool, error) {
+	var count int
+	// the roles requiring a second factor only grant their permissions to the users who enabled one
+	err := db.QueryRow("SELECT COUNT(*) FROM user_role JOIN role_permission ON role_permission.role_id = user_role.role_id JOIN permission ON permission.id = role_permission.permission_id "+
+		"JOIN role ON role.id = user_role.role_id WHERE user_role.user_id = ? AND permission.name = ? "+
+		"AND (role.requires_two_factor = 0 OR EXISTS (SELECT 1 FROM user_totp WHERE user_totp.user_id = user_role.user_id AND user_totp.enabled_at IS NOT NULL))", userId, permission).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking if user %d has permission %s, %v\n", userId, permission, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CheckTwoFactorRequired tells if the user holds a role requiring a second factor
+func CheckTwoFactorRequired(userId int, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user_role JOIN role ON role.id = user_role.role_id WHERE user_role.user_id = ? AND role.requires_two_factor = 1", userId).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking if user %d needs a second factor, %v\n", userId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func SetRoleRequiresTwoFactor(roleId int, required bool, db Querier) error {
+	_, err := db.Exec("UPDATE role SET requires_two_factor = ? WHERE id = ?", required, roleId)
+	if err != nil {
+		log.Printf("Error setting second factor requirement of role %d, %v\n", roleId, err)
+		return err
+	}
+	return nil
+}
+
+func CheckUserHasRole(userId int, roleId int, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user_role WHERE user_id = ? AND role_id = ?", userId, roleId).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking if user %d has role %d, %v\n", userId, roleId, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func CheckUserHasRoleName(userId int, roleName string, db Querier) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user_role JOIN role ON role.id = user_role.role_id WHERE user_role.user_id = ? AND role.name = ?", userId, roleName).Scan(&count)
+	if err != nil {
+		log.Printf("Error checking if user %d has role %s, %v\n", userId, roleName, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func GetRoleId(roleName string, db Querier) (int, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM role WHERE name = ?", roleName).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting id of role %s, %v\n", roleName, err)
+		return 0, err
+	} else if err == sql.ErrNoRows {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GrantRole gives a role to a user, grantedBy is 0 when the role is not granted by a user (see the grant-role subcommand)
+func GrantRole(userId int, roleId int, grantedBy int, db Querier) error {
+	_, err := db.Exec("INSERT INTO user_role (user_id, role_id, granted_by) VALUES (?, ?, ?)", userId, roleId, nullableId(grantedBy))
+	if err != nil {
+		log.Printf("Error granting role %d to user %d, %v\n", roleId, userId, err)
+		return err
+	}
+	return nil
+}
+
+// RevokeRole returns false if the user did not have the role
+func RevokeRole(userId int, roleId int, db Querier) (bool, error) {
+	result, err := db.Exec("DELETE FROM user_role WHERE user_id = ? AND role_id = ?", userId, roleId)
+	if err != nil {
+		log.Printf("Error revoking role %d from user %d, %v\n", roleId, userId, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for role %d of user %d, %v\n", roleId, userId, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetRoles returns every role along with the permissions it grants
+func GetRoles(db Querier) ([]models.Role, error) {
+	rows, err := db.Query("SELECT role.id, role.name, permission.name, role.requires_two_factor FROM role LEFT JOIN role_permission ON role_permission.role_id = role.id LEFT JOIN permission ON permission.id = role_permission.permission_id ORDER BY role.id, permission.name")
+	if err != nil {
+		log.Printf("Error getting roles, %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []models.Role{}
+	for rows.Next() {
+		var roleId int
+		var roleName string
+		var permission sql.NullString
+		var requiresTwoFactor bool
+		err := rows.Scan(&roleId, &roleName, &permission, &requiresTwoFactor)
+		if err != nil {
+			log.Printf("Error scanning role, %v\n", err)
+			return nil, err
+		}
+		if len(roles) == 0 || roles[len(roles)-1].Id != roleId {
+			roles = append(roles, models.Role{Id: roleId, Name: roleName, Permissions: []string{}, RequiresTwoFactor: requiresTwoFactor})
+		}
+		if permission.Valid {
+			roles[len(roles)-1].Permissions = append(roles[len(roles)-1].Permissions, permission.String)
+		}
+	}
+	return roles, nil
+}
+
+func GetUserRoles(userId int, db Querier) ([]string, error) {
+	rows, err := db.Query("SELECT role.name FROM user_role JOIN role ON role.id = user_role.role_id WHERE user_role.user_id = ? ORDER BY role.id", userId)
+	if err != nil {
+		log.Printf("Error getting roles of user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		err := rows.Scan(&role)
+		if err != nil {
+			log.Printf("Error scanning role of user %d, %v\n", userId, err)
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckUserPermission(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "users.ban").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	allowed, err := CheckUserPermission(1, "users.ban", db)
+	if err != nil || !allowed {
+		t.Errorf("Expected user to have permission, got %t, %v", allowed, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(2, "users.ban").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	allowed, err = CheckUserPermission(2, "users.ban", db)
+	if err != nil || allowed {
+		t.Errorf("Expected user not to have permission, got %t, %v", allowed, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "users.ban").WillReturnError(errors.New("error"))
+	_, err = CheckUserPermission(1, "users.ban", db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRoleTwoFactor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role (.+) role.requires_two_factor = 1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	required, err := CheckTwoFactorRequired(1, db)
+	if err != nil || !required {
+		t.Errorf("Expected a second factor to be required, got %t, %v", required, err)
+	}
+
+	mock.ExpectExec("UPDATE role SET requires_two_factor").WithArgs(true, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := SetRoleRequiresTwoFactor(2, true, db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestCheckUserHasRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role WHERE").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	hasRole, err := CheckUserHasRole(1, 2, db)
+	if err != nil || !hasRole {
+		t.Errorf("Expected user to have role, got %t, %v", hasRole, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(1, "admin").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	hasRole, err = CheckUserHasRoleName(1, "admin", db)
+	if err != nil || hasRole {
+		t.Errorf("Expected user not to have role, got %t, %v", hasRole, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(1, "admin").WillReturnError(errors.New("error"))
+	_, err = CheckUserHasRoleName(1, "admin", db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetRoleId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	id, err := GetRoleId("moderator", db)
+	if err != nil || id != 2 {
+		t.Errorf("Expected role 2, got %d, %v", id, err)
+	}
+
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	_, err = GetRoleId("unknown", db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGrantAndRevokeRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO user_role").WithArgs(2, 1, 3).WillReturnResult(sqlmock.NewResult(1, 1))
+	err = GrantRole(2, 1, 3, db)
+	if err != nil {
+		t.Errorf("Error while granting role: %s", err.Error())
+	}
+
+	mock.ExpectExec("INSERT INTO user_role").WithArgs(2, 1, 3).WillReturnError(errors.New("error"))
+	err = GrantRole(2, 1, 3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+
+	mock.ExpectExec("DELETE FROM user_role").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	revoked, err := RevokeRole(2, 1, db)
+	if err != nil || !revoked {
+		t.Errorf("Expected role to be revoked, got %t, %v", revoked, err)
+	}
+
+	mock.ExpectExec("DELETE FROM user_role").WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	revoked, err = RevokeRole(2, 1, db)
+	if err != nil || revoked {
+		t.Errorf("Expected role not to be revoked, got %t, %v", revoked, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "permission", "requires_two_factor"}).
+		AddRow(1, "admin", "roles.manage", true).
+		AddRow(1, "admin", "users.ban", true).
+		AddRow(2, "moderator", "users.ban", false).
+		AddRow(3, "empty", nil, false)
+	mock.ExpectQuery("SELECT role.id, role.name, permission.name, role.requires_two_factor FROM role").WillReturnRows(rows)
+	roles, err := GetRoles(db)
+	if err != nil {
+		t.Fatalf("Error while getting roles: %s", err.Error())
+	}
+	if len(roles) != 3 || len(roles[0].Permissions) != 2 || roles[1].Name != "moderator" || len(roles[2].Permissions) != 0 || !roles[0].RequiresTwoFactor || roles[1].RequiresTwoFactor {
+		t.Errorf("Unexpected roles %+v", roles)
+	}
+
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin").AddRow("moderator"))
+	userRoles, err := GetUserRoles(1, db)
+	if err != nil || len(userRoles) != 2 || userRoles[0] != "admin" {
+		t.Errorf("Unexpected user roles %v, %v", userRoles, err)
+	}
+
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(1).WillReturnError(errors.New("error"))
+	_, err = GetUserRoles(1, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
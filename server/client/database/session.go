@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+	"time"
+)
+
+func InsertRefreshToken(sessionId string, userId int, tokenHash string, ipAddress string, userAgent string, expiresAt time.Time, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO session (session_id, user_id, token_hash, ip_address, user_agent, expires_at) VALUES (?, ?, ?, ?, ?, ?)", sessionId, userId, tokenHash, ipAddress, userAgent, expiresAt.UTC())
+	if err != nil {
+		log.Printf("Error inserting refresh token for user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func GetRefreshTokenByHash(tokenHash string, db Querier) (models.RefreshToken, error) {
+	var refreshToken models.RefreshToken
+	err := db.QueryRow("SELECT id, session_id, user_id, expires_at, rotated_at IS NOT NULL, revoked_at IS NOT NULL FROM session WHERE token_hash = ?", tokenHash).Scan(&refreshToken.Id, &refreshToken.SessionId, &refreshToken.UserId, &refreshToken.ExpiresAt, &refreshToken.IsRotated, &refreshToken.IsRevoked)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting refresh token, %v\n", err)
+		return models.RefreshToken{}, err
+	} else if err == sql.ErrNoRows {
+		return models.RefreshToken{}, err
+	}
+	return refreshToken, nil
+}
+
+// MarkRefreshTokenAsRotated returns false if the token had already been rotated by a concurrent request
+func MarkRefreshTokenAsRotated(id int, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE session SET rotated_at = CURRENT_TIMESTAMP WHERE id = ? AND rotated_at IS NULL", id)
+	if err != nil {
+		log.Printf("Error marking refresh token %d as rotated, %v\n", id, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for refresh token %d, %v\n", id, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func RevokeSession(sessionId string, db Querier) error {
+	_, err := db.Exec("UPDATE session SET revoked_at = CURRENT_TIMESTAMP WHERE session_id = ? AND revoked_at IS NULL", sessionId)
+	if err != nil {
+		log.Printf("Error revoking session %s, %v\n", sessionId, err)
+		return err
+	}
+	return nil
+}
+
+// RevokeUserSession returns false if the user has no active session with this id
+func RevokeUserSession(userId int, sessionId string, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE session SET revoked_at = CURRENT_TIMESTAMP WHERE session_id = ? AND user_id = ? AND revoked_at IS NULL", sessionId, userId)
+	if err != nil {
+		log.Printf("Error revoking session %s of user %d, %v\n", sessionId, userId, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for session %s, %v\n", sessionId, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func RevokeAllUserSessions(userId int, db Querier) error {
+	_, err := db.Exec("UPDATE session SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userId)
+	if err != nil {
+		log.Printf("Error revoking sessions of user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
+
+func GetActiveSessions(userId int, db Querier) ([]models.Session, error) {
+	rows, err := db.Query("SELECT session_id, ip_address, user_agent, created_at, expires_at FROM session WHERE user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP ORDER BY created_at DESC", userId)
+	if err != nil {
+		log.Printf("Error getting sessions for user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(&session.Id, &session.IpAddress, &session.UserAgent, &session.LastUsedAt, &session.ExpiresAt)
+		if err != nil {
+			log.Printf("Error scanning session for user %d, %v\n", userId, err)
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
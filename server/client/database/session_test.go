@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestInsertRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Now().UTC().Add(time.Hour)
+	mock.ExpectExec("INSERT INTO session").WithArgs("session", 1, "hash", "127.0.0.1", "agent", expiresAt).WillReturnResult(sqlmock.NewResult(3, 1))
+	id, err := InsertRefreshToken("session", 1, "hash", "127.0.0.1", "agent", expiresAt, db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if id != 3 {
+		t.Errorf("expected id 3, got %d", id)
+	}
+
+	mock.ExpectExec("INSERT INTO session").WithArgs("session", 1, "hash", "127.0.0.1", "agent", expiresAt).WillReturnError(errors.New("error for db test"))
+	_, err = InsertRefreshToken("session", 1, "hash", "127.0.0.1", "agent", expiresAt, db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestGetRefreshTokenByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"id", "session_id", "user_id", "expires_at", "rotated", "revoked"}).AddRow(1, "session", 2, expiresAt, true, false)
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs("hash").WillReturnRows(rows)
+	refreshToken, err := GetRefreshTokenByHash("hash", db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if refreshToken.Id != 1 || refreshToken.SessionId != "session" || refreshToken.UserId != 2 || !refreshToken.IsRotated || refreshToken.IsRevoked {
+		t.Errorf("unexpected refresh token %+v", refreshToken)
+	}
+
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	_, err = GetRefreshTokenByHash("unknown", db)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs("hash").WillReturnError(errors.New("error for db test"))
+	_, err = GetRefreshTokenByHash("hash", db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestMarkRefreshTokenAsRotated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE session SET rotated_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	rotated, err := MarkRefreshTokenAsRotated(1, db)
+	if err != nil || !rotated {
+		t.Errorf("expected token to be rotated, got %t, %v", rotated, err)
+	}
+
+	// already rotated by another request
+	mock.ExpectExec("UPDATE session SET rotated_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	rotated, err = MarkRefreshTokenAsRotated(1, db)
+	if err != nil || rotated {
+		t.Errorf("expected token not to be rotated, got %t, %v", rotated, err)
+	}
+
+	mock.ExpectExec("UPDATE session SET rotated_at").WithArgs(1).WillReturnError(errors.New("error for db test"))
+	_, err = MarkRefreshTokenAsRotated(1, db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 2))
+	err = RevokeSession("session", db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session").WillReturnError(errors.New("error for db test"))
+	err = RevokeSession("session", db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestRevokeUserSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	revoked, err := RevokeUserSession(1, "session", db)
+	if err != nil || !revoked {
+		t.Errorf("expected session to be revoked, got %t, %v", revoked, err)
+	}
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("other", 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	revoked, err = RevokeUserSession(1, "other", db)
+	if err != nil || revoked {
+		t.Errorf("expected session not to be revoked, got %t, %v", revoked, err)
+	}
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session", 1).WillReturnError(errors.New("error for db test"))
+	_, err = RevokeUserSession(1, "session", db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestRevokeAllUserSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	err = RevokeAllUserSessions(1, db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs(1).WillReturnError(errors.New("error for db test"))
+	err = RevokeAllUserSessions(1, db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestGetActiveSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"session_id", "ip_address", "user_agent", "created_at", "expires_at"}).
+		AddRow("first", "127.0.0.1", "agent", now, now.Add(time.Hour)).
+		AddRow("second", "127.0.0.2", "other agent", now, now.Add(time.Hour))
+	mock.ExpectQuery("SELECT session_id, ip_address, user_agent, created_at, expires_at FROM session").WithArgs(1).WillReturnRows(rows)
+	sessions, err := GetActiveSessions(1, db)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(sessions) != 2 || sessions[0].Id != "first" || sessions[1].UserAgent != "other agent" {
+		t.Errorf("unexpected sessions %+v", sessions)
+	}
+
+	mock.ExpectQuery("SELECT session_id, ip_address, user_agent, created_at, expires_at FROM session").WithArgs(1).WillReturnError(errors.New("error for db test"))
+	_, err = GetActiveSessions(1, db)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+)
+
+// GetUserSettings returns the default settings when the user never changed them
+func GetUserSettings(userId int, db Querier) (models.UserSettings, error) {
+	var settings models.UserSettings
+	err := db.QueryRow("SELECT allow_anonymous_questions, guest_questions, following_only, inbox_paused FROM user_settings WHERE user_id = ?", userId).
+		Scan(&settings.AllowAnonymousQuestions, &settings.GuestQuestions, &settings.FollowingOnly, &settings.InboxPaused)
+	if err == sql.ErrNoRows {
+		return models.DefaultUserSettings, nil
+	} else if err != nil {
+		log.Printf("Error getting settings of user %d, %v\n", userId, err)
+		return models.UserSettings{}, err
+	}
+	return settings, nil
+}
+
+// UpdateUserSettings creates the settings row of the user on first update
+func UpdateUserSettings(userId int, settings models.UserSettings, db Querier) error {
+	return inTransaction(db, func(tx Querier) error {
+		_, err := tx.Exec(insertIgnore()+" INTO user_settings (user_id) VALUES (?)", userId)
+		if err != nil {
+			log.Printf("Error inserting settings of user %d, %v\n", userId, err)
+			return err
+		}
+		_, err = tx.Exec("UPDATE user_settings SET allow_anonymous_questions = ?, guest_questions = ?, following_only = ?, inbox_paused = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?",
+			settings.AllowAnonymousQuestions, settings.GuestQuestions, settings.FollowingOnly, settings.InboxPaused, userId)
+		if err != nil {
+			log.Printf("Error updating settings of user %d, %v\n", userId, err)
+			return err
+		}
+		return nil
+	})
+}
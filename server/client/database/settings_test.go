@@ -0,0 +1,69 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetUserSettings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	columns := []string{"allow_anonymous_questions", "guest_questions", "following_only", "inbox_paused"}
+	mock.ExpectQuery("SELECT allow_anonymous_questions, guest_questions, following_only, inbox_paused FROM user_settings WHERE user_id = \\?").
+		WithArgs(1).WillReturnRows(sqlmock.NewRows(columns))
+	settings, err := GetUserSettings(1, db)
+	if err != nil || settings != models.DefaultUserSettings {
+		t.Errorf("Expected the default settings, got %+v and %v", settings, err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM user_settings").WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).AddRow(false, "captcha", true, true))
+	settings, err = GetUserSettings(2, db)
+	expected := models.UserSettings{AllowAnonymousQuestions: false, GuestQuestions: models.GuestQuestionsCaptcha, FollowingOnly: true, InboxPaused: true}
+	if err != nil || settings != expected {
+		t.Errorf("Expected %+v, got %+v and %v", expected, settings, err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM user_settings").WithArgs(3).WillReturnError(errors.New("error"))
+	_, err = GetUserSettings(3, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestUpdateUserSettings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	settings := models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: models.GuestQuestionsDisabled, InboxPaused: true}
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_settings").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE user_settings SET").WithArgs(true, "disabled", false, true, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = UpdateUserSettings(1, settings, db)
+	if err != nil {
+		t.Errorf("Error while updating settings: %s", err.Error())
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_settings").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE user_settings SET").WithArgs(true, "disabled", false, true, 1).WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	err = UpdateUserSettings(1, settings, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,650 @@
+package database
+
+import (
+	"database/sql"
+	"project_truthful/models"
+	"time"
+)
+
+// SQLStore is the Store backed by the database functions of this package
+type SQLStore struct {
+	db Querier
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) InsertUser(username string, password string, email string, birthdate string) (int64, error) {
+	return InsertUser(username, password, email, birthdate, s.db)
+}
+
+func (s *SQLStore) InsertUserWithDisplayName(username string, displayName string, password string, email string, birthdate string) (int64, error) {
+	return InsertUserWithDisplayName(username, displayName, password, email, birthdate, s.db)
+}
+
+func (s *SQLStore) CheckUsernameExists(username string) (bool, error) {
+	return CheckUsernameExists(username, s.db)
+}
+
+func (s *SQLStore) CheckUserIdExists(id int) (bool, error) {
+	return CheckUserIdExists(id, s.db)
+}
+
+func (s *SQLStore) CheckEmailExists(email string) (bool, error) {
+	return CheckEmailExists(email, s.db)
+}
+
+func (s *SQLStore) GetUserId(username string) (int, error) {
+	return GetUserId(username, s.db)
+}
+
+func (s *SQLStore) GetHashedPassword(id int) (string, error) {
+	return GetHashedPassword(id, s.db)
+}
+
+func (s *SQLStore) GetUserIdByEmail(email string) (int, error) {
+	return GetUserIdByEmail(email, s.db)
+}
+
+func (s *SQLStore) UpdatePassword(id int, password string) error {
+	return UpdatePassword(id, password, s.db)
+}
+
+func (s *SQLStore) GetUserEmail(id int) (string, bool, error) {
+	return GetUserEmail(id, s.db)
+}
+
+func (s *SQLStore) SetEmailVerified(id int, email string) error {
+	return SetEmailVerified(id, email, s.db)
+}
+
+func (s *SQLStore) GetUsernameAndDisplayName(id int) (string, string, error) {
+	return GetUsernameAndDisplayName(id, s.db)
+}
+
+func (s *SQLStore) UpdateUserInformations(id int, displayName string, email string) error {
+	return UpdateUserInformations(id, displayName, email, s.db)
+}
+
+func (s *SQLStore) GetUserProfileInfos(id int, requestingUser int, count int, start int) (models.UserProfileInfos, error) {
+	return GetUserProfileInfos(id, requestingUser, count, start, s.db)
+}
+
+func (s *SQLStore) GetUserProfileInfosPage(id int, requestingUser int, cursor *models.Cursor, count int) (models.UserProfileInfos, error) {
+	return GetUserProfileInfosPage(id, requestingUser, cursor, count, s.db)
+}
+
+func (s *SQLStore) GetOAuthProvider(provider string) (int, error) {
+	return GetOAuthProvider(provider, s.db)
+}
+
+func (s *SQLStore) GetUserIdBySubject(providerId int, subject string) (int64, error) {
+	return GetUserIdBySubject(providerId, subject, s.db)
+}
+
+func (s *SQLStore) InsertOauthLogin(providerId int, subject string, userId int64) error {
+	return InsertOauthLogin(providerId, subject, userId, s.db)
+}
+
+func (s *SQLStore) GetQuestions(userId int, start int, count int) ([]models.Question, error) {
+	return GetQuestions(userId, start, count, s.db)
+}
+
+func (s *SQLStore) GetQuestionsPage(userId int, cursor *models.Cursor, count int) ([]models.Question, error) {
+	return GetQuestionsPage(userId, cursor, count, s.db)
+}
+
+func (s *SQLStore) GetQuestionReceiverId(questionId int) (int, error) {
+	return GetQuestionReceiverId(questionId, s.db)
+}
+
+func (s *SQLStore) AddQuestion(question string, authorId int, authorIpAddress string, isAuthorAnonymous bool, receiverId int) (int64, error) {
+	return AddQuestion(question, authorId, authorIpAddress, isAuthorAnonymous, receiverId, s.db)
+}
+
+func (s *SQLStore) GetQuestionById(questionId int) (models.Question, error) {
+	return GetQuestionById(questionId, s.db)
+}
+
+func (s *SQLStore) GetQuestionAuthorIpAddress(questionId int) (string, error) {
+	return GetQuestionAuthorIpAddress(questionId, s.db)
+}
+
+func (s *SQLStore) MarkQuestionAsDeleted(questionId int) error {
+	return MarkQuestionAsDeleted(questionId, s.db)
+}
+
+func (s *SQLStore) CheckQuestionRemoved(questionId int) (bool, error) {
+	return CheckQuestionRemoved(questionId, s.db)
+}
+
+func (s *SQLStore) RemoveQuestion(questionId int, moderatorId int, reason string) error {
+	return RemoveQuestion(questionId, moderatorId, reason, s.db)
+}
+
+func (s *SQLStore) RestoreQuestion(questionId int) error {
+	return RestoreQuestion(questionId, s.db)
+}
+
+func (s *SQLStore) CheckAnswerIdExists(answerId int) (bool, error) {
+	return CheckAnswerIdExists(answerId, s.db)
+}
+
+func (s *SQLStore) GetAnswerAuthorId(answerId int) (int, error) {
+	return GetAnswerAuthorId(answerId, s.db)
+}
+
+func (s *SQLStore) HasQuestionBeenAnswered(questionId int) (bool, error) {
+	return HasQuestionBeenAnswered(questionId, s.db)
+}
+
+func (s *SQLStore) GetAnswerIdByQuestionId(questionId int) (int, error) {
+	return GetAnswerIdByQuestionId(questionId, s.db)
+}
+
+func (s *SQLStore) AddAnswer(userId int, questionId int, answerText string, answererIpAddress string) (int64, error) {
+	return AddAnswer(userId, questionId, answerText, answererIpAddress, s.db)
+}
+
+func (s *SQLStore) MarkAnswerAsDeleted(answerId int) error {
+	return MarkAnswerAsDeleted(answerId, s.db)
+}
+
+func (s *SQLStore) CheckAnswerRemoved(answerId int) (bool, error) {
+	return CheckAnswerRemoved(answerId, s.db)
+}
+
+func (s *SQLStore) RemoveAnswer(answerId int, moderatorId int, reason string) error {
+	return RemoveAnswer(answerId, moderatorId, reason, s.db)
+}
+
+func (s *SQLStore) RestoreAnswer(answerId int) error {
+	return RestoreAnswer(answerId, s.db)
+}
+
+func (s *SQLStore) CheckLikeExists(userId int, postId int) (bool, error) {
+	return CheckLikeExists(userId, postId, s.db)
+}
+
+func (s *SQLStore) RemoveLike(userId int, postId int) error {
+	return RemoveLike(userId, postId, s.db)
+}
+
+func (s *SQLStore) AddLike(userId int, postId int) error {
+	return AddLike(userId, postId, s.db)
+}
+
+func (s *SQLStore) GetLikeCountForAnswer(answerId int) (int, error) {
+	return GetLikeCountForAnswer(answerId, s.db)
+}
+
+func (s *SQLStore) CheckFollowExists(follower int, followed int) (bool, error) {
+	return CheckFollowExists(follower, followed, s.db)
+}
+
+func (s *SQLStore) AddFollow(followerId int, followedId int) (bool, error) {
+	return AddFollow(followerId, followedId, s.db)
+}
+
+func (s *SQLStore) RemoveFollow(followerId int, followedId int) error {
+	return RemoveFollow(followerId, followedId, s.db)
+}
+
+func (s *SQLStore) GetFollowers(userId int, requestingUser int, cursor *models.Cursor, count int) ([]models.FollowPreview, error) {
+	return GetFollowers(userId, requestingUser, cursor, count, s.db)
+}
+
+func (s *SQLStore) GetFollowing(userId int, requestingUser int, cursor *models.Cursor, count int) ([]models.FollowPreview, error) {
+	return GetFollowing(userId, requestingUser, cursor, count, s.db)
+}
+
+func (s *SQLStore) GetTimeline(userId int, cursor *models.Cursor, count int) ([]models.TimelineAnswer, error) {
+	return GetTimeline(userId, cursor, count, s.db)
+}
+
+func (s *SQLStore) CheckBlockExists(blockerId int, blockedId int) (bool, error) {
+	return CheckBlockExists(blockerId, blockedId, s.db)
+}
+
+func (s *SQLStore) AddBlock(blockerId int, blockedId int) (bool, error) {
+	return AddBlock(blockerId, blockedId, s.db)
+}
+
+func (s *SQLStore) RemoveBlock(blockerId int, blockedId int) (bool, error) {
+	return RemoveBlock(blockerId, blockedId, s.db)
+}
+
+func (s *SQLStore) GetBlockedUsers(userId int, cursor *models.Cursor, count int) ([]models.RelatedUser, error) {
+	return GetBlockedUsers(userId, cursor, count, s.db)
+}
+
+func (s *SQLStore) CheckMuteExists(muterId int, mutedId int) (bool, error) {
+	return CheckMuteExists(muterId, mutedId, s.db)
+}
+
+func (s *SQLStore) AddMute(muterId int, mutedId int) (bool, error) {
+	return AddMute(muterId, mutedId, s.db)
+}
+
+func (s *SQLStore) RemoveMute(muterId int, mutedId int) (bool, error) {
+	return RemoveMute(muterId, mutedId, s.db)
+}
+
+func (s *SQLStore) GetMutedUsers(userId int, cursor *models.Cursor, count int) ([]models.RelatedUser, error) {
+	return GetMutedUsers(userId, cursor, count, s.db)
+}
+
+func (s *SQLStore) AddAskerBlock(receiverId int, ipHash string, questionId int, duration int) (int64, error) {
+	return AddAskerBlock(receiverId, ipHash, questionId, duration, s.db)
+}
+
+func (s *SQLStore) CheckAskerBlockExists(receiverId int, ipHash string) (bool, error) {
+	return CheckAskerBlockExists(receiverId, ipHash, s.db)
+}
+
+func (s *SQLStore) GetAskerBlocks(receiverId int, cursor *models.Cursor, count int) ([]models.AskerBlock, error) {
+	return GetAskerBlocks(receiverId, cursor, count, s.db)
+}
+
+func (s *SQLStore) RemoveAskerBlock(receiverId int, blockId int) (bool, error) {
+	return RemoveAskerBlock(receiverId, blockId, s.db)
+}
+
+func (s *SQLStore) GetUserSettings(userId int) (models.UserSettings, error) {
+	return GetUserSettings(userId, s.db)
+}
+
+func (s *SQLStore) UpdateUserSettings(userId int, settings models.UserSettings) error {
+	return UpdateUserSettings(userId, settings, s.db)
+}
+
+func (s *SQLStore) GetMutedWords(userId int) ([]string, error) {
+	return GetMutedWords(userId, s.db)
+}
+
+func (s *SQLStore) AddMutedWord(userId int, word string) (bool, error) {
+	return AddMutedWord(userId, word, s.db)
+}
+
+func (s *SQLStore) RemoveMutedWord(userId int, word string) (bool, error) {
+	return RemoveMutedWord(userId, word, s.db)
+}
+
+func (s *SQLStore) AddHeldContent(content models.HeldContent) (int64, error) {
+	return AddHeldContent(content, s.db)
+}
+
+func (s *SQLStore) GetHeldContent(heldId int) (models.HeldContent, error) {
+	return GetHeldContent(heldId, s.db)
+}
+
+func (s *SQLStore) GetPendingHeldContents(cursor *models.Cursor, count int) ([]models.HeldContent, error) {
+	return GetPendingHeldContents(cursor, count, s.db)
+}
+
+func (s *SQLStore) CheckPendingHeldAnswer(questionId int) (bool, error) {
+	return CheckPendingHeldAnswer(questionId, s.db)
+}
+
+func (s *SQLStore) ReviewHeldContent(heldId int, status string, reviewerId int) (bool, error) {
+	return ReviewHeldContent(heldId, status, reviewerId, s.db)
+}
+
+func (s *SQLStore) SetHeldContentId(heldId int, contentId int) error {
+	return SetHeldContentId(heldId, contentId, s.db)
+}
+
+func (s *SQLStore) GetReportTargetOwnerId(targetType string, targetId int) (int, error) {
+	return GetReportTargetOwnerId(targetType, targetId, s.db)
+}
+
+func (s *SQLStore) AddReport(reporterId int, targetType string, targetId int, reason string, details string) (int64, error) {
+	return AddReport(reporterId, targetType, targetId, reason, details, s.db)
+}
+
+func (s *SQLStore) CheckOpenReportExists(reporterId int, targetType string, targetId int) (bool, error) {
+	return CheckOpenReportExists(reporterId, targetType, targetId, s.db)
+}
+
+func (s *SQLStore) GetOpenReportGroups(cursor *models.Cursor, count int) ([]models.ReportGroup, error) {
+	return GetOpenReportGroups(cursor, count, s.db)
+}
+
+func (s *SQLStore) GetOpenReportGroup(targetType string, targetId int) (models.ReportGroup, error) {
+	return GetOpenReportGroup(targetType, targetId, s.db)
+}
+
+func (s *SQLStore) GetOpenReports(targetType string, targetId int) ([]models.Report, error) {
+	return GetOpenReports(targetType, targetId, s.db)
+}
+
+func (s *SQLStore) ClaimReports(targetType string, targetId int, moderatorId int) error {
+	return ClaimReports(targetType, targetId, moderatorId, s.db)
+}
+
+func (s *SQLStore) ResolveReports(resolution models.ReportResolution) (int64, int, error) {
+	return ResolveReports(resolution, s.db)
+}
+
+func (s *SQLStore) GetReportResolutions(targetType string, targetId int, cursor *models.Cursor, count int) ([]models.ReportResolution, error) {
+	return GetReportResolutions(targetType, targetId, cursor, count, s.db)
+}
+
+func (s *SQLStore) AddNotification(userId int, notificationType string, actorId int, questionId int, answerId int) (int64, error) {
+	return AddNotification(userId, notificationType, actorId, questionId, answerId, s.db)
+}
+
+func (s *SQLStore) GetNotifications(userId int, cursor *models.Cursor, count int) ([]models.Notification, error) {
+	return GetNotifications(userId, cursor, count, s.db)
+}
+
+func (s *SQLStore) CountUnreadNotifications(userId int) (int, error) {
+	return CountUnreadNotifications(userId, s.db)
+}
+
+func (s *SQLStore) MarkNotificationAsRead(userId int, notificationId int) (bool, error) {
+	return MarkNotificationAsRead(userId, notificationId, s.db)
+}
+
+func (s *SQLStore) MarkAllNotificationsAsRead(userId int) error {
+	return MarkAllNotificationsAsRead(userId, s.db)
+}
+
+func (s *SQLStore) BanUser(userId int, requesterId int, duration int, reason string) (int64, error) {
+	return BanUser(userId, requesterId, duration, reason, s.db)
+}
+
+func (s *SQLStore) CheckUserBanStatus(userId int) (bool, error) {
+	return CheckUserBanStatus(userId, s.db)
+}
+
+func (s *SQLStore) GetActiveBan(userId int) (models.Ban, error) {
+	return GetActiveBan(userId, s.db)
+}
+
+func (s *SQLStore) CheckBanExistsByBanId(banId int) (bool, error) {
+	return CheckBanExistsByBanId(banId, s.db)
+}
+
+func (s *SQLStore) GetBan(banId int) (models.BanDetails, error) {
+	return GetBan(banId, s.db)
+}
+
+func (s *SQLStore) GetBans(filter models.BanFilter, cursor *models.Cursor, count int) ([]models.BanDetails, error) {
+	return GetBans(filter, cursor, count, s.db)
+}
+
+func (s *SQLStore) UpdateBanExpiration(banId int, expiresAt *time.Time) error {
+	return UpdateBanExpiration(banId, expiresAt, s.db)
+}
+
+func (s *SQLStore) PardonUser(banId int, requesterId int) (int64, error) {
+	return PardonUser(banId, requesterId, s.db)
+}
+
+func (s *SQLStore) CheckPardonExists(banId int) (bool, error) {
+	return CheckPardonExists(banId, s.db)
+}
+
+func (s *SQLStore) AddAddressBan(banType string, value string, authorId int, duration int, reason string) (int64, error) {
+	return AddAddressBan(banType, value, authorId, duration, reason, s.db)
+}
+
+func (s *SQLStore) GetAddressBan(banId int) (models.AddressBan, error) {
+	return GetAddressBan(banId, s.db)
+}
+
+func (s *SQLStore) GetAddressBans(banType string, cursor *models.Cursor, count int) ([]models.AddressBan, error) {
+	return GetAddressBans(banType, cursor, count, s.db)
+}
+
+func (s *SQLStore) RemoveAddressBan(banId int) error {
+	return RemoveAddressBan(banId, s.db)
+}
+
+func (s *SQLStore) GetUserIpAddresses(userId int) ([]string, error) {
+	return GetUserIpAddresses(userId, s.db)
+}
+
+func (s *SQLStore) GetAccountsByIpAddresses(ipAddresses []string, userId int) ([]models.LinkedAccount, error) {
+	return GetAccountsByIpAddresses(ipAddresses, userId, s.db)
+}
+
+func (s *SQLStore) GetRateLimit(ip string) (models.RateLimit, error) {
+	return GetRateLimit(ip, s.db)
+}
+
+func (s *SQLStore) ResetRateLimit(ip string) error {
+	return ResetRateLimit(ip, s.db)
+}
+
+func (s *SQLStore) IncrementRateLimit(ip string) error {
+	return IncrementRateLimit(ip, s.db)
+}
+
+func (s *SQLStore) InsertRefreshToken(sessionId string, userId int, tokenHash string, ipAddress string, userAgent string, expiresAt time.Time) (int64, error) {
+	return InsertRefreshToken(sessionId, userId, tokenHash, ipAddress, userAgent, expiresAt, s.db)
+}
+
+func (s *SQLStore) GetRefreshTokenByHash(tokenHash string) (models.RefreshToken, error) {
+	return GetRefreshTokenByHash(tokenHash, s.db)
+}
+
+func (s *SQLStore) MarkRefreshTokenAsRotated(id int) (bool, error) {
+	return MarkRefreshTokenAsRotated(id, s.db)
+}
+
+func (s *SQLStore) RevokeSession(sessionId string) error {
+	return RevokeSession(sessionId, s.db)
+}
+
+func (s *SQLStore) RevokeUserSession(userId int, sessionId string) (bool, error) {
+	return RevokeUserSession(userId, sessionId, s.db)
+}
+
+func (s *SQLStore) RevokeAllUserSessions(userId int) error {
+	return RevokeAllUserSessions(userId, s.db)
+}
+
+func (s *SQLStore) GetActiveSessions(userId int) ([]models.Session, error) {
+	return GetActiveSessions(userId, s.db)
+}
+
+func (s *SQLStore) AddPasswordResetRequest(ipAddress string, userId int) error {
+	return AddPasswordResetRequest(ipAddress, userId, s.db)
+}
+
+func (s *SQLStore) CountPasswordResetRequestsByIp(ipAddress string, since time.Time) (int, error) {
+	return CountPasswordResetRequestsByIp(ipAddress, since, s.db)
+}
+
+func (s *SQLStore) CountPasswordResetRequestsByUser(userId int, since time.Time) (int, error) {
+	return CountPasswordResetRequestsByUser(userId, since, s.db)
+}
+
+func (s *SQLStore) AddPasswordResetToken(userId int, tokenHash string, expiresAt time.Time) (int64, error) {
+	return AddPasswordResetToken(userId, tokenHash, expiresAt, s.db)
+}
+
+func (s *SQLStore) GetPasswordResetToken(tokenHash string) (models.PasswordResetToken, error) {
+	return GetPasswordResetToken(tokenHash, s.db)
+}
+
+func (s *SQLStore) UsePasswordResetToken(id int) (bool, error) {
+	return UsePasswordResetToken(id, s.db)
+}
+
+func (s *SQLStore) RevokePasswordResetTokens(userId int) error {
+	return RevokePasswordResetTokens(userId, s.db)
+}
+
+func (s *SQLStore) AddEmailVerificationToken(userId int, email string, tokenHash string, expiresAt time.Time) (int64, error) {
+	return AddEmailVerificationToken(userId, email, tokenHash, expiresAt, s.db)
+}
+
+func (s *SQLStore) CountEmailVerificationTokens(userId int, since time.Time) (int, error) {
+	return CountEmailVerificationTokens(userId, since, s.db)
+}
+
+func (s *SQLStore) GetEmailVerificationToken(tokenHash string) (models.EmailVerificationToken, error) {
+	return GetEmailVerificationToken(tokenHash, s.db)
+}
+
+func (s *SQLStore) GetPendingEmail(userId int) (string, error) {
+	return GetPendingEmail(userId, s.db)
+}
+
+func (s *SQLStore) UseEmailVerificationToken(id int) (bool, error) {
+	return UseEmailVerificationToken(id, s.db)
+}
+
+func (s *SQLStore) RevokeEmailVerificationTokens(userId int) error {
+	return RevokeEmailVerificationTokens(userId, s.db)
+}
+
+func (s *SQLStore) GetTotpSecret(userId int) (models.TotpSecret, error) {
+	return GetTotpSecret(userId, s.db)
+}
+
+func (s *SQLStore) AddTotpSecret(userId int, secret string) error {
+	return AddTotpSecret(userId, secret, s.db)
+}
+
+func (s *SQLStore) EnableTotp(userId int) error {
+	return EnableTotp(userId, s.db)
+}
+
+func (s *SQLStore) UseTotpStep(userId int, step int64) (bool, error) {
+	return UseTotpStep(userId, step, s.db)
+}
+
+func (s *SQLStore) DeleteTotpSecret(userId int) error {
+	return DeleteTotpSecret(userId, s.db)
+}
+
+func (s *SQLStore) AddRecoveryCodes(userId int, codeHashes []string) error {
+	return AddRecoveryCodes(userId, codeHashes, s.db)
+}
+
+func (s *SQLStore) DeleteRecoveryCodes(userId int) error {
+	return DeleteRecoveryCodes(userId, s.db)
+}
+
+func (s *SQLStore) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	return UseRecoveryCode(userId, codeHash, s.db)
+}
+
+func (s *SQLStore) CountRecoveryCodes(userId int) (int, error) {
+	return CountRecoveryCodes(userId, s.db)
+}
+
+func (s *SQLStore) AddLoginChallenge(userId int, tokenHash string, expiresAt time.Time) (int64, error) {
+	return AddLoginChallenge(userId, tokenHash, expiresAt, s.db)
+}
+
+func (s *SQLStore) GetLoginChallenge(tokenHash string) (models.LoginChallenge, error) {
+	return GetLoginChallenge(tokenHash, s.db)
+}
+
+func (s *SQLStore) AddLoginChallengeAttempt(id int) error {
+	return AddLoginChallengeAttempt(id, s.db)
+}
+
+func (s *SQLStore) UseLoginChallenge(id int) (bool, error) {
+	return UseLoginChallenge(id, s.db)
+}
+
+func (s *SQLStore) AddStreamTicket(userId int, tokenHash string, expiresAt time.Time, streamExpiresAt time.Time) error {
+	return AddStreamTicket(userId, tokenHash, expiresAt, streamExpiresAt, s.db)
+}
+
+func (s *SQLStore) GetStreamTicket(tokenHash string) (models.StreamTicket, error) {
+	return GetStreamTicket(tokenHash, s.db)
+}
+
+func (s *SQLStore) UseStreamTicket(id int) (bool, error) {
+	return UseStreamTicket(id, s.db)
+}
+
+func (s *SQLStore) AddLoginAttempt(attempt models.LoginAttempt) (int64, error) {
+	return AddLoginAttempt(attempt, s.db)
+}
+
+func (s *SQLStore) FinishLoginAttempt(id int, userId int, success bool) error {
+	return FinishLoginAttempt(id, userId, success, s.db)
+}
+
+func (s *SQLStore) DeleteLoginAttempt(id int) error {
+	return DeleteLoginAttempt(id, s.db)
+}
+
+func (s *SQLStore) GetLoginFailuresByUsername(username string, since time.Time, beforeId int) (models.LoginFailures, error) {
+	return GetLoginFailuresByUsername(username, since, beforeId, s.db)
+}
+
+func (s *SQLStore) GetLoginFailuresByIp(ipAddress string, since time.Time, beforeId int) (models.LoginFailures, error) {
+	return GetLoginFailuresByIp(ipAddress, since, beforeId, s.db)
+}
+
+func (s *SQLStore) CheckNewLoginDevice(userId int, ipAddress string, userAgent string) (bool, error) {
+	return CheckNewLoginDevice(userId, ipAddress, userAgent, s.db)
+}
+
+func (s *SQLStore) GetLoginHistory(userId int, cursor *models.Cursor, count int) ([]models.LoginHistoryEntry, error) {
+	return GetLoginHistory(userId, cursor, count, s.db)
+}
+
+func (s *SQLStore) CheckUserPermission(userId int, permission string) (bool, error) {
+	return CheckUserPermission(userId, permission, s.db)
+}
+
+func (s *SQLStore) CheckUserHasRole(userId int, roleId int) (bool, error) {
+	return CheckUserHasRole(userId, roleId, s.db)
+}
+
+func (s *SQLStore) CheckUserHasRoleName(userId int, roleName string) (bool, error) {
+	return CheckUserHasRoleName(userId, roleName, s.db)
+}
+
+func (s *SQLStore) GetRoleId(roleName string) (int, error) {
+	return GetRoleId(roleName, s.db)
+}
+
+func (s *SQLStore) GrantRole(userId int, roleId int, grantedBy int) error {
+	return GrantRole(userId, roleId, grantedBy, s.db)
+}
+
+func (s *SQLStore) RevokeRole(userId int, roleId int) (bool, error) {
+	return RevokeRole(userId, roleId, s.db)
+}
+
+func (s *SQLStore) GetRoles() ([]models.Role, error) {
+	return GetRoles(s.db)
+}
+
+func (s *SQLStore) GetUserRoles(userId int) ([]string, error) {
+	return GetUserRoles(userId, s.db)
+}
+
+func (s *SQLStore) CheckTwoFactorRequired(userId int) (bool, error) {
+	return CheckTwoFactorRequired(userId, s.db)
+}
+
+func (s *SQLStore) SetRoleRequiresTwoFactor(roleId int, required bool) error {
+	return SetRoleRequiresTwoFactor(roleId, required, s.db)
+}
+
+func (s *SQLStore) AddAuditEntry(entry models.AuditEntry) error {
+	return AddAuditEntry(entry, s.db)
+}
+
+func (s *SQLStore) GetAuditEntries(filter models.AuditFilter, cursor *models.Cursor, count int) ([]models.AuditEntry, error) {
+	return GetAuditEntries(filter, cursor, count, s.db)
+}
+
+func (s *SQLStore) InTransaction(fn func(store Store) error) error {
+	return inTransaction(s.db, func(tx Querier) error {
+		return fn(&SQLStore{db: tx})
+	})
+}
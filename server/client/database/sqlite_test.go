@@ -0,0 +1,543 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"project_truthful/dialect"
+	"project_truthful/migrations"
+	"project_truthful/models"
+	"testing"
+	"time"
+)
+
+// openSQLite opens a migrated SQLite database in a temporary directory
+func openSQLite(t *testing.T) *sql.DB {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db"))
+	db, err := Init()
+	if err != nil {
+		t.Fatalf("Error while opening database: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		db.Close()
+		Dialect = dialect.MySQL
+	})
+	if Dialect != dialect.SQLite {
+		t.Errorf("Expected dialect %s, got %s", dialect.SQLite, Dialect)
+	}
+	_, err = migrations.Up(db, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("Error while migrating database: %s", err.Error())
+	}
+	return db
+}
+
+func TestInitRequiresUpToDateSchema(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db"))
+	defer func() { Dialect = dialect.MySQL }()
+	_, err := Init(RequireUpToDateSchema())
+	if err == nil {
+		t.Errorf("Expected error for a database without schema")
+	}
+
+	t.Setenv("DB_DRIVER", "postgres")
+	_, err = Init()
+	if err == nil {
+		t.Errorf("Expected error for an unknown driver")
+	}
+}
+
+func TestSQLiteUsers(t *testing.T) {
+	db := openSQLite(t)
+	id, err := InsertUser("Toto", "password", "Toto@toto.fr", "1990-01-01", db)
+	if err != nil || id != 1 {
+		t.Fatalf("Expected user 1 to be inserted, got %d, %v", id, err)
+	}
+
+	// usernames and emails are case insensitive, as with the MySQL collation
+	userId, err := GetUserId("toto", db)
+	if err != nil || userId != 1 {
+		t.Errorf("Expected user 1, got %d, %v", userId, err)
+	}
+	exists, err := CheckEmailExists("toto@TOTO.fr", db)
+	if err != nil || !exists {
+		t.Errorf("Expected email to exist, got %t, %v", exists, err)
+	}
+	_, err = GetUserId("titi", db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	rateLimit, err := GetRateLimit("127.0.0.1", db)
+	if err != nil || rateLimit.RequestCount != 0 {
+		t.Fatalf("Unexpected rate limit %+v, %v", rateLimit, err)
+	}
+	IncrementRateLimit("127.0.0.1", db)
+	rateLimit, err = GetRateLimit("127.0.0.1", db)
+	if err != nil || rateLimit.RequestCount != 1 || time.Since(rateLimit.LastRequestTime) > time.Minute {
+		t.Errorf("Unexpected rate limit %+v, %v", rateLimit, err)
+	}
+}
+
+func TestSQLiteExpirations(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	InsertUser("titi", "password", "titi@titi.fr", "1990-01-01", db)
+
+	// temporary bans are active until they expire
+	banId, err := BanUser(1, 2, 1, "spam", db)
+	if err != nil {
+		t.Fatalf("Error while banning user: %s", err.Error())
+	}
+	ban, err := GetActiveBan(1, db)
+	if err != nil || int64(ban.Id) != banId || ban.IsPermanent || ban.ExpiresAt == nil {
+		t.Errorf("Expected the temporary ban to be active, got %+v, %v", ban, err)
+	}
+	_, err = db.Exec("UPDATE ban SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), banId)
+	if err != nil {
+		t.Fatalf("Error while expiring ban: %s", err.Error())
+	}
+	banned, err := CheckUserBanStatus(1, db)
+	if err != nil || banned {
+		t.Errorf("Expected the expired ban to be inactive, got %t, %v", banned, err)
+	}
+
+	// expired sessions are not listed
+	InsertRefreshToken("active", 1, "hash1", "127.0.0.1", "agent", time.Now().Add(time.Hour), db)
+	InsertRefreshToken("expired", 1, "hash2", "127.0.0.1", "agent", time.Now().Add(-time.Minute), db)
+	sessions, err := GetActiveSessions(1, db)
+	if err != nil || len(sessions) != 1 || sessions[0].Id != "active" {
+		t.Errorf("Expected only the active session, got %+v, %v", sessions, err)
+	}
+}
+
+func TestSQLiteBans(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	InsertUser("titi", "password", "titi@titi.fr", "1990-01-01", db)
+
+	// permanent bans can be found to be pardoned
+	permanentId, _ := BanUser(1, 2, 0, "spam", db)
+	exists, err := CheckBanExistsByBanId(int(permanentId), db)
+	if err != nil || !exists {
+		t.Errorf("Expected the permanent ban to exist, got %t, %v", exists, err)
+	}
+	pardonId, err := PardonUser(int(permanentId), 2, db)
+	if err != nil || pardonId == 0 {
+		t.Errorf("Expected the ban to be pardoned, got %d, %v", pardonId, err)
+	}
+	pardonId, err = PardonUser(int(permanentId), 2, db)
+	if err != nil || pardonId != 0 {
+		t.Errorf("Expected the ban not to be pardoned twice, got %d, %v", pardonId, err)
+	}
+
+	expiredId, _ := BanUser(1, 2, 1, "spam", db)
+	UpdateBanExpiration(int(expiredId), &time.Time{}, db)
+	exists, err = CheckBanExistsByBanId(int(expiredId), db)
+	if err != nil || exists {
+		t.Errorf("Expected the expired ban not to be found, got %t, %v", exists, err)
+	}
+
+	activeId, _ := BanUser(1, 2, 1, "spam", db)
+	expiresAt := time.Now().Add(48 * time.Hour)
+	err = UpdateBanExpiration(int(activeId), &expiresAt, db)
+	if err != nil {
+		t.Fatalf("Error while updating ban: %s", err.Error())
+	}
+	ban, err := GetBan(int(activeId), db)
+	if err != nil || ban.Status != models.BanStatusActive || ban.ExpiresAt == nil || ban.ExpiresAt.Sub(expiresAt).Abs() > time.Second {
+		t.Errorf("Expected the ban to last 48 hours, got %+v, %v", ban, err)
+	}
+
+	for status, id := range map[string]int64{models.BanStatusActive: activeId, models.BanStatusExpired: expiredId, models.BanStatusPardoned: permanentId} {
+		bans, err := GetBans(models.BanFilter{UserId: 1, Status: status}, nil, 10, db)
+		if err != nil || len(bans) != 1 || int64(bans[0].Id) != id || bans[0].Status != status {
+			t.Errorf("Expected ban %d to be %s, got %+v, %v", id, status, bans, err)
+		}
+	}
+	bans, err := GetBans(models.BanFilter{UserId: 1}, nil, 10, db)
+	if err != nil || len(bans) != 3 || bans[2].Pardon == nil || bans[2].Pardon.PardonerId != 2 {
+		t.Errorf("Expected the 3 bans with the pardon of the first one, got %+v, %v", bans, err)
+	}
+}
+
+func TestSQLiteAddressBans(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	InsertUser("titi", "password", "titi@titi.fr", "1990-01-01", db)
+	InsertUser("tata", "password", "tata@tata.fr", "1990-01-01", db)
+
+	ipId, _ := AddAddressBan(models.AddressBanIp, "10.0.0.0/24", 1, 0, "spam", db)
+	AddAddressBan(models.AddressBanEmailDomain, "spam.com", 1, 1, "", db)
+	expiredId, _ := AddAddressBan(models.AddressBanIp, "10.1.0.0/16", 1, 1, "", db)
+	db.Exec("UPDATE address_ban SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Hour).UTC(), expiredId)
+
+	bans, err := GetAddressBans(models.AddressBanIp, nil, 0, db)
+	if err != nil || len(bans) != 1 || int64(bans[0].Id) != ipId || !bans[0].IsPermanent {
+		t.Errorf("Expected the permanent IP ban only, got %+v, %v", bans, err)
+	}
+	bans, err = GetAddressBans("", nil, 10, db)
+	if err != nil || len(bans) != 2 || bans[0].BanType != models.AddressBanEmailDomain || bans[0].ExpiresAt == nil {
+		t.Errorf("Expected the 2 active bans, got %+v, %v", bans, err)
+	}
+	RemoveAddressBan(int(ipId), db)
+	_, err = GetAddressBan(int(ipId), db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	// anonymous questions still link their author, guest questions link nobody
+	AddQuestion("question", 1, "10.0.0.1", false, 2, db)
+	AddQuestion("question", 1, "10.0.0.2", true, 2, db)
+	AddQuestion("question", 0, "10.0.0.3", true, 2, db)
+	AddAnswer(2, 1, "answer", "10.0.0.2", db)
+	AddQuestion("question", 3, "10.0.0.9", false, 2, db)
+	ipAddresses, err := GetUserIpAddresses(1, db)
+	if err != nil || len(ipAddresses) != 2 || ipAddresses[0] != "10.0.0.1" || ipAddresses[1] != "10.0.0.2" {
+		t.Errorf("Expected the 2 addresses of user 1, got %v, %v", ipAddresses, err)
+	}
+	accounts, err := GetAccountsByIpAddresses(ipAddresses, 1, db)
+	if err != nil || len(accounts) != 1 || accounts[0].Username != "titi" || len(accounts[0].IpAddresses) != 1 || accounts[0].IpAddresses[0] != "10.0.0.2" {
+		t.Errorf("Expected titi to share an address with toto, got %+v, %v", accounts, err)
+	}
+}
+
+func TestSQLitePasswordResets(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+
+	userId, err := GetUserIdByEmail("TOTO@toto.fr", db)
+	if err != nil || userId != 1 {
+		t.Errorf("Expected user 1, got %d, %v", userId, err)
+	}
+	UpdatePassword(1, "new password", db)
+	password, _ := GetHashedPassword(1, db)
+	if password != "new password" {
+		t.Errorf("Expected the password to be updated, got %s", password)
+	}
+
+	// only the requests of the window are counted
+	AddPasswordResetRequest("10.0.0.1", 0, db)
+	AddPasswordResetRequest("10.0.0.1", 1, db)
+	db.Exec("UPDATE password_reset_request SET created_at = ? WHERE id = 1", time.Now().UTC().Add(-2*time.Hour))
+	count, err := CountPasswordResetRequestsByIp("10.0.0.1", time.Now().Add(-time.Hour), db)
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 recent request, got %d, %v", count, err)
+	}
+	count, err = CountPasswordResetRequestsByUser(1, time.Now().Add(-time.Hour), db)
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 request of user 1, got %d, %v", count, err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	id, _ := AddPasswordResetToken(1, "hash1", expiresAt, db)
+	AddPasswordResetToken(1, "hash2", expiresAt, db)
+	resetToken, err := GetPasswordResetToken("hash1", db)
+	if err != nil || int64(resetToken.Id) != id || resetToken.IsUsed || resetToken.ExpiresAt.Sub(expiresAt).Abs() > time.Second {
+		t.Errorf("Unexpected token %+v, %v", resetToken, err)
+	}
+	used, _ := UsePasswordResetToken(int(id), db)
+	usedAgain, _ := UsePasswordResetToken(int(id), db)
+	if !used || usedAgain {
+		t.Errorf("Expected the token to be used once, got %t and %t", used, usedAgain)
+	}
+	RevokePasswordResetTokens(1, db)
+	resetToken, _ = GetPasswordResetToken("hash2", db)
+	if !resetToken.IsUsed {
+		t.Errorf("Expected the other token to be revoked")
+	}
+}
+
+func TestSQLiteFollows(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	InsertUser("titi", "password", "titi@titi.fr", "1990-01-01", db)
+
+	// the unique constraint makes the second follow a no-op
+	created, err := AddFollow(1, 2, db)
+	if err != nil || !created {
+		t.Fatalf("Expected follow to be created, got %t, %v", created, err)
+	}
+	created, err = AddFollow(1, 2, db)
+	if err != nil || created {
+		t.Errorf("Expected follow not to be created twice, got %t, %v", created, err)
+	}
+	AddFollow(2, 1, db)
+	followers, err := GetFollowers(2, 2, nil, 10, db)
+	if err != nil || len(followers) != 1 || !followers[0].IsMutual || followers[0].FollowedAt.IsZero() {
+		t.Errorf("Expected a mutual follower, got %+v, %v", followers, err)
+	}
+}
+
+func TestSQLiteTimeline(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	InsertUser("titi", "password", "titi@titi.fr", "1990-01-01", db)
+	InsertUser("tata", "password", "tata@tata.fr", "1990-01-01", db)
+
+	// the answers written before the follow are added with it, the next ones when they are written
+	AddQuestion("first question", 1, "10.0.0.1", false, 2, db)
+	AddQuestion("second question", 1, "10.0.0.1", true, 2, db)
+	AddQuestion("third question", 1, "10.0.0.1", false, 3, db)
+	AddAnswer(2, 1, "first answer", "10.0.0.2", db)
+	AddFollow(1, 2, db)
+	AddFollow(1, 3, db)
+	AddAnswer(2, 2, "second answer", "10.0.0.2", db)
+	AddAnswer(3, 3, "third answer", "10.0.0.3", db)
+	AddLike(1, 1, db)
+	answers, err := GetTimeline(1, nil, 2, db)
+	if err != nil || len(answers) != 2 || answers[0].AnswerText != "third answer" || answers[1].AnswerText != "second answer" {
+		t.Fatalf("Expected the 2 latest answers, got %+v, %v", answers, err)
+	}
+	if answers[0].Answerer.Username != "tata" || answers[0].Author.Username != "toto" || !answers[1].IsAuthorAnonymous || answers[1].Author.Id != 0 {
+		t.Errorf("Unexpected answerers or authors %+v", answers)
+	}
+	cursor := &models.Cursor{CreatedAt: answers[1].CreatedAt, Id: answers[1].Id}
+	answers, err = GetTimeline(1, cursor, 2, db)
+	if err != nil || len(answers) != 1 || answers[0].AnswerText != "first answer" || answers[0].LikeCount != 1 || !answers[0].LikedByRequester {
+		t.Errorf("Expected the liked first answer, got %+v, %v", answers, err)
+	}
+
+	// unfollowing or blocking removes the answers of the user, deleted answers are left out
+	RemoveFollow(1, 3, db)
+	MarkAnswerAsDeleted(1, db)
+	answers, _ = GetTimeline(1, nil, 10, db)
+	if len(answers) != 1 || answers[0].AnswerText != "second answer" {
+		t.Errorf("Expected the second answer only, got %+v", answers)
+	}
+	AddBlock(2, 1, db)
+	answers, _ = GetTimeline(1, nil, 10, db)
+	if len(answers) != 0 {
+		t.Errorf("Expected an empty timeline after the block, got %+v", answers)
+	}
+}
+
+func TestSQLiteUserSettings(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+
+	settings, err := GetUserSettings(1, db)
+	if err != nil || settings != models.DefaultUserSettings {
+		t.Fatalf("Expected the default settings, got %+v, %v", settings, err)
+	}
+	// the row is created on the first update and changed on the next ones
+	for _, expected := range []models.UserSettings{
+		{GuestQuestions: models.GuestQuestionsCaptcha, FollowingOnly: true},
+		{AllowAnonymousQuestions: true, GuestQuestions: models.GuestQuestionsDisabled, InboxPaused: true},
+	} {
+		err = UpdateUserSettings(1, expected, db)
+		if err != nil {
+			t.Fatalf("Error while updating settings: %s", err.Error())
+		}
+		settings, err = GetUserSettings(1, db)
+		if err != nil || settings != expected {
+			t.Errorf("Expected %+v, got %+v, %v", expected, settings, err)
+		}
+	}
+}
+
+func TestSQLiteEmailVerification(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+
+	// the accounts are created unverified
+	email, verified, err := GetUserEmail(1, db)
+	if err != nil || email != "toto@toto.fr" || verified {
+		t.Errorf("Expected the unverified email, got %s, %t, %v", email, verified, err)
+	}
+
+	// expired tokens are not pending
+	AddEmailVerificationToken(1, "expired@toto.fr", "hash0", time.Now().Add(-time.Minute), db)
+	_, err = GetPendingEmail(1, db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected no pending email, got %v", err)
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	AddEmailVerificationToken(1, "toto@toto.fr", "hash1", expiresAt, db)
+	id, _ := AddEmailVerificationToken(1, "titi@titi.fr", "hash2", expiresAt, db)
+	pendingEmail, err := GetPendingEmail(1, db)
+	if err != nil || pendingEmail != "titi@titi.fr" {
+		t.Errorf("Expected titi@titi.fr pending, got %s, %v", pendingEmail, err)
+	}
+	count, err := CountEmailVerificationTokens(1, time.Now().Add(-time.Hour), db)
+	if err != nil || count != 3 {
+		t.Errorf("Expected 3 tokens, got %d, %v", count, err)
+	}
+
+	verificationToken, err := GetEmailVerificationToken("hash2", db)
+	if err != nil || int64(verificationToken.Id) != id || verificationToken.Email != "titi@titi.fr" || verificationToken.IsUsed || verificationToken.ExpiresAt.Sub(expiresAt).Abs() > time.Second {
+		t.Errorf("Unexpected token %+v, %v", verificationToken, err)
+	}
+	used, _ := UseEmailVerificationToken(int(id), db)
+	usedAgain, _ := UseEmailVerificationToken(int(id), db)
+	if !used || usedAgain {
+		t.Errorf("Expected the token to be used once, got %t and %t", used, usedAgain)
+	}
+	SetEmailVerified(1, "titi@titi.fr", db)
+	RevokeEmailVerificationTokens(1, db)
+	email, verified, _ = GetUserEmail(1, db)
+	if email != "titi@titi.fr" || !verified {
+		t.Errorf("Expected the new email to be verified, got %s, %t", email, verified)
+	}
+	verificationToken, _ = GetEmailVerificationToken("hash1", db)
+	if !verificationToken.IsUsed {
+		t.Errorf("Expected the other token to be revoked")
+	}
+}
+
+func TestSQLiteTwoFactor(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	InsertUser("titi", "password", "titi@titi.fr", "1990-01-01", db)
+
+	AddTotpSecret(1, "SECRET", db)
+	secret, err := GetTotpSecret(1, db)
+	if err != nil || secret.Secret != "SECRET" || secret.IsEnabled {
+		t.Errorf("Expected the pending secret, got %+v, %v", secret, err)
+	}
+	EnableTotp(1, db)
+	used, _ := UseTotpStep(1, 10, db)
+	usedAgain, _ := UseTotpStep(1, 10, db)
+	secret, _ = GetTotpSecret(1, db)
+	if !used || usedAgain || !secret.IsEnabled || secret.LastUsedStep != 10 {
+		t.Errorf("Expected the step to be used once, got %t, %t, %+v", used, usedAgain, secret)
+	}
+
+	AddRecoveryCodes(1, []string{"hash1", "hash2"}, db)
+	used, _ = UseRecoveryCode(1, "hash1", db)
+	usedByOther, _ := UseRecoveryCode(2, "hash2", db)
+	count, _ := CountRecoveryCodes(1, db)
+	if !used || usedByOther || count != 1 {
+		t.Errorf("Expected the own code to be used, got %t, %t and %d left", used, usedByOther, count)
+	}
+
+	expiresAt := time.Now().Add(time.Minute)
+	id, _ := AddLoginChallenge(1, "challenge", expiresAt, db)
+	AddLoginChallengeAttempt(int(id), db)
+	challenge, err := GetLoginChallenge("challenge", db)
+	if err != nil || challenge.Attempts != 1 || challenge.IsUsed || challenge.ExpiresAt.Sub(expiresAt).Abs() > time.Second {
+		t.Errorf("Unexpected challenge %+v, %v", challenge, err)
+	}
+	used, _ = UseLoginChallenge(int(id), db)
+	usedAgain, _ = UseLoginChallenge(int(id), db)
+	if !used || usedAgain {
+		t.Errorf("Expected the challenge to be used once, got %t and %t", used, usedAgain)
+	}
+
+	// the moderators without a second factor lose their permissions once the role requires one
+	moderatorId, _ := GetRoleId("moderator", db)
+	GrantRole(1, moderatorId, 1, db)
+	GrantRole(2, moderatorId, 1, db)
+	SetRoleRequiresTwoFactor(moderatorId, true, db)
+	required, _ := CheckTwoFactorRequired(2, db)
+	allowed, _ := CheckUserPermission(2, "users.ban", db)
+	if !required || allowed {
+		t.Errorf("Expected the permission to be withheld, got %t and %t", required, allowed)
+	}
+	allowed, err = CheckUserPermission(1, "users.ban", db)
+	if err != nil || !allowed {
+		t.Errorf("Expected the user with a second factor to keep the permission, got %t, %v", allowed, err)
+	}
+}
+
+func TestSQLiteStreamTickets(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+
+	streamExpiresAt := time.Now().Add(15 * time.Minute)
+	err := AddStreamTicket(1, "ticket", time.Now().Add(time.Minute), streamExpiresAt, db)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ticket, err := GetStreamTicket("ticket", db)
+	if err != nil || ticket.UserId != 1 || ticket.IsUsed || ticket.StreamExpiresAt.Sub(streamExpiresAt).Abs() > time.Second {
+		t.Errorf("Unexpected ticket %+v, %v", ticket, err)
+	}
+	used, _ := UseStreamTicket(ticket.Id, db)
+	usedAgain, _ := UseStreamTicket(ticket.Id, db)
+	ticket, _ = GetStreamTicket("ticket", db)
+	if !used || usedAgain || !ticket.IsUsed {
+		t.Errorf("Expected the ticket to be used once, got %t, %t and %+v", used, usedAgain, ticket)
+	}
+}
+
+func TestSQLiteLoginAttempts(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	since := time.Now().Add(-time.Hour)
+
+	AddLoginAttempt(models.LoginAttempt{Username: "toto", UserId: 1, IpAddress: "10.0.0.1", UserAgent: "agent", Success: false}, db)
+	AddLoginAttempt(models.LoginAttempt{Username: "nobody", IpAddress: "10.0.0.1", Success: false}, db)
+	id, err := AddLoginAttempt(models.LoginAttempt{Username: "toto", IpAddress: "10.0.0.1", Success: false}, db)
+	if err != nil || id != 3 {
+		t.Fatalf("Expected the attempt to be recorded, got %d, %v", id, err)
+	}
+	failures, err := GetLoginFailuresByUsername("toto", since, int(id), db)
+	if err != nil || failures.Count != 1 || time.Since(failures.LastFailureAt).Abs() > 2*time.Second {
+		t.Errorf("Expected 1 failure of toto before the attempt, got %+v, %v", failures, err)
+	}
+	failures, err = GetLoginFailuresByIp("10.0.0.1", since, int(id), db)
+	if err != nil || failures.Count != 2 {
+		t.Errorf("Expected 2 failures from the address before the attempt, got %+v, %v", failures, err)
+	}
+
+	// a successful login forgets the failures of the username, not of the address
+	err = FinishLoginAttempt(int(id), 1, true, db)
+	if err != nil {
+		t.Fatalf("Error while finishing attempt: %s", err.Error())
+	}
+	next, _ := AddLoginAttempt(models.LoginAttempt{Username: "toto", IpAddress: "10.0.0.1", Success: false}, db)
+	failures, _ = GetLoginFailuresByUsername("toto", since, int(next), db)
+	if failures.Count != 0 {
+		t.Errorf("Expected the failures of toto to be forgotten, got %+v", failures)
+	}
+	failures, _ = GetLoginFailuresByIp("10.0.0.1", since, int(next), db)
+	if failures.Count != 2 {
+		t.Errorf("Expected the failures from the address to stay, got %+v", failures)
+	}
+	failures, _ = GetLoginFailuresByIp("10.0.0.1", time.Now().Add(time.Minute), int(next), db)
+	if failures.Count != 0 {
+		t.Errorf("Expected the older failures to be ignored, got %+v", failures)
+	}
+
+	err = DeleteLoginAttempt(int(next), db)
+	if err != nil {
+		t.Fatalf("Error while deleting attempt: %s", err.Error())
+	}
+	history, _ := GetLoginHistory(1, nil, 10, db)
+	if len(history) != 2 || !history[0].Success {
+		t.Errorf("Expected the deleted attempt to leave, and the finished one to be a login of toto, got %+v", history)
+	}
+}
+
+func TestSQLiteLoginHistory(t *testing.T) {
+	db := openSQLite(t)
+	InsertUser("toto", "password", "toto@toto.fr", "1990-01-01", db)
+	InsertUser("titi", "password", "titi@titi.fr", "1990-01-01", db)
+
+	AddLoginAttempt(models.LoginAttempt{Username: "toto", UserId: 1, IpAddress: "10.0.0.1", UserAgent: "agent", Method: models.LoginMethodPassword, Success: true}, db)
+	AddLoginAttempt(models.LoginAttempt{Username: "toto", UserId: 1, IpAddress: "10.0.0.2", UserAgent: "agent", Method: models.LoginMethodTwoFactor, Success: false}, db)
+	AddLoginAttempt(models.LoginAttempt{Username: "titi", UserId: 2, IpAddress: "10.0.0.2", UserAgent: "agent", Method: models.LoginMethodGoogle, Success: true}, db)
+	isNew, err := CheckNewLoginDevice(1, "10.0.0.1", "agent", db)
+	if err != nil || isNew {
+		t.Errorf("Expected a known device, got %t, %v", isNew, err)
+	}
+	isNew, _ = CheckNewLoginDevice(1, "10.0.0.2", "agent", db)
+	if !isNew {
+		t.Errorf("Expected the address of a failed login to be a new device")
+	}
+	isNew, _ = CheckNewLoginDevice(3, "10.0.0.1", "agent", db)
+	if isNew {
+		t.Errorf("Expected a user without logins to have no new device")
+	}
+
+	logins, err := GetLoginHistory(1, nil, 10, db)
+	if err != nil || len(logins) != 2 || logins[0].Method != models.LoginMethodTwoFactor || logins[0].Success || !logins[1].Success {
+		t.Fatalf("Expected the 2 logins of toto, newest first, got %+v, %v", logins, err)
+	}
+	logins, _ = GetLoginHistory(1, &models.Cursor{CreatedAt: logins[0].CreatedAt, Id: logins[0].Id}, 10, db)
+	if len(logins) != 1 || logins[0].IpAddress != "10.0.0.1" {
+		t.Errorf("Expected the oldest login after the cursor, got %+v", logins)
+	}
+}
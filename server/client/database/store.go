@@ -0,0 +1,292 @@
+package database
+
+import (
+	"project_truthful/models"
+	"time"
+)
+
+// Store gives access to everything the server persists, the client layer only goes through it
+type Store interface {
+	UserStore
+	OauthStore
+	QuestionStore
+	AnswerStore
+	LikeStore
+	FollowStore
+	BlockStore
+	MuteStore
+	AskerBlockStore
+	SettingsStore
+	MutedWordStore
+	HeldContentStore
+	ReportStore
+	TimelineStore
+	NotificationStore
+	BanStore
+	PardonStore
+	AddressBanStore
+	RateLimitStore
+	SessionStore
+	PasswordResetStore
+	EmailVerificationStore
+	TwoFactorStore
+	LoginAttemptStore
+	StreamTicketStore
+	RoleStore
+	ModerationStore
+}
+
+type UserStore interface {
+	InsertUser(username string, password string, email string, birthdate string) (int64, error)
+	InsertUserWithDisplayName(username string, displayName string, password string, email string, birthdate string) (int64, error)
+	CheckUsernameExists(username string) (bool, error)
+	CheckUserIdExists(id int) (bool, error)
+	CheckEmailExists(email string) (bool, error)
+	GetUserId(username string) (int, error)
+	GetHashedPassword(id int) (string, error)
+	GetUserIdByEmail(email string) (int, error)
+	UpdatePassword(id int, password string) error
+	GetUserEmail(id int) (string, bool, error)
+	SetEmailVerified(id int, email string) error
+	GetUsernameAndDisplayName(id int) (string, string, error)
+	UpdateUserInformations(id int, displayName string, email string) error
+	GetUserProfileInfos(id int, requestingUser int, count int, start int) (models.UserProfileInfos, error)
+	GetUserProfileInfosPage(id int, requestingUser int, cursor *models.Cursor, count int) (models.UserProfileInfos, error)
+}
+
+type OauthStore interface {
+	GetOAuthProvider(provider string) (int, error)
+	GetUserIdBySubject(providerId int, subject string) (int64, error)
+	InsertOauthLogin(providerId int, subject string, userId int64) error
+}
+
+type QuestionStore interface {
+	GetQuestions(userId int, start int, count int) ([]models.Question, error)
+	GetQuestionsPage(userId int, cursor *models.Cursor, count int) ([]models.Question, error)
+	GetQuestionReceiverId(questionId int) (int, error)
+	AddQuestion(question string, authorId int, authorIpAddress string, isAuthorAnonymous bool, receiverId int) (int64, error)
+	GetQuestionById(questionId int) (models.Question, error)
+	GetQuestionAuthorIpAddress(questionId int) (string, error)
+	MarkQuestionAsDeleted(questionId int) error
+	CheckQuestionRemoved(questionId int) (bool, error)
+	RemoveQuestion(questionId int, moderatorId int, reason string) error
+	RestoreQuestion(questionId int) error
+}
+
+type AnswerStore interface {
+	CheckAnswerIdExists(answerId int) (bool, error)
+	GetAnswerAuthorId(answerId int) (int, error)
+	HasQuestionBeenAnswered(questionId int) (bool, error)
+	GetAnswerIdByQuestionId(questionId int) (int, error)
+	AddAnswer(userId int, questionId int, answerText string, answererIpAddress string) (int64, error)
+	MarkAnswerAsDeleted(answerId int) error
+	CheckAnswerRemoved(answerId int) (bool, error)
+	RemoveAnswer(answerId int, moderatorId int, reason string) error
+	RestoreAnswer(answerId int) error
+}
+
+type LikeStore interface {
+	CheckLikeExists(userId int, postId int) (bool, error)
+	RemoveLike(userId int, postId int) error
+	AddLike(userId int, postId int) error
+	GetLikeCountForAnswer(answerId int) (int, error)
+}
+
+type FollowStore interface {
+	CheckFollowExists(follower int, followed int) (bool, error)
+	AddFollow(followerId int, followedId int) (bool, error)
+	RemoveFollow(followerId int, followedId int) error
+	GetFollowers(userId int, requestingUser int, cursor *models.Cursor, count int) ([]models.FollowPreview, error)
+	GetFollowing(userId int, requestingUser int, cursor *models.Cursor, count int) ([]models.FollowPreview, error)
+}
+
+type BlockStore interface {
+	CheckBlockExists(blockerId int, blockedId int) (bool, error)
+	AddBlock(blockerId int, blockedId int) (bool, error)
+	RemoveBlock(blockerId int, blockedId int) (bool, error)
+	GetBlockedUsers(userId int, cursor *models.Cursor, count int) ([]models.RelatedUser, error)
+}
+
+type MuteStore interface {
+	CheckMuteExists(muterId int, mutedId int) (bool, error)
+	AddMute(muterId int, mutedId int) (bool, error)
+	RemoveMute(muterId int, mutedId int) (bool, error)
+	GetMutedUsers(userId int, cursor *models.Cursor, count int) ([]models.RelatedUser, error)
+}
+
+type AskerBlockStore interface {
+	AddAskerBlock(receiverId int, ipHash string, questionId int, duration int) (int64, error)
+	CheckAskerBlockExists(receiverId int, ipHash string) (bool, error)
+	GetAskerBlocks(receiverId int, cursor *models.Cursor, count int) ([]models.AskerBlock, error)
+	RemoveAskerBlock(receiverId int, blockId int) (bool, error)
+}
+
+type SettingsStore interface {
+	GetUserSettings(userId int) (models.UserSettings, error)
+	UpdateUserSettings(userId int, settings models.UserSettings) error
+}
+
+type MutedWordStore interface {
+	GetMutedWords(userId int) ([]string, error)
+	AddMutedWord(userId int, word string) (bool, error)
+	RemoveMutedWord(userId int, word string) (bool, error)
+}
+
+type HeldContentStore interface {
+	AddHeldContent(content models.HeldContent) (int64, error)
+	GetHeldContent(heldId int) (models.HeldContent, error)
+	GetPendingHeldContents(cursor *models.Cursor, count int) ([]models.HeldContent, error)
+	CheckPendingHeldAnswer(questionId int) (bool, error)
+	ReviewHeldContent(heldId int, status string, reviewerId int) (bool, error)
+	SetHeldContentId(heldId int, contentId int) error
+}
+
+type ReportStore interface {
+	GetReportTargetOwnerId(targetType string, targetId int) (int, error)
+	AddReport(reporterId int, targetType string, targetId int, reason string, details string) (int64, error)
+	CheckOpenReportExists(reporterId int, targetType string, targetId int) (bool, error)
+	GetOpenReportGroups(cursor *models.Cursor, count int) ([]models.ReportGroup, error)
+	GetOpenReportGroup(targetType string, targetId int) (models.ReportGroup, error)
+	GetOpenReports(targetType string, targetId int) ([]models.Report, error)
+	ClaimReports(targetType string, targetId int, moderatorId int) error
+	ResolveReports(resolution models.ReportResolution) (int64, int, error)
+	GetReportResolutions(targetType string, targetId int, cursor *models.Cursor, count int) ([]models.ReportResolution, error)
+}
+
+type TimelineStore interface {
+	GetTimeline(userId int, cursor *models.Cursor, count int) ([]models.TimelineAnswer, error)
+}
+
+type NotificationStore interface {
+	AddNotification(userId int, notificationType string, actorId int, questionId int, answerId int) (int64, error)
+	GetNotifications(userId int, cursor *models.Cursor, count int) ([]models.Notification, error)
+	CountUnreadNotifications(userId int) (int, error)
+	MarkNotificationAsRead(userId int, notificationId int) (bool, error)
+	MarkAllNotificationsAsRead(userId int) error
+}
+
+type BanStore interface {
+	BanUser(userId int, requesterId int, duration int, reason string) (int64, error)
+	CheckUserBanStatus(userId int) (bool, error)
+	GetActiveBan(userId int) (models.Ban, error)
+	CheckBanExistsByBanId(banId int) (bool, error)
+	GetBan(banId int) (models.BanDetails, error)
+	GetBans(filter models.BanFilter, cursor *models.Cursor, count int) ([]models.BanDetails, error)
+	UpdateBanExpiration(banId int, expiresAt *time.Time) error
+}
+
+type PardonStore interface {
+	PardonUser(banId int, requesterId int) (int64, error)
+	CheckPardonExists(banId int) (bool, error)
+}
+
+type AddressBanStore interface {
+	AddAddressBan(banType string, value string, authorId int, duration int, reason string) (int64, error)
+	GetAddressBan(banId int) (models.AddressBan, error)
+	GetAddressBans(banType string, cursor *models.Cursor, count int) ([]models.AddressBan, error)
+	RemoveAddressBan(banId int) error
+	GetUserIpAddresses(userId int) ([]string, error)
+	GetAccountsByIpAddresses(ipAddresses []string, userId int) ([]models.LinkedAccount, error)
+}
+
+type RateLimitStore interface {
+	GetRateLimit(ip string) (models.RateLimit, error)
+	ResetRateLimit(ip string) error
+	IncrementRateLimit(ip string) error
+}
+
+type SessionStore interface {
+	InsertRefreshToken(sessionId string, userId int, tokenHash string, ipAddress string, userAgent string, expiresAt time.Time) (int64, error)
+	GetRefreshTokenByHash(tokenHash string) (models.RefreshToken, error)
+	MarkRefreshTokenAsRotated(id int) (bool, error)
+	RevokeSession(sessionId string) error
+	RevokeUserSession(userId int, sessionId string) (bool, error)
+	RevokeAllUserSessions(userId int) error
+	GetActiveSessions(userId int) ([]models.Session, error)
+}
+
+type PasswordResetStore interface {
+	AddPasswordResetRequest(ipAddress string, userId int) error
+	CountPasswordResetRequestsByIp(ipAddress string, since time.Time) (int, error)
+	CountPasswordResetRequestsByUser(userId int, since time.Time) (int, error)
+	AddPasswordResetToken(userId int, tokenHash string, expiresAt time.Time) (int64, error)
+	GetPasswordResetToken(tokenHash string) (models.PasswordResetToken, error)
+	UsePasswordResetToken(id int) (bool, error)
+	RevokePasswordResetTokens(userId int) error
+}
+
+type EmailVerificationStore interface {
+	AddEmailVerificationToken(userId int, email string, tokenHash string, expiresAt time.Time) (int64, error)
+	CountEmailVerificationTokens(userId int, since time.Time) (int, error)
+	GetEmailVerificationToken(tokenHash string) (models.EmailVerificationToken, error)
+	GetPendingEmail(userId int) (string, error)
+	UseEmailVerificationToken(id int) (bool, error)
+	RevokeEmailVerificationTokens(userId int) error
+}
+
+type TwoFactorStore interface {
+	GetTotpSecret(userId int) (models.TotpSecret, error)
+	AddTotpSecret(userId int, secret string) error
+	EnableTotp(userId int) error
+	UseTotpStep(userId int, step int64) (bool, error)
+	DeleteTotpSecret(userId int) error
+	AddRecoveryCodes(userId int, codeHashes []string) error
+	DeleteRecoveryCodes(userId int) error
+	UseRecoveryCode(userId int, codeHash string) (bool, error)
+	CountRecoveryCodes(userId int) (int, error)
+	AddLoginChallenge(userId int, tokenHash string, expiresAt time.Time) (int64, error)
+	GetLoginChallenge(tokenHash string) (models.LoginChallenge, error)
+	AddLoginChallengeAttempt(id int) error
+	UseLoginChallenge(id int) (bool, error)
+}
+
+type LoginAttemptStore interface {
+	AddLoginAttempt(attempt models.LoginAttempt) (int64, error)
+	FinishLoginAttempt(id int, userId int, success bool) error
+	DeleteLoginAttempt(id int) error
+	GetLoginFailuresByUsername(username string, since time.Time, beforeId int) (models.LoginFailures, error)
+	GetLoginFailuresByIp(ipAddress string, since time.Time, beforeId int) (models.LoginFailures, error)
+	CheckNewLoginDevice(userId int, ipAddress string, userAgent string) (bool, error)
+	GetLoginHistory(userId int, cursor *models.Cursor, count int) ([]models.LoginHistoryEntry, error)
+}
+
+type StreamTicketStore interface {
+	AddStreamTicket(userId int, tokenHash string, expiresAt time.Time, streamExpiresAt time.Time) error
+	GetStreamTicket(tokenHash string) (models.StreamTicket, error)
+	UseStreamTicket(id int) (bool, error)
+}
+
+type RoleStore interface {
+	CheckUserPermission(userId int, permission string) (bool, error)
+	CheckUserHasRole(userId int, roleId int) (bool, error)
+	CheckUserHasRoleName(userId int, roleName string) (bool, error)
+	GetRoleId(roleName string) (int, error)
+	GrantRole(userId int, roleId int, grantedBy int) error
+	RevokeRole(userId int, roleId int) (bool, error)
+	GetRoles() ([]models.Role, error)
+	GetUserRoles(userId int) ([]string, error)
+	CheckTwoFactorRequired(userId int) (bool, error)
+	SetRoleRequiresTwoFactor(roleId int, required bool) error
+}
+
+type ModerationStore interface {
+	AddAuditEntry(entry models.AuditEntry) error
+	GetAuditEntries(filter models.AuditFilter, cursor *models.Cursor, count int) ([]models.AuditEntry, error)
+	// InTransaction runs fn with a store whose writes are committed together when fn returns no error
+	InTransaction(fn func(store Store) error) error
+}
+
+var store Store
+
+// SetStore makes the client layer use the given store, nil goes back to the SQL store on DB
+func SetStore(s Store) {
+	store = s
+}
+
+// GetStore returns the store set by SetStore, or a SQL store on DB if none was set
+func GetStore() Store {
+	if store != nil {
+		return store
+	}
+	return NewSQLStore(DB)
+}
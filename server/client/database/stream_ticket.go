@@ -0,0 +1,44 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+	"time"
+)
+
+func AddStreamTicket(userId int, tokenHash string, expiresAt time.Time, streamExpiresAt time.Time, db Querier) error {
+	_, err := db.Exec("INSERT INTO stream_ticket (user_id, token_hash, expires_at, stream_expires_at) VALUES (?, ?, ?, ?)", userId, tokenHash, expiresAt.UTC(), streamExpiresAt.UTC())
+	if err != nil {
+		log.Printf("Error inserting stream ticket for user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
+
+// GetStreamTicket returns sql.ErrNoRows when no ticket has the hash
+func GetStreamTicket(tokenHash string, db Querier) (models.StreamTicket, error) {
+	var ticket models.StreamTicket
+	err := db.QueryRow("SELECT id, user_id, expires_at, stream_expires_at, used_at IS NOT NULL FROM stream_ticket WHERE token_hash = ?", tokenHash).
+		Scan(&ticket.Id, &ticket.UserId, &ticket.ExpiresAt, &ticket.StreamExpiresAt, &ticket.IsUsed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting stream ticket, %v\n", err)
+		return models.StreamTicket{}, err
+	}
+	return ticket, err
+}
+
+// UseStreamTicket returns false if the ticket had already been used by a concurrent request
+func UseStreamTicket(id int, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE stream_ticket SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL", id)
+	if err != nil {
+		log.Printf("Error using stream ticket %d, %v\n", id, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for stream ticket %d, %v\n", id, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
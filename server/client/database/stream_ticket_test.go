@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStreamTickets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	expiresAt := time.Now().Add(time.Minute).UTC()
+	streamExpiresAt := time.Now().Add(15 * time.Minute).UTC()
+
+	mock.ExpectExec("INSERT INTO stream_ticket").WithArgs(2, "hash", expiresAt, streamExpiresAt).WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := AddStreamTicket(2, "hash", expiresAt, streamExpiresAt, db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	mock.ExpectQuery("FROM stream_ticket WHERE token_hash = ").WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at", "stream_expires_at", "used"}).AddRow(1, 2, expiresAt, streamExpiresAt, false))
+	ticket, err := GetStreamTicket("hash", db)
+	if err != nil || ticket.Id != 1 || ticket.UserId != 2 || !ticket.StreamExpiresAt.Equal(streamExpiresAt) || ticket.IsUsed {
+		t.Errorf("Unexpected ticket %+v, %v", ticket, err)
+	}
+	mock.ExpectQuery("FROM stream_ticket WHERE token_hash = ").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	_, err = GetStreamTicket("unknown", db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	mock.ExpectExec("UPDATE stream_ticket SET used_at (.+) AND used_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	used, err := UseStreamTicket(1, db)
+	if err != nil || !used {
+		t.Errorf("Expected the ticket to be used, got %t, %v", used, err)
+	}
+	mock.ExpectExec("UPDATE stream_ticket SET used_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	used, err = UseStreamTicket(1, db)
+	if err != nil || used {
+		t.Errorf("Expected a used ticket to be refused, got %t, %v", used, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
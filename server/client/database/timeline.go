@@ -0,0 +1,83 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+)
+
+// AddAnswerToTimelines adds the answer to the timeline of each follower of its author
+func AddAnswerToTimelines(answerId int, db Querier) error {
+	_, err := db.Exec(insertIgnore()+" INTO timeline_entry (user_id, answer_id, answerer_id, created_at) "+
+		"SELECT follow.follower, answer.id, answer.user_id, answer.created_at FROM answer JOIN follow ON follow.followed = answer.user_id WHERE answer.id = ?", answerId)
+	if err != nil {
+		log.Printf("Error adding answer %d to the timelines, %v\n", answerId, err)
+		return err
+	}
+	return nil
+}
+
+// addFollowToTimeline adds the answers of the followed user to the timeline of the follower
+func addFollowToTimeline(followerId int, followedId int, db Querier) error {
+	_, err := db.Exec(insertIgnore()+" INTO timeline_entry (user_id, answer_id, answerer_id, created_at) "+
+		"SELECT ?, answer.id, answer.user_id, answer.created_at FROM answer WHERE answer.user_id = ? AND answer.has_been_deleted = 0", followerId, followedId)
+	if err != nil {
+		log.Printf("Error adding the answers of user %d to the timeline of user %d, %v\n", followedId, followerId, err)
+		return err
+	}
+	return nil
+}
+
+// removeFollowFromTimeline removes the answers of the followed user from the timeline of the follower
+func removeFollowFromTimeline(followerId int, followedId int, db Querier) error {
+	_, err := db.Exec("DELETE FROM timeline_entry WHERE user_id = ? AND answerer_id = ?", followerId, followedId)
+	if err != nil {
+		log.Printf("Error removing the answers of user %d from the timeline of user %d, %v\n", followedId, followerId, err)
+		return err
+	}
+	return nil
+}
+
+// GetTimeline returns the answers of the users followed by the user after the cursor, newest first.
+// Deleted answers and answers of banned or muted users are left out. The page is read from the timeline entries
+// of the user, with the questions and the likes of its answers
+func GetTimeline(userId int, cursor *models.Cursor, count int, db Querier) ([]models.TimelineAnswer, error) {
+	condition, args := pageConditionOn("timeline_entry.created_at", "timeline_entry.answer_id", cursor)
+	args = append([]any{userId, userId}, append(args, count)...)
+	rows, err := db.Query("SELECT answer.id, answer.text, answer.created_at, answer.removed_at IS NOT NULL, user.id, user.username, user.display_name, "+
+		"question.text, question.is_author_anonymous, question.removed_at IS NOT NULL, author.id, author.username, author.display_name, "+
+		"(SELECT COUNT(*) FROM answer_like WHERE answer_like.answer_id = answer.id), "+
+		"EXISTS (SELECT 1 FROM answer_like WHERE answer_like.answer_id = answer.id AND answer_like.user_id = ?) "+
+		"FROM timeline_entry JOIN answer ON answer.id = timeline_entry.answer_id JOIN user ON user.id = answer.user_id "+
+		"JOIN question ON question.id = answer.question_id LEFT JOIN user author ON author.id = question.author_id "+
+		"WHERE timeline_entry.user_id = ? AND answer.has_been_deleted = 0 "+
+		"AND NOT EXISTS (SELECT 1 FROM ban LEFT JOIN pardon ON pardon.ban_id = ban.id WHERE ban.user_id = answer.user_id AND pardon.id IS NULL AND (ban.expires_at IS NULL OR ban.expires_at > CURRENT_TIMESTAMP)) "+
+		"AND NOT EXISTS (SELECT 1 FROM user_mute WHERE user_mute.muter_id = timeline_entry.user_id AND user_mute.muted_id = answer.user_id)"+
+		condition+" ORDER BY timeline_entry.created_at DESC, timeline_entry.answer_id DESC LIMIT ?", args...)
+	if err != nil {
+		log.Printf("Error getting timeline for user %d, %v\n", userId, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := []models.TimelineAnswer{}
+	for rows.Next() {
+		var answer models.TimelineAnswer
+		var questionRemoved bool
+		var authorId sql.NullInt64
+		var authorUsername, authorDisplayName sql.NullString
+		err := rows.Scan(&answer.Id, &answer.AnswerText, &answer.CreatedAt, &answer.RemovedByModeration, &answer.Answerer.Id, &answer.Answerer.Username, &answer.Answerer.DisplayName,
+			&answer.QuestionText, &answer.IsAuthorAnonymous, &questionRemoved, &authorId, &authorUsername, &authorDisplayName, &answer.LikeCount, &answer.LikedByRequester)
+		if err != nil {
+			log.Printf("Error scanning timeline answer for user %d, %v\n", userId, err)
+			return nil, err
+		}
+		answer.RemovedByModeration = answer.RemovedByModeration || questionRemoved
+		if !answer.IsAuthorAnonymous && authorId.Valid {
+			answer.Author = models.UserPreview{Id: authorId.Int64, Username: authorUsername.String, DisplayName: authorDisplayName.String}
+		}
+		HideRemovedAnswer(&answer.Answer)
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
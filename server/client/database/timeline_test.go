@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetTimeline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	creationTime := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	columns := []string{"id", "text", "created_at", "removed", "id", "username", "display_name", "question_text", "is_author_anonymous", "question_removed",
+		"author_id", "author_username", "author_display_name", "like_count", "liked"}
+	// the page is read in one query, with the questions and the likes
+	rows := sqlmock.NewRows(columns).
+		AddRow(4, "answer", creationTime, false, 3, "titi", "Titi", "question", true, false, 5, "toto", "Toto", 2, true).
+		AddRow(3, "answer", creationTime, false, 3, "titi", "Titi", "question", false, false, 5, "toto", "Toto", 0, false).
+		AddRow(2, "answer", creationTime, false, 3, "titi", "Titi", "question", false, true, nil, nil, nil, 1, false)
+	mock.ExpectQuery("SELECT (.+) FROM timeline_entry JOIN answer ON answer.id = timeline_entry.answer_id (.+) WHERE timeline_entry.user_id = \\? AND answer.has_been_deleted = 0 AND NOT EXISTS \\(SELECT 1 FROM ban (.+)\\) ORDER BY timeline_entry.created_at DESC, timeline_entry.answer_id DESC LIMIT \\?").
+		WithArgs(1, 1, 11).WillReturnRows(rows)
+	answers, err := GetTimeline(1, nil, 11, db)
+	if err != nil {
+		t.Fatalf("Error while getting timeline: %s", err.Error())
+	}
+	if len(answers) != 3 || answers[0].Answerer.Username != "titi" || answers[0].QuestionText != "question" || answers[0].LikeCount != 2 || !answers[0].LikedByRequester {
+		t.Fatalf("Unexpected timeline %+v", answers)
+	}
+	if !answers[0].IsAuthorAnonymous || answers[0].Author.Id != 0 {
+		t.Errorf("The author of an anonymous question should be hidden, got %+v", answers[0])
+	}
+	if answers[1].Author.Username != "toto" || answers[1].LikedByRequester {
+		t.Errorf("Unexpected answer %+v", answers[1])
+	}
+	if !answers[2].RemovedByModeration || answers[2].QuestionText != "" || answers[2].AnswerText != "" {
+		t.Errorf("An answer to a removed question should be hidden, got %+v", answers[2])
+	}
+
+	cursor := &models.Cursor{CreatedAt: creationTime, Id: 4}
+	mock.ExpectQuery("SELECT (.+) FROM timeline_entry (.+) AND \\(timeline_entry.created_at < \\? OR \\(timeline_entry.created_at = \\? AND timeline_entry.answer_id < \\?\\)\\) ORDER BY").
+		WithArgs(1, 1, creationTime, creationTime, 4, 11).WillReturnRows(sqlmock.NewRows(columns))
+	answers, err = GetTimeline(1, cursor, 11, db)
+	if err != nil || len(answers) != 0 {
+		t.Errorf("Expected an empty timeline, got %+v, %v", answers, err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM timeline_entry").WithArgs(1, 1, 11).WillReturnError(errors.New("error"))
+	_, err = GetTimeline(1, nil, 11, db)
+	if err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
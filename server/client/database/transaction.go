@@ -0,0 +1,43 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+)
+
+// Querier runs the queries of the database functions, it is either the database or a transaction
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// inTransaction runs fn in a transaction committed when fn returns no error.
+// When db is already a transaction, fn joins it and the caller decides whether it commits
+func inTransaction(db Querier, fn func(tx Querier) error) error {
+	if tx, ok := db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction, %v\n", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction, %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"project_truthful/models"
+	"time"
+)
+
+// GetTotpSecret returns sql.ErrNoRows when the user never started an enrollment
+func GetTotpSecret(userId int, db Querier) (models.TotpSecret, error) {
+	var secret models.TotpSecret
+	err := db.QueryRow("SELECT user_id, secret, last_used_step, enabled_at IS NOT NULL FROM user_totp WHERE user_id = ?", userId).Scan(&secret.UserId, &secret.Secret, &secret.LastUsedStep, &secret.IsEnabled)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting totp secret of user %d, %v\n", userId, err)
+		return models.TotpSecret{}, err
+	}
+	return secret, err
+}
+
+// AddTotpSecret adds the secret of an enrollment, pending until EnableTotp
+func AddTotpSecret(userId int, secret string, db Querier) error {
+	_, err := db.Exec("INSERT INTO user_totp (user_id, secret) VALUES (?, ?)", userId, secret)
+	if err != nil {
+		log.Printf("Error inserting totp secret of user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
+
+func EnableTotp(userId int, db Querier) error {
+	_, err := db.Exec("UPDATE user_totp SET enabled_at = CURRENT_TIMESTAMP WHERE user_id = ?", userId)
+	if err != nil {
+		log.Printf("Error enabling totp of user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
+
+// UseTotpStep records the time step of a code, it returns false if a code of this step or a later one was already used
+func UseTotpStep(userId int, step int64, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?", step, userId, step)
+	if err != nil {
+		log.Printf("Error using totp step of user %d, %v\n", userId, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for totp step of user %d, %v\n", userId, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func DeleteTotpSecret(userId int, db Querier) error {
+	_, err := db.Exec("DELETE FROM user_totp WHERE user_id = ?", userId)
+	if err != nil {
+		log.Printf("Error deleting totp secret of user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
+
+func AddRecoveryCodes(userId int, codeHashes []string, db Querier) error {
+	for _, codeHash := range codeHashes {
+		_, err := db.Exec("INSERT INTO recovery_code (user_id, code_hash) VALUES (?, ?)", userId, codeHash)
+		if err != nil {
+			log.Printf("Error inserting recovery code of user %d, %v\n", userId, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func DeleteRecoveryCodes(userId int, db Querier) error {
+	_, err := db.Exec("DELETE FROM recovery_code WHERE user_id = ?", userId)
+	if err != nil {
+		log.Printf("Error deleting recovery codes of user %d, %v\n", userId, err)
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode returns false if the user has no unused code with the hash
+func UseRecoveryCode(userId int, codeHash string, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE recovery_code SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash)
+	if err != nil {
+		log.Printf("Error using recovery code of user %d, %v\n", userId, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for recovery code of user %d, %v\n", userId, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of the user
+func CountRecoveryCodes(userId int, db Querier) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM recovery_code WHERE user_id = ? AND used_at IS NULL", userId).Scan(&count)
+	if err != nil {
+		log.Printf("Error counting recovery codes of user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func AddLoginChallenge(userId int, tokenHash string, expiresAt time.Time, db Querier) (int64, error) {
+	result, err := db.Exec("INSERT INTO login_challenge (user_id, token_hash, expires_at) VALUES (?, ?, ?)", userId, tokenHash, expiresAt.UTC())
+	if err != nil {
+		log.Printf("Error inserting login challenge for user %d, %v\n", userId, err)
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetLoginChallenge returns sql.ErrNoRows when no challenge has the hash
+func GetLoginChallenge(tokenHash string, db Querier) (models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	err := db.QueryRow("SELECT id, user_id, attempts, expires_at, used_at IS NOT NULL FROM login_challenge WHERE token_hash = ?", tokenHash).Scan(&challenge.Id, &challenge.UserId, &challenge.Attempts, &challenge.ExpiresAt, &challenge.IsUsed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting login challenge, %v\n", err)
+		return models.LoginChallenge{}, err
+	}
+	return challenge, err
+}
+
+// AddLoginChallengeAttempt counts a wrong code given to the challenge
+func AddLoginChallengeAttempt(id int, db Querier) error {
+	_, err := db.Exec("UPDATE login_challenge SET attempts = attempts + 1 WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error counting attempt of login challenge %d, %v\n", id, err)
+		return err
+	}
+	return nil
+}
+
+// UseLoginChallenge returns false if the challenge had already been passed by a concurrent request
+func UseLoginChallenge(id int, db Querier) (bool, error) {
+	result, err := db.Exec("UPDATE login_challenge SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL", id)
+	if err != nil {
+		log.Printf("Error using login challenge %d, %v\n", id, err)
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows for login challenge %d, %v\n", id, err)
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTotpSecret(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT user_id, secret, last_used_step, enabled_at IS NOT NULL FROM user_totp WHERE user_id = ").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "last_used_step", "enabled"}).AddRow(2, "SECRET", 10, true))
+	secret, err := GetTotpSecret(2, db)
+	if err != nil || secret.Secret != "SECRET" || secret.LastUsedStep != 10 || !secret.IsEnabled {
+		t.Errorf("Unexpected secret %+v, %v", secret, err)
+	}
+	mock.ExpectQuery("FROM user_totp WHERE user_id = ").WithArgs(3).WillReturnError(sql.ErrNoRows)
+	_, err = GetTotpSecret(3, db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	mock.ExpectExec("INSERT INTO user_totp").WithArgs(2, "SECRET").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := AddTotpSecret(2, "SECRET", db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	mock.ExpectExec("UPDATE user_totp SET enabled_at").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := EnableTotp(2, db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	mock.ExpectExec("UPDATE user_totp SET last_used_step").WithArgs(int64(11), 2, int64(11)).WillReturnResult(sqlmock.NewResult(0, 1))
+	used, err := UseTotpStep(2, 11, db)
+	if err != nil || !used {
+		t.Errorf("Expected the step to be used, got %t, %v", used, err)
+	}
+	mock.ExpectExec("UPDATE user_totp SET last_used_step").WithArgs(int64(11), 2, int64(11)).WillReturnResult(sqlmock.NewResult(0, 0))
+	used, err = UseTotpStep(2, 11, db)
+	if err != nil || used {
+		t.Errorf("Expected a replayed step to be refused, got %t, %v", used, err)
+	}
+
+	mock.ExpectExec("DELETE FROM user_totp").WithArgs(2).WillReturnError(errors.New("error"))
+	if err := DeleteTotpSecret(2, db); err == nil {
+		t.Errorf("Error should not be nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO recovery_code").WithArgs(2, "hash1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO recovery_code").WithArgs(2, "hash2").WillReturnResult(sqlmock.NewResult(2, 1))
+	if err := AddRecoveryCodes(2, []string{"hash1", "hash2"}, db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	mock.ExpectExec("UPDATE recovery_code SET used_at (.+) AND used_at IS NULL").WithArgs(2, "hash1").WillReturnResult(sqlmock.NewResult(0, 1))
+	used, err := UseRecoveryCode(2, "hash1", db)
+	if err != nil || !used {
+		t.Errorf("Expected the code to be used, got %t, %v", used, err)
+	}
+	mock.ExpectExec("UPDATE recovery_code SET used_at").WithArgs(2, "hash1").WillReturnResult(sqlmock.NewResult(0, 0))
+	used, err = UseRecoveryCode(2, "hash1", db)
+	if err != nil || used {
+		t.Errorf("Expected a used code to be refused, got %t, %v", used, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM recovery_code WHERE user_id = (.+) AND used_at IS NULL").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	count, err := CountRecoveryCodes(2, db)
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 code, got %d, %v", count, err)
+	}
+
+	mock.ExpectExec("DELETE FROM recovery_code").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := DeleteRecoveryCodes(2, db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestLoginChallenges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	expiresAt := time.Now().Add(time.Minute).UTC()
+
+	mock.ExpectExec("INSERT INTO login_challenge").WithArgs(2, "hash", expiresAt).WillReturnResult(sqlmock.NewResult(1, 1))
+	id, err := AddLoginChallenge(2, "hash", expiresAt, db)
+	if err != nil || id != 1 {
+		t.Errorf("Expected challenge 1, got %d, %v", id, err)
+	}
+
+	mock.ExpectQuery("FROM login_challenge WHERE token_hash = ").WithArgs("hash").WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "attempts", "expires_at", "used"}).AddRow(1, 2, 1, expiresAt, false))
+	challenge, err := GetLoginChallenge("hash", db)
+	if err != nil || challenge.UserId != 2 || challenge.Attempts != 1 || challenge.IsUsed {
+		t.Errorf("Unexpected challenge %+v, %v", challenge, err)
+	}
+	mock.ExpectQuery("FROM login_challenge WHERE token_hash = ").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	_, err = GetLoginChallenge("unknown", db)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	mock.ExpectExec("UPDATE login_challenge SET attempts = attempts \\+ 1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := AddLoginChallengeAttempt(1, db); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	mock.ExpectExec("UPDATE login_challenge SET used_at (.+) AND used_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	used, err := UseLoginChallenge(1, db)
+	if err != nil || !used {
+		t.Errorf("Expected the challenge to be used, got %t, %v", used, err)
+	}
+	mock.ExpectExec("UPDATE login_challenge SET used_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	used, err = UseLoginChallenge(1, db)
+	if err != nil || used {
+		t.Errorf("Expected a used challenge to be refused, got %t, %v", used, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
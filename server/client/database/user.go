@@ -5,7 +5,7 @@ import (
 	"log"
 )
 
-func InsertUser(username string, password string, email string, birthdate string, db *sql.DB) (int64, error) {
+func InsertUser(username string, password string, email string, birthdate string, db Querier) (int64, error) {
 	result, err := db.Exec("INSERT INTO user (username, display_name, password, email, birthdate) VALUES (?, ?, ?, ?, ?)", username, username, password, email, birthdate)
 	if err != nil {
 		log.Printf("Error inserting user %s, %v\n", username, err)
@@ -14,7 +14,7 @@ func InsertUser(username string, password string, email string, birthdate string
 	return result.LastInsertId()
 }
 
-func InsertUserWithDisplayName(username string, displayName string, password string, email string, birthdate string, db *sql.DB) (int64, error) {
+func InsertUserWithDisplayName(username string, displayName string, password string, email string, birthdate string, db Querier) (int64, error) {
 	result, err := db.Exec("INSERT INTO user (username, display_name, password, email, birthdate) VALUES (?, ?, ?, ?, ?)", username, displayName, password, email, birthdate)
 	if err != nil {
 		log.Printf("Error inserting user %s, %v\n", username, err)
@@ -23,7 +23,7 @@ func InsertUserWithDisplayName(username string, displayName string, password str
 	return result.LastInsertId()
 }
 
-func CheckUsernameExists(username string, db *sql.DB) (bool, error) {
+func CheckUsernameExists(username string, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE username = ?", username).Scan(&count)
 	if err != nil {
@@ -33,7 +33,7 @@ func CheckUsernameExists(username string, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func CheckUserIdExists(id int, db *sql.DB) (bool, error) {
+func CheckUserIdExists(id int, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE id = ?", id).Scan(&count)
 	if err != nil {
@@ -43,7 +43,7 @@ func CheckUserIdExists(id int, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func CheckEmailExists(email string, db *sql.DB) (bool, error) {
+func CheckEmailExists(email string, db Querier) (bool, error) {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE email = ?", email).Scan(&count)
 	if err != nil {
@@ -53,7 +53,7 @@ func CheckEmailExists(email string, db *sql.DB) (bool, error) {
 	return count > 0, nil
 }
 
-func GetUserId(username string, db *sql.DB) (int, error) {
+func GetUserId(username string, db Querier) (int, error) {
 	var id int
 	err := db.QueryRow("SELECT id FROM user WHERE username = ?", username).Scan(&id)
 	if err != nil && err != sql.ErrNoRows {
@@ -66,7 +66,7 @@ func GetUserId(username string, db *sql.DB) (int, error) {
 	return id, nil
 }
 
-func GetHashedPassword(id int, db *sql.DB) (string, error) {
+func GetHashedPassword(id int, db Querier) (string, error) {
 	var password string
 	err := db.QueryRow("SELECT password FROM user WHERE id = ?", id).Scan(&password)
 	if err != nil {
@@ -76,7 +76,49 @@ func GetHashedPassword(id int, db *sql.DB) (string, error) {
 	return password, nil
 }
 
-func GetUsernameAndDisplayName(id int, db *sql.DB) (string, string, error) {
+// GetUserIdByEmail returns sql.ErrNoRows when no account uses the email
+func GetUserIdByEmail(email string, db Querier) (int, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM user WHERE email = ?", email).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error getting user id for email %s, %v\n", email, err)
+		return 0, err
+	}
+	return id, err
+}
+
+func UpdatePassword(id int, password string, db Querier) error {
+	_, err := db.Exec("UPDATE user SET password = ? WHERE id = ?", password, id)
+	if err != nil {
+		log.Printf("Error updating password for id %d, %v\n", id, err)
+		return err
+	}
+	return nil
+}
+
+// GetUserEmail returns the email of the user and whether it has been verified
+func GetUserEmail(id int, db Querier) (string, bool, error) {
+	var email string
+	var verified bool
+	err := db.QueryRow("SELECT email, email_verified FROM user WHERE id = ?", id).Scan(&email, &verified)
+	if err != nil {
+		log.Printf("Error getting email for id %d, %v\n", id, err)
+		return "", false, err
+	}
+	return email, verified, nil
+}
+
+// SetEmailVerified sets the email of the user, as verified
+func SetEmailVerified(id int, email string, db Querier) error {
+	_, err := db.Exec("UPDATE user SET email = ?, email_verified = 1 WHERE id = ?", email, id)
+	if err != nil {
+		log.Printf("Error setting verified email for id %d, %v\n", id, err)
+		return err
+	}
+	return nil
+}
+
+func GetUsernameAndDisplayName(id int, db Querier) (string, string, error) {
 	var username string
 	var displayName string
 	err := db.QueryRow("SELECT username, display_name FROM user WHERE id = ?", id).Scan(&username, &displayName)
@@ -87,7 +129,7 @@ func GetUsernameAndDisplayName(id int, db *sql.DB) (string, string, error) {
 	return username, displayName, nil
 }
 
-func UpdateUserInformations(id int, displayName string, email string, db *sql.DB) error {
+func UpdateUserInformations(id int, displayName string, email string, db Querier) error {
 	_, err := db.Exec("UPDATE user SET display_name = ?, email = ? WHERE id = ?", displayName, email, id)
 	if err != nil {
 		log.Printf("Error updating user informations for id %d, %v\n", id, err)
@@ -96,7 +138,7 @@ func UpdateUserInformations(id int, displayName string, email string, db *sql.DB
 	return nil
 }
 
-func GetOAuthProvider(provider string, db *sql.DB) (int, error) {
+func GetOAuthProvider(provider string, db Querier) (int, error) {
 	var id int
 	err := db.QueryRow("SELECT id FROM oauth_provider WHERE name = ?", provider).Scan(&id)
 	if err != nil && err != sql.ErrNoRows {
@@ -109,7 +151,7 @@ func GetOAuthProvider(provider string, db *sql.DB) (int, error) {
 	return id, nil
 }
 
-func GetUserIdBySubject(providerId int, subject string, db *sql.DB) (int64, error) {
+func GetUserIdBySubject(providerId int, subject string, db Querier) (int64, error) {
 	var id int64
 	err := db.QueryRow("SELECT user_id FROM oauth_login WHERE oauth_provider_id = ? AND subject_id = ?", providerId, subject).Scan(&id)
 	if err != nil && err != sql.ErrNoRows {
@@ -122,7 +164,7 @@ func GetUserIdBySubject(providerId int, subject string, db *sql.DB) (int64, erro
 	return id, nil
 }
 
-func InsertOauthLogin(providerId int, subject string, userId int64, db *sql.DB) error {
+func InsertOauthLogin(providerId int, subject string, userId int64, db Querier) error {
 	_, err := db.Exec("INSERT INTO oauth_login (oauth_provider_id, subject_id, user_id) VALUES (?, ?, ?)", providerId, subject, userId)
 	if err != nil {
 		log.Printf("Error inserting oauth login for provider %d, subject %s and user %d, %v\n", providerId, subject, userId, err)
@@ -8,7 +8,7 @@ import (
 )
 
 func MarkQuestionAsDeleted(userId int, questionId int) (int, error) {
-	authorId, err := database.GetQuestionReceiverId(questionId, database.DB)
+	authorId, err := database.GetStore().GetQuestionReceiverId(questionId)
 	if err != nil && err == sql.ErrNoRows {
 		// if the question doesn't exist, we return a 404
 		return http.StatusNotFound, errors.New("question not found")
@@ -20,20 +20,25 @@ func MarkQuestionAsDeleted(userId int, questionId int) (int, error) {
 		return http.StatusForbidden, errors.New("user is not the receiver of the question")
 	}
 
-	// we check if the user has already answered the question. if so, we delete the answer
+	return deleteQuestion(questionId)
+}
+
+// deleteQuestion marks the question as deleted, with its answer if it was answered
+func deleteQuestion(questionId int) (int, error) {
+	// we check if the question has already been answered. if so, we delete the answer
 	// below code is shitty. we should get the answer id from the db
-	answerId, err := database.GetAnswerIdByQuestionId(questionId, database.DB)
+	answerId, err := database.GetStore().GetAnswerIdByQuestionId(questionId)
 	if err != nil && err != sql.ErrNoRows {
 		return http.StatusInternalServerError, err
 	}
 	if err != sql.ErrNoRows {
-		err = database.MarkAnswerAsDeleted(answerId, database.DB)
+		err = database.GetStore().MarkAnswerAsDeleted(answerId)
 		if err != nil {
 			return http.StatusInternalServerError, err
 		}
 	}
 
-	err = database.MarkQuestionAsDeleted(questionId, database.DB)
+	err = database.GetStore().MarkQuestionAsDeleted(questionId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
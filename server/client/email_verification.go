@@ -0,0 +1,244 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/mailer"
+	"project_truthful/models"
+	"strings"
+	"time"
+)
+
+// a verification link can be used once, within a day
+const emailVerificationTokenDuration = 24 * time.Hour
+
+// verification emails a user can get per hour
+const (
+	emailVerificationWindow = time.Hour
+	maxEmailVerifications   = 3
+)
+
+// page of the front-end the verification links lead to when EMAIL_VERIFICATION_URL is not set
+const defaultEmailVerificationPageUrl = "http://localhost:3000/verify_email"
+
+// actions refused to unverified accounts when EMAIL_VERIFICATION_REQUIRED_FOR is not set
+const defaultEmailVerificationRequiredFor = "ask,answer"
+
+// actions which can require a verified email
+const (
+	VerifiedEmailToAsk    = "ask"
+	VerifiedEmailToAnswer = "answer"
+)
+
+const emailVerificationEmailSubject = "Verify your Truthful email address"
+const emailVerificationEmailBody = "Someone asked to use this address for a Truthful account.\n\n" +
+	"Follow this link within a day to confirm it:\n%s\n\n" +
+	"If it wasn't you, ignore this email, the address won't be used."
+
+var errTooManyEmailVerifications = errors.New("too many verification emails, try again later")
+var errInvalidEmailVerificationToken = errors.New("invalid or expired verification token")
+var errEmailNotVerified = errors.New("verify your email address first")
+var errEmailAlreadyExists = errors.New("email already exists")
+
+// emailVerificationLink returns the link to the page of the front-end verifying the address with the token
+func emailVerificationLink(verificationToken string) string {
+	pageUrl := os.Getenv("EMAIL_VERIFICATION_URL")
+	if pageUrl == "" {
+		pageUrl = defaultEmailVerificationPageUrl
+	}
+	return pageUrl + "?token=" + url.QueryEscape(verificationToken)
+}
+
+// isEmailVerificationRequired tells if EMAIL_VERIFICATION_REQUIRED_FOR lists the action, none lists nothing
+func isEmailVerificationRequired(action string) bool {
+	requiredFor := os.Getenv("EMAIL_VERIFICATION_REQUIRED_FOR")
+	if requiredFor == "" {
+		requiredFor = defaultEmailVerificationRequiredFor
+	}
+	for _, required := range strings.Split(requiredFor, ",") {
+		if strings.TrimSpace(required) == action {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEmailVerified refuses the action to the user when it requires a verified email and the email of the user isn't
+func CheckEmailVerified(userId int, action string) (int, error) {
+	if !isEmailVerificationRequired(action) {
+		return http.StatusOK, nil
+	}
+	_, verified, err := database.GetStore().GetUserEmail(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !verified {
+		return http.StatusForbidden, errEmailNotVerified
+	}
+	return http.StatusOK, nil
+}
+
+// checkEmailVerificationsSent refuses to send another verification email to a user who got too many of them
+func checkEmailVerificationsSent(userId int) (int, error) {
+	count, err := database.GetStore().CountEmailVerificationTokens(userId, time.Now().Add(-emailVerificationWindow))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if count >= maxEmailVerifications {
+		return http.StatusTooManyRequests, errTooManyEmailVerifications
+	}
+	return http.StatusOK, nil
+}
+
+// sendEmailVerification creates a verification token for the address and emails its link in the background,
+// the links sent before to the user stop working
+func sendEmailVerification(userId int, email string) (int, error) {
+	var verificationToken string
+	err := database.GetStore().InTransaction(func(store database.Store) error {
+		var err error
+		verificationToken, err = addEmailVerificationToken(store, userId, email)
+		return err
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	sendEmailVerificationLink(userId, email, verificationToken)
+	return http.StatusOK, nil
+}
+
+// addEmailVerificationToken creates a verification token for the address and revokes the ones created before for the user,
+// the store is expected to be a transaction
+func addEmailVerificationToken(store database.Store, userId int, email string) (string, error) {
+	verificationToken, err := token.GenerateEmailVerificationToken()
+	if err != nil {
+		return "", err
+	}
+	err = store.RevokeEmailVerificationTokens(userId)
+	if err != nil {
+		return "", err
+	}
+	_, err = store.AddEmailVerificationToken(userId, email, token.HashEmailVerificationToken(verificationToken), time.Now().Add(emailVerificationTokenDuration))
+	if err != nil {
+		return "", err
+	}
+	return verificationToken, nil
+}
+
+// sendEmailVerificationLink emails the link of the token in the background.
+// The token is saved already, a failure to send is only logged and the link can be sent again
+func sendEmailVerificationLink(userId int, email string, verificationToken string) {
+	inBackground(func() {
+		err := mailer.Send(mailer.Message{To: email, Subject: emailVerificationEmailSubject, Body: fmt.Sprintf(emailVerificationEmailBody, emailVerificationLink(verificationToken))})
+		if err != nil {
+			log.Printf("Error while sending verification email to user %d: %s\n", userId, err.Error())
+		}
+	})
+}
+
+// getPendingEmail returns the address waiting for its verification to replace the email of the user, empty when there is none
+func getPendingEmail(userId int, email string) (string, error) {
+	pendingEmail, err := database.GetStore().GetPendingEmail(userId)
+	if err == sql.ErrNoRows || strings.EqualFold(pendingEmail, email) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return pendingEmail, nil
+}
+
+// GetEmailStatus returns the email of the user, whether it is verified and the address waiting to replace it
+func GetEmailStatus(userId int) (models.EmailStatus, int, error) {
+	email, verified, err := database.GetStore().GetUserEmail(userId)
+	if err == sql.ErrNoRows {
+		return models.EmailStatus{}, http.StatusNotFound, errors.New("user not found")
+	} else if err != nil {
+		return models.EmailStatus{}, http.StatusInternalServerError, err
+	}
+	status := models.EmailStatus{Email: email, Verified: verified}
+	pendingEmail, err := getPendingEmail(userId, email)
+	if err != nil {
+		return models.EmailStatus{}, http.StatusInternalServerError, err
+	}
+	if pendingEmail != "" {
+		status.PendingEmail = &pendingEmail
+	}
+	return status, http.StatusOK, nil
+}
+
+// ResendEmailVerification sends the verification link again, to the address waiting to replace the email if any
+func ResendEmailVerification(userId int) (int, error) {
+	email, verified, err := database.GetStore().GetUserEmail(userId)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, errors.New("user not found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	pendingEmail, err := getPendingEmail(userId, email)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if pendingEmail != "" {
+		email = pendingEmail
+	} else if verified {
+		return http.StatusBadRequest, errors.New("email address already verified")
+	}
+	code, err := checkEmailVerificationsSent(userId)
+	if err != nil {
+		return code, err
+	}
+	return sendEmailVerification(userId, email)
+}
+
+// VerifyEmail verifies the address the token was sent to, which becomes the email of the user.
+// The other verification tokens of the user are revoked
+func VerifyEmail(verificationToken string) (int, error) {
+	if verificationToken == "" {
+		return http.StatusBadRequest, errors.New("token is required")
+	}
+	verificationTokenInfos, err := database.GetStore().GetEmailVerificationToken(token.HashEmailVerificationToken(verificationToken))
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest, errInvalidEmailVerificationToken
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if verificationTokenInfos.IsUsed || verificationTokenInfos.ExpiresAt.Before(time.Now()) {
+		return http.StatusBadRequest, errInvalidEmailVerificationToken
+	}
+	// another account may have taken the address since the link was sent
+	userId := verificationTokenInfos.UserId
+	ownerId, err := database.GetStore().GetUserIdByEmail(verificationTokenInfos.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	if err == nil && ownerId != userId {
+		return http.StatusBadRequest, errEmailAlreadyExists
+	}
+
+	err = database.GetStore().InTransaction(func(store database.Store) error {
+		used, err := store.UseEmailVerificationToken(verificationTokenInfos.Id)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errInvalidEmailVerificationToken
+		}
+		err = store.SetEmailVerified(userId, verificationTokenInfos.Email)
+		if err != nil {
+			return err
+		}
+		return store.RevokeEmailVerificationTokens(userId)
+	})
+	if err == errInvalidEmailVerificationToken {
+		return http.StatusBadRequest, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/mailer"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectUserEmail(mock sqlmock.Sqlmock, userId int, email string, verified bool) {
+	mock.ExpectQuery("SELECT email, email_verified FROM user WHERE id = ").WithArgs(userId).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow(email, verified))
+}
+
+func TestCheckEmailVerified(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	t.Setenv("EMAIL_VERIFICATION_REQUIRED_FOR", "")
+	expectUserEmail(mock, 2, "toto@toto.fr", false)
+	code, err := CheckEmailVerified(2, VerifiedEmailToAsk)
+	if code != http.StatusForbidden || err != errEmailNotVerified {
+		t.Errorf("Expected 403, got %d, %v", code, err)
+	}
+	expectUserEmail(mock, 2, "toto@toto.fr", true)
+	code, err = CheckEmailVerified(2, VerifiedEmailToAnswer)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected 200, got %d, %v", code, err)
+	}
+
+	// the actions which don't require a verified email don't query the database
+	t.Setenv("EMAIL_VERIFICATION_REQUIRED_FOR", "answer")
+	code, err = CheckEmailVerified(2, VerifiedEmailToAsk)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected 200, got %d, %v", code, err)
+	}
+	t.Setenv("EMAIL_VERIFICATION_REQUIRED_FOR", "none")
+	code, err = CheckEmailVerified(2, VerifiedEmailToAnswer)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected 200, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetEmailStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// a pending token for the current email is not a change of address
+	expectUserEmail(mock, 2, "toto@toto.fr", false)
+	mock.ExpectQuery("SELECT email FROM email_verification_token").WithArgs(2, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("TOTO@toto.fr"))
+	status, code, err := GetEmailStatus(2)
+	if code != http.StatusOK || err != nil || status.Email != "toto@toto.fr" || status.Verified || status.PendingEmail != nil {
+		t.Errorf("Expected the unverified email without pending email, got %+v, %d, %v", status, code, err)
+	}
+
+	expectUserEmail(mock, 2, "toto@toto.fr", true)
+	mock.ExpectQuery("SELECT email FROM email_verification_token").WithArgs(2, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("titi@titi.fr"))
+	status, code, err = GetEmailStatus(2)
+	if code != http.StatusOK || err != nil || !status.Verified || status.PendingEmail == nil || *status.PendingEmail != "titi@titi.fr" {
+		t.Errorf("Expected titi@titi.fr pending, got %+v, %d, %v", status, code, err)
+	}
+
+	mock.ExpectQuery("SELECT email, email_verified FROM user WHERE id = ").WithArgs(3).WillReturnError(sql.ErrNoRows)
+	_, code, _ = GetEmailStatus(3)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestResendEmailVerification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	recorder := &recordingMailer{}
+	mailer.SetMailer(recorder)
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+
+	expectUserEmail(mock, 2, "toto@toto.fr", true)
+	mock.ExpectQuery("SELECT email FROM email_verification_token").WithArgs(2, sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
+	code, err := ResendEmailVerification(2)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected a verified email without pending email to be refused, got %d, %v", code, err)
+	}
+
+	// the link goes to the pending email
+	expectUserEmail(mock, 2, "toto@toto.fr", true)
+	mock.ExpectQuery("SELECT email FROM email_verification_token").WithArgs(2, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("titi@titi.fr"))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM email_verification_token").WithArgs(2, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE email_verification_token SET used_at").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO email_verification_token").WithArgs(2, "titi@titi.fr", token.HashEmailVerificationToken("test"), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	runBackground := holdBackground(t)
+	code, err = ResendEmailVerification(2)
+	if code != http.StatusOK || err != nil || len(recorder.messages) != 0 {
+		t.Fatalf("Expected the email to be sent in the background, got %d, %v, %+v", code, err, recorder.messages)
+	}
+	runBackground()
+	if len(recorder.messages) != 1 {
+		t.Fatalf("Expected the email to be sent, got %+v", recorder.messages)
+	}
+	if recorder.messages[0].To != "titi@titi.fr" || !strings.Contains(recorder.messages[0].Body, defaultEmailVerificationPageUrl+"?token=test") {
+		t.Errorf("Unexpected email %+v", recorder.messages[0])
+	}
+
+	expectUserEmail(mock, 2, "toto@toto.fr", false)
+	mock.ExpectQuery("SELECT email FROM email_verification_token").WithArgs(2, sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM email_verification_token").WithArgs(2, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(maxEmailVerifications))
+	code, err = ResendEmailVerification(2)
+	if code != http.StatusTooManyRequests || err != errTooManyEmailVerifications {
+		t.Errorf("Expected 429, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	columns := []string{"id", "user_id", "email", "expires_at", "used"}
+	hash := token.HashEmailVerificationToken("verify")
+
+	mock.ExpectQuery("FROM email_verification_token WHERE token_hash = ").WithArgs(hash).WillReturnError(sql.ErrNoRows)
+	code, err := VerifyEmail("verify")
+	if code != http.StatusBadRequest || err != errInvalidEmailVerificationToken {
+		t.Errorf("Expected an unknown token to be refused, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("FROM email_verification_token WHERE token_hash = ").WithArgs(hash).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, "titi@titi.fr", time.Now().Add(-time.Minute), false))
+	code, err = VerifyEmail("verify")
+	if code != http.StatusBadRequest || err != errInvalidEmailVerificationToken {
+		t.Errorf("Expected an expired token to be refused, got %d, %v", code, err)
+	}
+
+	// another account took the address since the link was sent
+	mock.ExpectQuery("FROM email_verification_token WHERE token_hash = ").WithArgs(hash).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, "titi@titi.fr", time.Now().Add(time.Hour), false))
+	mock.ExpectQuery("SELECT id FROM user WHERE email = ").WithArgs("titi@titi.fr").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	code, err = VerifyEmail("verify")
+	if code != http.StatusBadRequest || err != errEmailAlreadyExists {
+		t.Errorf("Expected a taken email to be refused, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("FROM email_verification_token WHERE token_hash = ").WithArgs(hash).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, "titi@titi.fr", time.Now().Add(time.Hour), false))
+	mock.ExpectQuery("SELECT id FROM user WHERE email = ").WithArgs("titi@titi.fr").WillReturnError(sql.ErrNoRows)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE email_verification_token SET used_at (.+) WHERE id = ").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE user SET email = (.+), email_verified = 1").WithArgs("titi@titi.fr", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE email_verification_token SET used_at (.+) WHERE user_id = ").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	code, err = VerifyEmail("verify")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected the email to be verified, got %d, %v", code, err)
+	}
+
+	// a concurrent request already used the token
+	mock.ExpectQuery("FROM email_verification_token WHERE token_hash = ").WithArgs(hash).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, "titi@titi.fr", time.Now().Add(time.Hour), false))
+	mock.ExpectQuery("SELECT id FROM user WHERE email = ").WithArgs("titi@titi.fr").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE email_verification_token SET used_at (.+) WHERE id = ").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	code, err = VerifyEmail("verify")
+	if code != http.StatusBadRequest || err != errInvalidEmailVerificationToken {
+		t.Errorf("Expected the used token to be refused, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -1,9 +1,13 @@
 package client
 
 import (
+	"database/sql"
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+	"project_truthful/notifications"
 )
 
 func FollowUser(followerId int, followeeId int) (int, error) {
@@ -11,7 +15,7 @@ func FollowUser(followerId int, followeeId int) (int, error) {
 		return http.StatusBadRequest, errors.New("user can't follow himself")
 	}
 
-	followeeExists, err := database.CheckUserIdExists(followeeId, database.DB)
+	followeeExists, err := database.GetStore().CheckUserIdExists(followeeId)
 
 	if err != nil {
 		return http.StatusInternalServerError, err
@@ -20,7 +24,7 @@ func FollowUser(followerId int, followeeId int) (int, error) {
 		return http.StatusNotFound, errors.New("followee not found")
 	}
 
-	followerExists, err := database.CheckUserIdExists(followerId, database.DB)
+	followerExists, err := database.GetStore().CheckUserIdExists(followerId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -28,7 +32,20 @@ func FollowUser(followerId int, followeeId int) (int, error) {
 		return http.StatusNotFound, errors.New("follower not found")
 	}
 
-	followExists, err := database.CheckFollowExists(followerId, followeeId, database.DB)
+	// users can follow each other only if neither blocks the other
+	code, err := checkNotBlocked(followerId, followeeId)
+	if err != nil {
+		return code, err
+	}
+	blocksFollowee, err := database.GetStore().CheckBlockExists(followerId, followeeId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if blocksFollowee {
+		return http.StatusForbidden, errors.New("user blocks this user")
+	}
+
+	followExists, err := database.GetStore().CheckFollowExists(followerId, followeeId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -36,16 +53,21 @@ func FollowUser(followerId int, followeeId int) (int, error) {
 		return http.StatusBadRequest, errors.New("user already follows this user")
 	}
 
-	err = database.AddFollow(followerId, followeeId, database.DB)
+	// the follow may have been added by a concurrent request since the check
+	created, err := database.GetStore().AddFollow(followerId, followeeId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	if !created {
+		return http.StatusBadRequest, errors.New("user already follows this user")
+	}
+	notifications.NotifyNewFollower(followeeId, followerId)
 
 	return http.StatusOK, nil
 }
 
 func UnfollowUser(followerId int, followeeId int) (int, error) {
-	followExists, err := database.CheckFollowExists(followerId, followeeId, database.DB)
+	followExists, err := database.GetStore().CheckFollowExists(followerId, followeeId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -53,10 +75,51 @@ func UnfollowUser(followerId int, followeeId int) (int, error) {
 		return http.StatusBadRequest, errors.New("user doesn't follow this user")
 	}
 
-	err = database.RemoveFollow(followerId, followeeId, database.DB)
+	err = database.GetStore().RemoveFollow(followerId, followeeId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
 	return http.StatusOK, nil
 }
+
+// GetFollowers returns a page of the users following the user, most recent follow first
+func GetFollowers(username string, requestingUser int, cursor string, count int) (models.FollowPage, int, error) {
+	return getFollowPage(username, cursor, count, func(id int, after *models.Cursor, count int) ([]models.FollowPreview, error) {
+		return database.GetStore().GetFollowers(id, requestingUser, after, count)
+	})
+}
+
+// GetFollowing returns a page of the users followed by the user, most recent follow first
+func GetFollowing(username string, requestingUser int, cursor string, count int) (models.FollowPage, int, error) {
+	return getFollowPage(username, cursor, count, func(id int, after *models.Cursor, count int) ([]models.FollowPreview, error) {
+		return database.GetStore().GetFollowing(id, requestingUser, after, count)
+	})
+}
+
+func getFollowPage(username string, cursor string, count int, getUsers func(id int, after *models.Cursor, count int) ([]models.FollowPreview, error)) (models.FollowPage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.FollowPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+	id, err := database.GetStore().GetUserId(username)
+	if err == sql.ErrNoRows {
+		return models.FollowPage{}, http.StatusNotFound, errors.New("user not found")
+	} else if err != nil {
+		return models.FollowPage{}, http.StatusInternalServerError, err
+	}
+
+	// one more user is fetched to know if there is a next page
+	users, err := getUsers(id, after, count+1)
+	if err != nil {
+		return models.FollowPage{}, http.StatusInternalServerError, err
+	}
+	page := models.FollowPage{Users: users}
+	if len(users) > count {
+		page.Users = users[:count]
+		last := page.Users[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.FollowedAt, Id: last.FollowId})
+	}
+	return page, http.StatusOK, nil
+}
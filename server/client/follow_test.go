@@ -1,10 +1,13 @@
 package client
 
 import (
+	"database/sql"
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/client/pagination"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -67,9 +70,36 @@ func TestFollowUser(t *testing.T) {
 		t.Errorf("Expected error, got nil")
 	}
 
+	//tests for followee blocking the follower
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	code, err = FollowUser(1, 2)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected http.StatusForbidden, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+
+	//tests for follower blocking the followee
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	code, err = FollowUser(1, 2)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected http.StatusForbidden, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+
 	//tests for check follow already exists error
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnError(errors.New("error"))
 	code, err = FollowUser(1, 2)
 	if code != http.StatusInternalServerError {
@@ -82,6 +112,8 @@ func TestFollowUser(t *testing.T) {
 	//tests for follow already exists
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	code, err = FollowUser(1, 2)
 	if code != http.StatusBadRequest {
@@ -94,8 +126,12 @@ func TestFollowUser(t *testing.T) {
 	//tests for follow insert error
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("INSERT INTO follow").WillReturnError(errors.New("error"))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
 	code, err = FollowUser(1, 2)
 	if code != http.StatusInternalServerError {
 		t.Errorf("Expected http.StatusInternalServerError, got %d", code)
@@ -104,12 +140,40 @@ func TestFollowUser(t *testing.T) {
 		t.Errorf("Expected error, got nil")
 	}
 
+	//tests for follow inserted by a concurrent request
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	code, err = FollowUser(1, 2)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected http.StatusBadRequest, got %d", code)
+	}
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+
 	//tests for follow insert success
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("INSERT INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT IGNORE INTO timeline_entry").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_mute").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO notification").WithArgs(2, "new_follower", 1, nil, nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto", "Toto"))
 	code, err = FollowUser(1, 2)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
 	if code != http.StatusOK {
 		t.Errorf("Expected http.StatusOK, got %d", code)
 	}
@@ -146,7 +210,9 @@ func TestUnfollowUser(t *testing.T) {
 	}
 	// test for error when deleting follow
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2).WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
 	code, err = UnfollowUser(1, 2)
 	if code != http.StatusInternalServerError {
 		t.Errorf("Expected http.StatusInternalServerError, got %d", code)
@@ -156,7 +222,10 @@ func TestUnfollowUser(t *testing.T) {
 	}
 	// test for success when deleting follow
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
 	code, err = UnfollowUser(1, 2)
 	if code != http.StatusOK {
 		t.Errorf("Expected http.StatusOK, got %d", code)
@@ -165,3 +234,53 @@ func TestUnfollowUser(t *testing.T) {
 		t.Errorf("Expected nil, got %s", err)
 	}
 }
+
+func TestGetFollowers(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error initializing mock database: %s", err)
+	}
+	pagination.SetSecret("secret")
+
+	_, code, err := GetFollowers("toto", 0, "forged", 10)
+	if code != http.StatusBadRequest || err != pagination.ErrInvalidCursor {
+		t.Errorf("Expected invalid cursor with http.StatusBadRequest, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnError(sql.ErrNoRows)
+	_, code, err = GetFollowers("toto", 0, "", 10)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d, %v", code, err)
+	}
+
+	// one more user than asked means there is a next page
+	followedAt := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM follow JOIN user ON user.id = follow.follower").WithArgs(2, 2, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "id", "username", "display_name", "followed_by_requester", "follows_requester"}).
+			AddRow(7, followedAt, 3, "titi", "Titi", 1, 1).
+			AddRow(6, followedAt, 4, "tata", "Tata", 0, 0))
+	page, code, err := GetFollowers("toto", 2, "", 1)
+	if code != http.StatusOK || err != nil {
+		t.Fatalf("Expected http.StatusOK, got %d, %v", code, err)
+	}
+	if len(page.Users) != 1 || page.Users[0].Username != "titi" || !page.Users[0].IsMutual {
+		t.Errorf("Unexpected followers %+v", page.Users)
+	}
+	next, err := pagination.Decode(page.NextCursor)
+	if err != nil || next.Id != 7 || !next.CreatedAt.Equal(followedAt) {
+		t.Errorf("Expected next cursor to point at follow 7, got %+v, %v", next, err)
+	}
+
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM follow JOIN user ON user.id = follow.followed").WithArgs(0, 0, 1, 11).WillReturnError(errors.New("error"))
+	_, code, err = GetFollowing("toto", 0, "", 0)
+	if code != http.StatusInternalServerError || err == nil {
+		t.Errorf("Expected http.StatusInternalServerError, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -5,49 +5,95 @@ import (
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/client/pagination"
 	"project_truthful/models"
 )
 
+// GetQuestions returns the questions received by the user, the text of those removed by a moderator is hidden
 func GetQuestions(userId int, start int, count int) ([]models.Question, int, error) {
+	questions, code, err := getQuestions(userId, start, count)
+	if err != nil {
+		return nil, code, err
+	}
+	hideRemovedQuestions(questions)
+	return questions, http.StatusOK, nil
+}
+
+// hideRemovedQuestions empties the text of the questions removed by a moderator, their receiver only sees that they were removed
+func hideRemovedQuestions(questions []models.Question) {
+	for i := range questions {
+		if questions[i].RemovedByModeration {
+			questions[i].Text = ""
+		}
+	}
+}
+
+func getQuestions(userId int, start int, count int) ([]models.Question, int, error) {
 	if count < 0 || count > 30 {
 		count = 30
 	}
 	if start < 0 {
 		start = 0
 	}
-	exists, err := database.CheckUserIdExists(userId, database.DB)
+	exists, err := database.GetStore().CheckUserIdExists(userId)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	if !exists {
 		return nil, http.StatusNotFound, errors.New("user not found")
 	}
-	questions, err := database.GetQuestions(userId, start, start+count, database.DB)
+	questions, err := database.GetStore().GetQuestions(userId, start, count)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 	return questions, http.StatusOK, nil
 }
 
-func ModerationGetUserQuestions(requesterId int, username string, start int, count int) ([]models.Question, int, error) {
-	isModerator, err := database.CheckModeratorStatus(requesterId, database.DB)
+// GetQuestionsPage returns the unanswered questions received by the user after the cursor, with the cursor of the next page
+func GetQuestionsPage(userId int, cursor string, count int) (models.QuestionPage, int, error) {
+	after, err := pagination.Decode(cursor)
 	if err != nil {
-		return nil, http.StatusInternalServerError, err
+		return models.QuestionPage{}, http.StatusBadRequest, err
 	}
-	isAdmin, err := database.CheckAdminStatus(requesterId, database.DB)
+	count = pagination.ClampCount(count)
+	exists, err := database.GetStore().CheckUserIdExists(userId)
 	if err != nil {
-		return nil, http.StatusInternalServerError, err
+		return models.QuestionPage{}, http.StatusInternalServerError, err
 	}
-	if !isModerator && !isAdmin {
-		return nil, http.StatusForbidden, errors.New("user is not a moderator or admin")
+	if !exists {
+		return models.QuestionPage{}, http.StatusNotFound, errors.New("user not found")
+	}
+
+	// one more question is fetched to know if there is a next page
+	questions, err := database.GetStore().GetQuestionsPage(userId, after, count+1)
+	if err != nil {
+		return models.QuestionPage{}, http.StatusInternalServerError, err
+	}
+	hideRemovedQuestions(questions)
+	page := models.QuestionPage{Questions: questions}
+	if len(questions) > count {
+		page.Questions = questions[:count]
+		last := page.Questions[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
 	}
+	return page, http.StatusOK, nil
+}
 
-	userId, err := database.GetUserId(username, database.DB)
+// ModerationGetUserQuestions returns the questions received by the user, with the text of the removed ones.
+// The requester must be allowed to view them
+func ModerationGetUserQuestions(audit models.AuditContext, username string, start int, count int) ([]models.Question, int, error) {
+	userId, err := database.GetStore().GetUserId(username)
 	if err == sql.ErrNoRows {
 		return nil, http.StatusNotFound, errors.New("user not found")
 	} else if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 
-	return GetQuestions(userId, start, start+count)
+	// the questions are only shown once the view is in the audit log
+	err = addAuditEntry(database.GetStore(), audit, models.AuditQuestionsView, models.AuditTargetUser, userId, nil, nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return getQuestions(userId, start, count)
 }
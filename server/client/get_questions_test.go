@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/client/pagination"
 	"project_truthful/helpunittesting"
+	"project_truthful/models"
 	"testing"
 	"time"
 
@@ -48,7 +50,7 @@ func TestGetQuestionsFail(t *testing.T) {
 
 	// test with existing user id but error while getting questions + too high count
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnError(errors.New("error while getting questions"))
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnError(errors.New("error while getting questions"))
 	_, status, err = GetQuestions(1, 0, 50)
 	if err == nil {
 		t.Error("Expected error, got nil")
@@ -69,7 +71,7 @@ func TestGetQuestionsNoQuestions(t *testing.T) {
 	database.DB = db
 	// test with existing user id and nil questions
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "text", "created_at", "updated_at"}))
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "text", "created_at", "updated_at"}))
 	questions, status, err := GetQuestions(1, 0, 30)
 	if err != nil {
 		t.Error("Expected nil, got", err)
@@ -98,12 +100,12 @@ func TestGetQuestionsNoAnswers(t *testing.T) {
 	questions := helpunittesting.GenerateTestQuestions(30, 1, curTime)
 
 	// generate rows
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"})
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"})
 	for _, question := range questions {
-		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, question.RemovedByModeration)
 	}
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(rows)
 	// expects all the queries for getting answers
 	for i, question := range questions {
 		mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(question.Id).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
@@ -163,12 +165,12 @@ func TestGetQuestionsWithAnswers(t *testing.T) {
 	questions := helpunittesting.GenerateTestQuestions(30, 1, curTime)
 
 	// generate rows
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"})
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"})
 	for _, question := range questions {
-		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, question.RemovedByModeration)
 	}
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(rows)
 	// expects all the queries for getting answers. Every query will return no answers except the 25th one
 	for i, question := range questions {
 		if i == 25 {
@@ -222,38 +224,37 @@ func TestGetQuestionsWithAnswers(t *testing.T) {
 	}
 }
 
-func TestModerationErrorMod(t *testing.T) {
+func TestModerationUserNotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Error while creating mock: %s", err.Error())
 	}
 	database.DB = db
 
-	// test with error while checking moderator status
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking moderator status"))
-	_, status, err := ModerationGetUserQuestions(1, "username", 0, 30)
+	// test with user not found
+	mock.ExpectQuery("SELECT").WithArgs("username").WillReturnError(sql.ErrNoRows)
+	_, status, err := ModerationGetUserQuestions(models.AuditContext{ModeratorId: 2}, "username", 0, 30)
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
-	if status != http.StatusInternalServerError {
-		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, status)
+	if status != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, status)
 	}
 }
 
-func TestModerationErrorAdmin(t *testing.T) {
+func TestModerationErrorGetUserId(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Error while creating mock: %s", err.Error())
 	}
 	database.DB = db
 
-	// test with error while checking admin status
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnError(errors.New("error while checking admin status"))
-	_, status, err := ModerationGetUserQuestions(1, "username", 0, 30)
+	// test with error while getting user id
+	mock.ExpectQuery("SELECT").WithArgs("username").WillReturnError(errors.New("error while getting user id"))
+	_, status, err := ModerationGetUserQuestions(models.AuditContext{ModeratorId: 2}, "username", 0, 30)
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
@@ -265,95 +266,77 @@ func TestModerationErrorAdmin(t *testing.T) {
 	}
 }
 
-func TestModerationNotMod(t *testing.T) {
+func TestModerationQuestionSuccess(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Error while creating mock: %s", err.Error())
 	}
 	database.DB = db
 
-	// test with user not being a moderator
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(0))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(0))
-	_, status, err := ModerationGetUserQuestions(1, "username", 0, 30)
-	if err == nil {
-		t.Error("Expected error, got nil")
+	// test with success
+	mock.ExpectQuery("SELECT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(2, "questions.view", "user", 1, nil, "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT").WithArgs(1, 30, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}))
+	_, status, err := ModerationGetUserQuestions(models.AuditContext{ModeratorId: 2}, "username", 0, 30)
+	if err != nil {
+		t.Error("Expected nil, got", err)
 	}
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
-	if status != http.StatusForbidden {
-		t.Errorf("Expected status %d, got %d", http.StatusForbidden, status)
+	if status != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, status)
 	}
 }
 
-func TestModerationUserNotFound(t *testing.T) {
+func TestGetQuestionsPage(t *testing.T) {
 	db, mock, err := sqlmock.New()
+	database.DB = db
 	if err != nil {
 		t.Fatalf("Error while creating mock: %s", err.Error())
 	}
-	database.DB = db
+	pagination.SetSecret("secret")
 
-	// test with user not found
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs("username").WillReturnError(sql.ErrNoRows)
-	_, status, err := ModerationGetUserQuestions(1, "username", 0, 30)
-	if err == nil {
-		t.Error("Expected error, got nil")
-	}
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+	_, status, err := GetQuestionsPage(1, "forged", 10)
+	if err != pagination.ErrInvalidCursor || status != http.StatusBadRequest {
+		t.Errorf("Expected invalid cursor with status 400, got %v and %d", err, status)
 	}
-	if status != http.StatusNotFound {
-		t.Errorf("Expected status %d, got %d", http.StatusNotFound, status)
-	}
-}
 
-func TestModerationErrorGetUserId(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("Error while creating mock: %s", err.Error())
+	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	_, status, err = GetQuestionsPage(1, "", 10)
+	if err == nil || status != http.StatusNotFound {
+		t.Errorf("Expected user not found with status 404, got %v and %d", err, status)
 	}
-	database.DB = db
 
-	// test with error while getting user id
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs("username").WillReturnError(errors.New("error while getting user id"))
-	_, status, err := ModerationGetUserQuestions(1, "username", 0, 30)
-	if err == nil {
-		t.Error("Expected error, got nil")
+	// one more question than asked means there is a next page
+	creationTime := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT id, text, author_id").WithArgs(1, 3).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+		AddRow(5, "question", nil, true, 1, creationTime, false).
+		AddRow(4, "question", nil, true, 1, creationTime, false).
+		AddRow(3, "question", nil, true, 1, creationTime, false))
+	page, status, err := GetQuestionsPage(1, "", 2)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v and %d", err, status)
 	}
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+	if len(page.Questions) != 2 || page.Questions[1].Id != 4 {
+		t.Errorf("Expected questions 5 and 4, got %+v", page.Questions)
 	}
-	if status != http.StatusInternalServerError {
-		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, status)
+	next, err := pagination.Decode(page.NextCursor)
+	if err != nil || next.Id != 4 || !next.CreatedAt.Equal(creationTime) {
+		t.Errorf("Expected next cursor to point at question 4, got %+v, %v", next, err)
 	}
-}
 
-func TestModerationQuestionSuccess(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("Error while creating mock: %s", err.Error())
-	}
-	database.DB = db
-
-	// test with success
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_moderator"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 30).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}))
-	_, status, err := ModerationGetUserQuestions(1, "username", 0, 30)
-	if err != nil {
-		t.Error("Expected nil, got", err)
+	// the last page has no next cursor
+	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT id, text, author_id").WithArgs(1, creationTime, creationTime, 4, 3).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+		AddRow(3, "question", nil, true, 1, creationTime, false))
+	page, status, err = GetQuestionsPage(1, page.NextCursor, 2)
+	if err != nil || status != http.StatusOK || len(page.Questions) != 1 || page.NextCursor != "" {
+		t.Errorf("Expected the last page, got %+v, %v and %d", page, err, status)
 	}
-	if mock.ExpectationsWereMet() != nil {
+	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
-	if status != http.StatusOK {
-		t.Errorf("Expected status %d, got %d", http.StatusOK, status)
-	}
 }
@@ -0,0 +1,35 @@
+// Package iphash turns IP addresses into salted hashes, so that askers can be recognized without exposing their address
+package iphash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+)
+
+var secret []byte
+
+// Init reads the secret salting the hashes from the IP_HASH_SECRET env variable.
+// It is required, the blocked askers would not be recognized after a restart with a random secret
+func Init() error {
+	s := os.Getenv("IP_HASH_SECRET")
+	if s == "" {
+		return errors.New("IP_HASH_SECRET must be set")
+	}
+	secret = []byte(s)
+	return nil
+}
+
+// SetSecret salts the hashes with the given secret instead of the IP_HASH_SECRET env variable
+func SetSecret(s string) {
+	secret = []byte(s)
+}
+
+// Hash returns the salted hash of the IP address, the address can't be found back from it
+func Hash(ipAddress string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ipAddress))
+	return hex.EncodeToString(mac.Sum(nil))
+}
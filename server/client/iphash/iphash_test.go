@@ -0,0 +1,36 @@
+package iphash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	SetSecret("secret")
+	hash := Hash("127.0.0.1")
+	if hash != Hash("127.0.0.1") {
+		t.Errorf("Expected the hash of an address to be stable")
+	}
+	if hash == Hash("127.0.0.2") {
+		t.Errorf("Expected different addresses to have different hashes")
+	}
+	if len(hash) != 64 || strings.Contains(hash, "127") {
+		t.Errorf("Expected a hex sha256 hiding the address, got %s", hash)
+	}
+
+	SetSecret("other secret")
+	if hash == Hash("127.0.0.1") {
+		t.Errorf("Expected the hash to depend on the secret")
+	}
+}
+
+func TestInit(t *testing.T) {
+	t.Setenv("IP_HASH_SECRET", "")
+	if Init() == nil {
+		t.Errorf("Expected an error without IP_HASH_SECRET")
+	}
+	t.Setenv("IP_HASH_SECRET", "secret")
+	if Init() != nil || string(secret) != "secret" {
+		t.Errorf("Expected the secret to be read from IP_HASH_SECRET, got %s", secret)
+	}
+}
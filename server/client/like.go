@@ -4,10 +4,11 @@ import (
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/notifications"
 )
 
 func LikeAnswer(userId int, postId int) (int, error) {
-	userExists, err := database.CheckUserIdExists(userId, database.DB)
+	userExists, err := database.GetStore().CheckUserIdExists(userId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -15,7 +16,7 @@ func LikeAnswer(userId int, postId int) (int, error) {
 		return http.StatusNotFound, errors.New("user not found")
 	}
 
-	postExists, err := database.CheckAnswerIdExists(postId, database.DB)
+	postExists, err := database.GetStore().CheckAnswerIdExists(postId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -23,7 +24,16 @@ func LikeAnswer(userId int, postId int) (int, error) {
 		return http.StatusNotFound, errors.New("post not found")
 	}
 
-	likeExists, err := database.CheckLikeExists(userId, postId, database.DB)
+	authorId, err := database.GetStore().GetAnswerAuthorId(postId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	code, err := checkNotBlocked(userId, authorId)
+	if err != nil {
+		return code, err
+	}
+
+	likeExists, err := database.GetStore().CheckLikeExists(userId, postId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -31,16 +41,17 @@ func LikeAnswer(userId int, postId int) (int, error) {
 		return http.StatusBadRequest, errors.New("user already likes this post")
 	}
 
-	err = database.AddLike(userId, postId, database.DB)
+	err = database.GetStore().AddLike(userId, postId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	notifications.NotifyAnswerLiked(postId, userId)
 	return 0, nil
 }
 
 func UnlikeAnswer(userId int, postId int) (int, error) {
 	// no need to check whether user or post exists, since we can just delete the like
-	likeExists, err := database.CheckLikeExists(userId, postId, database.DB)
+	likeExists, err := database.GetStore().CheckLikeExists(userId, postId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -48,7 +59,7 @@ func UnlikeAnswer(userId int, postId int) (int, error) {
 		return http.StatusBadRequest, errors.New("user does not like this post")
 	}
 
-	err = database.RemoveLike(userId, postId, database.DB)
+	err = database.GetStore().RemoveLike(userId, postId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
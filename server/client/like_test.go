@@ -2,6 +2,7 @@ package client
 
 import (
 	"errors"
+	"net/http"
 	"project_truthful/client/database"
 	"testing"
 
@@ -70,9 +71,24 @@ func TestLike(t *testing.T) {
 		t.Errorf("Error should not be nil")
 	}
 
+	// Test that the like function returns an error when the author of the answer blocked the user
+	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	code, err := LikeAnswer(1, 2)
+	if mock.ExpectationsWereMet() != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+	if code != http.StatusForbidden {
+		t.Errorf("Expected http.StatusForbidden, got %d", code)
+	}
+
 	// Test that the like function returns an error when the user already likes the post
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	_, err = LikeAnswer(1, 2)
 	if mock.ExpectationsWereMet() != nil {
@@ -85,6 +101,8 @@ func TestLike(t *testing.T) {
 	// Test with an error when checking if like exists
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1, 2).WillReturnError(errors.New("error"))
 	_, err = LikeAnswer(1, 2)
 	if mock.ExpectationsWereMet() != nil {
@@ -97,6 +115,8 @@ func TestLike(t *testing.T) {
 	// Test with an error when inserting like
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(0))
 	mock.ExpectExec("INSERT INTO").WithArgs(1, 2).WillReturnError(errors.New("error"))
 	_, err = LikeAnswer(1, 2)
@@ -110,8 +130,14 @@ func TestLike(t *testing.T) {
 	// Test that the like function returns no error when the user likes the post
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(0))
 	mock.ExpectExec("INSERT INTO answer_like").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_mute").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO notification").WithArgs(5, "answer_liked", 1, nil, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto", "Toto"))
 	_, err = LikeAnswer(1, 2)
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -3,79 +3,156 @@ package client
 import (
 	"database/sql"
 	"errors"
+	"log"
 	"net/http"
 	"os"
 	"project_truthful/client/database"
 	"project_truthful/client/token"
 	"project_truthful/models"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-func Login(infos models.LoginInfos) (string, int, error) {
-	id, err := database.GetUserId(infos.Username, database.DB)
-	if err != nil && err == sql.ErrNoRows {
-		return "", http.StatusNotFound, errors.New("user not found")
-	} else if err != nil {
-		return "", http.StatusInternalServerError, err
+var errInvalidCredentials = errors.New("invalid login credentials. Please try again")
+
+// getDummyPasswordHash returns a hash no password matches, checked in place of the hash of an unknown user
+var getDummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not the password of anyone"), passwordHashCost)
+	if err != nil {
+		log.Printf("Error while hashing the dummy password: %s\n", err.Error())
 	}
-	if id == 0 {
-		return "", http.StatusBadRequest, errors.New("username does not exist")
+	return string(hash)
+})
+
+// Login checks the password of the user and creates a session, or a challenge when the user enabled a second factor.
+// An unknown username and a wrong password get the same answer, and are throttled the same
+func Login(infos models.LoginInfos, ipAddress string, userAgent string) (models.AuthTokens, int, error) {
+	code, err := CheckAddressNotBanned(ipAddress, "")
+	if err != nil {
+		return models.AuthTokens{}, code, err
 	}
-	hashedPassword, err := database.GetHashedPassword(id, database.DB)
+	attemptId, code, err := reserveLoginAttempt(infos.Username, ipAddress, userAgent, models.LoginMethodPassword)
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		return models.AuthTokens{}, code, err
+	}
+
+	id, err := database.GetStore().GetUserId(infos.Username)
+	if err != nil && err != sql.ErrNoRows {
+		cancelLoginAttempt(attemptId)
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	hashedPassword := ""
+	if id != 0 {
+		hashedPassword, err = database.GetStore().GetHashedPassword(id)
+		if err != nil {
+			cancelLoginAttempt(attemptId)
+			return models.AuthTokens{}, http.StatusInternalServerError, err
+		}
 	}
 
 	if os.Getenv("IS_TEST") != "true" {
+		// an unknown username takes as long to check as a wrong password
+		if id == 0 {
+			hashedPassword = getDummyPasswordHash()
+		}
 		err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(infos.Password))
-		if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-			return "", http.StatusBadRequest, errors.New("invalid login credentials. Please try again")
-		} else if err != nil {
-			return "", http.StatusInternalServerError, err
+	}
+	if id == 0 || err != nil {
+		// the attempt was recorded as failed already, only the account is added
+		if id != 0 {
+			err = finishLoginAttempt(attemptId, id, false)
+			if err != nil {
+				return models.AuthTokens{}, http.StatusInternalServerError, err
+			}
 		}
+		return models.AuthTokens{}, http.StatusBadRequest, errInvalidCredentials
+	}
+
+	code, err = CheckUserNotBanned(id)
+	if err != nil {
+		recordFailedLogin(attemptId, infos.Username, id, ipAddress, userAgent, models.LoginMethodPassword)
+		return models.AuthTokens{}, code, err
 	}
 
-	accessToken, err := token.GenerateJWT(id)
+	tokens, code, err := createSessionOrChallenge(id, ipAddress, userAgent)
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		cancelLoginAttempt(attemptId)
+		return models.AuthTokens{}, code, err
 	}
-	return accessToken, http.StatusOK, nil
+	// the login is recorded, and the failures forgotten, once the second factor is passed too
+	if tokens.ChallengeToken != "" {
+		cancelLoginAttempt(attemptId)
+	} else {
+		recordSuccessfulLogin(attemptId, infos.Username, id, ipAddress, userAgent, models.LoginMethodPassword)
+	}
+	return tokens, code, nil
 }
 
-func GoogleLogin(provider string, requestToken string) (string, int, error) {
+func GoogleLogin(provider string, requestToken string, ipAddress string, userAgent string) (models.AuthTokens, int, error) {
 	googleInfos, err := token.VerifyGoogleToken(requestToken)
 	if err != nil {
-		return "", http.StatusBadRequest, err
+		return models.AuthTokens{}, http.StatusBadRequest, err
+	}
+	code, err := CheckAddressNotBanned(ipAddress, "")
+	if err != nil {
+		return models.AuthTokens{}, code, err
 	}
 
 	// gets provider id for google
-	providerId, err := database.GetOAuthProvider(provider, database.DB)
+	providerId, err := database.GetStore().GetOAuthProvider(provider)
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		return models.AuthTokens{}, http.StatusInternalServerError, err
 	}
 
 	// checks if the user exists in the database
-	userId, err := database.GetUserIdBySubject(providerId, googleInfos.Subject, database.DB)
+	userId, err := database.GetStore().GetUserIdBySubject(providerId, googleInfos.Subject)
 	// flag to create a new user and a new entry in oauth_login
 	if err != nil && err == sql.ErrNoRows {
-		var code int
+		// email domain bans stop the new accounts only
+		code, err = CheckAddressNotBanned("", googleInfos.Email)
+		if err != nil {
+			return models.AuthTokens{}, code, err
+		}
 		userId, code, err = RegisterOauth(googleInfos.Name, googleInfos.Email, "2000-01-01") // TODO: add birthdate
 		if err != nil {
-			return "", code, err
+			return models.AuthTokens{}, code, err
 		}
 
 		// add to oauth_login table
-		err = database.InsertOauthLogin(providerId, googleInfos.Subject, userId, database.DB)
+		err = database.GetStore().InsertOauthLogin(providerId, googleInfos.Subject, userId)
 		if err != nil {
-			return "", http.StatusInternalServerError, err
+			return models.AuthTokens{}, http.StatusInternalServerError, err
+		}
+
+		// google already verified the address of most accounts
+		if googleInfos.EmailVerified {
+			err = database.GetStore().SetEmailVerified(int(userId), googleInfos.Email)
+		} else {
+			_, err = sendEmailVerification(int(userId), googleInfos.Email)
+		}
+		if err != nil {
+			log.Printf("Error while verifying the email of user %d: %s\n", userId, err.Error())
 		}
 	} else if err != nil {
-		return "", http.StatusInternalServerError, err
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+
+	username, _, err := database.GetStore().GetUsernameAndDisplayName(int(userId))
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
 	}
-	userToken, err := token.GenerateJWT(int(userId))
+	code, err = CheckUserNotBanned(int(userId))
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		recordFailedLogin(0, username, int(userId), ipAddress, userAgent, models.LoginMethodGoogle)
+		return models.AuthTokens{}, code, err
+	}
+	tokens, code, err := createSessionOrChallenge(int(userId), ipAddress, userAgent)
+	if err != nil {
+		return models.AuthTokens{}, code, err
+	}
+	if tokens.ChallengeToken == "" {
+		recordSuccessfulLogin(0, username, int(userId), ipAddress, userAgent, models.LoginMethodGoogle)
 	}
-	return userToken, http.StatusOK, nil
+	return tokens, code, nil
 }
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/mailer"
+	"project_truthful/models"
+	"project_truthful/notifications"
+	"time"
+)
+
+const newLoginEmailSubject = "New login to your Truthful account"
+const newLoginEmailBody = "Your Truthful account was logged in to from a new device.\n\n" +
+	"IP address: %s\nBrowser: %s\nDate: %s\n\n" +
+	"If it was you, there is nothing to do. Otherwise, change your password and log out your sessions from the settings of your account."
+
+// recordSuccessfulLogin adds the login to the history of the user, and alerts the user when it comes from
+// an IP address and user agent they never logged in from. attemptId is the reserved attempt of the login, 0 when there is none.
+// The user is logged in already, the errors are only logged
+func recordSuccessfulLogin(attemptId int, username string, userId int, ipAddress string, userAgent string, method string) {
+	// the user agents are stored truncated, the device is compared the same
+	userAgent = truncateUserAgent(userAgent)
+	isNewDevice, err := database.GetStore().CheckNewLoginDevice(userId, ipAddress, userAgent)
+	if err != nil {
+		log.Printf("Error while checking the device of the login of user %d: %s\n", userId, err.Error())
+	}
+	if attemptId != 0 {
+		err = finishLoginAttempt(attemptId, userId, true)
+	} else {
+		_, err = recordLoginAttempt(username, userId, ipAddress, userAgent, method, true)
+	}
+	if err != nil {
+		log.Printf("Error while recording the login of user %d: %s\n", userId, err.Error())
+	}
+	if isNewDevice {
+		alertNewLoginDevice(userId, ipAddress, userAgent)
+	}
+}
+
+// recordFailedLogin adds a login refused after the user was identified to their history, the refusal doesn't depend on it.
+// attemptId is the reserved attempt of the login, 0 when there is none
+func recordFailedLogin(attemptId int, username string, userId int, ipAddress string, userAgent string, method string) {
+	var err error
+	if attemptId != 0 {
+		err = finishLoginAttempt(attemptId, userId, false)
+	} else {
+		_, err = recordLoginAttempt(username, userId, ipAddress, userAgent, method, false)
+	}
+	if err != nil {
+		log.Printf("Error while recording the failed login of user %d: %s\n", userId, err.Error())
+	}
+}
+
+// alertNewLoginDevice notifies the user of a login from a new device, and emails them when their address is verified.
+// The email is sent in the background, the login doesn't wait for the mail server
+func alertNewLoginDevice(userId int, ipAddress string, userAgent string) {
+	log.Printf("User %d logged in from a new device, ip %s\n", userId, ipAddress)
+	notifications.NotifyNewLogin(userId)
+
+	loggedInAt := time.Now()
+	inBackground(func() {
+		sendNewLoginEmail(userId, ipAddress, userAgent, loggedInAt)
+	})
+}
+
+// sendNewLoginEmail emails the user about a login from a new device when their address is verified.
+// The user is logged in already, the errors are only logged
+func sendNewLoginEmail(userId int, ipAddress string, userAgent string, loggedInAt time.Time) {
+	email, verified, err := database.GetStore().GetUserEmail(userId)
+	if err != nil {
+		log.Printf("Error while getting the email of user %d to alert them of a new login: %s\n", userId, err.Error())
+		return
+	}
+	if !verified {
+		return
+	}
+	if userAgent == "" {
+		userAgent = "unknown"
+	}
+	body := fmt.Sprintf(newLoginEmailBody, ipAddress, userAgent, loggedInAt.UTC().Format(time.RFC1123))
+	err = mailer.Send(mailer.Message{To: email, Subject: newLoginEmailSubject, Body: body})
+	if err != nil {
+		log.Printf("Error while sending new login email to user %d: %s\n", userId, err.Error())
+	}
+}
+
+func getLoginHistoryPage(userId int, cursor string, count int) (models.LoginHistoryPage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.LoginHistoryPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more login is fetched to know if there is a next page
+	logins, err := database.GetStore().GetLoginHistory(userId, after, count+1)
+	if err != nil {
+		return models.LoginHistoryPage{}, http.StatusInternalServerError, err
+	}
+	page := models.LoginHistoryPage{Logins: logins}
+	if len(logins) > count {
+		page.Logins = logins[:count]
+		last := page.Logins[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
+
+// GetLoginHistory returns the successful and failed logins of the user, newest first
+func GetLoginHistory(userId int, cursor string, count int) (models.LoginHistoryPage, int, error) {
+	return getLoginHistoryPage(userId, cursor, count)
+}
+
+// GetUserLoginHistory returns the login history of a user for the moderators, the view is audited
+func GetUserLoginHistory(audit models.AuditContext, userId int, cursor string, count int) (models.LoginHistoryPage, int, error) {
+	exists, err := database.GetStore().CheckUserIdExists(userId)
+	if err != nil {
+		return models.LoginHistoryPage{}, http.StatusInternalServerError, err
+	}
+	if !exists {
+		return models.LoginHistoryPage{}, http.StatusNotFound, errors.New("user not found")
+	}
+	page, code, err := getLoginHistoryPage(userId, cursor, count)
+	if err != nil {
+		return models.LoginHistoryPage{}, code, err
+	}
+	err = addAuditEntry(database.GetStore(), audit, models.AuditLoginHistoryView, models.AuditTargetUser, userId, nil, nil)
+	if err != nil {
+		return models.LoginHistoryPage{}, http.StatusInternalServerError, err
+	}
+	return page, http.StatusOK, nil
+}
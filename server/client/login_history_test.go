@@ -0,0 +1,136 @@
+package client
+
+import (
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/mailer"
+	"project_truthful/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectLoginDevice expects the logins of the user from the device to be counted
+func expectLoginDevice(mock sqlmock.Sqlmock, userId int, ipAddress string, userAgent string, logins int, fromDevice int) {
+	mock.ExpectQuery("FROM login_attempt WHERE user_id = (.+) AND success = 1").WithArgs(ipAddress, userAgent, userId).
+		WillReturnRows(sqlmock.NewRows([]string{"logins", "from_device"}).AddRow(logins, fromDevice))
+}
+
+func TestRecordSuccessfulLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	recorder := &recordingMailer{}
+	mailer.SetMailer(recorder)
+	runBackground := holdBackground(t)
+
+	// a known device doesn't alert the user
+	expectLoginDevice(mock, 2, "10.0.0.1", "agent", 3, 1)
+	expectFinishedLogin(mock, 2, true)
+	recordSuccessfulLogin(testLoginAttemptId, "Toto", 2, "10.0.0.1", "agent", models.LoginMethodPassword)
+	if len(recorder.messages) != 0 {
+		t.Errorf("Expected no email, got %+v", recorder.messages)
+	}
+
+	// a new device is notified, and emailed to the verified address after the login is answered
+	expectLoginDevice(mock, 2, "10.0.0.2", "other agent", 3, 0)
+	expectLoginAttempt(mock, "toto", 2, "10.0.0.2", "other agent", models.LoginMethodGoogle, true)
+	mock.ExpectExec("INSERT INTO notification").WithArgs(2, "new_login", nil, nil, nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	recordSuccessfulLogin(0, "toto", 2, "10.0.0.2", "other agent", models.LoginMethodGoogle)
+	if err := mock.ExpectationsWereMet(); err != nil || len(recorder.messages) != 0 {
+		t.Errorf("Expected the email to wait for the login to be answered, got %+v and %v", recorder.messages, err)
+	}
+	expectUserEmail(mock, 2, "toto@toto.fr", true)
+	runBackground()
+	if len(recorder.messages) != 1 || recorder.messages[0].To != "toto@toto.fr" || !strings.Contains(recorder.messages[0].Body, "10.0.0.2") ||
+		!strings.Contains(recorder.messages[0].Body, "other agent") {
+		t.Errorf("Expected the new device to be emailed, got %+v", recorder.messages)
+	}
+
+	// the unverified addresses are not emailed
+	expectLoginDevice(mock, 2, "10.0.0.3", "agent", 3, 0)
+	expectLoginAttempt(mock, "toto", 2, "10.0.0.3", "agent", models.LoginMethodPassword, true)
+	mock.ExpectExec("INSERT INTO notification").WithArgs(2, "new_login", nil, nil, nil).WillReturnResult(sqlmock.NewResult(2, 1))
+	expectUserEmail(mock, 2, "toto@toto.fr", false)
+	recordSuccessfulLogin(0, "toto", 2, "10.0.0.3", "agent", models.LoginMethodPassword)
+	runBackground()
+	if len(recorder.messages) != 1 {
+		t.Errorf("Expected no email to the unverified address, got %+v", recorder.messages)
+	}
+
+	// the first login of an account comes from no new device
+	expectLoginDevice(mock, 3, "10.0.0.1", "agent", 0, 0)
+	expectLoginAttempt(mock, "titi", 3, "10.0.0.1", "agent", models.LoginMethodPassword, true)
+	recordSuccessfulLogin(0, "titi", 3, "10.0.0.1", "agent", models.LoginMethodPassword)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetLoginHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, code, err := GetLoginHistory(2, "invalid cursor", 10)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected an invalid cursor to be refused, got %d, %v", code, err)
+	}
+
+	columns := []string{"id", "ip_address", "user_agent", "method", "success", "created_at"}
+	mock.ExpectQuery("FROM login_attempt WHERE login_attempt.user_id = (.+) ORDER BY login_attempt.created_at DESC").WithArgs(2, 3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, "10.0.0.1", "agent", "password", true, now).
+			AddRow(4, "10.0.0.1", "agent", "password", false, now.Add(-time.Minute)).AddRow(3, "10.0.0.2", "", "google", true, now.Add(-time.Hour)))
+	page, code, err := GetLoginHistory(2, "", 2)
+	if code != http.StatusOK || err != nil || len(page.Logins) != 2 || page.Logins[1].Success || page.NextCursor == "" {
+		t.Fatalf("Expected a first page of 2 logins, got %d, %v, %+v", code, err, page)
+	}
+
+	mock.ExpectQuery("FROM login_attempt WHERE login_attempt.user_id = (.+) AND \\(login_attempt.created_at <").WithArgs(2, sqlmock.AnyArg(), sqlmock.AnyArg(), 4, 3).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, "10.0.0.2", "", "google", true, now.Add(-time.Hour)))
+	page, code, err = GetLoginHistory(2, page.NextCursor, 2)
+	if code != http.StatusOK || err != nil || len(page.Logins) != 1 || page.Logins[0].Method != models.LoginMethodGoogle || page.NextCursor != "" {
+		t.Errorf("Expected the last login, got %d, %v, %+v", code, err, page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetUserLoginHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	audit := models.AuditContext{ModeratorId: 3}
+
+	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(0))
+	_, code, err := GetUserLoginHistory(audit, 2, "", 10)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected 404, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
+	mock.ExpectQuery("FROM login_attempt WHERE login_attempt.user_id = ").WithArgs(2, pagination.DefaultCount+1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ip_address", "user_agent", "method", "success", "created_at"}).AddRow(1, "10.0.0.1", "agent", "password", true, time.Now()))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(3, "login_history.view", "user", 2, nil, "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	page, code, err := GetUserLoginHistory(audit, 2, "", pagination.DefaultCount)
+	if code != http.StatusOK || err != nil || len(page.Logins) != 1 {
+		t.Errorf("Expected the login history, got %d, %v, %+v", code, err, page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"os"
 	"project_truthful/client/database"
+	"project_truthful/client/token"
 	"project_truthful/models"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
@@ -24,25 +26,43 @@ func TestLogin(t *testing.T) {
 	}
 	defer database.DB.Close()
 
-	// tests that the username does not exist
+	// tests that the username does not exist, the answer is the same as for a wrong password
+	expectAddressBans(mock, models.AddressBanIp)
+	expectReservedLogin(mock, "username", "127.0.0.1", "test-agent", models.LoginMethodPassword, 0, 0)
 	mock.ExpectQuery("SELECT id FROM user").WithArgs("username").WillReturnError(sql.ErrNoRows)
-	_, _, err = Login(models.LoginInfos{Username: "username", Password: "password"})
+	_, code, err := Login(models.LoginInfos{Username: "username", Password: "password"}, "127.0.0.1", "test-agent")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+	if code != http.StatusBadRequest || err != errInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %d, %v", code, err)
 	}
 
 	// tests that the password is wrong
+	expectAddressBans(mock, models.AddressBanIp)
+	expectReservedLogin(mock, "username", "127.0.0.1", "test-agent", models.LoginMethodPassword, 0, 0)
 	mock.ExpectQuery("SELECT id FROM user").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT password FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("toto"))
-	_, _, err = Login(models.LoginInfos{Username: "username", Password: "password"})
+	expectFinishedLogin(mock, 1, false)
+	_, code, err = Login(models.LoginInfos{Username: "username", Password: "password"}, "127.0.0.1", "test-agent")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+	if code != http.StatusBadRequest || err != errInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %d, %v", code, err)
+	}
+
+	// tests that the login is refused while the username waits after its failures
+	expectAddressBans(mock, models.AddressBanIp)
+	expectReservedLogin(mock, "username", "127.0.0.1", "test-agent", models.LoginMethodPassword, usernameLoginThrottle.freeFailures, 0)
+	expectCancelledLogin(mock)
+	_, code, err = Login(models.LoginInfos{Username: "Username", Password: "password"}, "127.0.0.1", "test-agent")
+	if mock.ExpectationsWereMet() != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+	var throttledErr *LoginThrottledError
+	if code != http.StatusTooManyRequests || !errors.As(err, &throttledErr) {
+		t.Errorf("Expected the login to be throttled, got %d, %v", code, err)
 	}
 
 	// tests that the login is successful
@@ -50,11 +70,17 @@ func TestLogin(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error while encrypting password: %s", err.Error())
 	}
+	expectAddressBans(mock, models.AddressBanIp)
+	expectReservedLogin(mock, "username", "127.0.0.1", "test-agent", models.LoginMethodPassword, 0, 0)
 	mock.ExpectQuery("SELECT id FROM user").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(44))
 	mock.ExpectQuery("SELECT password FROM user").WithArgs(44).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedPassword))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(44).WillReturnError(sql.ErrNoRows)
+	expectNoTotpSecret(mock, 44)
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 44, token.HashRefreshToken("test"), "127.0.0.1", "test-agent", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectReservedSuccessfulLogin(mock, 44, "127.0.0.1", "test-agent")
 
 	os.Setenv("IS_TEST", "true")
-	_, _, err = Login(models.LoginInfos{Username: "username", Password: "password"})
+	_, _, err = Login(models.LoginInfos{Username: "username", Password: "password"}, "127.0.0.1", "test-agent")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
 	}
@@ -65,9 +91,42 @@ func TestLogin(t *testing.T) {
 	os.Setenv("IS_TEST", "false")
 }
 
+func TestLoginBannedUser(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+	expectAddressBans(mock, models.AddressBanIp)
+	expectReservedLogin(mock, "username", "127.0.0.1", "test-agent", models.LoginMethodPassword, 0, 0)
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(44))
+	mock.ExpectQuery("SELECT password FROM user").WithArgs(44).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("password"))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(44).WillReturnRows(sqlmock.NewRows([]string{"id", "reason", "created_at", "expires_at"}).AddRow(3, "spam", time.Now(), nil))
+	expectFinishedLogin(mock, 44, false)
+	_, code, err := Login(models.LoginInfos{Username: "username", Password: "password"}, "127.0.0.1", "test-agent")
+	if mock.ExpectationsWereMet() != nil {
+		t.Errorf("Error while checking expectations")
+	}
+	if code != http.StatusForbidden {
+		t.Errorf("Expected code %d, got %d", http.StatusForbidden, code)
+	}
+	var bannedErr *UserBannedError
+	if !errors.As(err, &bannedErr) {
+		t.Fatalf("Expected a UserBannedError, got %v", err)
+	}
+	if bannedErr.Ban.Id != 3 || bannedErr.Ban.Reason != "spam" || !bannedErr.Ban.IsPermanent {
+		t.Errorf("Unexpected ban %+v", bannedErr.Ban)
+	}
+}
+
 func TestGoogleLoginVerifyTokenFail(t *testing.T) {
 	userToken := "toto123"
-	_, _, err := GoogleLogin("google", userToken)
+	_, _, err := GoogleLogin("google", userToken, "127.0.0.1", "test-agent")
 	if err == nil {
 		t.Errorf("Error should not be nil")
 	}
@@ -85,8 +144,9 @@ func TestGoogleLoginGetOAuthProviderFail(t *testing.T) {
 	}
 	defer database.DB.Close()
 
+	expectAddressBans(mock, models.AddressBanIp)
 	mock.ExpectQuery("SELECT id FROM oauth_provider").WithArgs("google").WillReturnError(sql.ErrNoRows)
-	_, _, err = GoogleLogin("google", userToken)
+	_, _, err = GoogleLogin("google", userToken, "127.0.0.1", "test-agent")
 	os.Setenv("IS_TEST", "false")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -108,9 +168,10 @@ func TestGoogleLoginGetUserIdBySubjectFail(t *testing.T) {
 	}
 	defer database.DB.Close()
 
+	expectAddressBans(mock, models.AddressBanIp)
 	mock.ExpectQuery("SELECT id FROM oauth_provider").WithArgs("google").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT user_id FROM oauth_login").WithArgs(1, "123456").WillReturnError(sql.ErrNoRows)
-	_, _, err = GoogleLogin("google", userToken)
+	_, _, err = GoogleLogin("google", userToken, "127.0.0.1", "test-agent")
 	os.Setenv("IS_TEST", "false")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -132,10 +193,16 @@ func TestGoogleLoginUserExists(t *testing.T) {
 	}
 	defer database.DB.Close()
 
+	expectAddressBans(mock, models.AddressBanIp)
 	mock.ExpectQuery("SELECT id FROM oauth_provider").WithArgs("google").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT user_id FROM oauth_login").WithArgs(1, "123456").WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+	mock.ExpectQuery("SELECT username, display_name FROM user WHERE id = ").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto123", "toto123"))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	expectNoTotpSecret(mock, 1)
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 1, token.HashRefreshToken("test"), "127.0.0.1", "test-agent", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSuccessfulLogin(mock, "toto123", 1, "127.0.0.1", "test-agent", models.LoginMethodGoogle)
 
-	loginToken, code, err := GoogleLogin("google", userToken)
+	loginTokens, code, err := GoogleLogin("google", userToken, "127.0.0.1", "test-agent")
 	os.Setenv("IS_TEST", "false")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -146,8 +213,8 @@ func TestGoogleLoginUserExists(t *testing.T) {
 	if code != http.StatusOK {
 		t.Errorf("Code should be http.StatusOK")
 	}
-	if loginToken != "test" {
-		t.Errorf("Token should be \"test\"")
+	if loginTokens.AccessToken != "test" || loginTokens.RefreshToken != "test" {
+		t.Errorf("Tokens should be \"test\"")
 	}
 }
 
@@ -163,13 +230,15 @@ func TestGoogleLoginRegisterOauthFail(t *testing.T) {
 	}
 	defer database.DB.Close()
 
+	expectAddressBans(mock, models.AddressBanIp)
 	mock.ExpectQuery("SELECT id FROM oauth_provider").WithArgs("google").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT user_id FROM oauth_login").WithArgs(1, "123456").WillReturnError(sql.ErrNoRows)
+	expectAddressBans(mock, models.AddressBanEmailDomain)
 	mock.ExpectQuery("SELECT COUNT").WithArgs("toto123").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT").WithArgs("toto123@gmail.com").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 	mock.ExpectExec("INSERT INTO user").WithArgs("toto123", "toto123", "", "toto123@gmail.com", "2000-01-01").WillReturnError(errors.New("error for test register oauth"))
 
-	_, code, err := GoogleLogin("google", userToken)
+	_, code, err := GoogleLogin("google", userToken, "127.0.0.1", "test-agent")
 	os.Setenv("IS_TEST", "false")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -194,14 +263,26 @@ func TestGoogleLoginRegisterOauthSuccess(t *testing.T) {
 	}
 	defer database.DB.Close()
 
+	expectAddressBans(mock, models.AddressBanIp)
 	mock.ExpectQuery("SELECT id FROM oauth_provider").WithArgs("google").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT user_id FROM oauth_login").WithArgs(1, "123456").WillReturnError(sql.ErrNoRows)
+	expectAddressBans(mock, models.AddressBanEmailDomain)
 	mock.ExpectQuery("SELECT COUNT").WithArgs("toto123").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT").WithArgs("toto123@gmail.com").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 	mock.ExpectExec("INSERT INTO user").WithArgs("toto123", "toto123", "", "toto123@gmail.com", "2000-01-01").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec("INSERT INTO oauth_login").WithArgs(1, "123456", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	// google verified the address
+	mock.ExpectExec("UPDATE user SET email = \\?, email_verified = 1").WithArgs("toto123@gmail.com", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT username, display_name FROM user WHERE id = ").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto123", "toto123"))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	expectNoTotpSecret(mock, 1)
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 1, token.HashRefreshToken("test"), "127.0.0.1", "test-agent", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	// the first login of the account is not from a new device
+	mock.ExpectQuery("FROM login_attempt WHERE user_id = (.+) AND success = 1").WithArgs("127.0.0.1", "test-agent", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"logins", "from_device"}).AddRow(0, 0))
+	expectLoginAttempt(mock, "toto123", 1, "127.0.0.1", "test-agent", models.LoginMethodGoogle, true)
 
-	token, code, err := GoogleLogin("google", userToken)
+	tokens, code, err := GoogleLogin("google", userToken, "127.0.0.1", "test-agent")
 	os.Setenv("IS_TEST", "false")
 	if mock.ExpectationsWereMet() != nil {
 		t.Errorf("Error while checking expectations: %s", err.Error())
@@ -212,7 +293,7 @@ func TestGoogleLoginRegisterOauthSuccess(t *testing.T) {
 	if code != http.StatusOK {
 		t.Errorf("Code should be http.StatusCreated")
 	}
-	if token != "test" {
-		t.Errorf("Token should be \"test\"")
+	if tokens.AccessToken != "test" || tokens.RefreshToken != "test" {
+		t.Errorf("Tokens should be \"test\"")
 	}
 }
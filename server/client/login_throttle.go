@@ -0,0 +1,122 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"strings"
+	"time"
+)
+
+// failed logins older than this are forgotten
+const loginAttemptWindow = time.Hour
+
+// longest wait between two logins, reached once a username or an IP address has too many failures
+const loginLockoutDuration = 15 * time.Minute
+
+// loginThrottle tells how long to wait before another login after the failures of a username or an IP address.
+// The first failures are free, then the wait doubles with each failure until the lockout
+type loginThrottle struct {
+	freeFailures    int
+	lockoutFailures int
+}
+
+// a username is protected from the attempts spread over many IP addresses, an IP address from the attempts spread over many usernames
+var (
+	usernameLoginThrottle = loginThrottle{freeFailures: 5, lockoutFailures: 10}
+	ipLoginThrottle       = loginThrottle{freeFailures: 20, lockoutFailures: 100}
+)
+
+// LoginThrottledError refuses a login until RetryAfter has passed
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins, try again in %d seconds", int(e.RetryAfter.Seconds()))
+}
+
+func (t loginThrottle) delay(failures int) time.Duration {
+	if failures < t.freeFailures {
+		return 0
+	}
+	if failures >= t.lockoutFailures {
+		return loginLockoutDuration
+	}
+	return min(time.Second<<(failures-t.freeFailures), loginLockoutDuration)
+}
+
+// retryAfter returns how long to wait before another login, 0 when the login can be tried now
+func (t loginThrottle) retryAfter(failures models.LoginFailures) time.Duration {
+	delay := t.delay(failures.Count)
+	if delay == 0 {
+		return 0
+	}
+	return max(time.Until(failures.LastFailureAt.Add(delay)), 0)
+}
+
+// loginAttemptUsername is the username the attempts are counted for, the case doesn't give more attempts
+func loginAttemptUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// checkLoginThrottle refuses a login while the username or the IP address has to wait after the failures recorded before the attempt.
+// The unknown usernames are throttled the same, so that the answer doesn't tell which accounts exist
+func checkLoginThrottle(username string, ipAddress string, attemptId int) (int, error) {
+	since := time.Now().Add(-loginAttemptWindow)
+	failures, err := database.GetStore().GetLoginFailuresByUsername(loginAttemptUsername(username), since, attemptId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	retryAfter := usernameLoginThrottle.retryAfter(failures)
+
+	failures, err = database.GetStore().GetLoginFailuresByIp(ipAddress, since, attemptId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	retryAfter = max(retryAfter, ipLoginThrottle.retryAfter(failures))
+	if retryAfter > 0 {
+		log.Printf("Login of username %s from ip %s throttled for %s\n", username, ipAddress, retryAfter)
+		return http.StatusTooManyRequests, &LoginThrottledError{RetryAfter: (retryAfter + time.Second - 1).Truncate(time.Second)}
+	}
+	return http.StatusOK, nil
+}
+
+// reserveLoginAttempt records the login as failed before its credentials are checked, then checks the throttle.
+// Only the attempts recorded before count, so that concurrent logins can't all pass the check before their failures are recorded.
+// The attempt is finished with finishLoginAttempt once the credentials are checked, a throttled attempt is forgotten
+func reserveLoginAttempt(username string, ipAddress string, userAgent string, method string) (int, int, error) {
+	attemptId, err := recordLoginAttempt(username, 0, ipAddress, userAgent, method, false)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	code, err := checkLoginThrottle(username, ipAddress, attemptId)
+	if err != nil {
+		cancelLoginAttempt(attemptId)
+		return 0, code, err
+	}
+	return attemptId, http.StatusOK, nil
+}
+
+// finishLoginAttempt sets the account and the result of a reserved attempt, userId is 0 when the username matched no account
+func finishLoginAttempt(attemptId int, userId int, success bool) error {
+	return database.GetStore().FinishLoginAttempt(attemptId, userId, success)
+}
+
+// cancelLoginAttempt forgets a reserved attempt which is not a failure of the user, e.g. when a second factor is asked next
+func cancelLoginAttempt(attemptId int) {
+	err := database.GetStore().DeleteLoginAttempt(attemptId)
+	if err != nil {
+		log.Printf("Error while cancelling login attempt %d: %s\n", attemptId, err.Error())
+	}
+}
+
+// recordLoginAttempt adds the login to the attempts and returns its id, userId is 0 when the username matched no account
+func recordLoginAttempt(username string, userId int, ipAddress string, userAgent string, method string, success bool) (int, error) {
+	userAgent = truncateUserAgent(userAgent)
+	id, err := database.GetStore().AddLoginAttempt(models.LoginAttempt{Username: loginAttemptUsername(username), UserId: userId,
+		IpAddress: ipAddress, UserAgent: userAgent, Method: method, Success: success})
+	return int(id), err
+}
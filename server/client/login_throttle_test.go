@@ -0,0 +1,120 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// id of the login attempts reserved by the tests
+const testLoginAttemptId = 7
+
+// expectLoginFailures expects the failures of the username and of the IP address before the attempt to be counted,
+// the last ones happened at lastFailureAt
+func expectLoginFailures(mock sqlmock.Sqlmock, username string, usernameFailures int, ipAddress string, ipFailures int, lastFailureAt time.Time) {
+	mock.ExpectQuery("SELECT COUNT(.+) FROM login_attempt WHERE success = 0 AND username = ").WithArgs(username, sqlmock.AnyArg(), testLoginAttemptId, username, testLoginAttemptId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(usernameFailures))
+	if usernameFailures > 0 {
+		mock.ExpectQuery("SELECT created_at FROM login_attempt WHERE success = 0 AND username = ").WithArgs(username, sqlmock.AnyArg(), testLoginAttemptId, username, testLoginAttemptId).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(lastFailureAt))
+	}
+	mock.ExpectQuery("SELECT COUNT(.+) FROM login_attempt WHERE success = 0 AND ip_address = ").WithArgs(ipAddress, sqlmock.AnyArg(), testLoginAttemptId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(ipFailures))
+	if ipFailures > 0 {
+		mock.ExpectQuery("SELECT created_at FROM login_attempt WHERE success = 0 AND ip_address = ").WithArgs(ipAddress, sqlmock.AnyArg(), testLoginAttemptId).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(lastFailureAt))
+	}
+}
+
+// expectReservedLogin expects the login to be recorded as failed before the credentials are checked, then the failures before it to be counted
+func expectReservedLogin(mock sqlmock.Sqlmock, username string, ipAddress string, userAgent string, method string, usernameFailures int, ipFailures int) {
+	mock.ExpectExec("INSERT INTO login_attempt").WithArgs(username, nil, ipAddress, userAgent, method, false).WillReturnResult(sqlmock.NewResult(testLoginAttemptId, 1))
+	expectLoginFailures(mock, username, usernameFailures, ipAddress, ipFailures, time.Now())
+}
+
+// expectFinishedLogin expects the account and the result of the reserved login to be set
+func expectFinishedLogin(mock sqlmock.Sqlmock, userId int, success bool) {
+	mock.ExpectExec("UPDATE login_attempt SET user_id = (.+), success = (.+) WHERE id = ").WithArgs(userId, success, testLoginAttemptId).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectCancelledLogin expects the reserved login to be forgotten
+func expectCancelledLogin(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("DELETE FROM login_attempt WHERE id = ").WithArgs(testLoginAttemptId).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectLoginAttempt expects the login to be recorded, userId is nil for an unknown username
+func expectLoginAttempt(mock sqlmock.Sqlmock, username string, userId any, ipAddress string, userAgent string, method string, success bool) {
+	mock.ExpectExec("INSERT INTO login_attempt").WithArgs(username, userId, ipAddress, userAgent, method, success).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// expectSuccessfulLogin expects the login to be recorded from a device the user already logged in from
+func expectSuccessfulLogin(mock sqlmock.Sqlmock, username string, userId int, ipAddress string, userAgent string, method string) {
+	mock.ExpectQuery("FROM login_attempt WHERE user_id = (.+) AND success = 1").WithArgs(ipAddress, userAgent, userId).
+		WillReturnRows(sqlmock.NewRows([]string{"logins", "from_device"}).AddRow(3, 1))
+	expectLoginAttempt(mock, username, userId, ipAddress, userAgent, method, true)
+}
+
+// expectReservedSuccessfulLogin expects the reserved login to succeed from a device the user already logged in from
+func expectReservedSuccessfulLogin(mock sqlmock.Sqlmock, userId int, ipAddress string, userAgent string) {
+	mock.ExpectQuery("FROM login_attempt WHERE user_id = (.+) AND success = 1").WithArgs(ipAddress, userAgent, userId).
+		WillReturnRows(sqlmock.NewRows([]string{"logins", "from_device"}).AddRow(3, 1))
+	expectFinishedLogin(mock, userId, true)
+}
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle := loginThrottle{freeFailures: 3, lockoutFailures: 6}
+	for failures, expected := range []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, loginLockoutDuration, loginLockoutDuration} {
+		if delay := throttle.delay(failures); delay != expected {
+			t.Errorf("Expected a delay of %s after %d failures, got %s", expected, failures, delay)
+		}
+	}
+	if delay := (loginThrottle{freeFailures: 0, lockoutFailures: 100}).delay(50); delay != loginLockoutDuration {
+		t.Errorf("Expected the delay to stop at the lockout, got %s", delay)
+	}
+
+	failures := models.LoginFailures{Count: 4, LastFailureAt: time.Now().Add(-3 * time.Second)}
+	if retryAfter := throttle.retryAfter(failures); retryAfter != 0 {
+		t.Errorf("Expected the wait to be over, got %s", retryAfter)
+	}
+	failures.Count = 6
+	if retryAfter := throttle.retryAfter(failures); retryAfter < loginLockoutDuration-4*time.Second || retryAfter > loginLockoutDuration {
+		t.Errorf("Expected the lockout, got %s", retryAfter)
+	}
+}
+
+func TestCheckLoginThrottle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	expectLoginFailures(mock, "toto", usernameLoginThrottle.freeFailures-1, "127.0.0.1", 0, time.Now())
+	code, err := checkLoginThrottle("Toto ", "127.0.0.1", testLoginAttemptId)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected the free failures to be allowed, got %d, %v", code, err)
+	}
+
+	expectLoginFailures(mock, "toto", usernameLoginThrottle.lockoutFailures, "127.0.0.1", 0, time.Now())
+	code, err = checkLoginThrottle("toto", "127.0.0.1", testLoginAttemptId)
+	var throttledErr *LoginThrottledError
+	if code != http.StatusTooManyRequests || !errors.As(err, &throttledErr) || throttledErr.RetryAfter != loginLockoutDuration {
+		t.Errorf("Expected the username to be locked out, got %d, %v", code, err)
+	}
+
+	// the failures of other usernames from the same IP address add up
+	expectLoginFailures(mock, "toto", 0, "127.0.0.1", ipLoginThrottle.freeFailures+1, time.Now())
+	code, err = checkLoginThrottle("toto", "127.0.0.1", testLoginAttemptId)
+	if code != http.StatusTooManyRequests || !errors.As(err, &throttledErr) || throttledErr.RetryAfter != 2*time.Second {
+		t.Errorf("Expected the IP address to wait 2 seconds, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
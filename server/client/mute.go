@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+)
+
+// MuteUser stops the muter from being notified of the actions of the muted user, and hides their answers from the timeline
+func MuteUser(muterId int, mutedId int) (int, error) {
+	if muterId == mutedId {
+		return http.StatusBadRequest, errors.New("user can't mute himself")
+	}
+	mutedExists, err := database.GetStore().CheckUserIdExists(mutedId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !mutedExists {
+		return http.StatusNotFound, errors.New("user not found")
+	}
+
+	created, err := database.GetStore().AddMute(muterId, mutedId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !created {
+		return http.StatusBadRequest, errors.New("user already mutes this user")
+	}
+	return http.StatusOK, nil
+}
+
+func UnmuteUser(muterId int, mutedId int) (int, error) {
+	removed, err := database.GetStore().RemoveMute(muterId, mutedId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !removed {
+		return http.StatusBadRequest, errors.New("user doesn't mute this user")
+	}
+	return http.StatusOK, nil
+}
+
+// GetMutedUsers returns a page of the users muted by the user, most recent mute first
+func GetMutedUsers(userId int, cursor string, count int) (models.RelatedUserPage, int, error) {
+	return getRelatedUserPage(cursor, count, func(after *models.Cursor, count int) ([]models.RelatedUser, error) {
+		return database.GetStore().GetMutedUsers(userId, after, count)
+	})
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"net/http"
+	"project_truthful/client/database"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMuteUser(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	code, err := MuteUser(1, 1)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected users not to mute themselves, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	code, err = MuteUser(1, 2)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("INSERT IGNORE INTO user_mute").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	code, err = MuteUser(1, 2)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("INSERT IGNORE INTO user_mute").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	code, err = MuteUser(1, 2)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected the mute to exist already, got %d", code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestUnmuteUser(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	mock.ExpectExec("DELETE FROM user_mute").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	code, err := UnmuteUser(1, 2)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected http.StatusBadRequest, got %d", code)
+	}
+	mock.ExpectExec("DELETE FROM user_mute").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	code, err = UnmuteUser(1, 2)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
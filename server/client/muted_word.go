@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"strings"
+	"unicode/utf8"
+)
+
+// limits of the words a user can mute
+const (
+	maxMutedWords      = 100
+	maxMutedWordLength = 50
+)
+
+// normalizeMutedWord returns the word as it is stored, the words are matched case insensitively
+func normalizeMutedWord(word string) (string, error) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return "", errors.New("word is empty")
+	}
+	if utf8.RuneCountInString(word) > maxMutedWordLength {
+		return "", errors.New("word is too long")
+	}
+	return word, nil
+}
+
+func GetMutedWords(userId int) ([]string, int, error) {
+	words, err := database.GetStore().GetMutedWords(userId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return words, http.StatusOK, nil
+}
+
+// MuteWord rejects the questions containing the word, or the phrase, from the inbox of the user
+func MuteWord(userId int, word string) (int, error) {
+	word, err := normalizeMutedWord(word)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	words, err := database.GetStore().GetMutedWords(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(words) >= maxMutedWords {
+		return http.StatusBadRequest, errors.New("too many muted words")
+	}
+
+	created, err := database.GetStore().AddMutedWord(userId, word)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !created {
+		return http.StatusBadRequest, errors.New("word is already muted")
+	}
+	return http.StatusOK, nil
+}
+
+func UnmuteWord(userId int, word string) (int, error) {
+	word, err := normalizeMutedWord(word)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	removed, err := database.GetStore().RemoveMutedWord(userId, word)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !removed {
+		return http.StatusBadRequest, errors.New("word is not muted")
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"net/http"
+	"project_truthful/client/database"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMuteWord(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	for _, word := range []string{"  ", strings.Repeat("a", maxMutedWordLength+1)} {
+		code, err := MuteWord(1, word)
+		if code != http.StatusBadRequest || err == nil {
+			t.Errorf("Expected %q to be refused, got %d", word, code)
+		}
+	}
+
+	full := sqlmock.NewRows([]string{"word"})
+	for i := 0; i < maxMutedWords; i++ {
+		full.AddRow("word")
+	}
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(1).WillReturnRows(full)
+	code, err := MuteWord(1, "pizza")
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected too many muted words to be refused, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"word"}))
+	mock.ExpectExec("INSERT IGNORE INTO muted_word").WithArgs(1, "pizza").WillReturnResult(sqlmock.NewResult(0, 0))
+	code, err = MuteWord(1, " Pizza ")
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected an already muted word to be refused, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"word"}))
+	mock.ExpectExec("INSERT IGNORE INTO muted_word").WithArgs(1, "free money").WillReturnResult(sqlmock.NewResult(1, 1))
+	code, err = MuteWord(1, "Free Money")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestUnmuteWord(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	mock.ExpectExec("DELETE FROM muted_word").WithArgs(1, "pizza").WillReturnResult(sqlmock.NewResult(0, 0))
+	code, err := UnmuteWord(1, "pizza")
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected a word that is not muted to be refused, got %d", code)
+	}
+
+	mock.ExpectExec("DELETE FROM muted_word").WithArgs(1, "pizza").WillReturnResult(sqlmock.NewResult(0, 1))
+	code, err = UnmuteWord(1, "PIZZA")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
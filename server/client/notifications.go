@@ -0,0 +1,63 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+)
+
+// GetNotifications returns a page of the notifications of the user, newest first, with the unread count
+func GetNotifications(userId int, cursor string, count int) (models.NotificationPage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.NotificationPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more notification is fetched to know if there is a next page
+	notifications, err := database.GetStore().GetNotifications(userId, after, count+1)
+	if err != nil {
+		return models.NotificationPage{}, http.StatusInternalServerError, err
+	}
+	unreadCount, err := database.GetStore().CountUnreadNotifications(userId)
+	if err != nil {
+		return models.NotificationPage{}, http.StatusInternalServerError, err
+	}
+	page := models.NotificationPage{Notifications: notifications, UnreadCount: unreadCount}
+	if len(notifications) > count {
+		page.Notifications = notifications[:count]
+		last := page.Notifications[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
+
+func GetUnreadNotificationCount(userId int) (int, int, error) {
+	count, err := database.GetStore().CountUnreadNotifications(userId)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	return count, http.StatusOK, nil
+}
+
+// MarkNotificationAsRead marks a notification of the user as read, reading it again is not an error
+func MarkNotificationAsRead(userId int, notificationId int) (int, error) {
+	exists, err := database.GetStore().MarkNotificationAsRead(userId, notificationId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !exists {
+		return http.StatusNotFound, errors.New("notification not found")
+	}
+	return http.StatusOK, nil
+}
+
+func MarkAllNotificationsAsRead(userId int) (int, error) {
+	err := database.GetStore().MarkAllNotificationsAsRead(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
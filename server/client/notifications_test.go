@@ -0,0 +1,81 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetNotifications(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	database.DB = db
+	if err != nil {
+		t.Fatalf("Error while creating mock: %s", err.Error())
+	}
+	pagination.SetSecret("secret")
+
+	_, status, err := GetNotifications(1, "forged", 10)
+	if err != pagination.ErrInvalidCursor || status != http.StatusBadRequest {
+		t.Errorf("Expected invalid cursor with status 400, got %v and %d", err, status)
+	}
+
+	// one more notification than asked means there is a next page
+	createdAt := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "type", "question_id", "answer_id", "created_at", "read_at", "id", "username", "display_name"}).
+		AddRow(5, "new_follower", nil, nil, createdAt, nil, 2, "titi", "Titi").
+		AddRow(4, "new_follower", nil, nil, createdAt, nil, 3, "tata", "Tata")
+	mock.ExpectQuery("SELECT (.+) FROM notification").WithArgs(1, 2).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM notification").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(2))
+	page, status, err := GetNotifications(1, "", 1)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v and %d", err, status)
+	}
+	if len(page.Notifications) != 1 || page.UnreadCount != 2 || page.NextCursor == "" {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM notification").WithArgs(1, 11).WillReturnRows(sqlmock.NewRows([]string{"id", "type", "question_id", "answer_id", "created_at", "read_at", "id", "username", "display_name"}))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM notification").WithArgs(1).WillReturnError(errors.New("error"))
+	_, status, err = GetNotifications(1, "", 0)
+	if err == nil || status != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %v and %d", err, status)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestMarkNotificationAsRead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	database.DB = db
+	if err != nil {
+		t.Fatalf("Error while creating mock: %s", err.Error())
+	}
+
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(4, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	status, err := MarkNotificationAsRead(1, 4)
+	if err != nil || status != http.StatusOK {
+		t.Errorf("Expected status 200, got %v and %d", err, status)
+	}
+
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM notification").WithArgs(5, 1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	status, err = MarkNotificationAsRead(1, 5)
+	if err == nil || status != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %v and %d", err, status)
+	}
+
+	mock.ExpectExec("UPDATE notification SET read_at").WithArgs(1).WillReturnError(errors.New("error"))
+	status, err = MarkAllNotificationsAsRead(1)
+	if err == nil || status != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %v and %d", err, status)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
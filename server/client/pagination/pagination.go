@@ -0,0 +1,91 @@
+// Package pagination encodes the opaque cursors returned by list endpoints.
+// A cursor holds the creation date and id of the last item of a page, signed so that clients cannot forge positions
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"project_truthful/models"
+	"strings"
+	"time"
+)
+
+// number of items returned when the client does not ask for a count, and the most it can ask for
+const (
+	DefaultCount = 10
+	MaxCount     = 30
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var secret []byte
+
+// Init reads the secret signing the cursors from the CURSOR_SECRET env variable.
+// It is required, the cursors would not survive a restart with a random secret
+func Init() error {
+	s := os.Getenv("CURSOR_SECRET")
+	if s == "" {
+		return errors.New("CURSOR_SECRET must be set")
+	}
+	secret = []byte(s)
+	return nil
+}
+
+// SetSecret signs cursors with the given secret instead of the CURSOR_SECRET env variable
+func SetSecret(s string) {
+	secret = []byte(s)
+}
+
+type payload struct {
+	CreatedAt time.Time `json:"t"`
+	Id        int       `json:"id"`
+}
+
+func sign(data string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Encode returns the opaque cursor pointing after the given position
+func Encode(cursor models.Cursor) string {
+	data, _ := json.Marshal(payload{CreatedAt: cursor.CreatedAt.UTC(), Id: cursor.Id})
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + sign(encoded)
+}
+
+// Decode returns the position held by a cursor made by Encode, an empty cursor is the start of the list
+func Decode(cursor string) (*models.Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	encoded, signature, found := strings.Cut(cursor, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(sign(encoded))) {
+		return nil, ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var p payload
+	err = json.Unmarshal(data, &p)
+	if err != nil || p.Id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &models.Cursor{CreatedAt: p.CreatedAt, Id: p.Id}, nil
+}
+
+// ClampCount returns the number of items to return for the count asked by the client
+func ClampCount(count int) int {
+	if count <= 0 {
+		return DefaultCount
+	}
+	if count > MaxCount {
+		return MaxCount
+	}
+	return count
+}
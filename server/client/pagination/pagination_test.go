@@ -0,0 +1,72 @@
+package pagination
+
+import (
+	"project_truthful/models"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	SetSecret("secret")
+	createdAt := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	cursor := Encode(models.Cursor{CreatedAt: createdAt, Id: 42})
+
+	decoded, err := Decode(cursor)
+	if err != nil {
+		t.Fatalf("Error while decoding cursor: %s", err.Error())
+	}
+	if !decoded.CreatedAt.Equal(createdAt) || decoded.Id != 42 {
+		t.Errorf("Expected %s and 42, got %+v", createdAt, decoded)
+	}
+
+	decoded, err = Decode("")
+	if err != nil || decoded != nil {
+		t.Errorf("Expected no position for an empty cursor, got %+v, %v", decoded, err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	SetSecret("secret")
+	cursor := Encode(models.Cursor{CreatedAt: time.Now(), Id: 42})
+	forged := Encode(models.Cursor{CreatedAt: time.Now(), Id: 1})
+
+	invalid := []string{
+		"garbage",
+		cursor + "x",
+		forged[:len(forged)-43] + cursor[len(cursor)-43:],
+		"e30." + sign("e30"), // signed, but without id
+	}
+	for _, c := range invalid {
+		_, err := Decode(c)
+		if err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", c, err)
+		}
+	}
+
+	// cursors signed with another secret are rejected
+	SetSecret("another secret")
+	_, err := Decode(cursor)
+	if err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestClampCount(t *testing.T) {
+	tests := map[int]int{-1: DefaultCount, 0: DefaultCount, 5: 5, MaxCount: MaxCount, MaxCount + 1: MaxCount}
+	for count, expected := range tests {
+		if ClampCount(count) != expected {
+			t.Errorf("Expected %d for %d, got %d", expected, count, ClampCount(count))
+		}
+	}
+}
+
+func TestInit(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "")
+	if Init() == nil {
+		t.Errorf("Expected an error without CURSOR_SECRET")
+	}
+	t.Setenv("CURSOR_SECRET", "secret")
+	if Init() != nil || string(secret) != "secret" {
+		t.Errorf("Expected the secret to be read from CURSOR_SECRET, got %s", secret)
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/mailer"
+	"time"
+)
+
+// a reset link can be used once, within the hour
+const passwordResetTokenDuration = time.Hour
+
+// requests of the password reset flow allowed per hour, the account limit only applies to the emails sent
+const (
+	passwordResetWindow         = time.Hour
+	maxPasswordResetsPerAccount = 3
+	maxPasswordResetsPerIp      = 10
+)
+
+// page of the front-end the reset links lead to when PASSWORD_RESET_URL is not set
+const defaultPasswordResetPageUrl = "http://localhost:3000/reset_password"
+
+const passwordResetEmailSubject = "Reset your Truthful password"
+const passwordResetEmailBody = "Someone asked to reset the password of your Truthful account.\n\n" +
+	"Follow this link within the hour to choose a new one:\n%s\n\n" +
+	"If it wasn't you, ignore this email, your password won't change."
+
+var errTooManyPasswordResets = errors.New("too many password reset requests, try again later")
+var errInvalidPasswordResetToken = errors.New("invalid or expired reset token")
+
+// passwordResetLink returns the link to the page of the front-end resetting the password with the token
+func passwordResetLink(resetToken string) string {
+	pageUrl := os.Getenv("PASSWORD_RESET_URL")
+	if pageUrl == "" {
+		pageUrl = defaultPasswordResetPageUrl
+	}
+	return pageUrl + "?token=" + url.QueryEscape(resetToken)
+}
+
+// checkPasswordResetsFromIp records the request and refuses it when the IP address made too many of them
+func checkPasswordResetsFromIp(ipAddress string, userId int) (int, error) {
+	count, err := database.GetStore().CountPasswordResetRequestsByIp(ipAddress, time.Now().Add(-passwordResetWindow))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if count >= maxPasswordResetsPerIp {
+		return http.StatusTooManyRequests, errTooManyPasswordResets
+	}
+	err = database.GetStore().AddPasswordResetRequest(ipAddress, userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// ForgotPassword emails a reset link to the account using the email address.
+// It answers the same whether the address is used or not, and whether the account already got too many links,
+// so that it can't be used to find out who has an account. The work only done for accounts, from the reset token
+// to the email, runs in the background so that the response time doesn't tell either
+func ForgotPassword(email string, ipAddress string) (int, error) {
+	if email == "" {
+		return http.StatusBadRequest, errors.New("email_address is required")
+	}
+	userId, err := database.GetStore().GetUserIdByEmail(email)
+	if err != nil && err != sql.ErrNoRows {
+		return http.StatusInternalServerError, err
+	}
+	code, err := checkPasswordResetsFromIp(ipAddress, userId)
+	if err != nil {
+		return code, err
+	}
+	if userId != 0 {
+		inBackground(func() {
+			sendPasswordResetEmail(userId, email)
+		})
+	}
+	return http.StatusOK, nil
+}
+
+// sendPasswordResetEmail creates a reset token and emails its link to the user, unless they got too many links already.
+// The request was answered already, the errors are only logged
+func sendPasswordResetEmail(userId int, email string) {
+	// the request being answered is counted too
+	sent, err := database.GetStore().CountPasswordResetRequestsByUser(userId, time.Now().Add(-passwordResetWindow))
+	if err != nil {
+		log.Printf("Error while counting password reset requests of user %d: %s\n", userId, err.Error())
+		return
+	}
+	if sent > maxPasswordResetsPerAccount {
+		log.Printf("Too many password reset emails sent to user %d, not sending another one\n", userId)
+		return
+	}
+
+	resetToken, err := token.GeneratePasswordResetToken()
+	if err != nil {
+		log.Printf("Error while generating password reset token of user %d: %s\n", userId, err.Error())
+		return
+	}
+	_, err = database.GetStore().AddPasswordResetToken(userId, token.HashPasswordResetToken(resetToken), time.Now().Add(passwordResetTokenDuration))
+	if err != nil {
+		log.Printf("Error while adding password reset token of user %d: %s\n", userId, err.Error())
+		return
+	}
+	err = mailer.Send(mailer.Message{To: email, Subject: passwordResetEmailSubject, Body: fmt.Sprintf(passwordResetEmailBody, passwordResetLink(resetToken))})
+	if err != nil {
+		log.Printf("Error while sending password reset email to user %d: %s\n", userId, err.Error())
+	}
+}
+
+// ResetPassword sets the password of the account the reset token was sent to.
+// The other reset tokens of the account are revoked and every session is logged out
+func ResetPassword(resetToken string, password string, ipAddress string) (int, error) {
+	code, err := checkPasswordResetsFromIp(ipAddress, 0)
+	if err != nil {
+		return code, err
+	}
+	err = isPasswordValid(password)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	resetTokenInfos, err := database.GetStore().GetPasswordResetToken(token.HashPasswordResetToken(resetToken))
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest, errInvalidPasswordResetToken
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if resetTokenInfos.IsUsed || resetTokenInfos.ExpiresAt.Before(time.Now()) {
+		return http.StatusBadRequest, errInvalidPasswordResetToken
+	}
+	encryptedPassword, err := encryptPassword(password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	userId := resetTokenInfos.UserId
+	err = database.GetStore().InTransaction(func(store database.Store) error {
+		used, err := store.UsePasswordResetToken(resetTokenInfos.Id)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errInvalidPasswordResetToken
+		}
+		err = store.UpdatePassword(userId, encryptedPassword)
+		if err != nil {
+			return err
+		}
+		err = store.RevokePasswordResetTokens(userId)
+		if err != nil {
+			return err
+		}
+		return store.RevokeAllUserSessions(userId)
+	})
+	if err == errInvalidPasswordResetToken {
+		return http.StatusBadRequest, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
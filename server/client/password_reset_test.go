@@ -0,0 +1,169 @@
+package client
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/mailer"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// recordingMailer keeps the emails instead of sending them
+type recordingMailer struct {
+	messages []mailer.Message
+	// returned by Send, the messages are recorded anyway
+	err error
+}
+
+func (m *recordingMailer) Send(message mailer.Message) error {
+	m.messages = append(m.messages, message)
+	return m.err
+}
+
+func TestForgotPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	recorder := &recordingMailer{}
+	mailer.SetMailer(recorder)
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+
+	runBackground := holdBackground(t)
+
+	// unknown and known addresses get the same answer after the same queries, the email is sent in the background
+	mock.ExpectQuery("SELECT id FROM user WHERE email = ").WithArgs("nobody@toto.fr").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE ip_address = ").WithArgs("10.0.0.1", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO password_reset_request").WithArgs("10.0.0.1", nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	code, err := ForgotPassword("nobody@toto.fr", "10.0.0.1")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected 200, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations of the unknown address: %s", err.Error())
+	}
+
+	mock.ExpectQuery("SELECT id FROM user WHERE email = ").WithArgs("toto@toto.fr").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE ip_address = ").WithArgs("10.0.0.1", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO password_reset_request").WithArgs("10.0.0.1", 2).WillReturnResult(sqlmock.NewResult(2, 1))
+	code, err = ForgotPassword("toto@toto.fr", "10.0.0.1")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected 200, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations of the known address: %s", err.Error())
+	}
+	if len(recorder.messages) != 0 {
+		t.Fatalf("Expected the email to wait for the background, got %+v", recorder.messages)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE user_id = ").WithArgs(2, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO password_reset_token").WithArgs(2, token.HashPasswordResetToken("test"), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	runBackground()
+	if len(recorder.messages) != 1 {
+		t.Fatalf("Expected the reset email to be sent, got %+v", recorder.messages)
+	}
+	if recorder.messages[0].To != "toto@toto.fr" || !strings.Contains(recorder.messages[0].Body, defaultPasswordResetPageUrl+"?token=test") {
+		t.Errorf("Unexpected email %+v", recorder.messages[0])
+	}
+
+	// an account which got too many emails gets no more, silently
+	mock.ExpectQuery("SELECT id FROM user WHERE email = ").WithArgs("toto@toto.fr").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE ip_address = ").WithArgs("10.0.0.2", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO password_reset_request").WithArgs("10.0.0.2", 2).WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE user_id = ").WithArgs(2, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(maxPasswordResetsPerAccount + 1))
+	code, err = ForgotPassword("toto@toto.fr", "10.0.0.2")
+	runBackground()
+	if code != http.StatusOK || err != nil || len(recorder.messages) != 1 {
+		t.Errorf("Expected 200 without email, got %d, %v, %+v", code, err, recorder.messages)
+	}
+
+	// an address which made too many requests is refused
+	mock.ExpectQuery("SELECT id FROM user WHERE email = ").WithArgs("nobody@toto.fr").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE ip_address = ").WithArgs("10.0.0.1", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(maxPasswordResetsPerIp))
+	code, err = ForgotPassword("nobody@toto.fr", "10.0.0.1")
+	if code != http.StatusTooManyRequests || err != errTooManyPasswordResets {
+		t.Errorf("Expected 429, got %d, %v", code, err)
+	}
+
+	code, _ = ForgotPassword("", "10.0.0.1")
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+	expectRequest := func() {
+		mock.ExpectQuery("SELECT COUNT(.+) FROM password_reset_request WHERE ip_address = ").WithArgs("10.0.0.1", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec("INSERT INTO password_reset_request").WithArgs("10.0.0.1", nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	columns := []string{"id", "user_id", "expires_at", "used"}
+	hash := token.HashPasswordResetToken("reset")
+
+	expectRequest()
+	code, err := ResetPassword("reset", "weak", "10.0.0.1")
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected a weak password to be refused, got %d, %v", code, err)
+	}
+
+	expectRequest()
+	mock.ExpectQuery("FROM password_reset_token WHERE token_hash = ").WithArgs(hash).WillReturnError(sql.ErrNoRows)
+	code, err = ResetPassword("reset", "Password123@", "10.0.0.1")
+	if code != http.StatusBadRequest || err != errInvalidPasswordResetToken {
+		t.Errorf("Expected an unknown token to be refused, got %d, %v", code, err)
+	}
+
+	expectRequest()
+	mock.ExpectQuery("FROM password_reset_token WHERE token_hash = ").WithArgs(hash).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, time.Now().Add(-time.Minute), false))
+	code, err = ResetPassword("reset", "Password123@", "10.0.0.1")
+	if code != http.StatusBadRequest || err != errInvalidPasswordResetToken {
+		t.Errorf("Expected an expired token to be refused, got %d, %v", code, err)
+	}
+
+	expectRequest()
+	mock.ExpectQuery("FROM password_reset_token WHERE token_hash = ").WithArgs(hash).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, time.Now().Add(time.Hour), false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE password_reset_token SET used_at (.+) WHERE id = ").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE user SET password").WithArgs("Password123@", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE password_reset_token SET used_at (.+) WHERE user_id = ").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	code, err = ResetPassword("reset", "Password123@", "10.0.0.1")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected the password to be reset, got %d, %v", code, err)
+	}
+
+	// a concurrent reset already used the token
+	expectRequest()
+	mock.ExpectQuery("FROM password_reset_token WHERE token_hash = ").WithArgs(hash).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, time.Now().Add(time.Hour), false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE password_reset_token SET used_at (.+) WHERE id = ").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	code, err = ResetPassword("reset", "Password123@", "10.0.0.1")
+	if code != http.StatusBadRequest || err != errInvalidPasswordResetToken {
+		t.Errorf("Expected the used token to be refused, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -1,53 +1,112 @@
 package client
 
 import (
+	"database/sql"
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/models"
+	"project_truthful/permissions"
+	"slices"
 )
 
-func PromoteUser(requesterId int, userId int, promoteType string) (int, error) {
-	// Check if the requester is an admin
-	isUserAdmin, err := database.CheckAdminStatus(requesterId, database.DB)
+// roleState is the roles of a user recorded in the audit log before and after a role change
+type roleState struct {
+	Roles []string `json:"roles"`
+}
+
+// errRoleNotHeld rolls back the revocation of a role the user doesn't have
+var errRoleNotHeld = errors.New("user does not have this role")
+
+// PromoteUser grants the role to the user, the requester must be allowed to manage roles
+func PromoteUser(audit models.AuditContext, userId int, role string) (int, error) {
+	roleId, err := database.GetStore().GetRoleId(role)
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest, errors.New("invalid role")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	exists, err := database.GetStore().CheckUserIdExists(userId)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	if !isUserAdmin {
-		return http.StatusForbidden, errors.New("user has no permission to promote users")
+	if !exists {
+		return http.StatusNotFound, errors.New("user not found")
 	}
 
-	// Promote the user
-	if promoteType == "admin" {
-		// Check if the user is already an admin
-		isUserAdmin, err := database.CheckAdminStatus(userId, database.DB)
-		if err != nil {
-			return http.StatusInternalServerError, err
-		}
-		if isUserAdmin {
-			return http.StatusBadRequest, errors.New("user is already an admin")
-		}
-		// Promote the user to admin
-		err = database.PromoteUserToAdmin(userId, database.DB)
+	roles, err := database.GetStore().GetUserRoles(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if slices.Contains(roles, role) {
+		return http.StatusBadRequest, errors.New("user already has this role")
+	}
+
+	return inTransaction(func(store database.Store) error {
+		err := store.GrantRole(userId, roleId, audit.ModeratorId)
 		if err != nil {
-			return http.StatusInternalServerError, err
+			return err
 		}
-		return http.StatusOK, nil
-	} else if promoteType == "moderator" {
-		// Check if the user is already a moderator
-		isUserModerator, err := database.CheckModeratorStatus(userId, database.DB)
+		granted := append(append([]string{}, roles...), role)
+		return addAuditEntry(store, audit, models.AuditRoleGrant, models.AuditTargetUser, userId, roleState{Roles: roles}, roleState{Roles: granted})
+	})
+}
+
+// DemoteUser revokes the role from the user, the requester must be allowed to manage roles
+func DemoteUser(audit models.AuditContext, userId int, role string) (int, error) {
+	roleId, err := database.GetStore().GetRoleId(role)
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest, errors.New("invalid role")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// admins cannot drop their own admin role, so at least one admin always remains
+	if audit.ModeratorId == userId && role == permissions.RoleAdmin {
+		return http.StatusForbidden, errors.New("cannot remove own admin role")
+	}
+
+	roles, err := database.GetStore().GetUserRoles(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	code, err := inTransaction(func(store database.Store) error {
+		revoked, err := store.RevokeRole(userId, roleId)
 		if err != nil {
-			return http.StatusInternalServerError, err
-		}
-		if isUserModerator {
-			return http.StatusBadRequest, errors.New("user is already a moderator")
+			return err
 		}
-		// Promote the user to moderator
-		err = database.PromoteUserToModerator(userId, database.DB)
-		if err != nil {
-			return http.StatusInternalServerError, err
+		if !revoked {
+			return errRoleNotHeld
 		}
-		return http.StatusOK, nil
-	} else {
-		return http.StatusBadRequest, errors.New("invalid promote type")
+		remaining := slices.DeleteFunc(append([]string{}, roles...), func(r string) bool { return r == role })
+		return addAuditEntry(store, audit, models.AuditRoleRevoke, models.AuditTargetUser, userId, roleState{Roles: roles}, roleState{Roles: remaining})
+	})
+	if err == errRoleNotHeld {
+		return http.StatusNotFound, err
+	}
+	return code, err
+}
+
+func GetRoles() ([]models.Role, int, error) {
+	roles, err := database.GetStore().GetRoles()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return roles, http.StatusOK, nil
+}
+
+func GetUserRoles(username string) ([]string, int, error) {
+	userId, err := database.GetStore().GetUserId(username)
+	if err == sql.ErrNoRows {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	} else if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	roles, err := database.GetStore().GetUserRoles(userId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
+	return roles, http.StatusOK, nil
 }
@@ -1,28 +1,31 @@
 package client
 
 import (
+	"database/sql"
 	"errors"
+	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/models"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
-func TestPromoteUserNotAdmin(t *testing.T) {
+func TestPromoteUserInvalidRole(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
 	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("invalid").WillReturnError(sql.ErrNoRows)
 
-	_, err = PromoteUser(1, 1, "admin")
+	code, err := PromoteUser(models.AuditContext{ModeratorId: 1}, 2, "invalid")
 	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+		t.Errorf("Error while checking expectations")
 	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+	if err == nil || code != http.StatusBadRequest {
+		t.Errorf("Expected bad request, got %d, %v", code, err)
 	}
 }
 
@@ -33,183 +36,185 @@ func TestPromoteUserError(t *testing.T) {
 	}
 	defer db.Close()
 	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnError(err)
 
-	_, err = PromoteUser(1, 1, "admin")
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
-	}
-}
-
-func TestPromoteUserAlreadyAdmin(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	// error while getting role
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("admin").WillReturnError(errors.New("error"))
+	code, err := PromoteUser(models.AuditContext{ModeratorId: 1}, 2, "admin")
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error, got %d, %v", code, err)
+	}
+
+	// error while checking role of the user
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("admin").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnError(errors.New("error"))
+	code, err = PromoteUser(models.AuditContext{ModeratorId: 1}, 2, "admin")
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error, got %d, %v", code, err)
+	}
+
+	// error while granting role
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("admin").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_role").WithArgs(2, 1, 1).WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	code, err = PromoteUser(models.AuditContext{ModeratorId: 1}, 2, "admin")
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error, got %d, %v", code, err)
 	}
-	defer db.Close()
-	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-
-	_, err = PromoteUser(1, 1, "admin")
 	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+		t.Errorf("Error while checking expectations")
 	}
 }
 
-func TestPromoteUserAlreadyModerator(t *testing.T) {
+func TestPromoteUserNotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
 	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 
-	_, err = PromoteUser(1, 2, "moderator")
+	code, err := PromoteUser(models.AuditContext{ModeratorId: 1}, 2, "moderator")
 	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+		t.Errorf("Error while checking expectations")
 	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+	if err == nil || code != http.StatusNotFound {
+		t.Errorf("Expected not found, got %d, %v", code, err)
 	}
 }
 
-func TestCheckUserAdminError(t *testing.T) {
+func TestPromoteUserAlreadyHasRole(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
 	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnError(errors.New("test error"))
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("moderator"))
 
-	_, err = PromoteUser(1, 2, "admin")
+	code, err := PromoteUser(models.AuditContext{ModeratorId: 1}, 2, "moderator")
 	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+		t.Errorf("Error while checking expectations")
 	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+	if err == nil || code != http.StatusBadRequest {
+		t.Errorf("Expected bad request, got %d, %v", code, err)
 	}
 }
 
-func TestCheckUserModeratorError(t *testing.T) {
+func TestPromoteUserSuccess(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
 	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnError(errors.New("test error"))
-
-	_, err = PromoteUser(1, 2, "moderator")
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_role").WithArgs(2, 2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "role.grant", "user", 2, `{"before":{"roles":[]},"after":{"roles":["moderator"]}}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	code, err := PromoteUser(models.AuditContext{ModeratorId: 1}, 2, "moderator")
 	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
+		t.Errorf("Error while checking expectations")
 	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+	if err != nil || code != http.StatusOK {
+		t.Errorf("Expected success, got %d, %v", code, err)
 	}
 }
 
-func TestPromoteUserToAdminError(t *testing.T) {
+func TestDemoteUser(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
 	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("UPDATE user").WithArgs(2).WillReturnError(errors.New("test error"))
 
-	_, err = PromoteUser(1, 2, "admin")
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+	// invalid role
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("invalid").WillReturnError(sql.ErrNoRows)
+	code, err := DemoteUser(models.AuditContext{ModeratorId: 1}, 2, "invalid")
+	if err == nil || code != http.StatusBadRequest {
+		t.Errorf("Expected bad request, got %d, %v", code, err)
+	}
+
+	// demoting self from admin
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("admin").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	code, err = DemoteUser(models.AuditContext{ModeratorId: 1}, 1, "admin")
+	if err == nil || code != http.StatusForbidden {
+		t.Errorf("Expected forbidden, got %d, %v", code, err)
+	}
+
+	// user does not have the role
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_role").WithArgs(2, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	code, err = DemoteUser(models.AuditContext{ModeratorId: 1}, 2, "moderator")
+	if err == nil || code != http.StatusNotFound {
+		t.Errorf("Expected not found, got %d, %v", code, err)
+	}
+
+	// error while revoking
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("moderator"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_role").WithArgs(2, 2).WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	code, err = DemoteUser(models.AuditContext{ModeratorId: 1}, 2, "moderator")
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error, got %d, %v", code, err)
+	}
+
+	// success
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("moderator"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_role").WithArgs(2, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "role.revoke", "user", 2, `{"before":{"roles":["moderator"]},"after":{"roles":[]}}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	code, err = DemoteUser(models.AuditContext{ModeratorId: 1}, 2, "moderator")
+	if err != nil || code != http.StatusOK {
+		t.Errorf("Expected success, got %d, %v", code, err)
 	}
-}
-
-func TestPromoteUserToModeratorError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
-	}
-	defer db.Close()
-	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("UPDATE user").WithArgs(2).WillReturnError(errors.New("test error"))
-
-	_, err = PromoteUser(1, 2, "moderator")
 	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
-	if err == nil {
-		t.Errorf("Error should not be nil")
+		t.Errorf("Error while checking expectations")
 	}
 }
 
-func TestPromoteUserInvalidPromoteType(t *testing.T) {
+func TestGetUserRoles(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Errorf("Error while creating sqlmock: %s", err.Error())
 	}
 	defer db.Close()
 	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	_, err = PromoteUser(1, 1, "invalid")
-	if err == nil {
-		t.Errorf("Error should not be nil")
-	}
-}
 
-func TestPromoteUserToAdminSuccess(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	_, code, err := GetUserRoles("unknown")
+	if err == nil || code != http.StatusNotFound {
+		t.Errorf("Expected not found, got %d, %v", code, err)
 	}
-	defer db.Close()
-	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("UPDATE user").WithArgs(2).WillReturnResult(sqlmock.NewResult(1, 1))
 
-	_, err = PromoteUser(1, 2, "admin")
-	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("moderator"))
+	roles, code, err := GetUserRoles("toto")
+	if err != nil || code != http.StatusOK || len(roles) != 1 || roles[0] != "moderator" {
+		t.Errorf("Unexpected result %v, %d, %v", roles, code, err)
 	}
-}
-
-func TestPromoteUserToModeratorSuccess(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Errorf("Error while creating sqlmock: %s", err.Error())
-	}
-	defer db.Close()
-	database.DB = db
-	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT COUNT").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("UPDATE user").WithArgs(2).WillReturnResult(sqlmock.NewResult(1, 1))
-
-	_, err = PromoteUser(1, 2, "moderator")
 	if mock.ExpectationsWereMet() != nil {
-		t.Errorf("Error while checking expectations: %s", err.Error())
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
+		t.Errorf("Error while checking expectations")
 	}
 }
@@ -16,11 +16,14 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// cost of the bcrypt hashes of the passwords
+const passwordHashCost = 14
+
 func encryptPassword(password string) (string, error) {
 	if os.Getenv("IS_TEST") == "true" {
 		return password, nil
 	}
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
 	if err != nil {
 		return "", err
 	}
@@ -31,7 +34,7 @@ func isUsernameValid(username string) error {
 	if len(username) < 3 || len(username) > 20 {
 		return errors.New("username must be between 3 and 20 characters")
 	}
-	usernameExists, err := database.CheckUsernameExists(username, database.DB)
+	usernameExists, err := database.GetStore().CheckUsernameExists(username)
 	if err != nil {
 		return err
 	} else if usernameExists {
@@ -70,7 +73,7 @@ func isEmailValid(email string) error {
 	if err != nil {
 		return err
 	}
-	emailExists, err := database.CheckEmailExists(email, database.DB)
+	emailExists, err := database.GetStore().CheckEmailExists(email)
 	if err != nil {
 		return err
 	} else if emailExists {
@@ -95,7 +98,8 @@ func isBirthdateValid(birthdateStr string) error {
 	return nil
 }
 
-func Register(infos models.RegisterInfos) (int64, int, error) {
+// Register creates the account and emails a verification link to its address, unless the IP address of the request or the domain of the email is banned
+func Register(infos models.RegisterInfos, ipAddress string) (int64, int, error) {
 	log.Printf("Creating user %s\n", infos.Username)
 
 	err := isUsernameValid(infos.Username)
@@ -114,17 +118,26 @@ func Register(infos models.RegisterInfos) (int64, int, error) {
 	if err != nil {
 		return 0, http.StatusBadRequest, err
 	}
+	code, err := CheckAddressNotBanned(ipAddress, infos.Email)
+	if err != nil {
+		return 0, code, err
+	}
 
 	encryptedPassword, err := encryptPassword(infos.Password)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
 
-	id, err := database.InsertUser(infos.Username, encryptedPassword, infos.Email, infos.Birthdate, database.DB)
+	id, err := database.GetStore().InsertUser(infos.Username, encryptedPassword, infos.Email, infos.Birthdate)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
 	log.Printf("User %s created with id %d\n", infos.Username, id)
+	// the account works without the email, which can be sent again
+	_, err = sendEmailVerification(int(id), infos.Email)
+	if err != nil {
+		log.Printf("Error while sending verification email to user %d: %s\n", id, err.Error())
+	}
 	return id, http.StatusCreated, nil
 }
 
@@ -164,7 +177,7 @@ func RegisterOauth(username string, email string, birthdate string) (int64, int,
 	} else {
 		displayName = username
 	}
-	id, err := database.InsertUserWithDisplayName(usernameForDb, displayName, "", email, birthdate, database.DB)
+	id, err := database.GetStore().InsertUserWithDisplayName(usernameForDb, displayName, "", email, birthdate)
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"project_truthful/client/database"
+	"project_truthful/client/token"
 	"project_truthful/models"
 	"testing"
 
@@ -121,7 +122,7 @@ func TestIsBirthdateValid(t *testing.T) {
 func TestRegisterInvalidUsername(t *testing.T) {
 	userInfos := models.RegisterInfos{Username: "us", Password: "password", Email: "email@email.fr", Birthdate: "2000-01-01"}
 
-	_, _, err := Register(userInfos)
+	_, _, err := Register(userInfos, "")
 	if err == nil {
 		t.Errorf("No error while creating user with invalid username")
 	}
@@ -140,7 +141,7 @@ func TestRegisterInvalidPassword(t *testing.T) {
 
 	userInfos := models.RegisterInfos{Username: "username", Password: "pass", Email: "email@email.fr", Birthdate: "2000-01-01"}
 
-	_, _, err = Register(userInfos)
+	_, _, err = Register(userInfos, "")
 	if err == nil {
 		t.Errorf("No error while creating user with invalid password")
 	}
@@ -159,7 +160,7 @@ func TestRegisterInvalidEmail(t *testing.T) {
 
 	userInfos := models.RegisterInfos{Username: "username", Password: "Password123@", Email: "email", Birthdate: "2000-01-01"}
 
-	_, _, err = Register(userInfos)
+	_, _, err = Register(userInfos, "")
 	if err == nil {
 		t.Errorf("No error while creating user with invalid email")
 	}
@@ -179,7 +180,7 @@ func TestRegisterInvalidBirthdate(t *testing.T) {
 
 	userInfos := models.RegisterInfos{Username: "username", Password: "Password123@", Email: "email@email.fr", Birthdate: "2025-01-01"}
 
-	_, _, err = Register(userInfos)
+	_, _, err = Register(userInfos, "")
 	if err == nil {
 		t.Errorf("No error received while creating user with invalid birthdate")
 	}
@@ -198,11 +199,17 @@ func TestRegisterValid(t *testing.T) {
 
 	mock.ExpectQuery("SELECT COUNT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT").WithArgs("email@email.fr").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	expectAddressBans(mock, models.AddressBanEmailDomain)
 	mock.ExpectExec("INSERT INTO user").WithArgs("username", "username", "Password123@", "email@email.fr", "2000-01-01").WillReturnResult(sqlmock.NewResult(4, 1))
+	// a verification link is sent to the address
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE email_verification_token SET used_at").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO email_verification_token").WithArgs(4, "email@email.fr", token.HashEmailVerificationToken("test"), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	userInfos := models.RegisterInfos{Username: "username", Password: "Password123@", Email: "email@email.fr", Birthdate: "2000-01-01"}
 
-	id, returnStatus, err := Register(userInfos)
+	id, returnStatus, err := Register(userInfos, "")
 	if err != nil {
 		t.Errorf("Error while creating user: %s", err.Error())
 	}
@@ -212,6 +219,9 @@ func TestRegisterValid(t *testing.T) {
 	if id != 4 {
 		t.Errorf("Error: id is %d instead of %d", id, 4)
 	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
 	os.Setenv("IS_TEST", "false")
 }
 
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxRemovalReasonLength = 1000
+
+// removalState is the state of a question or an answer recorded in the audit log before and after a removal
+type removalState struct {
+	Removed bool   `json:"removed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func checkRemovalReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return errors.New("reason is empty")
+	}
+	if utf8.RuneCountInString(reason) > maxRemovalReasonLength {
+		return errors.New("reason is too long")
+	}
+	return nil
+}
+
+// checkQuestionRemoval checks that the question can be removed, or restored when restore is true
+func checkQuestionRemoval(questionId int, restore bool) (int, error) {
+	removed, err := database.GetStore().CheckQuestionRemoved(questionId)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, errors.New("question not found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if removed && !restore {
+		return http.StatusBadRequest, errors.New("question has already been removed")
+	}
+	if !removed && restore {
+		return http.StatusBadRequest, errors.New("question has not been removed")
+	}
+	return http.StatusOK, nil
+}
+
+// checkAnswerRemoval checks that the answer can be removed, or restored when restore is true
+func checkAnswerRemoval(answerId int, restore bool) (int, error) {
+	removed, err := database.GetStore().CheckAnswerRemoved(answerId)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, errors.New("answer not found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if removed && !restore {
+		return http.StatusBadRequest, errors.New("answer has already been removed")
+	}
+	if !removed && restore {
+		return http.StatusBadRequest, errors.New("answer has not been removed")
+	}
+	return http.StatusOK, nil
+}
+
+// RemoveQuestion removes the question on behalf of the moderator. Unlike a deletion by its receiver,
+// the question keeps its place and is shown as removed by moderation until it is restored
+func RemoveQuestion(audit models.AuditContext, questionId int, reason string) (int, error) {
+	err := checkRemovalReason(reason)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	code, err := checkQuestionRemoval(questionId, false)
+	if err != nil {
+		return code, err
+	}
+	return inTransaction(func(store database.Store) error {
+		return removeQuestion(store, audit, questionId, reason)
+	})
+}
+
+// removeQuestion writes the removal of the question and its audit entry in the store
+func removeQuestion(store database.Store, audit models.AuditContext, questionId int, reason string) error {
+	err := store.RemoveQuestion(questionId, audit.ModeratorId, reason)
+	if err != nil {
+		return err
+	}
+	return addAuditEntry(store, audit, models.AuditQuestionRemove, models.AuditTargetQuestion, questionId,
+		removalState{Removed: false}, removalState{Removed: true, Reason: reason})
+}
+
+func RestoreQuestion(audit models.AuditContext, questionId int) (int, error) {
+	code, err := checkQuestionRemoval(questionId, true)
+	if err != nil {
+		return code, err
+	}
+	return inTransaction(func(store database.Store) error {
+		err := store.RestoreQuestion(questionId)
+		if err != nil {
+			return err
+		}
+		return addAuditEntry(store, audit, models.AuditQuestionRestore, models.AuditTargetQuestion, questionId,
+			removalState{Removed: true}, removalState{Removed: false})
+	})
+}
+
+// RemoveAnswer removes the answer on behalf of the moderator, it is shown as removed by moderation until it is restored
+func RemoveAnswer(audit models.AuditContext, answerId int, reason string) (int, error) {
+	err := checkRemovalReason(reason)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	code, err := checkAnswerRemoval(answerId, false)
+	if err != nil {
+		return code, err
+	}
+	return inTransaction(func(store database.Store) error {
+		return removeAnswer(store, audit, answerId, reason)
+	})
+}
+
+// removeAnswer writes the removal of the answer and its audit entry in the store
+func removeAnswer(store database.Store, audit models.AuditContext, answerId int, reason string) error {
+	err := store.RemoveAnswer(answerId, audit.ModeratorId, reason)
+	if err != nil {
+		return err
+	}
+	return addAuditEntry(store, audit, models.AuditAnswerRemove, models.AuditTargetAnswer, answerId,
+		removalState{Removed: false}, removalState{Removed: true, Reason: reason})
+}
+
+func RestoreAnswer(audit models.AuditContext, answerId int) (int, error) {
+	code, err := checkAnswerRemoval(answerId, true)
+	if err != nil {
+		return code, err
+	}
+	return inTransaction(func(store database.Store) error {
+		err := store.RestoreAnswer(answerId)
+		if err != nil {
+			return err
+		}
+		return addAuditEntry(store, audit, models.AuditAnswerRestore, models.AuditTargetAnswer, answerId,
+			removalState{Removed: true}, removalState{Removed: false})
+	})
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"database/sql"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRemoveQuestion(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	moderator := models.AuditContext{ModeratorId: 1, IpAddress: "1.2.3.4", UserAgent: "agent"}
+
+	for _, reason := range []string{" ", strings.Repeat("a", maxRemovalReasonLength+1)} {
+		code, err := RemoveQuestion(moderator, 2, reason)
+		if code != http.StatusBadRequest || err == nil {
+			t.Errorf("Expected %q to be refused, got %d", reason, code)
+		}
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	code, err := RemoveQuestion(moderator, 2, "spam")
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(true))
+	code, err = RemoveQuestion(moderator, 2, "spam")
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected the question to be removed once, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE question SET removed_at = CURRENT_TIMESTAMP").WithArgs(1, "spam", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "question.remove", "question", 2, `{"before":{"removed":false},"after":{"removed":true,"reason":"spam"}}`, "1.2.3.4", "agent").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	code, err = RemoveQuestion(moderator, 2, "spam")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
+	code, err = RestoreQuestion(moderator, 2)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected only removed questions to be restored, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE question SET removed_at = NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "question.restore", "question", 2, `{"before":{"removed":true},"after":{"removed":false}}`, "1.2.3.4", "agent").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	code, err = RestoreQuestion(moderator, 2)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRemoveAnswer(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	moderator := models.AuditContext{ModeratorId: 1, IpAddress: "1.2.3.4", UserAgent: "agent"}
+
+	code, err := RemoveAnswer(moderator, 3, "")
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected removals to need a reason, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM answer").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE answer SET removed_at = CURRENT_TIMESTAMP").WithArgs(1, "spam", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "answer.remove", "answer", 3, `{"before":{"removed":false},"after":{"removed":true,"reason":"spam"}}`, "1.2.3.4", "agent").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	code, err = RemoveAnswer(moderator, 3, "spam")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM answer").WithArgs(4).WillReturnError(sql.ErrNoRows)
+	code, err = RestoreAnswer(moderator, 4)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM answer").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE answer SET removed_at = NULL").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "answer.restore", "answer", 3, `{"before":{"removed":true},"after":{"removed":false}}`, "1.2.3.4", "agent").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	code, err = RestoreAnswer(moderator, 3)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
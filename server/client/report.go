@@ -0,0 +1,305 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+	"project_truthful/permissions"
+	"unicode/utf8"
+)
+
+const maxReportDetailsLength = 1000
+
+var reportTargets = map[string]bool{
+	models.ReportTargetQuestion: true,
+	models.ReportTargetAnswer:   true,
+	models.ReportTargetUser:     true,
+}
+
+var reportReasons = map[string]bool{
+	models.ReportReasonSpam:       true,
+	models.ReportReasonHarassment: true,
+	models.ReportReasonHate:       true,
+	models.ReportReasonSexual:     true,
+	models.ReportReasonSelfHarm:   true,
+	models.ReportReasonOther:      true,
+}
+
+// getReportTargetOwnerId returns the user behind the target, a 404 when the target doesn't exist or was deleted
+func getReportTargetOwnerId(targetType string, targetId int) (int, int, error) {
+	if !reportTargets[targetType] {
+		return 0, http.StatusBadRequest, errors.New("target_type must be question, answer or user")
+	}
+	ownerId, err := database.GetStore().GetReportTargetOwnerId(targetType, targetId)
+	if err == sql.ErrNoRows {
+		return 0, http.StatusNotFound, errors.New(targetType + " not found")
+	} else if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	return ownerId, http.StatusOK, nil
+}
+
+// Report flags the target for the moderators, a user can only have one open report per target
+func Report(reporterId int, infos models.ReportInfos) (int64, int, error) {
+	if !reportReasons[infos.Reason] {
+		return 0, http.StatusBadRequest, errors.New("unknown reason " + infos.Reason)
+	}
+	if utf8.RuneCountInString(infos.Details) > maxReportDetailsLength {
+		return 0, http.StatusBadRequest, errors.New("details are too long")
+	}
+	ownerId, code, err := getReportTargetOwnerId(infos.TargetType, infos.TargetId)
+	if err != nil {
+		return 0, code, err
+	}
+	if ownerId == reporterId {
+		return 0, http.StatusBadRequest, errors.New("cannot report self")
+	}
+
+	reported, err := database.GetStore().CheckOpenReportExists(reporterId, infos.TargetType, infos.TargetId)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	if reported {
+		return 0, http.StatusBadRequest, errors.New(infos.TargetType + " is already reported")
+	}
+
+	id, err := database.GetStore().AddReport(reporterId, infos.TargetType, infos.TargetId, infos.Reason, infos.Details)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	return id, http.StatusCreated, nil
+}
+
+// GetReportQueue returns a page of the targets with open reports, the most recently reported first
+func GetReportQueue(cursor string, count int) (models.ReportGroupPage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.ReportGroupPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more group is fetched to know if there is a next page
+	groups, err := database.GetStore().GetOpenReportGroups(after, count+1)
+	if err != nil {
+		return models.ReportGroupPage{}, http.StatusInternalServerError, err
+	}
+	page := models.ReportGroupPage{Groups: groups}
+	if len(groups) > count {
+		page.Groups = groups[:count]
+		last := page.Groups[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.LastReportedAt, Id: last.LastReportId})
+	}
+	return page, http.StatusOK, nil
+}
+
+func getOpenReportGroup(targetType string, targetId int) (models.ReportGroup, int, error) {
+	group, err := database.GetStore().GetOpenReportGroup(targetType, targetId)
+	if err == sql.ErrNoRows {
+		return models.ReportGroup{}, http.StatusNotFound, errors.New("no open report for this target")
+	} else if err != nil {
+		return models.ReportGroup{}, http.StatusInternalServerError, err
+	}
+	return group, http.StatusOK, nil
+}
+
+// GetTargetReports returns the open reports of the target, with the details given by the reporters
+func GetTargetReports(targetType string, targetId int) ([]models.Report, int, error) {
+	if !reportTargets[targetType] {
+		return nil, http.StatusBadRequest, errors.New("target_type must be question, answer or user")
+	}
+	reports, err := database.GetStore().GetOpenReports(targetType, targetId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if len(reports) == 0 {
+		return nil, http.StatusNotFound, errors.New("no open report for this target")
+	}
+	return reports, http.StatusOK, nil
+}
+
+// ClaimReports tells the other moderators that the moderator handles the open reports of the target,
+// or releases them. Reports claimed by another moderator can't be taken over
+func ClaimReports(moderatorId int, targetType string, targetId int, claim bool) (int, error) {
+	group, code, err := getOpenReportGroup(targetType, targetId)
+	if err != nil {
+		return code, err
+	}
+	if group.ClaimedBy != 0 && group.ClaimedBy != moderatorId {
+		return http.StatusForbidden, errors.New("reports are claimed by another moderator")
+	}
+
+	claimer := 0
+	if claim {
+		claimer = moderatorId
+	}
+	err = database.GetStore().ClaimReports(targetType, targetId, claimer)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// resolutionRecord is the resolution recorded in the audit log
+type resolutionRecord struct {
+	ResolutionId int    `json:"resolution_id"`
+	Action       string `json:"action"`
+	Note         string `json:"note"`
+	BanId        int    `json:"ban_id,omitempty"`
+	ReportCount  int    `json:"report_count"`
+}
+
+// errAlreadyResolved rolls back the ban or the removal of a target whose reports were resolved by another moderator first
+var errAlreadyResolved = errors.New("reports have already been resolved")
+
+// ResolveReports closes every open report of the target after dismissing them, removing the reported content
+// or banning its owner. Removing and banning need their own permissions, the ban returned is the one of the owner
+func ResolveReports(audit models.AuditContext, infos models.ResolveReportsInfos) (models.ReportResolution, int, error) {
+	moderatorId := audit.ModeratorId
+	if infos.Action != models.ReportActionDismiss && infos.Action != models.ReportActionDelete && infos.Action != models.ReportActionBan {
+		return models.ReportResolution{}, http.StatusBadRequest, errors.New("action must be dismiss, delete or ban")
+	}
+	if utf8.RuneCountInString(infos.Note) > maxReportDetailsLength {
+		return models.ReportResolution{}, http.StatusBadRequest, errors.New("note is too long")
+	}
+	group, code, err := getOpenReportGroup(infos.TargetType, infos.TargetId)
+	if err != nil {
+		return models.ReportResolution{}, code, err
+	}
+	if group.ClaimedBy != 0 && group.ClaimedBy != moderatorId {
+		return models.ReportResolution{}, http.StatusForbidden, errors.New("reports are claimed by another moderator")
+	}
+
+	var ownerId int
+	switch infos.Action {
+	case models.ReportActionDelete:
+		code, err = checkReportTargetRemoval(moderatorId, infos)
+	case models.ReportActionBan:
+		ownerId, code, err = checkReportTargetOwnerBan(moderatorId, infos)
+	}
+	if err != nil {
+		return models.ReportResolution{}, code, err
+	}
+
+	// the ban or the removal is rolled back with the resolution
+	resolution := models.ReportResolution{TargetType: infos.TargetType, TargetId: infos.TargetId, ModeratorId: moderatorId, Action: infos.Action, Note: infos.Note}
+	code, err = inTransaction(func(store database.Store) error {
+		switch infos.Action {
+		case models.ReportActionDelete:
+			err := removeReportTarget(store, audit, infos)
+			if err != nil {
+				return err
+			}
+		case models.ReportActionBan:
+			reason := infos.Note
+			if reason == "" {
+				reason = fmt.Sprintf("reported %s %d", infos.TargetType, infos.TargetId)
+			}
+			banId, err := banUser(store, audit, ownerId, infos.Duration, reason)
+			if err != nil {
+				return err
+			}
+			resolution.BanId = int(banId)
+		}
+
+		id, count, err := store.ResolveReports(resolution)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errAlreadyResolved
+		}
+		resolution.Id = int(id)
+		resolution.ReportCount = count
+		return addAuditEntry(store, audit, models.AuditReportsResolve, resolution.TargetType, resolution.TargetId, nil,
+			resolutionRecord{ResolutionId: resolution.Id, Action: resolution.Action, Note: resolution.Note, BanId: resolution.BanId, ReportCount: resolution.ReportCount})
+	})
+	if err == errAlreadyResolved {
+		return models.ReportResolution{}, http.StatusBadRequest, err
+	} else if err != nil {
+		return models.ReportResolution{}, code, err
+	}
+	return resolution, http.StatusOK, nil
+}
+
+// checkReportTargetRemoval checks that the moderator can remove the reported content
+func checkReportTargetRemoval(moderatorId int, infos models.ResolveReportsInfos) (int, error) {
+	allowed, err := permissions.HasPermission(moderatorId, permissions.RemoveContent)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !allowed {
+		return http.StatusForbidden, errors.New("missing permission " + permissions.RemoveContent)
+	}
+	switch infos.TargetType {
+	case models.ReportTargetQuestion:
+		return checkQuestionRemoval(infos.TargetId, false)
+	case models.ReportTargetAnswer:
+		return checkAnswerRemoval(infos.TargetId, false)
+	}
+	return http.StatusBadRequest, errors.New("users can't be removed, ban them instead")
+}
+
+// removeReportTarget removes the reported content, the note of the moderator is the reason of the removal
+func removeReportTarget(store database.Store, audit models.AuditContext, infos models.ResolveReportsInfos) error {
+	reason := infos.Note
+	if reason == "" {
+		reason = "reported " + infos.TargetType
+	}
+	if infos.TargetType == models.ReportTargetQuestion {
+		return removeQuestion(store, audit, infos.TargetId, reason)
+	}
+	return removeAnswer(store, audit, infos.TargetId, reason)
+}
+
+// checkReportTargetOwnerBan checks that the moderator can ban the reported user, or the author of the reported content.
+// It returns the user to ban
+func checkReportTargetOwnerBan(moderatorId int, infos models.ResolveReportsInfos) (int, int, error) {
+	allowed, err := permissions.HasPermission(moderatorId, permissions.BanUsers)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	if !allowed {
+		return 0, http.StatusForbidden, errors.New("missing permission " + permissions.BanUsers)
+	}
+	ownerId, code, err := getReportTargetOwnerId(infos.TargetType, infos.TargetId)
+	if err != nil {
+		return 0, code, err
+	}
+	if ownerId == 0 {
+		return 0, http.StatusBadRequest, errors.New("author of the question was not logged in")
+	}
+	code, err = checkBan(ownerId, moderatorId)
+	if err != nil {
+		return 0, code, err
+	}
+	return ownerId, http.StatusOK, nil
+}
+
+// GetReportResolutions returns a page of what the moderators did about the reports, most recent first.
+// Only the resolutions of the target are returned when targetType is not empty
+func GetReportResolutions(targetType string, targetId int, cursor string, count int) (models.ReportResolutionPage, int, error) {
+	if targetType != "" && !reportTargets[targetType] {
+		return models.ReportResolutionPage{}, http.StatusBadRequest, errors.New("target_type must be question, answer or user")
+	}
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.ReportResolutionPage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	resolutions, err := database.GetStore().GetReportResolutions(targetType, targetId, after, count+1)
+	if err != nil {
+		return models.ReportResolutionPage{}, http.StatusInternalServerError, err
+	}
+	page := models.ReportResolutionPage{Resolutions: resolutions}
+	if len(resolutions) > count {
+		page.Resolutions = resolutions[:count]
+		last := page.Resolutions[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
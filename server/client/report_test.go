@@ -0,0 +1,172 @@
+package client
+
+import (
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectReportGroup(mock sqlmock.Sqlmock, targetType string, targetId int, claimedBy any) {
+	mock.ExpectQuery("SELECT (.+) FROM report WHERE resolution_id IS NULL AND target_type").WithArgs(targetType, targetId).
+		WillReturnRows(sqlmock.NewRows([]string{"target_type", "target_id", "report_count", "reasons", "claimed_by", "first_reported_at", "last_reported_at", "last_report_id"}).
+			AddRow(targetType, targetId, 2, "spam", claimedBy, time.Now(), time.Now(), 2))
+}
+
+func TestReport(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	invalid := []models.ReportInfos{
+		{TargetType: "user", TargetId: 2, Reason: "boring"},
+		{TargetType: "post", TargetId: 2, Reason: "spam"},
+		{TargetType: "user", TargetId: 2, Reason: "spam", Details: strings.Repeat("a", maxReportDetailsLength+1)},
+	}
+	for _, infos := range invalid {
+		_, code, err := Report(1, infos)
+		if code != http.StatusBadRequest || err == nil {
+			t.Errorf("Expected %+v to be refused, got %d", infos, code)
+		}
+	}
+
+	mock.ExpectQuery("SELECT author_id FROM question").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"author_id"}))
+	_, code, err := Report(1, models.ReportInfos{TargetType: "question", TargetId: 2, Reason: "spam"})
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT id FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, code, err = Report(1, models.ReportInfos{TargetType: "user", TargetId: 1, Reason: "spam"})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected users not to report themselves, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM report").WithArgs(1, "answer", 3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	_, code, err = Report(1, models.ReportInfos{TargetType: "answer", TargetId: 3, Reason: "spam"})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected a second open report to be refused, got %d", code)
+	}
+
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM report").WithArgs(1, "answer", 3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO report").WithArgs(1, "answer", 3, "spam", "details").WillReturnResult(sqlmock.NewResult(4, 1))
+	id, code, err := Report(1, models.ReportInfos{TargetType: "answer", TargetId: 3, Reason: "spam", Details: "details"})
+	if code != http.StatusCreated || err != nil || id != 4 {
+		t.Errorf("Expected report 4 to be created, got %d, %d and %v", id, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestClaimReports(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM report WHERE resolution_id IS NULL AND target_type").WithArgs("user", 2).WillReturnRows(sqlmock.NewRows([]string{"target_type"}))
+	code, err := ClaimReports(1, "user", 2, true)
+	if code != http.StatusNotFound || err == nil {
+		t.Errorf("Expected http.StatusNotFound, got %d", code)
+	}
+
+	expectReportGroup(mock, "user", 2, 3)
+	code, err = ClaimReports(1, "user", 2, false)
+	if code != http.StatusForbidden || err == nil {
+		t.Errorf("Expected the reports of another moderator to be refused, got %d", code)
+	}
+
+	expectReportGroup(mock, "user", 2, nil)
+	mock.ExpectExec("UPDATE report SET claimed_by = \\?").WithArgs(1, "user", 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	code, err = ClaimReports(1, "user", 2, true)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestResolveReports(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	moderator := models.AuditContext{ModeratorId: 1}
+
+	_, code, err := ResolveReports(moderator, models.ResolveReportsInfos{TargetType: "user", TargetId: 2, Action: "warn"})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected unknown actions to be refused, got %d", code)
+	}
+
+	expectReportGroup(mock, "user", 2, nil)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, "content.remove").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	_, code, err = ResolveReports(moderator, models.ResolveReportsInfos{TargetType: "user", TargetId: 2, Action: "delete"})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected users not to be deleted, got %d", code)
+	}
+
+	expectReportGroup(mock, "question", 2, nil)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, "users.ban").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	_, code, err = ResolveReports(moderator, models.ResolveReportsInfos{TargetType: "question", TargetId: 2, Action: "ban"})
+	if code != http.StatusForbidden || err == nil {
+		t.Errorf("Expected bans to need the permission, got %d", code)
+	}
+
+	expectReportGroup(mock, "question", 2, nil)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, "users.ban").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT author_id FROM question").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"author_id"}).AddRow(nil))
+	_, code, err = ResolveReports(moderator, models.ResolveReportsInfos{TargetType: "question", TargetId: 2, Action: "ban"})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected guests not to be banned, got %d", code)
+	}
+
+	expectReportGroup(mock, "answer", 3, 1)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, "content.remove").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM answer").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE answer SET removed_at = CURRENT_TIMESTAMP").WithArgs(1, "spam", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "answer.remove", "answer", 3, sqlmock.AnyArg(), "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO report_resolution").WithArgs("answer", 3, 1, "delete", "spam", nil).WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec("UPDATE report SET resolution_id").WithArgs(5, "answer", 3).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE report_resolution SET report_count").WithArgs(2, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "reports.resolve", "answer", 3,
+		`{"after":{"resolution_id":5,"action":"delete","note":"spam","report_count":2}}`, "", "").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	resolution, code, err := ResolveReports(moderator, models.ResolveReportsInfos{TargetType: "answer", TargetId: 3, Action: "delete", Note: "spam"})
+	if code != http.StatusOK || err != nil || resolution.Id != 5 || resolution.ReportCount != 2 {
+		t.Errorf("Expected the answer to be removed, got %+v, %d and %v", resolution, code, err)
+	}
+
+	// the removal is rolled back when another moderator resolved the reports first
+	expectReportGroup(mock, "answer", 3, nil)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, "content.remove").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM answer").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE answer SET removed_at = CURRENT_TIMESTAMP").WithArgs(1, "spam", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT INTO report_resolution").WillReturnResult(sqlmock.NewResult(6, 1))
+	mock.ExpectExec("UPDATE report SET resolution_id").WithArgs(6, "answer", 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	_, code, err = ResolveReports(moderator, models.ResolveReportsInfos{TargetType: "answer", TargetId: 3, Action: "delete", Note: "spam"})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected the reports to be resolved once, got %d and %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/models"
+	"time"
+)
+
+// userAgentLength is the length of the user agent columns, in characters
+const userAgentLength = 255
+
+// truncateUserAgent shortens the user agent to the length of its columns, without cutting a character
+func truncateUserAgent(userAgent string) string {
+	runes := []rune(userAgent)
+	if len(runes) <= userAgentLength {
+		return userAgent
+	}
+	return string(runes[:userAgentLength])
+}
+
+func issueRefreshToken(sessionId string, userId int, ipAddress string, userAgent string) (string, error) {
+	refreshToken, err := token.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	userAgent = truncateUserAgent(userAgent)
+	expiresAt := time.Now().Add(token.RefreshTokenDuration)
+	_, err = database.GetStore().InsertRefreshToken(sessionId, userId, token.HashRefreshToken(refreshToken), ipAddress, userAgent, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// CreateSession starts a new session for the user and returns its first pair of tokens
+func CreateSession(userId int, ipAddress string, userAgent string) (models.AuthTokens, int, error) {
+	sessionId, err := token.GenerateSessionId()
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	refreshToken, err := issueRefreshToken(sessionId, userId, ipAddress, userAgent)
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	accessToken, err := token.GenerateJWT(userId)
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	return models.AuthTokens{AccessToken: accessToken, RefreshToken: refreshToken}, http.StatusOK, nil
+}
+
+// RefreshSession exchanges a refresh token for a new pair of tokens.
+// A refresh token can only be used once: presenting it again revokes the whole session
+func RefreshSession(refreshToken string, ipAddress string, userAgent string) (models.AuthTokens, int, error) {
+	current, err := database.GetStore().GetRefreshTokenByHash(token.HashRefreshToken(refreshToken))
+	if err == sql.ErrNoRows {
+		return models.AuthTokens{}, http.StatusUnauthorized, errors.New("invalid refresh token")
+	} else if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+
+	if current.IsRevoked {
+		return models.AuthTokens{}, http.StatusUnauthorized, errors.New("session has been revoked")
+	}
+	if current.IsRotated {
+		log.Printf("Refresh token reuse detected for session %s of user %d, revoking session\n", current.SessionId, current.UserId)
+		err = database.GetStore().RevokeSession(current.SessionId)
+		if err != nil {
+			return models.AuthTokens{}, http.StatusInternalServerError, err
+		}
+		return models.AuthTokens{}, http.StatusUnauthorized, errors.New("refresh token has already been used, session revoked")
+	}
+	if current.ExpiresAt.Before(time.Now()) {
+		return models.AuthTokens{}, http.StatusUnauthorized, errors.New("refresh token expired")
+	}
+
+	code, err := CheckUserNotBanned(current.UserId)
+	if err != nil {
+		return models.AuthTokens{}, code, err
+	}
+
+	// if another request rotated the token in the meantime, it is a reuse as well
+	rotated, err := database.GetStore().MarkRefreshTokenAsRotated(current.Id)
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	if !rotated {
+		log.Printf("Concurrent refresh token reuse detected for session %s of user %d, revoking session\n", current.SessionId, current.UserId)
+		err = database.GetStore().RevokeSession(current.SessionId)
+		if err != nil {
+			return models.AuthTokens{}, http.StatusInternalServerError, err
+		}
+		return models.AuthTokens{}, http.StatusUnauthorized, errors.New("refresh token has already been used, session revoked")
+	}
+
+	newRefreshToken, err := issueRefreshToken(current.SessionId, current.UserId, ipAddress, userAgent)
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	accessToken, err := token.GenerateJWT(current.UserId)
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	return models.AuthTokens{AccessToken: accessToken, RefreshToken: newRefreshToken}, http.StatusOK, nil
+}
+
+// Logout revokes the session the refresh token belongs to
+func Logout(userId int, refreshToken string) (int, error) {
+	current, err := database.GetStore().GetRefreshTokenByHash(token.HashRefreshToken(refreshToken))
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, errors.New("session not found")
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if current.UserId != userId {
+		return http.StatusNotFound, errors.New("session not found")
+	}
+
+	err = database.GetStore().RevokeSession(current.SessionId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func GetSessions(userId int) ([]models.Session, int, error) {
+	sessions, err := database.GetStore().GetActiveSessions(userId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return sessions, http.StatusOK, nil
+}
+
+func RevokeSession(userId int, sessionId string) (int, error) {
+	revoked, err := database.GetStore().RevokeUserSession(userId, sessionId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !revoked {
+		return http.StatusNotFound, errors.New("session not found")
+	}
+	return http.StatusOK, nil
+}
+
+func RevokeAllSessions(userId int) (int, error) {
+	err := database.GetStore().RevokeAllUserSessions(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var refreshTokenColumns = []string{"id", "session_id", "user_id", "expires_at", "rotated", "revoked"}
+
+func TestCreateSession(t *testing.T) {
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 1, token.HashRefreshToken("test"), "127.0.0.1", "agent", sqlmock.AnyArg()).WillReturnError(errors.New("error"))
+	_, code, err := CreateSession(1, "127.0.0.1", "agent")
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error, got %d, %v", code, err)
+	}
+
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 1, token.HashRefreshToken("test"), "127.0.0.1", "agent", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	tokens, code, err := CreateSession(1, "127.0.0.1", "agent")
+	if err != nil || code != http.StatusOK {
+		t.Errorf("Expected success, got %d, %v", code, err)
+	}
+	if tokens.AccessToken != "test" || tokens.RefreshToken != "test" {
+		t.Errorf("Unexpected tokens %+v", tokens)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestTruncateUserAgent(t *testing.T) {
+	if userAgent := truncateUserAgent("agent"); userAgent != "agent" {
+		t.Errorf("Expected the short user agent to be kept, got %q", userAgent)
+	}
+	userAgent := truncateUserAgent(strings.Repeat("é", 300))
+	if !utf8.ValidString(userAgent) {
+		t.Errorf("Expected a valid UTF-8 user agent, got %q", userAgent)
+	}
+	if count := utf8.RuneCountInString(userAgent); count != userAgentLength {
+		t.Errorf("Expected %d characters, got %d", userAgentLength, count)
+	}
+}
+
+func TestRefreshSessionInvalid(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+	hash := token.HashRefreshToken("refresh")
+
+	// unknown token
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnError(sql.ErrNoRows)
+	_, code, err := RefreshSession("refresh", "127.0.0.1", "agent")
+	if err == nil || code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized, got %d, %v", code, err)
+	}
+
+	// database error
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnError(errors.New("error"))
+	_, code, err = RefreshSession("refresh", "127.0.0.1", "agent")
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error, got %d, %v", code, err)
+	}
+
+	// revoked session
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 1, time.Now().Add(time.Hour), false, true))
+	_, code, err = RefreshSession("refresh", "127.0.0.1", "agent")
+	if err == nil || code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized, got %d, %v", code, err)
+	}
+
+	// expired token
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 1, time.Now().Add(-time.Hour), false, false))
+	_, code, err = RefreshSession("refresh", "127.0.0.1", "agent")
+	if err == nil || code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRefreshSessionReuseRevokesSession(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+	hash := token.HashRefreshToken("refresh")
+
+	// token already rotated
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 1, time.Now().Add(time.Hour), true, false))
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 2))
+	_, code, err := RefreshSession("refresh", "127.0.0.1", "agent")
+	if err == nil || code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized, got %d, %v", code, err)
+	}
+
+	// token rotated by a concurrent request
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 1, time.Now().Add(time.Hour), false, false))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("UPDATE session SET rotated_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 2))
+	_, code, err = RefreshSession("refresh", "127.0.0.1", "agent")
+	if err == nil || code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRefreshSessionSuccess(t *testing.T) {
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+	hash := token.HashRefreshToken("refresh")
+
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 4, time.Now().Add(time.Hour), false, false))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(4).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("UPDATE session SET rotated_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO session").WithArgs("session", 4, token.HashRefreshToken("test"), "127.0.0.1", "agent", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+	tokens, code, err := RefreshSession("refresh", "127.0.0.1", "agent")
+	if err != nil || code != http.StatusOK {
+		t.Errorf("Expected success, got %d, %v", code, err)
+	}
+	if tokens.AccessToken != "test" || tokens.RefreshToken != "test" {
+		t.Errorf("Unexpected tokens %+v", tokens)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRefreshSessionBannedUser(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+	hash := token.HashRefreshToken("refresh")
+
+	expiresAt := time.Now().Add(time.Hour)
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 4, time.Now().Add(time.Hour), false, false))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"id", "reason", "created_at", "expires_at"}).AddRow(2, "spam", time.Now(), expiresAt))
+	_, code, err := RefreshSession("refresh", "127.0.0.1", "agent")
+	if code != http.StatusForbidden {
+		t.Errorf("Expected forbidden, got %d, %v", code, err)
+	}
+	var bannedErr *UserBannedError
+	if !errors.As(err, &bannedErr) || bannedErr.Ban.Id != 2 || bannedErr.Ban.IsPermanent {
+		t.Errorf("Expected a temporary ban error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestLogout(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+	hash := token.HashRefreshToken("refresh")
+
+	// unknown token
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnError(sql.ErrNoRows)
+	code, err := Logout(1, "refresh")
+	if err == nil || code != http.StatusNotFound {
+		t.Errorf("Expected not found, got %d, %v", code, err)
+	}
+
+	// token of another user
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 2, time.Now().Add(time.Hour), false, false))
+	code, err = Logout(1, "refresh")
+	if err == nil || code != http.StatusNotFound {
+		t.Errorf("Expected not found, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(hash).WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(1, "session", 1, time.Now().Add(time.Hour), false, false))
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 1))
+	code, err = Logout(1, "refresh")
+	if err != nil || code != http.StatusOK {
+		t.Errorf("Expected success, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer database.DB.Close()
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session", 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	code, err := RevokeSession(1, "session")
+	if err == nil || code != http.StatusNotFound {
+		t.Errorf("Expected not found, got %d, %v", code, err)
+	}
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	code, err = RevokeSession(1, "session")
+	if err != nil || code != http.StatusOK {
+		t.Errorf("Expected success, got %d, %v", code, err)
+	}
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs(1).WillReturnError(errors.New("error"))
+	code, err = RevokeAllSessions(1)
+	if err == nil || code != http.StatusInternalServerError {
+		t.Errorf("Expected internal server error, got %d, %v", code, err)
+	}
+
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	code, err = RevokeAllSessions(1)
+	if err != nil || code != http.StatusOK {
+		t.Errorf("Expected success, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/captcha"
+	"project_truthful/client/database"
+	"project_truthful/models"
+)
+
+// codes of the questions refused by the settings of the receiver, for the front-end to explain why
+const (
+	InboxPaused                = "inbox_paused"
+	LoginRequired              = "login_required"
+	FollowingOnly              = "following_only"
+	AnonymousQuestionsDisabled = "anonymous_questions_disabled"
+	CaptchaRequired            = "captcha_required"
+	CaptchaInvalid             = "captcha_invalid"
+)
+
+// InboxError is returned when the settings of the receiver refuse a question, Code tells which setting did
+type InboxError struct {
+	Code    string
+	message string
+}
+
+func (e *InboxError) Error() string {
+	return e.message
+}
+
+func GetUserSettings(userId int) (models.UserSettings, int, error) {
+	settings, err := database.GetStore().GetUserSettings(userId)
+	if err != nil {
+		return models.UserSettings{}, http.StatusInternalServerError, err
+	}
+	return settings, http.StatusOK, nil
+}
+
+// UpdateUserSettings changes the given settings only and returns all the settings of the user
+func UpdateUserSettings(userId int, infos models.UpdateUserSettingsInfos) (models.UserSettings, int, error) {
+	settings, err := database.GetStore().GetUserSettings(userId)
+	if err != nil {
+		return models.UserSettings{}, http.StatusInternalServerError, err
+	}
+	if infos.AllowAnonymousQuestions != nil {
+		settings.AllowAnonymousQuestions = *infos.AllowAnonymousQuestions
+	}
+	if infos.GuestQuestions != nil {
+		switch *infos.GuestQuestions {
+		case models.GuestQuestionsAllowed, models.GuestQuestionsDisabled:
+		case models.GuestQuestionsCaptcha:
+			if !captcha.Enabled() {
+				return models.UserSettings{}, http.StatusBadRequest, captcha.ErrNotConfigured
+			}
+		default:
+			return models.UserSettings{}, http.StatusBadRequest, errors.New("guest_questions must be allowed, captcha or disabled")
+		}
+		settings.GuestQuestions = *infos.GuestQuestions
+	}
+	if infos.FollowingOnly != nil {
+		settings.FollowingOnly = *infos.FollowingOnly
+	}
+	if infos.InboxPaused != nil {
+		settings.InboxPaused = *infos.InboxPaused
+	}
+
+	err = database.GetStore().UpdateUserSettings(userId, settings)
+	if err != nil {
+		return models.UserSettings{}, http.StatusInternalServerError, err
+	}
+	return settings, http.StatusOK, nil
+}
+
+// checkInboxSettings returns an InboxError when the settings of the receiver refuse the question.
+// The captcha is verified last, only once every other setting accepts the question
+func checkInboxSettings(receiverId int, authorId int, isAuthorAnonymous bool, captchaToken string, authorIpAddress string) (int, error) {
+	// users can always ask themselves
+	if authorId == receiverId {
+		return http.StatusOK, nil
+	}
+	settings, err := database.GetStore().GetUserSettings(receiverId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isGuest := authorId == 0
+
+	if settings.InboxPaused {
+		return http.StatusForbidden, &InboxError{Code: InboxPaused, message: "user doesn't accept questions for now"}
+	}
+	if isGuest && (settings.FollowingOnly || !settings.AllowAnonymousQuestions || settings.GuestQuestions == models.GuestQuestionsDisabled) {
+		return http.StatusUnauthorized, &InboxError{Code: LoginRequired, message: "user only accepts questions from logged in users"}
+	}
+	if settings.FollowingOnly {
+		follows, err := database.GetStore().CheckFollowExists(receiverId, authorId)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !follows {
+			return http.StatusForbidden, &InboxError{Code: FollowingOnly, message: "user only accepts questions from the users they follow"}
+		}
+	}
+	if isAuthorAnonymous && !settings.AllowAnonymousQuestions {
+		return http.StatusForbidden, &InboxError{Code: AnonymousQuestionsDisabled, message: "user doesn't accept anonymous questions"}
+	}
+
+	if isGuest && settings.GuestQuestions == models.GuestQuestionsCaptcha {
+		if captchaToken == "" {
+			return http.StatusBadRequest, &InboxError{Code: CaptchaRequired, message: "user asks logged out visitors to solve a captcha"}
+		}
+		valid, err := captcha.Verify(captchaToken, authorIpAddress)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !valid {
+			return http.StatusBadRequest, &InboxError{Code: CaptchaInvalid, message: "captcha is invalid"}
+		}
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"project_truthful/client/database"
+	"project_truthful/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var settingsColumns = []string{"allow_anonymous_questions", "guest_questions", "following_only", "inbox_paused"}
+
+func expectSettings(mock sqlmock.Sqlmock, userId int, settings models.UserSettings) {
+	mock.ExpectQuery("SELECT (.+) FROM user_settings").WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows(settingsColumns).AddRow(settings.AllowAnonymousQuestions, settings.GuestQuestions, settings.FollowingOnly, settings.InboxPaused))
+}
+
+func TestUpdateUserSettings(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+
+	unknown := "sometimes"
+	mock.ExpectQuery("SELECT (.+) FROM user_settings").WithArgs(1).WillReturnRows(sqlmock.NewRows(settingsColumns))
+	_, code, err := UpdateUserSettings(1, models.UpdateUserSettingsInfos{GuestQuestions: &unknown})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected an unknown guest_questions to be refused, got %d", code)
+	}
+
+	os.Setenv("CAPTCHA_SECRET", "")
+	captchaSetting := models.GuestQuestionsCaptcha
+	mock.ExpectQuery("SELECT (.+) FROM user_settings").WithArgs(1).WillReturnRows(sqlmock.NewRows(settingsColumns))
+	_, code, err = UpdateUserSettings(1, models.UpdateUserSettingsInfos{GuestQuestions: &captchaSetting})
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected captchas to be refused when not configured, got %d", code)
+	}
+
+	// the settings that are not given are kept
+	paused := true
+	expectSettings(mock, 1, models.UserSettings{GuestQuestions: models.GuestQuestionsDisabled, FollowingOnly: true})
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO user_settings").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE user_settings SET").WithArgs(false, "disabled", true, true, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	settings, code, err := UpdateUserSettings(1, models.UpdateUserSettingsInfos{InboxPaused: &paused})
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+	expected := models.UserSettings{GuestQuestions: models.GuestQuestionsDisabled, FollowingOnly: true, InboxPaused: true}
+	if settings != expected {
+		t.Errorf("Expected %+v, got %+v", expected, settings)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestCheckInboxSettings(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	database.DB, mock, err = sqlmock.New()
+	if err != nil {
+		t.Errorf("Error while creating sqlmock: %s", err.Error())
+	}
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+
+	// users asking themselves are never refused
+	code, err := checkInboxSettings(1, 1, true, "", "ip_address")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected http.StatusOK, got %d and %v", code, err)
+	}
+
+	tests := []struct {
+		name              string
+		settings          models.UserSettings
+		authorId          int
+		isAuthorAnonymous bool
+		captchaToken      string
+		// number of follows of the author by the receiver, -1 when they are not checked
+		follows   int
+		code      int
+		errorCode string
+	}{
+		{"paused", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "allowed", InboxPaused: true}, 2, false, "", -1, http.StatusForbidden, InboxPaused},
+		{"guests disabled", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "disabled"}, 0, true, "", -1, http.StatusUnauthorized, LoginRequired},
+		{"guest when anonymous disabled", models.UserSettings{GuestQuestions: "allowed"}, 0, true, "", -1, http.StatusUnauthorized, LoginRequired},
+		{"guest when following only", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "allowed", FollowingOnly: true}, 0, true, "", -1, http.StatusUnauthorized, LoginRequired},
+		{"not followed", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "allowed", FollowingOnly: true}, 2, false, "", 0, http.StatusForbidden, FollowingOnly},
+		{"anonymous disabled", models.UserSettings{GuestQuestions: "allowed"}, 2, true, "", -1, http.StatusForbidden, AnonymousQuestionsDisabled},
+		{"missing captcha", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "captcha"}, 0, true, "", -1, http.StatusBadRequest, CaptchaRequired},
+		{"invalid captcha", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "captcha"}, 0, true, "wrong", -1, http.StatusBadRequest, CaptchaInvalid},
+		{"valid captcha", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "captcha"}, 0, true, "test", -1, http.StatusOK, ""},
+		{"captcha not asked to users", models.UserSettings{AllowAnonymousQuestions: true, GuestQuestions: "captcha"}, 2, true, "", -1, http.StatusOK, ""},
+		{"followed", models.UserSettings{GuestQuestions: "disabled", FollowingOnly: true}, 2, false, "", 1, http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		expectSettings(mock, 1, test.settings)
+		if test.follows >= 0 {
+			mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, test.authorId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(test.follows))
+		}
+		code, err := checkInboxSettings(1, test.authorId, test.isAuthorAnonymous, test.captchaToken, "ip_address")
+		if code != test.code {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.code, code)
+		}
+		var inboxErr *InboxError
+		if test.errorCode == "" && err != nil {
+			t.Errorf("%s: expected no error, got %v", test.name, err)
+		} else if test.errorCode != "" && (!errors.As(err, &inboxErr) || inboxErr.Code != test.errorCode) {
+			t.Errorf("%s: expected error code %s, got %v", test.name, test.errorCode, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/models"
+	"time"
+)
+
+// a ticket is exchanged right away for a stream, it doesn't need to live longer
+const streamTicketDuration = 30 * time.Second
+
+var errInvalidStreamTicket = errors.New("invalid or expired stream ticket")
+
+// CreateStreamTicket returns a ticket opening one event stream of the user, so that the access token stays out of the URL.
+// The stream ends at streamExpiresAt, when the access token the ticket is created with expires
+func CreateStreamTicket(userId int, streamExpiresAt time.Time) (models.StreamTicketInfos, int, error) {
+	ticket, err := token.GenerateStreamTicket()
+	if err != nil {
+		return models.StreamTicketInfos{}, http.StatusInternalServerError, err
+	}
+	err = database.GetStore().AddStreamTicket(userId, token.HashStreamTicket(ticket), time.Now().Add(streamTicketDuration), streamExpiresAt)
+	if err != nil {
+		return models.StreamTicketInfos{}, http.StatusInternalServerError, err
+	}
+	return models.StreamTicketInfos{Ticket: ticket, ExpiresIn: int(streamTicketDuration.Seconds())}, http.StatusOK, nil
+}
+
+// UseStreamTicket returns the user a ticket opens a stream for and when the stream ends. A ticket opens a single stream
+func UseStreamTicket(ticket string) (int, time.Time, int, error) {
+	ticketInfos, err := database.GetStore().GetStreamTicket(token.HashStreamTicket(ticket))
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, http.StatusUnauthorized, errInvalidStreamTicket
+	} else if err != nil {
+		return 0, time.Time{}, http.StatusInternalServerError, err
+	}
+	if ticketInfos.IsUsed || ticketInfos.ExpiresAt.Before(time.Now()) {
+		return 0, time.Time{}, http.StatusUnauthorized, errInvalidStreamTicket
+	}
+	used, err := database.GetStore().UseStreamTicket(ticketInfos.Id)
+	if err != nil {
+		return 0, time.Time{}, http.StatusInternalServerError, err
+	}
+	if !used {
+		return 0, time.Time{}, http.StatusUnauthorized, errInvalidStreamTicket
+	}
+	return ticketInfos.UserId, ticketInfos.StreamExpiresAt, http.StatusOK, nil
+}
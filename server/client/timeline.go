@@ -0,0 +1,30 @@
+package client
+
+import (
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+)
+
+// GetTimeline returns a page of the answers of the users followed by the user, newest first
+func GetTimeline(userId int, cursor string, count int) (models.TimelinePage, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.TimelinePage{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more answer is fetched to know if there is a next page
+	answers, err := database.GetStore().GetTimeline(userId, after, count+1)
+	if err != nil {
+		return models.TimelinePage{}, http.StatusInternalServerError, err
+	}
+	page := models.TimelinePage{Answers: answers}
+	if len(answers) > count {
+		page.Answers = answers[:count]
+		last := page.Answers[count-1]
+		page.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return page, http.StatusOK, nil
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetTimeline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	database.DB = db
+	if err != nil {
+		t.Fatalf("Error while creating mock: %s", err.Error())
+	}
+	pagination.SetSecret("secret")
+
+	_, status, err := GetTimeline(1, "forged", 10)
+	if err != pagination.ErrInvalidCursor || status != http.StatusBadRequest {
+		t.Errorf("Expected invalid cursor with status 400, got %v and %d", err, status)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM timeline_entry").WithArgs(1, 1, 11).WillReturnError(errors.New("error while getting timeline"))
+	_, status, err = GetTimeline(1, "", 0)
+	if err == nil || status != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %v and %d", err, status)
+	}
+
+	// one more answer than asked means there is a next page
+	creationTime := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	cursor := pagination.Encode(models.Cursor{CreatedAt: creationTime, Id: 9})
+	// the questions and the likes are read with the page
+	rows := sqlmock.NewRows([]string{"id", "text", "created_at", "removed", "id", "username", "display_name", "question_text", "is_author_anonymous", "question_removed",
+		"author_id", "author_username", "author_display_name", "like_count", "liked"}).
+		AddRow(8, "answer", creationTime, false, 2, "titi", "Titi", "question", true, false, nil, nil, nil, 0, false).
+		AddRow(7, "answer", creationTime, false, 3, "tata", "Tata", "question", true, false, nil, nil, nil, 0, false)
+	mock.ExpectQuery("SELECT (.+) FROM timeline_entry").WithArgs(1, 1, creationTime, creationTime, 9, 2).WillReturnRows(rows)
+	page, status, err := GetTimeline(1, cursor, 1)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v and %d", err, status)
+	}
+	if len(page.Answers) != 1 || page.Answers[0].Id != 8 {
+		t.Errorf("Expected only answer 8, got %+v", page.Answers)
+	}
+	next, err := pagination.Decode(page.NextCursor)
+	if err != nil || next.Id != 8 {
+		t.Errorf("Expected next cursor to point at answer 8, got %+v, %v", next, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
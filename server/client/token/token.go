@@ -2,13 +2,19 @@ package token
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"project_truthful/models"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +25,10 @@ import (
 var jwtPublicKey *rsa.PublicKey
 var jwtPrivateKey *rsa.PrivateKey
 
+// access tokens are short-lived, sessions are kept alive with refresh tokens
+const AccessTokenDuration = 15 * time.Minute
+const RefreshTokenDuration = 30 * 24 * time.Hour
+
 func Init() error {
 	pubKey, err := os.ReadFile("/cert/id_rsa.pub")
 	if err != nil {
@@ -44,6 +54,12 @@ func Init() error {
 	return nil
 }
 
+// SetKey signs and verifies access tokens with the given key instead of the one read by Init
+func SetKey(privateKey *rsa.PrivateKey) {
+	jwtPrivateKey = privateKey
+	jwtPublicKey = &privateKey.PublicKey
+}
+
 func GenerateJWT(userID int) (string, error) {
 	if os.Getenv("IS_TEST") == "true" {
 		return "test", nil
@@ -51,15 +67,21 @@ func GenerateJWT(userID int) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
 		"user_id":     userID,
 		"created_at":  time.Now().Unix(),
-		"expiry_date": time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"expiry_date": time.Now().Add(AccessTokenDuration).Unix(),
 	})
 
 	return token.SignedString(jwtPrivateKey)
 }
 
 func VerifyJWT(tokenString string) (int, int, error) {
+	userId, _, code, err := VerifyJWTWithExpiry(tokenString)
+	return userId, code, err
+}
+
+// VerifyJWTWithExpiry checks the access token like VerifyJWT, and also returns when it expires
+func VerifyJWTWithExpiry(tokenString string) (int, time.Time, int, error) {
 	if os.Getenv("IS_TEST") == "true" {
-		return 1, http.StatusOK, nil
+		return 1, time.Now().Add(AccessTokenDuration), http.StatusOK, nil
 	}
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
@@ -69,31 +91,117 @@ func VerifyJWT(tokenString string) (int, int, error) {
 	})
 	if err != nil {
 		log.Printf("Error parsing token: %v", err)
-		return 0, http.StatusInternalServerError, err
+		return 0, time.Time{}, http.StatusInternalServerError, err
 	}
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		expiryDate := int64(claims["expiry_date"].(float64))
 		if expiryDate < time.Now().Unix() {
-			return 0, http.StatusBadRequest, errors.New("token expired")
+			return 0, time.Time{}, http.StatusBadRequest, errors.New("token expired")
 		}
-		return int(claims["user_id"].(float64)), http.StatusAccepted, nil
+		return int(claims["user_id"].(float64)), time.Unix(expiryDate, 0), http.StatusAccepted, nil
 	}
-	return 0, http.StatusBadRequest, errors.New("invalid token")
+	return 0, time.Time{}, http.StatusBadRequest, errors.New("invalid token")
 }
 
-func RefreshJWT(tokenString string) (string, int, error) {
+func generateOpaqueToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		log.Printf("Unable to generate random bytes: %v", err)
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// GenerateRefreshToken returns an opaque refresh token. Only its hash is meant to be stored
+func GenerateRefreshToken() (string, error) {
 	if os.Getenv("IS_TEST") == "true" {
-		return "test", http.StatusOK, nil
+		return "test", nil
 	}
-	id, status, err := VerifyJWT(tokenString)
-	if err != nil {
-		return "", status, err
+	return generateOpaqueToken(32)
+}
+
+// GenerateSessionId returns the identifier shared by every refresh token of a same login
+func GenerateSessionId() (string, error) {
+	if os.Getenv("IS_TEST") == "true" {
+		return "test", nil
+	}
+	return generateOpaqueToken(16)
+}
+
+// GeneratePasswordResetToken returns the opaque token sent by email to reset a password. Only its hash is meant to be stored
+func GeneratePasswordResetToken() (string, error) {
+	if os.Getenv("IS_TEST") == "true" {
+		return "test", nil
 	}
-	newToken, err := GenerateJWT(id)
+	return generateOpaqueToken(32)
+}
+
+// GenerateEmailVerificationToken returns the opaque token sent by email to verify an address. Only its hash is meant to be stored
+func GenerateEmailVerificationToken() (string, error) {
+	if os.Getenv("IS_TEST") == "true" {
+		return "test", nil
+	}
+	return generateOpaqueToken(32)
+}
+
+// GenerateLoginChallengeToken returns the opaque token a login waiting for its second factor is continued with. Only its hash is meant to be stored
+func GenerateLoginChallengeToken() (string, error) {
+	if os.Getenv("IS_TEST") == "true" {
+		return "test", nil
+	}
+	return generateOpaqueToken(32)
+}
+
+// GenerateStreamTicket returns the opaque ticket an event stream is opened with once. Only its hash is meant to be stored
+func GenerateStreamTicket() (string, error) {
+	if os.Getenv("IS_TEST") == "true" {
+		return "test", nil
+	}
+	return generateOpaqueToken(32)
+}
+
+// GenerateRecoveryCode returns a code such as "abcde-fghij" replacing a two-factor code once. Only its hash is meant to be stored
+func GenerateRecoveryCode() (string, error) {
+	bytes := make([]byte, 10)
+	_, err := rand.Read(bytes)
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		log.Printf("Unable to generate random bytes: %v", err)
+		return "", err
 	}
-	return newToken, http.StatusOK, nil
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func hashOpaqueToken(opaqueToken string) string {
+	hash := sha256.Sum256([]byte(opaqueToken))
+	return hex.EncodeToString(hash[:])
+}
+
+func HashRefreshToken(refreshToken string) string {
+	return hashOpaqueToken(refreshToken)
+}
+
+func HashPasswordResetToken(resetToken string) string {
+	return hashOpaqueToken(resetToken)
+}
+
+func HashEmailVerificationToken(verificationToken string) string {
+	return hashOpaqueToken(verificationToken)
+}
+
+func HashLoginChallengeToken(challengeToken string) string {
+	return hashOpaqueToken(challengeToken)
+}
+
+func HashStreamTicket(ticket string) string {
+	return hashOpaqueToken(ticket)
+}
+
+// HashRecoveryCode ignores the case, the spaces and the dashes the user may type the code with
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashOpaqueToken(code)
 }
 
 func ParseAccessToken(c *gin.Context) (string, int, error) {
@@ -109,7 +217,7 @@ func VerifyGoogleToken(requestToken string) (models.GoogleInfos, error) {
 	isTest := os.Getenv("IS_TEST") == "true"
 
 	if isTest {
-		return models.GoogleInfos{Name: "toto123", Email: "toto123@gmail.com", Subject: "123456"}, nil
+		return models.GoogleInfos{Name: "toto123", Email: "toto123@gmail.com", EmailVerified: true, Subject: "123456"}, nil
 	}
 
 	// Verify the token
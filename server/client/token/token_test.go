@@ -1,9 +1,13 @@
 package token
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -46,3 +50,89 @@ func TestParseAccessToken(t *testing.T) {
 		t.Errorf("Expected token %s, got %s", "token", token)
 	}
 }
+
+func TestHashRefreshToken(t *testing.T) {
+	hash := HashRefreshToken("refresh")
+	if len(hash) != 64 {
+		t.Errorf("Expected a 64 characters hash, got %d", len(hash))
+	}
+	if hash != HashRefreshToken("refresh") {
+		t.Errorf("Expected hashing to be deterministic")
+	}
+	if hash == HashRefreshToken("other") {
+		t.Errorf("Expected different tokens to have different hashes")
+	}
+}
+
+func TestGenerateRefreshToken(t *testing.T) {
+	first, err := GenerateRefreshToken()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	second, err := GenerateRefreshToken()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if first == second {
+		t.Errorf("Expected refresh tokens to be random")
+	}
+}
+
+func TestGeneratePasswordResetToken(t *testing.T) {
+	first, err := GeneratePasswordResetToken()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	second, _ := GeneratePasswordResetToken()
+	if first == second || len(first) < 32 {
+		t.Errorf("Expected password reset tokens to be long and random, got %s and %s", first, second)
+	}
+	if HashPasswordResetToken(first) != HashPasswordResetToken(first) || len(HashPasswordResetToken(first)) != 64 {
+		t.Errorf("Expected a deterministic 64 characters hash")
+	}
+}
+
+func TestVerifyJWTWithExpiry(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error while generating key: %s", err.Error())
+	}
+	SetKey(key)
+	accessToken, err := GenerateJWT(3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	userId, expiresAt, _, err := VerifyJWTWithExpiry(accessToken)
+	if err != nil || userId != 3 || time.Until(expiresAt) > AccessTokenDuration || time.Until(expiresAt) < AccessTokenDuration-time.Minute {
+		t.Errorf("Expected user 3 and the expiry of the token, got %d, %s, %v", userId, expiresAt, err)
+	}
+	_, _, _, err = VerifyJWTWithExpiry("forged")
+	if err == nil {
+		t.Errorf("Expected a forged token to be refused")
+	}
+}
+
+func TestGenerateStreamTicket(t *testing.T) {
+	first, err := GenerateStreamTicket()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	second, _ := GenerateStreamTicket()
+	if first == second || len(first) < 32 || len(HashStreamTicket(first)) != 64 {
+		t.Errorf("Expected stream tickets to be long and random, got %s and %s", first, second)
+	}
+}
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	first, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	second, _ := GenerateRecoveryCode()
+	if first == second || len(first) != 11 || first[5] != '-' {
+		t.Errorf("Expected recovery codes such as abcde-fghij, got %s and %s", first, second)
+	}
+	if HashRecoveryCode(first) != HashRecoveryCode(strings.ToUpper(strings.Replace(first, "-", " ", 1))) {
+		t.Errorf("Expected the hash to ignore the case and the separator")
+	}
+}
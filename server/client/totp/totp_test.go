@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret of the test vectors of RFC 6238, for SHA-1
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAt(t *testing.T) {
+	// the RFC gives 8 digit codes, the last 6 are the codes with 6 digits
+	vectors := map[int64]string{59: "287082", 1111111109: "081804", 1111111111: "050471", 1234567890: "005924", 2000000000: "279037"}
+	for unix, expected := range vectors {
+		code, err := CodeAt(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil || code != expected {
+			t.Errorf("Expected %s at %d, got %s, %v", expected, unix, code, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step, ok := Validate(rfcSecret, "005924", now)
+	if !ok || step != Step(now) {
+		t.Errorf("Expected the current code to be valid, got %d, %t", step, ok)
+	}
+	// the previous step is still accepted, not the ones before
+	previous, _ := CodeAt(rfcSecret, Step(now)-1)
+	if step, ok := Validate(rfcSecret, previous, now); !ok || step != Step(now)-1 {
+		t.Errorf("Expected the previous code to be valid, got %d, %t", step, ok)
+	}
+	old, _ := CodeAt(rfcSecret, Step(now)-2)
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Errorf("Expected an older code to be refused")
+	}
+	for _, code := range []string{"", "12345", "abcdef", "0059245"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Expected %q to be refused", code)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fatalf("Expected a 32 character secret, got %q, %v", secret, err)
+	}
+	other, _ := GenerateSecret()
+	if secret == other {
+		t.Errorf("Expected random secrets")
+	}
+	if _, err := CodeAt(secret, 1); err != nil {
+		t.Errorf("Expected the secret to be usable, got %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Truthful", "toto", "SECRET")
+	if !strings.HasPrefix(uri, "otpauth://totp/Truthful:toto?") || !strings.Contains(uri, "secret=SECRET") || !strings.Contains(uri, "issuer=Truthful") {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+}
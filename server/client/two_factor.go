@@ -0,0 +1,391 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/client/totp"
+	"project_truthful/models"
+	"time"
+)
+
+// name of the account in the authenticator apps
+const totpIssuer = "Truthful"
+
+// a login waiting for its second factor can be continued for a few minutes, with a few wrong codes
+const (
+	loginChallengeDuration    = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+)
+
+// recovery codes given at once, each replaces a code of the authenticator app once
+const recoveryCodeCount = 10
+
+// roleTwoFactorState is the requirement of a role recorded in the audit log before and after it is edited
+type roleTwoFactorState struct {
+	RequiresTwoFactor bool `json:"requires_two_factor"`
+}
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+var errInvalidLoginChallenge = errors.New("invalid or expired login challenge")
+var errTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+
+// getEnabledTotpSecret returns the secret of the user, refusing users who didn't confirm an enrollment
+func getEnabledTotpSecret(userId int) (models.TotpSecret, int, error) {
+	secret, err := database.GetStore().GetTotpSecret(userId)
+	if err == sql.ErrNoRows || (err == nil && !secret.IsEnabled) {
+		return models.TotpSecret{}, http.StatusBadRequest, errTwoFactorNotEnabled
+	} else if err != nil {
+		return models.TotpSecret{}, http.StatusInternalServerError, err
+	}
+	return secret, http.StatusOK, nil
+}
+
+// useTotpCode checks the code of the authenticator app, a code can only be used once
+func useTotpCode(store database.Store, secret models.TotpSecret, code string) (bool, error) {
+	step, ok := totp.Validate(secret.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return store.UseTotpStep(secret.UserId, step)
+}
+
+// useSecondFactor checks the code of the authenticator app, or uses the recovery code when it doesn't look like one
+func useSecondFactor(store database.Store, secret models.TotpSecret, code string) (bool, error) {
+	if len(code) == totp.Digits {
+		return useTotpCode(store, secret, code)
+	}
+	return store.UseRecoveryCode(secret.UserId, token.HashRecoveryCode(code))
+}
+
+// generateRecoveryCodes returns new recovery codes and the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := token.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, token.HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// replaceRecoveryCodes swaps the recovery codes of the user for new ones
+func replaceRecoveryCodes(store database.Store, userId int, hashes []string) error {
+	err := store.DeleteRecoveryCodes(userId)
+	if err != nil {
+		return err
+	}
+	return store.AddRecoveryCodes(userId, hashes)
+}
+
+// createSessionOrChallenge logs the user in, or returns a challenge to pass with a second factor when the user enabled one
+func createSessionOrChallenge(userId int, ipAddress string, userAgent string) (models.AuthTokens, int, error) {
+	secret, err := database.GetStore().GetTotpSecret(userId)
+	if err != nil && err != sql.ErrNoRows {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	if !secret.IsEnabled {
+		return CreateSession(userId, ipAddress, userAgent)
+	}
+
+	challengeToken, err := token.GenerateLoginChallengeToken()
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	_, err = database.GetStore().AddLoginChallenge(userId, token.HashLoginChallengeToken(challengeToken), time.Now().Add(loginChallengeDuration))
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	return models.AuthTokens{ChallengeToken: challengeToken}, http.StatusOK, nil
+}
+
+// LoginTwoFactor passes the challenge of a login with a code of the authenticator app or a recovery code, and creates the session
+func LoginTwoFactor(infos models.LoginTwoFactorInfos, ipAddress string, userAgent string) (models.AuthTokens, int, error) {
+	if infos.ChallengeToken == "" || infos.Code == "" {
+		return models.AuthTokens{}, http.StatusBadRequest, errors.New("missing fields")
+	}
+	challenge, err := database.GetStore().GetLoginChallenge(token.HashLoginChallengeToken(infos.ChallengeToken))
+	if err == sql.ErrNoRows {
+		return models.AuthTokens{}, http.StatusUnauthorized, errInvalidLoginChallenge
+	} else if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	if challenge.IsUsed || challenge.ExpiresAt.Before(time.Now()) || challenge.Attempts >= maxLoginChallengeAttempts {
+		return models.AuthTokens{}, http.StatusUnauthorized, errInvalidLoginChallenge
+	}
+	// the wrong codes count as failed logins of the user, the attempts of a challenge alone don't stop new challenges
+	username, _, err := database.GetStore().GetUsernameAndDisplayName(challenge.UserId)
+	if err != nil {
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+	attemptId, code, err := reserveLoginAttempt(username, ipAddress, userAgent, models.LoginMethodTwoFactor)
+	if err != nil {
+		return models.AuthTokens{}, code, err
+	}
+	// the second factor may have been disabled since the password was checked
+	secret, _, err := getEnabledTotpSecret(challenge.UserId)
+	if err == errTwoFactorNotEnabled {
+		cancelLoginAttempt(attemptId)
+		return models.AuthTokens{}, http.StatusUnauthorized, errInvalidLoginChallenge
+	} else if err != nil {
+		cancelLoginAttempt(attemptId)
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+
+	err = database.GetStore().InTransaction(func(store database.Store) error {
+		valid, err := useSecondFactor(store, secret, infos.Code)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return errInvalidTwoFactorCode
+		}
+		used, err := store.UseLoginChallenge(challenge.Id)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errInvalidLoginChallenge
+		}
+		return nil
+	})
+	if err == errInvalidTwoFactorCode {
+		err = database.GetStore().AddLoginChallengeAttempt(challenge.Id)
+		if err != nil {
+			return models.AuthTokens{}, http.StatusInternalServerError, err
+		}
+		err = finishLoginAttempt(attemptId, challenge.UserId, false)
+		if err != nil {
+			return models.AuthTokens{}, http.StatusInternalServerError, err
+		}
+		return models.AuthTokens{}, http.StatusUnauthorized, errInvalidTwoFactorCode
+	} else if err == errInvalidLoginChallenge {
+		cancelLoginAttempt(attemptId)
+		return models.AuthTokens{}, http.StatusUnauthorized, err
+	} else if err != nil {
+		cancelLoginAttempt(attemptId)
+		return models.AuthTokens{}, http.StatusInternalServerError, err
+	}
+
+	code, err = CheckUserNotBanned(challenge.UserId)
+	if err != nil {
+		recordFailedLogin(attemptId, username, challenge.UserId, ipAddress, userAgent, models.LoginMethodTwoFactor)
+		return models.AuthTokens{}, code, err
+	}
+	tokens, code, err := CreateSession(challenge.UserId, ipAddress, userAgent)
+	if err != nil {
+		cancelLoginAttempt(attemptId)
+		return models.AuthTokens{}, code, err
+	}
+	recordSuccessfulLogin(attemptId, username, challenge.UserId, ipAddress, userAgent, models.LoginMethodTwoFactor)
+	return tokens, code, nil
+}
+
+// GetTwoFactorStatus tells if the user enabled a second factor, if a role of the user requires one and how many recovery codes are left
+func GetTwoFactorStatus(userId int) (models.TwoFactorStatus, int, error) {
+	secret, err := database.GetStore().GetTotpSecret(userId)
+	if err != nil && err != sql.ErrNoRows {
+		return models.TwoFactorStatus{}, http.StatusInternalServerError, err
+	}
+	required, err := database.GetStore().CheckTwoFactorRequired(userId)
+	if err != nil {
+		return models.TwoFactorStatus{}, http.StatusInternalServerError, err
+	}
+	status := models.TwoFactorStatus{Enabled: secret.IsEnabled, Required: required}
+	if secret.IsEnabled {
+		status.RecoveryCodesLeft, err = database.GetStore().CountRecoveryCodes(userId)
+		if err != nil {
+			return models.TwoFactorStatus{}, http.StatusInternalServerError, err
+		}
+	}
+	return status, http.StatusOK, nil
+}
+
+// BeginTotpEnrollment returns a new secret for the authenticator app of the user, replacing an enrollment left unconfirmed.
+// The second factor is only enabled once a code of the app confirms it
+func BeginTotpEnrollment(userId int) (models.TwoFactorEnrollment, int, error) {
+	secret, err := database.GetStore().GetTotpSecret(userId)
+	if err != nil && err != sql.ErrNoRows {
+		return models.TwoFactorEnrollment{}, http.StatusInternalServerError, err
+	}
+	if secret.IsEnabled {
+		return models.TwoFactorEnrollment{}, http.StatusBadRequest, errors.New("two-factor authentication is already enabled")
+	}
+	username, _, err := database.GetStore().GetUsernameAndDisplayName(userId)
+	if err == sql.ErrNoRows {
+		return models.TwoFactorEnrollment{}, http.StatusNotFound, errors.New("user not found")
+	} else if err != nil {
+		return models.TwoFactorEnrollment{}, http.StatusInternalServerError, err
+	}
+
+	newSecret, err := totp.GenerateSecret()
+	if err != nil {
+		return models.TwoFactorEnrollment{}, http.StatusInternalServerError, err
+	}
+	err = database.GetStore().InTransaction(func(store database.Store) error {
+		err := store.DeleteTotpSecret(userId)
+		if err != nil {
+			return err
+		}
+		return store.AddTotpSecret(userId, newSecret)
+	})
+	if err != nil {
+		return models.TwoFactorEnrollment{}, http.StatusInternalServerError, err
+	}
+	return models.TwoFactorEnrollment{Secret: newSecret, Uri: totp.URI(totpIssuer, username, newSecret)}, http.StatusOK, nil
+}
+
+// confirmTwoFactorChange runs a change of the second factor confirmed by a code. The wrong codes count as failed logins
+// of the user and the changes wait with the logins, so that whoever holds an access token can't guess the code.
+// change returns errInvalidTwoFactorCode for a wrong code
+func confirmTwoFactorChange(userId int, ipAddress string, userAgent string, change func() error) (int, error) {
+	username, _, err := database.GetStore().GetUsernameAndDisplayName(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	attemptId, code, err := reserveLoginAttempt(username, ipAddress, userAgent, models.LoginMethodTwoFactor)
+	if err != nil {
+		return code, err
+	}
+	err = change()
+	if err == errInvalidTwoFactorCode {
+		err = finishLoginAttempt(attemptId, userId, false)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusBadRequest, errInvalidTwoFactorCode
+	}
+	// a right code is no login, it doesn't forget the failures of the user
+	cancelLoginAttempt(attemptId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// ConfirmTotpEnrollment enables the second factor with a first code of the authenticator app, and returns the recovery codes.
+// They are only shown this once
+func ConfirmTotpEnrollment(userId int, code string, ipAddress string, userAgent string) (models.RecoveryCodes, int, error) {
+	secret, err := database.GetStore().GetTotpSecret(userId)
+	if err == sql.ErrNoRows || (err == nil && secret.IsEnabled) {
+		return models.RecoveryCodes{}, http.StatusBadRequest, errors.New("no two-factor enrollment in progress")
+	} else if err != nil {
+		return models.RecoveryCodes{}, http.StatusInternalServerError, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return models.RecoveryCodes{}, http.StatusInternalServerError, err
+	}
+
+	status, err := confirmTwoFactorChange(userId, ipAddress, userAgent, func() error {
+		return database.GetStore().InTransaction(func(store database.Store) error {
+			valid, err := useTotpCode(store, secret, code)
+			if err != nil {
+				return err
+			}
+			if !valid {
+				return errInvalidTwoFactorCode
+			}
+			err = store.EnableTotp(userId)
+			if err != nil {
+				return err
+			}
+			return replaceRecoveryCodes(store, userId, hashes)
+		})
+	})
+	if err != nil {
+		return models.RecoveryCodes{}, status, err
+	}
+	return models.RecoveryCodes{Codes: codes}, http.StatusOK, nil
+}
+
+// DisableTotp removes the second factor of the user with a code of the authenticator app or a recovery code,
+// unless a role of the user requires one
+func DisableTotp(userId int, code string, ipAddress string, userAgent string) (int, error) {
+	required, err := database.GetStore().CheckTwoFactorRequired(userId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if required {
+		return http.StatusForbidden, errors.New("your role requires two-factor authentication")
+	}
+	secret, status, err := getEnabledTotpSecret(userId)
+	if err != nil {
+		return status, err
+	}
+
+	return confirmTwoFactorChange(userId, ipAddress, userAgent, func() error {
+		return database.GetStore().InTransaction(func(store database.Store) error {
+			valid, err := useSecondFactor(store, secret, code)
+			if err != nil {
+				return err
+			}
+			if !valid {
+				return errInvalidTwoFactorCode
+			}
+			err = store.DeleteTotpSecret(userId)
+			if err != nil {
+				return err
+			}
+			return store.DeleteRecoveryCodes(userId)
+		})
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, confirmed with a code of the authenticator app or a recovery code
+func RegenerateRecoveryCodes(userId int, code string, ipAddress string, userAgent string) (models.RecoveryCodes, int, error) {
+	secret, status, err := getEnabledTotpSecret(userId)
+	if err != nil {
+		return models.RecoveryCodes{}, status, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return models.RecoveryCodes{}, http.StatusInternalServerError, err
+	}
+
+	status, err = confirmTwoFactorChange(userId, ipAddress, userAgent, func() error {
+		return database.GetStore().InTransaction(func(store database.Store) error {
+			valid, err := useSecondFactor(store, secret, code)
+			if err != nil {
+				return err
+			}
+			if !valid {
+				return errInvalidTwoFactorCode
+			}
+			return replaceRecoveryCodes(store, userId, hashes)
+		})
+	})
+	if err != nil {
+		return models.RecoveryCodes{}, status, err
+	}
+	return models.RecoveryCodes{Codes: codes}, http.StatusOK, nil
+}
+
+// SetRoleTwoFactor sets whether the holders of the role need a second factor to use its permissions,
+// the requester must be allowed to manage roles
+func SetRoleTwoFactor(audit models.AuditContext, roleName string, required bool) (int, error) {
+	roles, err := database.GetStore().GetRoles()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for _, role := range roles {
+		if role.Name != roleName {
+			continue
+		}
+		return inTransaction(func(store database.Store) error {
+			err := store.SetRoleRequiresTwoFactor(role.Id, required)
+			if err != nil {
+				return err
+			}
+			return addAuditEntry(store, audit, models.AuditRoleEdit, models.AuditTargetRole, role.Id,
+				roleTwoFactorState{RequiresTwoFactor: role.RequiresTwoFactor}, roleTwoFactorState{RequiresTwoFactor: required})
+		})
+	}
+	return http.StatusBadRequest, errors.New("invalid role")
+}
@@ -0,0 +1,403 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+	"project_truthful/client/totp"
+	"project_truthful/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const testTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func expectNoTotpSecret(mock sqlmock.Sqlmock, userId int) {
+	mock.ExpectQuery("FROM user_totp WHERE user_id = ").WithArgs(userId).WillReturnError(sql.ErrNoRows)
+}
+
+func expectTotpSecret(mock sqlmock.Sqlmock, userId int, enabled bool) {
+	mock.ExpectQuery("FROM user_totp WHERE user_id = ").WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "last_used_step", "enabled"}).AddRow(userId, testTotpSecret, 0, enabled))
+}
+
+func currentTotpCode(t *testing.T) string {
+	code, err := totp.CodeAt(testTotpSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("Error while generating the code: %s", err.Error())
+	}
+	return code
+}
+
+func expectLoginChallenge(mock sqlmock.Sqlmock, challengeToken string, attempts int, expiresAt time.Time, used bool) {
+	mock.ExpectQuery("FROM login_challenge WHERE token_hash = ").WithArgs(token.HashLoginChallengeToken(challengeToken)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "attempts", "expires_at", "used"}).AddRow(1, 2, attempts, expiresAt, used))
+}
+
+// expectChallengeUser expects the username of the user of the challenge to be read, the login is reserved before the code is checked
+func expectChallengeUser(mock sqlmock.Sqlmock, userId int, username string) {
+	mock.ExpectQuery("SELECT username, display_name FROM user WHERE id = ").WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow(username, username))
+	expectReservedLogin(mock, username, "127.0.0.1", "agent", models.LoginMethodTwoFactor, 0, 0)
+}
+
+func TestLoginWithTwoFactor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+
+	// the password only opens a challenge
+	expectAddressBans(mock, models.AddressBanIp)
+	expectReservedLogin(mock, "username", "127.0.0.1", "agent", models.LoginMethodPassword, 0, 0)
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT password FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("hash"))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	expectTotpSecret(mock, 2, true)
+	mock.ExpectExec("INSERT INTO login_challenge").WithArgs(2, token.HashLoginChallengeToken("test"), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectCancelledLogin(mock)
+	tokens, code, err := Login(models.LoginInfos{Username: "username", Password: "password"}, "127.0.0.1", "agent")
+	if code != http.StatusOK || err != nil || tokens.ChallengeToken != "test" || tokens.AccessToken != "" || tokens.RefreshToken != "" {
+		t.Errorf("Expected a challenge without session, got %+v, %d, %v", tokens, code, err)
+	}
+
+	// an enrollment left unconfirmed doesn't change the login
+	expectAddressBans(mock, models.AddressBanIp)
+	expectReservedLogin(mock, "username", "127.0.0.1", "agent", models.LoginMethodPassword, 0, 0)
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT password FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("hash"))
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	expectTotpSecret(mock, 2, false)
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 2, token.HashRefreshToken("test"), "127.0.0.1", "agent", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectReservedSuccessfulLogin(mock, 2, "127.0.0.1", "agent")
+	tokens, code, err = Login(models.LoginInfos{Username: "username", Password: "password"}, "127.0.0.1", "agent")
+	if code != http.StatusOK || err != nil || tokens.ChallengeToken != "" || tokens.RefreshToken != "test" {
+		t.Errorf("Expected a session, got %+v, %d, %v", tokens, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+	later := time.Now().Add(time.Minute)
+
+	mock.ExpectQuery("FROM login_challenge WHERE token_hash = ").WithArgs(token.HashLoginChallengeToken("unknown")).WillReturnError(sql.ErrNoRows)
+	_, code, err := LoginTwoFactor(models.LoginTwoFactorInfos{ChallengeToken: "unknown", Code: "123456"}, "127.0.0.1", "agent")
+	if code != http.StatusUnauthorized || err != errInvalidLoginChallenge {
+		t.Errorf("Expected an unknown challenge to be refused, got %d, %v", code, err)
+	}
+
+	for _, challenge := range []struct {
+		attempts  int
+		expiresAt time.Time
+		used      bool
+	}{{0, time.Now().Add(-time.Minute), false}, {maxLoginChallengeAttempts, later, false}, {0, later, true}} {
+		expectLoginChallenge(mock, "challenge", challenge.attempts, challenge.expiresAt, challenge.used)
+		_, code, err = LoginTwoFactor(models.LoginTwoFactorInfos{ChallengeToken: "challenge", Code: "123456"}, "127.0.0.1", "agent")
+		if code != http.StatusUnauthorized || err != errInvalidLoginChallenge {
+			t.Errorf("Expected challenge %+v to be refused, got %d, %v", challenge, code, err)
+		}
+	}
+
+	// a wrong recovery code counts as an attempt
+	expectLoginChallenge(mock, "challenge", 1, later, false)
+	expectChallengeUser(mock, 2, "username")
+	expectTotpSecret(mock, 2, true)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE recovery_code SET used_at").WithArgs(2, token.HashRecoveryCode("wrong-code")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectExec("UPDATE login_challenge SET attempts").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFinishedLogin(mock, 2, false)
+	_, code, err = LoginTwoFactor(models.LoginTwoFactorInfos{ChallengeToken: "challenge", Code: "wrong-code"}, "127.0.0.1", "agent")
+	if code != http.StatusUnauthorized || err != errInvalidTwoFactorCode {
+		t.Errorf("Expected a wrong code to be refused, got %d, %v", code, err)
+	}
+
+	expectLoginChallenge(mock, "challenge", 1, later, false)
+	expectChallengeUser(mock, 2, "username")
+	expectTotpSecret(mock, 2, true)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp SET last_used_step").WithArgs(sqlmock.AnyArg(), 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE login_challenge SET used_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 2, token.HashRefreshToken("test"), "127.0.0.1", "agent", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectReservedSuccessfulLogin(mock, 2, "127.0.0.1", "agent")
+	tokens, code, err := LoginTwoFactor(models.LoginTwoFactorInfos{ChallengeToken: "challenge", Code: currentTotpCode(t)}, "127.0.0.1", "agent")
+	if code != http.StatusOK || err != nil || tokens.RefreshToken != "test" {
+		t.Errorf("Expected a session, got %+v, %d, %v", tokens, code, err)
+	}
+
+	// the code of this step was already used
+	expectLoginChallenge(mock, "challenge", 0, later, false)
+	expectChallengeUser(mock, 2, "username")
+	expectTotpSecret(mock, 2, true)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp SET last_used_step").WithArgs(sqlmock.AnyArg(), 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectExec("UPDATE login_challenge SET attempts").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFinishedLogin(mock, 2, false)
+	_, code, err = LoginTwoFactor(models.LoginTwoFactorInfos{ChallengeToken: "challenge", Code: currentTotpCode(t)}, "127.0.0.1", "agent")
+	if code != http.StatusUnauthorized || err != errInvalidTwoFactorCode {
+		t.Errorf("Expected a replayed code to be refused, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestTotpEnrollment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	expectTotpSecret(mock, 2, true)
+	_, code, err := BeginTotpEnrollment(2)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected an enabled second factor to be refused, got %d, %v", code, err)
+	}
+
+	expectTotpSecret(mock, 2, false)
+	mock.ExpectQuery("SELECT username, display_name FROM user WHERE id = ").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto", "Toto"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_totp").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_totp").WithArgs(2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	enrollment, code, err := BeginTotpEnrollment(2)
+	if code != http.StatusOK || err != nil || len(enrollment.Secret) != 32 || enrollment.Uri != totp.URI(totpIssuer, "toto", enrollment.Secret) {
+		t.Errorf("Expected a new secret, got %+v, %d, %v", enrollment, code, err)
+	}
+
+	expectNoTotpSecret(mock, 2)
+	_, code, err = ConfirmTotpEnrollment(2, "123456", "127.0.0.1", "agent")
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected a confirmation without enrollment to be refused, got %d, %v", code, err)
+	}
+
+	// a wrong code counts as a failed login
+	expectTotpSecret(mock, 2, false)
+	expectChallengeUser(mock, 2, "toto")
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	expectFinishedLogin(mock, 2, false)
+	_, code, err = ConfirmTotpEnrollment(2, "abcdef", "127.0.0.1", "agent")
+	if code != http.StatusBadRequest || err != errInvalidTwoFactorCode {
+		t.Errorf("Expected a wrong code to be refused, got %d, %v", code, err)
+	}
+
+	// the codes wait with the logins of the user, without being checked
+	expectTotpSecret(mock, 2, false)
+	mock.ExpectQuery("SELECT username, display_name FROM user WHERE id = ").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto", "toto"))
+	expectReservedLogin(mock, "toto", "127.0.0.1", "agent", models.LoginMethodTwoFactor, usernameLoginThrottle.freeFailures, 0)
+	expectCancelledLogin(mock)
+	_, code, err = ConfirmTotpEnrollment(2, currentTotpCode(t), "127.0.0.1", "agent")
+	var throttledErr *LoginThrottledError
+	if code != http.StatusTooManyRequests || !errors.As(err, &throttledErr) {
+		t.Errorf("Expected the code to wait, got %d, %v", code, err)
+	}
+
+	// a right code is no login, the failures are kept
+	expectTotpSecret(mock, 2, false)
+	expectChallengeUser(mock, 2, "toto")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp SET last_used_step").WithArgs(sqlmock.AnyArg(), 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE user_totp SET enabled_at").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_code").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	for range recoveryCodeCount {
+		mock.ExpectExec("INSERT INTO recovery_code").WithArgs(2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+	expectCancelledLogin(mock)
+	codes, code, err := ConfirmTotpEnrollment(2, currentTotpCode(t), "127.0.0.1", "agent")
+	if code != http.StatusOK || err != nil || len(codes.Codes) != recoveryCodeCount {
+		t.Errorf("Expected the recovery codes, got %+v, %d, %v", codes, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestDisableTotp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	code, err := DisableTotp(2, "123456", "127.0.0.1", "agent")
+	if code != http.StatusForbidden || err == nil {
+		t.Errorf("Expected a required second factor to stay, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectNoTotpSecret(mock, 2)
+	code, err = DisableTotp(2, "123456", "127.0.0.1", "agent")
+	if code != http.StatusBadRequest || err != errTwoFactorNotEnabled {
+		t.Errorf("Expected 400, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectTotpSecret(mock, 2, true)
+	expectChallengeUser(mock, 2, "toto")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE recovery_code SET used_at").WithArgs(2, token.HashRecoveryCode("wrong")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	expectFinishedLogin(mock, 2, false)
+	code, err = DisableTotp(2, "wrong", "127.0.0.1", "agent")
+	if code != http.StatusBadRequest || err != errInvalidTwoFactorCode {
+		t.Errorf("Expected a wrong code to be refused, got %d, %v", code, err)
+	}
+
+	// once the user has too many failures, the codes are not checked anymore
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectTotpSecret(mock, 2, true)
+	mock.ExpectQuery("SELECT username, display_name FROM user WHERE id = ").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("toto", "toto"))
+	expectReservedLogin(mock, "toto", "127.0.0.1", "agent", models.LoginMethodTwoFactor, usernameLoginThrottle.lockoutFailures, 0)
+	expectCancelledLogin(mock)
+	code, err = DisableTotp(2, "ABCDE FGHIJ", "127.0.0.1", "agent")
+	var throttledErr *LoginThrottledError
+	if code != http.StatusTooManyRequests || !errors.As(err, &throttledErr) || throttledErr.RetryAfter <= time.Minute {
+		t.Errorf("Expected the user to be locked out, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectTotpSecret(mock, 2, true)
+	expectChallengeUser(mock, 2, "toto")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE recovery_code SET used_at").WithArgs(2, token.HashRecoveryCode("abcde-fghij")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user_totp").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_code").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 9))
+	mock.ExpectCommit()
+	expectCancelledLogin(mock)
+	code, err = DisableTotp(2, "ABCDE FGHIJ", "127.0.0.1", "agent")
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected the second factor to be disabled, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	expectTotpSecret(mock, 2, true)
+	expectChallengeUser(mock, 2, "toto")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp SET last_used_step").WithArgs(sqlmock.AnyArg(), 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	expectFinishedLogin(mock, 2, false)
+	_, code, err := RegenerateRecoveryCodes(2, currentTotpCode(t), "127.0.0.1", "agent")
+	if code != http.StatusBadRequest || err != errInvalidTwoFactorCode {
+		t.Errorf("Expected a used code to be refused, got %d, %v", code, err)
+	}
+
+	expectTotpSecret(mock, 2, true)
+	expectChallengeUser(mock, 2, "toto")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp SET last_used_step").WithArgs(sqlmock.AnyArg(), 2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_code").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	for range recoveryCodeCount {
+		mock.ExpectExec("INSERT INTO recovery_code").WithArgs(2, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+	expectCancelledLogin(mock)
+	codes, code, err := RegenerateRecoveryCodes(2, currentTotpCode(t), "127.0.0.1", "agent")
+	if code != http.StatusOK || err != nil || len(codes.Codes) != recoveryCodeCount {
+		t.Errorf("Expected new recovery codes, got %+v, %d, %v", codes, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetTwoFactorStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	expectNoTotpSecret(mock, 2)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	status, code, err := GetTwoFactorStatus(2)
+	if code != http.StatusOK || err != nil || status.Enabled || !status.Required {
+		t.Errorf("Expected a required second factor, got %+v, %d, %v", status, code, err)
+	}
+
+	expectTotpSecret(mock, 2, true)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM recovery_code").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	status, code, err = GetTwoFactorStatus(2)
+	if code != http.StatusOK || err != nil || !status.Enabled || status.Required || status.RecoveryCodesLeft != 7 {
+		t.Errorf("Expected an enabled second factor, got %+v, %d, %v", status, code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestSetRoleTwoFactor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	roles := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "permission", "requires_two_factor"}).
+			AddRow(1, "admin", "roles.manage", false).
+			AddRow(2, "moderator", "users.ban", false)
+	}
+
+	mock.ExpectQuery("SELECT role.id, role.name, permission.name, role.requires_two_factor FROM role").WillReturnRows(roles())
+	code, err := SetRoleTwoFactor(models.AuditContext{ModeratorId: 1}, "unknown", true)
+	if code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected an unknown role to be refused, got %d, %v", code, err)
+	}
+
+	mock.ExpectQuery("SELECT role.id, role.name, permission.name, role.requires_two_factor FROM role").WillReturnRows(roles())
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE role SET requires_two_factor").WithArgs(true, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "role.edit", "role", 2, `{"before":{"requires_two_factor":false},"after":{"requires_two_factor":true}}`, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	code, err = SetRoleTwoFactor(models.AuditContext{ModeratorId: 1}, "moderator", true)
+	if code != http.StatusOK || err != nil {
+		t.Errorf("Expected the role to be edited, got %d, %v", code, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -1,49 +1,83 @@
-package client
-
-import (
-	"errors"
-	"net/http"
-	"net/mail"
-	"project_truthful/client/database"
-)
-
-func checkUserInfos(displayName string, email string) error {
-	if len(displayName) == 0 {
-		return errors.New("display name is empty")
-	}
-	if len(displayName) > 30 {
-		return errors.New("display name is too long")
-	}
-	if len(email) == 0 {
-		return errors.New("email address is empty")
-	}
-	if len(email) > 319 {
-		return errors.New("email address is too long")
-	}
-	_, err := mail.ParseAddress(email) // todo : check if email is already in db and not the same as the current one
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func UpdateUserInformations(requesterId int, displayName string, email string) (int, error) {
-	exists, err := database.CheckUserIdExists(requesterId, database.DB)
-	if err != nil {
-		return http.StatusInternalServerError, err
-	}
-	if !exists {
-		return http.StatusNotFound, errors.New("user not found")
-	}
-
-	err = checkUserInfos(displayName, email)
-	if err != nil {
-		return http.StatusBadRequest, err
-	}
-
-	err = database.UpdateUserInformations(requesterId, displayName, email, database.DB)
-	if err != nil {
-		return http.StatusInternalServerError, err
-	}
-	return 0, nil
-}
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"project_truthful/client/database"
+	"strings"
+)
+
+func checkUserInfos(displayName string, email string) error {
+	if len(displayName) == 0 {
+		return errors.New("display name is empty")
+	}
+	if len(displayName) > 30 {
+		return errors.New("display name is too long")
+	}
+	if len(email) == 0 {
+		return errors.New("email address is empty")
+	}
+	if len(email) > 319 {
+		return errors.New("email address is too long")
+	}
+	_, err := mail.ParseAddress(email)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateUserInformations updates the display name of the user.
+// A new email only replaces the current one once verified, with the link emailed to it
+func UpdateUserInformations(requesterId int, displayName string, email string) (int, error) {
+	exists, err := database.GetStore().CheckUserIdExists(requesterId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !exists {
+		return http.StatusNotFound, errors.New("user not found")
+	}
+
+	err = checkUserInfos(displayName, email)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	currentEmail, _, err := database.GetStore().GetUserEmail(requesterId)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	emailChanged := !strings.EqualFold(email, currentEmail)
+	if emailChanged {
+		emailExists, err := database.GetStore().CheckEmailExists(email)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if emailExists {
+			return http.StatusBadRequest, errEmailAlreadyExists
+		}
+		code, err := checkEmailVerificationsSent(requesterId)
+		if err != nil {
+			return code, err
+		}
+	}
+
+	// the verification token is saved with the update, only the email is sent once it is committed
+	var verificationToken string
+	err = database.GetStore().InTransaction(func(store database.Store) error {
+		err := store.UpdateUserInformations(requesterId, displayName, currentEmail)
+		if err != nil || !emailChanged {
+			return err
+		}
+		verificationToken, err = addEmailVerificationToken(store, requesterId, email)
+		return err
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if emailChanged {
+		sendEmailVerificationLink(requesterId, email, verificationToken)
+	}
+	return 0, nil
+}
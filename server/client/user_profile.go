@@ -5,18 +5,48 @@ import (
 	"errors"
 	"net/http"
 	"project_truthful/client/database"
+	"project_truthful/client/pagination"
 	"project_truthful/models"
 )
 
 func GetUserProfile(username string, requestingUser int, count int, start int) (models.UserProfileInfos, int, error) {
-	id, err := database.GetUserId(username, database.DB)
+	return getUserProfile(username, requestingUser, func(id int) (models.UserProfileInfos, error) {
+		return database.GetStore().GetUserProfileInfos(id, requestingUser, count, start)
+	})
+}
+
+// GetUserProfilePage returns the profile of the user with their answers after the cursor, and the cursor of the next page
+func GetUserProfilePage(username string, requestingUser int, cursor string, count int) (models.UserProfileInfos, int, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return models.UserProfileInfos{}, http.StatusBadRequest, err
+	}
+	count = pagination.ClampCount(count)
+
+	// one more answer is fetched to know if there is a next page
+	infos, code, err := getUserProfile(username, requestingUser, func(id int) (models.UserProfileInfos, error) {
+		return database.GetStore().GetUserProfileInfosPage(id, requestingUser, after, count+1)
+	})
+	if err != nil {
+		return infos, code, err
+	}
+	if len(infos.Answers) > count {
+		infos.Answers = infos.Answers[:count]
+		last := infos.Answers[count-1]
+		infos.NextCursor = pagination.Encode(models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	return infos, code, nil
+}
+
+func getUserProfile(username string, requestingUser int, getInfos func(id int) (models.UserProfileInfos, error)) (models.UserProfileInfos, int, error) {
+	id, err := database.GetStore().GetUserId(username)
 	if err != nil && err != sql.ErrNoRows {
 		return models.UserProfileInfos{}, http.StatusInternalServerError, err
 	}
 	if id == 0 || err == sql.ErrNoRows {
 		return models.UserProfileInfos{}, http.StatusNotFound, errors.New("user not found")
 	}
-	infos, err := database.GetUserProfileInfos(id, requestingUser, count, start, database.DB)
+	infos, err := getInfos(id)
 	if err != nil {
 		return models.UserProfileInfos{}, http.StatusInternalServerError, err
 	}
@@ -27,11 +57,16 @@ func GetUserProfile(username string, requestingUser int, count int, start int) (
 			infos.IsRequestingSelf = true
 		} else {
 			infos.IsRequestingSelf = false
-			isFollowedByRequester, err := database.CheckFollowExists(requestingUser, id, database.DB)
+			isFollowedByRequester, err := database.GetStore().CheckFollowExists(requestingUser, id)
 			if err != nil {
 				return models.UserProfileInfos{}, http.StatusInternalServerError, err
 			}
 			infos.IsFollowedByRequester = isFollowedByRequester
+
+			code, err := getRelationsWithRequester(&infos, id, requestingUser)
+			if err != nil {
+				return models.UserProfileInfos{}, code, err
+			}
 		}
 	} else {
 		infos.IsRequestingSelf = false
@@ -40,3 +75,25 @@ func GetUserProfile(username string, requestingUser int, count int, start int) (
 
 	return infos, http.StatusOK, nil
 }
+
+// getRelationsWithRequester sets the blocks and mute between the user and the requester,
+// the answers are hidden from a requester blocked by the user
+func getRelationsWithRequester(infos *models.UserProfileInfos, id int, requestingUser int) (int, error) {
+	var err error
+	infos.HasBlockedRequester, err = database.GetStore().CheckBlockExists(id, requestingUser)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if infos.HasBlockedRequester {
+		infos.Answers = []models.Answer{}
+	}
+	infos.IsBlockedByRequester, err = database.GetStore().CheckBlockExists(requestingUser, id)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	infos.IsMutedByRequester, err = database.GetStore().CheckMuteExists(requestingUser, id)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,33 @@
+// Package dialect names the SQL databases the server can run on
+package dialect
+
+import (
+	"fmt"
+	"os"
+)
+
+type Dialect string
+
+const (
+	MySQL  Dialect = "mysql"
+	SQLite Dialect = "sqlite"
+)
+
+// All lists every supported dialect
+var All = []Dialect{MySQL, SQLite}
+
+// Parse returns the dialect with the given name, an empty name being MySQL
+func Parse(name string) (Dialect, error) {
+	switch name {
+	case "", string(MySQL):
+		return MySQL, nil
+	case string(SQLite), "sqlite3":
+		return SQLite, nil
+	}
+	return "", fmt.Errorf("unknown database driver %s, expected mysql or sqlite", name)
+}
+
+// FromEnv returns the dialect selected by the DB_DRIVER env variable
+func FromEnv() (Dialect, error) {
+	return Parse(os.Getenv("DB_DRIVER"))
+}
@@ -0,0 +1,18 @@
+package dialect
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := map[string]Dialect{"": MySQL, "mysql": MySQL, "sqlite": SQLite, "sqlite3": SQLite}
+	for name, expected := range tests {
+		d, err := Parse(name)
+		if err != nil || d != expected {
+			t.Errorf("Expected %s for %q, got %s, %v", expected, name, d, err)
+		}
+	}
+
+	_, err := Parse("postgres")
+	if err == nil {
+		t.Errorf("Expected error for an unknown driver")
+	}
+}
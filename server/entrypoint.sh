@@ -3,5 +3,8 @@
 # Execute the script to generate keys
 bash generate_rsa_keys.sh
 
+# Bring the database schema up to date
+go run . migrate up || exit 1
+
 # Execute the command passed as arguments
 exec "$@"
@@ -0,0 +1,217 @@
+// Package events pushes events to the connected users in real time.
+// Events are published to a Broker which delivers them to the Hub of every instance,
+// the Hub then fans them out to the subscriptions of the user on this instance
+package events
+
+import (
+	"errors"
+	"log"
+	"sync"
+)
+
+// types of the events
+const (
+	NewQuestion  = "new_question"
+	Notification = "notification"
+)
+
+// number of events a subscription buffers before the next ones are dropped for it
+const subscriptionBufferSize = 32
+
+var ErrHubClosed = errors.New("event hub closed")
+
+// Event is pushed to a user, Data is encoded to JSON when sent
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Message is an event addressed to a user, it is what a Broker carries
+type Message struct {
+	UserId int   `json:"user_id"`
+	Event  Event `json:"event"`
+}
+
+// Broker carries messages between the instances of the server.
+// Start is called once by the hub with the function delivering the messages received to the local subscriptions,
+// a broker backed by an external bus publishes to the bus and calls deliver for every message read from it
+type Broker interface {
+	Start(deliver func(Message)) error
+	Publish(message Message) error
+	Close() error
+}
+
+// LocalBroker delivers the messages to the hub of this instance only, it is enough when a single instance runs
+type LocalBroker struct {
+	mu      sync.RWMutex
+	deliver func(Message)
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{}
+}
+
+func (b *LocalBroker) Start(deliver func(Message)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver = deliver
+	return nil
+}
+
+func (b *LocalBroker) Publish(message Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.deliver == nil {
+		return errors.New("broker not started")
+	}
+	b.deliver(message)
+	return nil
+}
+
+func (b *LocalBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver = nil
+	return nil
+}
+
+// Subscription receives the events of a user until it is unsubscribed or the hub is closed
+type Subscription struct {
+	UserId int
+	events chan Event
+}
+
+// Events returns the channel of the events, it is closed when the subscription ends
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Hub keeps the subscriptions of the users connected to this instance, a user may have many of them
+type Hub struct {
+	mu            sync.RWMutex
+	broker        Broker
+	subscriptions map[int]map[*Subscription]struct{}
+	closed        bool
+}
+
+// NewHub returns a hub receiving its messages from the given broker, nil uses a LocalBroker
+func NewHub(broker Broker) (*Hub, error) {
+	if broker == nil {
+		broker = NewLocalBroker()
+	}
+	h := &Hub{broker: broker, subscriptions: make(map[int]map[*Subscription]struct{})}
+	err := broker.Start(h.deliver)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Subscribe registers a new subscription to the events of the user
+func (h *Hub) Subscribe(userId int) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	s := &Subscription{UserId: userId, events: make(chan Event, subscriptionBufferSize)}
+	if h.subscriptions[userId] == nil {
+		h.subscriptions[userId] = make(map[*Subscription]struct{})
+	}
+	h.subscriptions[userId][s] = struct{}{}
+	return s, nil
+}
+
+// Unsubscribe ends the subscription, it can be called more than once
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	userSubscriptions, ok := h.subscriptions[s.UserId]
+	if !ok {
+		return
+	}
+	if _, ok := userSubscriptions[s]; !ok {
+		return
+	}
+	delete(userSubscriptions, s)
+	if len(userSubscriptions) == 0 {
+		delete(h.subscriptions, s.UserId)
+	}
+	close(s.events)
+}
+
+// CountSubscriptions returns the number of subscriptions of the user on this instance
+func (h *Hub) CountSubscriptions(userId int) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscriptions[userId])
+}
+
+// Publish sends the event to every subscription of the user, through the broker
+func (h *Hub) Publish(userId int, event Event) error {
+	h.mu.RLock()
+	closed := h.closed
+	h.mu.RUnlock()
+	if closed {
+		return ErrHubClosed
+	}
+	return h.broker.Publish(Message{UserId: userId, Event: event})
+}
+
+// deliver fans a message out to the local subscriptions of its user.
+// It never blocks: a subscription whose buffer is full misses the event
+func (h *Hub) deliver(message Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subscriptions[message.UserId] {
+		select {
+		case s.events <- message.Event:
+		default:
+			log.Printf("Dropping %s event for user %d, subscription is too slow\n", message.Event.Type, message.UserId)
+		}
+	}
+}
+
+// Close ends every subscription and closes the broker, the hub cannot be used afterwards
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	for userId, userSubscriptions := range h.subscriptions {
+		for s := range userSubscriptions {
+			close(s.events)
+		}
+		delete(h.subscriptions, userId)
+	}
+	h.mu.Unlock()
+	return h.broker.Close()
+}
+
+var hub *Hub
+var hubOnce sync.Once
+
+// SetHub makes the server use the given hub instead of the default one
+func SetHub(h *Hub) {
+	hubOnce.Do(func() {})
+	hub = h
+}
+
+// GetHub returns the hub set by SetHub, or a hub on a LocalBroker created on first use
+func GetHub() *Hub {
+	hubOnce.Do(func() {
+		// starting a LocalBroker never fails
+		hub, _ = NewHub(nil)
+	})
+	return hub
+}
+
+// Publish sends the event to the user, it is best effort: an error is logged and never fails the caller
+func Publish(userId int, eventType string, data interface{}) {
+	err := GetHub().Publish(userId, Event{Type: eventType, Data: data})
+	if err != nil {
+		log.Printf("Error while publishing %s event to user %d: %s\n", eventType, userId, err.Error())
+	}
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"sync"
+	"testing"
+)
+
+func newHub(t *testing.T, broker Broker) *Hub {
+	h, err := NewHub(broker)
+	if err != nil {
+		t.Fatalf("Error while creating hub: %s", err.Error())
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestPublishToEverySubscriptionOfTheUser(t *testing.T) {
+	h := newHub(t, nil)
+	first, _ := h.Subscribe(1)
+	second, _ := h.Subscribe(1)
+	other, _ := h.Subscribe(2)
+	if h.CountSubscriptions(1) != 2 {
+		t.Errorf("Expected 2 subscriptions, got %d", h.CountSubscriptions(1))
+	}
+
+	err := h.Publish(1, Event{Type: NewQuestion, Data: "question"})
+	if err != nil {
+		t.Fatalf("Error while publishing: %s", err.Error())
+	}
+	for _, s := range []*Subscription{first, second} {
+		event := <-s.Events()
+		if event.Type != NewQuestion || event.Data != "question" {
+			t.Errorf("Expected the question, got %+v", event)
+		}
+	}
+	select {
+	case event := <-other.Events():
+		t.Errorf("Expected no event for another user, got %+v", event)
+	default:
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	h := newHub(t, nil)
+	s, _ := h.Subscribe(1)
+	h.Unsubscribe(s)
+	h.Unsubscribe(s)
+	if _, ok := <-s.Events(); ok {
+		t.Errorf("Expected the events to be closed")
+	}
+	if h.CountSubscriptions(1) != 0 {
+		t.Errorf("Expected no subscription, got %d", h.CountSubscriptions(1))
+	}
+	err := h.Publish(1, Event{Type: Notification})
+	if err != nil {
+		t.Errorf("Expected publishing without subscription to succeed, got %s", err.Error())
+	}
+}
+
+func TestSlowSubscriptionDoesNotBlock(t *testing.T) {
+	h := newHub(t, nil)
+	slow, _ := h.Subscribe(1)
+	for i := 0; i < subscriptionBufferSize+10; i++ {
+		h.Publish(1, Event{Type: Notification, Data: i})
+	}
+	if len(slow.Events()) != subscriptionBufferSize {
+		t.Errorf("Expected the buffer to be full, got %d events", len(slow.Events()))
+	}
+	if event := <-slow.Events(); event.Data != 0 {
+		t.Errorf("Expected the oldest events to be kept, got %+v", event)
+	}
+}
+
+func TestClose(t *testing.T) {
+	h := newHub(t, nil)
+	s, _ := h.Subscribe(1)
+	h.Close()
+	if _, ok := <-s.Events(); ok {
+		t.Errorf("Expected the events to be closed")
+	}
+	h.Unsubscribe(s)
+	if _, err := h.Subscribe(1); err != ErrHubClosed {
+		t.Errorf("Expected ErrHubClosed, got %v", err)
+	}
+	if err := h.Publish(1, Event{}); err != ErrHubClosed {
+		t.Errorf("Expected ErrHubClosed, got %v", err)
+	}
+}
+
+// busBroker stands for an external bus shared by several instances
+type busBroker struct {
+	mu       sync.Mutex
+	bus      *[]func(Message)
+	closed   bool
+	received []Message
+}
+
+func (b *busBroker) Start(deliver func(Message)) error {
+	*b.bus = append(*b.bus, deliver)
+	return nil
+}
+
+func (b *busBroker) Publish(message Message) error {
+	for _, deliver := range *b.bus {
+		deliver(message)
+	}
+	b.mu.Lock()
+	b.received = append(b.received, message)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *busBroker) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestBrokerDeliversToEveryInstance(t *testing.T) {
+	var bus []func(Message)
+	firstBroker := &busBroker{bus: &bus}
+	first := newHub(t, firstBroker)
+	second := newHub(t, &busBroker{bus: &bus})
+	s, _ := second.Subscribe(1)
+
+	first.Publish(1, Event{Type: Notification, Data: "follow"})
+	event := <-s.Events()
+	if event.Data != "follow" {
+		t.Errorf("Expected the event published on the other instance, got %+v", event)
+	}
+	if len(firstBroker.received) != 1 || firstBroker.received[0].UserId != 1 {
+		t.Errorf("Expected the message to go through the broker, got %+v", firstBroker.received)
+	}
+	first.Close()
+	if !firstBroker.closed {
+		t.Errorf("Expected the broker to be closed with the hub")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"project_truthful/client/database"
+)
+
+const grantRoleUsage = "usage: server grant-role <username> <role>"
+
+// runGrantRole implements the grant-role subcommand, which gives a role to a user without going through the API.
+// It creates the first admin, who can then grant the roles through the API. It returns the exit code of the program
+func runGrantRole(args []string, out io.Writer) int {
+	if len(args) != 2 {
+		fmt.Fprintln(out, grantRoleUsage)
+		return 2
+	}
+
+	db, err := database.Init(database.RequireUpToDateSchema())
+	if err != nil {
+		log.Printf("Error while connecting to the database: %s\n", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	err = grantRole(db, args[0], args[1], out)
+	if err != nil {
+		log.Printf("Error while granting role: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func grantRole(db *sql.DB, username string, roleName string, out io.Writer) error {
+	userId, err := database.GetUserId(username, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %s not found", username)
+	} else if err != nil {
+		return err
+	}
+	roleId, err := database.GetRoleId(roleName, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("role %s not found", roleName)
+	} else if err != nil {
+		return err
+	}
+
+	hasRole, err := database.CheckUserHasRole(userId, roleId, db)
+	if err != nil {
+		return err
+	}
+	if hasRole {
+		fmt.Fprintf(out, "%s already has the role %s\n", username, roleName)
+		return nil
+	}
+	err = database.GrantRole(userId, roleId, 0, db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "granted the role %s to %s\n", roleName, username)
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package mailer sends the emails of the server.
+// Production servers go through an SMTP server, the file and log sinks keep the emails local for development and tests
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(message Message) error
+}
+
+var errHeaderInjection = errors.New("email headers cannot contain line breaks")
+
+// format returns the message as sent over SMTP, the headers are refused when they could inject other headers
+func format(from string, message Message) ([]byte, error) {
+	for _, header := range []string{from, message.To, message.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errHeaderInjection
+		}
+	}
+	body := strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n")
+	return []byte("From: " + from + "\r\n" +
+		"To: " + message.To + "\r\n" +
+		"Subject: " + message.Subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body + "\r\n"), nil
+}
+
+// SMTP sends the emails through an SMTP server, authenticating when a username is set
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTP) Send(message Message) error {
+	content, err := format(m.From, message)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	err = smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{message.To}, content)
+	if err != nil {
+		log.Printf("Error sending email to %s, %v\n", message.To, err)
+		return err
+	}
+	return nil
+}
+
+// File appends the emails to a file instead of sending them
+type File struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+func (m *File) Send(message Message) error {
+	content, err := format(m.From, message)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Error opening email file %s, %v\n", m.Path, err)
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(content, []byte("\r\n")...))
+	return err
+}
+
+// Log writes the emails to the log instead of sending them
+type Log struct{}
+
+func (Log) Send(message Message) error {
+	_, err := format("", message)
+	if err != nil {
+		return err
+	}
+	log.Printf("Email to %s: %s\n%s\n", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// Init sets the mailer of the server from the env variables:
+// MAILER is smtp, file or log (the default).
+// The smtp mailer needs SMTP_HOST and MAIL_FROM, SMTP_PORT is 587 when empty and SMTP_USERNAME and SMTP_PASSWORD are optional.
+// The file mailer appends the emails to MAILER_FILE_PATH
+func Init() error {
+	m, err := FromEnv()
+	if err != nil {
+		return err
+	}
+	SetMailer(m)
+	return nil
+}
+
+// FromEnv returns the mailer configured by the env variables described by Init
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	switch os.Getenv("MAILER") {
+	case "", "log":
+		return Log{}, nil
+	case "file":
+		path := os.Getenv("MAILER_FILE_PATH")
+		if path == "" {
+			return nil, errors.New("MAILER_FILE_PATH must be set to write the emails to a file")
+		}
+		return &File{Path: path, From: from}, nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" || from == "" {
+			return nil, errors.New("SMTP_HOST and MAIL_FROM must be set to send the emails over SMTP")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return SMTP{Host: host, Port: port, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD"), From: from}, nil
+	}
+	return nil, fmt.Errorf("invalid MAILER %s, must be smtp, file or log", os.Getenv("MAILER"))
+}
+
+var mailer Mailer
+var mailerOnce sync.Once
+
+// SetMailer replaces the mailer of the server, tests use it to catch the emails
+func SetMailer(m Mailer) {
+	mailerOnce.Do(func() {})
+	mailer = m
+}
+
+// GetMailer returns the mailer set by Init or SetMailer, or the one built from the env variables on first use
+func GetMailer() Mailer {
+	mailerOnce.Do(func() {
+		var err error
+		mailer, err = FromEnv()
+		if err != nil {
+			log.Printf("Error while building mailer, emails are written to the log: %s\n", err.Error())
+			mailer = Log{}
+		}
+	})
+	return mailer
+}
+
+// Send sends the message with the mailer of the server
+func Send(message Message) error {
+	return GetMailer().Send(message)
+}
@@ -0,0 +1,135 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	content, err := format("truthful@truthful.test", Message{To: "toto@toto.fr", Subject: "Hello", Body: "line 1\nline 2"})
+	if err != nil {
+		t.Fatalf("Error while formatting message: %s", err.Error())
+	}
+	text := string(content)
+	if !strings.HasPrefix(text, "From: truthful@truthful.test\r\nTo: toto@toto.fr\r\nSubject: Hello\r\n") || !strings.HasSuffix(text, "\r\n\r\nline 1\r\nline 2\r\n") {
+		t.Errorf("Unexpected message %q", text)
+	}
+
+	for _, message := range []Message{{To: "toto@toto.fr\r\nBcc: titi@titi.fr"}, {To: "toto@toto.fr", Subject: "Hello\nBcc: titi@titi.fr"}} {
+		_, err := format("truthful@truthful.test", message)
+		if err != errHeaderInjection {
+			t.Errorf("Expected errHeaderInjection for %+v, got %v", message, err)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emails.txt")
+	m := &File{Path: path, From: "truthful@truthful.test"}
+	m.Send(Message{To: "toto@toto.fr", Subject: "First", Body: "first body"})
+	m.Send(Message{To: "titi@titi.fr", Subject: "Second", Body: "second body"})
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error while reading emails: %s", err.Error())
+	}
+	if !strings.Contains(string(content), "first body") || !strings.Contains(string(content), "To: titi@titi.fr") {
+		t.Errorf("Expected both emails in the file, got %q", content)
+	}
+}
+
+// serveSMTP answers a single SMTP session and returns the data of the email it received
+func serveSMTP(listener net.Listener) <-chan string {
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ready\r\n"))
+		data := ""
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				received <- data
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					conn.Write([]byte("250 OK\r\n"))
+					continue
+				}
+				data += line
+				continue
+			}
+			switch strings.ToUpper(strings.Fields(line)[0]) {
+			case "DATA":
+				inData = true
+				conn.Write([]byte("354 go ahead\r\n"))
+			case "QUIT":
+				conn.Write([]byte("221 bye\r\n"))
+				received <- data
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+	return received
+}
+
+func TestSMTP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error while listening: %s", err.Error())
+	}
+	defer listener.Close()
+	received := serveSMTP(listener)
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	m := SMTP{Host: host, Port: port, From: "truthful@truthful.test"}
+	err = m.Send(Message{To: "toto@toto.fr", Subject: "Hello", Body: "body"})
+	if err != nil {
+		t.Fatalf("Error while sending email: %s", err.Error())
+	}
+	data := <-received
+	if !strings.Contains(data, "To: toto@toto.fr\r\n") || !strings.Contains(data, "\r\nbody\r\n") {
+		t.Errorf("Unexpected email %q", data)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("MAILER", "")
+	if m, err := FromEnv(); err != nil || m != (Log{}) {
+		t.Errorf("Expected the log mailer by default, got %+v, %v", m, err)
+	}
+
+	t.Setenv("MAILER", "file")
+	t.Setenv("MAILER_FILE_PATH", "")
+	if _, err := FromEnv(); err == nil {
+		t.Errorf("Expected the file mailer to need a path")
+	}
+
+	t.Setenv("MAILER", "smtp")
+	t.Setenv("SMTP_HOST", "smtp.truthful.test")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("MAIL_FROM", "truthful@truthful.test")
+	m, err := FromEnv()
+	if err != nil || m.(SMTP).Port != "587" {
+		t.Errorf("Expected the smtp mailer on port 587, got %+v, %v", m, err)
+	}
+
+	t.Setenv("MAILER", "pigeon")
+	if _, err := FromEnv(); err == nil {
+		t.Errorf("Expected an invalid mailer to be refused")
+	}
+}
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"project_truthful/client"
 	"project_truthful/client/database"
+	"project_truthful/client/iphash"
+	"project_truthful/client/pagination"
 	"project_truthful/client/token"
+	"project_truthful/events"
+	"project_truthful/mailer"
+	"project_truthful/moderation/filter"
 	"project_truthful/routes"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -13,7 +25,17 @@ import (
 
 const DEFAULT_PORT = "8080"
 
+// time given to the requests in progress to end when the server shuts down
+const SHUTDOWN_TIMEOUT = 10 * time.Second
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "grant-role" {
+		os.Exit(runGrantRole(os.Args[2:], os.Stdout))
+	}
+
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
 		log.Printf("PORT not found in env, using default port %s\n", DEFAULT_PORT)
@@ -37,7 +59,7 @@ func main() {
 	routes.SetupRoutes(router)
 
 	var err error
-	database.DB, err = database.Init()
+	database.DB, err = database.Init(database.RequireUpToDateSchema())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -45,12 +67,49 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = iphash.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = pagination.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = filter.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = mailer.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Start the server
 	log.Println("Starting server...")
-	err = router.Run(":" + port)
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+
+	// event streams never end on their own, closing the hub ends them before waiting for the other requests
+	log.Println("Shutting down server...")
+	err = events.GetHub().Close()
+	if err != nil {
+		log.Printf("Error while closing event hub: %s\n", err.Error())
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// the emails of the last requests are still being sent
+	client.WaitBackgroundJobs()
 	log.Println("Server shutted down.")
 }
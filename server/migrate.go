@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"project_truthful/client/database"
+	"project_truthful/dialect"
+	"project_truthful/migrations"
+)
+
+const migrateUsage = "usage: server migrate up|down|status"
+
+// runMigrate implements the migrate subcommand and returns the exit code of the program
+func runMigrate(args []string, out io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(out, migrateUsage)
+		return 2
+	}
+	if args[0] != "up" && args[0] != "down" && args[0] != "status" {
+		fmt.Fprintln(out, migrateUsage)
+		return 2
+	}
+
+	db, err := database.Init()
+	if err != nil {
+		log.Printf("Error while connecting to the database: %s\n", err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	err = migrate(db, database.Dialect, args[0], out)
+	if err != nil {
+		log.Printf("Error while running migrations: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func migrate(db *sql.DB, d dialect.Dialect, command string, out io.Writer) error {
+	switch command {
+	case "up":
+		applied, err := migrations.Up(db, d)
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+	case "down":
+		migration, reverted, err := migrations.Down(db, d)
+		if err != nil {
+			return err
+		}
+		if !reverted {
+			fmt.Fprintln(out, "no migration to revert")
+		} else {
+			fmt.Fprintf(out, "reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "status":
+		statuses, err := migrations.GetStatus(db, d)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				fmt.Fprintf(out, "%04d_%s\tpending\n", status.Version, status.Name)
+			} else {
+				fmt.Fprintf(out, "%04d_%s\tapplied at %s\n", status.Version, status.Name, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %s", command)
+	}
+	return nil
+}
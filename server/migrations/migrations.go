@@ -0,0 +1,312 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"project_truthful/dialect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// files are named sql/<dialect>/<version>_<name>.up.sql and sql/<dialect>/<version>_<name>.down.sql,
+// every dialect has the same migrations
+//
+//go:embed sql/mysql/*.sql sql/sqlite/*.sql
+var files embed.FS
+
+var ErrSchemaBehind = errors.New("database schema is behind, run the migrations")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Load returns the embedded migrations of the dialect ordered by version
+func Load(d dialect.Dialect) ([]Migration, error) {
+	return load(files, "sql/"+string(d))
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		if strings.HasSuffix(fileName, ".up.sql") {
+			direction = "up"
+		} else if strings.HasSuffix(fileName, ".down.sql") {
+			direction = "down"
+		} else {
+			continue
+		}
+
+		versionStr, name, found := strings.Cut(strings.TrimSuffix(fileName, "."+direction+".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name %s", fileName)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", fileName)
+		}
+		content, err := fs.ReadFile(fsys, dir+"/"+fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// LatestVersion returns the version the schema is at once every migration is applied
+func LatestVersion(d dialect.Dialect) (int, error) {
+	migrations, err := Load(d)
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+func ensureTable(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version int unsigned NOT NULL PRIMARY KEY, name varchar(255) NOT NULL, applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP)")
+	if err != nil {
+		log.Printf("Error creating schema_migrations table, %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func appliedVersions(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		log.Printf("Error getting applied migrations, %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		err := rows.Scan(&version, &appliedAt)
+		if err != nil {
+			log.Printf("Error scanning applied migration, %v\n", err)
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, nil
+}
+
+// CurrentVersion returns the version of the latest applied migration, 0 if none was applied
+func CurrentVersion(db *sql.DB) (int, error) {
+	err := ensureTable(db)
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		log.Printf("Error getting schema version, %v\n", err)
+		return 0, err
+	}
+	return version, nil
+}
+
+// CheckUpToDate returns ErrSchemaBehind if some migrations are not applied yet
+func CheckUpToDate(db *sql.DB, d dialect.Dialect) error {
+	latest, err := LatestVersion(d)
+	if err != nil {
+		return err
+	}
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return err
+	}
+	if current < latest {
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaBehind, current, latest)
+	}
+	return nil
+}
+
+// Up applies every pending migration in order and returns the applied ones
+func Up(db *sql.DB, d dialect.Dialect) ([]Migration, error) {
+	migrations, err := Load(d)
+	if err != nil {
+		return nil, err
+	}
+	err = ensureTable(db)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		log.Printf("Applying migration %d_%s\n", migration.Version, migration.Name)
+		err = run(db, migration.Up, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name)
+		if err != nil {
+			log.Printf("Error applying migration %d_%s, %v\n", migration.Version, migration.Name, err)
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the latest applied migration, it returns false if there was nothing to revert
+func Down(db *sql.DB, d dialect.Dialect) (Migration, bool, error) {
+	migrations, err := Load(d)
+	if err != nil {
+		return Migration{}, false, err
+	}
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return Migration{}, false, err
+	}
+	if current == 0 {
+		return Migration{}, false, nil
+	}
+
+	for _, migration := range migrations {
+		if migration.Version != current {
+			continue
+		}
+		log.Printf("Reverting migration %d_%s\n", migration.Version, migration.Name)
+		err = run(db, migration.Down, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		if err != nil {
+			log.Printf("Error reverting migration %d_%s, %v\n", migration.Version, migration.Name, err)
+			return Migration{}, false, err
+		}
+		return migration, true, nil
+	}
+	return Migration{}, false, fmt.Errorf("applied migration %d is unknown to this server", current)
+}
+
+// GetStatus returns every known migration along with the date it was applied at, if it was
+func GetStatus(db *sql.DB, d dialect.Dialect) ([]Status, error) {
+	migrations, err := Load(d)
+	if err != nil {
+		return nil, err
+	}
+	err = ensureTable(db)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for _, migration := range migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// run executes the statements of a migration file and records it in a transaction, on a single connection
+// so that session settings such as foreign_key_checks apply to every statement.
+// SQLite rolls the whole migration back when a statement fails. MySQL commits every schema change implicitly,
+// so a failed MySQL migration can be left half applied and is run again from the start: the MySQL files only
+// change the schema after checking information_schema, and their data changes can be repeated
+func run(db *sql.DB, script string, record string, recordArgs ...any) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range SplitStatements(script) {
+		_, err = tx.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("%w, in statement: %s", err, statement)
+		}
+	}
+	_, err = tx.ExecContext(ctx, record, recordArgs...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SplitStatements splits a migration file on semicolons, dropping comment lines.
+// Migration files must not contain semicolons inside string literals
+func SplitStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	statements := []string{}
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		statement = strings.TrimSpace(statement)
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
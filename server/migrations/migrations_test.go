@@ -0,0 +1,325 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"project_truthful/dialect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "modernc.org/sqlite"
+)
+
+func TestSplitStatements(t *testing.T) {
+	statements := SplitStatements("-- comment\nCREATE TABLE a (id int);\n\n  -- another comment\nINSERT INTO a VALUES (1);\n")
+	if len(statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d: %v", len(statements), statements)
+	}
+	if statements[0] != "CREATE TABLE a (id int)" || statements[1] != "INSERT INTO a VALUES (1)" {
+		t.Errorf("Unexpected statements %v", statements)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_second.up.sql":   {Data: []byte("up 2")},
+		"sql/0002_second.down.sql": {Data: []byte("down 2")},
+		"sql/0001_first.up.sql":    {Data: []byte("up 1")},
+		"sql/0001_first.down.sql":  {Data: []byte("down 1")},
+		"sql/README.md":            {Data: []byte("ignored")},
+	}
+	migrations, err := load(fsys, "sql")
+	if err != nil {
+		t.Fatalf("Error while loading migrations: %s", err.Error())
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "second" || migrations[1].Down != "down 2" {
+		t.Errorf("Unexpected migrations %+v", migrations)
+	}
+
+	// missing down file
+	fsys = fstest.MapFS{"sql/0001_first.up.sql": {Data: []byte("up 1")}}
+	_, err = load(fsys, "sql")
+	if err == nil {
+		t.Errorf("Expected error for a migration without down file")
+	}
+
+	// invalid version
+	fsys = fstest.MapFS{"sql/first.up.sql": {Data: []byte("up 1")}}
+	_, err = load(fsys, "sql")
+	if err == nil {
+		t.Errorf("Expected error for a migration without version")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	mysqlMigrations, err := Load(dialect.MySQL)
+	if err != nil {
+		t.Fatalf("Error while loading embedded migrations: %s", err.Error())
+	}
+	if len(mysqlMigrations) == 0 || mysqlMigrations[0].Version != 1 || mysqlMigrations[0].Name != "init" {
+		t.Fatalf("Expected the first migration to be 0001_init, got %+v", mysqlMigrations)
+	}
+
+	for _, d := range dialect.All {
+		migrations, err := Load(d)
+		if err != nil {
+			t.Fatalf("Error while loading embedded %s migrations: %s", d, err.Error())
+		}
+		if len(migrations) != len(mysqlMigrations) {
+			t.Fatalf("Expected %d %s migrations, got %d", len(mysqlMigrations), d, len(migrations))
+		}
+		for i, migration := range migrations {
+			if migration.Version != i+1 {
+				t.Errorf("Expected %s migration %d to have version %d, got %d", d, i, i+1, migration.Version)
+			}
+			if migration.Name != mysqlMigrations[i].Name {
+				t.Errorf("Expected %s migration %d to be named %s, got %s", d, migration.Version, mysqlMigrations[i].Name, migration.Name)
+			}
+			if len(SplitStatements(migration.Up)) == 0 || len(SplitStatements(migration.Down)) == 0 {
+				t.Errorf("Migration %s/%d_%s has no statement", d, migration.Version, migration.Name)
+			}
+		}
+	}
+}
+
+func TestCheckUpToDate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	latest, err := LatestVersion(dialect.MySQL)
+	if err != nil {
+		t.Fatalf("Error while getting latest version: %s", err.Error())
+	}
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(latest - 1))
+	err = CheckUpToDate(db, dialect.MySQL)
+	if !errors.Is(err, ErrSchemaBehind) {
+		t.Errorf("Expected ErrSchemaBehind, got %v", err)
+	}
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(latest))
+	err = CheckUpToDate(db, dialect.MySQL)
+	if err != nil {
+		t.Errorf("Expected schema to be up to date, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	migrations, err := Load(dialect.MySQL)
+	if err != nil {
+		t.Fatalf("Error while loading migrations: %s", err.Error())
+	}
+	latest := migrations[len(migrations)-1]
+
+	// every migration but the latest one is applied
+	applied := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, migration := range migrations[:len(migrations)-1] {
+		applied.AddRow(migration.Version, time.Now())
+	}
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(applied)
+	mock.ExpectBegin()
+	for range SplitStatements(latest.Up) {
+		mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(latest.Version, latest.Name).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	done, err := Up(db, dialect.MySQL)
+	if err != nil {
+		t.Fatalf("Error while applying migrations: %s", err.Error())
+	}
+	if len(done) != 1 || done[0].Version != latest.Version {
+		t.Errorf("Expected only migration %d to be applied, got %+v", latest.Version, done)
+	}
+
+	// a failing statement stops the migration before it is recorded
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(".+").WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	done, err = Up(db, dialect.MySQL)
+	if err == nil || len(done) != 0 {
+		t.Errorf("Expected error and no applied migration, got %+v, %v", done, err)
+	}
+
+	// down reverts the latest migration
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(latest.Version))
+	mock.ExpectBegin()
+	for range SplitStatements(latest.Down) {
+		mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(latest.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	reverted, ok, err := Down(db, dialect.MySQL)
+	if err != nil || !ok || reverted.Version != latest.Version {
+		t.Errorf("Expected migration %d to be reverted, got %+v, %t, %v", latest.Version, reverted, ok, err)
+	}
+
+	// nothing to revert
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	_, ok, err = Down(db, dialect.MySQL)
+	if err != nil || ok {
+		t.Errorf("Expected nothing to revert, got %t, %v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	statuses, err := GetStatus(db, dialect.MySQL)
+	if err != nil {
+		t.Fatalf("Error while getting status: %s", err.Error())
+	}
+	if len(statuses) == 0 || statuses[0].AppliedAt == nil {
+		t.Errorf("Expected migration 1 to be applied, got %+v", statuses)
+	}
+	for _, status := range statuses[1:] {
+		if status.AppliedAt != nil {
+			t.Errorf("Expected migration %d to be pending", status.Version)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
+
+// TestSQLite applies and reverts every migration on a real SQLite database
+func TestSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("Error while opening database: %s", err.Error())
+	}
+	defer db.Close()
+	migrations, err := Load(dialect.SQLite)
+	if err != nil {
+		t.Fatalf("Error while loading migrations: %s", err.Error())
+	}
+
+	applied, err := Up(db, dialect.SQLite)
+	if err != nil || len(applied) != len(migrations) {
+		t.Fatalf("Expected %d migrations to be applied, got %d, %v", len(migrations), len(applied), err)
+	}
+	err = CheckUpToDate(db, dialect.SQLite)
+	if err != nil {
+		t.Errorf("Expected schema to be up to date, got %v", err)
+	}
+	var roleCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM role_permission").Scan(&roleCount)
+	if err != nil || roleCount != 14 {
+		t.Errorf("Expected 14 role permissions to be seeded, got %d, %v", roleCount, err)
+	}
+
+	// the free-text actions logged before the audit log are typed, and kept in the payload
+	for version, _ := CurrentVersion(db); version > 10; version, _ = CurrentVersion(db) {
+		_, ok, err := Down(db, dialect.SQLite)
+		if err != nil || !ok {
+			t.Fatalf("Expected migration to be reverted, got %t, %v", ok, err)
+		}
+	}
+	_, err = db.Exec("INSERT INTO moderation_logging (action) VALUES ('pardonUser')")
+	if err != nil {
+		t.Fatalf("Error while logging legacy action: %s", err.Error())
+	}
+	_, err = Up(db, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("Error while applying migration again: %s", err.Error())
+	}
+	var action, payload string
+	err = db.QueryRow("SELECT action, payload FROM moderation_logging").Scan(&action, &payload)
+	if err != nil || action != "user.pardon" || payload != `{"legacy_action":"pardonUser"}` {
+		t.Errorf("Expected legacy action to be typed, got %s %s, %v", action, payload, err)
+	}
+
+	for range migrations {
+		_, ok, err := Down(db, dialect.SQLite)
+		if err != nil || !ok {
+			t.Fatalf("Expected migration to be reverted, got %t, %v", ok, err)
+		}
+	}
+	version, err := CurrentVersion(db)
+	if err != nil || version != 0 {
+		t.Errorf("Expected every migration to be reverted, got version %d, %v", version, err)
+	}
+
+	// reverted migrations can be applied again
+	applied, err = Up(db, dialect.SQLite)
+	if err != nil || len(applied) != len(migrations) {
+		t.Errorf("Expected %d migrations to be applied again, got %d, %v", len(migrations), len(applied), err)
+	}
+}
+
+// TestSQLiteRollback checks that a failing SQLite migration leaves neither its changes nor its record behind
+func TestSQLiteRollback(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Error while opening database: %s", err.Error())
+	}
+	defer db.Close()
+	err = ensureTable(db)
+	if err != nil {
+		t.Fatalf("Error while creating schema_migrations: %s", err.Error())
+	}
+
+	err = run(db, "CREATE TABLE `created` (`id` integer);\nINSERT INTO `missing` VALUES (1);", "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", 1, "failing")
+	if err == nil {
+		t.Fatalf("Expected the migration to fail")
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'created'").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected the table of the failed migration to be rolled back")
+	}
+	version, err := CurrentVersion(db)
+	if err != nil || version != 0 {
+		t.Errorf("Expected the failed migration not to be recorded, got version %d, %v", version, err)
+	}
+}
+
+// TestMySQLSchemaChangesAreGuarded checks that the MySQL migrations, which can't be rolled back, only change the schema
+// through statements prepared after checking information_schema, so that a half applied migration can be run again
+func TestMySQLSchemaChangesAreGuarded(t *testing.T) {
+	migrations, err := Load(dialect.MySQL)
+	if err != nil {
+		t.Fatalf("Error while loading migrations: %s", err.Error())
+	}
+	for _, migration := range migrations {
+		for _, script := range []string{migration.Up, migration.Down} {
+			for _, statement := range SplitStatements(script) {
+				upper := strings.ToUpper(statement)
+				unguarded := strings.HasPrefix(upper, "CREATE INDEX") || strings.HasPrefix(upper, "DROP INDEX") ||
+					(strings.HasPrefix(upper, "ALTER TABLE") && (strings.Contains(upper, " ADD ") || strings.Contains(upper, " DROP ")))
+				if unguarded {
+					t.Errorf("Migration %d_%s changes the schema without checking it first: %s", migration.Version, migration.Name, statement)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+-- Initial schema, formerly sql/init.sql.
+-- Tables are only created when missing so that databases created from init.sql can adopt the migrations.
+
+SET NAMES utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `user` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `username` varchar(30) NOT NULL,
+  `email` varchar(319) NOT NULL,
+  `display_name` varchar(30) NOT NULL,
+  `password` char(60) NOT NULL,
+  `birthdate` date NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `question` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `receiver_id` int unsigned NOT NULL,
+  `author_id` int unsigned NULL,
+  `author_ip_address` varchar(45) NOT NULL,
+  `is_author_anonymous` tinyint(1) NOT NULL DEFAULT '1',
+  `text` varchar(500) NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `has_been_deleted` tinyint(1) NOT NULL DEFAULT '0',
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `author_id` (`author_id`),
+  CONSTRAINT `question_ibfk_1` FOREIGN KEY (`author_id`) REFERENCES `user` (`id`),
+  KEY `receiver_id` (`receiver_id`),
+  CONSTRAINT `question_ibfk_2` FOREIGN KEY (`receiver_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `answer` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `question_id` int unsigned NOT NULL,
+  `text` varchar(1000) NOT NULL,
+  `answerer_ip_address` varchar(45) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `has_been_deleted` tinyint(1) NOT NULL DEFAULT '0',
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `question_id` (`question_id`),
+  CONSTRAINT `answer_ibfk_1` FOREIGN KEY (`question_id`) REFERENCES `question` (`id`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `answer_ibfk_2` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+
+CREATE TABLE IF NOT EXISTS `answer_like` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `answer_id` int unsigned NOT NULL,
+  `user_id` int unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `answer_id` (`answer_id`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `answer_like_ibfk_1` FOREIGN KEY (`answer_id`) REFERENCES `answer` (`id`),
+  CONSTRAINT `answer_like_ibfk_2` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+
+CREATE TABLE IF NOT EXISTS `follow` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `follower` int unsigned NOT NULL,
+  `followed` int unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `follower` (`follower`),
+  KEY `followed` (`followed`),
+  CONSTRAINT `follow_ibfk_1` FOREIGN KEY (`follower`) REFERENCES `user` (`id`),
+  CONSTRAINT `follow_ibfk_2` FOREIGN KEY (`followed`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `ban` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `author_id` int unsigned NOT NULL,
+  `reason` varchar(1000) NULL DEFAULT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `user_id` (`user_id`),
+  KEY `author_id` (`author_id`),
+  CONSTRAINT `ban_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `ban_ibfk_2` FOREIGN KEY (`author_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `pardon` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `ban_id` int unsigned NOT NULL,
+  `pardoner_id` int unsigned NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `ban_id` (`ban_id`),
+  KEY `pardoner_id` (`pardoner_id`),
+  CONSTRAINT `pardon_ibfk_1` FOREIGN KEY (`ban_id`) REFERENCES `ban` (`id`),
+  CONSTRAINT `pardon_ibfk_2` FOREIGN KEY (`pardoner_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `moderation_logging` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `action` varchar(100) NOT NULL,
+  `target_id` int unsigned NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `user_id` (`user_id`),
+  KEY `target_id` (`target_id`),
+  CONSTRAINT `moderation_logging_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `moderation_logging_ibfk_2` FOREIGN KEY (`target_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `rate_limit` (
+  `ip_address` varchar(45) NOT NULL PRIMARY KEY,
+  `request_count` int unsigned NOT NULL DEFAULT '0',
+  `last_updated` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `oauth_provider` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+INSERT INTO `oauth_provider` (`name`) SELECT 'Google' FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM `oauth_provider` WHERE `name` = 'Google');
+
+CREATE TABLE IF NOT EXISTS `oauth_login` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `oauth_provider_id` int unsigned NOT NULL,
+  `subject_id` varchar(255) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `user_id` (`user_id`),
+  KEY `oauth_provider_id` (`oauth_provider_id`),
+  CONSTRAINT `oauth_login_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `oauth_login_ibfk_2` FOREIGN KEY (`oauth_provider_id`) REFERENCES `oauth_provider` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `session` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `session_id` varchar(64) NOT NULL,
+  `user_id` int unsigned NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `ip_address` varchar(45) NOT NULL,
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `rotated_at` timestamp NULL DEFAULT NULL,
+  `revoked_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash` (`token_hash`),
+  KEY `session_id` (`session_id`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `session_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `role` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(50) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+INSERT IGNORE INTO `role` (`name`) VALUES ('admin'), ('moderator');
+
+CREATE TABLE IF NOT EXISTS `permission` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(50) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+INSERT IGNORE INTO `permission` (`name`) VALUES ('users.ban'), ('users.pardon'), ('questions.view_any'), ('roles.manage');
+
+CREATE TABLE IF NOT EXISTS `role_permission` (
+  `role_id` int unsigned NOT NULL,
+  `permission_id` int unsigned NOT NULL,
+  PRIMARY KEY (`role_id`, `permission_id`),
+  KEY `permission_id` (`permission_id`),
+  CONSTRAINT `role_permission_ibfk_1` FOREIGN KEY (`role_id`) REFERENCES `role` (`id`),
+  CONSTRAINT `role_permission_ibfk_2` FOREIGN KEY (`permission_id`) REFERENCES `permission` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+INSERT IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` = 'admin' OR (`role`.`name` = 'moderator' AND `permission`.`name` IN ('users.ban', 'users.pardon', 'questions.view_any'));
+
+CREATE TABLE IF NOT EXISTS `user_role` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `role_id` int unsigned NOT NULL,
+  `granted_by` int unsigned NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `user_role` (`user_id`, `role_id`),
+  KEY `role_id` (`role_id`),
+  KEY `granted_by` (`granted_by`),
+  CONSTRAINT `user_role_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `user_role_ibfk_2` FOREIGN KEY (`role_id`) REFERENCES `role` (`id`),
+  CONSTRAINT `user_role_ibfk_3` FOREIGN KEY (`granted_by`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,4 @@
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'answer' AND INDEX_NAME = 'answer_user_created_at') > 0, 'DROP INDEX `answer_user_created_at` ON `answer`', 'DO 0');
+PREPARE timeline FROM @statement;
+EXECUTE timeline;
+DEALLOCATE PREPARE timeline;
@@ -0,0 +1,8 @@
+-- The timeline merges the answers of the followed users, newest first.
+-- The index lets it read the latest answers of each followed user without scanning all of them.
+-- MySQL has no CREATE INDEX IF NOT EXISTS, the index is only created when missing.
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'answer' AND INDEX_NAME = 'answer_user_created_at') = 0, 'CREATE INDEX `answer_user_created_at` ON `answer` (`user_id`, `created_at`, `id`)', 'DO 0');
+PREPARE timeline FROM @statement;
+EXECUTE timeline;
+DEALLOCATE PREPARE timeline;
@@ -0,0 +1,14 @@
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'follow' AND INDEX_NAME = 'follow_follower_created_at') > 0, 'DROP INDEX `follow_follower_created_at` ON `follow`', 'DO 0');
+PREPARE follow_unique FROM @statement;
+EXECUTE follow_unique;
+DEALLOCATE PREPARE follow_unique;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'follow' AND INDEX_NAME = 'follow_followed_created_at') > 0, 'DROP INDEX `follow_followed_created_at` ON `follow`', 'DO 0');
+PREPARE follow_unique FROM @statement;
+EXECUTE follow_unique;
+DEALLOCATE PREPARE follow_unique;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'follow' AND COLUMN_NAME = 'created_at') > 0, 'ALTER TABLE `follow` DROP INDEX `follower_followed`, DROP COLUMN `created_at`', 'DO 0');
+PREPARE follow_unique FROM @statement;
+EXECUTE follow_unique;
+DEALLOCATE PREPARE follow_unique;
@@ -0,0 +1,20 @@
+-- A user follows another one at most once, the followers and following lists are ordered by follow date.
+-- Duplicates inserted by concurrent follow requests are removed first, the oldest follow is kept.
+-- The column and the indexes are only added when missing.
+
+DELETE newer FROM `follow` newer JOIN `follow` older ON older.`follower` = newer.`follower` AND older.`followed` = newer.`followed` AND older.`id` < newer.`id`;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'follow' AND COLUMN_NAME = 'created_at') = 0, 'ALTER TABLE `follow` ADD COLUMN `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, ADD UNIQUE KEY `follower_followed` (`follower`, `followed`)', 'DO 0');
+PREPARE follow_unique FROM @statement;
+EXECUTE follow_unique;
+DEALLOCATE PREPARE follow_unique;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'follow' AND INDEX_NAME = 'follow_followed_created_at') = 0, 'CREATE INDEX `follow_followed_created_at` ON `follow` (`followed`, `created_at`, `id`)', 'DO 0');
+PREPARE follow_unique FROM @statement;
+EXECUTE follow_unique;
+DEALLOCATE PREPARE follow_unique;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'follow' AND INDEX_NAME = 'follow_follower_created_at') = 0, 'CREATE INDEX `follow_follower_created_at` ON `follow` (`follower`, `created_at`, `id`)', 'DO 0');
+PREPARE follow_unique FROM @statement;
+EXECUTE follow_unique;
+DEALLOCATE PREPARE follow_unique;
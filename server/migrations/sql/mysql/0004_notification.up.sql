@@ -0,0 +1,23 @@
+-- In-app notifications. The actor is the user who caused the notification,
+-- it stays NULL for anonymous questions so that the asker can't be found from the notification.
+
+CREATE TABLE IF NOT EXISTS `notification` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `type` varchar(32) NOT NULL,
+  `actor_id` int unsigned NULL DEFAULT NULL,
+  `question_id` int unsigned NULL DEFAULT NULL,
+  `answer_id` int unsigned NULL DEFAULT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `read_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `user_created_at` (`user_id`, `created_at`, `id`),
+  KEY `user_read_at` (`user_id`, `read_at`),
+  KEY `actor_id` (`actor_id`),
+  KEY `question_id` (`question_id`),
+  KEY `answer_id` (`answer_id`),
+  CONSTRAINT `notification_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `notification_ibfk_2` FOREIGN KEY (`actor_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `notification_ibfk_3` FOREIGN KEY (`question_id`) REFERENCES `question` (`id`),
+  CONSTRAINT `notification_ibfk_4` FOREIGN KEY (`answer_id`) REFERENCES `answer` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,28 @@
+-- A user blocks another one to stop them from asking questions, following them and liking their answers.
+-- A user mutes another one to stop being notified of their actions and to hide their answers from the timeline.
+
+CREATE TABLE IF NOT EXISTS `user_block` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `blocker_id` int unsigned NOT NULL,
+  `blocked_id` int unsigned NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `blocker_blocked` (`blocker_id`, `blocked_id`),
+  KEY `blocker_created_at` (`blocker_id`, `created_at`, `id`),
+  KEY `blocked_id` (`blocked_id`),
+  CONSTRAINT `user_block_ibfk_1` FOREIGN KEY (`blocker_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `user_block_ibfk_2` FOREIGN KEY (`blocked_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `user_mute` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `muter_id` int unsigned NOT NULL,
+  `muted_id` int unsigned NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `muter_muted` (`muter_id`, `muted_id`),
+  KEY `muter_created_at` (`muter_id`, `created_at`, `id`),
+  KEY `muted_id` (`muted_id`),
+  CONSTRAINT `user_mute_ibfk_1` FOREIGN KEY (`muter_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `user_mute_ibfk_2` FOREIGN KEY (`muted_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,18 @@
+-- A receiver blocks the asker of an anonymous question without learning who they are.
+-- Only a salted hash of the IP address of the asker is kept, the question is kept to show which asker was blocked.
+-- A block without expiration date is permanent.
+
+CREATE TABLE IF NOT EXISTS `asker_block` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `receiver_id` int unsigned NOT NULL,
+  `ip_hash` char(64) NOT NULL,
+  `question_id` int unsigned NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `receiver_ip_hash` (`receiver_id`, `ip_hash`),
+  KEY `receiver_created_at` (`receiver_id`, `created_at`, `id`),
+  KEY `question_id` (`question_id`),
+  CONSTRAINT `asker_block_ibfk_1` FOREIGN KEY (`receiver_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `asker_block_ibfk_2` FOREIGN KEY (`question_id`) REFERENCES `question` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,13 @@
+-- Settings of the inbox of a user, a user without a row uses the default settings.
+-- guest_questions tells if logged out visitors can ask: allowed, captcha (after solving a captcha) or disabled.
+
+CREATE TABLE IF NOT EXISTS `user_settings` (
+  `user_id` int unsigned NOT NULL,
+  `allow_anonymous_questions` tinyint(1) NOT NULL DEFAULT '1',
+  `guest_questions` varchar(16) NOT NULL DEFAULT 'allowed',
+  `following_only` tinyint(1) NOT NULL DEFAULT '0',
+  `inbox_paused` tinyint(1) NOT NULL DEFAULT '0',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`),
+  CONSTRAINT `user_settings_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
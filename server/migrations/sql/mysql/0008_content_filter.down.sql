@@ -0,0 +1,10 @@
+DELETE `role_permission` FROM `role_permission` JOIN `permission` ON `permission`.`id` = `role_permission`.`permission_id`
+WHERE `permission`.`name` = 'content.review';
+DELETE FROM `permission` WHERE `name` = 'content.review';
+
+DROP TABLE IF EXISTS `muted_word`;
+DROP TABLE IF EXISTS `held_content`;
+
+-- the decisions of the filters have no moderator to keep them
+DELETE FROM `moderation_logging` WHERE `user_id` IS NULL;
+ALTER TABLE `moderation_logging` MODIFY `user_id` int unsigned NOT NULL;
@@ -0,0 +1,50 @@
+-- The content filters hold the questions and answers they are unsure about until a moderator reviews them,
+-- a held content is only written to the question or answer table once approved.
+-- For answers, receiver_id is the receiver of the question, who is also the author of the answer.
+-- Users mute the words they don't want to see in the questions they get.
+-- The decisions of the filters are logged without moderator.
+
+ALTER TABLE `moderation_logging` MODIFY `user_id` int unsigned NULL;
+
+CREATE TABLE IF NOT EXISTS `held_content` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `content_type` varchar(16) NOT NULL,
+  `text` text NOT NULL,
+  `author_id` int unsigned NULL,
+  `author_ip_address` varchar(45) NOT NULL,
+  `is_author_anonymous` tinyint(1) NOT NULL DEFAULT '0',
+  `receiver_id` int unsigned NOT NULL,
+  `question_id` int unsigned NULL,
+  `score` int NOT NULL,
+  `reasons` text NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `reviewed_by` int unsigned NULL,
+  `reviewed_at` timestamp NULL DEFAULT NULL,
+  `content_id` int unsigned NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `status_created_at` (`status`, `created_at`, `id`),
+  KEY `author_id` (`author_id`),
+  KEY `receiver_id` (`receiver_id`),
+  KEY `question_id` (`question_id`),
+  KEY `reviewed_by` (`reviewed_by`),
+  CONSTRAINT `held_content_ibfk_1` FOREIGN KEY (`author_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `held_content_ibfk_2` FOREIGN KEY (`receiver_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `held_content_ibfk_3` FOREIGN KEY (`question_id`) REFERENCES `question` (`id`),
+  CONSTRAINT `held_content_ibfk_4` FOREIGN KEY (`reviewed_by`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `muted_word` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `word` varchar(50) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `user_word` (`user_id`, `word`),
+  CONSTRAINT `muted_word_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT IGNORE INTO `permission` (`name`) VALUES ('content.review');
+INSERT IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` IN ('admin', 'moderator') AND `permission`.`name` = 'content.review';
@@ -0,0 +1,6 @@
+DELETE `role_permission` FROM `role_permission` JOIN `permission` ON `permission`.`id` = `role_permission`.`permission_id`
+WHERE `permission`.`name` = 'reports.review';
+DELETE FROM `permission` WHERE `name` = 'reports.review';
+
+DROP TABLE IF EXISTS `report`;
+DROP TABLE IF EXISTS `report_resolution`;
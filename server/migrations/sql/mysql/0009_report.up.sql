@@ -0,0 +1,46 @@
+-- Users report the questions, answers and users breaking the rules.
+-- The open reports of a target are handled together: a moderator claims the target, then resolves every open report at once.
+-- A resolution keeps what the moderator did, the reports it closed point to it.
+
+CREATE TABLE IF NOT EXISTS `report_resolution` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `target_type` varchar(16) NOT NULL,
+  `target_id` int unsigned NOT NULL,
+  `moderator_id` int unsigned NOT NULL,
+  `action` varchar(16) NOT NULL,
+  `note` varchar(1000) NOT NULL DEFAULT '',
+  `ban_id` int unsigned NULL,
+  `report_count` int NOT NULL DEFAULT 0,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `target` (`target_type`, `target_id`),
+  KEY `moderator_id` (`moderator_id`),
+  KEY `ban_id` (`ban_id`),
+  CONSTRAINT `report_resolution_ibfk_1` FOREIGN KEY (`moderator_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `report_resolution_ibfk_2` FOREIGN KEY (`ban_id`) REFERENCES `ban` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `report` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `reporter_id` int unsigned NOT NULL,
+  `target_type` varchar(16) NOT NULL,
+  `target_id` int unsigned NOT NULL,
+  `reason` varchar(32) NOT NULL,
+  `details` varchar(1000) NOT NULL DEFAULT '',
+  `claimed_by` int unsigned NULL,
+  `claimed_at` timestamp NULL DEFAULT NULL,
+  `resolution_id` int unsigned NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `open_target` (`resolution_id`, `target_type`, `target_id`),
+  KEY `reporter_id` (`reporter_id`),
+  KEY `claimed_by` (`claimed_by`),
+  CONSTRAINT `report_ibfk_1` FOREIGN KEY (`reporter_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `report_ibfk_2` FOREIGN KEY (`claimed_by`) REFERENCES `user` (`id`),
+  CONSTRAINT `report_ibfk_3` FOREIGN KEY (`resolution_id`) REFERENCES `report_resolution` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT IGNORE INTO `permission` (`name`) VALUES ('reports.review');
+INSERT IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` IN ('admin', 'moderator') AND `permission`.`name` = 'reports.review';
@@ -0,0 +1,24 @@
+DELETE `role_permission` FROM `role_permission` JOIN `permission` ON `permission`.`id` = `role_permission`.`permission_id`
+WHERE `permission`.`name` = 'content.remove';
+DELETE FROM `permission` WHERE `name` = 'content.remove';
+
+-- the targets which are not users can't be kept once target_id references user again
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'moderation_logging' AND COLUMN_NAME = 'target_type') > 0, 'UPDATE `moderation_logging` SET `target_id` = NULL WHERE `target_type` <> ''user''', 'DO 0');
+PREPARE content_removal FROM @statement;
+EXECUTE content_removal;
+DEALLOCATE PREPARE content_removal;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'moderation_logging' AND COLUMN_NAME = 'target_type') > 0, 'ALTER TABLE `moderation_logging` DROP `target_type`, ADD CONSTRAINT `moderation_logging_ibfk_2` FOREIGN KEY (`target_id`) REFERENCES `user` (`id`)', 'DO 0');
+PREPARE content_removal FROM @statement;
+EXECUTE content_removal;
+DEALLOCATE PREPARE content_removal;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'answer' AND COLUMN_NAME = 'removed_at') > 0, 'ALTER TABLE `answer` DROP FOREIGN KEY `answer_ibfk_3`, DROP KEY `removed_by`, DROP `removed_at`, DROP `removed_by`, DROP `removal_reason`', 'DO 0');
+PREPARE content_removal FROM @statement;
+EXECUTE content_removal;
+DEALLOCATE PREPARE content_removal;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'question' AND COLUMN_NAME = 'removed_at') > 0, 'ALTER TABLE `question` DROP FOREIGN KEY `question_ibfk_3`, DROP KEY `removed_by`, DROP `removed_at`, DROP `removed_by`, DROP `removal_reason`', 'DO 0');
+PREPARE content_removal FROM @statement;
+EXECUTE content_removal;
+DEALLOCATE PREPARE content_removal;
@@ -0,0 +1,26 @@
+-- Moderators remove the questions and answers breaking the rules. A removal is not a deletion:
+-- the content keeps its place, shown as removed by moderation, and can be restored.
+-- The moderation log names the type of its target, which is no longer always a user.
+-- Each table is only altered when it doesn't have its new column yet.
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'question' AND COLUMN_NAME = 'removed_at') = 0, 'ALTER TABLE `question` ADD `removed_at` timestamp NULL DEFAULT NULL, ADD `removed_by` int unsigned NULL, ADD `removal_reason` varchar(1000) NULL, ADD KEY `removed_by` (`removed_by`), ADD CONSTRAINT `question_ibfk_3` FOREIGN KEY (`removed_by`) REFERENCES `user` (`id`)', 'DO 0');
+PREPARE content_removal FROM @statement;
+EXECUTE content_removal;
+DEALLOCATE PREPARE content_removal;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'answer' AND COLUMN_NAME = 'removed_at') = 0, 'ALTER TABLE `answer` ADD `removed_at` timestamp NULL DEFAULT NULL, ADD `removed_by` int unsigned NULL, ADD `removal_reason` varchar(1000) NULL, ADD KEY `removed_by` (`removed_by`), ADD CONSTRAINT `answer_ibfk_3` FOREIGN KEY (`removed_by`) REFERENCES `user` (`id`)', 'DO 0');
+PREPARE content_removal FROM @statement;
+EXECUTE content_removal;
+DEALLOCATE PREPARE content_removal;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'moderation_logging' AND COLUMN_NAME = 'target_type') = 0, 'ALTER TABLE `moderation_logging` DROP FOREIGN KEY `moderation_logging_ibfk_2`, ADD `target_type` varchar(16) NULL AFTER `action`', 'DO 0');
+PREPARE content_removal FROM @statement;
+EXECUTE content_removal;
+DEALLOCATE PREPARE content_removal;
+
+UPDATE `moderation_logging` SET `target_type` = 'user' WHERE `target_id` IS NOT NULL AND `target_type` IS NULL;
+
+INSERT IGNORE INTO `permission` (`name`) VALUES ('content.remove');
+INSERT IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` IN ('admin', 'moderator') AND `permission`.`name` = 'content.remove';
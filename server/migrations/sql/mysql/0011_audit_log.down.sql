@@ -0,0 +1,14 @@
+DELETE `role_permission` FROM `role_permission` JOIN `permission` ON `permission`.`id` = `role_permission`.`permission_id`
+WHERE `permission`.`name` = 'audit.view';
+DELETE FROM `permission` WHERE `name` = 'audit.view';
+
+-- the entries written before the audit log get their free-text action back
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'moderation_logging' AND COLUMN_NAME = 'payload') > 0, 'UPDATE `moderation_logging` SET `action` = JSON_UNQUOTE(JSON_EXTRACT(`payload`, ''$.legacy_action'')) WHERE JSON_EXTRACT(`payload`, ''$.legacy_action'') IS NOT NULL', 'DO 0');
+PREPARE audit_log FROM @statement;
+EXECUTE audit_log;
+DEALLOCATE PREPARE audit_log;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'moderation_logging' AND COLUMN_NAME = 'payload') > 0, 'ALTER TABLE `moderation_logging` DROP KEY `created_at`, DROP KEY `action`, DROP `user_agent`, DROP `ip_address`, DROP `payload`', 'DO 0');
+PREPARE audit_log FROM @statement;
+EXECUTE audit_log;
+DEALLOCATE PREPARE audit_log;
@@ -0,0 +1,29 @@
+-- The moderation log becomes an audit log: typed actions, the target before and after the action in a JSON payload,
+-- and the IP address and user agent of the request. The free-text actions of the older entries are kept in their payload.
+-- The columns are only added when missing, and only the entries without a payload are typed.
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'moderation_logging' AND COLUMN_NAME = 'payload') = 0, 'ALTER TABLE `moderation_logging` ADD `payload` text NULL AFTER `target_id`, ADD `ip_address` varchar(45) NOT NULL DEFAULT '''' AFTER `payload`, ADD `user_agent` varchar(255) NOT NULL DEFAULT '''' AFTER `ip_address`, ADD KEY `action` (`action`), ADD KEY `created_at` (`created_at`)', 'DO 0');
+PREPARE audit_log FROM @statement;
+EXECUTE audit_log;
+DEALLOCATE PREPARE audit_log;
+
+UPDATE `moderation_logging` SET `payload` = JSON_OBJECT('legacy_action', `action`), `action` = CASE
+  WHEN `action` LIKE 'grantRole:%' THEN 'role.grant'
+  WHEN `action` LIKE 'revokeRole:%' THEN 'role.revoke'
+  WHEN `action` = 'banUser' THEN 'user.ban'
+  WHEN `action` = 'pardonUser' THEN 'user.pardon'
+  WHEN `action` = 'moderationGetUserQuestions' THEN 'questions.view'
+  WHEN `action` LIKE 'reviewHeldContent:%' THEN 'held_content.review'
+  WHEN `action` LIKE 'resolveReports:%' THEN 'reports.resolve'
+  WHEN `action` = 'removeQuestion' THEN 'question.remove'
+  WHEN `action` = 'restoreQuestion' THEN 'question.restore'
+  WHEN `action` = 'removeAnswer' THEN 'answer.remove'
+  WHEN `action` = 'restoreAnswer' THEN 'answer.restore'
+  WHEN `action` LIKE 'filter:%' THEN 'filter.decision'
+  ELSE `action` END
+WHERE `payload` IS NULL;
+
+INSERT IGNORE INTO `permission` (`name`) VALUES ('audit.view');
+INSERT IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` = 'admin' AND `permission`.`name` = 'audit.view';
@@ -0,0 +1,11 @@
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'answer' AND INDEX_NAME = 'answerer_ip_address') > 0, 'ALTER TABLE `answer` DROP KEY `answerer_ip_address`', 'DO 0');
+PREPARE address_ban FROM @statement;
+EXECUTE address_ban;
+DEALLOCATE PREPARE address_ban;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'question' AND INDEX_NAME = 'author_ip_address') > 0, 'ALTER TABLE `question` DROP KEY `author_ip_address`', 'DO 0');
+PREPARE address_ban FROM @statement;
+EXECUTE address_ban;
+DEALLOCATE PREPARE address_ban;
+
+DROP TABLE IF EXISTS `address_ban`;
@@ -0,0 +1,26 @@
+-- Moderators ban IP addresses, CIDR ranges and email domains so that banned users can't come back with a new account.
+-- The IP addresses of the questions and answers are indexed to find the accounts sharing them, unless they already are.
+
+CREATE TABLE IF NOT EXISTS `address_ban` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `ban_type` varchar(16) NOT NULL,
+  `value` varchar(255) NOT NULL,
+  `author_id` int unsigned NOT NULL,
+  `reason` varchar(1000) NOT NULL DEFAULT '',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `ban_type` (`ban_type`),
+  KEY `author_id` (`author_id`),
+  CONSTRAINT `address_ban_ibfk_1` FOREIGN KEY (`author_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'question' AND INDEX_NAME = 'author_ip_address') = 0, 'ALTER TABLE `question` ADD KEY `author_ip_address` (`author_ip_address`)', 'DO 0');
+PREPARE address_ban FROM @statement;
+EXECUTE address_ban;
+DEALLOCATE PREPARE address_ban;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'answer' AND INDEX_NAME = 'answerer_ip_address') = 0, 'ALTER TABLE `answer` ADD KEY `answerer_ip_address` (`answerer_ip_address`)', 'DO 0');
+PREPARE address_ban FROM @statement;
+EXECUTE address_ban;
+DEALLOCATE PREPARE address_ban;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `email_verification_token`;
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'user' AND COLUMN_NAME = 'email_verified') > 0, 'ALTER TABLE `user` DROP COLUMN `email_verified`', 'DO 0');
+PREPARE email_verification FROM @statement;
+EXECUTE email_verification;
+DEALLOCATE PREPARE email_verification;
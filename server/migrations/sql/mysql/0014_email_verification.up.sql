@@ -0,0 +1,23 @@
+-- Email addresses are verified with a link sent to them, only the hash of the token is stored.
+-- A token carries the address it verifies, which is how a change of address waits for its confirmation.
+-- The accounts created before the verification are trusted, the column is only added when missing.
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'user' AND COLUMN_NAME = 'email_verified') = 0, 'ALTER TABLE `user` ADD `email_verified` tinyint(1) NOT NULL DEFAULT 0', 'DO 0');
+PREPARE email_verification FROM @statement;
+EXECUTE email_verification;
+DEALLOCATE PREPARE email_verification;
+UPDATE `user` SET `email_verified` = 1;
+
+CREATE TABLE IF NOT EXISTS `email_verification_token` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `email` varchar(319) NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `used_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash` (`token_hash`),
+  KEY `user_id` (`user_id`, `created_at`),
+  CONSTRAINT `email_verification_token_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS `login_challenge`;
+DROP TABLE IF EXISTS `recovery_code`;
+DROP TABLE IF EXISTS `user_totp`;
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'role' AND COLUMN_NAME = 'requires_two_factor') > 0, 'ALTER TABLE `role` DROP COLUMN `requires_two_factor`', 'DO 0');
+PREPARE two_factor FROM @statement;
+EXECUTE two_factor;
+DEALLOCATE PREPARE two_factor;
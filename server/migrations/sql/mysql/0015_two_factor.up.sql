@@ -0,0 +1,45 @@
+-- Users can protect their account with a TOTP second factor. The secret is pending until a first code confirms it,
+-- last_used_step keeps a code from being used twice. Recovery codes are stored hashed and used once.
+-- A login with a second factor goes through a short-lived challenge, which counts the wrong codes.
+-- Roles can require their holders to use a second factor, their permissions are not granted otherwise.
+-- The column of the roles is only added when missing.
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'role' AND COLUMN_NAME = 'requires_two_factor') = 0, 'ALTER TABLE `role` ADD `requires_two_factor` tinyint(1) NOT NULL DEFAULT 0', 'DO 0');
+PREPARE two_factor FROM @statement;
+EXECUTE two_factor;
+DEALLOCATE PREPARE two_factor;
+
+CREATE TABLE IF NOT EXISTS `user_totp` (
+  `user_id` int unsigned NOT NULL,
+  `secret` varchar(64) NOT NULL,
+  `last_used_step` bigint NOT NULL DEFAULT 0,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `enabled_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`user_id`),
+  CONSTRAINT `user_totp_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `recovery_code` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `used_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `user_code` (`user_id`, `code_hash`),
+  CONSTRAINT `recovery_code_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `login_challenge` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` int unsigned NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `attempts` int unsigned NOT NULL DEFAULT 0,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `used_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash` (`token_hash`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `login_challenge_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
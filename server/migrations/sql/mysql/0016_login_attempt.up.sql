@@ -0,0 +1,17 @@
+-- Every password login is recorded, whether the username exists or not, so that the failures can be throttled
+-- per username and per IP address. user_id is only set when the username matched an account.
+
+CREATE TABLE IF NOT EXISTS `login_attempt` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `username` varchar(255) NOT NULL,
+  `user_id` int unsigned DEFAULT NULL,
+  `ip_address` varchar(45) NOT NULL,
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `success` tinyint(1) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `username` (`username`, `created_at`),
+  KEY `ip_address` (`ip_address`, `created_at`),
+  KEY `user_id` (`user_id`, `created_at`),
+  CONSTRAINT `login_attempt_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
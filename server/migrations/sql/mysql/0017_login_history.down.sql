@@ -0,0 +1,9 @@
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'login_attempt' AND INDEX_NAME = 'user_device') > 0, 'ALTER TABLE `login_attempt` DROP KEY `user_device`', 'DO 0');
+PREPARE login_history FROM @statement;
+EXECUTE login_history;
+DEALLOCATE PREPARE login_history;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'login_attempt' AND COLUMN_NAME = 'method') > 0, 'ALTER TABLE `login_attempt` DROP COLUMN `method`', 'DO 0');
+PREPARE login_history FROM @statement;
+EXECUTE login_history;
+DEALLOCATE PREPARE login_history;
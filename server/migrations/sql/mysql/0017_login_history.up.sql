@@ -0,0 +1,13 @@
+-- The login attempts double as the login history of the users. method tells how the user logged in,
+-- the user_device key finds whether the user already logged in from the IP address and user agent of a new login.
+-- The column and the key are only added when missing.
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'login_attempt' AND COLUMN_NAME = 'method') = 0, 'ALTER TABLE `login_attempt` ADD `method` varchar(16) NOT NULL DEFAULT ''password''', 'DO 0');
+PREPARE login_history FROM @statement;
+EXECUTE login_history;
+DEALLOCATE PREPARE login_history;
+
+SET @statement = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'login_attempt' AND INDEX_NAME = 'user_device') = 0, 'ALTER TABLE `login_attempt` ADD KEY `user_device` (`user_id`, `ip_address`, `user_agent`)', 'DO 0');
+PREPARE login_history FROM @statement;
+EXECUTE login_history;
+DEALLOCATE PREPARE login_history;
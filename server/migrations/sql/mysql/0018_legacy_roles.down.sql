@@ -0,0 +1,2 @@
+-- The flags are not brought back, the roles copied from them are kept.
+DO 0;
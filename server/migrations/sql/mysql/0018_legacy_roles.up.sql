@@ -0,0 +1,20 @@
+-- Databases created before the roles still have the is_admin and is_moderator flags of the users.
+-- The flagged users are given the matching role, then the flags are dropped. Databases created by 0001_init
+-- never had the flags, the statements are only prepared when the columns exist.
+
+SET @has_flags = (SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'user' AND COLUMN_NAME IN ('is_admin', 'is_moderator'));
+
+SET @statement = IF(@has_flags = 2, 'INSERT IGNORE INTO `user_role` (`user_id`, `role_id`) SELECT `user`.`id`, `role`.`id` FROM `user` JOIN `role` ON `role`.`name` = ''admin'' WHERE `user`.`is_admin` = 1', 'DO 0');
+PREPARE legacy_roles FROM @statement;
+EXECUTE legacy_roles;
+DEALLOCATE PREPARE legacy_roles;
+
+SET @statement = IF(@has_flags = 2, 'INSERT IGNORE INTO `user_role` (`user_id`, `role_id`) SELECT `user`.`id`, `role`.`id` FROM `user` JOIN `role` ON `role`.`name` = ''moderator'' WHERE `user`.`is_moderator` = 1', 'DO 0');
+PREPARE legacy_roles FROM @statement;
+EXECUTE legacy_roles;
+DEALLOCATE PREPARE legacy_roles;
+
+SET @statement = IF(@has_flags = 2, 'ALTER TABLE `user` DROP COLUMN `is_admin`, DROP COLUMN `is_moderator`', 'DO 0');
+PREPARE legacy_roles FROM @statement;
+EXECUTE legacy_roles;
+DEALLOCATE PREPARE legacy_roles;
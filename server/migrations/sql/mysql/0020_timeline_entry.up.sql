@@ -0,0 +1,20 @@
+-- The timeline is fanned out: an answer is added to the timeline of each follower when it is written,
+-- and the answers of a user are added to the timeline of a new follower. A page of the timeline reads
+-- the latest entries of the user from the index, however many users they follow.
+-- The entries of the existing follows are filled in.
+
+CREATE TABLE IF NOT EXISTS `timeline_entry` (
+  `user_id` int unsigned NOT NULL,
+  `answer_id` int unsigned NOT NULL,
+  `answerer_id` int unsigned NOT NULL,
+  `created_at` timestamp NOT NULL,
+  PRIMARY KEY (`user_id`, `answer_id`),
+  KEY `user_created_at` (`user_id`, `created_at`, `answer_id`),
+  KEY `user_answerer` (`user_id`, `answerer_id`),
+  CONSTRAINT `timeline_entry_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
+  CONSTRAINT `timeline_entry_ibfk_2` FOREIGN KEY (`answer_id`) REFERENCES `answer` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT IGNORE INTO `timeline_entry` (`user_id`, `answer_id`, `answerer_id`, `created_at`)
+SELECT `follow`.`follower`, `answer`.`id`, `answer`.`user_id`, `answer`.`created_at` FROM `follow`
+JOIN `answer` ON `answer`.`user_id` = `follow`.`followed` WHERE `answer`.`has_been_deleted` = 0;
@@ -0,0 +1,19 @@
+-- foreign_keys can't be changed within the transaction of the migration, the checks wait for its commit instead
+PRAGMA defer_foreign_keys = ON;
+
+DROP TABLE IF EXISTS `user_role`;
+DROP TABLE IF EXISTS `role_permission`;
+DROP TABLE IF EXISTS `permission`;
+DROP TABLE IF EXISTS `role`;
+DROP TABLE IF EXISTS `session`;
+DROP TABLE IF EXISTS `oauth_login`;
+DROP TABLE IF EXISTS `oauth_provider`;
+DROP TABLE IF EXISTS `rate_limit`;
+DROP TABLE IF EXISTS `moderation_logging`;
+DROP TABLE IF EXISTS `pardon`;
+DROP TABLE IF EXISTS `ban`;
+DROP TABLE IF EXISTS `follow`;
+DROP TABLE IF EXISTS `answer_like`;
+DROP TABLE IF EXISTS `answer`;
+DROP TABLE IF EXISTS `question`;
+DROP TABLE IF EXISTS `user`;
@@ -0,0 +1,154 @@
+-- Initial schema, SQLite version of mysql/0001_init.up.sql.
+-- Names compared case insensitively by the MySQL collation use COLLATE NOCASE.
+
+CREATE TABLE IF NOT EXISTS `user` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `username` varchar(30) NOT NULL COLLATE NOCASE,
+  `email` varchar(319) NOT NULL COLLATE NOCASE,
+  `display_name` varchar(30) NOT NULL,
+  `password` char(60) NOT NULL,
+  `birthdate` date NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS `question` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `receiver_id` integer NOT NULL REFERENCES `user` (`id`),
+  `author_id` integer NULL REFERENCES `user` (`id`),
+  `author_ip_address` varchar(45) NOT NULL,
+  `is_author_anonymous` tinyint(1) NOT NULL DEFAULT 1,
+  `text` varchar(500) NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `has_been_deleted` tinyint(1) NOT NULL DEFAULT 0,
+  `deleted_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `question_author_id` ON `question` (`author_id`);
+CREATE INDEX IF NOT EXISTS `question_receiver_id` ON `question` (`receiver_id`);
+
+CREATE TABLE IF NOT EXISTS `answer` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `question_id` integer NOT NULL REFERENCES `question` (`id`),
+  `text` varchar(1000) NOT NULL,
+  `answerer_ip_address` varchar(45) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `has_been_deleted` tinyint(1) NOT NULL DEFAULT 0,
+  `deleted_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `answer_question_id` ON `answer` (`question_id`);
+CREATE INDEX IF NOT EXISTS `answer_user_id` ON `answer` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `answer_like` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `answer_id` integer NOT NULL REFERENCES `answer` (`id`),
+  `user_id` integer NOT NULL REFERENCES `user` (`id`)
+);
+CREATE INDEX IF NOT EXISTS `answer_like_answer_id` ON `answer_like` (`answer_id`);
+CREATE INDEX IF NOT EXISTS `answer_like_user_id` ON `answer_like` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `follow` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `follower` integer NOT NULL REFERENCES `user` (`id`),
+  `followed` integer NOT NULL REFERENCES `user` (`id`)
+);
+CREATE INDEX IF NOT EXISTS `follow_follower` ON `follow` (`follower`);
+CREATE INDEX IF NOT EXISTS `follow_followed` ON `follow` (`followed`);
+
+CREATE TABLE IF NOT EXISTS `ban` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `author_id` integer NOT NULL REFERENCES `user` (`id`),
+  `reason` varchar(1000) NULL DEFAULT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `ban_user_id` ON `ban` (`user_id`);
+CREATE INDEX IF NOT EXISTS `ban_author_id` ON `ban` (`author_id`);
+
+CREATE TABLE IF NOT EXISTS `pardon` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `ban_id` integer NOT NULL REFERENCES `ban` (`id`),
+  `pardoner_id` integer NOT NULL REFERENCES `user` (`id`),
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `pardon_ban_id` ON `pardon` (`ban_id`);
+CREATE INDEX IF NOT EXISTS `pardon_pardoner_id` ON `pardon` (`pardoner_id`);
+
+CREATE TABLE IF NOT EXISTS `moderation_logging` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `action` varchar(100) NOT NULL,
+  `target_id` integer NULL REFERENCES `user` (`id`),
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `moderation_logging_user_id` ON `moderation_logging` (`user_id`);
+CREATE INDEX IF NOT EXISTS `moderation_logging_target_id` ON `moderation_logging` (`target_id`);
+
+CREATE TABLE IF NOT EXISTS `rate_limit` (
+  `ip_address` varchar(45) NOT NULL PRIMARY KEY,
+  `request_count` integer NOT NULL DEFAULT 0,
+  `last_updated` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS `oauth_provider` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(255) NOT NULL COLLATE NOCASE
+);
+INSERT INTO `oauth_provider` (`name`) SELECT 'Google' WHERE NOT EXISTS (SELECT 1 FROM `oauth_provider` WHERE `name` = 'Google');
+
+CREATE TABLE IF NOT EXISTS `oauth_login` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `oauth_provider_id` integer NOT NULL REFERENCES `oauth_provider` (`id`),
+  `subject_id` varchar(255) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `oauth_login_user_id` ON `oauth_login` (`user_id`);
+CREATE INDEX IF NOT EXISTS `oauth_login_oauth_provider_id` ON `oauth_login` (`oauth_provider_id`);
+
+CREATE TABLE IF NOT EXISTS `session` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `session_id` varchar(64) NOT NULL,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `token_hash` char(64) NOT NULL UNIQUE,
+  `ip_address` varchar(45) NOT NULL,
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `rotated_at` timestamp NULL DEFAULT NULL,
+  `revoked_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `session_session_id` ON `session` (`session_id`);
+CREATE INDEX IF NOT EXISTS `session_user_id` ON `session` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `role` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(50) NOT NULL UNIQUE COLLATE NOCASE
+);
+INSERT OR IGNORE INTO `role` (`name`) VALUES ('admin'), ('moderator');
+
+CREATE TABLE IF NOT EXISTS `permission` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(50) NOT NULL UNIQUE COLLATE NOCASE
+);
+INSERT OR IGNORE INTO `permission` (`name`) VALUES ('users.ban'), ('users.pardon'), ('questions.view_any'), ('roles.manage');
+
+CREATE TABLE IF NOT EXISTS `role_permission` (
+  `role_id` integer NOT NULL REFERENCES `role` (`id`),
+  `permission_id` integer NOT NULL REFERENCES `permission` (`id`),
+  PRIMARY KEY (`role_id`, `permission_id`)
+);
+CREATE INDEX IF NOT EXISTS `role_permission_permission_id` ON `role_permission` (`permission_id`);
+INSERT OR IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` = 'admin' OR (`role`.`name` = 'moderator' AND `permission`.`name` IN ('users.ban', 'users.pardon', 'questions.view_any'));
+
+CREATE TABLE IF NOT EXISTS `user_role` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `role_id` integer NOT NULL REFERENCES `role` (`id`),
+  `granted_by` integer NULL REFERENCES `user` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (`user_id`, `role_id`)
+);
+CREATE INDEX IF NOT EXISTS `user_role_role_id` ON `user_role` (`role_id`);
+CREATE INDEX IF NOT EXISTS `user_role_granted_by` ON `user_role` (`granted_by`);
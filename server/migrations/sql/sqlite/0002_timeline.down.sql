@@ -0,0 +1 @@
+DROP INDEX IF EXISTS `answer_user_created_at`;
@@ -0,0 +1,3 @@
+-- SQLite version of mysql/0002_timeline.up.sql.
+
+CREATE INDEX IF NOT EXISTS `answer_user_created_at` ON `answer` (`user_id`, `created_at`, `id`);
@@ -0,0 +1,11 @@
+CREATE TABLE `follow_old` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `follower` integer NOT NULL REFERENCES `user` (`id`),
+  `followed` integer NOT NULL REFERENCES `user` (`id`)
+);
+INSERT INTO `follow_old` (`id`, `follower`, `followed`) SELECT `id`, `follower`, `followed` FROM `follow`;
+DROP TABLE `follow`;
+ALTER TABLE `follow_old` RENAME TO `follow`;
+
+CREATE INDEX IF NOT EXISTS `follow_follower` ON `follow` (`follower`);
+CREATE INDEX IF NOT EXISTS `follow_followed` ON `follow` (`followed`);
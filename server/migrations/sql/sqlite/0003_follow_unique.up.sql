@@ -0,0 +1,18 @@
+-- SQLite version of mysql/0003_follow_unique.up.sql.
+-- SQLite can't add a column defaulting to CURRENT_TIMESTAMP, the table is rebuilt instead.
+
+CREATE TABLE `follow_new` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `follower` integer NOT NULL REFERENCES `user` (`id`),
+  `followed` integer NOT NULL REFERENCES `user` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (`follower`, `followed`)
+);
+INSERT INTO `follow_new` (`id`, `follower`, `followed`) SELECT MIN(`id`), `follower`, `followed` FROM `follow` GROUP BY `follower`, `followed`;
+DROP TABLE `follow`;
+ALTER TABLE `follow_new` RENAME TO `follow`;
+
+CREATE INDEX IF NOT EXISTS `follow_follower` ON `follow` (`follower`);
+CREATE INDEX IF NOT EXISTS `follow_followed` ON `follow` (`followed`);
+CREATE INDEX IF NOT EXISTS `follow_followed_created_at` ON `follow` (`followed`, `created_at`, `id`);
+CREATE INDEX IF NOT EXISTS `follow_follower_created_at` ON `follow` (`follower`, `created_at`, `id`);
@@ -0,0 +1,15 @@
+-- SQLite version of mysql/0004_notification.up.sql.
+
+CREATE TABLE IF NOT EXISTS `notification` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `type` varchar(32) NOT NULL,
+  `actor_id` integer NULL DEFAULT NULL REFERENCES `user` (`id`),
+  `question_id` integer NULL DEFAULT NULL REFERENCES `question` (`id`),
+  `answer_id` integer NULL DEFAULT NULL REFERENCES `answer` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `read_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `notification_user_created_at` ON `notification` (`user_id`, `created_at`, `id`);
+CREATE INDEX IF NOT EXISTS `notification_user_read_at` ON `notification` (`user_id`, `read_at`);
+CREATE INDEX IF NOT EXISTS `notification_actor_id` ON `notification` (`actor_id`);
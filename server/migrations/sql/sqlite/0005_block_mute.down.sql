@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `user_mute`;
+DROP TABLE IF EXISTS `user_block`;
@@ -0,0 +1,21 @@
+-- SQLite version of mysql/0005_block_mute.up.sql.
+
+CREATE TABLE IF NOT EXISTS `user_block` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `blocker_id` integer NOT NULL REFERENCES `user` (`id`),
+  `blocked_id` integer NOT NULL REFERENCES `user` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (`blocker_id`, `blocked_id`)
+);
+CREATE INDEX IF NOT EXISTS `user_block_blocker_created_at` ON `user_block` (`blocker_id`, `created_at`, `id`);
+CREATE INDEX IF NOT EXISTS `user_block_blocked_id` ON `user_block` (`blocked_id`);
+
+CREATE TABLE IF NOT EXISTS `user_mute` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `muter_id` integer NOT NULL REFERENCES `user` (`id`),
+  `muted_id` integer NOT NULL REFERENCES `user` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (`muter_id`, `muted_id`)
+);
+CREATE INDEX IF NOT EXISTS `user_mute_muter_created_at` ON `user_mute` (`muter_id`, `created_at`, `id`);
+CREATE INDEX IF NOT EXISTS `user_mute_muted_id` ON `user_mute` (`muted_id`);
@@ -0,0 +1,12 @@
+-- SQLite version of mysql/0006_asker_block.up.sql.
+
+CREATE TABLE IF NOT EXISTS `asker_block` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `receiver_id` integer NOT NULL REFERENCES `user` (`id`),
+  `ip_hash` char(64) NOT NULL,
+  `question_id` integer NOT NULL REFERENCES `question` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `asker_block_receiver_ip_hash` ON `asker_block` (`receiver_id`, `ip_hash`);
+CREATE INDEX IF NOT EXISTS `asker_block_receiver_created_at` ON `asker_block` (`receiver_id`, `created_at`, `id`);
@@ -0,0 +1,10 @@
+-- SQLite version of mysql/0007_user_settings.up.sql.
+
+CREATE TABLE IF NOT EXISTS `user_settings` (
+  `user_id` integer PRIMARY KEY REFERENCES `user` (`id`),
+  `allow_anonymous_questions` tinyint(1) NOT NULL DEFAULT 1,
+  `guest_questions` varchar(16) NOT NULL DEFAULT 'allowed',
+  `following_only` tinyint(1) NOT NULL DEFAULT 0,
+  `inbox_paused` tinyint(1) NOT NULL DEFAULT 0,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
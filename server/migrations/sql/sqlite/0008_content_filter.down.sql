@@ -0,0 +1,19 @@
+DELETE FROM `role_permission` WHERE `permission_id` IN (SELECT `id` FROM `permission` WHERE `name` = 'content.review');
+DELETE FROM `permission` WHERE `name` = 'content.review';
+
+DROP TABLE IF EXISTS `muted_word`;
+DROP TABLE IF EXISTS `held_content`;
+
+-- the decisions of the filters have no moderator to keep them
+CREATE TABLE `moderation_logging_old` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `action` varchar(100) NOT NULL,
+  `target_id` integer NULL REFERENCES `user` (`id`),
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO `moderation_logging_old` SELECT `id`, `user_id`, `action`, `target_id`, `created_at` FROM `moderation_logging` WHERE `user_id` IS NOT NULL;
+DROP TABLE `moderation_logging`;
+ALTER TABLE `moderation_logging_old` RENAME TO `moderation_logging`;
+CREATE INDEX IF NOT EXISTS `moderation_logging_user_id` ON `moderation_logging` (`user_id`);
+CREATE INDEX IF NOT EXISTS `moderation_logging_target_id` ON `moderation_logging` (`target_id`);
@@ -0,0 +1,47 @@
+-- SQLite version of mysql/0008_content_filter.up.sql.
+-- SQLite can't change the nullability of a column, moderation_logging is rebuilt instead.
+
+CREATE TABLE `moderation_logging_new` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NULL REFERENCES `user` (`id`),
+  `action` varchar(100) NOT NULL,
+  `target_id` integer NULL REFERENCES `user` (`id`),
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO `moderation_logging_new` SELECT `id`, `user_id`, `action`, `target_id`, `created_at` FROM `moderation_logging`;
+DROP TABLE `moderation_logging`;
+ALTER TABLE `moderation_logging_new` RENAME TO `moderation_logging`;
+CREATE INDEX IF NOT EXISTS `moderation_logging_user_id` ON `moderation_logging` (`user_id`);
+CREATE INDEX IF NOT EXISTS `moderation_logging_target_id` ON `moderation_logging` (`target_id`);
+
+CREATE TABLE IF NOT EXISTS `held_content` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `content_type` varchar(16) NOT NULL,
+  `text` text NOT NULL,
+  `author_id` integer NULL REFERENCES `user` (`id`),
+  `author_ip_address` varchar(45) NOT NULL,
+  `is_author_anonymous` tinyint(1) NOT NULL DEFAULT 0,
+  `receiver_id` integer NOT NULL REFERENCES `user` (`id`),
+  `question_id` integer NULL REFERENCES `question` (`id`),
+  `score` integer NOT NULL,
+  `reasons` text NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `reviewed_by` integer NULL REFERENCES `user` (`id`),
+  `reviewed_at` timestamp NULL DEFAULT NULL,
+  `content_id` integer NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `held_content_status_created_at` ON `held_content` (`status`, `created_at`, `id`);
+
+CREATE TABLE IF NOT EXISTS `muted_word` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `word` varchar(50) NOT NULL COLLATE NOCASE,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (`user_id`, `word`)
+);
+
+INSERT OR IGNORE INTO `permission` (`name`) VALUES ('content.review');
+INSERT OR IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` IN ('admin', 'moderator') AND `permission`.`name` = 'content.review';
@@ -0,0 +1,5 @@
+DELETE FROM `role_permission` WHERE `permission_id` IN (SELECT `id` FROM `permission` WHERE `name` = 'reports.review');
+DELETE FROM `permission` WHERE `name` = 'reports.review';
+
+DROP TABLE IF EXISTS `report`;
+DROP TABLE IF EXISTS `report_resolution`;
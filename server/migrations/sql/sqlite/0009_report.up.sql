@@ -0,0 +1,34 @@
+-- SQLite version of mysql/0009_report.up.sql.
+
+CREATE TABLE IF NOT EXISTS `report_resolution` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `target_type` varchar(16) NOT NULL,
+  `target_id` integer NOT NULL,
+  `moderator_id` integer NOT NULL REFERENCES `user` (`id`),
+  `action` varchar(16) NOT NULL,
+  `note` varchar(1000) NOT NULL DEFAULT '',
+  `ban_id` integer NULL REFERENCES `ban` (`id`),
+  `report_count` integer NOT NULL DEFAULT 0,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `report_resolution_target` ON `report_resolution` (`target_type`, `target_id`);
+
+CREATE TABLE IF NOT EXISTS `report` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `reporter_id` integer NOT NULL REFERENCES `user` (`id`),
+  `target_type` varchar(16) NOT NULL,
+  `target_id` integer NOT NULL,
+  `reason` varchar(32) NOT NULL,
+  `details` varchar(1000) NOT NULL DEFAULT '',
+  `claimed_by` integer NULL REFERENCES `user` (`id`),
+  `claimed_at` timestamp NULL DEFAULT NULL,
+  `resolution_id` integer NULL REFERENCES `report_resolution` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `report_open_target` ON `report` (`resolution_id`, `target_type`, `target_id`);
+CREATE INDEX IF NOT EXISTS `report_reporter_id` ON `report` (`reporter_id`);
+
+INSERT OR IGNORE INTO `permission` (`name`) VALUES ('reports.review');
+INSERT OR IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` IN ('admin', 'moderator') AND `permission`.`name` = 'reports.review';
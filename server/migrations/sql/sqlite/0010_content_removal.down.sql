@@ -0,0 +1,24 @@
+DELETE FROM `role_permission` WHERE `permission_id` IN (SELECT `id` FROM `permission` WHERE `name` = 'content.remove');
+DELETE FROM `permission` WHERE `name` = 'content.remove';
+
+-- the targets which are not users can't be kept once target_id references user again
+CREATE TABLE `moderation_logging_old` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NULL REFERENCES `user` (`id`),
+  `action` varchar(100) NOT NULL,
+  `target_id` integer NULL REFERENCES `user` (`id`),
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO `moderation_logging_old` SELECT `id`, `user_id`, `action`, CASE WHEN `target_type` = 'user' THEN `target_id` ELSE NULL END, `created_at` FROM `moderation_logging`;
+DROP TABLE `moderation_logging`;
+ALTER TABLE `moderation_logging_old` RENAME TO `moderation_logging`;
+CREATE INDEX IF NOT EXISTS `moderation_logging_user_id` ON `moderation_logging` (`user_id`);
+CREATE INDEX IF NOT EXISTS `moderation_logging_target_id` ON `moderation_logging` (`target_id`);
+
+ALTER TABLE `answer` DROP COLUMN `removal_reason`;
+ALTER TABLE `answer` DROP COLUMN `removed_by`;
+ALTER TABLE `answer` DROP COLUMN `removed_at`;
+
+ALTER TABLE `question` DROP COLUMN `removal_reason`;
+ALTER TABLE `question` DROP COLUMN `removed_by`;
+ALTER TABLE `question` DROP COLUMN `removed_at`;
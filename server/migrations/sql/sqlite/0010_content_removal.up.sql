@@ -0,0 +1,30 @@
+-- SQLite version of mysql/0010_content_removal.up.sql.
+-- SQLite can't drop a column used by a foreign key, removed_by isn't declared as one so that the migration can be reverted.
+-- SQLite can't drop a foreign key either, moderation_logging is rebuilt instead.
+
+ALTER TABLE `question` ADD COLUMN `removed_at` timestamp NULL DEFAULT NULL;
+ALTER TABLE `question` ADD COLUMN `removed_by` integer NULL;
+ALTER TABLE `question` ADD COLUMN `removal_reason` varchar(1000) NULL;
+
+ALTER TABLE `answer` ADD COLUMN `removed_at` timestamp NULL DEFAULT NULL;
+ALTER TABLE `answer` ADD COLUMN `removed_by` integer NULL;
+ALTER TABLE `answer` ADD COLUMN `removal_reason` varchar(1000) NULL;
+
+CREATE TABLE `moderation_logging_new` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NULL REFERENCES `user` (`id`),
+  `action` varchar(100) NOT NULL,
+  `target_type` varchar(16) NULL,
+  `target_id` integer NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO `moderation_logging_new` SELECT `id`, `user_id`, `action`, CASE WHEN `target_id` IS NULL THEN NULL ELSE 'user' END, `target_id`, `created_at` FROM `moderation_logging`;
+DROP TABLE `moderation_logging`;
+ALTER TABLE `moderation_logging_new` RENAME TO `moderation_logging`;
+CREATE INDEX IF NOT EXISTS `moderation_logging_user_id` ON `moderation_logging` (`user_id`);
+CREATE INDEX IF NOT EXISTS `moderation_logging_target_id` ON `moderation_logging` (`target_id`);
+
+INSERT OR IGNORE INTO `permission` (`name`) VALUES ('content.remove');
+INSERT OR IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` IN ('admin', 'moderator') AND `permission`.`name` = 'content.remove';
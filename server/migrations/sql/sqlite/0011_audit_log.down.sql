@@ -0,0 +1,12 @@
+DELETE FROM `role_permission` WHERE `permission_id` IN (SELECT `id` FROM `permission` WHERE `name` = 'audit.view');
+DELETE FROM `permission` WHERE `name` = 'audit.view';
+
+-- the entries written before the audit log get their free-text action back
+UPDATE `moderation_logging` SET `action` = json_extract(`payload`, '$.legacy_action')
+WHERE json_extract(`payload`, '$.legacy_action') IS NOT NULL;
+
+DROP INDEX IF EXISTS `moderation_logging_created_at`;
+DROP INDEX IF EXISTS `moderation_logging_action`;
+ALTER TABLE `moderation_logging` DROP COLUMN `user_agent`;
+ALTER TABLE `moderation_logging` DROP COLUMN `ip_address`;
+ALTER TABLE `moderation_logging` DROP COLUMN `payload`;
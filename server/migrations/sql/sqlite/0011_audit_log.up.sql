@@ -0,0 +1,27 @@
+-- SQLite version of mysql/0011_audit_log.up.sql.
+
+ALTER TABLE `moderation_logging` ADD COLUMN `payload` text NULL;
+ALTER TABLE `moderation_logging` ADD COLUMN `ip_address` varchar(45) NOT NULL DEFAULT '';
+ALTER TABLE `moderation_logging` ADD COLUMN `user_agent` varchar(255) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS `moderation_logging_action` ON `moderation_logging` (`action`);
+CREATE INDEX IF NOT EXISTS `moderation_logging_created_at` ON `moderation_logging` (`created_at`);
+
+UPDATE `moderation_logging` SET `payload` = json_object('legacy_action', `action`), `action` = CASE
+  WHEN `action` LIKE 'grantRole:%' THEN 'role.grant'
+  WHEN `action` LIKE 'revokeRole:%' THEN 'role.revoke'
+  WHEN `action` = 'banUser' THEN 'user.ban'
+  WHEN `action` = 'pardonUser' THEN 'user.pardon'
+  WHEN `action` = 'moderationGetUserQuestions' THEN 'questions.view'
+  WHEN `action` LIKE 'reviewHeldContent:%' THEN 'held_content.review'
+  WHEN `action` LIKE 'resolveReports:%' THEN 'reports.resolve'
+  WHEN `action` = 'removeQuestion' THEN 'question.remove'
+  WHEN `action` = 'restoreQuestion' THEN 'question.restore'
+  WHEN `action` = 'removeAnswer' THEN 'answer.remove'
+  WHEN `action` = 'restoreAnswer' THEN 'answer.restore'
+  WHEN `action` LIKE 'filter:%' THEN 'filter.decision'
+  ELSE `action` END;
+
+INSERT OR IGNORE INTO `permission` (`name`) VALUES ('audit.view');
+INSERT OR IGNORE INTO `role_permission` (`role_id`, `permission_id`)
+SELECT `role`.`id`, `permission`.`id` FROM `role` JOIN `permission`
+WHERE `role`.`name` = 'admin' AND `permission`.`name` = 'audit.view';
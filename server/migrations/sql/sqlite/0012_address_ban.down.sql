@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS `answer_answerer_ip_address`;
+DROP INDEX IF EXISTS `question_author_ip_address`;
+DROP TABLE IF EXISTS `address_ban`;
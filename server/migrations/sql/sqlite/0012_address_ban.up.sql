@@ -0,0 +1,15 @@
+-- SQLite version of mysql/0012_address_ban.up.sql.
+
+CREATE TABLE IF NOT EXISTS `address_ban` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `ban_type` varchar(16) NOT NULL,
+  `value` varchar(255) NOT NULL,
+  `author_id` integer NOT NULL REFERENCES `user` (`id`),
+  `reason` varchar(1000) NOT NULL DEFAULT '',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `address_ban_ban_type` ON `address_ban` (`ban_type`);
+
+CREATE INDEX IF NOT EXISTS `question_author_ip_address` ON `question` (`author_ip_address`);
+CREATE INDEX IF NOT EXISTS `answer_answerer_ip_address` ON `answer` (`answerer_ip_address`);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `password_reset_request`;
+DROP TABLE IF EXISTS `password_reset_token`;
@@ -0,0 +1,20 @@
+-- SQLite version of mysql/0013_password_reset.up.sql.
+
+CREATE TABLE IF NOT EXISTS `password_reset_token` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `token_hash` char(64) NOT NULL UNIQUE,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `used_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `password_reset_token_user_id` ON `password_reset_token` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `password_reset_request` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `ip_address` varchar(45) NOT NULL,
+  `user_id` integer DEFAULT NULL REFERENCES `user` (`id`),
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `password_reset_request_ip_address` ON `password_reset_request` (`ip_address`, `created_at`);
+CREATE INDEX IF NOT EXISTS `password_reset_request_user_id` ON `password_reset_request` (`user_id`, `created_at`);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `email_verification_token`;
+ALTER TABLE `user` DROP COLUMN `email_verified`;
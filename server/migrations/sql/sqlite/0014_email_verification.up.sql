@@ -0,0 +1,15 @@
+-- SQLite version of mysql/0014_email_verification.up.sql.
+
+ALTER TABLE `user` ADD COLUMN `email_verified` boolean NOT NULL DEFAULT 0;
+UPDATE `user` SET `email_verified` = 1;
+
+CREATE TABLE IF NOT EXISTS `email_verification_token` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `email` varchar(319) NOT NULL COLLATE NOCASE,
+  `token_hash` char(64) NOT NULL UNIQUE,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `used_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `email_verification_token_user_id` ON `email_verification_token` (`user_id`, `created_at`);
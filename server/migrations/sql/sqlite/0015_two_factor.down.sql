@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `login_challenge`;
+DROP TABLE IF EXISTS `recovery_code`;
+DROP TABLE IF EXISTS `user_totp`;
+ALTER TABLE `role` DROP COLUMN `requires_two_factor`;
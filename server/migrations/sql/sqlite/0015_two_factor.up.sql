@@ -0,0 +1,31 @@
+-- SQLite version of mysql/0015_two_factor.up.sql.
+
+ALTER TABLE `role` ADD COLUMN `requires_two_factor` boolean NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS `user_totp` (
+  `user_id` integer PRIMARY KEY REFERENCES `user` (`id`),
+  `secret` varchar(64) NOT NULL,
+  `last_used_step` integer NOT NULL DEFAULT 0,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `enabled_at` timestamp NULL DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `recovery_code` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `code_hash` char(64) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `used_at` timestamp NULL DEFAULT NULL,
+  UNIQUE (`user_id`, `code_hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `login_challenge` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `token_hash` char(64) NOT NULL UNIQUE,
+  `attempts` integer NOT NULL DEFAULT 0,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `used_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `login_challenge_user_id` ON `login_challenge` (`user_id`);
@@ -0,0 +1,14 @@
+-- SQLite version of mysql/0016_login_attempt.up.sql.
+
+CREATE TABLE IF NOT EXISTS `login_attempt` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `username` varchar(255) NOT NULL,
+  `user_id` integer DEFAULT NULL REFERENCES `user` (`id`),
+  `ip_address` varchar(45) NOT NULL,
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `success` tinyint(1) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `login_attempt_username` ON `login_attempt` (`username`, `created_at`);
+CREATE INDEX IF NOT EXISTS `login_attempt_ip_address` ON `login_attempt` (`ip_address`, `created_at`);
+CREATE INDEX IF NOT EXISTS `login_attempt_user_id` ON `login_attempt` (`user_id`, `created_at`);
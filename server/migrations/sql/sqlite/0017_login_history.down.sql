@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS `login_attempt_user_device`;
+ALTER TABLE `login_attempt` DROP COLUMN `method`;
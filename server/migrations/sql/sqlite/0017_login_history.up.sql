@@ -0,0 +1,4 @@
+-- SQLite version of mysql/0017_login_history.up.sql.
+
+ALTER TABLE `login_attempt` ADD COLUMN `method` varchar(16) NOT NULL DEFAULT 'password';
+CREATE INDEX IF NOT EXISTS `login_attempt_user_device` ON `login_attempt` (`user_id`, `ip_address`, `user_agent`);
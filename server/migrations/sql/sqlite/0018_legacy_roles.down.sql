@@ -0,0 +1,2 @@
+-- Nothing to revert, see sqlite/0018_legacy_roles.up.sql.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- SQLite databases never had the is_admin and is_moderator flags, see mysql/0018_legacy_roles.up.sql.
+SELECT 1;
@@ -0,0 +1,12 @@
+-- SQLite version of mysql/0019_stream_ticket.up.sql.
+
+CREATE TABLE IF NOT EXISTS `stream_ticket` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `token_hash` char(64) NOT NULL UNIQUE,
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` timestamp NOT NULL,
+  `stream_expires_at` timestamp NOT NULL,
+  `used_at` timestamp NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS `stream_ticket_user_id` ON `stream_ticket` (`user_id`);
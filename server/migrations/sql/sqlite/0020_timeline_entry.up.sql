@@ -0,0 +1,15 @@
+-- SQLite version of mysql/0020_timeline_entry.up.sql.
+
+CREATE TABLE IF NOT EXISTS `timeline_entry` (
+  `user_id` integer NOT NULL REFERENCES `user` (`id`),
+  `answer_id` integer NOT NULL REFERENCES `answer` (`id`),
+  `answerer_id` integer NOT NULL,
+  `created_at` timestamp NOT NULL,
+  PRIMARY KEY (`user_id`, `answer_id`)
+);
+CREATE INDEX IF NOT EXISTS `timeline_entry_user_created_at` ON `timeline_entry` (`user_id`, `created_at`, `answer_id`);
+CREATE INDEX IF NOT EXISTS `timeline_entry_user_answerer` ON `timeline_entry` (`user_id`, `answerer_id`);
+
+INSERT OR IGNORE INTO `timeline_entry` (`user_id`, `answer_id`, `answerer_id`, `created_at`)
+SELECT `follow`.`follower`, `answer`.`id`, `answer`.`user_id`, `answer`.`created_at` FROM `follow`
+JOIN `answer` ON `answer`.`user_id` = `follow`.`followed` WHERE `answer`.`has_been_deleted` = 0;
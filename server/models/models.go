@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -16,6 +17,168 @@ type LoginInfos struct {
 	Password string `json:"password"`
 }
 
+type RefreshTokenInfos struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RevokeSessionInfos struct {
+	SessionId string `json:"session_id"`
+}
+
+// AuthTokens are the tokens of a new session. When the account has a second factor, only the challenge token is set,
+// the session is created once the challenge is passed
+type AuthTokens struct {
+	AccessToken    string `json:"token"`
+	RefreshToken   string `json:"refresh_token"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+type RefreshToken struct {
+	Id        int
+	SessionId string
+	UserId    int
+	ExpiresAt time.Time
+	IsRotated bool
+	IsRevoked bool
+}
+
+type VerifyEmailInfos struct {
+	Token string `json:"token"`
+}
+
+type LoginTwoFactorInfos struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+// TwoFactorCodeInfos holds a code of the authenticator app, or a recovery code where accepted
+type TwoFactorCodeInfos struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"otpauth_uri"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// TotpSecret is pending until a first code confirms the enrollment
+type TotpSecret struct {
+	UserId       int
+	Secret       string
+	LastUsedStep int64
+	IsEnabled    bool
+}
+
+type LoginChallenge struct {
+	Id        int
+	UserId    int
+	Attempts  int
+	ExpiresAt time.Time
+	IsUsed    bool
+}
+
+// StreamTicket opens one event stream in place of the access token, which an EventSource can't send in a header.
+// The stream ends when the access token the ticket was created with expires
+type StreamTicket struct {
+	Id              int
+	UserId          int
+	ExpiresAt       time.Time
+	StreamExpiresAt time.Time
+	IsUsed          bool
+}
+
+type StreamTicketInfos struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// how a user logged in
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodGoogle    = "google"
+	LoginMethodTwoFactor = "two_factor"
+)
+
+// LoginAttempt is a login of a user, UserId is 0 when the username matched no account
+type LoginAttempt struct {
+	Username  string
+	UserId    int
+	IpAddress string
+	UserAgent string
+	Method    string
+	Success   bool
+}
+
+// LoginHistoryEntry is a login attempt as shown in the login history of the user
+type LoginHistoryEntry struct {
+	Id        int       `json:"id"`
+	IpAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Method    string    `json:"method"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginHistoryPage struct {
+	Logins     []LoginHistoryEntry `json:"logins"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// LoginFailures are the failed logins of a username or an IP address within the throttling window
+type LoginFailures struct {
+	Count         int
+	LastFailureAt time.Time
+}
+
+type EmailStatus struct {
+	Email        string  `json:"email_address"`
+	Verified     bool    `json:"email_verified"`
+	PendingEmail *string `json:"pending_email"`
+}
+
+type ForgotPasswordInfos struct {
+	Email string `json:"email_address"`
+}
+
+type ResetPasswordInfos struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type PasswordResetToken struct {
+	Id        int
+	UserId    int
+	ExpiresAt time.Time
+	IsUsed    bool
+}
+
+// EmailVerificationToken is sent to the address it verifies, which becomes the email of the user once verified
+type EmailVerificationToken struct {
+	Id        int
+	UserId    int
+	Email     string
+	ExpiresAt time.Time
+	IsUsed    bool
+}
+
+type Session struct {
+	Id         string    `json:"id"`
+	IpAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
 type UserPreview struct {
 	Id          int64  `json:"id"`
 	Username    string `json:"username"`
@@ -29,6 +192,8 @@ type Question struct {
 	Author            UserPreview `json:"author"`
 	ReceiverId        int         `json:"receiver_id"`
 	CreatedAt         time.Time   `json:"created_at"`
+	// the text of a question removed by a moderator is hidden from its receiver
+	RemovedByModeration bool `json:"removed_by_moderation"`
 }
 
 type Answer struct {
@@ -41,6 +206,48 @@ type Answer struct {
 	CreatedAt         time.Time   `json:"date_answered"`
 	LikeCount         int         `json:"like_count"`
 	LikedByRequester  bool        `json:"liked_by_requester"`
+	// an answer removed by a moderator, or answering a removed question, is shown empty
+	RemovedByModeration bool `json:"removed_by_moderation"`
+}
+
+// TimelineAnswer is an answer of a followed user, the answerer is the user who answered
+type TimelineAnswer struct {
+	Answer
+	Answerer UserPreview `json:"answerer"`
+}
+
+type TimelinePage struct {
+	Answers    []TimelineAnswer `json:"answers"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// FollowPreview is a user of a followers or following list, the flags are relative to the requesting user
+type FollowPreview struct {
+	UserPreview
+	FollowedAt          time.Time `json:"followed_at"`
+	FollowedByRequester bool      `json:"followed_by_requester"`
+	FollowsRequester    bool      `json:"follows_requester"`
+	IsMutual            bool      `json:"is_mutual"`
+	// FollowId identifies the follow in the list cursors
+	FollowId int `json:"-"`
+}
+
+type FollowPage struct {
+	Users      []FollowPreview `json:"users"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// RelatedUser is a user of the blocked or muted list of the requesting user
+type RelatedUser struct {
+	UserPreview
+	Since time.Time `json:"since"`
+	// RelationId identifies the block or the mute in the list cursors
+	RelationId int `json:"-"`
+}
+
+type RelatedUserPage struct {
+	Users      []RelatedUser `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
 }
 
 type UserProfileInfos struct {
@@ -52,7 +259,22 @@ type UserProfileInfos struct {
 	AnswerCount           int      `json:"answer_count"`
 	IsFollowedByRequester bool     `json:"followed_by_requester"`
 	IsRequestingSelf      bool     `json:"is_requesting_self"`
+	IsBlockedByRequester  bool     `json:"blocked_by_requester"`
+	IsMutedByRequester    bool     `json:"muted_by_requester"`
+	HasBlockedRequester   bool     `json:"has_blocked_requester"`
 	Answers               []Answer `json:"answers"`
+	NextCursor            string   `json:"next_cursor,omitempty"`
+}
+
+// Cursor is the position of an item in a list ordered by creation date then id, newest first
+type Cursor struct {
+	CreatedAt time.Time
+	Id        int
+}
+
+type QuestionPage struct {
+	Questions  []Question `json:"questions"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
 
 type FollowUserInfos struct {
@@ -60,10 +282,22 @@ type FollowUserInfos struct {
 	Follow bool `json:"follow"`
 }
 
+type BlockUserInfos struct {
+	UserId int  `json:"user_id"`
+	Block  bool `json:"block"`
+}
+
+type MuteUserInfos struct {
+	UserId int  `json:"user_id"`
+	Mute   bool `json:"mute"`
+}
+
 type AskQuestionInfos struct {
 	UserId            int    `json:"user_id"`
 	QuestionText      string `json:"text"`
 	IsAuthorAnonymous bool   `json:"is_author_anonymous"`
+	// token of the captcha solved by a logged out visitor, when the receiver asks for one
+	CaptchaToken string `json:"captcha_token"`
 }
 
 type AnswerQuestionInfos struct {
@@ -84,6 +318,24 @@ type DeleteQuestionInfos struct {
 	QuestionId int `json:"question_id"`
 }
 
+type RemoveQuestionInfos struct {
+	QuestionId int    `json:"question_id"`
+	Reason     string `json:"reason"`
+}
+
+type RemoveAnswerInfos struct {
+	AnswerId int    `json:"answer_id"`
+	Reason   string `json:"reason"`
+}
+
+type RestoreQuestionInfos struct {
+	QuestionId int `json:"question_id"`
+}
+
+type RestoreAnswerInfos struct {
+	AnswerId int `json:"answer_id"`
+}
+
 type UpdateUserInfos struct {
 	DisplayName string `json:"display_name"`
 	Email       string `json:"email_address"`
@@ -91,7 +343,24 @@ type UpdateUserInfos struct {
 
 type PromoteUserInfos struct {
 	UserId      int    `json:"user_id"`
-	PromoteType string `json:"promote_type"`
+	PromoteType string `json:"promote_type"` // name of the role to grant
+}
+
+type DemoteUserInfos struct {
+	UserId int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type Role struct {
+	Id                int      `json:"id"`
+	Name              string   `json:"name"`
+	Permissions       []string `json:"permissions"`
+	RequiresTwoFactor bool     `json:"requires_two_factor"`
+}
+
+type RoleTwoFactorInfos struct {
+	Role     string `json:"role"`
+	Required bool   `json:"required"`
 }
 
 type BanUserInfos struct {
@@ -100,10 +369,365 @@ type BanUserInfos struct {
 	Reason   string `json:"reason"`
 }
 
+type Ban struct {
+	Id          int        `json:"id"`
+	Reason      string     `json:"reason"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	IsPermanent bool       `json:"is_permanent"`
+}
+
+type BlockAskerInfos struct {
+	QuestionId int `json:"question_id"`
+	// duration of the block in hours, 0 for a permanent block
+	Duration int `json:"duration"`
+}
+
+type UnblockAskerInfos struct {
+	BlockId int `json:"block_id"`
+}
+
+// AskerBlock is a block of the asker of an anonymous question, it only tells which question the asker was blocked from
+type AskerBlock struct {
+	Id              int        `json:"id"`
+	QuestionId      int        `json:"question_id"`
+	QuestionSnippet string     `json:"question_snippet"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	IsPermanent     bool       `json:"is_permanent"`
+}
+
+type AskerBlockPage struct {
+	Blocks     []AskerBlock `json:"blocks"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// who can ask questions to a user without being logged in
+const (
+	GuestQuestionsAllowed  = "allowed"
+	GuestQuestionsCaptcha  = "captcha"
+	GuestQuestionsDisabled = "disabled"
+)
+
+// UserSettings tells which questions a user accepts in their inbox
+type UserSettings struct {
+	AllowAnonymousQuestions bool   `json:"allow_anonymous_questions"`
+	GuestQuestions          string `json:"guest_questions"`
+	FollowingOnly           bool   `json:"following_only"`
+	InboxPaused             bool   `json:"inbox_paused"`
+}
+
+// DefaultUserSettings are the settings of the users who never changed them
+var DefaultUserSettings = UserSettings{AllowAnonymousQuestions: true, GuestQuestions: GuestQuestionsAllowed}
+
+// UpdateUserSettingsInfos only changes the settings that are given
+type UpdateUserSettingsInfos struct {
+	AllowAnonymousQuestions *bool   `json:"allow_anonymous_questions"`
+	GuestQuestions          *string `json:"guest_questions"`
+	FollowingOnly           *bool   `json:"following_only"`
+	InboxPaused             *bool   `json:"inbox_paused"`
+}
+
+// statuses of the contents held by the filters
+const (
+	HeldPending  = "pending"
+	HeldApproved = "approved"
+	HeldRejected = "rejected"
+)
+
+// HeldContent is a question or an answer held by the filters until a moderator reviews it
+type HeldContent struct {
+	Id                int    `json:"id"`
+	ContentType       string `json:"content_type"`
+	Text              string `json:"text"`
+	AuthorId          int    `json:"author_id"`
+	AuthorIpAddress   string `json:"-"`
+	IsAuthorAnonymous bool   `json:"is_author_anonymous"`
+	// owner of the inbox of the question
+	ReceiverId int `json:"receiver_id"`
+	// question of the answer, 0 for questions
+	QuestionId int       `json:"question_id,omitempty"`
+	Score      int       `json:"score"`
+	Reasons    []string  `json:"reasons"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type HeldContentPage struct {
+	Contents   []HeldContent `json:"contents"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type ReviewHeldContentInfos struct {
+	HeldId  int  `json:"held_id"`
+	Approve bool `json:"approve"`
+}
+
+type MuteWordInfos struct {
+	Word string `json:"word"`
+	Mute bool   `json:"mute"`
+}
+
+// types of the targets of the reports
+const (
+	ReportTargetQuestion = "question"
+	ReportTargetAnswer   = "answer"
+	ReportTargetUser     = "user"
+)
+
+// reasons given by the reporters
+const (
+	ReportReasonSpam       = "spam"
+	ReportReasonHarassment = "harassment"
+	ReportReasonHate       = "hate"
+	ReportReasonSexual     = "sexual"
+	ReportReasonSelfHarm   = "self_harm"
+	ReportReasonOther      = "other"
+)
+
+// actions of the moderators resolving the reports of a target
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionDelete  = "delete"
+	ReportActionBan     = "ban"
+)
+
+type ReportInfos struct {
+	TargetType string `json:"target_type"`
+	TargetId   int    `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+type Report struct {
+	Id         int       `json:"id"`
+	ReporterId int       `json:"reporter_id"`
+	TargetType string    `json:"target_type"`
+	TargetId   int       `json:"target_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReportGroup gathers the open reports of a target, it is what the moderators work on
+type ReportGroup struct {
+	TargetType  string   `json:"target_type"`
+	TargetId    int      `json:"target_id"`
+	ReportCount int      `json:"report_count"`
+	Reasons     []string `json:"reasons"`
+	// moderator handling the reports, 0 when nobody claimed them
+	ClaimedBy       int       `json:"claimed_by,omitempty"`
+	FirstReportedAt time.Time `json:"first_reported_at"`
+	LastReportedAt  time.Time `json:"last_reported_at"`
+	// last report of the target, the groups are paginated on it
+	LastReportId int `json:"-"`
+}
+
+type ReportGroupPage struct {
+	Groups     []ReportGroup `json:"groups"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type ClaimReportsInfos struct {
+	TargetType string `json:"target_type"`
+	TargetId   int    `json:"target_id"`
+	Claim      bool   `json:"claim"`
+}
+
+type ResolveReportsInfos struct {
+	TargetType string `json:"target_type"`
+	TargetId   int    `json:"target_id"`
+	Action     string `json:"action"`
+	Note       string `json:"note"`
+	// hours of the ban, permanent when 0
+	Duration int `json:"duration"`
+}
+
+// ReportResolution is what a moderator did about the open reports of a target
+type ReportResolution struct {
+	Id          int    `json:"id"`
+	TargetType  string `json:"target_type"`
+	TargetId    int    `json:"target_id"`
+	ModeratorId int    `json:"moderator_id"`
+	Action      string `json:"action"`
+	Note        string `json:"note"`
+	// ban given to the owner of the target, 0 for the other actions
+	BanId       int       `json:"ban_id,omitempty"`
+	ReportCount int       `json:"report_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ReportResolutionPage struct {
+	Resolutions []ReportResolution `json:"resolutions"`
+	NextCursor  string             `json:"next_cursor,omitempty"`
+}
+
 type PardonUserInfos struct {
 	BanId int `json:"ban_id"`
 }
 
+type EditBanInfos struct {
+	BanId int `json:"ban_id"`
+	// new duration of the ban in hours, counted from its creation, 0 for a permanent ban
+	Duration int `json:"duration"`
+}
+
+// statuses of the bans in the ban listings
+const (
+	BanStatusActive   = "active"
+	BanStatusExpired  = "expired"
+	BanStatusPardoned = "pardoned"
+)
+
+// Pardon lifts a ban
+type Pardon struct {
+	Id         int       `json:"id"`
+	PardonerId int       `json:"pardoner_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BanDetails is a ban as listed to the moderators, with its pardon if it was lifted
+type BanDetails struct {
+	Id          int        `json:"id"`
+	UserId      int        `json:"user_id"`
+	AuthorId    int        `json:"author_id"`
+	Reason      string     `json:"reason"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	IsPermanent bool       `json:"is_permanent"`
+	Status      string     `json:"status"`
+	Pardon      *Pardon    `json:"pardon"`
+}
+
+// BanFilter selects the bans of the listings, the zero values match every ban
+type BanFilter struct {
+	UserId   int
+	AuthorId int
+	Status   string
+}
+
+type BanPage struct {
+	Bans       []BanDetails `json:"bans"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// types of the address bans, IP addresses are banned as CIDR ranges
+const (
+	AddressBanIp          = "ip"
+	AddressBanEmailDomain = "email_domain"
+)
+
+// AddressBan stops the requests from an IP range, or the new accounts with an email of a domain or of its subdomains
+type AddressBan struct {
+	Id          int        `json:"id"`
+	BanType     string     `json:"ban_type"`
+	Value       string     `json:"value"`
+	AuthorId    int        `json:"author_id"`
+	Reason      string     `json:"reason"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	IsPermanent bool       `json:"is_permanent"`
+}
+
+type AddAddressBanInfos struct {
+	BanType string `json:"ban_type"`
+	// an IP address or a CIDR range for the ip bans, a domain for the email_domain bans
+	Value string `json:"value"`
+	// in hours, 0 for a permanent ban
+	Duration int    `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+type RemoveAddressBanInfos struct {
+	BanId int `json:"ban_id"`
+}
+
+type AddressBanPage struct {
+	Bans       []AddressBan `json:"bans"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// LinkedAccount is an account which asked or answered from IP addresses used by another user
+type LinkedAccount struct {
+	UserId      int      `json:"user_id"`
+	Username    string   `json:"username"`
+	IpAddresses []string `json:"ip_addresses"`
+}
+
+// LinkedAccounts are the IP addresses a user asked or answered from, and the other accounts using them
+type LinkedAccounts struct {
+	IpAddresses []string        `json:"ip_addresses"`
+	Accounts    []LinkedAccount `json:"accounts"`
+}
+
+// actions recorded in the audit log
+const (
+	AuditRoleGrant          = "role.grant"
+	AuditRoleRevoke         = "role.revoke"
+	AuditUserBan            = "user.ban"
+	AuditUserPardon         = "user.pardon"
+	AuditBanEdit            = "ban.edit"
+	AuditAddressBanAdd      = "address_ban.add"
+	AuditAddressBanRemove   = "address_ban.remove"
+	AuditLinkedAccountsView = "linked_accounts.view"
+	AuditQuestionsView      = "questions.view"
+	AuditHeldContentReview  = "held_content.review"
+	AuditReportsResolve     = "reports.resolve"
+	AuditQuestionRemove     = "question.remove"
+	AuditQuestionRestore    = "question.restore"
+	AuditAnswerRemove       = "answer.remove"
+	AuditAnswerRestore      = "answer.restore"
+	AuditFilterDecision     = "filter.decision"
+	AuditRoleEdit           = "role.edit"
+	AuditLoginHistoryView   = "login_history.view"
+)
+
+// types of the targets of the audit log
+const (
+	AuditTargetUser       = "user"
+	AuditTargetQuestion   = "question"
+	AuditTargetAnswer     = "answer"
+	AuditTargetBan        = "ban"
+	AuditTargetAddressBan = "address_ban"
+	AuditTargetRole       = "role"
+)
+
+// AuditContext tells who did an audited action, and from where
+type AuditContext struct {
+	ModeratorId int
+	IpAddress   string
+	UserAgent   string
+}
+
+// AuditEntry records an action of a moderator, or of the content filters when ModeratorId is 0.
+// Payload is a JSON object holding the target before and after the action
+type AuditEntry struct {
+	Id          int             `json:"id"`
+	ModeratorId int             `json:"moderator_id"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetId    int             `json:"target_id"`
+	Payload     json.RawMessage `json:"payload"`
+	IpAddress   string          `json:"ip_address"`
+	UserAgent   string          `json:"user_agent"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects the entries of the audit log, zero values match every entry
+type AuditFilter struct {
+	ModeratorId int
+	Action      string
+	TargetType  string
+	TargetId    int
+	From        time.Time
+	To          time.Time
+}
+
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
 type RateLimit struct {
 	IpAddress       string
 	RequestCount    int
@@ -131,3 +755,24 @@ type GoogleInfos struct {
 	ExpiryDate      int64  `json:"exp"`
 	JwtId           string `json:"jti"`
 }
+
+// Notification tells the user about an event, Actor is nil when the event comes from an anonymous user
+type Notification struct {
+	Id         int          `json:"id"`
+	Type       string       `json:"type"`
+	Actor      *UserPreview `json:"actor,omitempty"`
+	QuestionId int          `json:"question_id,omitempty"`
+	AnswerId   int          `json:"answer_id,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	IsRead     bool         `json:"is_read"`
+}
+
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+}
+
+type ReadNotificationInfos struct {
+	NotificationId int `json:"notification_id"`
+}
@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type rule struct {
+	pattern *regexp.Regexp
+	source  string
+	score   int
+}
+
+// Blocklist catches words and regular expressions, case insensitively
+type Blocklist struct {
+	rules []rule
+}
+
+// ParseBlocklist reads one rule per line, empty lines and lines starting with # are skipped.
+// A rule is a word or a phrase matched as a whole, or a regular expression when prefixed with re:.
+// It rejects the content, unless prefixed with hold which holds it for review:
+//
+//	casino
+//	hold free money
+//	re:b[i1]tc[o0]in
+//	hold re:whats?app
+func ParseBlocklist(r io.Reader) (*Blocklist, error) {
+	blocklist := &Blocklist{}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		score := RejectScore
+		if rest, ok := strings.CutPrefix(line, "hold "); ok {
+			score = HoldScore
+			line = strings.TrimSpace(rest)
+		} else if rest, ok := strings.CutPrefix(line, "reject "); ok {
+			line = strings.TrimSpace(rest)
+		}
+
+		var pattern *regexp.Regexp
+		if expression, ok := strings.CutPrefix(line, "re:"); ok {
+			var err error
+			pattern, err = regexp.Compile("(?i)" + expression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression on line %d of the blocklist: %w", lineNumber, err)
+			}
+		} else {
+			pattern = wordPattern(line)
+		}
+		blocklist.rules = append(blocklist.rules, rule{pattern: pattern, source: line, score: score})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return blocklist, nil
+}
+
+// LoadBlocklist reads the blocklist file described by ParseBlocklist
+func LoadBlocklist(path string) (*Blocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseBlocklist(file)
+}
+
+func (b *Blocklist) Check(content Content) ([]Match, error) {
+	matches := []Match{}
+	for _, r := range b.rules {
+		if r.pattern.MatchString(content.Text) {
+			matches = append(matches, Match{Filter: "blocklist", Reason: "matches " + r.source, Score: r.score})
+		}
+	}
+	return matches, nil
+}
+
+// wordPattern matches the word or the phrase when it is not part of a longer word
+func wordPattern(word string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(word) + `($|[^\p{L}\p{N}_])`)
+}
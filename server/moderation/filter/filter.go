@@ -0,0 +1,163 @@
+// Package filter screens the questions and answers before they are published.
+// A Pipeline runs every Filter on the content and adds up the scores of their matches,
+// the total decides if the content is allowed, held for review by a moderator or rejected
+package filter
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+)
+
+// kinds of the content going through the filters
+const (
+	Question = "question"
+	Answer   = "answer"
+)
+
+type Decision int
+
+const (
+	Allow Decision = iota
+	Hold
+	Reject
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	}
+	return "allow"
+}
+
+// default thresholds of the pipelines, a match scored at one of them is enough to hold or reject the content
+const (
+	HoldScore   = 50
+	RejectScore = 100
+)
+
+// Content is a question or an answer about to be published
+type Content struct {
+	Kind string
+	Text string
+	// 0 for logged out visitors
+	AuthorId int
+	// owner of the inbox the question goes to, the author for answers
+	ReceiverId int
+	// words the receiver doesn't want to see in the questions they get
+	MutedWords []string
+}
+
+// Match is a part of the content caught by a filter
+type Match struct {
+	Filter string `json:"filter"`
+	Reason string `json:"reason"`
+	Score  int    `json:"score"`
+}
+
+// Result is the decision of a pipeline, with the matches that led to it
+type Result struct {
+	Decision Decision
+	Score    int
+	Matches  []Match
+}
+
+// Filter looks for what is not welcome in the content, it returns nothing when the content is fine
+type Filter interface {
+	Check(content Content) ([]Match, error)
+}
+
+type Pipeline struct {
+	filters     []Filter
+	HoldScore   int
+	RejectScore int
+}
+
+// NewPipeline returns a pipeline running the filters in order, with the default thresholds
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters, HoldScore: HoldScore, RejectScore: RejectScore}
+}
+
+// Run checks the content with every filter, it stops at the first error
+func (p *Pipeline) Run(content Content) (Result, error) {
+	result := Result{Decision: Allow, Matches: []Match{}}
+	for _, f := range p.filters {
+		matches, err := f.Check(content)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, match := range matches {
+			result.Score += match.Score
+			result.Matches = append(result.Matches, match)
+		}
+	}
+	if result.Score >= p.RejectScore {
+		result.Decision = Reject
+	} else if result.Score >= p.HoldScore {
+		result.Decision = Hold
+	}
+	return result, nil
+}
+
+var pipeline *Pipeline
+var pipelineOnce sync.Once
+
+// Init builds the pipeline of the server from the env variables:
+// FILTER_BLOCKLIST_PATH is the blocklist file, none when empty,
+// FILTER_LINKS tells what to do with the links, allow (the default), hold or reject.
+// The muted words of the receivers are always checked
+func Init() error {
+	p, err := FromEnv()
+	if err != nil {
+		return err
+	}
+	SetPipeline(p)
+	return nil
+}
+
+// FromEnv returns the pipeline configured by the env variables described by Init
+func FromEnv() (*Pipeline, error) {
+	filters := []Filter{}
+	if path := os.Getenv("FILTER_BLOCKLIST_PATH"); path != "" {
+		blocklist, err := LoadBlocklist(path)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, blocklist)
+	}
+	filters = append(filters, MutedWords{})
+
+	switch os.Getenv("FILTER_LINKS") {
+	case "", "allow":
+	case "hold":
+		filters = append(filters, Links{Score: HoldScore})
+	case "reject":
+		filters = append(filters, Links{Score: RejectScore})
+	default:
+		return nil, errors.New("FILTER_LINKS must be allow, hold or reject")
+	}
+	return NewPipeline(filters...), nil
+}
+
+// SetPipeline makes the server use the given pipeline instead of the one built from the env variables
+func SetPipeline(p *Pipeline) {
+	pipelineOnce.Do(func() {})
+	pipeline = p
+}
+
+// GetPipeline returns the pipeline set by Init or SetPipeline, or the one built from the env variables on first use
+func GetPipeline() *Pipeline {
+	pipelineOnce.Do(func() {
+		var err error
+		pipeline, err = FromEnv()
+		if err != nil {
+			log.Printf("Error while building content filters, only the muted words are checked: %s\n", err.Error())
+			pipeline = NewPipeline(MutedWords{})
+		}
+	})
+	return pipeline
+}
@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const blocklistFile = `# spam
+casino
+hold free money
+re:b[i1]tc[o0]in
+hold re:whats?app
+`
+
+func TestParseBlocklist(t *testing.T) {
+	blocklist, err := ParseBlocklist(strings.NewReader(blocklistFile))
+	if err != nil {
+		t.Fatalf("Error while parsing blocklist: %s", err.Error())
+	}
+	tests := map[string]int{
+		"Do you like the CASINO?":           RejectScore,
+		"casinos are fine":                  0,
+		"Get free money now":                HoldScore,
+		"buy B1TCOIN":                       RejectScore,
+		"text me on whatsapp":               HoldScore,
+		"casino and free money and bitcoin": 2*RejectScore + HoldScore,
+		"What is the meaning of life?":      0,
+	}
+	for text, expected := range tests {
+		matches, _ := blocklist.Check(Content{Kind: Question, Text: text})
+		score := 0
+		for _, match := range matches {
+			score += match.Score
+		}
+		if score != expected {
+			t.Errorf("Expected score %d for %q, got %d with %+v", expected, text, score, matches)
+		}
+	}
+
+	_, err = ParseBlocklist(strings.NewReader("re:(unclosed"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected an error on line 1, got %v", err)
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	os.WriteFile(path, []byte(blocklistFile), 0o600)
+	blocklist, err := LoadBlocklist(path)
+	if err != nil || len(blocklist.rules) != 4 {
+		t.Errorf("Expected 4 rules, got %v and %v", blocklist, err)
+	}
+	_, err = LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt"))
+	if err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestLinks(t *testing.T) {
+	links := Links{Score: HoldScore}
+	for _, text := range []string{"see https://example.com", "go to www.example.org", "visit spam.xyz/offer", "mail me at a.b.co"} {
+		matches, _ := links.Check(Content{Text: text})
+		if len(matches) != 1 {
+			t.Errorf("Expected a link in %q, got %+v", text, matches)
+		}
+	}
+	for _, text := range []string{"What is the meaning of life?", "I use node.js, e.g. for scripts"} {
+		matches, _ := links.Check(Content{Text: text})
+		if len(matches) != 0 {
+			t.Errorf("Expected no link in %q, got %+v", text, matches)
+		}
+	}
+}
+
+func TestMutedWords(t *testing.T) {
+	content := Content{Kind: Question, Text: "What about Pineapple pizza?", MutedWords: []string{"pineapple", "apple", "pizza"}}
+	matches, _ := MutedWords{}.Check(content)
+	if len(matches) != 2 {
+		t.Errorf("Expected pineapple and pizza to match as whole words, got %+v", matches)
+	}
+	content.Kind = Answer
+	matches, _ = MutedWords{}.Check(content)
+	if len(matches) != 0 {
+		t.Errorf("Expected answers not to be checked, got %+v", matches)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	blocklist, _ := ParseBlocklist(strings.NewReader(blocklistFile))
+	p := NewPipeline(blocklist, MutedWords{}, Links{Score: HoldScore})
+	tests := map[string]Decision{
+		"What is the meaning of life?":      Allow,
+		"Get free money now":                Hold,
+		"https://example.com":               Hold,
+		"free money at https://example.com": Reject,
+		"casino":                            Reject,
+	}
+	for text, expected := range tests {
+		result, err := p.Run(Content{Kind: Question, Text: text})
+		if err != nil || result.Decision != expected {
+			t.Errorf("Expected %s for %q, got %s with %+v", expected, text, result.Decision, result.Matches)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("FILTER_BLOCKLIST_PATH", "")
+	t.Setenv("FILTER_LINKS", "reject")
+	p, err := FromEnv()
+	if err != nil {
+		t.Fatalf("Error while building pipeline: %s", err.Error())
+	}
+	result, _ := p.Run(Content{Kind: Question, Text: "https://example.com"})
+	if result.Decision != Reject {
+		t.Errorf("Expected links to be rejected, got %s", result.Decision)
+	}
+
+	t.Setenv("FILTER_LINKS", "")
+	p, err = FromEnv()
+	if err != nil {
+		t.Fatalf("Error while building pipeline: %s", err.Error())
+	}
+	result, _ = p.Run(Content{Kind: Question, Text: "see https://example.com or example.me"})
+	if result.Decision != Allow {
+		t.Errorf("Expected links to be allowed by default, got %s", result.Decision)
+	}
+
+	t.Setenv("FILTER_LINKS", "sometimes")
+	if _, err := FromEnv(); err == nil {
+		t.Errorf("Expected an error for an unknown FILTER_LINKS")
+	}
+	t.Setenv("FILTER_LINKS", "")
+	t.Setenv("FILTER_BLOCKLIST_PATH", filepath.Join(t.TempDir(), "missing.txt"))
+	if _, err := FromEnv(); err == nil {
+		t.Errorf("Expected an error for a missing blocklist")
+	}
+}
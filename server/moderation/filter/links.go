@@ -0,0 +1,19 @@
+package filter
+
+import "regexp"
+
+// linkPattern catches the URLs and the bare domains, e.g. example.com/page
+var linkPattern = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+|\b[a-z0-9-]+(\.[a-z0-9-]+)*\.(com|net|org|io|co|me|ly|gg|xyz|info|biz|ru|fr|de|uk|app|link|site|online|tk)\b`)
+
+// Links catches the links in the content, they are the usual way of spamming
+type Links struct {
+	Score int
+}
+
+func (l Links) Check(content Content) ([]Match, error) {
+	link := linkPattern.FindString(content.Text)
+	if link == "" {
+		return nil, nil
+	}
+	return []Match{{Filter: "links", Reason: "contains link " + link, Score: l.Score}}, nil
+}
@@ -0,0 +1,17 @@
+package filter
+
+// MutedWords rejects the questions containing a word muted by their receiver, answers are never checked
+type MutedWords struct{}
+
+func (MutedWords) Check(content Content) ([]Match, error) {
+	if content.Kind != Question {
+		return nil, nil
+	}
+	matches := []Match{}
+	for _, word := range content.MutedWords {
+		if wordPattern(word).MatchString(content.Text) {
+			matches = append(matches, Match{Filter: "muted_words", Reason: "contains muted word " + word, Score: RejectScore})
+		}
+	}
+	return matches, nil
+}
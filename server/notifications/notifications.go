@@ -0,0 +1,118 @@
+// Package notifications records the events users are notified of and pushes them to the connected users.
+// Recording is best effort: an error is logged and never fails the action causing the notification
+package notifications
+
+import (
+	"log"
+	"project_truthful/client/database"
+	"project_truthful/events"
+	"project_truthful/models"
+	"time"
+)
+
+// types of the notifications
+const (
+	QuestionReceived = "question_received"
+	QuestionAnswered = "question_answered"
+	AnswerLiked      = "answer_liked"
+	NewFollower      = "new_follower"
+	NewLogin         = "new_login"
+)
+
+// notify records the notification and pushes it to the user,
+// users are not notified of their own actions nor of the actions of the users they muted
+func notify(userId int, notificationType string, actorId int, questionId int, answerId int) {
+	if userId == 0 || userId == actorId || isMuted(userId, actorId) {
+		return
+	}
+	record(userId, notificationType, actorId, questionId, answerId)
+}
+
+// isMuted tells if the user muted the actor, the user is notified when the mute can't be checked
+func isMuted(userId int, actorId int) bool {
+	if actorId == 0 || actorId == userId {
+		return false
+	}
+	muted, err := database.GetStore().CheckMuteExists(userId, actorId)
+	if err != nil {
+		log.Printf("Error while checking if user %d muted user %d: %s\n", userId, actorId, err.Error())
+		return false
+	}
+	return muted
+}
+
+func record(userId int, notificationType string, actorId int, questionId int, answerId int) {
+	id, err := database.GetStore().AddNotification(userId, notificationType, actorId, questionId, answerId)
+	if err != nil {
+		log.Printf("Error while notifying user %d of %s: %s\n", userId, notificationType, err.Error())
+		return
+	}
+
+	notification := models.Notification{Id: int(id), Type: notificationType, QuestionId: questionId, AnswerId: answerId, CreatedAt: time.Now().UTC()}
+	if actorId != 0 {
+		username, displayName, err := database.GetStore().GetUsernameAndDisplayName(actorId)
+		if err != nil {
+			log.Printf("Error while getting actor %d of notification %d: %s\n", actorId, id, err.Error())
+		} else {
+			notification.Actor = &models.UserPreview{Id: int64(actorId), Username: username, DisplayName: displayName}
+		}
+	}
+	events.Publish(userId, events.Notification, notification)
+}
+
+// NotifyQuestionReceived notifies the receiver of a new question, the author is left out when anonymous.
+// The question itself is pushed too so that the inbox of the receiver updates without polling.
+// Nothing is sent when the receiver muted the author of a signed question
+func NotifyQuestionReceived(receiverId int, authorId int, isAuthorAnonymous bool, questionId int) {
+	if isAuthorAnonymous {
+		authorId = 0
+	}
+	if isMuted(receiverId, authorId) {
+		return
+	}
+	if receiverId != authorId {
+		record(receiverId, QuestionReceived, authorId, questionId, 0)
+	}
+
+	question, err := database.GetStore().GetQuestionById(questionId)
+	if err != nil {
+		log.Printf("Error while getting question %d to push it to user %d: %s\n", questionId, receiverId, err.Error())
+		return
+	}
+	events.Publish(receiverId, events.NewQuestion, question)
+}
+
+// NotifyQuestionAnswered notifies the author of the question that it was answered.
+// Authors of anonymous questions are not notified, the question doesn't tell who they are
+func NotifyQuestionAnswered(questionId int, answererId int, answerId int) {
+	question, err := database.GetStore().GetQuestionById(questionId)
+	if err != nil {
+		log.Printf("Error while getting question %d to notify its author: %s\n", questionId, err.Error())
+		return
+	}
+	if question.IsAuthorAnonymous {
+		return
+	}
+	notify(int(question.Author.Id), QuestionAnswered, answererId, questionId, answerId)
+}
+
+// NotifyAnswerLiked notifies the author of the answer that it was liked
+func NotifyAnswerLiked(answerId int, likerId int) {
+	authorId, err := database.GetStore().GetAnswerAuthorId(answerId)
+	if err != nil {
+		log.Printf("Error while getting author of answer %d to notify them: %s\n", answerId, err.Error())
+		return
+	}
+	notify(authorId, AnswerLiked, likerId, 0, answerId)
+}
+
+// NotifyNewFollower notifies the followed user
+func NotifyNewFollower(followedId int, followerId int) {
+	notify(followedId, NewFollower, followerId, 0, 0)
+}
+
+// NotifyNewLogin notifies the user that their account was logged in to from a device they never used,
+// the login history tells where from
+func NotifyNewLogin(userId int) {
+	record(userId, NewLogin, 0, 0, 0)
+}
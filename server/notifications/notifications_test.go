@@ -0,0 +1,148 @@
+package notifications
+
+import (
+	"project_truthful/client/database"
+	"project_truthful/client/database/memory"
+	"project_truthful/events"
+	"project_truthful/models"
+	"testing"
+)
+
+func newStore(t *testing.T) *memory.Store {
+	store := memory.New()
+	database.SetStore(store)
+	t.Cleanup(func() { database.SetStore(nil) })
+	store.InsertUser("toto", "password", "toto@toto.fr", "1990-01-01")
+	store.InsertUser("titi", "password", "titi@titi.fr", "1990-01-01")
+	return store
+}
+
+func TestNotifyQuestionReceived(t *testing.T) {
+	store := newStore(t)
+	questionId, _ := store.AddQuestion("question", 2, "127.0.0.1", false, 1)
+	NotifyQuestionReceived(1, 2, false, int(questionId))
+	anonymousId, _ := store.AddQuestion("anonymous", 2, "127.0.0.1", true, 1)
+	NotifyQuestionReceived(1, 2, true, int(anonymousId))
+
+	notifications, _ := store.GetNotifications(1, nil, 10)
+	if len(notifications) != 2 {
+		t.Fatalf("Expected 2 notifications, got %+v", notifications)
+	}
+	if notifications[0].Type != QuestionReceived || notifications[0].Actor != nil || notifications[0].QuestionId != int(anonymousId) {
+		t.Errorf("Expected the anonymous question without its author, got %+v", notifications[0])
+	}
+	if notifications[1].Actor == nil || notifications[1].Actor.Username != "titi" {
+		t.Errorf("Expected the signed question with its author, got %+v", notifications[1])
+	}
+}
+
+func TestNotifyQuestionAnswered(t *testing.T) {
+	store := newStore(t)
+	signedId, _ := store.AddQuestion("signed", 2, "127.0.0.1", false, 1)
+	anonymousId, _ := store.AddQuestion("anonymous", 2, "127.0.0.1", true, 1)
+	NotifyQuestionAnswered(int(signedId), 1, 1)
+	NotifyQuestionAnswered(int(anonymousId), 1, 2)
+	NotifyQuestionAnswered(42, 1, 3)
+
+	// the author of the anonymous question is not notified
+	notifications, _ := store.GetNotifications(2, nil, 10)
+	if len(notifications) != 1 || notifications[0].Type != QuestionAnswered || notifications[0].QuestionId != int(signedId) || notifications[0].Actor.Username != "toto" {
+		t.Errorf("Expected only the signed question to be notified, got %+v", notifications)
+	}
+}
+
+func TestNotifyAnswerLikedAndNewFollower(t *testing.T) {
+	store := newStore(t)
+	questionId, _ := store.AddQuestion("question", 0, "127.0.0.1", true, 1)
+	answerId, _ := store.AddAnswer(1, int(questionId), "answer", "127.0.0.1")
+	NotifyAnswerLiked(int(answerId), 2)
+	NotifyAnswerLiked(int(answerId), 1)
+	NotifyNewFollower(1, 2)
+
+	// users are not notified of their own like
+	notifications, _ := store.GetNotifications(1, nil, 10)
+	if len(notifications) != 2 || notifications[0].Type != NewFollower || notifications[1].Type != AnswerLiked || notifications[1].AnswerId != int(answerId) {
+		t.Errorf("Unexpected notifications %+v", notifications)
+	}
+}
+
+func TestNotifyNewLogin(t *testing.T) {
+	store := newStore(t)
+	NotifyNewLogin(1)
+
+	notifications, _ := store.GetNotifications(1, nil, 10)
+	if len(notifications) != 1 || notifications[0].Type != NewLogin || notifications[0].Actor != nil {
+		t.Errorf("Expected a new login notification without actor, got %+v", notifications)
+	}
+}
+
+func TestNotificationsArePushed(t *testing.T) {
+	store := newStore(t)
+	hub, _ := events.NewHub(nil)
+	events.SetHub(hub)
+	t.Cleanup(func() { hub.Close() })
+	subscription, _ := hub.Subscribe(1)
+
+	questionId, _ := store.AddQuestion("anonymous", 2, "127.0.0.1", true, 1)
+	NotifyQuestionReceived(1, 2, true, int(questionId))
+	NotifyNewFollower(1, 2)
+
+	event := <-subscription.Events()
+	notification, ok := event.Data.(models.Notification)
+	if event.Type != events.Notification || !ok || notification.Type != QuestionReceived || notification.Actor != nil {
+		t.Errorf("Expected the notification of the anonymous question, got %+v", event)
+	}
+	event = <-subscription.Events()
+	question, ok := event.Data.(models.Question)
+	if event.Type != events.NewQuestion || !ok || question.Id != int(questionId) || question.Author.Id != 0 {
+		t.Errorf("Expected the anonymous question without its author, got %+v", event)
+	}
+	event = <-subscription.Events()
+	notification, ok = event.Data.(models.Notification)
+	if !ok || notification.Type != NewFollower || notification.Actor == nil || notification.Actor.Username != "titi" {
+		t.Errorf("Expected the new follower notification with the follower, got %+v", event)
+	}
+}
+
+func TestMutedUsersDoNotNotify(t *testing.T) {
+	store := newStore(t)
+	store.AddMute(1, 2)
+	questionId, _ := store.AddQuestion("question", 2, "127.0.0.1", false, 1)
+	NotifyQuestionReceived(1, 2, false, int(questionId))
+	NotifyNewFollower(1, 2)
+	NotifyNewFollower(2, 1)
+
+	notifications, _ := store.GetNotifications(1, nil, 10)
+	if len(notifications) != 0 {
+		t.Errorf("Expected no notification from the muted user, got %+v", notifications)
+	}
+	notifications, _ = store.GetNotifications(2, nil, 10)
+	if len(notifications) != 1 {
+		t.Errorf("Expected the muted user to still be notified, got %+v", notifications)
+	}
+}
+
+// muting must not tell apart the anonymous questions of the muted users
+func TestAnonymousQuestionsOfMutedUsersNotify(t *testing.T) {
+	store := newStore(t)
+	store.AddMute(1, 2)
+	hub, _ := events.NewHub(nil)
+	events.SetHub(hub)
+	t.Cleanup(func() { hub.Close() })
+	subscription, _ := hub.Subscribe(1)
+	questionId, _ := store.AddQuestion("anonymous", 2, "127.0.0.1", true, 1)
+	NotifyQuestionReceived(1, 2, true, int(questionId))
+
+	notifications, _ := store.GetNotifications(1, nil, 10)
+	if len(notifications) != 1 || notifications[0].Actor != nil || notifications[0].QuestionId != int(questionId) {
+		t.Fatalf("Expected the anonymous question to be notified without its author, got %+v", notifications)
+	}
+	event := <-subscription.Events()
+	if event.Type != events.Notification {
+		t.Errorf("Expected the notification to be pushed, got %+v", event)
+	}
+	event = <-subscription.Events()
+	if event.Type != events.NewQuestion {
+		t.Errorf("Expected the question to be pushed, got %+v", event)
+	}
+}
@@ -0,0 +1,104 @@
+package permissions
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"project_truthful/client/database"
+	"project_truthful/client/token"
+
+	"github.com/gin-gonic/gin"
+)
+
+// names of the roles created by the database schema
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// names of the permissions created by the database schema
+const (
+	BanUsers          = "users.ban"
+	PardonUsers       = "users.pardon"
+	ViewUserQuestions = "questions.view_any"
+	ManageRoles       = "roles.manage"
+	ReviewContent     = "content.review"
+	ReviewReports     = "reports.review"
+	RemoveContent     = "content.remove"
+	ViewAuditLog      = "audit.view"
+)
+
+// RequesterIdKey is the gin context key holding the id of the authenticated requester
+const RequesterIdKey = "requester_id"
+
+func HasPermission(userId int, permission string) (bool, error) {
+	return database.GetStore().CheckUserPermission(userId, permission)
+}
+
+// Require returns a middleware aborting the request unless the requester has the given permission.
+// The requester is the one set under RequesterIdKey by a previous middleware, or the owner of the access token
+func Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requesterId, ok := c.Get(RequesterIdKey)
+		if !ok {
+			id, err := authenticate(c)
+			if err != nil {
+				c.Abort()
+				return
+			}
+			requesterId = id
+			c.Set(RequesterIdKey, id)
+		}
+
+		allowed, err := HasPermission(requesterId.(int), permission)
+		if err != nil {
+			log.Printf("Error while checking permission %s of user %d: %s\n", permission, requesterId, err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "error while checking permissions", "error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			log.Printf("User %d is missing permission %s\n", requesterId, permission)
+			message := "missing permission " + permission
+			if isMissingTwoFactor(requesterId.(int)) {
+				message += ", enable two-factor authentication to use the permissions of your role"
+			}
+			c.JSON(http.StatusForbidden, gin.H{"message": "error while checking permissions", "error": message})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// isMissingTwoFactor tells if the user holds a role requiring a second factor without having enabled one,
+// the permissions of such a role are withheld until it is
+func isMissingTwoFactor(userId int) bool {
+	required, err := database.GetStore().CheckTwoFactorRequired(userId)
+	if err != nil || !required {
+		return false
+	}
+	secret, err := database.GetStore().GetTotpSecret(userId)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error while checking second factor of user %d: %s\n", userId, err.Error())
+		return false
+	}
+	return !secret.IsEnabled
+}
+
+func authenticate(c *gin.Context) (int, error) {
+	accessToken, code, err := token.ParseAccessToken(c)
+	if err != nil {
+		log.Printf("Error while parsing token: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while parsing token", "error": err.Error()})
+		return 0, err
+	}
+
+	requesterId, code, err := token.VerifyJWT(accessToken)
+	if err != nil {
+		log.Printf("Error while checking token: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
+		return 0, err
+	}
+	return requesterId, nil
+}
@@ -0,0 +1,98 @@
+package permissions
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"project_truthful/client/database"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRouter() *gin.Engine {
+	router := gin.New()
+	router.GET("/protected", Require(BanUsers), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"requester_id": c.GetInt(RequesterIdKey)})
+	})
+	return router
+}
+
+func TestRequireInvalidToken(t *testing.T) {
+	router := setupRouter()
+	r, _ := http.NewRequest("GET", "/protected", nil)
+	r.Header.Set("Authorization", "invalid_token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	assert.Equal(t, `{"error":"missing fields","message":"error while parsing token"}`, w.Body.String())
+}
+
+func TestRequire(t *testing.T) {
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error while creating sqlmock: %s", err.Error())
+	}
+	defer db.Close()
+	database.DB = db
+	router := setupRouter()
+
+	// database error
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, BanUsers).WillReturnError(errors.New("error"))
+	r, _ := http.NewRequest("GET", "/protected", nil)
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	assert.Equal(t, `{"error":"error","message":"error while checking permissions"}`, w.Body.String())
+
+	// missing permission
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, BanUsers).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	r, _ = http.NewRequest("GET", "/protected", nil)
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+	assert.Equal(t, `{"error":"missing permission users.ban","message":"error while checking permissions"}`, w.Body.String())
+
+	// missing permission of a role requiring a second factor
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, BanUsers).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("FROM user_totp WHERE user_id = ").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	r, _ = http.NewRequest("GET", "/protected", nil)
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+	assert.Equal(t, `{"error":"missing permission users.ban, enable two-factor authentication to use the permissions of your role","message":"error while checking permissions"}`, w.Body.String())
+
+	// allowed
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role").WithArgs(1, BanUsers).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	r, _ = http.NewRequest("GET", "/protected", nil)
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `{"requester_id":1}`, w.Body.String())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"project_truthful/client"
+	"project_truthful/client/basicfuncs"
+	"project_truthful/client/pagination"
+	"project_truthful/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formats of the exports of the audit log, the log is paged as JSON without format
+const (
+	auditFormatCSV   = "csv"
+	auditFormatJSONL = "jsonl"
+)
+
+var auditCSVHeader = []string{"id", "created_at", "moderator_id", "action", "target_type", "target_id", "ip_address", "user_agent", "payload"}
+
+// parseAuditFilter reads the filter of the audit log from the query parameters, the dates are RFC 3339
+func parseAuditFilter(c *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{Action: c.Query("action"), TargetType: c.Query("target_type")}
+	var err error
+	filter.ModeratorId, err = basicfuncs.ConvertQueryParameterToInt(c.Query("moderator_id"), 0)
+	if err != nil {
+		return models.AuditFilter{}, errors.New("invalid moderator_id")
+	}
+	filter.TargetId, err = basicfuncs.ConvertQueryParameterToInt(c.Query("target_id"), 0)
+	if err != nil {
+		return models.AuditFilter{}, errors.New("invalid target_id")
+	}
+	if from := c.Query("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return models.AuditFilter{}, errors.New("invalid from, expected an RFC 3339 date")
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return models.AuditFilter{}, errors.New("invalid to, expected an RFC 3339 date")
+		}
+	}
+	return filter, nil
+}
+
+func getAuditLog(c *gin.Context) {
+	log.Printf("Received request to get audit log from ip %s\n", c.ClientIP())
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		log.Printf("Error while parsing audit filter: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid filter", "error": err.Error()})
+		return
+	}
+
+	format := c.Query("format")
+	if format == auditFormatCSV || format == auditFormatJSONL {
+		exportAuditLog(c, filter, format)
+		return
+	}
+	if format != "" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid format", "error": "format must be json, csv or jsonl"})
+		return
+	}
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+	page, code, err := client.GetAuditLog(filter, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting audit log: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting audit log", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// exportAuditLog sends every entry matching the filter as a CSV or JSON Lines file
+func exportAuditLog(c *gin.Context, filter models.AuditFilter, format string) {
+	entries, code, err := client.ExportAuditLog(filter)
+	if err != nil {
+		log.Printf("Error while exporting audit log: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while exporting audit log", "error": err.Error()})
+		return
+	}
+
+	var buffer bytes.Buffer
+	contentType := "application/x-ndjson"
+	if format == auditFormatCSV {
+		contentType = "text/csv; charset=utf-8"
+		err = writeAuditCSV(&buffer, entries)
+	} else {
+		err = writeAuditJSONL(&buffer, entries)
+	}
+	if err != nil {
+		log.Printf("Error while writing audit log export: %s\n", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "error while exporting audit log", "error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=audit."+format)
+	c.Data(http.StatusOK, contentType, buffer.Bytes())
+}
+
+func writeAuditCSV(buffer *bytes.Buffer, entries []models.AuditEntry) error {
+	writer := csv.NewWriter(buffer)
+	err := writer.Write(auditCSVHeader)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err = writer.Write([]string{strconv.Itoa(entry.Id), entry.CreatedAt.UTC().Format(time.RFC3339), strconv.Itoa(entry.ModeratorId), entry.Action,
+			entry.TargetType, strconv.Itoa(entry.TargetId), entry.IpAddress, entry.UserAgent, string(entry.Payload)})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeAuditJSONL(buffer *bytes.Buffer, entries []models.AuditEntry) error {
+	encoder := json.NewEncoder(buffer)
+	for _, entry := range entries {
+		err := encoder.Encode(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,2236 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"project_truthful/client"
+	"project_truthful/client/database"
+	"project_truthful/client/database/memory"
+	"project_truthful/client/token"
+	"project_truthful/client/totp"
+	"project_truthful/dialect"
+	"project_truthful/events"
+	"project_truthful/mailer"
+	"project_truthful/migrations"
+	"project_truthful/models"
+	"project_truthful/moderation/filter"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// e2eStores are the stores the end-to-end tests run against
+var e2eStores = map[string]func(t *testing.T) database.Store{
+	"memory": func(t *testing.T) database.Store { return memory.New() },
+	"sqlite": newSQLiteStore,
+}
+
+// newSQLiteStore migrates a new SQLite database in a temporary directory
+func newSQLiteStore(t *testing.T) database.Store {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "e2e.db"))
+	db, err := database.Init()
+	if err != nil {
+		t.Fatalf("Error while opening database: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		db.Close()
+		database.Dialect = dialect.MySQL
+	})
+	_, err = migrations.Up(db, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("Error while migrating database: %s", err.Error())
+	}
+	return database.NewSQLStore(db)
+}
+
+// runE2E runs the test once per store
+func runE2E(t *testing.T, test func(s *e2eServer)) {
+	for name, newStore := range e2eStores {
+		t.Run(name, func(t *testing.T) {
+			test(newE2EServer(t, newStore(t)))
+		})
+	}
+}
+
+// e2eServer runs the routes against a store, with real access tokens
+type e2eServer struct {
+	t      *testing.T
+	router *gin.Engine
+	store  database.Store
+}
+
+func newE2EServer(t *testing.T, store database.Store) *e2eServer {
+	os.Setenv("IS_TEST", "false")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error while generating key: %s", err.Error())
+	}
+	token.SetKey(key)
+
+	database.SetStore(store)
+	t.Cleanup(func() { database.SetStore(nil) })
+
+	router := gin.Default()
+	SetupRoutes(router)
+	SetMiddleware(router)
+	return &e2eServer{t: t, router: router, store: store}
+}
+
+// do sends a request and decodes the JSON response into out, when given
+func (s *e2eServer) do(method string, path string, accessToken string, body any, out any) int {
+	return s.doFrom("", method, path, accessToken, body, out)
+}
+
+// doFrom sends a request like do, from the given IP address
+func (s *e2eServer) doFrom(ipAddress string, method string, path string, accessToken string, body any, out any) int {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	if ipAddress != "" {
+		req.RemoteAddr = ipAddress + ":1234"
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	// the emails are sent after the response, the tests check them right away
+	client.WaitBackgroundJobs()
+	if out != nil {
+		err := json.Unmarshal(w.Body.Bytes(), out)
+		if err != nil {
+			s.t.Fatalf("Error while decoding response of %s %s: %s, body %s", method, path, err.Error(), w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+// createUser inserts a user with a cheaply hashed password and logs them in
+func (s *e2eServer) createUser(username string, password string) (int, string) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	id, err := s.store.InsertUser(username, string(hash), username+"@truthful.test", "1990-01-01")
+	if err != nil {
+		s.t.Fatalf("Error while creating user %s: %s", username, err.Error())
+	}
+	// the users of the tests are verified, testEmailVerification goes through the links
+	err = s.store.SetEmailVerified(int(id), username+"@truthful.test")
+	if err != nil {
+		s.t.Fatalf("Error while verifying the email of user %s: %s", username, err.Error())
+	}
+	var tokens models.AuthTokens
+	code := s.do("POST", "/login", "", models.LoginInfos{Username: username, Password: password}, &tokens)
+	if code != http.StatusOK || tokens.AccessToken == "" {
+		s.t.Fatalf("Expected user %s to log in, got status %d", username, code)
+	}
+	return int(id), tokens.AccessToken
+}
+
+func TestE2EQuestionsAndAnswers(t *testing.T) {
+	runE2E(t, testQuestionsAndAnswers)
+}
+
+func testQuestionsAndAnswers(s *e2eServer) {
+	t := s.t
+
+	var registered struct {
+		Id    int    `json:"id"`
+		Token string `json:"token"`
+	}
+	code := s.do("POST", "/register", "", models.RegisterInfos{Username: "alice", Password: "Alice123@", Email: "alice@truthful.test", Birthdate: "1990-01-01"}, &registered)
+	if code != http.StatusCreated || registered.Token == "" {
+		t.Fatalf("Expected registration to succeed, got status %d", code)
+	}
+	aliceId, aliceToken := registered.Id, registered.Token
+	s.store.SetEmailVerified(aliceId, "alice@truthful.test")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+
+	code = s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "signed question"}, nil)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected question to be asked, got status %d", code)
+	}
+	code = s.do("POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: "anonymous question"}, nil)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected anonymous question to be asked, got status %d", code)
+	}
+
+	var page models.QuestionPage
+	code = s.do("GET", "/get_questions", aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Questions) != 2 || page.NextCursor != "" {
+		t.Fatalf("Expected 2 questions, got status %d and %+v", code, page)
+	}
+	var signed models.Question
+	for _, question := range page.Questions {
+		if question.IsAuthorAnonymous {
+			if question.Author.Id != 0 || question.Author.Username != "" {
+				t.Errorf("Expected anonymous question to hide its author, got %+v", question.Author)
+			}
+		} else {
+			signed = question
+		}
+	}
+	if signed.Author.Username != "bob" {
+		t.Fatalf("Expected signed question to be authored by bob, got %+v", signed.Author)
+	}
+
+	var answered struct {
+		Id int `json:"id"`
+	}
+	code = s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: signed.Id, AnswerText: "my answer"}, &answered)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected question to be answered, got status %d", code)
+	}
+	code = s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: signed.Id, AnswerText: "again"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected second answer to be rejected, got status %d", code)
+	}
+	page = models.QuestionPage{}
+	code = s.do("GET", "/get_questions", aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Questions) != 1 {
+		t.Errorf("Expected only the unanswered question to be left, got status %d and %+v", code, page)
+	}
+
+	code = s.do("POST", "/like_answer", bobToken, models.LikeAnswerInfos{AnswerId: answered.Id, Like: true}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected answer to be liked, got status %d", code)
+	}
+	code = s.do("POST", "/follow_user", bobToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected user to be followed, got status %d", code)
+	}
+
+	var profile models.UserProfileInfos
+	code = s.do("GET", "/get_user_profile/alice", bobToken, nil, &profile)
+	if code != http.StatusOK {
+		t.Fatalf("Expected profile, got status %d", code)
+	}
+	if profile.FollowerCount != 1 || !profile.IsFollowedByRequester || profile.AnswerCount != 1 || len(profile.Answers) != 1 {
+		t.Fatalf("Unexpected profile %+v", profile)
+	}
+	answer := profile.Answers[0]
+	if answer.LikeCount != 1 || !answer.LikedByRequester || answer.Author.Id != int64(bobId) || answer.QuestionText != "signed question" {
+		t.Errorf("Unexpected answer %+v", answer)
+	}
+}
+
+func TestE2ECursorPagination(t *testing.T) {
+	runE2E(t, testCursorPagination)
+}
+
+func testCursorPagination(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	_, bobToken := s.createUser("bob", "Bob12345@")
+
+	// the questions are asked within the same second, the id breaks the ties
+	for i := 0; i < 7; i++ {
+		code := s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: fmt.Sprintf("question %d", i)}, nil)
+		if code != http.StatusCreated {
+			t.Fatalf("Expected question to be asked, got status %d", code)
+		}
+	}
+
+	seen := map[int]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected the questions to fit in 3 pages")
+		}
+		var page models.QuestionPage
+		code := s.do("GET", "/get_questions?count=3&cursor="+url.QueryEscape(cursor), aliceToken, nil, &page)
+		if code != http.StatusOK {
+			t.Fatalf("Expected a page of questions, got status %d", code)
+		}
+		for _, question := range page.Questions {
+			if seen[question.Id] {
+				t.Errorf("Question %d was returned twice", question.Id)
+			}
+			seen[question.Id] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 7 {
+		t.Errorf("Expected 7 questions, got %d", len(seen))
+	}
+
+	code := s.do("GET", "/get_questions?cursor=forged", aliceToken, nil, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected forged cursor to be rejected, got status %d", code)
+	}
+}
+
+func TestE2ETimeline(t *testing.T) {
+	runE2E(t, testTimeline)
+}
+
+func testTimeline(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+	carolId, carolToken := s.createUser("carol", "Carol123@")
+
+	// bob and carol answer two questions each, alice follows both of them
+	for _, user := range []struct {
+		id    int
+		token string
+	}{{bobId, bobToken}, {carolId, carolToken}} {
+		for i := 0; i < 2; i++ {
+			s.do("POST", "/ask_question", aliceToken, models.AskQuestionInfos{UserId: user.id, QuestionText: fmt.Sprintf("question %d", i)}, nil)
+		}
+		var page models.QuestionPage
+		s.do("GET", "/get_questions", user.token, nil, &page)
+		for _, question := range page.Questions {
+			code := s.do("POST", "/answer_question", user.token, models.AnswerQuestionInfos{QuestionId: question.Id, AnswerText: "answer"}, nil)
+			if code != http.StatusCreated {
+				t.Fatalf("Expected question to be answered, got status %d", code)
+			}
+		}
+		s.do("POST", "/follow_user", aliceToken, models.FollowUserInfos{UserId: user.id, Follow: true}, nil)
+	}
+
+	code := s.do("GET", "/timeline", "", nil, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected timeline to require a token, got status %d", code)
+	}
+
+	var timeline models.TimelinePage
+	code = s.do("GET", "/timeline?count=3", aliceToken, nil, &timeline)
+	if code != http.StatusOK || len(timeline.Answers) != 3 || timeline.NextCursor == "" {
+		t.Fatalf("Expected a first page of 3 answers, got status %d and %+v", code, timeline)
+	}
+	first := timeline.Answers[0]
+	if first.Answerer.Username != "carol" || first.Author.Id != int64(aliceId) || first.QuestionText == "" {
+		t.Errorf("Expected the newest answer to be carol's, got %+v", first)
+	}
+	code = s.do("POST", "/like_answer", aliceToken, models.LikeAnswerInfos{AnswerId: first.Id, Like: true}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected answer to be liked, got status %d", code)
+	}
+	var next models.TimelinePage
+	s.do("GET", "/timeline?count=3&cursor="+url.QueryEscape(timeline.NextCursor), aliceToken, nil, &next)
+	if len(next.Answers) != 1 || next.NextCursor != "" || next.Answers[0].Answerer.Username != "bob" {
+		t.Errorf("Expected the oldest answer of bob on the last page, got %+v", next)
+	}
+
+	// the answers of banned users are hidden
+	err := s.store.GrantRole(aliceId, 1, aliceId)
+	if err != nil {
+		t.Fatalf("Error while granting admin role: %s", err.Error())
+	}
+	s.do("POST", "/moderation/ban_user", aliceToken, models.BanUserInfos{UserId: carolId, Reason: "spam"}, nil)
+	timeline = models.TimelinePage{}
+	s.do("GET", "/timeline", aliceToken, nil, &timeline)
+	if len(timeline.Answers) != 2 {
+		t.Fatalf("Expected only the answers of bob, got %+v", timeline.Answers)
+	}
+	for _, answer := range timeline.Answers {
+		if answer.Answerer.Id != int64(bobId) || answer.LikedByRequester {
+			t.Errorf("Unexpected answer %+v", answer)
+		}
+	}
+}
+
+func TestE2EFollowLists(t *testing.T) {
+	runE2E(t, testFollowLists)
+}
+
+func testFollowLists(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+	_, carolToken := s.createUser("carol", "Carol123@")
+
+	s.do("POST", "/follow_user", bobToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	s.do("POST", "/follow_user", carolToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	s.do("POST", "/follow_user", aliceToken, models.FollowUserInfos{UserId: bobId, Follow: true}, nil)
+	code := s.do("POST", "/follow_user", bobToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected second follow to be rejected, got status %d", code)
+	}
+
+	// the followers of alice seen by alice herself, bob follows her back
+	var page models.FollowPage
+	code = s.do("GET", "/users/alice/followers?count=1", aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Users) != 1 || page.NextCursor == "" {
+		t.Fatalf("Expected a first page of followers, got status %d and %+v", code, page)
+	}
+	var next models.FollowPage
+	s.do("GET", "/users/alice/followers?count=1&cursor="+url.QueryEscape(page.NextCursor), aliceToken, nil, &next)
+	if len(next.Users) != 1 || next.NextCursor != "" {
+		t.Fatalf("Expected a last page of followers, got %+v", next)
+	}
+	followers := map[string]models.FollowPreview{}
+	for _, user := range append(page.Users, next.Users...) {
+		followers[user.Username] = user
+	}
+	if !followers["bob"].IsMutual || followers["carol"].IsMutual || !followers["carol"].FollowsRequester {
+		t.Errorf("Unexpected followers %+v", followers)
+	}
+
+	// anonymous requesters get no flag
+	page = models.FollowPage{}
+	code = s.do("GET", "/users/bob/following", "", nil, &page)
+	if code != http.StatusOK || len(page.Users) != 1 || page.Users[0].Username != "alice" || page.Users[0].FollowedByRequester {
+		t.Errorf("Expected bob to follow alice, got status %d and %+v", code, page)
+	}
+	code = s.do("GET", "/users/nobody/followers", "", nil, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected unknown user to be not found, got status %d", code)
+	}
+}
+
+func TestE2ENotifications(t *testing.T) {
+	runE2E(t, testNotifications)
+}
+
+func testNotifications(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+
+	// bob asks a signed and an anonymous question, follows alice and likes her answer
+	s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "signed question"}, nil)
+	s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "anonymous question", IsAuthorAnonymous: true}, nil)
+	s.do("POST", "/follow_user", bobToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	var questions models.QuestionPage
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	var answerIds []int
+	for _, question := range questions.Questions {
+		var answered struct {
+			Id int `json:"id"`
+		}
+		s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: question.Id, AnswerText: "answer"}, &answered)
+		answerIds = append(answerIds, answered.Id)
+	}
+	for _, answerId := range answerIds {
+		s.do("POST", "/like_answer", bobToken, models.LikeAnswerInfos{AnswerId: answerId, Like: true}, nil)
+	}
+
+	var unread struct {
+		UnreadCount int `json:"unread_count"`
+	}
+	code := s.do("GET", "/notifications/unread_count", aliceToken, nil, &unread)
+	if code != http.StatusOK || unread.UnreadCount != 5 {
+		t.Errorf("Expected 5 unread notifications, got status %d and %d", code, unread.UnreadCount)
+	}
+
+	// the asker of the anonymous question can't be found from the notifications
+	var page models.NotificationPage
+	s.do("GET", "/notifications", aliceToken, nil, &page)
+	if len(page.Notifications) != 5 || page.UnreadCount != 5 {
+		t.Fatalf("Expected 5 notifications, got %+v", page)
+	}
+	types := map[string]int{}
+	for _, notification := range page.Notifications {
+		types[notification.Type]++
+		if notification.Type != "question_received" && (notification.Actor == nil || notification.Actor.Id != int64(bobId)) {
+			t.Errorf("Expected bob to be the actor, got %+v", notification)
+		}
+	}
+	if types["question_received"] != 2 || types["new_follower"] != 1 || types["answer_liked"] != 2 {
+		t.Errorf("Unexpected notification types %v", types)
+	}
+	var anonymous, signed int
+	for _, notification := range page.Notifications {
+		if notification.Type != "question_received" {
+			continue
+		}
+		if notification.Actor == nil {
+			anonymous++
+		} else if notification.Actor.Id == int64(bobId) {
+			signed++
+		}
+	}
+	if anonymous != 1 || signed != 1 {
+		t.Errorf("Expected one anonymous and one signed question, got %d and %d", anonymous, signed)
+	}
+
+	// bob is told about the answer to his signed question only
+	var bobPage models.NotificationPage
+	s.do("GET", "/notifications", bobToken, nil, &bobPage)
+	if len(bobPage.Notifications) != 1 || bobPage.Notifications[0].Type != "question_answered" || bobPage.Notifications[0].Actor.Id != int64(aliceId) {
+		t.Errorf("Expected bob to be notified of one answer, got %+v", bobPage.Notifications)
+	}
+
+	code = s.do("POST", "/notifications/read", bobToken, models.ReadNotificationInfos{NotificationId: page.Notifications[0].Id}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected the notification of another user not to be found, got status %d", code)
+	}
+	code = s.do("POST", "/notifications/read", aliceToken, models.ReadNotificationInfos{NotificationId: page.Notifications[0].Id}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected notification to be read, got status %d", code)
+	}
+	s.do("GET", "/notifications/unread_count", aliceToken, nil, &unread)
+	if unread.UnreadCount != 4 {
+		t.Errorf("Expected 4 unread notifications, got %d", unread.UnreadCount)
+	}
+	code = s.do("POST", "/notifications/read_all", aliceToken, nil, nil)
+	s.do("GET", "/notifications/unread_count", aliceToken, nil, &unread)
+	if code != http.StatusOK || unread.UnreadCount != 0 {
+		t.Errorf("Expected every notification to be read, got status %d and %d", code, unread.UnreadCount)
+	}
+}
+
+func TestE2EModeration(t *testing.T) {
+	runE2E(t, testModeration)
+}
+
+func testModeration(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+	carolId, carolToken := s.createUser("carol", "Carol123@")
+
+	code := s.do("POST", "/moderation/ban_user", bobToken, models.BanUserInfos{UserId: carolId, Reason: "spam"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected ban without permission to be forbidden, got status %d", code)
+	}
+
+	adminRoleId, err := s.store.GetRoleId("admin")
+	if err != nil {
+		t.Fatalf("Error while getting admin role: %s", err.Error())
+	}
+	err = s.store.GrantRole(aliceId, adminRoleId, aliceId)
+	if err != nil {
+		t.Fatalf("Error while granting admin role: %s", err.Error())
+	}
+	code = s.do("POST", "/moderation/promote", aliceToken, models.PromoteUserInfos{UserId: bobId, PromoteType: "moderator"}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected bob to be promoted, got status %d", code)
+	}
+
+	var banned struct {
+		BanId int `json:"ban_id"`
+	}
+	code = s.do("POST", "/moderation/ban_user", bobToken, models.BanUserInfos{UserId: carolId, Reason: "spam"}, &banned)
+	if code != http.StatusOK || banned.BanId == 0 {
+		t.Fatalf("Expected carol to be banned, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/ban_user", bobToken, models.BanUserInfos{UserId: aliceId, Reason: "spam"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected ban of an admin to be forbidden, got status %d", code)
+	}
+
+	var rejected struct {
+		Ban models.Ban `json:"ban"`
+	}
+	code = s.do("POST", "/follow_user", carolToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, &rejected)
+	if code != http.StatusForbidden || rejected.Ban.Id != banned.BanId || !rejected.Ban.IsPermanent {
+		t.Errorf("Expected banned user to be rejected with their ban, got status %d and %+v", code, rejected)
+	}
+	code = s.do("POST", "/login", "", models.LoginInfos{Username: "carol", Password: "Carol123@"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected banned user to be unable to log in, got status %d", code)
+	}
+}
+
+func TestE2EEventStream(t *testing.T) {
+	runE2E(t, testEventStream)
+}
+
+// readEvent reads the stream until an event or a comment other than the connection one, and returns its name and data
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error while reading stream: %s", err.Error())
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && (name != "" || data != ""):
+			return name, data
+		case strings.HasPrefix(line, ": ") && line != ": connected":
+			return "", strings.TrimPrefix(line, ": ")
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimPrefix(line, "data:")
+		}
+	}
+}
+
+func testEventStream(s *e2eServer) {
+	t := s.t
+	hub, _ := events.NewHub(nil)
+	events.SetHub(hub)
+	defer hub.Close()
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	_, bobToken := s.createUser("bob", "Bob12345@")
+
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	code := s.do("GET", "/events", "", nil, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without token, got %d", code)
+	}
+	// the access token is not taken from the URL, where it would be logged
+	code = s.do("GET", "/events?access_token="+url.QueryEscape(aliceToken), "", nil, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the access token of the URL to be ignored, got %d", code)
+	}
+	code = s.do("GET", "/events?ticket=forged", "", nil, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("Expected the stream to be refused with a forged ticket, got %d", code)
+	}
+
+	// alice opens two streams with a ticket each, both receive her events
+	var readers []*bufio.Reader
+	for i := 0; i < 2; i++ {
+		var ticket models.StreamTicketInfos
+		code = s.do("POST", "/events/ticket", aliceToken, nil, &ticket)
+		if code != http.StatusOK || ticket.Ticket == "" || ticket.ExpiresIn <= 0 {
+			t.Fatalf("Expected a stream ticket, got status %d and %+v", code, ticket)
+		}
+		resp, err := http.Get(server.URL + "/events?ticket=" + url.QueryEscape(ticket.Ticket))
+		if err != nil {
+			t.Fatalf("Error while opening stream: %s", err.Error())
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got status %d and %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		readers = append(readers, bufio.NewReader(resp.Body))
+
+		// a ticket opens a single stream
+		code = s.do("GET", "/events?ticket="+url.QueryEscape(ticket.Ticket), "", nil, nil)
+		if code != http.StatusUnauthorized {
+			t.Errorf("Expected a used ticket to be refused, got %d", code)
+		}
+	}
+	for hub.CountSubscriptions(aliceId) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "anonymous question", IsAuthorAnonymous: true}, nil)
+	for _, reader := range readers {
+		name, data := readEvent(t, reader)
+		var notification models.Notification
+		json.Unmarshal([]byte(data), &notification)
+		if name != events.Notification || notification.Type != "question_received" || notification.Actor != nil {
+			t.Errorf("Expected the notification of the anonymous question, got %s %s", name, data)
+		}
+		name, data = readEvent(t, reader)
+		var question models.Question
+		json.Unmarshal([]byte(data), &question)
+		if name != events.NewQuestion || question.Text != "anonymous question" || question.Author.Username != "" {
+			t.Errorf("Expected the anonymous question without its author, got %s %s", name, data)
+		}
+	}
+
+	// closing the hub ends the streams
+	hub.Close()
+	for _, reader := range readers {
+		_, err := reader.ReadString('\n')
+		for err == nil {
+			_, err = reader.ReadString('\n')
+		}
+	}
+	if hub.CountSubscriptions(aliceId) != 0 {
+		t.Errorf("Expected the subscriptions to end with the hub")
+	}
+}
+
+func TestE2EEventStreamHeartbeat(t *testing.T) {
+	runE2E(t, func(s *e2eServer) {
+		hub, _ := events.NewHub(nil)
+		events.SetHub(hub)
+		defer hub.Close()
+		defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+		heartbeatInterval = 10 * time.Millisecond
+		_, token := s.createUser("alice", "Alice123@")
+
+		server := httptest.NewServer(s.router)
+		defer server.Close()
+		req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error while opening stream: %s", err.Error())
+		}
+		defer resp.Body.Close()
+		name, data := readEvent(t, bufio.NewReader(resp.Body))
+		if name != "" || data != "heartbeat" {
+			t.Errorf("Expected a heartbeat, got %s %s", name, data)
+		}
+	})
+}
+
+func TestE2EEventStreamEnd(t *testing.T) {
+	runE2E(t, func(s *e2eServer) {
+		hub, _ := events.NewHub(nil)
+		events.SetHub(hub)
+		defer hub.Close()
+		defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+		heartbeatInterval = 10 * time.Millisecond
+		aliceId, aliceToken := s.createUser("alice", "Alice123@")
+		bobId, _ := s.createUser("bob", "Bob12345@")
+		server := httptest.NewServer(s.router)
+		defer server.Close()
+
+		// the stream ends with the access token the ticket was created with
+		err := s.store.AddStreamTicket(aliceId, token.HashStreamTicket("expiring"), time.Now().Add(time.Minute), time.Now().Add(50*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Error while adding stream ticket: %s", err.Error())
+		}
+		resp, err := http.Get(server.URL + "/events?ticket=expiring")
+		if err != nil {
+			t.Fatalf("Error while opening stream: %s", err.Error())
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		if err != nil {
+			t.Errorf("Expected the stream to end when the access token expires, got %s", err.Error())
+		}
+
+		// a user banned while streaming stops receiving events
+		var ticket models.StreamTicketInfos
+		s.do("POST", "/events/ticket", aliceToken, nil, &ticket)
+		resp, err = http.Get(server.URL + "/events?ticket=" + url.QueryEscape(ticket.Ticket))
+		if err != nil {
+			t.Fatalf("Error while opening stream: %s", err.Error())
+		}
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		name, data := readEvent(t, reader)
+		if name != "" || data != "heartbeat" {
+			t.Errorf("Expected a heartbeat, got %s %s", name, data)
+		}
+		_, err = s.store.BanUser(aliceId, bobId, 0, "spam")
+		if err != nil {
+			t.Fatalf("Error while banning alice: %s", err.Error())
+		}
+		_, err = io.ReadAll(reader)
+		if err != nil {
+			t.Errorf("Expected the stream to end with the ban, got %s", err.Error())
+		}
+		if hub.CountSubscriptions(aliceId) != 0 {
+			t.Errorf("Expected the subscriptions to end with the ban")
+		}
+		code := s.do("POST", "/events/ticket", aliceToken, nil, nil)
+		if code != http.StatusForbidden {
+			t.Errorf("Expected a banned user to get no ticket, got %d", code)
+		}
+	})
+}
+
+func TestE2EBlockAndMute(t *testing.T) {
+	runE2E(t, testBlockAndMute)
+}
+
+func testBlockAndMute(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+	carolId, carolToken := s.createUser("carol", "Carol123@")
+
+	// alice answers a question, alice and bob follow each other
+	s.do("POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: "question"}, nil)
+	var questions models.QuestionPage
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	var answered struct {
+		Id int `json:"id"`
+	}
+	s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: questions.Questions[0].Id, AnswerText: "answer"}, &answered)
+	s.do("POST", "/follow_user", aliceToken, models.FollowUserInfos{UserId: bobId, Follow: true}, nil)
+	s.do("POST", "/follow_user", bobToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+
+	code := s.do("POST", "/users/block", aliceToken, models.BlockUserInfos{UserId: aliceId, Block: true}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected users not to block themselves, got status %d", code)
+	}
+	code = s.do("POST", "/users/block", aliceToken, models.BlockUserInfos{UserId: bobId, Block: true}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected bob to be blocked, got status %d", code)
+	}
+	code = s.do("POST", "/users/block", aliceToken, models.BlockUserInfos{UserId: bobId, Block: true}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the block to exist already, got status %d", code)
+	}
+
+	// the follows are removed both ways
+	var follows models.FollowPage
+	s.do("GET", "/users/alice/followers", "", nil, &follows)
+	if len(follows.Users) != 0 {
+		t.Errorf("Expected alice to lose bob as follower, got %+v", follows.Users)
+	}
+	s.do("GET", "/users/alice/following", "", nil, &follows)
+	if len(follows.Users) != 0 {
+		t.Errorf("Expected alice to stop following bob, got %+v", follows.Users)
+	}
+
+	// bob can't ask, even anonymously, follow or like
+	for _, anonymous := range []bool{false, true} {
+		code = s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "question", IsAuthorAnonymous: anonymous}, nil)
+		if code != http.StatusForbidden {
+			t.Errorf("Expected bob not to ask alice, anonymous %t, got status %d", anonymous, code)
+		}
+	}
+	code = s.do("POST", "/follow_user", bobToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected bob not to follow alice, got status %d", code)
+	}
+	code = s.do("POST", "/follow_user", aliceToken, models.FollowUserInfos{UserId: bobId, Follow: true}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected alice not to follow the user she blocked, got status %d", code)
+	}
+	code = s.do("POST", "/like_answer", bobToken, models.LikeAnswerInfos{AnswerId: answered.Id, Like: true}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected bob not to like the answer of alice, got status %d", code)
+	}
+
+	// bob doesn't see the answers of alice, others still do
+	var profile models.UserProfileInfos
+	s.do("GET", "/get_user_profile/alice", bobToken, nil, &profile)
+	if !profile.HasBlockedRequester || len(profile.Answers) != 0 {
+		t.Errorf("Expected the answers to be hidden from bob, got %+v", profile)
+	}
+	s.do("GET", "/get_user_profile/alice", carolToken, nil, &profile)
+	if profile.HasBlockedRequester || len(profile.Answers) != 1 {
+		t.Errorf("Expected carol to see the answers, got %+v", profile)
+	}
+	s.do("GET", "/get_user_profile/bob", aliceToken, nil, &profile)
+	if !profile.IsBlockedByRequester {
+		t.Errorf("Expected the profile to tell alice blocks bob, got %+v", profile)
+	}
+
+	var blocked models.RelatedUserPage
+	code = s.do("GET", "/users/blocked", aliceToken, nil, &blocked)
+	if code != http.StatusOK || len(blocked.Users) != 1 || blocked.Users[0].Username != "bob" {
+		t.Errorf("Expected bob in the blocked users, got status %d and %+v", code, blocked)
+	}
+
+	// unblocking lets bob follow alice again
+	code = s.do("POST", "/users/block", aliceToken, models.BlockUserInfos{UserId: bobId, Block: false}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected bob to be unblocked, got status %d", code)
+	}
+	code = s.do("POST", "/follow_user", bobToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected bob to follow alice again, got status %d", code)
+	}
+
+	// alice is not notified of the actions of carol once muted, and carol's answers leave her timeline
+	s.do("POST", "/follow_user", aliceToken, models.FollowUserInfos{UserId: carolId, Follow: true}, nil)
+	s.do("POST", "/ask_question", aliceToken, models.AskQuestionInfos{UserId: carolId, QuestionText: "question"}, nil)
+	s.do("GET", "/get_questions", carolToken, nil, &questions)
+	s.do("POST", "/answer_question", carolToken, models.AnswerQuestionInfos{QuestionId: questions.Questions[0].Id, AnswerText: "answer"}, nil)
+	var timeline models.TimelinePage
+	s.do("GET", "/timeline", aliceToken, nil, &timeline)
+	if len(timeline.Answers) != 1 {
+		t.Fatalf("Expected the answer of carol in the timeline, got %+v", timeline)
+	}
+	var notifications models.NotificationPage
+	s.do("GET", "/notifications", aliceToken, nil, &notifications)
+	notificationCount := len(notifications.Notifications)
+
+	code = s.do("POST", "/users/mute", aliceToken, models.MuteUserInfos{UserId: carolId, Mute: true}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected carol to be muted, got status %d", code)
+	}
+	s.do("POST", "/follow_user", carolToken, models.FollowUserInfos{UserId: aliceId, Follow: true}, nil)
+	s.do("POST", "/ask_question", carolToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "muted question", IsAuthorAnonymous: false}, nil)
+	s.do("GET", "/notifications", aliceToken, nil, &notifications)
+	if len(notifications.Notifications) != notificationCount {
+		t.Errorf("Expected no notification from carol, got %+v", notifications.Notifications)
+	}
+	s.do("GET", "/timeline", aliceToken, nil, &timeline)
+	if len(timeline.Answers) != 0 {
+		t.Errorf("Expected the answers of carol to leave the timeline, got %+v", timeline)
+	}
+
+	var muted models.RelatedUserPage
+	code = s.do("GET", "/users/muted", aliceToken, nil, &muted)
+	if code != http.StatusOK || len(muted.Users) != 1 || muted.Users[0].Username != "carol" {
+		t.Errorf("Expected carol in the muted users, got status %d and %+v", code, muted)
+	}
+	code = s.do("POST", "/users/mute", aliceToken, models.MuteUserInfos{UserId: carolId, Mute: false}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected carol to be unmuted, got status %d", code)
+	}
+	code = s.do("POST", "/users/mute", aliceToken, models.MuteUserInfos{UserId: carolId, Mute: false}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected carol not to be muted anymore, got status %d", code)
+	}
+}
+
+func TestE2EAskerBlocks(t *testing.T) {
+	runE2E(t, testAskerBlocks)
+}
+
+func testAskerBlocks(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+
+	// an anonymous asker and bob ask alice from two addresses
+	longQuestion := strings.Repeat("a", 60)
+	s.doFrom("198.51.100.1", "POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: longQuestion}, nil)
+	s.doFrom("198.51.100.2", "POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "signed question"}, nil)
+	var questions models.QuestionPage
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	if len(questions.Questions) != 2 {
+		t.Fatalf("Expected 2 questions, got %+v", questions)
+	}
+	signedId, anonymousId := questions.Questions[0].Id, questions.Questions[1].Id
+
+	code := s.do("POST", "/questions/block_asker", bobToken, models.BlockAskerInfos{QuestionId: anonymousId}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected only the receiver to block the asker, got status %d", code)
+	}
+	code = s.do("POST", "/questions/block_asker", aliceToken, models.BlockAskerInfos{QuestionId: signedId}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected signed questions to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/questions/block_asker", aliceToken, models.BlockAskerInfos{QuestionId: anonymousId, Duration: -1}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected negative durations to be refused, got status %d", code)
+	}
+	var blocked struct {
+		Id int `json:"id"`
+	}
+	code = s.do("POST", "/questions/block_asker", aliceToken, models.BlockAskerInfos{QuestionId: anonymousId, Duration: 24}, &blocked)
+	if code != http.StatusCreated || blocked.Id == 0 {
+		t.Fatalf("Expected the asker to be blocked, got status %d", code)
+	}
+
+	// the address of the asker can't ask alice anymore, even logged in, others still can
+	code = s.doFrom("198.51.100.1", "POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: "again"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected the blocked asker to be refused, got status %d", code)
+	}
+	code = s.doFrom("198.51.100.1", "POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "again"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected the blocked address to be refused, got status %d", code)
+	}
+	code = s.doFrom("198.51.100.1", "POST", "/ask_question", "", models.AskQuestionInfos{UserId: bobId, QuestionText: "to bob"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected the asker to still ask others, got status %d", code)
+	}
+	code = s.doFrom("198.51.100.2", "POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: "other asker"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected other askers to still ask alice, got status %d", code)
+	}
+
+	// the list shows a snippet of the question and never the address
+	var page struct {
+		Blocks []map[string]any `json:"blocks"`
+	}
+	code = s.do("GET", "/questions/blocked_askers", aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Blocks) != 1 {
+		t.Fatalf("Expected one blocked asker, got status %d and %+v", code, page)
+	}
+	block := page.Blocks[0]
+	if block["question_snippet"] != strings.Repeat("a", 50)+"…" || block["is_permanent"] != false || block["expires_at"] == nil {
+		t.Errorf("Expected a temporary block with a snippet, got %+v", block)
+	}
+	for key, value := range block {
+		if strings.Contains(fmt.Sprint(value), "198.51.100.1") || key == "ip_hash" || key == "ip_address" {
+			t.Errorf("Expected the address not to be exposed, got %s: %v", key, value)
+		}
+	}
+
+	code = s.do("POST", "/questions/unblock_asker", bobToken, models.UnblockAskerInfos{BlockId: blocked.Id}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected the block to be found only for alice, got status %d", code)
+	}
+	code = s.do("POST", "/questions/unblock_asker", aliceToken, models.UnblockAskerInfos{BlockId: blocked.Id}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected the asker to be unblocked, got status %d", code)
+	}
+	code = s.doFrom("198.51.100.1", "POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: "again"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected the unblocked asker to ask again, got status %d", code)
+	}
+}
+
+func TestE2EInboxSettings(t *testing.T) {
+	// a siteverify endpoint accepting the "solved" token only
+	captchaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		json.NewEncoder(w).Encode(gin.H{"success": r.PostForm.Get("response") == "solved"})
+	}))
+	defer captchaServer.Close()
+	t.Setenv("CAPTCHA_SECRET", "secret")
+	t.Setenv("CAPTCHA_VERIFY_URL", captchaServer.URL)
+	runE2E(t, testInboxSettings)
+}
+
+func testInboxSettings(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+
+	var settings models.UserSettings
+	code := s.do("GET", "/users/settings", aliceToken, nil, &settings)
+	if code != http.StatusOK || settings != models.DefaultUserSettings {
+		t.Fatalf("Expected the default settings, got status %d and %+v", code, settings)
+	}
+	code = s.do("POST", "/users/settings", aliceToken, gin.H{"guest_questions": "sometimes"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected unknown guest_questions to be refused, got status %d", code)
+	}
+
+	// ask returns the status and the code of the error, if any
+	ask := func(accessToken string, isAuthorAnonymous bool, captchaToken string) (int, string) {
+		var response struct {
+			Code string `json:"code"`
+		}
+		code := s.do("POST", "/ask_question", accessToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "question", IsAuthorAnonymous: isAuthorAnonymous, CaptchaToken: captchaToken}, &response)
+		return code, response.Code
+	}
+	update := func(settings gin.H) {
+		if code := s.do("POST", "/users/settings", aliceToken, settings, nil); code != http.StatusOK {
+			t.Fatalf("Expected settings %v to be updated, got status %d", settings, code)
+		}
+	}
+
+	update(gin.H{"guest_questions": "captcha"})
+	if code, errorCode := ask("", true, ""); code != http.StatusBadRequest || errorCode != "captcha_required" {
+		t.Errorf("Expected a captcha to be required, got %d %s", code, errorCode)
+	}
+	if code, errorCode := ask("", true, "wrong"); code != http.StatusBadRequest || errorCode != "captcha_invalid" {
+		t.Errorf("Expected the captcha to be invalid, got %d %s", code, errorCode)
+	}
+	if code, _ := ask("", true, "solved"); code != http.StatusCreated {
+		t.Errorf("Expected the guest who solved the captcha to ask, got %d", code)
+	}
+	if code, _ := ask(bobToken, true, ""); code != http.StatusCreated {
+		t.Errorf("Expected logged in users to ask without captcha, got %d", code)
+	}
+
+	update(gin.H{"guest_questions": "disabled"})
+	if code, errorCode := ask("", true, "solved"); code != http.StatusUnauthorized || errorCode != "login_required" {
+		t.Errorf("Expected a login to be required, got %d %s", code, errorCode)
+	}
+
+	update(gin.H{"allow_anonymous_questions": false})
+	if code, errorCode := ask(bobToken, true, ""); code != http.StatusForbidden || errorCode != "anonymous_questions_disabled" {
+		t.Errorf("Expected anonymous questions to be refused, got %d %s", code, errorCode)
+	}
+	if code, _ := ask(bobToken, false, ""); code != http.StatusCreated {
+		t.Errorf("Expected signed questions to be accepted, got %d", code)
+	}
+
+	update(gin.H{"following_only": true})
+	if code, errorCode := ask(bobToken, false, ""); code != http.StatusForbidden || errorCode != "following_only" {
+		t.Errorf("Expected questions from users alice doesn't follow to be refused, got %d %s", code, errorCode)
+	}
+	s.do("POST", "/follow_user", aliceToken, models.FollowUserInfos{UserId: bobId, Follow: true}, nil)
+	if code, _ := ask(bobToken, false, ""); code != http.StatusCreated {
+		t.Errorf("Expected questions from users alice follows to be accepted, got %d", code)
+	}
+
+	update(gin.H{"inbox_paused": true})
+	if code, errorCode := ask(bobToken, false, ""); code != http.StatusForbidden || errorCode != "inbox_paused" {
+		t.Errorf("Expected questions to be refused while the inbox is paused, got %d %s", code, errorCode)
+	}
+
+	// the settings that were not given are kept
+	s.do("GET", "/users/settings", aliceToken, nil, &settings)
+	expected := models.UserSettings{GuestQuestions: models.GuestQuestionsDisabled, FollowingOnly: true, InboxPaused: true}
+	if settings != expected {
+		t.Errorf("Expected %+v, got %+v", expected, settings)
+	}
+	if code := s.do("GET", "/users/settings", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected the settings to need a token, got status %d", code)
+	}
+}
+
+func TestE2EContentFilter(t *testing.T) {
+	previous := filter.GetPipeline()
+	blocklist, err := filter.ParseBlocklist(strings.NewReader("casino"))
+	if err != nil {
+		t.Fatalf("Error while parsing blocklist: %s", err.Error())
+	}
+	filter.SetPipeline(filter.NewPipeline(blocklist, filter.MutedWords{}, filter.Links{Score: filter.HoldScore}))
+	defer filter.SetPipeline(previous)
+	runE2E(t, testContentFilter)
+}
+
+func testContentFilter(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+
+	var rejected struct {
+		Code string `json:"code"`
+	}
+	code := s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "casino tonight?"}, &rejected)
+	if code != http.StatusBadRequest || rejected.Code != "content_rejected" {
+		t.Errorf("Expected the blocklisted question to be rejected, got status %d and %+v", code, rejected)
+	}
+
+	code = s.do("POST", "/users/muted_words", aliceToken, models.MuteWordInfos{Word: "Pizza", Mute: true}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the word to be muted, got status %d", code)
+	}
+	var muted struct {
+		Words []string `json:"words"`
+	}
+	s.do("GET", "/users/muted_words", aliceToken, nil, &muted)
+	if len(muted.Words) != 1 || muted.Words[0] != "pizza" {
+		t.Errorf("Expected pizza to be muted, got %v", muted.Words)
+	}
+	code = s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "Do you like PIZZA?"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the muted word to reject the question, got status %d", code)
+	}
+	code = s.do("POST", "/ask_question", aliceToken, models.AskQuestionInfos{UserId: bobId, QuestionText: "Do you like pizza?"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected the words to be muted for alice only, got status %d", code)
+	}
+
+	// links are held until a moderator approves them
+	code = s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "see https://example.com"}, nil)
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected the question to be held, got status %d", code)
+	}
+	var questions models.QuestionPage
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	if len(questions.Questions) != 0 {
+		t.Errorf("Expected the held question to be hidden, got %+v", questions.Questions)
+	}
+
+	code = s.do("GET", "/moderation/held_content", bobToken, nil, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected the queue to need a permission, got status %d", code)
+	}
+	carolId, carolToken := s.createUser("carol", "Carol123@")
+	moderatorRoleId, err := s.store.GetRoleId("moderator")
+	if err != nil {
+		t.Fatalf("Error while getting moderator role: %s", err.Error())
+	}
+	err = s.store.GrantRole(carolId, moderatorRoleId, carolId)
+	if err != nil {
+		t.Fatalf("Error while granting moderator role: %s", err.Error())
+	}
+	var held models.HeldContentPage
+	code = s.do("GET", "/moderation/held_content", carolToken, nil, &held)
+	if code != http.StatusOK || len(held.Contents) != 1 || held.Contents[0].AuthorId != bobId || len(held.Contents[0].Reasons) != 1 {
+		t.Fatalf("Expected the held question in the queue, got status %d and %+v", code, held)
+	}
+
+	code = s.do("POST", "/moderation/review_held_content", carolToken, models.ReviewHeldContentInfos{HeldId: held.Contents[0].Id, Approve: true}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the question to be approved, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/review_held_content", carolToken, models.ReviewHeldContentInfos{HeldId: held.Contents[0].Id, Approve: false}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the question to be reviewed once, got status %d", code)
+	}
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	if len(questions.Questions) != 1 || questions.Questions[0].Text != "see https://example.com" {
+		t.Fatalf("Expected the approved question in the inbox, got %+v", questions.Questions)
+	}
+
+	// a held answer keeps the question from being answered again
+	code = s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: questions.Questions[0].Id, AnswerText: "go to www.example.org"}, nil)
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected the answer to be held, got status %d", code)
+	}
+	code = s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: questions.Questions[0].Id, AnswerText: "answer"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected the question not to be answered while an answer is held, got status %d", code)
+	}
+	s.do("GET", "/moderation/held_content", carolToken, nil, &held)
+	if len(held.Contents) != 1 || held.Contents[0].ContentType != "answer" {
+		t.Fatalf("Expected the held answer in the queue, got %+v", held)
+	}
+	code = s.do("POST", "/moderation/review_held_content", carolToken, models.ReviewHeldContentInfos{HeldId: held.Contents[0].Id, Approve: false}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected the answer to be rejected, got status %d", code)
+	}
+	code = s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: questions.Questions[0].Id, AnswerText: "answer"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected the question to be answered once the held answer is rejected, got status %d", code)
+	}
+}
+
+func TestE2EReports(t *testing.T) {
+	runE2E(t, testReports)
+}
+
+func testReports(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+	carolId, carolToken := s.createUser("carol", "Carol123@")
+	daveId, daveToken := s.createUser("dave", "Dave1234@")
+	moderatorRoleId, err := s.store.GetRoleId("moderator")
+	if err != nil {
+		t.Fatalf("Error while getting moderator role: %s", err.Error())
+	}
+	err = s.store.GrantRole(daveId, moderatorRoleId, daveId)
+	if err != nil {
+		t.Fatalf("Error while granting moderator role: %s", err.Error())
+	}
+
+	// bob asks alice a question she answers
+	s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "rude question"}, nil)
+	var questions models.QuestionPage
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	if len(questions.Questions) != 1 {
+		t.Fatalf("Expected alice to get the question, got %+v", questions.Questions)
+	}
+	questionId := questions.Questions[0].Id
+	var answered struct {
+		Id int `json:"id"`
+	}
+	s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: questionId, AnswerText: "answer"}, &answered)
+
+	code := s.do("POST", "/report", "", models.ReportInfos{TargetType: "user", TargetId: bobId, Reason: "spam"}, nil)
+	if code != http.StatusBadRequest && code != http.StatusUnauthorized {
+		t.Errorf("Expected reports to need a token, got status %d", code)
+	}
+	code = s.do("POST", "/report", aliceToken, models.ReportInfos{TargetType: "user", TargetId: bobId, Reason: "boring"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected unknown reasons to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/report", aliceToken, models.ReportInfos{TargetType: "answer", TargetId: answered.Id, Reason: "spam"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected users not to report their own answers, got status %d", code)
+	}
+	code = s.do("POST", "/report", aliceToken, models.ReportInfos{TargetType: "question", TargetId: 1000, Reason: "spam"}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected unknown questions to be refused, got status %d", code)
+	}
+
+	for _, token := range []string{aliceToken, carolToken} {
+		code = s.do("POST", "/report", token, models.ReportInfos{TargetType: "question", TargetId: questionId, Reason: "harassment", Details: "mean"}, nil)
+		if code != http.StatusCreated {
+			t.Fatalf("Expected the question to be reported, got status %d", code)
+		}
+	}
+	code = s.do("POST", "/report", carolToken, models.ReportInfos{TargetType: "question", TargetId: questionId, Reason: "spam"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected a single open report per user and target, got status %d", code)
+	}
+	s.do("POST", "/report", carolToken, models.ReportInfos{TargetType: "user", TargetId: bobId, Reason: "spam"}, nil)
+	s.do("POST", "/report", aliceToken, models.ReportInfos{TargetType: "user", TargetId: bobId, Reason: "hate"}, nil)
+	s.do("POST", "/report", bobToken, models.ReportInfos{TargetType: "answer", TargetId: answered.Id, Reason: "other"}, nil)
+
+	if code := s.do("GET", "/moderation/reports", bobToken, nil, nil); code != http.StatusForbidden {
+		t.Errorf("Expected the queue to need a permission, got status %d", code)
+	}
+	var queue models.ReportGroupPage
+	code = s.do("GET", "/moderation/reports?count=2", daveToken, nil, &queue)
+	if code != http.StatusOK || len(queue.Groups) != 2 || queue.NextCursor == "" {
+		t.Fatalf("Expected a first page of 2 targets, got status %d and %+v", code, queue)
+	}
+	if queue.Groups[0].TargetType != "answer" || queue.Groups[1].TargetType != "user" || queue.Groups[1].ReportCount != 2 || len(queue.Groups[1].Reasons) != 2 {
+		t.Errorf("Expected the answer then bob with 2 reports, got %+v", queue.Groups)
+	}
+	s.do("GET", "/moderation/reports?count=2&cursor="+url.QueryEscape(queue.NextCursor), daveToken, nil, &queue)
+	if len(queue.Groups) != 1 || queue.Groups[0].TargetType != "question" || queue.Groups[0].ReportCount != 2 || queue.Groups[0].Reasons[0] != "harassment" {
+		t.Fatalf("Expected the question with 2 reports on the second page, got %+v", queue.Groups)
+	}
+
+	var reports struct {
+		Reports []models.Report `json:"reports"`
+	}
+	code = s.do("GET", fmt.Sprintf("/moderation/reports/target?target_type=question&target_id=%d", questionId), daveToken, nil, &reports)
+	if code != http.StatusOK || len(reports.Reports) != 2 || reports.Reports[0].ReporterId != aliceId || reports.Reports[1].Details != "mean" {
+		t.Errorf("Expected the reports of the question, got status %d and %+v", code, reports.Reports)
+	}
+
+	// an admin can't take over the reports claimed by dave
+	adminRoleId, _ := s.store.GetRoleId("admin")
+	s.store.GrantRole(carolId, adminRoleId, carolId)
+	code = s.do("POST", "/moderation/reports/claim", daveToken, models.ClaimReportsInfos{TargetType: "question", TargetId: questionId, Claim: true}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected dave to claim the reports, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/reports/resolve", carolToken, models.ResolveReportsInfos{TargetType: "question", TargetId: questionId, Action: "dismiss"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected claimed reports to be resolved by their moderator only, got status %d", code)
+	}
+
+	var resolution models.ReportResolution
+	code = s.do("POST", "/moderation/reports/resolve", daveToken, models.ResolveReportsInfos{TargetType: "question", TargetId: questionId, Action: "delete", Note: "harassment"}, &resolution)
+	if code != http.StatusOK || resolution.ReportCount != 2 || resolution.ModeratorId != daveId {
+		t.Fatalf("Expected the question to be removed, got status %d and %+v", code, resolution)
+	}
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	if len(questions.Questions) != 0 {
+		t.Errorf("Expected the answered question to stay out of the inbox, got %+v", questions.Questions)
+	}
+	code = s.do("POST", "/moderation/reports/resolve", daveToken, models.ResolveReportsInfos{TargetType: "question", TargetId: questionId, Action: "dismiss"}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected the reports to be resolved once, got status %d", code)
+	}
+
+	code = s.do("POST", "/moderation/reports/resolve", daveToken, models.ResolveReportsInfos{TargetType: "user", TargetId: bobId, Action: "ban", Duration: 24}, &resolution)
+	if code != http.StatusOK || resolution.BanId == 0 {
+		t.Fatalf("Expected bob to be banned, got status %d and %+v", code, resolution)
+	}
+	code = s.do("GET", "/moderation/reports", bobToken, nil, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected bob to be banned, got status %d", code)
+	}
+
+	var history models.ReportResolutionPage
+	code = s.do("GET", "/moderation/reports/history", daveToken, nil, &history)
+	if code != http.StatusOK || len(history.Resolutions) != 2 || history.Resolutions[0].Action != "ban" || history.Resolutions[1].Note != "harassment" {
+		t.Errorf("Expected the ban then the deletion, got status %d and %+v", code, history)
+	}
+	s.do("GET", fmt.Sprintf("/moderation/reports/history?target_type=user&target_id=%d", bobId), daveToken, nil, &history)
+	if len(history.Resolutions) != 1 || history.Resolutions[0].ReportCount != 2 {
+		t.Errorf("Expected the resolution of the reports of bob, got %+v", history)
+	}
+
+	// the reports of a resolved target can be made again
+	code = s.do("POST", "/report", carolToken, models.ReportInfos{TargetType: "user", TargetId: bobId, Reason: "spam"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected bob to be reported again, got status %d", code)
+	}
+}
+
+func TestE2EContentRemoval(t *testing.T) {
+	runE2E(t, testContentRemoval)
+}
+
+func testContentRemoval(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	_, bobToken := s.createUser("bob", "Bob12345@")
+	daveId, daveToken := s.createUser("dave", "Dave1234@")
+	moderatorRoleId, err := s.store.GetRoleId("moderator")
+	if err != nil {
+		t.Fatalf("Error while getting moderator role: %s", err.Error())
+	}
+	err = s.store.GrantRole(daveId, moderatorRoleId, daveId)
+	if err != nil {
+		t.Fatalf("Error while granting moderator role: %s", err.Error())
+	}
+
+	// bob asks alice two questions, she answers the first one
+	s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "first question"}, nil)
+	s.do("POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "second question"}, nil)
+	var questions models.QuestionPage
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	if len(questions.Questions) != 2 {
+		t.Fatalf("Expected alice to get 2 questions, got %+v", questions.Questions)
+	}
+	firstId, secondId := questions.Questions[1].Id, questions.Questions[0].Id
+	var answered struct {
+		Id int `json:"id"`
+	}
+	s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: firstId, AnswerText: "answer"}, &answered)
+
+	code := s.do("POST", "/moderation/delete_answer", bobToken, models.RemoveAnswerInfos{AnswerId: answered.Id, Reason: "spam"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected removals to need a permission, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/delete_answer", daveToken, models.RemoveAnswerInfos{AnswerId: answered.Id, Reason: " "}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected removals to need a reason, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/delete_answer", daveToken, models.RemoveAnswerInfos{AnswerId: 1000, Reason: "spam"}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected unknown answers to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/delete_answer", daveToken, models.RemoveAnswerInfos{AnswerId: answered.Id, Reason: "spam"}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the answer to be removed, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/delete_answer", daveToken, models.RemoveAnswerInfos{AnswerId: answered.Id, Reason: "spam"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the answer to be removed once, got status %d", code)
+	}
+
+	// the removed answer keeps its place, without its content
+	var profile models.UserProfileInfos
+	s.do("GET", "/get_user_profile/alice", bobToken, nil, &profile)
+	if len(profile.Answers) != 1 || !profile.Answers[0].RemovedByModeration || profile.Answers[0].AnswerText != "" || profile.Answers[0].QuestionText != "" {
+		t.Errorf("Expected the answer to be shown as removed, got %+v", profile.Answers)
+	}
+	code = s.do("POST", "/report", bobToken, models.ReportInfos{TargetType: "answer", TargetId: answered.Id, Reason: "spam"}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected removed answers not to be reported, got status %d", code)
+	}
+
+	code = s.do("POST", "/moderation/restore_answer", daveToken, models.RestoreAnswerInfos{AnswerId: answered.Id}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the answer to be restored, got status %d", code)
+	}
+	s.do("GET", "/get_user_profile/alice", bobToken, nil, &profile)
+	if len(profile.Answers) != 1 || profile.Answers[0].RemovedByModeration || profile.Answers[0].AnswerText != "answer" {
+		t.Errorf("Expected the answer to be restored, got %+v", profile.Answers)
+	}
+	code = s.do("POST", "/moderation/restore_answer", daveToken, models.RestoreAnswerInfos{AnswerId: answered.Id}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected only removed answers to be restored, got status %d", code)
+	}
+
+	// a removed question stays in the inbox of its receiver, who can't answer it
+	code = s.do("POST", "/moderation/delete_question", daveToken, models.RemoveQuestionInfos{QuestionId: secondId}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected removals to need a reason, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/delete_question", daveToken, models.RemoveQuestionInfos{QuestionId: secondId, Reason: "harassment"}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the question to be removed, got status %d", code)
+	}
+	s.do("GET", "/get_questions", aliceToken, nil, &questions)
+	if len(questions.Questions) != 1 || !questions.Questions[0].RemovedByModeration || questions.Questions[0].Text != "" {
+		t.Errorf("Expected the question to be shown as removed, got %+v", questions.Questions)
+	}
+	code = s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: secondId, AnswerText: "answer"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected removed questions not to be answered, got status %d", code)
+	}
+
+	// removing an answered question hides its answer too
+	s.do("POST", "/moderation/delete_question", daveToken, models.RemoveQuestionInfos{QuestionId: firstId, Reason: "harassment"}, nil)
+	s.do("GET", "/get_user_profile/alice", bobToken, nil, &profile)
+	if len(profile.Answers) != 1 || !profile.Answers[0].RemovedByModeration || profile.Answers[0].AnswerText != "" {
+		t.Errorf("Expected the answer of the removed question to be shown as removed, got %+v", profile.Answers)
+	}
+
+	code = s.do("POST", "/moderation/restore_question", daveToken, models.RestoreQuestionInfos{QuestionId: secondId}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the question to be restored, got status %d", code)
+	}
+	code = s.do("POST", "/answer_question", aliceToken, models.AnswerQuestionInfos{QuestionId: secondId, AnswerText: "answer"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected the restored question to be answered, got status %d", code)
+	}
+}
+
+func TestE2EAuditLog(t *testing.T) {
+	runE2E(t, testAuditLog)
+}
+
+func testAuditLog(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, _ := s.createUser("bob", "Bob12345@")
+	daveId, daveToken := s.createUser("dave", "Dave1234@")
+	for userId, role := range map[int]string{aliceId: "admin", daveId: "moderator"} {
+		roleId, err := s.store.GetRoleId(role)
+		if err != nil {
+			t.Fatalf("Error while getting %s role: %s", role, err.Error())
+		}
+		err = s.store.GrantRole(userId, roleId, aliceId)
+		if err != nil {
+			t.Fatalf("Error while granting %s role: %s", role, err.Error())
+		}
+	}
+
+	// dave bans then pardons bob, a refused ban is not logged
+	code := s.doFrom("10.0.0.1", "POST", "/moderation/ban_user", daveToken, models.BanUserInfos{UserId: daveId, Reason: "self"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected moderators not to ban themselves, got status %d", code)
+	}
+	var banned struct {
+		BanId int `json:"ban_id"`
+	}
+	code = s.doFrom("10.0.0.1", "POST", "/moderation/ban_user", daveToken, models.BanUserInfos{UserId: bobId, Duration: 24, Reason: "spam"}, &banned)
+	if code != http.StatusOK {
+		t.Fatalf("Expected bob to be banned, got status %d", code)
+	}
+	s.doFrom("10.0.0.2", "POST", "/moderation/pardon_user", daveToken, models.PardonUserInfos{BanId: banned.BanId}, nil)
+
+	code = s.do("GET", "/moderation/audit", daveToken, nil, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected the audit log to be reserved to admins, got status %d", code)
+	}
+	var page models.AuditPage
+	code = s.do("GET", "/moderation/audit?action=user.ban", aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Entries) != 1 {
+		t.Fatalf("Expected 1 ban in the audit log, got status %d and %+v", code, page.Entries)
+	}
+	ban := page.Entries[0]
+	if ban.ModeratorId != daveId || ban.TargetType != "user" || ban.TargetId != bobId || ban.IpAddress != "10.0.0.1" {
+		t.Errorf("Unexpected ban entry %+v", ban)
+	}
+	var payload struct {
+		After struct {
+			BanId  int    `json:"ban_id"`
+			Reason string `json:"reason"`
+		} `json:"after"`
+	}
+	err := json.Unmarshal(ban.Payload, &payload)
+	if err != nil || payload.After.BanId != banned.BanId || payload.After.Reason != "spam" {
+		t.Errorf("Expected the ban in the payload, got %s, %v", ban.Payload, err)
+	}
+
+	page = models.AuditPage{}
+	s.do("GET", fmt.Sprintf("/moderation/audit?target_type=ban&target_id=%d", banned.BanId), aliceToken, nil, &page)
+	if len(page.Entries) != 1 || page.Entries[0].Action != "user.pardon" || page.Entries[0].IpAddress != "10.0.0.2" {
+		t.Errorf("Expected the pardon of the ban, got %+v", page.Entries)
+	}
+	page = models.AuditPage{}
+	s.do("GET", fmt.Sprintf("/moderation/audit?moderator_id=%d&count=1", daveId), aliceToken, nil, &page)
+	if len(page.Entries) != 1 || page.Entries[0].Action != "user.pardon" || page.NextCursor == "" {
+		t.Fatalf("Expected the pardon first with a next page, got %+v", page)
+	}
+	cursor := page.NextCursor
+	page = models.AuditPage{}
+	s.do("GET", fmt.Sprintf("/moderation/audit?moderator_id=%d&count=1&cursor=%s", daveId, cursor), aliceToken, nil, &page)
+	if len(page.Entries) != 1 || page.Entries[0].Action != "user.ban" || page.NextCursor != "" {
+		t.Errorf("Expected the ban last, got %+v", page)
+	}
+	future := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	page = models.AuditPage{}
+	s.do("GET", "/moderation/audit?from="+future, aliceToken, nil, &page)
+	if len(page.Entries) != 0 {
+		t.Errorf("Expected no entry in the future, got %+v", page.Entries)
+	}
+	for _, query := range []string{"action=banUser", "from=yesterday", "target_type=comment", "format=xml"} {
+		code = s.do("GET", "/moderation/audit?"+query, aliceToken, nil, nil)
+		if code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be refused, got status %d", query, code)
+		}
+	}
+
+	// exports
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/moderation/audit?moderator_id=%d&format=csv", daveId), nil)
+	req.Header.Set("Authorization", "Bearer "+aliceToken)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || err != nil || len(records) != 3 || records[0][0] != "id" || records[1][3] != "user.pardon" {
+		t.Errorf("Expected a CSV export of 2 entries, got status %d, %v and %v", w.Code, records, err)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("Expected a CSV content type, got %s", w.Header().Get("Content-Type"))
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/moderation/audit?moderator_id=%d&format=jsonl", daveId), nil)
+	req.Header.Set("Authorization", "Bearer "+aliceToken)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var entry models.AuditEntry
+	if w.Code != http.StatusOK || len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &entry) != nil || entry.Action != "user.ban" {
+		t.Errorf("Expected a JSON Lines export of 2 entries, got status %d and %s", w.Code, w.Body.String())
+	}
+}
+
+func TestE2EBans(t *testing.T) {
+	runE2E(t, testBans)
+}
+
+func testBans(s *e2eServer) {
+	t := s.t
+	daveId, daveToken := s.createUser("dave", "Dave1234@")
+	bobId, _ := s.createUser("bob", "Bob12345@")
+	carolId, _ := s.createUser("carol", "Carol123@")
+	roleId, err := s.store.GetRoleId("moderator")
+	if err != nil {
+		t.Fatalf("Error while getting moderator role: %s", err.Error())
+	}
+	s.store.GrantRole(daveId, roleId, daveId)
+
+	// permanent bans can be pardoned
+	var banned struct {
+		BanId int `json:"ban_id"`
+	}
+	s.do("POST", "/moderation/ban_user", daveToken, models.BanUserInfos{UserId: bobId, Reason: "spam"}, &banned)
+	permanentBanId := banned.BanId
+	code := s.do("POST", "/moderation/pardon_user", daveToken, models.PardonUserInfos{BanId: permanentBanId}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected the permanent ban to be pardoned, got status %d", code)
+	}
+	s.do("POST", "/moderation/ban_user", daveToken, models.BanUserInfos{UserId: bobId, Duration: 24, Reason: "spam again"}, &banned)
+	s.do("POST", "/moderation/ban_user", daveToken, models.BanUserInfos{UserId: carolId, Duration: 24, Reason: "insults"}, nil)
+
+	var page models.BanPage
+	code = s.do("GET", fmt.Sprintf("/moderation/users/%d/bans", bobId), daveToken, nil, &page)
+	if code != http.StatusOK || len(page.Bans) != 2 {
+		t.Fatalf("Expected the 2 bans of bob, got status %d and %+v", code, page.Bans)
+	}
+	if page.Bans[0].Status != models.BanStatusActive || page.Bans[1].Status != models.BanStatusPardoned || page.Bans[1].Pardon == nil || page.Bans[1].Pardon.PardonerId != daveId {
+		t.Errorf("Expected the active ban then the pardoned one, got %+v", page.Bans)
+	}
+	code = s.do("GET", "/moderation/users/1000/bans", daveToken, nil, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing user, got status %d", code)
+	}
+
+	page = models.BanPage{}
+	s.do("GET", "/moderation/bans?status=active&count=1", daveToken, nil, &page)
+	if len(page.Bans) != 1 || page.Bans[0].UserId != carolId || page.NextCursor == "" {
+		t.Fatalf("Expected the ban of carol first with a next page, got %+v", page)
+	}
+	cursor := page.NextCursor
+	page = models.BanPage{}
+	s.do("GET", "/moderation/bans?status=active&count=1&cursor="+cursor, daveToken, nil, &page)
+	if len(page.Bans) != 1 || page.Bans[0].Id != banned.BanId || page.NextCursor != "" {
+		t.Errorf("Expected the active ban of bob last, got %+v", page)
+	}
+	page = models.BanPage{}
+	s.do("GET", fmt.Sprintf("/moderation/bans?status=pardoned&author_id=%d", daveId), daveToken, nil, &page)
+	if len(page.Bans) != 1 || page.Bans[0].Id != permanentBanId || !page.Bans[0].IsPermanent {
+		t.Errorf("Expected the pardoned permanent ban, got %+v", page.Bans)
+	}
+	code = s.do("GET", "/moderation/bans?status=lifted", daveToken, nil, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid status to be refused, got status %d", code)
+	}
+
+	// edits are counted from the creation of the ban and audited
+	var ban models.BanDetails
+	code = s.do("POST", "/moderation/edit_ban", daveToken, models.EditBanInfos{BanId: banned.BanId, Duration: 72}, &ban)
+	if code != http.StatusOK || ban.ExpiresAt == nil || ban.ExpiresAt.Sub(ban.CreatedAt).Round(time.Hour) != 72*time.Hour {
+		t.Errorf("Expected the ban to last 72 hours, got status %d and %+v", code, ban)
+	}
+	s.do("GET", fmt.Sprintf("/moderation/users/%d/bans", bobId), daveToken, nil, &page)
+	edited := page.Bans[0]
+	if edited.ExpiresAt == nil || edited.ExpiresAt.Sub(edited.CreatedAt).Round(time.Hour) != 72*time.Hour {
+		t.Errorf("Expected the edit to be saved, got %+v", edited)
+	}
+	code = s.do("POST", "/moderation/edit_ban", daveToken, models.EditBanInfos{BanId: permanentBanId, Duration: 72}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected a pardoned ban not to be edited, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/edit_ban", daveToken, models.EditBanInfos{BanId: 1000, Duration: 72}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing ban, got status %d", code)
+	}
+	entries, _ := s.store.GetAuditEntries(models.AuditFilter{Action: models.AuditBanEdit}, nil, 10)
+	if len(entries) != 1 || entries[0].TargetId != banned.BanId || !strings.Contains(string(entries[0].Payload), `"duration":72`) {
+		t.Errorf("Expected the edit in the audit log, got %+v", entries)
+	}
+}
+
+func TestE2EAddressBans(t *testing.T) {
+	runE2E(t, testAddressBans)
+}
+
+func testAddressBans(s *e2eServer) {
+	t := s.t
+	daveId, daveToken := s.createUser("dave", "Dave1234@")
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+	_, carolToken := s.createUser("carol", "Carol123@")
+	roleId, err := s.store.GetRoleId("moderator")
+	if err != nil {
+		t.Fatalf("Error while getting moderator role: %s", err.Error())
+	}
+	s.store.GrantRole(daveId, roleId, daveId)
+	adminRoleId, _ := s.store.GetRoleId("admin")
+	s.store.GrantRole(aliceId, adminRoleId, aliceId)
+
+	// bob and carol ask from the same network
+	s.doFrom("10.0.0.5", "POST", "/ask_question", bobToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "from bob"}, nil)
+	s.doFrom("10.0.0.5", "POST", "/ask_question", carolToken, models.AskQuestionInfos{UserId: aliceId, QuestionText: "from carol"}, nil)
+	var linked models.LinkedAccounts
+	code := s.do("GET", fmt.Sprintf("/moderation/users/%d/linked_accounts", bobId), daveToken, nil, &linked)
+	if code != http.StatusOK || len(linked.IpAddresses) != 1 || len(linked.Accounts) != 1 || linked.Accounts[0].Username != "carol" {
+		t.Errorf("Expected carol to be linked to bob, got status %d and %+v", code, linked)
+	}
+	code = s.do("GET", fmt.Sprintf("/moderation/users/%d/linked_accounts", bobId), bobToken, nil, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected users not to see linked accounts, got status %d", code)
+	}
+
+	var added struct {
+		BanId int `json:"ban_id"`
+	}
+	code = s.do("POST", "/moderation/add_address_ban", daveToken, models.AddAddressBanInfos{BanType: models.AddressBanIp, Value: "10.0.0.0/8"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected a too broad range to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/add_address_ban", daveToken, models.AddAddressBanInfos{BanType: models.AddressBanIp, Value: "10.0.0.0/24", Reason: "ban evasion"}, &added)
+	if code != http.StatusOK || added.BanId == 0 {
+		t.Fatalf("Expected the range to be banned, got status %d", code)
+	}
+	ipBanId := added.BanId
+	s.do("POST", "/moderation/add_address_ban", daveToken, models.AddAddressBanInfos{BanType: models.AddressBanEmailDomain, Value: "spam.test", Duration: 24}, nil)
+
+	// the banned network can't register, log in nor ask as a guest, other networks still can
+	register := models.RegisterInfos{Username: "eve", Password: "Eve12345@", Email: "eve@truthful.test", Birthdate: "1990-01-01"}
+	code = s.doFrom("10.0.0.7", "POST", "/register", "", register, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected registering from the banned network to be refused, got status %d", code)
+	}
+	code = s.doFrom("10.0.0.7", "POST", "/login", "", models.LoginInfos{Username: "bob", Password: "Bob12345@"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected logging in from the banned network to be refused, got status %d", code)
+	}
+	code = s.doFrom("10.0.0.7", "POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: "guest question"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected guest questions from the banned network to be refused, got status %d", code)
+	}
+	code = s.doFrom("10.0.1.7", "POST", "/ask_question", "", models.AskQuestionInfos{UserId: aliceId, QuestionText: "guest question"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected guest questions from another network to be accepted, got status %d", code)
+	}
+	register.Email = "eve@mail.spam.test"
+	code = s.doFrom("10.0.1.7", "POST", "/register", "", register, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected registering with a banned domain to be refused, got status %d", code)
+	}
+
+	var page models.AddressBanPage
+	code = s.do("GET", "/moderation/address_bans?ban_type=ip", daveToken, nil, &page)
+	if code != http.StatusOK || len(page.Bans) != 1 || page.Bans[0].Value != "10.0.0.0/24" || !page.Bans[0].IsPermanent {
+		t.Errorf("Expected the IP ban, got status %d and %+v", code, page)
+	}
+	page = models.AddressBanPage{}
+	s.do("GET", "/moderation/address_bans", daveToken, nil, &page)
+	if len(page.Bans) != 2 || page.Bans[0].BanType != models.AddressBanEmailDomain {
+		t.Errorf("Expected the 2 bans, most recent first, got %+v", page)
+	}
+
+	code = s.do("POST", "/moderation/remove_address_ban", daveToken, models.RemoveAddressBanInfos{BanId: ipBanId}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected the IP ban to be removed, got status %d", code)
+	}
+	code = s.doFrom("10.0.0.7", "POST", "/login", "", models.LoginInfos{Username: "bob", Password: "Bob12345@"}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected logging in to work once the ban is removed, got status %d", code)
+	}
+	code = s.do("POST", "/moderation/remove_address_ban", daveToken, models.RemoveAddressBanInfos{BanId: ipBanId}, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected a removed ban not to be found, got status %d", code)
+	}
+
+	var audit models.AuditPage
+	s.do("GET", "/moderation/audit?target_type=address_ban", aliceToken, nil, &audit)
+	if len(audit.Entries) != 3 || audit.Entries[0].Action != models.AuditAddressBanRemove {
+		t.Errorf("Expected the address bans to be audited, got %+v", audit.Entries)
+	}
+}
+
+// e2eMailer keeps the emails sent by the server
+type e2eMailer struct {
+	messages []mailer.Message
+}
+
+func (m *e2eMailer) Send(message mailer.Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// linkToken returns the token of the link in the email
+func linkToken(t *testing.T, message mailer.Message) string {
+	for _, line := range strings.Split(message.Body, "\n") {
+		link, err := url.Parse(line)
+		if err == nil && link.Query().Get("token") != "" {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("Expected a link in %q", message.Body)
+	return ""
+}
+
+func TestE2EPasswordReset(t *testing.T) {
+	previous := mailer.GetMailer()
+	defer mailer.SetMailer(previous)
+	runE2E(t, testPasswordReset)
+}
+
+func testPasswordReset(s *e2eServer) {
+	t := s.t
+	sent := &e2eMailer{}
+	mailer.SetMailer(sent)
+	s.createUser("alice", "Alice123@")
+	var tokens models.AuthTokens
+	s.do("POST", "/login", "", models.LoginInfos{Username: "alice", Password: "Alice123@"}, &tokens)
+
+	// unknown addresses get the same answer, without email
+	code := s.do("POST", "/password/forgot", "", models.ForgotPasswordInfos{Email: "nobody@truthful.test"}, nil)
+	if code != http.StatusOK || len(sent.messages) != 0 {
+		t.Errorf("Expected 200 without email, got status %d and %+v", code, sent.messages)
+	}
+	for range 2 {
+		code = s.do("POST", "/password/forgot", "", models.ForgotPasswordInfos{Email: "alice@truthful.test"}, nil)
+		if code != http.StatusOK {
+			t.Errorf("Expected the reset link to be sent, got status %d", code)
+		}
+	}
+	if len(sent.messages) != 2 || sent.messages[0].To != "alice@truthful.test" {
+		t.Fatalf("Expected 2 emails to alice, got %+v", sent.messages)
+	}
+	firstToken := linkToken(t, sent.messages[0])
+	secondToken := linkToken(t, sent.messages[1])
+
+	code = s.do("POST", "/password/reset", "", models.ResetPasswordInfos{Token: secondToken, Password: "weak"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected a weak password to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/password/reset", "", models.ResetPasswordInfos{Token: "unknown", Password: "Alice456@"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown token to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/password/reset", "", models.ResetPasswordInfos{Token: secondToken, Password: "Alice456@"}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the password to be reset, got status %d", code)
+	}
+
+	// the old password, the sessions and the other links don't work anymore
+	code = s.do("POST", "/login", "", models.LoginInfos{Username: "alice", Password: "Alice123@"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the old password to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/login", "", models.LoginInfos{Username: "alice", Password: "Alice456@"}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected the new password to work, got status %d", code)
+	}
+	code = s.do("POST", "/refresh_token", "", models.RefreshTokenInfos{RefreshToken: tokens.RefreshToken}, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("Expected the sessions to be revoked, got status %d", code)
+	}
+	for _, used := range []string{firstToken, secondToken} {
+		code = s.do("POST", "/password/reset", "", models.ResetPasswordInfos{Token: used, Password: "Alice789@"}, nil)
+		if code != http.StatusBadRequest {
+			t.Errorf("Expected the reset links to be revoked, got status %d", code)
+		}
+	}
+
+	// an account gets a few links per hour, an IP address makes a few more requests
+	s.do("POST", "/password/forgot", "", models.ForgotPasswordInfos{Email: "alice@truthful.test"}, nil)
+	s.do("POST", "/password/forgot", "", models.ForgotPasswordInfos{Email: "alice@truthful.test"}, nil)
+	if len(sent.messages) != 3 {
+		t.Errorf("Expected a third email only, got %d", len(sent.messages))
+	}
+	for range 10 {
+		s.doFrom("10.0.0.9", "POST", "/password/forgot", "", models.ForgotPasswordInfos{Email: "nobody@truthful.test"}, nil)
+	}
+	code = s.doFrom("10.0.0.9", "POST", "/password/forgot", "", models.ForgotPasswordInfos{Email: "nobody@truthful.test"}, nil)
+	if code != http.StatusTooManyRequests {
+		t.Errorf("Expected too many requests, got status %d", code)
+	}
+}
+
+func TestE2EEmailVerification(t *testing.T) {
+	previous := mailer.GetMailer()
+	defer mailer.SetMailer(previous)
+	runE2E(t, testEmailVerification)
+}
+
+func testEmailVerification(s *e2eServer) {
+	t := s.t
+	sent := &e2eMailer{}
+	mailer.SetMailer(sent)
+	bobId, _ := s.createUser("bob", "Bob12345@")
+
+	var registered struct {
+		Id    int    `json:"id"`
+		Token string `json:"token"`
+	}
+	code := s.do("POST", "/register", "", models.RegisterInfos{Username: "carol", Password: "Carol123@", Email: "carol@truthful.test", Birthdate: "1990-01-01"}, &registered)
+	if code != http.StatusCreated || len(sent.messages) != 1 || sent.messages[0].To != "carol@truthful.test" {
+		t.Fatalf("Expected the registration to send a verification email, got status %d and %+v", code, sent.messages)
+	}
+	carolToken := registered.Token
+	var status models.EmailStatus
+	code = s.do("GET", "/users/email", carolToken, nil, &status)
+	if code != http.StatusOK || status.Email != "carol@truthful.test" || status.Verified || status.PendingEmail != nil {
+		t.Errorf("Expected the email to be unverified, got status %d and %+v", code, status)
+	}
+
+	// unverified accounts can only ask anonymously
+	code = s.do("POST", "/ask_question", carolToken, models.AskQuestionInfos{UserId: bobId, QuestionText: "signed question"}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected the signed question to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/ask_question", carolToken, models.AskQuestionInfos{UserId: bobId, QuestionText: "anonymous question", IsAuthorAnonymous: true}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected the anonymous question to be asked, got status %d", code)
+	}
+
+	registrationLink := linkToken(t, sent.messages[0])
+	code = s.do("POST", "/email/verify", "", models.VerifyEmailInfos{Token: registrationLink}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the email to be verified, got status %d", code)
+	}
+	code = s.do("POST", "/email/verify", "", models.VerifyEmailInfos{Token: registrationLink}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the link to work once, got status %d", code)
+	}
+	code = s.do("POST", "/ask_question", carolToken, models.AskQuestionInfos{UserId: bobId, QuestionText: "signed question"}, nil)
+	if code != http.StatusCreated {
+		t.Errorf("Expected the signed question to be asked, got status %d", code)
+	}
+
+	code = s.do("PUT", "/users/update", carolToken, models.UpdateUserInfos{DisplayName: "Carol", Email: "BOB@truthful.test"}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the email of bob to be refused, got status %d", code)
+	}
+
+	// a new email waits for its verification
+	code = s.do("PUT", "/users/update", carolToken, models.UpdateUserInfos{DisplayName: "Carol", Email: "carol@new.test"}, nil)
+	if code != http.StatusOK || len(sent.messages) != 2 || sent.messages[1].To != "carol@new.test" {
+		t.Fatalf("Expected a verification email to the new address, got status %d and %+v", code, sent.messages)
+	}
+	code = s.do("GET", "/users/email", carolToken, nil, &status)
+	if code != http.StatusOK || status.Email != "carol@truthful.test" || !status.Verified || status.PendingEmail == nil || *status.PendingEmail != "carol@new.test" {
+		t.Errorf("Expected carol@new.test to be pending, got status %d and %+v", code, status)
+	}
+
+	// the link sent again replaces the previous one
+	code = s.do("POST", "/users/email/resend", carolToken, nil, nil)
+	if code != http.StatusOK || len(sent.messages) != 3 || sent.messages[2].To != "carol@new.test" {
+		t.Fatalf("Expected the verification email to be sent again, got status %d and %+v", code, sent.messages)
+	}
+	code = s.do("POST", "/email/verify", "", models.VerifyEmailInfos{Token: linkToken(t, sent.messages[1])}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the previous link to be revoked, got status %d", code)
+	}
+	code = s.do("POST", "/email/verify", "", models.VerifyEmailInfos{Token: linkToken(t, sent.messages[2])}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected the new email to be verified, got status %d", code)
+	}
+	code = s.do("GET", "/users/email", carolToken, nil, &status)
+	if code != http.StatusOK || status.Email != "carol@new.test" || !status.Verified || status.PendingEmail != nil {
+		t.Errorf("Expected carol@new.test to be the verified email, got status %d and %+v", code, status)
+	}
+
+	// the verification emails are limited
+	code = s.do("PUT", "/users/update", carolToken, models.UpdateUserInfos{DisplayName: "Carol", Email: "carol@other.test"}, nil)
+	if code != http.StatusTooManyRequests {
+		t.Errorf("Expected too many verification emails, got status %d", code)
+	}
+}
+
+func TestE2ETwoFactor(t *testing.T) {
+	runE2E(t, testTwoFactor)
+}
+
+func testTwoFactor(s *e2eServer) {
+	t := s.t
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	bobId, bobToken := s.createUser("bob", "Bob12345@")
+	carolId, _ := s.createUser("carol", "Carol123@")
+	adminRoleId, _ := s.store.GetRoleId("admin")
+	s.store.GrantRole(aliceId, adminRoleId, aliceId)
+	code := s.do("POST", "/moderation/promote", aliceToken, models.PromoteUserInfos{UserId: bobId, PromoteType: "moderator"}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected bob to be promoted, got status %d", code)
+	}
+
+	// the moderators need a second factor to use their permissions
+	code = s.do("POST", "/moderation/roles/two_factor", aliceToken, models.RoleTwoFactorInfos{Role: "moderator", Required: true}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the role to require a second factor, got status %d", code)
+	}
+	var refused struct {
+		Error string `json:"error"`
+	}
+	code = s.do("POST", "/moderation/ban_user", bobToken, models.BanUserInfos{UserId: carolId, Reason: "spam"}, &refused)
+	if code != http.StatusForbidden || !strings.Contains(refused.Error, "two-factor") {
+		t.Errorf("Expected the ban to need a second factor, got status %d and %+v", code, refused)
+	}
+	var status models.TwoFactorStatus
+	code = s.do("GET", "/users/two_factor", bobToken, nil, &status)
+	if code != http.StatusOK || status.Enabled || !status.Required {
+		t.Errorf("Expected a required second factor, got status %d and %+v", code, status)
+	}
+
+	var enrollment models.TwoFactorEnrollment
+	code = s.do("POST", "/users/two_factor/enroll", bobToken, nil, &enrollment)
+	if code != http.StatusOK || enrollment.Secret == "" || !strings.HasPrefix(enrollment.Uri, "otpauth://totp/Truthful:bob?") {
+		t.Fatalf("Expected a secret, got status %d and %+v", code, enrollment)
+	}
+	step := totp.Step(time.Now())
+	codeAt := func(step int64) string {
+		code, _ := totp.CodeAt(enrollment.Secret, step)
+		return code
+	}
+	code = s.do("POST", "/users/two_factor/confirm", bobToken, models.TwoFactorCodeInfos{Code: codeAt(step - 5)}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected an old code to be refused, got status %d", code)
+	}
+	var recoveryCodes models.RecoveryCodes
+	code = s.do("POST", "/users/two_factor/confirm", bobToken, models.TwoFactorCodeInfos{Code: codeAt(step)}, &recoveryCodes)
+	if code != http.StatusOK || len(recoveryCodes.Codes) != 10 {
+		t.Fatalf("Expected the recovery codes, got status %d and %+v", code, recoveryCodes)
+	}
+	code = s.do("POST", "/moderation/ban_user", bobToken, models.BanUserInfos{UserId: carolId, Reason: "spam"}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected bob to ban with a second factor, got status %d", code)
+	}
+	code = s.do("POST", "/users/two_factor/disable", bobToken, models.TwoFactorCodeInfos{Code: recoveryCodes.Codes[0]}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected the required second factor to stay, got status %d", code)
+	}
+
+	// the password only opens a challenge
+	var tokens models.AuthTokens
+	login := func() string {
+		tokens = models.AuthTokens{}
+		code := s.do("POST", "/login", "", models.LoginInfos{Username: "bob", Password: "Bob12345@"}, &tokens)
+		if code != http.StatusOK || tokens.ChallengeToken == "" || tokens.AccessToken != "" {
+			t.Fatalf("Expected a challenge, got status %d and %+v", code, tokens)
+		}
+		return tokens.ChallengeToken
+	}
+	challengeToken := login()
+	code = s.do("POST", "/login/two_factor", "", models.LoginTwoFactorInfos{ChallengeToken: challengeToken, Code: codeAt(step)}, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("Expected the used code to be refused, got status %d", code)
+	}
+	code = s.do("POST", "/login/two_factor", "", models.LoginTwoFactorInfos{ChallengeToken: challengeToken, Code: codeAt(step + 1)}, &tokens)
+	if code != http.StatusOK || tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Errorf("Expected bob to log in, got status %d and %+v", code, tokens)
+	}
+	code = s.do("POST", "/login/two_factor", "", models.LoginTwoFactorInfos{ChallengeToken: challengeToken, Code: recoveryCodes.Codes[0]}, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("Expected the challenge to be passed once, got status %d", code)
+	}
+
+	// a recovery code replaces the recovery codes
+	var newCodes models.RecoveryCodes
+	code = s.do("POST", "/users/two_factor/recovery_codes", bobToken, models.TwoFactorCodeInfos{Code: recoveryCodes.Codes[0]}, &newCodes)
+	if code != http.StatusOK || len(newCodes.Codes) != 10 {
+		t.Fatalf("Expected new recovery codes, got status %d and %+v", code, newCodes)
+	}
+	code = s.do("POST", "/users/two_factor/recovery_codes", bobToken, models.TwoFactorCodeInfos{Code: recoveryCodes.Codes[1]}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected the previous recovery codes to be replaced, got status %d", code)
+	}
+
+	// the recovery codes work once, whatever the case they are typed in
+	challengeToken = login()
+	code = s.do("POST", "/login/two_factor", "", models.LoginTwoFactorInfos{ChallengeToken: challengeToken, Code: strings.ToUpper(newCodes.Codes[0])}, &tokens)
+	if code != http.StatusOK || tokens.AccessToken == "" {
+		t.Errorf("Expected the recovery code to log bob in, got status %d", code)
+	}
+	challengeToken = login()
+	for range 5 {
+		code = s.do("POST", "/login/two_factor", "", models.LoginTwoFactorInfos{ChallengeToken: challengeToken, Code: newCodes.Codes[0]}, nil)
+		if code != http.StatusUnauthorized {
+			t.Errorf("Expected the used recovery code to be refused, got status %d", code)
+		}
+	}
+	code = s.do("POST", "/login/two_factor", "", models.LoginTwoFactorInfos{ChallengeToken: challengeToken, Code: newCodes.Codes[1]}, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("Expected the challenge to stop after too many attempts, got status %d", code)
+	}
+	code = s.do("GET", "/users/two_factor", bobToken, nil, &status)
+	if code != http.StatusOK || !status.Enabled || status.RecoveryCodesLeft != 9 {
+		t.Errorf("Expected 9 recovery codes left, got status %d and %+v", code, status)
+	}
+
+	// no role requires the second factor anymore
+	code = s.do("POST", "/moderation/roles/two_factor", aliceToken, models.RoleTwoFactorInfos{Role: "moderator", Required: false}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the requirement to be lifted, got status %d", code)
+	}
+	// the wrong codes counted as failed logins of bob, the access token can't be used to try more codes
+	var throttled struct {
+		RetryAfter int `json:"retry_after"`
+	}
+	code = s.do("POST", "/users/two_factor/disable", bobToken, models.TwoFactorCodeInfos{Code: newCodes.Codes[1]}, &throttled)
+	if code != http.StatusTooManyRequests || throttled.RetryAfter <= 0 {
+		t.Errorf("Expected the code to wait, got status %d and %+v", code, throttled)
+	}
+	code = s.do("GET", "/users/two_factor", bobToken, nil, &status)
+	if code != http.StatusOK || !status.Enabled || status.RecoveryCodesLeft != 9 {
+		t.Errorf("Expected the second factor to stay, got status %d and %+v", code, status)
+	}
+	// the password waits too
+	code = s.do("POST", "/login", "", models.LoginInfos{Username: "bob", Password: "Bob12345@"}, nil)
+	if code != http.StatusTooManyRequests {
+		t.Errorf("Expected the login of bob to be throttled, got status %d", code)
+	}
+
+	var page models.AuditPage
+	code = s.do("GET", "/moderation/audit?action=role.edit", aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Entries) != 2 || page.Entries[0].TargetType != "role" {
+		t.Errorf("Expected the role edits to be audited, got status %d and %+v", code, page.Entries)
+	}
+}
+
+func TestE2ELoginThrottle(t *testing.T) {
+	runE2E(t, testLoginThrottle)
+}
+
+func testLoginThrottle(s *e2eServer) {
+	t := s.t
+	s.createUser("alice", "Alice123@")
+	s.createUser("bob", "Bob12345@")
+	type loginError struct {
+		Message    string `json:"message"`
+		Error      string `json:"error"`
+		RetryAfter int    `json:"retry_after"`
+	}
+
+	// a wrong password and an unknown username get the same answer
+	var wrongPassword, unknownUser loginError
+	code := s.doFrom("10.0.0.1", "POST", "/login", "", models.LoginInfos{Username: "alice", Password: "wrong"}, &wrongPassword)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected a wrong password to be refused, got status %d", code)
+	}
+	code = s.doFrom("10.0.0.1", "POST", "/login", "", models.LoginInfos{Username: "nobody", Password: "wrong"}, &unknownUser)
+	if code != http.StatusBadRequest || unknownUser != wrongPassword {
+		t.Errorf("Expected an unknown username to get %+v, got status %d and %+v", wrongPassword, code, unknownUser)
+	}
+
+	// the failures of alice add up whatever the address, the username then waits
+	for i := range 4 {
+		s.doFrom(fmt.Sprintf("10.0.1.%d", i), "POST", "/login", "", models.LoginInfos{Username: "Alice", Password: "wrong"}, nil)
+	}
+	var throttled loginError
+	code = s.doFrom("10.0.2.1", "POST", "/login", "", models.LoginInfos{Username: "alice", Password: "Alice123@"}, &throttled)
+	if code != http.StatusTooManyRequests || throttled.RetryAfter <= 0 {
+		t.Errorf("Expected alice to wait, got status %d and %+v", code, throttled)
+	}
+	var tokens models.AuthTokens
+	code = s.doFrom("10.0.1.0", "POST", "/login", "", models.LoginInfos{Username: "bob", Password: "Bob12345@"}, &tokens)
+	if code != http.StatusOK || tokens.AccessToken == "" {
+		t.Errorf("Expected bob not to wait for alice, got status %d", code)
+	}
+
+	// an address trying many usernames waits, whichever username it tries next
+	for i := range 20 {
+		username := fmt.Sprintf("user%d", i%5)
+		if i < 5 {
+			s.createUser(username, "User1234@")
+		}
+		s.doFrom("10.0.3.1", "POST", "/login", "", models.LoginInfos{Username: username, Password: "wrong"}, nil)
+	}
+	code = s.doFrom("10.0.3.1", "POST", "/login", "", models.LoginInfos{Username: "bob", Password: "Bob12345@"}, nil)
+	if code != http.StatusTooManyRequests {
+		t.Errorf("Expected the address to wait, got status %d", code)
+	}
+	code = s.doFrom("10.0.3.2", "POST", "/login", "", models.LoginInfos{Username: "user0", Password: "User1234@"}, nil)
+	if code != http.StatusOK {
+		t.Errorf("Expected user0 to log in from another address, got status %d", code)
+	}
+}
+
+// the SQL stores keep the dates to the second, the first delay of the throttle could already be over:
+// the memory store checks the logins are counted in order
+func TestE2EConcurrentLogins(t *testing.T) {
+	testConcurrentLogins(newE2EServer(t, memory.New()))
+}
+
+func testConcurrentLogins(s *e2eServer) {
+	t := s.t
+	s.createUser("alice", "Alice123@")
+
+	// logins sent at the same time each count the ones before them, only the free failures check the password
+	const attempts = 12
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- s.doFrom(fmt.Sprintf("10.0.4.%d", i), "POST", "/login", "", models.LoginInfos{Username: "alice", Password: "wrong"}, nil)
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	refused, throttled := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusBadRequest:
+			refused++
+		case http.StatusTooManyRequests:
+			throttled++
+		default:
+			t.Errorf("Expected the login to be refused or throttled, got status %d", code)
+		}
+	}
+	if refused != 5 || throttled != attempts-5 {
+		t.Errorf("Expected 5 passwords to be checked and the other logins to wait, got %d checked and %d waiting", refused, throttled)
+	}
+
+	// the logins which waited are not failures, alice still has to wait for the checked ones
+	code := s.doFrom("10.0.4.100", "POST", "/login", "", models.LoginInfos{Username: "alice", Password: "Alice123@"}, nil)
+	if code != http.StatusTooManyRequests {
+		t.Errorf("Expected alice to wait, got status %d", code)
+	}
+	failures, err := s.store.GetLoginFailuresByUsername("alice", time.Now().Add(-time.Hour), math.MaxInt32)
+	if err != nil || failures.Count != 5 {
+		t.Errorf("Expected 5 failures to be recorded, got %+v and %v", failures, err)
+	}
+}
+
+func TestE2ELoginHistory(t *testing.T) {
+	previous := mailer.GetMailer()
+	defer mailer.SetMailer(previous)
+	runE2E(t, testLoginHistory)
+}
+
+func testLoginHistory(s *e2eServer) {
+	t := s.t
+	sent := &e2eMailer{}
+	mailer.SetMailer(sent)
+	aliceId, aliceToken := s.createUser("alice", "Alice123@")
+	daveId, daveToken := s.createUser("dave", "Dave1234@")
+	moderatorRoleId, _ := s.store.GetRoleId("moderator")
+	s.store.GrantRole(daveId, moderatorRoleId, daveId)
+
+	// a failed login alerts nobody, the first login from the address does
+	s.doFrom("10.0.0.9", "POST", "/login", "", models.LoginInfos{Username: "alice", Password: "wrong"}, nil)
+	if len(sent.messages) != 0 {
+		t.Errorf("Expected no email after a failed login, got %+v", sent.messages)
+	}
+	for range 2 {
+		code := s.doFrom("10.0.0.9", "POST", "/login", "", models.LoginInfos{Username: "alice", Password: "Alice123@"}, nil)
+		if code != http.StatusOK {
+			t.Fatalf("Expected alice to log in, got status %d", code)
+		}
+	}
+	if len(sent.messages) != 1 || sent.messages[0].To != "alice@truthful.test" || !strings.Contains(sent.messages[0].Body, "10.0.0.9") {
+		t.Errorf("Expected alice to be emailed once about the new device, got %+v", sent.messages)
+	}
+	var notifications models.NotificationPage
+	code := s.do("GET", "/notifications", aliceToken, nil, &notifications)
+	if code != http.StatusOK || len(notifications.Notifications) != 1 || notifications.Notifications[0].Type != "new_login" {
+		t.Errorf("Expected a new login notification, got status %d and %+v", code, notifications)
+	}
+
+	var page models.LoginHistoryPage
+	code = s.do("GET", "/users/login_history?count=3", aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Logins) != 3 || page.NextCursor == "" {
+		t.Fatalf("Expected a first page of 3 logins, got status %d and %+v", code, page)
+	}
+	if !page.Logins[0].Success || page.Logins[0].IpAddress != "10.0.0.9" || page.Logins[0].Method != models.LoginMethodPassword || page.Logins[2].Success {
+		t.Errorf("Expected the newest logins first, got %+v", page.Logins)
+	}
+	cursor := page.NextCursor
+	page = models.LoginHistoryPage{}
+	code = s.do("GET", "/users/login_history?count=3&cursor="+cursor, aliceToken, nil, &page)
+	if code != http.StatusOK || len(page.Logins) != 1 || !page.Logins[0].Success || page.NextCursor != "" {
+		t.Errorf("Expected the first login of alice last, got status %d and %+v", code, page)
+	}
+
+	// the moderators see the history of any user, the view is audited
+	path := fmt.Sprintf("/moderation/users/%d/login_history", aliceId)
+	code = s.do("GET", path, aliceToken, nil, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected users not to see the login history of others, got status %d", code)
+	}
+	code = s.do("GET", path, daveToken, nil, &page)
+	if code != http.StatusOK || len(page.Logins) != 4 {
+		t.Errorf("Expected the 4 logins of alice, got status %d and %+v", code, page)
+	}
+	code = s.do("GET", "/moderation/users/999/login_history", daveToken, nil, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected an unknown user to be refused, got status %d", code)
+	}
+	entries, _ := s.store.GetAuditEntries(models.AuditFilter{Action: models.AuditLoginHistoryView}, nil, 10)
+	if len(entries) != 1 || entries[0].ModeratorId != daveId || entries[0].TargetId != aliceId {
+		t.Errorf("Expected the view to be audited, got %+v", entries)
+	}
+}
@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"project_truthful/client"
+	"project_truthful/client/token"
+	"project_truthful/events"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval is how often a comment is sent on idle streams so that proxies keep them open
+var heartbeatInterval = 25 * time.Second
+
+// createStreamTicket exchanges the access token for a ticket opening one event stream: browsers can't set headers
+// on an EventSource, and an access token in the URL would end up in the access logs
+func createStreamTicket(c *gin.Context) {
+	log.Printf("Received request to create a stream ticket from ip %s\n", c.ClientIP())
+
+	accessToken, code, err := token.ParseAccessToken(c)
+	if err != nil {
+		log.Printf("Error while parsing token: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while parsing token", "error": err.Error()})
+		return
+	}
+	requesterId, expiresAt, code, err := token.VerifyJWTWithExpiry(accessToken)
+	if err != nil {
+		log.Printf("Error while checking token: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
+		return
+	}
+	code, err = client.CheckUserNotBanned(requesterId)
+	if err != nil {
+		log.Printf("Error while checking ban status of user %d: %s\n", requesterId, err.Error())
+		c.JSON(code, errorResponse("error while checking ban status", err))
+		return
+	}
+
+	ticket, code, err := client.CreateStreamTicket(requesterId, expiresAt)
+	if err != nil {
+		log.Printf("Error while creating stream ticket: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while creating stream ticket", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ticket)
+}
+
+// streamRequester authenticates the stream with its ticket, or with the Authorization header for the clients which can set it.
+// It returns the requester and when the stream ends, which is when their access token expires
+func streamRequester(c *gin.Context) (int, time.Time, int, error) {
+	if ticket, ok := c.GetQuery("ticket"); ok && ticket != "" {
+		return client.UseStreamTicket(ticket)
+	}
+	accessToken, code, err := token.ParseAccessToken(c)
+	if err != nil {
+		return 0, time.Time{}, code, err
+	}
+	return token.VerifyJWTWithExpiry(accessToken)
+}
+
+// streamEvents pushes the events of the requester as server-sent events until the client leaves, the server shuts down,
+// the access token of the stream expires or the requester is banned
+func streamEvents(c *gin.Context) {
+	requesterId, expiresAt, code, err := streamRequester(c)
+	if err != nil {
+		log.Printf("Error while authenticating stream: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while authenticating stream", "error": err.Error()})
+		return
+	}
+	code, err = client.CheckUserNotBanned(requesterId)
+	if err != nil {
+		log.Printf("Error while checking ban status of user %d: %s\n", requesterId, err.Error())
+		c.JSON(code, errorResponse("error while checking ban status", err))
+		return
+	}
+
+	hub := events.GetHub()
+	subscription, err := hub.Subscribe(requesterId)
+	if err != nil {
+		log.Printf("Error while subscribing user %d to events: %s\n", requesterId, err.Error())
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "error while subscribing to events", "error": err.Error()})
+		return
+	}
+	defer hub.Unsubscribe(subscription)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	// the first comment sends the headers right away so that the client knows the stream is open
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event.Data)
+			return true
+		case <-heartbeat.C:
+			// the requester may have been banned since the stream was opened
+			_, err := client.CheckUserNotBanned(requesterId)
+			if err != nil {
+				log.Printf("Closing the event stream of user %d: %s\n", requesterId, err.Error())
+				return false
+			}
+			fmt.Fprint(w, ": heartbeat\n\n")
+			return true
+		case <-expiry.C:
+			// the client opens a new stream with a new ticket
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
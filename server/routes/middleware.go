@@ -1,11 +1,15 @@
 package routes
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"project_truthful/client"
 	"project_truthful/client/database"
 	"project_truthful/client/token"
+	"project_truthful/models"
+	"project_truthful/permissions"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +25,15 @@ func setJSONResponse(c *gin.Context) {
 	c.Next()
 }
 
+// usesOffsetPagination tells if the request pages a list with the deprecated "start" offset instead of a cursor
+func usesOffsetPagination(c *gin.Context) bool {
+	_, ok := c.GetQuery("start")
+	if ok {
+		c.Header("Deprecation", "true")
+	}
+	return ok
+}
+
 func parseAndVerifyAccessToken(c *gin.Context) (int, int, error) {
 	accessToken, code, err := token.ParseAccessToken(c)
 	if err != nil {
@@ -38,13 +51,58 @@ func parseAndVerifyAccessToken(c *gin.Context) (int, int, error) {
 	return requesterId, http.StatusOK, nil
 }
 
-func moderationLogging(moderatorId int, action string, targetId int) error {
-	err := database.LogModerationAction(moderatorId, action, targetId, database.DB)
+// parseAndVerifyActiveUser authenticates the requester like parseAndVerifyAccessToken and rejects banned users
+func parseAndVerifyActiveUser(c *gin.Context) (int, int, error) {
+	requesterId, code, err := parseAndVerifyAccessToken(c)
+	if err != nil {
+		return 0, code, err
+	}
 
+	code, err = client.CheckUserNotBanned(requesterId)
 	if err != nil {
-		log.Printf("Error logging moderation action %s by moderator %d, %v\n", action, moderatorId, err)
+		log.Printf("Error while checking ban status of user %d: %s\n", requesterId, err.Error())
+		c.JSON(code, errorResponse("error while checking ban status", err))
+		return 0, code, err
 	}
-	return err
+	return requesterId, http.StatusOK, nil
+}
+
+// requireActiveUser is the middleware version of parseAndVerifyActiveUser, it stores the requester id for the next handlers
+func requireActiveUser(c *gin.Context) {
+	requesterId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		c.Abort()
+		return
+	}
+	c.Set(permissions.RequesterIdKey, requesterId)
+	c.Next()
+}
+
+// errorResponse adds the details of the ban to the response when the error comes from a banned user,
+// and a code when a question is refused by the inbox settings of its receiver or a content by the filters
+func errorResponse(message string, err error) gin.H {
+	var bannedErr *client.UserBannedError
+	if errors.As(err, &bannedErr) {
+		return gin.H{"message": message, "error": err.Error(), "ban": bannedErr.Ban}
+	}
+	var inboxErr *client.InboxError
+	if errors.As(err, &inboxErr) {
+		return gin.H{"message": message, "error": err.Error(), "code": inboxErr.Code}
+	}
+	var throttledErr *client.LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		return gin.H{"message": message, "error": err.Error(), "retry_after": int(throttledErr.RetryAfter.Seconds())}
+	}
+	var rejectedErr *client.ContentRejectedError
+	if errors.As(err, &rejectedErr) {
+		return gin.H{"message": message, "error": err.Error(), "code": client.ContentRejected}
+	}
+	return gin.H{"message": message, "error": err.Error()}
+}
+
+// auditContext tells the audit log who is doing the request, and from where
+func auditContext(c *gin.Context) models.AuditContext {
+	return models.AuditContext{ModeratorId: c.GetInt(permissions.RequesterIdKey), IpAddress: c.ClientIP(), UserAgent: c.Request.UserAgent()}
 }
 
 // true : rate limit exceeded
@@ -58,7 +116,7 @@ func checkAndUpdateRateLimit(c *gin.Context) {
 	}
 
 	// get latest rate limit
-	rateLimit, err := database.GetRateLimit(c.ClientIP(), database.DB)
+	rateLimit, err := database.GetStore().GetRateLimit(c.ClientIP())
 
 	if err != nil {
 		log.Printf("Error getting rate limit for ip %s, %v\n", c.ClientIP(), err)
@@ -69,7 +127,7 @@ func checkAndUpdateRateLimit(c *gin.Context) {
 
 	// if last request was more than 1 hour ago, reset request count
 	if rateLimit.LastRequestTime.Add(1 * time.Hour).Before(time.Now()) {
-		err = database.ResetRateLimit(c.ClientIP(), database.DB)
+		err = database.GetStore().ResetRateLimit(c.ClientIP())
 		if err != nil {
 			log.Printf("Error resetting rate limit for ip %s, %v\n", c.ClientIP(), err)
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "error resetting rate limit", "error": err.Error()})
@@ -80,7 +138,7 @@ func checkAndUpdateRateLimit(c *gin.Context) {
 		return
 	}
 
-	err = database.IncrementRateLimit(c.ClientIP(), database.DB)
+	err = database.GetStore().IncrementRateLimit(c.ClientIP())
 	if err != nil {
 		log.Printf("Error incrementing rate limit for ip %s, %v\n", c.ClientIP(), err)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "error incrementing rate limit", "error": err.Error()})
@@ -1,13 +1,15 @@
 package routes
 
 import (
-	"fmt"
 	"log"
 	"net/http"
 	"project_truthful/client"
 	"project_truthful/client/basicfuncs"
+	"project_truthful/client/pagination"
 	"project_truthful/client/token"
 	"project_truthful/models"
+	"project_truthful/permissions"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -39,7 +41,7 @@ func register(c *gin.Context) {
 		return
 	}
 
-	id, code, err := client.Register(infos)
+	id, code, err := client.Register(infos, c.ClientIP())
 	if err != nil {
 		log.Printf("Error while creating user: %s\n", err.Error())
 		c.JSON(code, gin.H{
@@ -49,19 +51,20 @@ func register(c *gin.Context) {
 		return
 	}
 	log.Printf("User created with id %d\n", id)
-	token, err := token.GenerateJWT(int(id))
+	tokens, code, err := client.CreateSession(int(id), c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		log.Printf("Error while generating token: %s\n", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(code, gin.H{
 			"message": "error while generating token after registration",
 			"error":   err.Error(),
 		})
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "User created",
-		"id":      id,
-		"token":   token,
+		"message":       "User created",
+		"id":            id,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
@@ -88,353 +91,1005 @@ func login(c *gin.Context) {
 		return
 	}
 
-	token, code, err := client.Login(infos)
+	tokens, code, err := client.Login(infos, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		log.Printf("Error while logging in: %s\n", err.Error())
-		c.JSON(code, gin.H{
-			"message": "error while logging in",
-			"error":   err.Error(),
-		})
+		c.JSON(code, errorResponse("error while logging in", err))
+		return
+	}
+	log.Printf("User %s logged in\n", infos.Username)
+	loginResponse(c, "User logged in", tokens)
+}
+
+// loginResponse answers with the tokens of the new session, or with the challenge to pass when the user enabled a second factor
+func loginResponse(c *gin.Context, message string, tokens models.AuthTokens) {
+	if tokens.ChallengeToken != "" {
+		c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication required", "challenge_token": tokens.ChallengeToken})
 		return
 	}
-	log.Printf("User logged in with token %s\n", token)
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User logged in",
-		"token":   token,
+		"message":       message,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
-func refreshToken(c *gin.Context) {
-	log.Printf("Received request to refresh token from ip %s\n", c.ClientIP())
+func loginTwoFactor(c *gin.Context) {
+	log.Printf("Received request to login with a second factor from ip %s\n", c.ClientIP())
 
-	accessToken, code, err := token.ParseAccessToken(c)
+	var infos models.LoginTwoFactorInfos
+	err := c.ShouldBindJSON(&infos)
 	if err != nil {
-		log.Printf("Error while parsing token: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while parsing token", "error": err.Error()})
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
 		return
 	}
 
-	newToken, code, err := token.RefreshJWT(accessToken)
+	tokens, code, err := client.LoginTwoFactor(infos, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
-		log.Printf("Error while checking token: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
+		log.Printf("Error while logging in with a second factor: %s\n", err.Error())
+		c.JSON(code, errorResponse("error while logging in", err))
 		return
 	}
-	log.Printf("Token refreshed")
-	c.JSON(code, gin.H{"message": "Token refreshed", "token": newToken})
+	loginResponse(c, "User logged in", tokens)
 }
 
-func getUserProfile(c *gin.Context) {
-	log.Printf("Received request to get user from ip %s\n", c.ClientIP())
-
-	accessToken, _, err := token.ParseAccessToken(c)
-	requesterId := 0
-	var code int
-	if err == nil {
-		requesterId, code, err = token.VerifyJWT(accessToken)
-		if err != nil {
-			log.Printf("Error while checking token: %s\n", err.Error())
-			c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
-			return
-		}
-	}
-
-	username := c.Param("user")
-
-	// parses 2 query parameters, "count" and "start"
-	countStr := c.Query("count")
-	startStr := c.Query("start")
+func refreshToken(c *gin.Context) {
+	log.Printf("Received request to refresh token from ip %s\n", c.ClientIP())
 
-	count, err := basicfuncs.ConvertQueryParameterToInt(countStr, 10)
-	if err != nil {
-		log.Printf("Error while parsing query parameter: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "error while parsing query parameter",
-			"error":   err.Error(),
-		})
-		return
-	}
-	start, err := basicfuncs.ConvertQueryParameterToInt(startStr, 0)
+	var infos models.RefreshTokenInfos
+	err := c.ShouldBindJSON(&infos)
 	if err != nil {
-		log.Printf("Error while parsing query parameter: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "error while parsing query parameter",
-			"error":   err.Error(),
-		})
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
 		return
 	}
-
-	if count < 0 || start < 0 {
-		log.Printf("Error while parsing query parameter: negative values\n")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "error while parsing query parameter",
-			"error":   "negative values",
-		})
+	if infos.RefreshToken == "" {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
 		return
 	}
 
-	if count > 30 {
-		count = 30
-	}
-
-	user, code, err := client.GetUserProfile(username, requesterId, count, start)
+	tokens, code, err := client.RefreshSession(infos.RefreshToken, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
-		log.Printf("Error while getting user: %s\n", err.Error())
-		c.JSON(code, gin.H{
-			"message": "error while getting user",
-			"error":   err.Error(),
-		})
+		log.Printf("Error while refreshing token: %s\n", err.Error())
+		c.JSON(code, errorResponse("error while refreshing token", err))
 		return
 	}
-	log.Printf("User %s found\n", username)
-	c.JSON(http.StatusOK, user)
+	log.Printf("Token refreshed")
+	c.JSON(http.StatusOK, gin.H{"message": "Token refreshed", "token": tokens.AccessToken, "refresh_token": tokens.RefreshToken})
 }
 
-func followUser(c *gin.Context) {
-	log.Printf("Received request to follow user from ip %s\n", c.ClientIP())
+func logout(c *gin.Context) {
+	log.Printf("Received request to logout from ip %s\n", c.ClientIP())
 
 	requesterId, _, err := parseAndVerifyAccessToken(c)
 	if err != nil {
 		return
 	}
 
-	var infos models.FollowUserInfos
-	if err := c.ShouldBindJSON(&infos); err != nil {
+	var infos models.RefreshTokenInfos
+	err = c.ShouldBindJSON(&infos)
+	if err != nil {
 		log.Printf("Error while parsing request body: %s\n", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
 		return
-	} else if infos.UserId == 0 {
+	}
+	if infos.RefreshToken == "" {
 		log.Printf("Error while parsing request body: missing fields\n")
 		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
 		return
 	}
 
-	var message string
-	var code int
-	if infos.Follow {
-		code, err = client.FollowUser(requesterId, infos.UserId)
-		message = "User followed"
-	} else {
-		code, err = client.UnfollowUser(requesterId, infos.UserId)
-		message = "User unfollowed"
-	}
+	code, err := client.Logout(requesterId, infos.RefreshToken)
 	if err != nil {
-		log.Printf("Error while following user: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while following user", "error": err.Error()})
+		log.Printf("Error while logging out: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while logging out", "error": err.Error()})
 		return
 	}
-
-	log.Printf("User %d followed user %d\n", requesterId, infos.UserId)
-	c.JSON(http.StatusOK, gin.H{"message": message})
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out"})
 }
 
-func askQuestion(c *gin.Context) {
-	log.Printf("Received request to ask question from ip %s\n", c.ClientIP())
-	accessToken, _, err := token.ParseAccessToken(c)
-	requesterId := 0
-	var code int
-	if err == nil {
-		requesterId, code, err = token.VerifyJWT(accessToken)
-		if err != nil {
-			log.Printf("Error while checking token: %s\n", err.Error())
-			c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
-			return
-		}
-	}
+func forgotPassword(c *gin.Context) {
+	log.Printf("Received request to send a password reset email from ip %s\n", c.ClientIP())
 
-	var infos models.AskQuestionInfos
-	if err = c.ShouldBindJSON(&infos); err != nil {
+	var infos models.ForgotPasswordInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
 		log.Printf("Error while parsing request body: %s\n", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
 		return
 	}
 
-	id, code, err := client.AskQuestion(infos.QuestionText, requesterId, c.ClientIP(), infos.IsAuthorAnonymous, infos.UserId)
+	code, err := client.ForgotPassword(infos.Email, c.ClientIP())
 	if err != nil {
-		log.Printf("Error while asking question: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while asking question", "error": err.Error()})
+		log.Printf("Error while sending password reset email: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while sending password reset email", "error": err.Error()})
 		return
 	}
-
-	log.Printf("Question asked with id %d\n", id)
-	c.JSON(http.StatusCreated, gin.H{"message": "Question asked", "id": id})
+	c.JSON(http.StatusOK, gin.H{"message": "if an account uses this email address, a password reset link was sent to it"})
 }
 
-func getQuestions(c *gin.Context) {
-	log.Printf("Received request to get questions from ip %s\n", c.ClientIP())
-
-	//get the "count" parameter from query parameters
-	countStr := c.Query("count")
-	startStr := c.Query("start")
+func resetPassword(c *gin.Context) {
+	log.Printf("Received request to reset password from ip %s\n", c.ClientIP())
 
-	count, err := basicfuncs.ConvertQueryParameterToInt(countStr, 10)
+	var infos models.ResetPasswordInfos
+	err := c.ShouldBindJSON(&infos)
 	if err != nil {
-		log.Printf("Error while parsing count: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "invalid count",
-			"error":   err.Error(),
-		})
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	}
+	if infos.Token == "" || infos.Password == "" {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
 		return
 	}
 
-	start, err := basicfuncs.ConvertQueryParameterToInt(startStr, 0)
+	code, err := client.ResetPassword(infos.Token, infos.Password, c.ClientIP())
 	if err != nil {
-		log.Printf("Error while parsing count: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "invalid count",
-			"error":   err.Error(),
-		})
+		log.Printf("Error while resetting password: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while resetting password", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "password reset"})
+}
 
-	userId, _, err := parseAndVerifyAccessToken(c)
+func verifyEmail(c *gin.Context) {
+	log.Printf("Received request to verify email from ip %s\n", c.ClientIP())
+
+	var infos models.VerifyEmailInfos
+	err := c.ShouldBindJSON(&infos)
 	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
 		return
 	}
 
-	questions, code, err := client.GetQuestions(userId, start, count)
+	code, err := client.VerifyEmail(infos.Token)
 	if err != nil {
-		log.Printf("Error while getting questions: %s\n", err.Error())
-		c.JSON(code, gin.H{
-			"message": "error while getting questions",
-			"error":   err.Error(),
-		})
+		log.Printf("Error while verifying email: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while verifying email", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
 
-	if len(questions) == 0 {
-		c.JSON(http.StatusOK, gin.H{})
+func getEmailStatus(c *gin.Context) {
+	log.Printf("Received request to get email status from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	status, code, err := client.GetEmailStatus(requesterId)
+	if err != nil {
+		log.Printf("Error while getting email status: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting email status", "error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, questions)
+	c.JSON(http.StatusOK, status)
 }
 
-func answerQuestion(c *gin.Context) {
-	log.Printf("Received request to answer question from ip %s\n", c.ClientIP())
-	requesterId, _, err := parseAndVerifyAccessToken(c)
+func resendEmailVerification(c *gin.Context) {
+	log.Printf("Received request to resend email verification from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	code, err := client.ResendEmailVerification(requesterId)
 	if err != nil {
+		log.Printf("Error while resending email verification: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while resending email verification", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "verification email sent"})
+}
 
-	var infos models.AnswerQuestionInfos
-	err = c.ShouldBindJSON(&infos)
+func getTwoFactorStatus(c *gin.Context) {
+	log.Printf("Received request to get two-factor status from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	status, code, err := client.GetTwoFactorStatus(requesterId)
 	if err != nil {
-		log.Printf("Error while parsing request body: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		log.Printf("Error while getting two-factor status: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting two-factor status", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, status)
+}
 
-	id, code, err := client.AnswerQuestion(requesterId, infos.QuestionId, infos.AnswerText, c.ClientIP())
+func beginTotpEnrollment(c *gin.Context) {
+	log.Printf("Received request to enroll two-factor authentication from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	enrollment, code, err := client.BeginTotpEnrollment(requesterId)
 	if err != nil {
-		log.Printf("Error while answering question: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while answering question", "error": err.Error()})
+		log.Printf("Error while enrolling two-factor authentication: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while enrolling two-factor authentication", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, enrollment)
+}
 
-	log.Printf("Question answered with id %d\n", id)
-	c.JSON(http.StatusCreated, gin.H{"message": "question answered", "id": id})
+// bindTwoFactorCode parses the code of a request changing the second factor, it answers the request when the body is invalid
+func bindTwoFactorCode(c *gin.Context) (string, bool) {
+	var infos models.TwoFactorCodeInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return "", false
+	}
+	if infos.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return "", false
+	}
+	return infos.Code, true
 }
 
-func likeAnswer(c *gin.Context) {
-	log.Printf("Received request to like answer from ip %s\n", c.ClientIP())
+func confirmTotpEnrollment(c *gin.Context) {
+	log.Printf("Received request to confirm two-factor authentication from ip %s\n", c.ClientIP())
 
-	requesterId, _, err := parseAndVerifyAccessToken(c)
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	recoveryCodes, status, err := client.ConfirmTotpEnrollment(requesterId, code, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
+		log.Printf("Error while confirming two-factor authentication: %s\n", err.Error())
+		c.JSON(status, errorResponse("error while confirming two-factor authentication", err))
 		return
 	}
+	c.JSON(http.StatusOK, recoveryCodes)
+}
 
-	var infos models.LikeAnswerInfos
-	err = c.ShouldBindJSON(&infos)
+func disableTotp(c *gin.Context) {
+	log.Printf("Received request to disable two-factor authentication from ip %s\n", c.ClientIP())
+
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	status, err := client.DisableTotp(requesterId, code, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
-		log.Printf("Error while parsing request body: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		log.Printf("Error while disabling two-factor authentication: %s\n", err.Error())
+		c.JSON(status, errorResponse("error while disabling two-factor authentication", err))
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
 
-	if infos.Like {
-		code, err := client.LikeAnswer(requesterId, infos.AnswerId)
-		if err != nil {
-			log.Printf("Error while liking answer: %s\n", err.Error())
-			c.JSON(code, gin.H{"message": "error while liking answer", "error": err.Error()})
-			return
-		}
-	} else {
-		code, err := client.UnlikeAnswer(requesterId, infos.AnswerId)
-		if err != nil {
-			log.Printf("Error while unliking answer: %s\n", err.Error())
-			c.JSON(code, gin.H{"message": "error while unliking answer", "error": err.Error()})
-			return
-		}
-	}
+func regenerateRecoveryCodes(c *gin.Context) {
+	log.Printf("Received request to regenerate recovery codes from ip %s\n", c.ClientIP())
 
-	if infos.Like {
-		c.JSON(http.StatusCreated, gin.H{"message": "answer liked"})
-	} else {
-		c.JSON(http.StatusOK, gin.H{"message": "answer unliked"})
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	recoveryCodes, status, err := client.RegenerateRecoveryCodes(requesterId, code, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		log.Printf("Error while regenerating recovery codes: %s\n", err.Error())
+		c.JSON(status, errorResponse("error while regenerating recovery codes", err))
+		return
 	}
+	c.JSON(http.StatusOK, recoveryCodes)
 }
 
-func deleteAnswer(c *gin.Context) {
-	log.Printf("Received request to delete answer from ip %s\n", c.ClientIP())
+func getLoginHistory(c *gin.Context) {
+	log.Printf("Received request to get login history from ip %s\n", c.ClientIP())
 
-	requesterId, _, err := parseAndVerifyAccessToken(c)
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
 	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
 		return
 	}
 
-	var infos models.DeleteAnswerInfos
-	err = c.ShouldBindJSON(&infos)
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	page, code, err := client.GetLoginHistory(requesterId, c.Query("cursor"), count)
 	if err != nil {
-		log.Printf("Error while parsing request body: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		log.Printf("Error while getting login history: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting login history", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, page)
+}
 
-	code, err := client.MarkAnswerAsDeleted(requesterId, infos.AnswerId)
+func getSessions(c *gin.Context) {
+	log.Printf("Received request to get sessions from ip %s\n", c.ClientIP())
+
+	requesterId, _, err := parseAndVerifyAccessToken(c)
 	if err != nil {
-		log.Printf("Error while deleting answer: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while deleting answer", "error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "answer deleted"})
+	sessions, code, err := client.GetSessions(requesterId)
+	if err != nil {
+		log.Printf("Error while getting sessions: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting sessions", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
 }
 
-func deleteQuestion(c *gin.Context) {
-	log.Printf("Received request to delete question from ip %s\n", c.ClientIP())
+func revokeSession(c *gin.Context) {
+	log.Printf("Received request to revoke session from ip %s\n", c.ClientIP())
 
 	requesterId, _, err := parseAndVerifyAccessToken(c)
 	if err != nil {
 		return
 	}
 
-	var infos models.DeleteQuestionInfos
+	var infos models.RevokeSessionInfos
 	err = c.ShouldBindJSON(&infos)
 	if err != nil {
 		log.Printf("Error while parsing request body: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	}
+	if infos.SessionId == "" {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
 		return
 	}
 
-	code, err := client.MarkQuestionAsDeleted(requesterId, infos.QuestionId)
+	code, err := client.RevokeSession(requesterId, infos.SessionId)
 	if err != nil {
-		log.Printf("Error while deleting question: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while deleting question", "error": err.Error()})
+		log.Printf("Error while revoking session: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while revoking session", "error": err.Error()})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "question deleted"})
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
 }
 
-func updateUser(c *gin.Context) {
-	// NOTE : In the future, change this function to a lot of smaller functions with PATCH requests
-	log.Printf("Received request to update user from ip %s\n", c.ClientIP())
+func revokeAllSessions(c *gin.Context) {
+	log.Printf("Received request to revoke all sessions from ip %s\n", c.ClientIP())
 
 	requesterId, _, err := parseAndVerifyAccessToken(c)
 	if err != nil {
 		return
 	}
 
+	code, err := client.RevokeAllSessions(requesterId)
+	if err != nil {
+		log.Printf("Error while revoking sessions: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while revoking sessions", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "sessions revoked"})
+}
+
+func getUserProfile(c *gin.Context) {
+	log.Printf("Received request to get user from ip %s\n", c.ClientIP())
+
+	accessToken, _, err := token.ParseAccessToken(c)
+	requesterId := 0
+	var code int
+	if err == nil {
+		requesterId, code, err = token.VerifyJWT(accessToken)
+		if err != nil {
+			log.Printf("Error while checking token: %s\n", err.Error())
+			c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
+			return
+		}
+	}
+
+	username := c.Param("user")
+
+	// parses 2 query parameters, "count" and "start"
+	countStr := c.Query("count")
+	startStr := c.Query("start")
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(countStr, 10)
+	if err != nil {
+		log.Printf("Error while parsing query parameter: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "error while parsing query parameter",
+			"error":   err.Error(),
+		})
+		return
+	}
+	start, err := basicfuncs.ConvertQueryParameterToInt(startStr, 0)
+	if err != nil {
+		log.Printf("Error while parsing query parameter: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "error while parsing query parameter",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if count < 0 || start < 0 {
+		log.Printf("Error while parsing query parameter: negative values\n")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "error while parsing query parameter",
+			"error":   "negative values",
+		})
+		return
+	}
+
+	if count > 30 {
+		count = 30
+	}
+
+	var user models.UserProfileInfos
+	if usesOffsetPagination(c) {
+		user, code, err = client.GetUserProfile(username, requesterId, count, start)
+	} else {
+		user, code, err = client.GetUserProfilePage(username, requesterId, c.Query("cursor"), count)
+	}
+	if err != nil {
+		log.Printf("Error while getting user: %s\n", err.Error())
+		c.JSON(code, gin.H{
+			"message": "error while getting user",
+			"error":   err.Error(),
+		})
+		return
+	}
+	log.Printf("User %s found\n", username)
+	c.JSON(http.StatusOK, user)
+}
+
+func followUser(c *gin.Context) {
+	log.Printf("Received request to follow user from ip %s\n", c.ClientIP())
+
+	requesterId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		return
+	}
+
+	var infos models.FollowUserInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.UserId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	var message string
+	var code int
+	if infos.Follow {
+		code, err = client.FollowUser(requesterId, infos.UserId)
+		message = "User followed"
+	} else {
+		code, err = client.UnfollowUser(requesterId, infos.UserId)
+		message = "User unfollowed"
+	}
+	if err != nil {
+		log.Printf("Error while following user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while following user", "error": err.Error()})
+		return
+	}
+
+	log.Printf("User %d followed user %d\n", requesterId, infos.UserId)
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func blockUser(c *gin.Context) {
+	log.Printf("Received request to block user from ip %s\n", c.ClientIP())
+
+	var infos models.BlockUserInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.UserId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	var message string
+	var code int
+	var err error
+	if infos.Block {
+		code, err = client.BlockUser(requesterId, infos.UserId)
+		message = "User blocked"
+	} else {
+		code, err = client.UnblockUser(requesterId, infos.UserId)
+		message = "User unblocked"
+	}
+	if err != nil {
+		log.Printf("Error while blocking user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while blocking user", "error": err.Error()})
+		return
+	}
+
+	log.Printf("User %d set block of user %d to %t\n", requesterId, infos.UserId, infos.Block)
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func muteUser(c *gin.Context) {
+	log.Printf("Received request to mute user from ip %s\n", c.ClientIP())
+
+	var infos models.MuteUserInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.UserId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	var message string
+	var code int
+	var err error
+	if infos.Mute {
+		code, err = client.MuteUser(requesterId, infos.UserId)
+		message = "User muted"
+	} else {
+		code, err = client.UnmuteUser(requesterId, infos.UserId)
+		message = "User unmuted"
+	}
+	if err != nil {
+		log.Printf("Error while muting user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while muting user", "error": err.Error()})
+		return
+	}
+
+	log.Printf("User %d set mute of user %d to %t\n", requesterId, infos.UserId, infos.Mute)
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func getBlockedUsers(c *gin.Context) {
+	log.Printf("Received request to get blocked users from ip %s\n", c.ClientIP())
+	getRelatedUsers(c, client.GetBlockedUsers)
+}
+
+func getMutedUsers(c *gin.Context) {
+	log.Printf("Received request to get muted users from ip %s\n", c.ClientIP())
+	getRelatedUsers(c, client.GetMutedUsers)
+}
+
+func getUserSettings(c *gin.Context) {
+	log.Printf("Received request to get user settings from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	settings, code, err := client.GetUserSettings(requesterId)
+	if err != nil {
+		log.Printf("Error while getting user settings: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting user settings", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func updateUserSettings(c *gin.Context) {
+	log.Printf("Received request to update user settings from ip %s\n", c.ClientIP())
+
+	var infos models.UpdateUserSettingsInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	settings, code, err := client.UpdateUserSettings(requesterId, infos)
+	if err != nil {
+		log.Printf("Error while updating user settings: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while updating user settings", "error": err.Error()})
+		return
+	}
+
+	log.Printf("User %d updated their settings\n", requesterId)
+	c.JSON(http.StatusOK, settings)
+}
+
+func getMutedWords(c *gin.Context) {
+	log.Printf("Received request to get muted words from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	words, code, err := client.GetMutedWords(requesterId)
+	if err != nil {
+		log.Printf("Error while getting muted words: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting muted words", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"words": words})
+}
+
+func muteWord(c *gin.Context) {
+	log.Printf("Received request to mute word from ip %s\n", c.ClientIP())
+
+	var infos models.MuteWordInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	var message string
+	var code int
+	var err error
+	if infos.Mute {
+		code, err = client.MuteWord(requesterId, infos.Word)
+		message = "Word muted"
+	} else {
+		code, err = client.UnmuteWord(requesterId, infos.Word)
+		message = "Word unmuted"
+	}
+	if err != nil {
+		log.Printf("Error while muting word: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while muting word", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// getRelatedUsers answers with a page of the users blocked or muted by the requester
+func getRelatedUsers(c *gin.Context, getPage func(userId int, cursor string, count int) (models.RelatedUserPage, int, error)) {
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+
+	page, code, err := getPage(c.GetInt(permissions.RequesterIdKey), c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting users: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting users", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func getFollowers(c *gin.Context) {
+	log.Printf("Received request to get followers from ip %s\n", c.ClientIP())
+	getFollowList(c, client.GetFollowers)
+}
+
+func getFollowing(c *gin.Context) {
+	log.Printf("Received request to get following from ip %s\n", c.ClientIP())
+	getFollowList(c, client.GetFollowing)
+}
+
+// getFollowList responds with a page of the list of the user, the requester is optional
+func getFollowList(c *gin.Context, getList func(username string, requestingUser int, cursor string, count int) (models.FollowPage, int, error)) {
+	requesterId := 0
+	accessToken, _, err := token.ParseAccessToken(c)
+	if err == nil {
+		var code int
+		requesterId, code, err = token.VerifyJWT(accessToken)
+		if err != nil {
+			log.Printf("Error while checking token: %s\n", err.Error())
+			c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
+			return
+		}
+	}
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+
+	page, code, err := getList(c.Param("user"), requesterId, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting follow list: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting follow list", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func askQuestion(c *gin.Context) {
+	log.Printf("Received request to ask question from ip %s\n", c.ClientIP())
+	accessToken, _, err := token.ParseAccessToken(c)
+	requesterId := 0
+	var code int
+	if err == nil {
+		requesterId, code, err = token.VerifyJWT(accessToken)
+		if err != nil {
+			log.Printf("Error while checking token: %s\n", err.Error())
+			c.JSON(code, gin.H{"message": "error while checking token", "error": err.Error()})
+			return
+		}
+		code, err = client.CheckUserNotBanned(requesterId)
+		if err != nil {
+			log.Printf("Error while checking ban status of user %d: %s\n", requesterId, err.Error())
+			c.JSON(code, errorResponse("error while checking ban status", err))
+			return
+		}
+	}
+
+	var infos models.AskQuestionInfos
+	if err = c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	}
+
+	id, code, err := client.AskQuestion(infos.QuestionText, requesterId, c.ClientIP(), infos.IsAuthorAnonymous, infos.UserId, infos.CaptchaToken)
+	if err != nil {
+		log.Printf("Error while asking question: %s\n", err.Error())
+		c.JSON(code, errorResponse("error while asking question", err))
+		return
+	}
+	if code == http.StatusAccepted {
+		log.Printf("Question to user %d held for review\n", infos.UserId)
+		c.JSON(http.StatusAccepted, gin.H{"message": "Question held for review"})
+		return
+	}
+
+	log.Printf("Question asked with id %d\n", id)
+	c.JSON(http.StatusCreated, gin.H{"message": "Question asked", "id": id})
+}
+
+func getQuestions(c *gin.Context) {
+	log.Printf("Received request to get questions from ip %s\n", c.ClientIP())
+
+	//get the "count" parameter from query parameters
+	countStr := c.Query("count")
+	startStr := c.Query("start")
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(countStr, 10)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid count",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	start, err := basicfuncs.ConvertQueryParameterToInt(startStr, 0)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid count",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	userId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		return
+	}
+
+	if !usesOffsetPagination(c) {
+		page, code, err := client.GetQuestionsPage(userId, c.Query("cursor"), count)
+		if err != nil {
+			log.Printf("Error while getting questions: %s\n", err.Error())
+			c.JSON(code, gin.H{
+				"message": "error while getting questions",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
+	questions, code, err := client.GetQuestions(userId, start, count)
+	if err != nil {
+		log.Printf("Error while getting questions: %s\n", err.Error())
+		c.JSON(code, gin.H{
+			"message": "error while getting questions",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if len(questions) == 0 {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+	c.JSON(http.StatusOK, questions)
+}
+
+func answerQuestion(c *gin.Context) {
+	log.Printf("Received request to answer question from ip %s\n", c.ClientIP())
+	requesterId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		return
+	}
+
+	var infos models.AnswerQuestionInfos
+	err = c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	id, code, err := client.AnswerQuestion(requesterId, infos.QuestionId, infos.AnswerText, c.ClientIP())
+	if err != nil {
+		log.Printf("Error while answering question: %s\n", err.Error())
+		c.JSON(code, errorResponse("error while answering question", err))
+		return
+	}
+	if code == http.StatusAccepted {
+		log.Printf("Answer to question %d held for review\n", infos.QuestionId)
+		c.JSON(http.StatusAccepted, gin.H{"message": "answer held for review"})
+		return
+	}
+
+	log.Printf("Question answered with id %d\n", id)
+	c.JSON(http.StatusCreated, gin.H{"message": "question answered", "id": id})
+}
+
+func likeAnswer(c *gin.Context) {
+	log.Printf("Received request to like answer from ip %s\n", c.ClientIP())
+
+	requesterId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		return
+	}
+
+	var infos models.LikeAnswerInfos
+	err = c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	if infos.Like {
+		code, err := client.LikeAnswer(requesterId, infos.AnswerId)
+		if err != nil {
+			log.Printf("Error while liking answer: %s\n", err.Error())
+			c.JSON(code, gin.H{"message": "error while liking answer", "error": err.Error()})
+			return
+		}
+	} else {
+		code, err := client.UnlikeAnswer(requesterId, infos.AnswerId)
+		if err != nil {
+			log.Printf("Error while unliking answer: %s\n", err.Error())
+			c.JSON(code, gin.H{"message": "error while unliking answer", "error": err.Error()})
+			return
+		}
+	}
+
+	if infos.Like {
+		c.JSON(http.StatusCreated, gin.H{"message": "answer liked"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "answer unliked"})
+	}
+}
+
+func deleteAnswer(c *gin.Context) {
+	log.Printf("Received request to delete answer from ip %s\n", c.ClientIP())
+
+	requesterId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		return
+	}
+
+	var infos models.DeleteAnswerInfos
+	err = c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	code, err := client.MarkAnswerAsDeleted(requesterId, infos.AnswerId)
+	if err != nil {
+		log.Printf("Error while deleting answer: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while deleting answer", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "answer deleted"})
+}
+
+func deleteQuestion(c *gin.Context) {
+	log.Printf("Received request to delete question from ip %s\n", c.ClientIP())
+
+	requesterId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		return
+	}
+
+	var infos models.DeleteQuestionInfos
+	err = c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	code, err := client.MarkQuestionAsDeleted(requesterId, infos.QuestionId)
+	if err != nil {
+		log.Printf("Error while deleting question: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while deleting question", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "question deleted"})
+}
+
+func blockAsker(c *gin.Context) {
+	log.Printf("Received request to block asker from ip %s\n", c.ClientIP())
+
+	var infos models.BlockAskerInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.QuestionId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	id, code, err := client.BlockAsker(requesterId, infos.QuestionId, infos.Duration)
+	if err != nil {
+		log.Printf("Error while blocking asker: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while blocking asker", "error": err.Error()})
+		return
+	}
+
+	log.Printf("User %d blocked the asker of question %d\n", requesterId, infos.QuestionId)
+	c.JSON(http.StatusCreated, gin.H{"message": "Asker blocked", "id": id})
+}
+
+func unblockAsker(c *gin.Context) {
+	log.Printf("Received request to unblock asker from ip %s\n", c.ClientIP())
+
+	var infos models.UnblockAskerInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.BlockId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	code, err := client.UnblockAsker(requesterId, infos.BlockId)
+	if err != nil {
+		log.Printf("Error while unblocking asker: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while unblocking asker", "error": err.Error()})
+		return
+	}
+
+	log.Printf("User %d removed asker block %d\n", requesterId, infos.BlockId)
+	c.JSON(http.StatusOK, gin.H{"message": "Asker unblocked"})
+}
+
+func getBlockedAskers(c *gin.Context) {
+	log.Printf("Received request to get blocked askers from ip %s\n", c.ClientIP())
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+
+	page, code, err := client.GetAskerBlocks(c.GetInt(permissions.RequesterIdKey), c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting blocked askers: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting blocked askers", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func updateUser(c *gin.Context) {
+	// NOTE : In the future, change this function to a lot of smaller functions with PATCH requests
+	log.Printf("Received request to update user from ip %s\n", c.ClientIP())
+
+	requesterId, _, err := parseAndVerifyActiveUser(c)
+	if err != nil {
+		return
+	}
+
 	var infos models.UpdateUserInfos
 	err = c.ShouldBindJSON(&infos)
 	if err != nil {
@@ -443,156 +1098,635 @@ func updateUser(c *gin.Context) {
 		return
 	}
 
-	code, err := client.UpdateUserInformations(requesterId, infos.DisplayName, infos.Email)
+	code, err := client.UpdateUserInformations(requesterId, infos.DisplayName, infos.Email)
+	if err != nil {
+		log.Printf("Error while updating user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while updating user", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user updated"})
+}
+
+func promoteUser(c *gin.Context) {
+	log.Printf("Received request to promote user from ip %s\n", c.ClientIP())
+
+	var infos models.PromoteUserInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	code, err := client.PromoteUser(auditContext(c), infos.UserId, infos.PromoteType)
+	if err != nil {
+		log.Printf("Error while promoting user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while promoting user", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user promoted"})
+}
+
+func demoteUser(c *gin.Context) {
+	log.Printf("Received request to demote user from ip %s\n", c.ClientIP())
+
+	var infos models.DemoteUserInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	code, err := client.DemoteUser(auditContext(c), infos.UserId, infos.Role)
+	if err != nil {
+		log.Printf("Error while demoting user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while demoting user", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user demoted"})
+}
+
+func getRoles(c *gin.Context) {
+	log.Printf("Received request to get roles from ip %s\n", c.ClientIP())
+
+	roles, code, err := client.GetRoles()
+	if err != nil {
+		log.Printf("Error while getting roles: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting roles", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func setRoleTwoFactor(c *gin.Context) {
+	log.Printf("Received request to set the two-factor requirement of a role from ip %s\n", c.ClientIP())
+
+	var infos models.RoleTwoFactorInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	code, err := client.SetRoleTwoFactor(auditContext(c), infos.Role, infos.Required)
+	if err != nil {
+		log.Printf("Error while editing role: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while editing role", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated"})
+}
+
+func getUserRoles(c *gin.Context) {
+	log.Printf("Received request to get user roles from ip %s\n", c.ClientIP())
+
+	roles, code, err := client.GetUserRoles(c.Param("user"))
+	if err != nil {
+		log.Printf("Error while getting user roles: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting user roles", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func moderationGetUserQuestions(c *gin.Context) {
+	log.Printf("Received request to get user questions from ip %s\n", c.ClientIP())
+
+	username := c.Param("user")
+
+	//get the "count" parameter from query parameters
+	countStr := c.Query("count")
+	startStr := c.Query("start")
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(countStr, 10)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid count",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	start, err := basicfuncs.ConvertQueryParameterToInt(startStr, 0)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid count",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	questions, code, err := client.ModerationGetUserQuestions(auditContext(c), username, start, count)
+	if err != nil {
+		log.Printf("Error while getting user questions: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting user questions", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, questions)
+}
+
+func banUser(c *gin.Context) {
+	log.Printf("Received request to ban user from ip %s\n", c.ClientIP())
+
+	var infos models.BanUserInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	banId, code, err := client.BanUser(auditContext(c), infos.UserId, infos.Duration, infos.Reason)
+	if err != nil {
+		log.Printf("Error while banning user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while banning user", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user banned", "ban_id": banId})
+}
+
+func pardonUser(c *gin.Context) {
+	log.Printf("Received request to pardon user from ip %s\n", c.ClientIP())
+
+	var infos models.PardonUserInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	pardonId, code, err := client.PardonUser(auditContext(c), infos.BanId)
+	if err != nil {
+		log.Printf("Error while pardoning user: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while pardoning user", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user pardoned", "pardon_id": pardonId})
+}
+
+func getBans(c *gin.Context) {
+	log.Printf("Received request to get bans from ip %s\n", c.ClientIP())
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+	filter := models.BanFilter{Status: c.Query("status")}
+	filter.UserId, err = basicfuncs.ConvertQueryParameterToInt(c.Query("user_id"), 0)
+	if err != nil {
+		log.Printf("Error while parsing user_id: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user_id", "error": err.Error()})
+		return
+	}
+	filter.AuthorId, err = basicfuncs.ConvertQueryParameterToInt(c.Query("author_id"), 0)
+	if err != nil {
+		log.Printf("Error while parsing author_id: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid author_id", "error": err.Error()})
+		return
+	}
+
+	page, code, err := client.GetBans(filter, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting bans: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting bans", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func getUserBans(c *gin.Context) {
+	log.Printf("Received request to get user bans from ip %s\n", c.ClientIP())
+
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Error while parsing user id: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user id", "error": err.Error()})
+		return
+	}
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+
+	page, code, err := client.GetUserBans(userId, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting user bans: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting user bans", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func editBan(c *gin.Context) {
+	log.Printf("Received request to edit ban from ip %s\n", c.ClientIP())
+
+	var infos models.EditBanInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	ban, code, err := client.EditBan(auditContext(c), infos)
 	if err != nil {
-		log.Printf("Error while updating user: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while updating user", "error": err.Error()})
+		log.Printf("Error while editing ban: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while editing ban", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, ban)
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "user updated"})
+func addAddressBan(c *gin.Context) {
+	log.Printf("Received request to add address ban from ip %s\n", c.ClientIP())
+
+	var infos models.AddAddressBanInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		return
+	}
+
+	banId, code, err := client.AddAddressBan(auditContext(c), infos)
+	if err != nil {
+		log.Printf("Error while adding address ban: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while adding address ban", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "address banned", "ban_id": banId})
 }
 
-func promoteUser(c *gin.Context) {
-	log.Printf("Received request to promote user from ip %s\n", c.ClientIP())
+func getAddressBans(c *gin.Context) {
+	log.Printf("Received request to get address bans from ip %s\n", c.ClientIP())
 
-	requesterId, _, err := parseAndVerifyAccessToken(c)
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
 	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
 		return
 	}
 
-	var infos models.PromoteUserInfos
-	err = c.ShouldBindJSON(&infos)
+	page, code, err := client.GetAddressBans(c.Query("ban_type"), c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting address bans: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting address bans", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func removeAddressBan(c *gin.Context) {
+	log.Printf("Received request to remove address ban from ip %s\n", c.ClientIP())
+
+	var infos models.RemoveAddressBanInfos
+	err := c.ShouldBindJSON(&infos)
 	if err != nil {
 		log.Printf("Error while parsing request body: %s\n", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
 		return
 	}
 
-	code, err := client.PromoteUser(requesterId, infos.UserId, infos.PromoteType)
+	code, err := client.RemoveAddressBan(auditContext(c), infos.BanId)
 	if err != nil {
-		log.Printf("Error while promoting user: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while promoting user", "error": err.Error()})
+		log.Printf("Error while removing address ban: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while removing address ban", "error": err.Error()})
 		return
 	}
 
-	err = moderationLogging(requesterId, "promoteUser", infos.UserId)
+	c.JSON(http.StatusOK, gin.H{"message": "address ban removed"})
+}
+
+func getLinkedAccounts(c *gin.Context) {
+	log.Printf("Received request to get linked accounts from ip %s\n", c.ClientIP())
+
+	userId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		log.Printf("Error while logging moderation action: %s\n", err.Error())
+		log.Printf("Error while parsing user id: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user id", "error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "user promoted"})
+	linked, code, err := client.GetLinkedAccounts(auditContext(c), userId)
+	if err != nil {
+		log.Printf("Error while getting linked accounts: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting linked accounts", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, linked)
 }
 
-func moderationGetUserQuestions(c *gin.Context) {
-	log.Printf("Received request to get user questions from ip %s\n", c.ClientIP())
+func getUserLoginHistory(c *gin.Context) {
+	log.Printf("Received request to get the login history of a user from ip %s\n", c.ClientIP())
 
-	username := c.Param("user")
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Error while parsing user id: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user id", "error": err.Error()})
+		return
+	}
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
 
-	//get the "count" parameter from query parameters
-	countStr := c.Query("count")
-	startStr := c.Query("start")
+	page, code, err := client.GetUserLoginHistory(auditContext(c), userId, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting login history: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting login history", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
 
-	count, err := basicfuncs.ConvertQueryParameterToInt(countStr, 10)
+func getHeldContents(c *gin.Context) {
+	log.Printf("Received request to get held contents from ip %s\n", c.ClientIP())
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
 	if err != nil {
 		log.Printf("Error while parsing count: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "invalid count",
-			"error":   err.Error(),
-		})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
 		return
 	}
 
-	start, err := basicfuncs.ConvertQueryParameterToInt(startStr, 0)
+	page, code, err := client.GetHeldContents(c.Query("cursor"), count)
 	if err != nil {
-		log.Printf("Error while parsing count: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "invalid count",
-			"error":   err.Error(),
-		})
+		log.Printf("Error while getting held contents: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting held contents", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, page)
+}
 
-	requesterId, _, err := parseAndVerifyAccessToken(c)
+func reviewHeldContent(c *gin.Context) {
+	log.Printf("Received request to review held content from ip %s\n", c.ClientIP())
+
+	var infos models.ReviewHeldContentInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.HeldId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	held, code, err := client.ReviewHeldContent(auditContext(c), infos.HeldId, infos.Approve)
 	if err != nil {
+		log.Printf("Error while reviewing held content: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while reviewing held content", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "held content " + held.Status})
+}
+
+func moderationDeleteQuestion(c *gin.Context) {
+	log.Printf("Received request to remove question from ip %s\n", c.ClientIP())
+
+	var infos models.RemoveQuestionInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.QuestionId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
 		return
 	}
 
-	questions, code, err := client.ModerationGetUserQuestions(requesterId, username, start, count)
+	code, err := client.RemoveQuestion(auditContext(c), infos.QuestionId, infos.Reason)
 	if err != nil {
-		log.Printf("Error while getting user questions: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while getting user questions", "error": err.Error()})
+		log.Printf("Error while removing question: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while removing question", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "question removed"})
+}
+
+func moderationRestoreQuestion(c *gin.Context) {
+	log.Printf("Received request to restore question from ip %s\n", c.ClientIP())
+
+	var infos models.RestoreQuestionInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.QuestionId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
 		return
 	}
 
-	err = moderationLogging(requesterId, "moderationGetUserQuestions", 0)
+	code, err := client.RestoreQuestion(auditContext(c), infos.QuestionId)
 	if err != nil {
-		log.Printf("Error while logging moderation action: %s\n", err.Error())
+		log.Printf("Error while restoring question: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while restoring question", "error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, questions)
+	c.JSON(http.StatusOK, gin.H{"message": "question restored"})
 }
 
-func banUser(c *gin.Context) {
-	log.Printf("Received request to ban user from ip %s\n", c.ClientIP())
+func moderationDeleteAnswer(c *gin.Context) {
+	log.Printf("Received request to remove answer from ip %s\n", c.ClientIP())
 
-	requesterId, _, err := parseAndVerifyAccessToken(c)
+	var infos models.RemoveAnswerInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.AnswerId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	code, err := client.RemoveAnswer(auditContext(c), infos.AnswerId, infos.Reason)
 	if err != nil {
+		log.Printf("Error while removing answer: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while removing answer", "error": err.Error()})
 		return
 	}
 
-	var infos models.BanUserInfos
-	err = c.ShouldBindJSON(&infos)
+	c.JSON(http.StatusOK, gin.H{"message": "answer removed"})
+}
+
+func moderationRestoreAnswer(c *gin.Context) {
+	log.Printf("Received request to restore answer from ip %s\n", c.ClientIP())
+
+	var infos models.RestoreAnswerInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.AnswerId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	code, err := client.RestoreAnswer(auditContext(c), infos.AnswerId)
 	if err != nil {
+		log.Printf("Error while restoring answer: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while restoring answer", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "answer restored"})
+}
+
+func report(c *gin.Context) {
+	log.Printf("Received request to report from ip %s\n", c.ClientIP())
+
+	var infos models.ReportInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
 		log.Printf("Error while parsing request body: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	} else if infos.TargetType == "" || infos.TargetId == 0 || infos.Reason == "" {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
 		return
 	}
 
-	banId, code, err := client.BanUser(infos.UserId, requesterId, infos.Duration, infos.Reason)
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	id, code, err := client.Report(requesterId, infos)
 	if err != nil {
-		log.Printf("Error while banning user: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while banning user", "error": err.Error()})
+		log.Printf("Error while reporting: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while reporting", "error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Report sent", "id": id})
+}
+
+func getReportQueue(c *gin.Context) {
+	log.Printf("Received request to get report queue from ip %s\n", c.ClientIP())
 
-	err = moderationLogging(requesterId, "banUser", infos.UserId)
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
 	if err != nil {
-		log.Printf("Error while logging moderation action: %s\n", err.Error())
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "user banned", "ban_id": banId})
+	page, code, err := client.GetReportQueue(c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting report queue: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting report queue", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
-func pardonUser(c *gin.Context) {
-	log.Printf("Received request to pardon user from ip %s\n", c.ClientIP())
+func getTargetReports(c *gin.Context) {
+	log.Printf("Received request to get reports of a target from ip %s\n", c.ClientIP())
 
-	requesterId, _, err := parseAndVerifyAccessToken(c)
+	targetId, err := basicfuncs.ConvertQueryParameterToInt(c.Query("target_id"), 0)
 	if err != nil {
+		log.Printf("Error while parsing target_id: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid target_id", "error": err.Error()})
 		return
 	}
 
-	var infos models.PardonUserInfos
-	err = c.ShouldBindJSON(&infos)
+	reports, code, err := client.GetTargetReports(c.Query("target_type"), targetId)
 	if err != nil {
+		log.Printf("Error while getting reports: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting reports", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+func claimReports(c *gin.Context) {
+	log.Printf("Received request to claim reports from ip %s\n", c.ClientIP())
+
+	var infos models.ClaimReportsInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
 		log.Printf("Error while parsing request body: %s\n", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"message": "error while parsing request body", "error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
 		return
 	}
 
-	pardonId, code, err := client.PardonUser(infos.BanId, requesterId)
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	code, err := client.ClaimReports(requesterId, infos.TargetType, infos.TargetId, infos.Claim)
 	if err != nil {
-		log.Printf("Error while pardoning user: %s\n", err.Error())
-		c.JSON(code, gin.H{"message": "error while pardoning user", "error": err.Error()})
+		log.Printf("Error while claiming reports: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while claiming reports", "error": err.Error()})
+		return
+	}
+	if infos.Claim {
+		c.JSON(http.StatusOK, gin.H{"message": "Reports claimed"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "Reports released"})
+	}
+}
+
+func resolveReports(c *gin.Context) {
+	log.Printf("Received request to resolve reports from ip %s\n", c.ClientIP())
+
+	var infos models.ResolveReportsInfos
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
 		return
 	}
 
-	err = moderationLogging(requesterId, "pardonUser", 0)
+	resolution, code, err := client.ResolveReports(auditContext(c), infos)
 	if err != nil {
-		log.Printf("Error while logging moderation action: %s\n", err.Error())
+		log.Printf("Error while resolving reports: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while resolving reports", "error": err.Error()})
+		return
 	}
+	c.JSON(http.StatusOK, resolution)
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "user pardoned", "pardon_id": pardonId})
+func getReportResolutions(c *gin.Context) {
+	log.Printf("Received request to get report resolutions from ip %s\n", c.ClientIP())
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+	targetId, err := basicfuncs.ConvertQueryParameterToInt(c.Query("target_id"), 0)
+	if err != nil {
+		log.Printf("Error while parsing target_id: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid target_id", "error": err.Error()})
+		return
+	}
+
+	page, code, err := client.GetReportResolutions(c.Query("target_type"), targetId, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting report resolutions: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting report resolutions", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
 func oauthLogin(c *gin.Context) {
@@ -606,41 +1740,193 @@ func oauthLogin(c *gin.Context) {
 		return
 	}
 
-	var token string
-	var code int
+	var tokens models.AuthTokens
 	if strings.ToLower(infos.Provider) == "google" {
-		token, code, err = client.GoogleLogin(infos.Provider, infos.Token)
+		var code int
+		tokens, code, err = client.GoogleLogin(infos.Provider, infos.Token, c.ClientIP(), c.Request.UserAgent())
 		if err != nil {
 			log.Printf("Error while logging in with google: %s\n", err.Error())
-			c.JSON(http.StatusBadRequest, gin.H{"message": "error while logging in with google", "error": err.Error()})
+			c.JSON(code, errorResponse("error while logging in with google", err))
 			return
 		}
-		fmt.Printf("Token: %s, code: %d\n", token, code) // debug, remove
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid provider"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "logged in with oauth successfuly", "token": token})
+	loginResponse(c, "logged in with oauth successfuly", tokens)
+}
+
+func getTimeline(c *gin.Context) {
+	log.Printf("Received request to get timeline from ip %s\n", c.ClientIP())
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	page, code, err := client.GetTimeline(requesterId, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting timeline: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting timeline", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func getNotifications(c *gin.Context) {
+	log.Printf("Received request to get notifications from ip %s\n", c.ClientIP())
+
+	count, err := basicfuncs.ConvertQueryParameterToInt(c.Query("count"), pagination.DefaultCount)
+	if err != nil {
+		log.Printf("Error while parsing count: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid count", "error": err.Error()})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	page, code, err := client.GetNotifications(requesterId, c.Query("cursor"), count)
+	if err != nil {
+		log.Printf("Error while getting notifications: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while getting notifications", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func getUnreadNotificationCount(c *gin.Context) {
+	log.Printf("Received request to get unread notification count from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	count, code, err := client.GetUnreadNotificationCount(requesterId)
+	if err != nil {
+		log.Printf("Error while counting unread notifications: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while counting unread notifications", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+func readNotification(c *gin.Context) {
+	log.Printf("Received request to read notification from ip %s\n", c.ClientIP())
+
+	var infos models.ReadNotificationInfos
+	err := c.ShouldBindJSON(&infos)
+	if err != nil {
+		log.Printf("Error while parsing request body: %s\n", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": err.Error()})
+		return
+	}
+	if infos.NotificationId == 0 {
+		log.Printf("Error while parsing request body: missing fields\n")
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request body", "error": "missing fields"})
+		return
+	}
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	code, err := client.MarkNotificationAsRead(requesterId, infos.NotificationId)
+	if err != nil {
+		log.Printf("Error while reading notification: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while reading notification", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "notification read"})
+}
+
+func readAllNotifications(c *gin.Context) {
+	log.Printf("Received request to read all notifications from ip %s\n", c.ClientIP())
+
+	requesterId := c.GetInt(permissions.RequesterIdKey)
+	code, err := client.MarkAllNotificationsAsRead(requesterId)
+	if err != nil {
+		log.Printf("Error while reading notifications: %s\n", err.Error())
+		c.JSON(code, gin.H{"message": "error while reading notifications", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "notifications read"})
 }
 
 func SetupRoutes(r *gin.Engine) {
 	r.GET("/hello_world", helloWorld)
 	r.POST("/register", register)
 	r.POST("/login", login)
-	r.GET("/refresh_token", refreshToken)
+	r.POST("/login/two_factor", loginTwoFactor)
+	r.POST("/refresh_token", refreshToken)
+	r.POST("/logout", logout)
+	r.POST("/password/forgot", forgotPassword)
+	r.POST("/password/reset", resetPassword)
+	r.POST("/email/verify", verifyEmail)
+	r.GET("/sessions", getSessions)
+	r.POST("/sessions/revoke", revokeSession)
+	r.POST("/sessions/revoke_all", revokeAllSessions)
 	r.GET("/get_user_profile/:user", getUserProfile)
 	r.POST("/follow_user", followUser)
+	r.GET("/users/:user/followers", getFollowers)
+	r.GET("/users/:user/following", getFollowing)
+	r.POST("/users/block", requireActiveUser, blockUser)
+	r.POST("/users/mute", requireActiveUser, muteUser)
+	r.GET("/users/blocked", requireActiveUser, getBlockedUsers)
+	r.GET("/users/muted", requireActiveUser, getMutedUsers)
+	r.GET("/users/email", requireActiveUser, getEmailStatus)
+	r.POST("/users/email/resend", requireActiveUser, resendEmailVerification)
+	r.GET("/users/two_factor", requireActiveUser, getTwoFactorStatus)
+	r.POST("/users/two_factor/enroll", requireActiveUser, beginTotpEnrollment)
+	r.POST("/users/two_factor/confirm", requireActiveUser, confirmTotpEnrollment)
+	r.POST("/users/two_factor/disable", requireActiveUser, disableTotp)
+	r.POST("/users/two_factor/recovery_codes", requireActiveUser, regenerateRecoveryCodes)
+	r.GET("/users/login_history", requireActiveUser, getLoginHistory)
+	r.GET("/users/settings", requireActiveUser, getUserSettings)
+	r.POST("/users/settings", requireActiveUser, updateUserSettings)
+	r.GET("/users/muted_words", requireActiveUser, getMutedWords)
+	r.POST("/users/muted_words", requireActiveUser, muteWord)
 	r.POST("/ask_question", askQuestion)
 	r.GET("/get_questions", getQuestions)
+	r.POST("/questions/block_asker", requireActiveUser, blockAsker)
+	r.POST("/questions/unblock_asker", requireActiveUser, unblockAsker)
+	r.GET("/questions/blocked_askers", requireActiveUser, getBlockedAskers)
+	r.GET("/timeline", requireActiveUser, getTimeline)
+	r.GET("/notifications", requireActiveUser, getNotifications)
+	r.GET("/notifications/unread_count", requireActiveUser, getUnreadNotificationCount)
+	r.POST("/notifications/read", requireActiveUser, readNotification)
+	r.POST("/notifications/read_all", requireActiveUser, readAllNotifications)
+	r.GET("/events", streamEvents)
+	r.POST("/events/ticket", createStreamTicket)
 	r.POST("/answer_question", answerQuestion)
 	r.POST("/like_answer", likeAnswer)
 	r.POST("/delete_answer", deleteAnswer)
 	r.POST("/delete_question", deleteQuestion)
+	r.POST("/report", requireActiveUser, report)
 	r.PUT("/users/update", updateUser)
-	r.POST("/moderation/promote", promoteUser)
-	r.GET("/moderation/get_user_questions/:user", moderationGetUserQuestions)
-	r.POST("/moderation/ban_user", banUser)
-	r.POST("/moderation/pardon_user", pardonUser)
+	r.POST("/moderation/promote", requireActiveUser, permissions.Require(permissions.ManageRoles), promoteUser)
+	r.POST("/moderation/demote", requireActiveUser, permissions.Require(permissions.ManageRoles), demoteUser)
+	r.GET("/moderation/roles", requireActiveUser, permissions.Require(permissions.ManageRoles), getRoles)
+	r.POST("/moderation/roles/two_factor", requireActiveUser, permissions.Require(permissions.ManageRoles), setRoleTwoFactor)
+	r.GET("/moderation/roles/:user", requireActiveUser, permissions.Require(permissions.ManageRoles), getUserRoles)
+	r.GET("/moderation/get_user_questions/:user", requireActiveUser, permissions.Require(permissions.ViewUserQuestions), moderationGetUserQuestions)
+	r.POST("/moderation/ban_user", requireActiveUser, permissions.Require(permissions.BanUsers), banUser)
+	r.POST("/moderation/pardon_user", requireActiveUser, permissions.Require(permissions.PardonUsers), pardonUser)
+	r.GET("/moderation/bans", requireActiveUser, permissions.Require(permissions.BanUsers), getBans)
+	r.GET("/moderation/users/:id/bans", requireActiveUser, permissions.Require(permissions.BanUsers), getUserBans)
+	r.POST("/moderation/edit_ban", requireActiveUser, permissions.Require(permissions.BanUsers), editBan)
+	r.POST("/moderation/add_address_ban", requireActiveUser, permissions.Require(permissions.BanUsers), addAddressBan)
+	r.GET("/moderation/address_bans", requireActiveUser, permissions.Require(permissions.BanUsers), getAddressBans)
+	r.POST("/moderation/remove_address_ban", requireActiveUser, permissions.Require(permissions.BanUsers), removeAddressBan)
+	r.GET("/moderation/users/:id/linked_accounts", requireActiveUser, permissions.Require(permissions.BanUsers), getLinkedAccounts)
+	r.GET("/moderation/users/:id/login_history", requireActiveUser, permissions.Require(permissions.BanUsers), getUserLoginHistory)
+	r.GET("/moderation/held_content", requireActiveUser, permissions.Require(permissions.ReviewContent), getHeldContents)
+	r.POST("/moderation/review_held_content", requireActiveUser, permissions.Require(permissions.ReviewContent), reviewHeldContent)
+	r.POST("/moderation/delete_question", requireActiveUser, permissions.Require(permissions.RemoveContent), moderationDeleteQuestion)
+	r.POST("/moderation/restore_question", requireActiveUser, permissions.Require(permissions.RemoveContent), moderationRestoreQuestion)
+	r.POST("/moderation/delete_answer", requireActiveUser, permissions.Require(permissions.RemoveContent), moderationDeleteAnswer)
+	r.POST("/moderation/restore_answer", requireActiveUser, permissions.Require(permissions.RemoveContent), moderationRestoreAnswer)
+	r.GET("/moderation/reports", requireActiveUser, permissions.Require(permissions.ReviewReports), getReportQueue)
+	r.GET("/moderation/reports/target", requireActiveUser, permissions.Require(permissions.ReviewReports), getTargetReports)
+	r.POST("/moderation/reports/claim", requireActiveUser, permissions.Require(permissions.ReviewReports), claimReports)
+	r.POST("/moderation/reports/resolve", requireActiveUser, permissions.Require(permissions.ReviewReports), resolveReports)
+	r.GET("/moderation/reports/history", requireActiveUser, permissions.Require(permissions.ReviewReports), getReportResolutions)
+	r.GET("/moderation/audit", requireActiveUser, permissions.Require(permissions.ViewAuditLog), getAuditLog)
 	r.POST("/oauth/login", oauthLogin)
 }
@@ -11,6 +11,8 @@ import (
 	"os"
 	"project_truthful/client/basicfuncs"
 	"project_truthful/client/database"
+	"project_truthful/client/pagination"
+	"project_truthful/client/token"
 	"project_truthful/helpunittesting"
 	"project_truthful/models"
 	"testing"
@@ -21,6 +23,25 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// expectActiveUser expects the ban check done on authenticated requests and reports the user as not banned
+func expectActiveUser(mock sqlmock.Sqlmock, userId int) {
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(userId).WillReturnError(sql.ErrNoRows)
+}
+
+// expectReservedLogin expects the password login to be recorded as failed before the password is checked,
+// then the failed logins of the username and of the requests without address before it to be counted
+func expectReservedLogin(mock sqlmock.Sqlmock, username string, usernameFailures int) {
+	mock.ExpectExec("INSERT INTO login_attempt").WithArgs(username, nil, "", "", "password", false).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM login_attempt WHERE success = 0 AND username = ").WithArgs(username, sqlmock.AnyArg(), 1, username, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(usernameFailures))
+	if usernameFailures > 0 {
+		mock.ExpectQuery("SELECT created_at FROM login_attempt WHERE success = 0 AND username = ").WithArgs(username, sqlmock.AnyArg(), 1, username, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	}
+	mock.ExpectQuery("SELECT COUNT(.+) FROM login_attempt WHERE success = 0 AND ip_address = ").WithArgs("", sqlmock.AnyArg(), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
+
 // this function tests the SetupRoutes function
 func TestSetupRoutes(t *testing.T) {
 	router := gin.Default()
@@ -87,8 +108,17 @@ func TestRegister(t *testing.T) {
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	// expect a query to check if the email address is already taken
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs("toto@toto.fr").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	// expect the banned email domains to be checked
+	mock.ExpectQuery("SELECT (.+) FROM address_ban").WithArgs("email_domain").WillReturnRows(sqlmock.NewRows([]string{"id", "ban_type", "value", "author_id", "reason", "created_at", "expires_at"}))
 	// expect a query to insert the user
 	mock.ExpectExec("INSERT INTO user").WithArgs("toto", "toto", "Toto123@", "toto@toto.fr", "1990-01-01").WillReturnResult(sqlmock.NewResult(1, 1))
+	// expect the verification link of the email address to be stored
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE email_verification_token SET used_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO email_verification_token").WithArgs(1, "toto@toto.fr", token.HashEmailVerificationToken("test"), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// expect the first refresh token of the session to be stored
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 1, token.HashRefreshToken("test"), "", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 	r, err = http.NewRequest("POST", "/register", bytes.NewBuffer([]byte(`{"username": "toto", "password": "Toto123@", "email_address": "toto@toto.fr", "birthdate": "1990-01-01"}`)))
 	if err != nil {
 		t.Fatal(err)
@@ -98,7 +128,7 @@ func TestRegister(t *testing.T) {
 	if w.Code != http.StatusCreated {
 		t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
 	}
-	assert.Equal(t, `{"id":1,"message":"User created","token":"test"}`, w.Body.String())
+	assert.Equal(t, `{"id":1,"message":"User created","refresh_token":"test","token":"test"}`, w.Body.String())
 	os.Setenv("IS_TEST", "false")
 }
 
@@ -135,7 +165,8 @@ func TestLogin(t *testing.T) {
 	}
 	defer database.DB.Close()
 
-	// checks with login failure (invalid username)
+	// checks with login failure (invalid username), the answer doesn't tell that the user doesn't exist
+	expectReservedLogin(mock, "toto", 0)
 	mock.ExpectQuery("SELECT (.+) FROM user").WithArgs("toto").WillReturnError(sql.ErrNoRows)
 	r, err = http.NewRequest("POST", "/login", bytes.NewBuffer([]byte(`{"username": "toto", "password": "Toto123@"}`)))
 	if err != nil {
@@ -143,15 +174,32 @@ func TestLogin(t *testing.T) {
 	}
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, r)
-	if w.Code != http.StatusNotFound {
-		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
 	}
-	assert.Equal(t, `{"error":"user not found","message":"error while logging in"}`, w.Body.String())
+	assert.Equal(t, `{"error":"invalid login credentials. Please try again","message":"error while logging in"}`, w.Body.String())
+
+	// checks with too many failures
+	expectReservedLogin(mock, "toto", 10)
+	mock.ExpectExec("DELETE FROM login_attempt WHERE id = ").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	r, _ = http.NewRequest("POST", "/login", bytes.NewBuffer([]byte(`{"username": "toto", "password": "Toto123@"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status code %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	assert.Equal(t, `{"error":"too many failed logins, try again in 900 seconds","message":"error while logging in","retry_after":900}`, w.Body.String())
 
 	// checks with login success
 	os.Setenv("IS_TEST", "true")
+	expectReservedLogin(mock, "toto", 0)
 	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT password FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("Toto123@"))
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("FROM user_totp WHERE user_id = ").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO session").WithArgs("test", 1, token.HashRefreshToken("test"), "", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("FROM login_attempt WHERE user_id = (.+) AND success = 1").WithArgs("", "", 1).WillReturnRows(sqlmock.NewRows([]string{"logins", "from_device"}).AddRow(1, 1))
+	mock.ExpectExec("UPDATE login_attempt SET user_id = (.+), success = (.+) WHERE id = ").WithArgs(1, true, 1).WillReturnResult(sqlmock.NewResult(0, 1))
 	r, err = http.NewRequest("POST", "/login", bytes.NewBuffer([]byte(`{"username": "toto", "password": "Toto123@"}`)))
 	if err != nil {
 		t.Fatal(err)
@@ -161,7 +209,23 @@ func TestLogin(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 	}
-	assert.Equal(t, `{"message":"User logged in","token":"test"}`, w.Body.String())
+	assert.Equal(t, `{"message":"User logged in","refresh_token":"test","token":"test"}`, w.Body.String())
+
+	// checks with a second factor enabled
+	expectReservedLogin(mock, "toto", 0)
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT password FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("Toto123@"))
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("FROM user_totp WHERE user_id = ").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "last_used_step", "enabled"}).AddRow(1, "SECRET", 0, true))
+	mock.ExpectExec("INSERT INTO login_challenge").WithArgs(1, token.HashLoginChallengeToken("test"), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM login_attempt WHERE id = ").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	r, _ = http.NewRequest("POST", "/login", bytes.NewBuffer([]byte(`{"username": "toto", "password": "Toto123@"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `{"challenge_token":"test","message":"two-factor authentication required"}`, w.Body.String())
 	os.Setenv("IS_TEST", "false")
 }
 
@@ -170,8 +234,67 @@ func TestRefreshToken(t *testing.T) {
 	SetupRoutes(router)
 	SetMiddleware(router)
 
+	// With invalid body
+	r, _ := http.NewRequest("POST", "/refresh_token", bytes.NewBufferString("<invalid json>"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	assert.Equal(t, `{"error":"invalid character '\u003c' looking for beginning of value","message":"invalid request body"}`, w.Body.String())
+
+	// With missing refresh token
+	r, _ = http.NewRequest("POST", "/refresh_token", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	assert.Equal(t, `{"error":"missing fields","message":"invalid request body"}`, w.Body.String())
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// With unknown refresh token
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(token.HashRefreshToken("unknown")).WillReturnError(sql.ErrNoRows)
+	r, _ = http.NewRequest("POST", "/refresh_token", bytes.NewBufferString(`{"refresh_token": "unknown"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	assert.Equal(t, `{"error":"invalid refresh token","message":"error while refreshing token"}`, w.Body.String())
+
+	// With valid refresh token
+	os.Setenv("IS_TEST", "true")
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(token.HashRefreshToken("valid")).WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "user_id", "expires_at", "rotated", "revoked"}).AddRow(1, "session", 1, time.Now().Add(time.Hour), false, false))
+	expectActiveUser(mock, 1)
+	mock.ExpectExec("UPDATE session SET rotated_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO session").WithArgs("session", 1, token.HashRefreshToken("test"), "", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+	r, _ = http.NewRequest("POST", "/refresh_token", bytes.NewBufferString(`{"refresh_token": "valid"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `{"message":"Token refreshed","refresh_token":"test","token":"test"}`, w.Body.String())
+	os.Setenv("IS_TEST", "false")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	router := gin.Default()
+	SetupRoutes(router)
+	SetMiddleware(router)
+
 	// With invalid format token
-	r, _ := http.NewRequest("GET", "/refresh_token", nil)
+	r, _ := http.NewRequest("POST", "/logout", nil)
 	r.Header.Set("Authorization", "invalid_token")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, r)
@@ -180,27 +303,89 @@ func TestRefreshToken(t *testing.T) {
 	}
 	assert.Equal(t, `{"error":"missing fields","message":"error while parsing token"}`, w.Body.String())
 
-	// With valid format token, but invalid token
-	r, _ = http.NewRequest("GET", "/refresh_token", nil)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	os.Setenv("IS_TEST", "true")
+	mock.ExpectQuery("SELECT id, session_id, user_id, expires_at").WithArgs(token.HashRefreshToken("refresh")).WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "user_id", "expires_at", "rotated", "revoked"}).AddRow(1, "session", 1, time.Now().Add(time.Hour), false, false))
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 1))
+	r, _ = http.NewRequest("POST", "/logout", bytes.NewBufferString(`{"refresh_token": "refresh"}`))
 	r.Header.Set("Authorization", "Bearer 123456789")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, r)
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 	}
-	assert.Equal(t, `{"error":"token contains an invalid number of segments","message":"error while checking token"}`, w.Body.String())
+	assert.Equal(t, `{"message":"User logged out"}`, w.Body.String())
+	os.Setenv("IS_TEST", "false")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	router := gin.Default()
+	SetupRoutes(router)
+	SetMiddleware(router)
 
-	// With is_test env variable set to true (token is impossible to check due to the fact that the key is not the same)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
 	os.Setenv("IS_TEST", "true")
-	r, _ = http.NewRequest("GET", "/refresh_token", nil)
+	defer os.Setenv("IS_TEST", "false")
+
+	lastUsed := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	expiresAt := lastUsed.Add(token.RefreshTokenDuration)
+	mock.ExpectQuery("SELECT session_id, ip_address, user_agent, created_at, expires_at FROM session").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"session_id", "ip_address", "user_agent", "created_at", "expires_at"}).AddRow("session", "127.0.0.1", "agent", lastUsed, expiresAt))
+	r, _ := http.NewRequest("GET", "/sessions", nil)
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `[{"id":"session","ip_address":"127.0.0.1","user_agent":"agent","last_used_at":"2024-05-01T10:00:00Z","expires_at":"2024-05-31T10:00:00Z"}]`, w.Body.String())
+
+	// revoke one session, missing id
+	r, _ = http.NewRequest("POST", "/sessions/revoke", bytes.NewBufferString(`{}`))
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// revoke one unknown session
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs("unknown", 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	r, _ = http.NewRequest("POST", "/sessions/revoke", bytes.NewBufferString(`{"session_id": "unknown"}`))
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+	assert.Equal(t, `{"error":"session not found","message":"error while revoking session"}`, w.Body.String())
+
+	// revoke all sessions
+	mock.ExpectExec("UPDATE session SET revoked_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	r, _ = http.NewRequest("POST", "/sessions/revoke_all", nil)
 	r.Header.Set("Authorization", "Bearer 123456789")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, r)
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 	}
-	assert.Equal(t, `{"message":"Token refreshed","token":"test"}`, w.Body.String())
-	os.Setenv("IS_TEST", "false")
+	assert.Equal(t, `{"message":"sessions revoked"}`, w.Body.String())
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: %s", err)
+	}
 }
 
 func TestGetUserProfileFail(t *testing.T) {
@@ -270,22 +455,82 @@ func TestGetUserProfileSuccess(t *testing.T) {
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
-	mock.ExpectQuery("SELECT id, question_id, text, created_at FROM answer").WithArgs(1, 0, 10).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at"}).AddRow(1, 1, "answer_text", creationTime))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer").WithArgs(1, 10, 0).WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(1, 1, "answer_text", creationTime, false))
 
-	questionRows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"}).
-		AddRow(1, "question_text", 2, false, 1, creationTime)
-	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at FROM question").WithArgs(1).WillReturnRows(questionRows)
+	questionRows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).
+		AddRow(1, "question_text", 2, false, 1, creationTime, false)
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(questionRows)
 	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("username_author", "display_name_author"))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 
-	r, _ := http.NewRequest("GET", "/get_user_profile/toto", nil)
+	r, _ := http.NewRequest("GET", "/get_user_profile/toto?start=0", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	answerDate, _ := creationTime.MarshalJSON()
+	expectedResponse := `{"id":1,"username":"username","display_name":"display_name","follower_count":1,"following_count":1,"answer_count":1,"is_requesting_self":false,"followed_by_requester":false,"blocked_by_requester":false,"muted_by_requester":false,"has_blocked_requester":false,"answers":[{"id":1,"is_author_anonymous":false,"author":{"id":2,"username":"username_author","display_name":"display_name_author"},"question_text":"question_text","answer_text":"answer_text","answer_date":"","date_answered":` + string(answerDate) + `,"like_count":1,"liked_by_requester":false,"removed_by_moderation":false}]}`
+	assert.JSONEq(t, expectedResponse, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+}
+
+func TestGetUserProfileWithCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+	router := gin.Default()
+	SetupRoutes(router)
+	SetMiddleware(router)
+	pagination.SetSecret("secret")
+
+	r, _ := http.NewRequest("GET", "/get_user_profile/toto?cursor=invalid", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	assert.Equal(t, `{"error":"invalid cursor","message":"error while getting user"}`, w.Body.String())
+
+	// the answers after the cursor are fetched, with one more to know if there is a next page
+	creationTime := time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC)
+	cursor := pagination.Encode(models.Cursor{CreatedAt: creationTime, Id: 5})
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("username", "display_name"))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+	mock.ExpectQuery("SELECT id, question_id, text, created_at, removed_at IS NOT NULL FROM answer WHERE (.+) \\(answer.created_at < \\? OR").WithArgs(1, creationTime, creationTime, 5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "question_id", "text", "created_at", "removed"}).AddRow(4, 1, "answer_text", creationTime, false).AddRow(3, 2, "answer_text", creationTime, false))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(1, "question_text", nil, true, 1, creationTime, false))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, text, author_id, is_author_anonymous, receiver_id, created_at, removed_at IS NOT NULL FROM question").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"}).AddRow(2, "question_text", nil, true, 1, creationTime, false))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+
+	r, _ = http.NewRequest("GET", "/get_user_profile/toto?count=1&cursor="+cursor, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 	}
-	expectedResponse := `{"id":1,"username":"username","display_name":"display_name","follower_count":1,"following_count":1,"answer_count":1,"answers":[{"id":1,"is_author_anonymous":false,"author":{"id":2,"username":"username_author","display_name":"display_name_author"},"question_text":"question_text","answer_text":"answer_text","answer_date":"","date_answered":"` + creationTime.Format("2006-01-02T15:04:05.999999999-07:00") + `","like_count":1}]}`
-	assert.Equal(t, expectedResponse, w.Body.String())
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+	var profile models.UserProfileInfos
+	if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+		t.Fatalf("Error while unmarshalling response: %s", err)
+	}
+	if len(profile.Answers) != 1 || profile.Answers[0].Id != 4 {
+		t.Fatalf("Expected only answer 4, got %+v", profile.Answers)
+	}
+	next, err := pagination.Decode(profile.NextCursor)
+	if err != nil || next.Id != 4 || !next.CreatedAt.Equal(creationTime) {
+		t.Errorf("Expected next cursor to point at answer 4, got %+v, %v", next, err)
+	}
+	assert.Empty(t, w.Header().Get("Deprecation"))
 }
 
 func TestFollowUser(t *testing.T) {
@@ -303,8 +548,26 @@ func TestFollowUser(t *testing.T) {
 	assert.Equal(t, `{"error":"missing fields","message":"error while parsing token"}`, w.Body.String())
 
 	os.Setenv("IS_TEST", "true")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// Test with banned user
+	mock.ExpectQuery("SELECT (.+) FROM ban").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "reason", "created_at", "expires_at"}).AddRow(3, "spam", time.Date(2024, 5, 12, 16, 9, 0, 0, time.UTC), nil))
+	r, _ = http.NewRequest("POST", "/follow_user", bytes.NewBuffer([]byte(`{"user_id":2, "follow":true}`)))
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+	assert.Equal(t, `{"ban":{"id":3,"reason":"spam","created_at":"2024-05-12T16:09:00Z","expires_at":null,"is_permanent":true},"error":"user is banned","message":"error while checking ban status"}`, w.Body.String())
 
 	// Test with nil request body
+	expectActiveUser(mock, 1)
 	r, _ = http.NewRequest("POST", "/follow_user", nil)
 	r.Header.Set("Authorization", "Bearer 123456789")
 	w = httptest.NewRecorder()
@@ -315,6 +578,7 @@ func TestFollowUser(t *testing.T) {
 	assert.Equal(t, `{"error":"invalid request","message":"invalid request body"}`, w.Body.String())
 
 	// Test with invalid request body
+	expectActiveUser(mock, 1)
 	requestBody := []byte(`{"username": "toto"}`)
 	r, _ = http.NewRequest("POST", "/follow_user", bytes.NewBuffer(requestBody))
 	r.Header.Set("Authorization", "Bearer 123456789")
@@ -326,6 +590,7 @@ func TestFollowUser(t *testing.T) {
 	assert.Equal(t, `{"error":"missing fields","message":"invalid request body"}`, w.Body.String())
 
 	// Test with invalid request body
+	expectActiveUser(mock, 1)
 	requestBody = []byte("<invalid json>")
 	r, _ = http.NewRequest("POST", "/follow_user", bytes.NewBuffer(requestBody))
 	r.Header.Set("Authorization", "Bearer 123456789")
@@ -337,16 +602,15 @@ func TestFollowUser(t *testing.T) {
 	assert.Equal(t, `{"error":"invalid character '\u003c' looking for beginning of value","message":"invalid request body"}`, w.Body.String())
 
 	// tests for error when following
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-	database.DB = db
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("INSERT INTO follow").WillReturnError(errors.New("error"))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
 	requestBody = []byte(`{"user_id":2, "follow":true}`)
 	r, _ = http.NewRequest("POST", "/follow_user", bytes.NewBuffer(requestBody))
 	r.Header.Set("Authorization", "Bearer 123456789")
@@ -358,10 +622,16 @@ func TestFollowUser(t *testing.T) {
 	assert.Equal(t, `{"error":"error","message":"error while following user"}`, w.Body.String())
 
 	// tests for following success
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-	mock.ExpectExec("INSERT INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT IGNORE INTO follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT IGNORE INTO timeline_entry").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
 	requestBody = []byte(`{"user_id":2, "follow":true}`)
 	r, _ = http.NewRequest("POST", "/follow_user", bytes.NewBuffer(requestBody))
 	r.Header.Set("Authorization", "Bearer 123456789")
@@ -373,8 +643,12 @@ func TestFollowUser(t *testing.T) {
 	assert.Equal(t, `{"message":"User followed"}`, w.Body.String())
 
 	// tests for unfollowing success
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM follow").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM follow").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM timeline_entry").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
 	requestBody = []byte(`{"user_id":2, "follow":false}`)
 	r, _ = http.NewRequest("POST", "/follow_user", bytes.NewBuffer(requestBody))
 	r.Header.Set("Authorization", "Bearer 123456789")
@@ -431,6 +705,9 @@ func TestAskQuestion(t *testing.T) {
 	body = []byte(`{"user_id": 1, "text":"question"}`)
 	r, _ = http.NewRequest("POST", "/ask_question", bytes.NewBuffer(body))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM asker_block").WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT (.+) FROM user_settings").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"allow_anonymous_questions"}))
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"word"}))
 	mock.ExpectExec("INSERT INTO question").WithArgs("question", "", 1).WillReturnError(errors.New("error"))
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, r)
@@ -443,6 +720,9 @@ func TestAskQuestion(t *testing.T) {
 	body = []byte(`{"user_id": 1, "text":"question"}`)
 	r, _ = http.NewRequest("POST", "/ask_question", bytes.NewBuffer(body))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM asker_block").WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT (.+) FROM user_settings").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"allow_anonymous_questions"}))
+	mock.ExpectQuery("SELECT word FROM muted_word").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"word"}))
 	mock.ExpectExec("INSERT INTO question").WithArgs("question", "", 1).WillReturnResult(sqlmock.NewResult(1, 1))
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, r)
@@ -494,6 +774,7 @@ func TestGetQuestionsWithParameters(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	database.DB = db
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnError(errors.New("error for checking user"))
 	r, _ = http.NewRequest("GET", "/get_questions", nil)
 	r.Header.Set("Authorization", "Bearer token")
@@ -511,18 +792,19 @@ func TestGetQuestionsWithParameters(t *testing.T) {
 	// Generate questions
 	questionTime := time.Now()
 	questions := helpunittesting.GenerateTestQuestions(10, 1, questionTime)
-	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at"})
+	rows := sqlmock.NewRows([]string{"id", "text", "author_id", "is_author_anonymous", "receiver_id", "created_at", "removed"})
 	for _, question := range questions {
-		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt)
+		rows.AddRow(question.Id, question.Text, question.Author.Id, question.IsAuthorAnonymous, question.ReceiverId, question.CreatedAt, question.RemovedByModeration)
 	}
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery("SELECT").WithArgs(1, 0, 10).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WithArgs(1, 10, 0).WillReturnRows(rows)
 
 	for i, question := range questions {
 		mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(question.Id).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 		mock.ExpectQuery("SELECT username, display_name FROM user").WithArgs(question.Author.Id).WillReturnRows(sqlmock.NewRows([]string{"username", "display_name"}).AddRow("username"+fmt.Sprintf("%d", i), "display_name"+fmt.Sprintf("%d", i)))
 	}
-	r, _ = http.NewRequest("GET", "/get_questions", nil)
+	r, _ = http.NewRequest("GET", "/get_questions?start=0", nil)
 	r.Header.Set("Authorization", "Bearer token")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, r)
@@ -532,6 +814,7 @@ func TestGetQuestionsWithParameters(t *testing.T) {
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
 	var returnedQuestions []models.Question
 	if err := json.Unmarshal(w.Body.Bytes(), &returnedQuestions); err != nil {
 		t.Errorf("Error while unmarshalling response: %s", err)
@@ -581,8 +864,15 @@ func TestAnswerQuestion(t *testing.T) {
 	assert.Equal(t, `{"error":"token contains an invalid number of segments","message":"error while checking token"}`, w.Body.String())
 
 	os.Setenv("IS_TEST", "true")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
 
 	// Test with invalid request body
+	expectActiveUser(mock, 1)
 	requestBody := bytes.NewBuffer([]byte(`<invalid json>`))
 	r, _ = http.NewRequest("POST", "/answer_question", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
@@ -593,14 +883,8 @@ func TestAnswerQuestion(t *testing.T) {
 	}
 	assert.Equal(t, `{"error":"invalid character '\u003c' looking for beginning of value","message":"error while parsing request body"}`, w.Body.String())
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-	database.DB = db
-
 	// Test with error when answering question
+	expectActiveUser(mock, 1)
 	str := basicfuncs.GenerateRandomString(1500)
 	requestBody = bytes.NewBuffer([]byte(`{"question_id": 1, "text": "` + str + `"}`))
 	r, _ = http.NewRequest("POST", "/answer_question", requestBody)
@@ -614,10 +898,17 @@ func TestAnswerQuestion(t *testing.T) {
 
 	// Test success
 	requestBody = bytes.NewBuffer([]byte(`{"question_id": 1, "text": "answer"}`))
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT email, email_verified FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow("toto@toto.fr", true))
 	mock.ExpectQuery("SELECT receiver_id FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT removed_at IS NOT NULL FROM question").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"removed"}).AddRow(false))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM held_content").WithArgs(1, "pending").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO answer").WithArgs(1, 1, "answer", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT IGNORE INTO timeline_entry").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
 	r, _ = http.NewRequest("POST", "/answer_question", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
 	w = httptest.NewRecorder()
@@ -647,9 +938,17 @@ func TestLikeAnswerErrors(t *testing.T) {
 	}
 	assert.Equal(t, `{"error":"token contains an invalid number of segments","message":"error while checking token"}`, w.Body.String())
 
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
 	os.Setenv("IS_TEST", "true")
 
 	// Test with invalid request body
+	expectActiveUser(mock, 1)
 	requestBody := bytes.NewBuffer([]byte(`<invalid json>`))
 	r, _ = http.NewRequest("POST", "/like_answer", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
@@ -661,19 +960,13 @@ func TestLikeAnswerErrors(t *testing.T) {
 	assert.Equal(t, `{"error":"invalid character '\u003c' looking for beginning of value","message":"error while parsing request body"}`, w.Body.String())
 	os.Setenv("IS_TEST", "false")
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-	database.DB = db
-
 	// Test with error when liking answer
 	os.Setenv("IS_TEST", "true")
 	requestBody = bytes.NewBuffer([]byte(`{"answer_id": 1, "like": true}`))
 	r, _ = http.NewRequest("POST", "/like_answer", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
 	w = httptest.NewRecorder()
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnError(errors.New("error when getting user"))
 	router.ServeHTTP(w, r)
 	if w.Code != http.StatusInternalServerError {
@@ -686,6 +979,7 @@ func TestLikeAnswerErrors(t *testing.T) {
 	r, _ = http.NewRequest("POST", "/like_answer", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
 	w = httptest.NewRecorder()
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1, 1).WillReturnError(errors.New("error when getting user"))
 	router.ServeHTTP(w, r)
 	if w.Code != http.StatusInternalServerError {
@@ -713,8 +1007,11 @@ func TestLikeAnswerSuccess(t *testing.T) {
 	r, _ := http.NewRequest("POST", "/like_answer", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
 	w := httptest.NewRecorder()
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_block").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectExec("INSERT INTO answer_like").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(1, 1))
 	router.ServeHTTP(w, r)
@@ -746,6 +1043,7 @@ func TestUnlikeAnswerSuccess(t *testing.T) {
 	r, _ := http.NewRequest("POST", "/like_answer", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
 	w := httptest.NewRecorder()
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT COUNT(.+) FROM answer_like").WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	mock.ExpectExec("DELETE FROM answer_like").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(1, 1))
 	router.ServeHTTP(w, r)
@@ -775,7 +1073,14 @@ func TestDeleteAnswer(t *testing.T) {
 	assert.Equal(t, `{"error":"token contains an invalid number of segments","message":"error while checking token"}`, w.Body.String())
 
 	os.Setenv("IS_TEST", "true")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
 
+	expectActiveUser(mock, 1)
 	requestBody := bytes.NewBuffer([]byte(`<invalid json>`))
 	r, _ = http.NewRequest("POST", "/delete_answer", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
@@ -786,14 +1091,8 @@ func TestDeleteAnswer(t *testing.T) {
 	}
 	assert.Equal(t, `{"error":"invalid character '\u003c' looking for beginning of value","message":"error while parsing request body"}`, w.Body.String())
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-	database.DB = db
-
 	// Test for error when marking answer as deleted
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(1).WillReturnError(errors.New("error"))
 	requestBody = bytes.NewBuffer([]byte(`{"answer_id": 1, "like": false}`))
 	r, _ = http.NewRequest("POST", "/delete_answer", requestBody)
@@ -809,6 +1108,7 @@ func TestDeleteAnswer(t *testing.T) {
 	assert.Equal(t, `{"error":"error","message":"error while deleting answer"}`, w.Body.String())
 
 	// Test for successfully when marking answer as deleted
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT user_id FROM answer").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
 	mock.ExpectExec("UPDATE answer").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
 	requestBody = bytes.NewBuffer([]byte(`{"answer_id": 1, "like": false}`))
@@ -841,7 +1141,14 @@ func TestDeleteQuestion(t *testing.T) {
 	assert.Equal(t, `{"error":"token contains an invalid number of segments","message":"error while checking token"}`, w.Body.String())
 
 	os.Setenv("IS_TEST", "true")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
 
+	expectActiveUser(mock, 1)
 	requestBody := bytes.NewBuffer([]byte(`<invalid json>`))
 	r, _ = http.NewRequest("POST", "/delete_question", requestBody)
 	r.Header.Set("Authorization", "Bearer valid_token")
@@ -852,14 +1159,8 @@ func TestDeleteQuestion(t *testing.T) {
 	}
 	assert.Equal(t, `{"error":"invalid character '\u003c' looking for beginning of value","message":"error while parsing request body"}`, w.Body.String())
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-	database.DB = db
-
 	// Test for error when marking question as deleted
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT receiver_id FROM question WHERE id").WithArgs(2).WillReturnError(errors.New("test error"))
 	requestBody = bytes.NewBuffer([]byte(`{"question_id": 2}`))
 	r, _ = http.NewRequest("POST", "/delete_question", requestBody)
@@ -874,6 +1175,7 @@ func TestDeleteQuestion(t *testing.T) {
 	}
 
 	// Test for successfully when marking question as deleted
+	expectActiveUser(mock, 1)
 	mock.ExpectQuery("SELECT receiver_id FROM question WHERE id").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
 	mock.ExpectQuery("SELECT id FROM answer").WithArgs(7).WillReturnError(sql.ErrNoRows)
 	mock.ExpectExec("UPDATE question SET has_been_deleted = 1").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
@@ -890,3 +1192,117 @@ func TestDeleteQuestion(t *testing.T) {
 	}
 	assert.Equal(t, `{"message":"question deleted"}`, w.Body.String())
 }
+
+func TestModerationRoles(t *testing.T) {
+	router := gin.Default()
+	SetupRoutes(router)
+	SetMiddleware(router)
+
+	os.Setenv("IS_TEST", "true")
+	defer os.Setenv("IS_TEST", "false")
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	// requester without the permission
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "roles.manage").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	r, _ := http.NewRequest("POST", "/moderation/promote", bytes.NewBuffer([]byte(`{"user_id":2, "promote_type":"moderator"}`)))
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+	assert.Equal(t, `{"error":"missing permission roles.manage","message":"error while checking permissions"}`, w.Body.String())
+
+	// promote success, logged as a role grant
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "roles.manage").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_role").WithArgs(2, 2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "role.grant", "user", 2, sqlmock.AnyArg(), sqlmock.AnyArg(), "agent").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	r, _ = http.NewRequest("POST", "/moderation/promote", bytes.NewBuffer([]byte(`{"user_id":2, "promote_type":"moderator"}`)))
+	r.Header.Set("Authorization", "Bearer 123456789")
+	r.Header.Set("User-Agent", "agent")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `{"message":"user promoted"}`, w.Body.String())
+
+	// demote success, logged as a role revocation
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "roles.manage").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM role").WithArgs("moderator").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("moderator"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_role").WithArgs(2, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "role.revoke", "user", 2, sqlmock.AnyArg(), sqlmock.AnyArg(), "agent").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	r, _ = http.NewRequest("POST", "/moderation/demote", bytes.NewBuffer([]byte(`{"user_id":2, "role":"moderator"}`)))
+	r.Header.Set("Authorization", "Bearer 123456789")
+	r.Header.Set("User-Agent", "agent")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `{"message":"user demoted"}`, w.Body.String())
+
+	// list roles
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "roles.manage").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT role.id, role.name, permission.name, role.requires_two_factor FROM role").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "permission", "requires_two_factor"}).AddRow(2, "moderator", "users.ban", false))
+	r, _ = http.NewRequest("GET", "/moderation/roles", nil)
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `[{"id":2,"name":"moderator","permissions":["users.ban"],"requires_two_factor":false}]`, w.Body.String())
+
+	// require a second factor for a role
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "roles.manage").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT role.id, role.name, permission.name, role.requires_two_factor FROM role").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "permission", "requires_two_factor"}).AddRow(2, "moderator", "users.ban", false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE role SET requires_two_factor").WithArgs(true, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO moderation_logging").WithArgs(1, "role.edit", "role", 2, `{"before":{"requires_two_factor":false},"after":{"requires_two_factor":true}}`, "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	r, _ = http.NewRequest("POST", "/moderation/roles/two_factor", bytes.NewBuffer([]byte(`{"role": "moderator", "required": true}`)))
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `{"message":"role updated"}`, w.Body.String())
+
+	// list roles of a user
+	expectActiveUser(mock, 1)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM user_role JOIN role_permission").WithArgs(1, "roles.manage").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM user").WithArgs("toto").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT role.name FROM user_role").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("moderator"))
+	r, _ = http.NewRequest("GET", "/moderation/roles/toto", nil)
+	r.Header.Set("Authorization", "Bearer 123456789")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	assert.Equal(t, `{"roles":["moderator"]}`, w.Body.String())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Error while checking expectations: %s", err.Error())
+	}
+}
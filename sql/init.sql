@@ -1,158 +1,6 @@
--- Adminer 4.8.1 MySQL 8.0.30 dump
-
-SET NAMES utf8;
-SET time_zone = '+00:00';
-SET foreign_key_checks = 0;
-SET sql_mode = 'NO_AUTO_VALUE_ON_ZERO';
+-- Creates the database, the schema itself is managed by the server migrations (server/migrations/sql).
+-- Apply them with `go run . migrate up` from the server directory.
 
 SET NAMES utf8mb4;
 
-CREATE DATABASE `project_truthful` /*!40100 DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci */ /*!80016 DEFAULT ENCRYPTION='N' */;
-USE `project_truthful`;
-
-DROP TABLE IF EXISTS `user`;
-CREATE TABLE `user` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `username` varchar(30) NOT NULL,
-  `email` varchar(319) NOT NULL,
-  `display_name` varchar(30) NOT NULL,
-  `password` char(60) NOT NULL,
-  `birthdate` date NOT NULL,
-  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
-  `is_moderator` tinyint(1) NOT NULL DEFAULT '0',
-  `is_admin` tinyint(1) NOT NULL DEFAULT '0',
-  PRIMARY KEY (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-DROP TABLE IF EXISTS `question`;
-CREATE TABLE `question` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `receiver_id` int unsigned NOT NULL,
-  `author_id` int unsigned NULL,
-  `author_ip_address` varchar(45) NOT NULL,
-  `is_author_anonymous` tinyint(1) NOT NULL DEFAULT '1',
-  `text` varchar(500) NOT NULL,
-  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
-  `has_been_deleted` tinyint(1) NOT NULL DEFAULT '0',
-  `deleted_at` timestamp NULL DEFAULT NULL,
-  PRIMARY KEY (`id`),
-  KEY `author_id` (`author_id`),
-  CONSTRAINT `question_ibfk_1` FOREIGN KEY (`author_id`) REFERENCES `user` (`id`),
-  KEY `receiver_id` (`receiver_id`),
-  CONSTRAINT `question_ibfk_2` FOREIGN KEY (`receiver_id`) REFERENCES `user` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-DROP TABLE IF EXISTS `answer`;
-CREATE TABLE `answer` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `user_id` int unsigned NOT NULL,
-  `question_id` int unsigned NOT NULL,
-  `text` varchar(1000) NOT NULL,
-  `answerer_ip_address` varchar(45) NOT NULL,
-  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
-  `has_been_deleted` tinyint(1) NOT NULL DEFAULT '0',
-  `deleted_at` timestamp NULL DEFAULT NULL,
-  PRIMARY KEY (`id`),
-  KEY `question_id` (`question_id`),
-  CONSTRAINT `answer_ibfk_1` FOREIGN KEY (`question_id`) REFERENCES `question` (`id`),
-  KEY `user_id` (`user_id`),
-  CONSTRAINT `answer_ibfk_2` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-
-DROP TABLE IF EXISTS `answer_like`;
-CREATE TABLE `answer_like` (
-  `id` int NOT NULL AUTO_INCREMENT,
-  `answer_id` int unsigned NOT NULL,
-  `user_id` int unsigned NOT NULL,
-  PRIMARY KEY (`id`),
-  KEY `answer_id` (`answer_id`),
-  KEY `user_id` (`user_id`),
-  CONSTRAINT `answer_like_ibfk_1` FOREIGN KEY (`answer_id`) REFERENCES `answer` (`id`),
-  CONSTRAINT `answer_like_ibfk_2` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-
-DROP TABLE IF EXISTS `follow`;
-CREATE TABLE `follow` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `follower` int unsigned NOT NULL,
-  `followed` int unsigned NOT NULL,
-  PRIMARY KEY (`id`),
-  KEY `follower` (`follower`),
-  KEY `followed` (`followed`),
-  CONSTRAINT `follow_ibfk_1` FOREIGN KEY (`follower`) REFERENCES `user` (`id`),
-  CONSTRAINT `follow_ibfk_2` FOREIGN KEY (`followed`) REFERENCES `user` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-DROP TABLE IF EXISTS `ban`;	
-CREATE TABLE `ban` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `user_id` int unsigned NOT NULL,
-  `author_id` int unsigned NOT NULL,
-  `reason` varchar(1000) NULL DEFAULT NULL,
-  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
-  `expires_at` timestamp NULL DEFAULT NULL,
-  PRIMARY KEY (`id`),
-  KEY `user_id` (`user_id`),
-  KEY `author_id` (`author_id`),
-  CONSTRAINT `ban_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
-  CONSTRAINT `ban_ibfk_2` FOREIGN KEY (`author_id`) REFERENCES `user` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-DROP TABLE IF EXISTS `pardon`;
-CREATE TABLE `pardon` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `ban_id` int unsigned NOT NULL,
-  `pardoner_id` int unsigned NOT NULL,
-  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (`id`),
-  KEY `ban_id` (`ban_id`),
-  KEY `pardoner_id` (`pardoner_id`),
-  CONSTRAINT `pardon_ibfk_1` FOREIGN KEY (`ban_id`) REFERENCES `ban` (`id`),
-  CONSTRAINT `pardon_ibfk_2` FOREIGN KEY (`pardoner_id`) REFERENCES `user` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-DROP TABLE IF EXISTS `moderation_logging`;
-CREATE TABLE `moderation_logging` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `user_id` int unsigned NOT NULL,
-  `action` varchar(100) NOT NULL,
-  `target_id` int unsigned NULL,
-  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (`id`),
-  KEY `user_id` (`user_id`),
-  KEY `target_id` (`target_id`),
-  CONSTRAINT `moderation_logging_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
-  CONSTRAINT `moderation_logging_ibfk_2` FOREIGN KEY (`target_id`) REFERENCES `user` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-DROP TABLE IF EXISTS `rate_limit`;
-CREATE TABLE `rate_limit` (
-  `ip_address` varchar(45) NOT NULL PRIMARY KEY,
-  `request_count` int unsigned NOT NULL DEFAULT '0',
-  `last_updated` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
-DROP TABLE IF EXISTS `oauth_provider`;
-CREATE TABLE `oauth_provider` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `name` varchar(255) NOT NULL,
-  PRIMARY KEY (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-INSERT INTO `oauth_provider` (`name`) VALUES ('Google');
-
-DROP TABLE IF EXISTS `oauth_login`;
-CREATE TABLE `oauth_login` (
-  `id` int unsigned NOT NULL AUTO_INCREMENT,
-  `user_id` int unsigned NOT NULL,
-  `oauth_provider_id` int unsigned NOT NULL,
-  `subject_id` varchar(255) NOT NULL,
-  PRIMARY KEY (`id`),
-  KEY `user_id` (`user_id`),
-  KEY `oauth_provider_id` (`oauth_provider_id`),
-  CONSTRAINT `oauth_login_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`),
-  CONSTRAINT `oauth_login_ibfk_2` FOREIGN KEY (`oauth_provider_id`) REFERENCES `oauth_provider` (`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
--- 2024-05-12 16:09:00
\ No newline at end of file
+CREATE DATABASE IF NOT EXISTS `project_truthful` /*!40100 DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci */ /*!80016 DEFAULT ENCRYPTION='N' */;